@@ -0,0 +1,165 @@
+package audit
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"time"
+
+	"phenix/store"
+)
+
+// Origins of audit entries.
+const (
+	OriginAPI    = "api"
+	OriginBroker = "broker"
+	OriginCLI    = "cli"
+	OriginGRPC   = "grpc"
+)
+
+// Outcomes of audited actions.
+const (
+	OutcomeSuccess = "success"
+	OutcomeFailure = "failure"
+)
+
+// Entry is a single audited action.
+type Entry struct {
+	ID        string    `json:"id"`
+	Timestamp time.Time `json:"timestamp"`
+
+	Origin string `json:"origin"`
+	User   string `json:"user"`
+	Role   string `json:"role"`
+
+	Verb         string `json:"verb"`
+	Resource     string `json:"resource"`
+	ResourceName string `json:"resourceName,omitempty"`
+	Experiment   string `json:"experiment,omitempty"`
+
+	// Digest is the hex encoded SHA-256 digest of the request body (or command
+	// arguments), so what was requested can be verified without storing it.
+	Digest string `json:"digest,omitempty"`
+
+	Outcome  string        `json:"outcome"`
+	Status   int           `json:"status,omitempty"`
+	Duration time.Duration `json:"duration"`
+}
+
+// Entries is a list of audit entries.
+type Entries []Entry
+
+// Record writes the given entry to the event store.
+func Record(e Entry) error {
+	target := e.Resource
+
+	if e.ResourceName != "" {
+		target += " " + e.ResourceName
+	}
+
+	event := store.NewEvent("%s %s %s (%s)", e.User, e.Verb, target, e.Outcome)
+	event.Type = store.EventTypeAudit
+
+	if !e.Timestamp.IsZero() {
+		event.Timestamp = e.Timestamp
+	}
+
+	event.
+		WithMetadata("origin", e.Origin).
+		WithMetadata("user", e.User).
+		WithMetadata("role", e.Role).
+		WithMetadata("verb", e.Verb).
+		WithMetadata("resource", e.Resource).
+		WithMetadata("outcome", e.Outcome).
+		WithMetadata("duration", e.Duration.String())
+
+	optional := map[string]string{
+		"resource-name": e.ResourceName,
+		"experiment":    e.Experiment,
+		"digest":        e.Digest,
+	}
+
+	for k, v := range optional {
+		if v != "" {
+			event.WithMetadata(k, v)
+		}
+	}
+
+	if e.Status != 0 {
+		event.WithMetadata("status", strconv.Itoa(e.Status))
+	}
+
+	if err := store.AddEvent(*event); err != nil {
+		return fmt.Errorf("recording audit entry: %w", err)
+	}
+
+	return nil
+}
+
+// List returns the audit entries matching the given options, most recent
+// first.
+func List(opts ...Option) (Entries, error) {
+	o := newOptions(opts...)
+
+	query := store.EventQuery{
+		Types:    []store.EventType{store.EventTypeAudit},
+		Metadata: make(map[string]string),
+		Since:    o.since,
+		Until:    o.until,
+		Limit:    o.limit,
+	}
+
+	filters := map[string]string{
+		"user":       o.user,
+		"experiment": o.experiment,
+		"resource":   o.resource,
+		"outcome":    o.outcome,
+	}
+
+	for k, v := range filters {
+		if v != "" {
+			query.Metadata[k] = v
+		}
+	}
+
+	events, err := store.QueryEvents(query)
+	if err != nil {
+		return nil, fmt.Errorf("querying audit events: %w", err)
+	}
+
+	entries := make(Entries, len(events))
+
+	for i, e := range events {
+		entries[i] = entryFromEvent(e)
+	}
+
+	return entries, nil
+}
+
+// Digest returns the hex encoded SHA-256 digest of the given data.
+func Digest(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func entryFromEvent(e store.Event) Entry {
+	entry := Entry{
+		ID:           e.ID,
+		Timestamp:    e.Timestamp,
+		Origin:       e.Metadata["origin"],
+		User:         e.Metadata["user"],
+		Role:         e.Metadata["role"],
+		Verb:         e.Metadata["verb"],
+		Resource:     e.Metadata["resource"],
+		ResourceName: e.Metadata["resource-name"],
+		Experiment:   e.Metadata["experiment"],
+		Digest:       e.Metadata["digest"],
+		Outcome:      e.Metadata["outcome"],
+	}
+
+	entry.Status, _ = strconv.Atoi(e.Metadata["status"])
+	entry.Duration, _ = time.ParseDuration(e.Metadata["duration"])
+
+	return entry
+}
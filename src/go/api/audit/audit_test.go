@@ -0,0 +1,84 @@
+package audit
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"phenix/store"
+)
+
+func TestRecordAndList(t *testing.T) {
+	store.DefaultStore = store.NewBoltDB()
+
+	if err := store.DefaultStore.Init(store.Endpoint("bolt://" + filepath.Join(t.TempDir(), "phenix.bdb"))); err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+
+	now := time.Now()
+
+	entries := []Entry{
+		{Timestamp: now.Add(-2 * time.Hour), Origin: OriginAPI, User: "admin", Role: "Global Admin", Verb: "create", Resource: "experiments/start", ResourceName: "foo", Experiment: "foo", Outcome: OutcomeSuccess, Status: 200, Duration: time.Second},
+		{Timestamp: now.Add(-1 * time.Hour), Origin: OriginCLI, User: "root", Verb: "stop", Resource: "experiment", ResourceName: "foo", Experiment: "foo", Outcome: OutcomeFailure},
+		{Timestamp: now, Origin: OriginAPI, User: "admin", Verb: "delete", Resource: "experiments", ResourceName: "bar", Experiment: "bar", Digest: Digest([]byte("{}")), Outcome: OutcomeSuccess, Status: 204},
+	}
+
+	for _, e := range entries {
+		if err := Record(e); err != nil {
+			t.Log(err)
+			t.FailNow()
+		}
+	}
+
+	// Non-audit events should never be returned.
+	if err := store.AddEvent(*store.NewInfoEvent("foo").WithMetadata("user", "admin")); err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+
+	queries := []struct {
+		opts     []Option
+		expected []string
+	}{
+		{nil, []string{"delete", "stop", "create"}},
+		{[]Option{User("admin")}, []string{"delete", "create"}},
+		{[]Option{Experiment("foo")}, []string{"stop", "create"}},
+		{[]Option{Outcome(OutcomeFailure)}, []string{"stop"}},
+		{[]Option{Since(now.Add(-90 * time.Minute)), Until(now)}, []string{"stop"}},
+		{[]Option{User("admin"), Limit(1)}, []string{"delete"}},
+	}
+
+	for _, q := range queries {
+		got, err := List(q.opts...)
+		if err != nil {
+			t.Log(err)
+			t.FailNow()
+		}
+
+		var verbs []string
+
+		for _, e := range got {
+			verbs = append(verbs, e.Verb)
+		}
+
+		if len(verbs) != len(q.expected) {
+			t.Logf("expected entries %v, got %v", q.expected, verbs)
+			t.FailNow()
+		}
+
+		for i := range verbs {
+			if verbs[i] != q.expected[i] {
+				t.Logf("expected entries %v, got %v", q.expected, verbs)
+				t.FailNow()
+			}
+		}
+	}
+
+	got, _ := List(Resource("experiments/start"))
+
+	if len(got) != 1 || got[0].Status != 200 || got[0].Duration != time.Second || got[0].Role != "Global Admin" {
+		t.Logf("expected entry details to round trip, got %+v", got)
+		t.FailNow()
+	}
+}
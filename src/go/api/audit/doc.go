@@ -0,0 +1,7 @@
+// Implementation of the phenix audit API.
+//
+// Audit entries record who did what (and how it turned out) for every
+// mutating REST API call, gRPC call, WebSocket broker request, and CLI command. They are
+// stored as events of type `audit` in the phenix event store, with the entry
+// details kept in the event metadata so they can be queried.
+package audit
@@ -0,0 +1,78 @@
+package audit
+
+import "time"
+
+type Option func(*options)
+
+type options struct {
+	user       string
+	experiment string
+	resource   string
+	outcome    string
+
+	since time.Time
+	until time.Time
+	limit int
+}
+
+func newOptions(opts ...Option) options {
+	var o options
+
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	return o
+}
+
+// User limits audit entries to those for the given user.
+func User(u string) Option {
+	return func(o *options) {
+		o.user = u
+	}
+}
+
+// Experiment limits audit entries to those for the given experiment.
+func Experiment(e string) Option {
+	return func(o *options) {
+		o.experiment = e
+	}
+}
+
+// Resource limits audit entries to those for the given resource (e.g.,
+// `experiments/start`).
+func Resource(r string) Option {
+	return func(o *options) {
+		o.resource = r
+	}
+}
+
+// Outcome limits audit entries to those with the given outcome (success or
+// failure).
+func Outcome(oc string) Option {
+	return func(o *options) {
+		o.outcome = oc
+	}
+}
+
+// Since limits audit entries to those recorded at or after the given time.
+func Since(t time.Time) Option {
+	return func(o *options) {
+		o.since = t
+	}
+}
+
+// Until limits audit entries to those recorded before the given time.
+func Until(t time.Time) Option {
+	return func(o *options) {
+		o.until = t
+	}
+}
+
+// Limit limits the number of audit entries returned to the given number of
+// most recent entries.
+func Limit(l int) Option {
+	return func(o *options) {
+		o.limit = l
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"strings"
 
 	"phenix/api/experiment"
+	"phenix/scheduler"
 	"phenix/util"
 	"phenix/util/mm"
 	"phenix/util/mm/mmcli"
@@ -32,6 +33,12 @@ type ImageDetails struct {
 var DefaultClusterFiles ClusterFiles = new(MMClusterFiles)
 var mmFilesDirectory = util.GetMMFilesDirectory()
 
+func init() {
+	// The scheduler can't import this package without an import cycle, so the
+	// images present on each cluster node are registered with it instead.
+	scheduler.HostImages = hostImageSizes
+}
+
 type ClusterFiles interface {
 	// Get list of VM disk images, container filesystems, or both.
 	// Assumes disk images have `.qc2` or `.qcow2` extension.
@@ -40,6 +47,10 @@ type ClusterFiles interface {
 	// (such as `base-images` and `container-fs`).
 	GetImages(expName string, kind ImageKind) ([]ImageDetails, error)
 
+	// Get list of VM disk images, container filesystems, or both present on each
+	// cluster node, keyed by node name.
+	GetHostImages(kind ImageKind) (map[string][]ImageDetails, error)
+
 	GetExperimentFileNames(exp string) ([]string, error)
 
 	// Looks in experiment directory on each cluster node for matching filenames
@@ -61,6 +72,10 @@ func GetImages(expName string, kind ImageKind) ([]ImageDetails, error) {
 	return DefaultClusterFiles.GetImages(expName, kind)
 }
 
+func GetHostImages(kind ImageKind) (map[string][]ImageDetails, error) {
+	return DefaultClusterFiles.GetHostImages(kind)
+}
+
 func GetExperimentFileNames(exp string) ([]string, error) {
 	return DefaultClusterFiles.GetExperimentFileNames(exp)
 }
@@ -114,6 +129,61 @@ func (MMClusterFiles) GetImages(expName string, kind ImageKind) ([]ImageDetails,
 	return images, nil
 }
 
+func (MMClusterFiles) GetHostImages(kind ImageKind) (map[string][]ImageDetails, error) {
+	images := make(map[string][]ImageDetails)
+
+	// First get file listings from mesh, then from headnode.
+	commands := []string{"mesh send all file list", "file list"}
+
+	cmd := mmcli.NewCommand()
+
+	for _, command := range commands {
+		cmd.Command = command
+
+		for _, row := range mmcli.RunTabular(cmd) {
+			// Only look in the base directory
+			if row["dir"] != "" {
+				continue
+			}
+
+			image, ok, err := imageFromFileRow(row)
+			if err != nil {
+				return nil, err
+			}
+
+			// Only return image types that were requested
+			if !ok || kind&image.Kind == 0 {
+				continue
+			}
+
+			images[row["host"]] = append(images[row["host"]], image)
+		}
+	}
+
+	return images, nil
+}
+
+// hostImageSizes returns the sizes of the VM disk images present on each
+// cluster node, keyed by node name and then image name.
+func hostImageSizes() (map[string]map[string]int, error) {
+	images, err := GetHostImages(VM_IMAGE)
+	if err != nil {
+		return nil, err
+	}
+
+	sizes := make(map[string]map[string]int)
+
+	for host, details := range images {
+		sizes[host] = make(map[string]int)
+
+		for _, image := range details {
+			sizes[host][image.Name] = image.Size
+		}
+	}
+
+	return sizes, nil
+}
+
 func (MMClusterFiles) GetExperimentFileNames(exp string) ([]string, error) {
 	// Using a map here to weed out duplicates.
 	matches := make(map[string]struct{})
@@ -289,37 +359,20 @@ func getAllFiles(details map[string]ImageDetails) error {
 				continue
 			}
 
-			baseName := filepath.Base(row["name"])
-
 			// Avoid adding the same image twice
-			if _, ok := details[baseName]; ok {
+			if _, ok := details[filepath.Base(row["name"])]; ok {
 				continue
 			}
 
-			image := ImageDetails{
-				Name:     baseName,
-				FullPath: util.GetMMFullPath(row["name"]),
+			image, ok, err := imageFromFileRow(row)
+			if err != nil {
+				return err
 			}
 
-			if strings.HasSuffix(image.Name, ".qc2") || strings.HasSuffix(image.Name, ".qcow2") {
-				image.Kind = VM_IMAGE
-			} else if strings.HasSuffix(image.Name, "_rootfs.tgz") {
-				image.Kind = CONTAINER_IMAGE
-			} else if strings.HasSuffix(image.Name, ".hdd") {
-				image.Kind = VM_IMAGE
-			} else if strings.HasSuffix(image.Name, ".iso") {
-				image.Kind = ISO_IMAGE
-			} else {
+			if !ok {
 				continue
 			}
 
-			var err error
-
-			image.Size, err = strconv.Atoi(row["size"])
-			if err != nil {
-				return fmt.Errorf("getting size of file: %w", err)
-			}
-
 			details[image.Name] = image
 		}
 	}
@@ -328,6 +381,36 @@ func getAllFiles(details map[string]ImageDetails) error {
 
 }
 
+// imageFromFileRow converts a row of minimega `file list` output to image
+// details, returning false if the file isn't an image.
+func imageFromFileRow(row map[string]string) (ImageDetails, bool, error) {
+	image := ImageDetails{
+		Name:     filepath.Base(row["name"]),
+		FullPath: util.GetMMFullPath(row["name"]),
+	}
+
+	if strings.HasSuffix(image.Name, ".qc2") || strings.HasSuffix(image.Name, ".qcow2") {
+		image.Kind = VM_IMAGE
+	} else if strings.HasSuffix(image.Name, "_rootfs.tgz") {
+		image.Kind = CONTAINER_IMAGE
+	} else if strings.HasSuffix(image.Name, ".hdd") {
+		image.Kind = VM_IMAGE
+	} else if strings.HasSuffix(image.Name, ".iso") {
+		image.Kind = ISO_IMAGE
+	} else {
+		return image, false, nil
+	}
+
+	var err error
+
+	image.Size, err = strconv.Atoi(row["size"])
+	if err != nil {
+		return image, false, fmt.Errorf("getting size of file: %w", err)
+	}
+
+	return image, true, nil
+}
+
 // Retrieves all the unique image names defined in the topology
 func getTopologyFiles(expName string, details map[string]ImageDetails) error {
 	// Retrieve the experiment
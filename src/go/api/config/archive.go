@@ -0,0 +1,414 @@
+package config
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+	"time"
+
+	"phenix/store"
+	"phenix/version"
+)
+
+// ArchiveVersion is the version of the archive format written by Export.
+// Import refuses archives with a newer version than this.
+const ArchiveVersion = 1
+
+const (
+	archiveManifest = "manifest.json"
+
+	// archiveEventsPerEntry is the number of events written to each
+	// `events/<n>.jsonl` archive entry, which limits how much of the archive
+	// has to be buffered in memory at once.
+	archiveEventsPerEntry = 1000
+)
+
+// ArchiveManifest is the first entry in every archive written by Export. It
+// identifies the archive format version.
+type ArchiveManifest struct {
+	Version       int       `json:"version"`
+	PhenixVersion string    `json:"phenixVersion"`
+	Created       time.Time `json:"created"`
+}
+
+// ExportResult summarizes the contents of an archive written by Export.
+type ExportResult struct {
+	Configs int
+	Events  int
+}
+
+// ImportResult summarizes the changes made to the store by Import.
+type ImportResult struct {
+	Created  int
+	Replaced int
+	Upgraded int
+	Events   int
+
+	// Skipped is the number of configs and events that already existed in the
+	// store and were left as-is.
+	Skipped int
+}
+
+// Export writes a gzipped tarball of the configs (of all kinds by default) and
+// events in the store to the given writer. The archive contains a manifest,
+// one JSON file per config at `configs/<kind>/<name>.json`, and the events as
+// JSON lines split across `events/<n>.jsonl` files. Configs and events are
+// written to the archive as they're read from the store, one kind at a time.
+// It returns the number of configs and events written to the archive.
+func Export(w io.Writer, opts ...ArchiveOption) (ExportResult, error) {
+	var (
+		o      = newArchiveOptions(opts...)
+		result ExportResult
+	)
+
+	manifest := ArchiveManifest{
+		Version:       ArchiveVersion,
+		PhenixVersion: version.Tag,
+		Created:       time.Now().UTC(),
+	}
+
+	gw := gzip.NewWriter(w)
+	tw := tar.NewWriter(gw)
+
+	body, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return result, fmt.Errorf("marshaling archive manifest: %w", err)
+	}
+
+	if err := writeArchiveEntry(tw, archiveManifest, body, manifest.Created); err != nil {
+		return result, err
+	}
+
+	for _, kind := range AllKinds {
+		if !o.includesKind(kind) {
+			continue
+		}
+
+		configs, err := store.List(kind)
+		if err != nil {
+			return result, fmt.Errorf("getting %s configs to export: %w", kind, err)
+		}
+
+		for _, c := range configs {
+			if !o.includesConfig(c) {
+				continue
+			}
+
+			body, err := json.MarshalIndent(c, "", "  ")
+			if err != nil {
+				return result, fmt.Errorf("marshaling config %s/%s: %w", c.Kind, c.Metadata.Name, err)
+			}
+
+			name := path.Join("configs", strings.ToLower(c.Kind), c.Metadata.Name+".json")
+
+			if err := writeArchiveEntry(tw, name, body, manifest.Created); err != nil {
+				return result, err
+			}
+
+			result.Configs++
+		}
+	}
+
+	if o.events {
+		var entry int
+
+		err := eachEventPage(func(page store.Events) error {
+			var (
+				buf bytes.Buffer
+				enc = json.NewEncoder(&buf)
+			)
+
+			for _, e := range page {
+				if err := enc.Encode(e); err != nil {
+					return fmt.Errorf("marshaling event %s: %w", e.ID, err)
+				}
+			}
+
+			name := fmt.Sprintf("events/%06d.jsonl", entry)
+
+			if err := writeArchiveEntry(tw, name, buf.Bytes(), manifest.Created); err != nil {
+				return err
+			}
+
+			entry++
+			result.Events += len(page)
+
+			return nil
+		})
+
+		if err != nil {
+			return result, fmt.Errorf("exporting events: %w", err)
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return result, fmt.Errorf("closing archive: %w", err)
+	}
+
+	if err := gw.Close(); err != nil {
+		return result, fmt.Errorf("closing archive: %w", err)
+	}
+
+	return result, nil
+}
+
+// Import reads a gzipped tarball written by Export from the given reader and
+// writes its configs and events to the store. By default, configs that already
+// exist in the store are kept as-is; the ArchiveWithReplace option replaces
+// them instead. Events are never replaced since they're immutable. Configs are
+// written to the store as-is (config hooks are not called), preserving their
+// creation timestamps, unless the ArchiveWithUpgrade option is given, in which
+// case configs of older versions are upgraded first.
+func Import(r io.Reader, opts ...ArchiveOption) (ImportResult, error) {
+	o := newArchiveOptions(opts...)
+
+	var result ImportResult
+
+	gr, err := gzip.NewReader(r)
+	if err != nil {
+		return result, fmt.Errorf("reading archive: %w", err)
+	}
+
+	defer gr.Close()
+
+	tr := tar.NewReader(gr)
+
+	hdr, err := tr.Next()
+	if err != nil {
+		return result, fmt.Errorf("reading archive: %w", err)
+	}
+
+	if hdr.Name != archiveManifest {
+		return result, fmt.Errorf("archive is missing manifest")
+	}
+
+	var manifest ArchiveManifest
+
+	if err := json.NewDecoder(tr).Decode(&manifest); err != nil {
+		return result, fmt.Errorf("decoding archive manifest: %w", err)
+	}
+
+	if manifest.Version < 1 || manifest.Version > ArchiveVersion {
+		return result, fmt.Errorf("unsupported archive version %d (expected %d or older)", manifest.Version, ArchiveVersion)
+	}
+
+	// IDs of events already in the store
+	var existing map[string]struct{}
+
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+
+		if err != nil {
+			return result, fmt.Errorf("reading archive: %w", err)
+		}
+
+		switch {
+		case strings.HasPrefix(hdr.Name, "configs/"):
+			var c store.Config
+
+			if err := json.NewDecoder(tr).Decode(&c); err != nil {
+				return result, fmt.Errorf("decoding archived config %s: %w", hdr.Name, err)
+			}
+
+			if !o.includesConfig(c) {
+				continue
+			}
+
+			if err := importConfig(c, o, &result); err != nil {
+				return result, err
+			}
+		case strings.HasPrefix(hdr.Name, "events/"):
+			if !o.events {
+				continue
+			}
+
+			// Only get the existing events once, rather than for every events file.
+			if existing == nil {
+				if existing, err = existingEvents(); err != nil {
+					return result, err
+				}
+			}
+
+			if err := importEvents(tr, existing, &result); err != nil {
+				return result, err
+			}
+		}
+	}
+
+	return result, nil
+}
+
+func importConfig(c store.Config, o archiveOptions, result *ImportResult) error {
+	if o.upgrade {
+		upgraded, err := upgradeConfig(&c)
+		if err != nil {
+			return fmt.Errorf("upgrading config %s/%s: %w", c.Kind, c.Metadata.Name, err)
+		}
+
+		if upgraded != &c {
+			// Upgraded configs are created from scratch, so carry over the metadata
+			// and status from the archived config.
+			upgraded.Metadata.Created = c.Metadata.Created
+			upgraded.Metadata.Annotations = c.Metadata.Annotations
+			upgraded.Status = c.Status
+
+			c = *upgraded
+			result.Upgraded++
+		}
+	}
+
+	c.Metadata.ResourceVersion = 0
+
+	existing := store.Config{Kind: c.Kind, Metadata: store.ConfigMetadata{Name: c.Metadata.Name}}
+
+	if err := store.Get(&existing); err != nil {
+		if err := store.Create(&c); err != nil {
+			return fmt.Errorf("creating config %s/%s: %w", c.Kind, c.Metadata.Name, err)
+		}
+
+		result.Created++
+
+		return nil
+	}
+
+	if !o.replace {
+		result.Skipped++
+		return nil
+	}
+
+	if err := store.Update(&c); err != nil {
+		return fmt.Errorf("replacing config %s/%s: %w", c.Kind, c.Metadata.Name, err)
+	}
+
+	result.Replaced++
+
+	return nil
+}
+
+func existingEvents() (map[string]struct{}, error) {
+	ids := make(map[string]struct{})
+
+	err := eachEventPage(func(page store.Events) error {
+		for _, e := range page {
+			ids[e.ID] = struct{}{}
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return nil, fmt.Errorf("getting existing events: %w", err)
+	}
+
+	return ids, nil
+}
+
+// eachEventPage calls the given function with successive pages of at most
+// archiveEventsPerEntry events from the store, oldest first, so all the events
+// in the store never have to be in memory at once.
+func eachEventPage(fn func(store.Events) error) error {
+	var (
+		since time.Time
+
+		// IDs of the events already paged through with the same timestamp as
+		// since, which are returned again by the next query since it's inclusive.
+		seen = make(map[string]struct{})
+	)
+
+	for {
+		limit := archiveEventsPerEntry + len(seen)
+
+		events, err := store.QueryEvents(store.EventQuery{Since: since, Limit: limit, Ascending: true})
+		if err != nil {
+			return fmt.Errorf("querying events: %w", err)
+		}
+
+		var page store.Events
+
+		for _, e := range events {
+			if _, ok := seen[e.ID]; !ok {
+				page = append(page, e)
+			}
+		}
+
+		if len(page) > 0 {
+			if err := fn(page); err != nil {
+				return err
+			}
+
+			if last := page[len(page)-1].Timestamp; !last.Equal(since) {
+				since = last
+				seen = make(map[string]struct{})
+			}
+
+			for _, e := range page {
+				if e.Timestamp.Equal(since) {
+					seen[e.ID] = struct{}{}
+				}
+			}
+		}
+
+		if len(events) < limit {
+			return nil
+		}
+	}
+}
+
+func importEvents(r io.Reader, ids map[string]struct{}, result *ImportResult) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+
+	for scanner.Scan() {
+		var e store.Event
+
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			return fmt.Errorf("decoding archived event: %w", err)
+		}
+
+		if _, ok := ids[e.ID]; ok {
+			result.Skipped++
+			continue
+		}
+
+		if err := store.AddEvent(e); err != nil {
+			return fmt.Errorf("adding event %s: %w", e.ID, err)
+		}
+
+		result.Events++
+	}
+
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("reading archived events: %w", err)
+	}
+
+	return nil
+}
+
+func writeArchiveEntry(tw *tar.Writer, name string, body []byte, modified time.Time) error {
+	hdr := &tar.Header{
+		Name:    name,
+		Mode:    0600,
+		Size:    int64(len(body)),
+		ModTime: modified,
+	}
+
+	if err := tw.WriteHeader(hdr); err != nil {
+		return fmt.Errorf("writing archive header for %s: %w", name, err)
+	}
+
+	if _, err := tw.Write(body); err != nil {
+		return fmt.Errorf("writing %s to archive: %w", name, err)
+	}
+
+	return nil
+}
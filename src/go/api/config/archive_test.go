@@ -0,0 +1,238 @@
+package config
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"phenix/store"
+)
+
+func TestExportImport(t *testing.T) {
+	base := t.TempDir()
+
+	store.DefaultStore = store.NewBoltDB()
+
+	if err := store.DefaultStore.Init(store.Endpoint("bolt://" + filepath.Join(base, "phenix.bdb"))); err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+
+	topo, err := Create(CreateFromYAML([]byte(revisionTopology)), CreateWithValidation())
+	if err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+
+	scenario := &store.Config{
+		Version:  "phenix.sandia.gov/v1",
+		Kind:     "Scenario",
+		Metadata: store.ConfigMetadata{Name: "foobar", Annotations: map[string]string{"topology": "foobar"}},
+		Spec: map[string]any{
+			"apps": map[string]any{
+				"host": []any{
+					map[string]any{"name": "foo", "hosts": []any{map[string]any{"hostname": "turbine-01"}}},
+				},
+			},
+		},
+	}
+
+	if err := store.Create(scenario); err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+
+	if err := store.AddEvent(*store.NewInfoEvent("foo")); err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+
+	var archive bytes.Buffer
+
+	exported, err := Export(&archive, ArchiveKinds("Topology", "Scenario"))
+	if err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+
+	if exported.Configs != 2 || exported.Events != 1 {
+		t.Logf("unexpected export result: %+v", exported)
+		t.FailNow()
+	}
+
+	// Import into a fresh store, upgrading the v1 scenario along the way.
+	store.DefaultStore = store.NewSQLite()
+
+	if err := store.DefaultStore.Init(store.Endpoint("sqlite://" + filepath.Join(base, "phenix.db"))); err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+
+	result, err := Import(bytes.NewReader(archive.Bytes()), ArchiveWithUpgrade())
+	if err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+
+	if result.Created != 2 || result.Upgraded != 1 || result.Events != 1 {
+		t.Logf("unexpected import result: %+v", result)
+		t.FailNow()
+	}
+
+	imported, err := Get("topology/foobar", false)
+	if err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+
+	if imported.Metadata.Created != topo.Metadata.Created {
+		t.Logf("expected created timestamp %s to be preserved, got %s", topo.Metadata.Created, imported.Metadata.Created)
+		t.FailNow()
+	}
+
+	upgraded, err := Get("scenario/foobar", false)
+	if err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+
+	if upgraded.Version != "phenix.sandia.gov/v2" || upgraded.Metadata.Annotations["topology"] != "foobar" {
+		t.Logf("expected scenario to be upgraded to v2 with annotations preserved, got %+v", upgraded)
+		t.FailNow()
+	}
+
+	// Importing again merges by default, leaving existing entries untouched.
+	imported.Spec["nodes"] = []any{}
+
+	if err := store.Update(imported); err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+
+	result, err = Import(bytes.NewReader(archive.Bytes()), ArchiveNames("topology/foobar"))
+	if err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+
+	if result.Created != 0 || result.Skipped != 2 {
+		t.Logf("unexpected merge import result: %+v", result)
+		t.FailNow()
+	}
+
+	result, err = Import(bytes.NewReader(archive.Bytes()), ArchiveNames("topology/foobar"), ArchiveWithReplace(), ArchiveWithoutEvents())
+	if err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+
+	if result.Replaced != 1 || result.Skipped != 0 || result.Events != 0 {
+		t.Logf("unexpected replace import result: %+v", result)
+		t.FailNow()
+	}
+
+	imported, _ = Get("topology/foobar", false)
+
+	if nodes, _ := imported.Spec["nodes"].([]any); len(nodes) != 1 {
+		t.Logf("expected topology to be replaced by archived version, got %v", imported.Spec["nodes"])
+		t.FailNow()
+	}
+}
+
+func TestExportEventsSplit(t *testing.T) {
+	base := t.TempDir()
+
+	store.DefaultStore = store.NewBoltDB()
+
+	if err := store.DefaultStore.Init(store.Endpoint("bolt://" + filepath.Join(base, "phenix.bdb"))); err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+
+	var (
+		total = archiveEventsPerEntry + 1
+		now   = time.Now()
+	)
+
+	for i := 0; i < total; i++ {
+		event := store.NewInfoEvent(fmt.Sprintf("event %d", i))
+
+		// Events are paged through by timestamp, so make sure events sharing a
+		// timestamp across page boundaries are neither skipped nor duplicated.
+		event.Timestamp = now.Add(time.Duration(i/7) * time.Second)
+
+		if err := store.AddEvent(*event); err != nil {
+			t.Log(err)
+			t.FailNow()
+		}
+	}
+
+	var archive bytes.Buffer
+
+	exported, err := Export(&archive)
+	if err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+
+	if exported.Events != total {
+		t.Logf("expected %d events to be exported, got %d", total, exported.Events)
+		t.FailNow()
+	}
+
+	gr, err := gzip.NewReader(bytes.NewReader(archive.Bytes()))
+	if err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+
+	var (
+		tr    = tar.NewReader(gr)
+		names []string
+	)
+
+	for hdr, err := tr.Next(); err == nil; hdr, err = tr.Next() {
+		names = append(names, hdr.Name)
+	}
+
+	expected := fmt.Sprint([]string{archiveManifest, "events/000000.jsonl", "events/000001.jsonl"})
+
+	if fmt.Sprint(names) != expected {
+		t.Logf("expected archive entries %s, got %v", expected, names)
+		t.FailNow()
+	}
+
+	store.DefaultStore = store.NewSQLite()
+
+	if err := store.DefaultStore.Init(store.Endpoint("sqlite://" + filepath.Join(base, "phenix.db"))); err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+
+	result, err := Import(bytes.NewReader(archive.Bytes()))
+	if err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+
+	if result.Events != total {
+		t.Logf("expected %d events to be imported, got %d", total, result.Events)
+		t.FailNow()
+	}
+
+	// Events are immutable, so importing them again shouldn't change anything.
+	result, err = Import(bytes.NewReader(archive.Bytes()))
+	if err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+
+	if result.Events != 0 || result.Skipped != total {
+		t.Logf("expected all %d events to be skipped on reimport, got %+v", total, result)
+		t.FailNow()
+	}
+}
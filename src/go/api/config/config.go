@@ -25,6 +25,18 @@ var AllKinds = []string{"Topology", "Scenario", "Experiment", "Image", "User", "
 
 var NameRegex = regexp.MustCompile(`^[a-zA-Z0-9_@.-]*$`)
 
+// Experiment annotations used to record who owns an experiment and who it has
+// been shared with. They're managed via the experiment ownership and sharing
+// APIs, so they can't be changed by updating or rolling back a config.
+const (
+	AnnotationOwner  = "phenix.rbac/owner"
+	AnnotationShares = "phenix.rbac/shares"
+)
+
+var protectedAnnotations = []string{AnnotationOwner, AnnotationShares}
+
+var ErrProtectedAnnotation = errors.New("cannot change protected annotation")
+
 // ConfigHook is a function to be called during the different lifecycle stages
 // of a config. The passed config can be updated by the hook functions as
 // necessary, and an error can be returned if the lifecycle stage should be
@@ -234,27 +246,38 @@ func Get(name string, upgrade bool) (*store.Config, error) {
 	}
 
 	if upgrade {
-		latest := version.StoredVersion[c.Kind]
-
-		if c.APIVersion() != latest {
-			upgrader := types.GetUpgrader(c.Kind + "/" + latest)
-			if upgrader != nil {
-				iface, err := upgrader.Upgrade(c.APIVersion(), c.Spec, c.Metadata)
-				if err != nil {
-					return nil, fmt.Errorf("upgrading config: %w", err)
-				}
+		return upgradeConfig(c)
+	}
 
-				cfg, err := types.NewConfigFromSpec(c.Metadata.Name, iface)
-				if err != nil {
-					return nil, fmt.Errorf("creating new config from spec: %w", err)
-				}
+	return c, nil
+}
 
-				return cfg, nil
-			}
-		}
+// upgradeConfig upgrades the given config to the latest stored version of its
+// kind if it's not already at the latest version and an upgrader for the kind
+// is registered. Otherwise, the given config is returned as-is.
+func upgradeConfig(c *store.Config) (*store.Config, error) {
+	latest := version.StoredVersion[c.Kind]
+
+	if c.APIVersion() == latest {
+		return c, nil
 	}
 
-	return c, nil
+	upgrader := types.GetUpgrader(c.Kind + "/" + latest)
+	if upgrader == nil {
+		return c, nil
+	}
+
+	iface, err := upgrader.Upgrade(c.APIVersion(), c.Spec, c.Metadata)
+	if err != nil {
+		return nil, fmt.Errorf("upgrading config: %w", err)
+	}
+
+	cfg, err := types.NewConfigFromSpec(c.Metadata.Name, iface)
+	if err != nil {
+		return nil, fmt.Errorf("creating new config from spec: %w", err)
+	}
+
+	return cfg, nil
 }
 
 // Create reads a config file from the given path, validates it, and persists it
@@ -304,6 +327,14 @@ func Create(opts ...CreateOption) (*store.Config, error) {
 		return nil, fmt.Errorf("no config, path, or data provided")
 	}
 
+	if o.owner != "" && c.Kind == "Experiment" {
+		if c.Metadata.Annotations == nil {
+			c.Metadata.Annotations = make(map[string]string)
+		}
+
+		c.Metadata.Annotations[AnnotationOwner] = o.owner
+	}
+
 	if o.validate {
 		if err := types.ValidateConfigSpec(*c); err != nil {
 			return nil, fmt.Errorf("validating config: %w", err)
@@ -398,7 +429,9 @@ func Edit(name string, force bool) (*store.Config, error) {
 
 // Update updates the store with the given config. If the name of the config was
 // changed as part of the update, a new config will be created and the old
-// config deleted.
+// config deleted. Protected annotations (e.g., experiment ownership) missing
+// from the given config are carried over from the stored config, and changes to
+// them result in an ErrProtectedAnnotation error.
 func Update(name string, c *store.Config) error {
 	old, err := store.NewConfig(name)
 	if err != nil {
@@ -411,6 +444,27 @@ func Update(name string, c *store.Config) error {
 
 	c.Metadata.Created = old.Metadata.Created
 
+	for _, key := range protectedAnnotations {
+		value, ok := old.Metadata.Annotations[key]
+
+		updated, set := c.Metadata.Annotations[key]
+		if !set {
+			if ok {
+				if c.Metadata.Annotations == nil {
+					c.Metadata.Annotations = make(map[string]string)
+				}
+
+				c.Metadata.Annotations[key] = value
+			}
+
+			continue
+		}
+
+		if !ok || updated != value {
+			return fmt.Errorf("%w %s", ErrProtectedAnnotation, key)
+		}
+	}
+
 	if err := types.ValidateConfigSpec(*c); err != nil {
 		return fmt.Errorf("validating config: %w", err)
 	}
@@ -446,6 +500,50 @@ func Update(name string, c *store.Config) error {
 	return nil
 }
 
+// Patch applies the given patch to the spec and status of the config with the
+// given name. The given name should be of the form `type/name`. The patch is
+// applied in the store (see `store.Patch`), with the patched config being
+// validated before it's committed. The config for a running experiment cannot
+// be patched, nor can the name of an experiment be changed. It returns the
+// patched config and any errors encountered while patching the config.
+func Patch(name string, patch []byte, typ store.PatchType) (*store.Config, error) {
+	c, err := Get(name, false)
+	if err != nil {
+		return nil, fmt.Errorf("getting config %s: %w", name, err)
+	}
+
+	var expName string
+
+	if c.Kind == "Experiment" {
+		exp, err := types.DecodeExperimentFromConfig(*c)
+		if err != nil {
+			return nil, fmt.Errorf("decoding experiment from config: %w", err)
+		}
+
+		if exp.Running() {
+			return nil, fmt.Errorf("cannot patch running experiment")
+		}
+
+		expName = exp.Spec.ExperimentName()
+	}
+
+	validate := func(c store.Config) error {
+		if c.Kind == "Experiment" && c.Spec["experimentName"] != expName {
+			return fmt.Errorf("cannot change experiment name")
+		}
+
+		return types.ValidateConfigSpec(c)
+	}
+
+	opts := []store.PatchOption{store.PatchWithType(typ), store.PatchWithValidator(validate)}
+
+	if err := store.Patch(c, patch, opts...); err != nil {
+		return nil, fmt.Errorf("patching config %s: %w", name, err)
+	}
+
+	return c, nil
+}
+
 // Delete removes the config with the given name from the store. The given name
 // should be of the form `type/name`, where `type` is one of `topology,
 // scenario, or experiment`. If `all` is specified, then all the known configs
@@ -1,9 +1,12 @@
 package config
 
 import (
+	"errors"
+	"os"
 	"testing"
 
 	"phenix/store"
+	"phenix/types"
 
 	"github.com/golang/mock/gomock"
 )
@@ -71,3 +74,48 @@ func TestCreateScoped(t *testing.T) {
 		t.FailNow()
 	}
 }
+
+func TestPatch(t *testing.T) {
+	f, err := os.CreateTemp("", "phenix")
+	if err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+
+	defer os.Remove(f.Name())
+
+	store.DefaultStore = store.NewBoltDB()
+
+	if err := store.DefaultStore.Init(store.Endpoint("bolt://" + f.Name())); err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+
+	if _, err := Create(CreateFromYAML([]byte(revisionTopology)), CreateWithValidation()); err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+
+	patch := []byte(`[{"op": "replace", "path": "/spec/nodes/0/general/hostname", "value": "turbine-02"}]`)
+
+	c, err := Patch("topology/foobar", patch, store.PatchTypeJSON)
+	if err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+
+	hostname := c.Spec["nodes"].([]any)[0].(map[string]any)["general"].(map[string]any)["hostname"]
+
+	if hostname != "turbine-02" {
+		t.Logf("expected patched hostname turbine-02, got %v", hostname)
+		t.FailNow()
+	}
+
+	// The topology schema requires node types to be one of a known set.
+	patch = []byte(`{"spec": {"nodes": [{"type": "Blech"}]}}`)
+
+	if _, err := Patch("topology/foobar", patch, store.PatchTypeMerge); !errors.Is(err, types.ErrValidationFailed) {
+		t.Logf("expected validation error, got %v", err)
+		t.FailNow()
+	}
+}
@@ -1,6 +1,10 @@
 package config
 
-import "phenix/store"
+import (
+	"strings"
+
+	"phenix/store"
+)
 
 type DataType int
 
@@ -19,6 +23,7 @@ type createOptions struct {
 	dataType DataType
 	validate bool
 	scope    string
+	owner    string
 
 	scopeVariables []string
 }
@@ -72,3 +77,105 @@ func CreateWithScope(s string) CreateOption {
 		o.scope = s
 	}
 }
+
+// CreateWithOwner sets the user that owns the config being created if it's an
+// experiment, replacing any owner annotation included in the config itself.
+func CreateWithOwner(u string) CreateOption {
+	return func(o *createOptions) {
+		o.owner = u
+	}
+}
+
+type ArchiveOption func(*archiveOptions)
+
+type archiveOptions struct {
+	kinds   []string
+	names   []string
+	events  bool
+	upgrade bool
+	replace bool
+}
+
+func newArchiveOptions(opts ...ArchiveOption) archiveOptions {
+	o := archiveOptions{events: true}
+
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	return o
+}
+
+// ArchiveKinds limits the configs exported or imported to the given kinds.
+func ArchiveKinds(k ...string) ArchiveOption {
+	return func(o *archiveOptions) {
+		o.kinds = append(o.kinds, k...)
+	}
+}
+
+// ArchiveNames limits the configs exported or imported to the given names,
+// which can be of the form `name` or `kind/name`.
+func ArchiveNames(n ...string) ArchiveOption {
+	return func(o *archiveOptions) {
+		o.names = append(o.names, n...)
+	}
+}
+
+// ArchiveWithoutEvents skips exporting or importing events.
+func ArchiveWithoutEvents() ArchiveOption {
+	return func(o *archiveOptions) {
+		o.events = false
+	}
+}
+
+// ArchiveWithUpgrade upgrades imported configs to the latest stored version
+// of their kind when an upgrader is available.
+func ArchiveWithUpgrade() ArchiveOption {
+	return func(o *archiveOptions) {
+		o.upgrade = true
+	}
+}
+
+// ArchiveWithReplace replaces configs that already exist in the store when
+// importing, instead of keeping the existing configs.
+func ArchiveWithReplace() ArchiveOption {
+	return func(o *archiveOptions) {
+		o.replace = true
+	}
+}
+
+func (this archiveOptions) includesKind(kind string) bool {
+	if len(this.kinds) == 0 {
+		return true
+	}
+
+	for _, k := range this.kinds {
+		if strings.EqualFold(k, kind) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (this archiveOptions) includesConfig(c store.Config) bool {
+	if !this.includesKind(c.Kind) {
+		return false
+	}
+
+	if len(this.names) == 0 {
+		return true
+	}
+
+	for _, n := range this.names {
+		if kind, name, ok := strings.Cut(n, "/"); ok {
+			if strings.EqualFold(kind, c.Kind) && name == c.Metadata.Name {
+				return true
+			}
+		} else if n == c.Metadata.Name {
+			return true
+		}
+	}
+
+	return false
+}
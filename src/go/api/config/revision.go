@@ -0,0 +1,156 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+
+	"phenix/store"
+	"phenix/types"
+
+	"github.com/pmezard/go-difflib/difflib"
+	"gopkg.in/yaml.v3"
+)
+
+var ErrRevisionNotExist = errors.New("config revision does not exist")
+
+// History returns the revision history of the config with the given name. The
+// given name should be of the form `type/name`. The prior revisions kept by the
+// store are returned oldest first, followed by the current config. Each
+// revision is identified by its resource version.
+func History(name string) (store.Configs, error) {
+	c, err := Get(name, false)
+	if err != nil {
+		return nil, fmt.Errorf("getting config %s: %w", name, err)
+	}
+
+	revs, err := store.Revisions(c)
+	if err != nil {
+		return nil, fmt.Errorf("getting revisions for config %s: %w", name, err)
+	}
+
+	return append(revs, *c), nil
+}
+
+// Revision returns the revision of the config with the given name matching the
+// given resource version. The current config is returned if the resource
+// version matches it.
+func Revision(name string, rev int64) (*store.Config, error) {
+	history, err := History(name)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, c := range history {
+		if c.Metadata.ResourceVersion == rev {
+			return &c, nil
+		}
+	}
+
+	return nil, fmt.Errorf("revision %d of config %s: %w", rev, name, ErrRevisionNotExist)
+}
+
+// Diff returns a unified diff of the YAML representations of the two given
+// revisions of the config with the given name. Timestamps, resource versions,
+// and status are not included in the diff.
+func Diff(name string, from, to int64) (string, error) {
+	a, err := Revision(name, from)
+	if err != nil {
+		return "", err
+	}
+
+	b, err := Revision(name, to)
+	if err != nil {
+		return "", err
+	}
+
+	fromYAML, err := revisionYAML(*a)
+	if err != nil {
+		return "", fmt.Errorf("marshaling revision %d to YAML: %w", from, err)
+	}
+
+	toYAML, err := revisionYAML(*b)
+	if err != nil {
+		return "", fmt.Errorf("marshaling revision %d to YAML: %w", to, err)
+	}
+
+	diff := difflib.UnifiedDiff{
+		A:        difflib.SplitLines(fromYAML),
+		B:        difflib.SplitLines(toYAML),
+		FromFile: fmt.Sprintf("%s@%d", name, from),
+		ToFile:   fmt.Sprintf("%s@%d", name, to),
+		Context:  3,
+	}
+
+	return difflib.GetUnifiedDiffString(diff)
+}
+
+// Rollback updates the config with the given name to match the spec and
+// annotations of the given prior revision of it. The rollback is done as a
+// normal update, so the config is validated, update hooks are called, and the
+// config being replaced is kept as a revision itself. Protected annotations
+// (e.g., experiment ownership) aren't rolled back. Running experiments cannot
+// be rolled back. It returns the updated config.
+func Rollback(name string, rev int64) (*store.Config, error) {
+	c, err := Get(name, false)
+	if err != nil {
+		return nil, fmt.Errorf("getting config %s: %w", name, err)
+	}
+
+	if c.Kind == "Experiment" {
+		exp, err := types.DecodeExperimentFromConfig(*c)
+		if err != nil {
+			return nil, fmt.Errorf("decoding experiment from config: %w", err)
+		}
+
+		if exp.Running() {
+			return nil, fmt.Errorf("cannot rollback running experiment")
+		}
+	}
+
+	if rev == c.Metadata.ResourceVersion {
+		return nil, fmt.Errorf("revision %d is the current revision of config %s", rev, name)
+	}
+
+	old, err := Revision(name, rev)
+	if err != nil {
+		return nil, err
+	}
+
+	annotations := make(map[string]string)
+
+	for k, v := range old.Metadata.Annotations {
+		annotations[k] = v
+	}
+
+	for _, key := range protectedAnnotations {
+		delete(annotations, key)
+
+		if value, ok := c.Metadata.Annotations[key]; ok {
+			annotations[key] = value
+		}
+	}
+
+	c.Version = old.Version
+	c.Spec = old.Spec
+	c.Metadata.Annotations = annotations
+
+	if err := Update(name, c); err != nil {
+		return nil, fmt.Errorf("rolling back config %s to revision %d: %w", name, rev, err)
+	}
+
+	return c, nil
+}
+
+func revisionYAML(c store.Config) (string, error) {
+	c.Metadata.Created = ""
+	c.Metadata.Updated = ""
+	c.Metadata.ResourceVersion = 0
+	c.Status = nil
+
+	body, err := yaml.Marshal(c)
+	if err != nil {
+		return "", err
+	}
+
+	return string(body), nil
+}
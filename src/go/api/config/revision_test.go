@@ -0,0 +1,218 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"phenix/store"
+)
+
+var revisionTopology = `
+apiVersion: phenix.sandia.gov/v1
+kind: Topology
+metadata:
+  name: foobar
+spec:
+  nodes:
+  - type: VirtualMachine
+    general:
+      hostname: turbine-01
+    hardware:
+      os_type: linux
+      drives:
+      - image: foo.qc2
+    network:
+      interfaces:
+      - name: IF0
+        vlan: ot
+        address: 192.168.10.1
+        mask: 24
+        proto: static
+        type: ethernet
+`
+
+func TestRollback(t *testing.T) {
+	f, err := os.CreateTemp("", "phenix")
+	if err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+
+	defer os.Remove(f.Name())
+
+	store.DefaultStore = store.NewBoltDB()
+
+	if err := store.DefaultStore.Init(store.Endpoint("bolt://" + f.Name())); err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+
+	c, err := Create(CreateFromYAML([]byte(revisionTopology)), CreateWithValidation())
+	if err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+
+	c.Spec["nodes"].([]any)[0].(map[string]any)["general"].(map[string]any)["hostname"] = "turbine-02"
+
+	if err := Update("topology/foobar", c); err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+
+	history, err := History("topology/foobar")
+	if err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+
+	if len(history) != 2 {
+		t.Logf("expected 2 revisions, got %d", len(history))
+		t.FailNow()
+	}
+
+	diff, err := Diff("topology/foobar", 1, 2)
+	if err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+
+	var removed, added bool
+
+	for _, line := range strings.Split(diff, "\n") {
+		if strings.HasPrefix(line, "-") && strings.HasSuffix(line, "hostname: turbine-01") {
+			removed = true
+		}
+
+		if strings.HasPrefix(line, "+") && strings.HasSuffix(line, "hostname: turbine-02") {
+			added = true
+		}
+	}
+
+	if !removed || !added {
+		t.Logf("unexpected diff:\n%s", diff)
+		t.FailNow()
+	}
+
+	if _, err := Rollback("topology/foobar", 1); err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+
+	c, err = Get("topology/foobar", false)
+	if err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+
+	hostname := c.Spec["nodes"].([]any)[0].(map[string]any)["general"].(map[string]any)["hostname"]
+
+	if hostname != "turbine-01" {
+		t.Logf("expected hostname turbine-01 after rollback, got %v", hostname)
+		t.FailNow()
+	}
+
+	if c.Metadata.ResourceVersion != 3 {
+		t.Logf("expected rollback to create revision 3, got %d", c.Metadata.ResourceVersion)
+		t.FailNow()
+	}
+
+	if _, err := Rollback("topology/foobar", 42); err == nil {
+		t.Log("expected error rolling back to unknown revision")
+		t.FailNow()
+	}
+}
+
+func TestProtectedAnnotations(t *testing.T) {
+	store.DefaultStore = store.NewBoltDB()
+
+	if err := store.DefaultStore.Init(store.Endpoint("bolt://" + filepath.Join(t.TempDir(), "phenix.bdb"))); err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+
+	c, err := Create(CreateFromYAML([]byte(revisionTopology)), CreateWithValidation())
+	if err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+
+	// Ownership is normally set when creating an experiment and updated via the
+	// sharing API, both of which write to the store directly.
+	c.Metadata.Annotations = map[string]string{AnnotationOwner: "alice@foo.com"}
+
+	if err := store.Update(c); err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+
+	c, _ = Get("topology/foobar", false)
+	c.Metadata.Annotations = map[string]string{"foo": "bar"}
+
+	if err := Update("topology/foobar", c); err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+
+	c, _ = Get("topology/foobar", false)
+
+	if c.Metadata.Annotations[AnnotationOwner] != "alice@foo.com" || c.Metadata.Annotations["foo"] != "bar" {
+		t.Logf("expected owner to be carried over when omitted, got %v", c.Metadata.Annotations)
+		t.FailNow()
+	}
+
+	for key, value := range map[string]string{AnnotationOwner: "bob@foo.com", AnnotationShares: "user:bob@foo.com"} {
+		c, _ = Get("topology/foobar", false)
+		c.Metadata.Annotations[key] = value
+
+		if err := Update("topology/foobar", c); !errors.Is(err, ErrProtectedAnnotation) {
+			t.Logf("expected protected annotation error when changing %s, got %v", key, err)
+			t.FailNow()
+		}
+	}
+
+	c, _ = Get("topology/foobar", false)
+	c.Metadata.Annotations[AnnotationOwner] = "bob@foo.com"
+	c.Metadata.Annotations[AnnotationShares] = "user:alice@foo.com"
+
+	if err := store.Update(c); err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+
+	c, err = Rollback("topology/foobar", 2)
+	if err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+
+	if c.Metadata.Annotations[AnnotationOwner] != "bob@foo.com" || c.Metadata.Annotations[AnnotationShares] != "user:alice@foo.com" {
+		t.Logf("expected ownership not to be rolled back, got %v", c.Metadata.Annotations)
+		t.FailNow()
+	}
+
+	exp := `
+apiVersion: phenix.sandia.gov/v1
+kind: Experiment
+metadata:
+  name: foobar
+  annotations:
+    phenix.rbac/owner: alice@foo.com
+spec:
+  experimentName: foobar
+`
+
+	c, err = Create(CreateFromYAML([]byte(exp)), CreateWithOwner("bob@foo.com"))
+	if err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+
+	if c.Metadata.Annotations[AnnotationOwner] != "bob@foo.com" {
+		t.Logf("expected experiment to be owned by creator, got %v", c.Metadata.Annotations)
+		t.FailNow()
+	}
+}
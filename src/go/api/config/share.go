@@ -0,0 +1,64 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Kinds of principals an experiment can be shared with.
+const (
+	ShareKindUser = "user"
+	ShareKindRole = "role"
+)
+
+// Share grants a user, or every user with a role, access to an experiment via
+// the `shared` ownership policies of their role.
+type Share struct {
+	Kind string `json:"kind"`
+	Name string `json:"name"`
+}
+
+func (this Share) String() string {
+	return this.Kind + ":" + this.Name
+}
+
+// ParseShare parses a share of the form <user|role>:<name>.
+func ParseShare(s string) (Share, error) {
+	parts := strings.SplitN(s, ":", 2)
+
+	if len(parts) != 2 || parts[1] == "" {
+		return Share{}, fmt.Errorf("share %s must be of the form <user|role>:<name>", s)
+	}
+
+	switch parts[0] {
+	case ShareKindUser, ShareKindRole:
+		return Share{Kind: parts[0], Name: parts[1]}, nil
+	default:
+		return Share{}, fmt.Errorf("unknown share kind %s (must be user or role)", parts[0])
+	}
+}
+
+// ParseShares parses the comma-separated list of shares stored in an
+// experiment's shares annotation. Invalid shares are ignored.
+func ParseShares(annotation string) []Share {
+	var shares []Share
+
+	for _, s := range strings.Split(annotation, ",") {
+		if share, err := ParseShare(strings.TrimSpace(s)); err == nil {
+			shares = append(shares, share)
+		}
+	}
+
+	return shares
+}
+
+// FormatShares formats the given shares for an experiment's shares annotation.
+func FormatShares(shares []Share) string {
+	formatted := make([]string, len(shares))
+
+	for i, s := range shares {
+		formatted[i] = s.String()
+	}
+
+	return strings.Join(formatted, ",")
+}
@@ -0,0 +1,17 @@
+package config
+
+import "testing"
+
+func TestParseShares(t *testing.T) {
+	shares := ParseShares("user:alice, role:Experiment Viewer,bogus,group:foo")
+
+	if len(shares) != 2 || shares[0] != (Share{ShareKindUser, "alice"}) || shares[1] != (Share{ShareKindRole, "Experiment Viewer"}) {
+		t.Logf("unexpected shares: %+v", shares)
+		t.FailNow()
+	}
+
+	if formatted := FormatShares(shares); formatted != "user:alice,role:Experiment Viewer" {
+		t.Logf("unexpected formatted shares: %s", formatted)
+		t.FailNow()
+	}
+}
@@ -0,0 +1,375 @@
+package experiment
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	ifaces "phenix/types/interfaces"
+	"phenix/util/common"
+	"phenix/util/file"
+	"phenix/util/mm"
+	"phenix/util/plog"
+
+	"github.com/hashicorp/go-multierror"
+)
+
+var (
+	ErrCheckpointExists   = errors.New("checkpoint already exists")
+	ErrCheckpointNotFound = errors.New("checkpoint not found")
+
+	checkpointNameRegex = regexp.MustCompile(`^[\w.-]+$`)
+)
+
+// Checkpoint is the manifest recorded for a point-in-time checkpoint of all the
+// VMs in a running experiment.
+type Checkpoint struct {
+	Name       string                  `json:"name"`
+	Experiment string                  `json:"experiment"`
+	Created    time.Time               `json:"created"`
+	VMs        map[string]CheckpointVM `json:"vms"`
+	VLANs      map[string]int          `json:"vlans"`
+	Schedules  map[string]string       `json:"schedules"`
+	Apps       map[string]any          `json:"apps"`
+}
+
+// CheckpointVM describes the snapshot files for a single VM in a checkpoint.
+// The snapshot file paths are relative to the minimega files directory on the
+// cluster host the VM was running on.
+type CheckpointVM struct {
+	Host   string `json:"host"`
+	State  string `json:"state"`
+	Disk   string `json:"disk"`
+	Memory string `json:"memory"`
+}
+
+// CheckpointProgress is passed to checkpoint and restore callbacks as each VM
+// is processed. Progress is the overall progress (0.0 - 1.0) of the operation.
+type CheckpointProgress struct {
+	Checkpoint string  `json:"checkpoint"`
+	VM         string  `json:"vm"`
+	Progress   float64 `json:"percent"`
+}
+
+// CreateCheckpoint pauses all the VMs in the given running experiment,
+// snapshots the disk and memory of each VM while they're all paused, and
+// records a manifest of the snapshot files along with the experiment's VLANs,
+// schedule, and app status. VMs that were running are resumed once the
+// checkpoint is complete (or fails). If no checkpoint name is provided, one is
+// generated using the current time. The optional callback is called with the
+// progress of the checkpoint.
+func CreateCheckpoint(expName, name string, cb func(CheckpointProgress)) (*Checkpoint, error) {
+	exp, err := Get(expName)
+	if err != nil {
+		return nil, fmt.Errorf("getting experiment %s: %w", expName, err)
+	}
+
+	if !exp.Running() {
+		return nil, ErrExperimentNotRunning
+	}
+
+	if name == "" {
+		name = time.Now().Format("20060102-150405")
+	}
+
+	if err := validateCheckpointName(name); err != nil {
+		return nil, err
+	}
+
+	manifest := checkpointManifestPath(exp.Spec.BaseDir(), name)
+
+	if _, err := os.Stat(manifest); err == nil {
+		return nil, fmt.Errorf("checkpoint %s for experiment %s: %w", name, expName, ErrCheckpointExists)
+	}
+
+	var vms mm.VMs
+
+	for _, vm := range mm.GetVMInfo(mm.NS(expName)) {
+		if vm.State == "RUNNING" || vm.State == "PAUSED" {
+			vms = append(vms, vm)
+		}
+	}
+
+	if len(vms) == 0 {
+		return nil, fmt.Errorf("no VMs to checkpoint in experiment %s", expName)
+	}
+
+	checkpoint := &Checkpoint{
+		Name:       name,
+		Experiment: expName,
+		Created:    time.Now().UTC(),
+		VMs:        make(map[string]CheckpointVM),
+		VLANs:      exp.Status.VLANs(),
+		Schedules:  exp.Status.Schedules(),
+		Apps:       exp.Status.AppStatus(),
+	}
+
+	progress := func(vm string, done int, p float64) {
+		if cb != nil {
+			cb(CheckpointProgress{Checkpoint: name, VM: vm, Progress: (float64(done) + p) / float64(len(vms))})
+		}
+	}
+
+	// Pause all the VMs at once so the snapshots are consistent with each other.
+	if err := mm.StopVM(mm.NS(expName), mm.VMName("all")); err != nil {
+		return nil, fmt.Errorf("pausing VMs in experiment %s: %w", expName, err)
+	}
+
+	defer func() {
+		// Resume the VMs that were running prior to the checkpoint, even if the
+		// checkpoint failed.
+		for _, vm := range vms {
+			if vm.State != "RUNNING" {
+				continue
+			}
+
+			if err := mm.StartVM(mm.NS(expName), mm.VMName(vm.Name)); err != nil {
+				plog.Error("resuming VM after checkpoint", "exp", expName, "vm", vm.Name, "err", err)
+			}
+		}
+	}()
+
+	dir := checkpointFilesDir(expName, name)
+
+	for i, vm := range vms {
+		progress(vm.Name, i, 0)
+
+		if err := mm.MeshShell(vm.Host, fmt.Sprintf("mkdir -p %s/images/%s", common.PhenixBase, dir)); err != nil {
+			return nil, fmt.Errorf("creating checkpoint directory on host %s: %w", vm.Host, err)
+		}
+
+		snap := dir + "/" + vm.Name
+
+		opts := []mm.Option{
+			mm.NS(expName),
+			mm.VMName(vm.Name),
+			mm.SnapshotFile(snap),
+			mm.SnapshotProgress(func(p float64) { progress(vm.Name, i, p) }),
+		}
+
+		if err := mm.SnapshotVM(opts...); err != nil {
+			file.DeleteFile(dir)
+			return nil, fmt.Errorf("snapshotting VM %s: %w", vm.Name, err)
+		}
+
+		checkpoint.VMs[vm.Name] = CheckpointVM{Host: vm.Host, State: vm.State, Disk: snap + ".qc2", Memory: snap + ".SNAP"}
+	}
+
+	if err := writeCheckpointManifest(manifest, checkpoint); err != nil {
+		file.DeleteFile(dir)
+		return nil, fmt.Errorf("writing checkpoint manifest: %w", err)
+	}
+
+	progress("", len(vms), 0)
+
+	return checkpoint, nil
+}
+
+// RestoreCheckpoint restores all the VMs in the given running experiment to the
+// state they were in when the given checkpoint was created, resuming the VMs
+// that were running at the time. The experiment's app status is also restored
+// from the checkpoint. VMs must still be on the cluster hosts they were on when
+// the checkpoint was created, since that's where their snapshot files are. The
+// optional callback is called with the progress of the restore.
+func RestoreCheckpoint(expName, name string, cb func(CheckpointProgress)) error {
+	if err := validateCheckpointName(name); err != nil {
+		return err
+	}
+
+	exp, err := Get(expName)
+	if err != nil {
+		return fmt.Errorf("getting experiment %s: %w", expName, err)
+	}
+
+	if !exp.Running() {
+		return ErrExperimentNotRunning
+	}
+
+	checkpoint, err := readCheckpointManifest(checkpointManifestPath(exp.Spec.BaseDir(), name))
+	if err != nil {
+		return fmt.Errorf("reading checkpoint %s for experiment %s: %w", name, expName, err)
+	}
+
+	var names []string
+
+	for vm, details := range checkpoint.VMs {
+		host, err := mm.GetVMHost(mm.NS(expName), mm.VMName(vm))
+		if err != nil {
+			return fmt.Errorf("getting host for VM %s: %w", vm, err)
+		}
+
+		if host != details.Host {
+			return fmt.Errorf("VM %s moved from host %s to %s since checkpoint was created", vm, details.Host, host)
+		}
+
+		names = append(names, vm)
+	}
+
+	sort.Strings(names)
+
+	if !reflect.DeepEqual(checkpoint.VLANs, exp.Status.VLANs()) {
+		plog.Warn("experiment VLANs have changed since checkpoint was created", "exp", expName, "checkpoint", name)
+	}
+
+	for i, vm := range names {
+		if cb != nil {
+			cb(CheckpointProgress{Checkpoint: name, VM: vm, Progress: float64(i) / float64(len(names))})
+		}
+
+		snap := strings.TrimSuffix(checkpoint.VMs[vm].Disk, filepath.Ext(checkpoint.VMs[vm].Disk))
+
+		if err := mm.RestoreVM(mm.NS(expName), mm.VMName(vm), mm.SnapshotFile(snap)); err != nil {
+			return fmt.Errorf("restoring VM %s: %w", vm, err)
+		}
+	}
+
+	// Start the VMs once they've all been restored so they resume from the
+	// checkpoint at the same time.
+	var errs error
+
+	for _, vm := range names {
+		if checkpoint.VMs[vm].State != "RUNNING" {
+			continue
+		}
+
+		if err := mm.StartVM(mm.NS(expName), mm.VMName(vm)); err != nil {
+			errs = multierror.Append(errs, fmt.Errorf("starting VM %s: %w", vm, err))
+		}
+	}
+
+	if errs != nil {
+		return errs
+	}
+
+	err = exp.UpdateStatus(func(status ifaces.ExperimentStatus) error {
+		for app, s := range checkpoint.Apps {
+			status.SetAppStatus(app, s)
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return fmt.Errorf("restoring app status for experiment %s: %w", expName, err)
+	}
+
+	if cb != nil {
+		cb(CheckpointProgress{Checkpoint: name, Progress: 1})
+	}
+
+	return nil
+}
+
+// Checkpoints returns the checkpoints recorded for the given experiment, sorted
+// by creation time.
+func Checkpoints(expName string) ([]Checkpoint, error) {
+	exp, err := Get(expName)
+	if err != nil {
+		return nil, fmt.Errorf("getting experiment %s: %w", expName, err)
+	}
+
+	paths, err := filepath.Glob(filepath.Join(exp.Spec.BaseDir(), "checkpoints", "*.json"))
+	if err != nil {
+		return nil, fmt.Errorf("listing checkpoints for experiment %s: %w", expName, err)
+	}
+
+	var checkpoints []Checkpoint
+
+	for _, path := range paths {
+		checkpoint, err := readCheckpointManifest(path)
+		if err != nil {
+			return nil, fmt.Errorf("reading checkpoint manifest %s: %w", path, err)
+		}
+
+		checkpoints = append(checkpoints, *checkpoint)
+	}
+
+	sort.Slice(checkpoints, func(i, j int) bool {
+		return checkpoints[i].Created.Before(checkpoints[j].Created)
+	})
+
+	return checkpoints, nil
+}
+
+// DeleteCheckpoint deletes the given checkpoint's snapshot files from the
+// cluster along with its manifest.
+func DeleteCheckpoint(expName, name string) error {
+	if err := validateCheckpointName(name); err != nil {
+		return err
+	}
+
+	exp, err := Get(expName)
+	if err != nil {
+		return fmt.Errorf("getting experiment %s: %w", expName, err)
+	}
+
+	manifest := checkpointManifestPath(exp.Spec.BaseDir(), name)
+
+	if _, err := os.Stat(manifest); err != nil {
+		return fmt.Errorf("checkpoint %s for experiment %s: %w", name, expName, ErrCheckpointNotFound)
+	}
+
+	if err := file.DeleteFile(checkpointFilesDir(expName, name)); err != nil {
+		return fmt.Errorf("deleting checkpoint files: %w", err)
+	}
+
+	return os.Remove(manifest)
+}
+
+// validateCheckpointName ensures the given checkpoint name can't be used to
+// reference files outside of the experiment's checkpoints directories.
+func validateCheckpointName(name string) error {
+	if !checkpointNameRegex.MatchString(name) || name == "." || name == ".." {
+		return fmt.Errorf("invalid checkpoint name %s", name)
+	}
+
+	return nil
+}
+
+func checkpointFilesDir(expName, name string) string {
+	return fmt.Sprintf("%s/files/checkpoints/%s", expName, name)
+}
+
+func checkpointManifestPath(baseDir, name string) string {
+	return filepath.Join(baseDir, "checkpoints", name+".json")
+}
+
+func writeCheckpointManifest(path string, checkpoint *Checkpoint) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+
+	body, err := json.MarshalIndent(checkpoint, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(path, body, 0644)
+}
+
+func readCheckpointManifest(path string) (*Checkpoint, error) {
+	body, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, ErrCheckpointNotFound
+		}
+
+		return nil, err
+	}
+
+	var checkpoint Checkpoint
+
+	if err := json.Unmarshal(body, &checkpoint); err != nil {
+		return nil, err
+	}
+
+	return &checkpoint, nil
+}
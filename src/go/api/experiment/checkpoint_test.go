@@ -0,0 +1,187 @@
+package experiment
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"phenix/store"
+	"phenix/util/common"
+	"phenix/util/file"
+	"phenix/util/mm"
+	"phenix/util/mm/mmfake"
+)
+
+var checkpointScript = `
+namespace foo
+ns queueing true
+
+clear vm config
+vm config schedule compute0
+vm config memory 2048
+vm config disk linux.qc2
+vm launch kvm host-00
+
+clear vm config
+vm config schedule compute1
+vm config memory 4096
+vm config disk linux.qc2
+vm launch kvm host-01
+`
+
+func TestCheckpoint(t *testing.T) {
+	base := t.TempDir()
+
+	common.PhenixBase = base
+	store.DefaultStore = store.NewBoltDB()
+
+	if err := store.DefaultStore.Init(store.Endpoint("bolt://" + filepath.Join(base, "phenix.bdb"))); err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+
+	cluster := mmfake.New(
+		mmfake.Headnode("compute0"),
+		mmfake.ClusterHost(mm.Host{Name: "compute0", CPUs: 8, MemTotal: 16384}),
+		mmfake.ClusterHost(mm.Host{Name: "compute1", CPUs: 8, MemTotal: 16384}),
+	)
+
+	mm.DefaultMM = cluster
+	file.DefaultClusterFiles = cluster
+
+	script := filepath.Join(base, "foo.mm")
+
+	if err := os.WriteFile(script, []byte(checkpointScript), 0600); err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+
+	if err := cluster.ReadScriptFromFile(script); err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+
+	// Leave host-01 paused so it isn't resumed after the checkpoint or restore.
+	if err := cluster.LaunchVMs("foo", "host-00"); err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+
+	c := &store.Config{
+		Version:  "phenix.sandia.gov/v1",
+		Kind:     "Experiment",
+		Metadata: store.ConfigMetadata{Name: "foo"},
+		Spec: map[string]any{
+			"experimentName": "foo",
+			"baseDir":        filepath.Join(base, "experiments", "foo"),
+			"topology":       map[string]any{"nodes": []any{}},
+		},
+		Status: map[string]any{
+			"startTime": "2024-01-01T00:00:00Z",
+			"vlans":     map[string]any{"EXP": 101},
+			"apps":      map[string]any{"foo": "bar"},
+		},
+	}
+
+	if err := store.Create(c); err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+
+	var progress []CheckpointProgress
+
+	checkpoint, err := CreateCheckpoint("foo", "before", func(p CheckpointProgress) { progress = append(progress, p) })
+	if err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+
+	if len(checkpoint.VMs) != 2 || checkpoint.VMs["host-01"].Host != "compute1" {
+		t.Logf("unexpected checkpoint VMs: %v", checkpoint.VMs)
+		t.FailNow()
+	}
+
+	if last := progress[len(progress)-1]; last.Progress != 1 {
+		t.Logf("expected final progress of 1.0, got %f", last.Progress)
+		t.FailNow()
+	}
+
+	if state, _ := mm.GetVMState(mm.NS("foo"), mm.VMName("host-00")); state != "RUNNING" {
+		t.Logf("expected host-00 to be resumed after checkpoint, got %s", state)
+		t.FailNow()
+	}
+
+	if state, _ := mm.GetVMState(mm.NS("foo"), mm.VMName("host-01")); state != "PAUSED" {
+		t.Logf("expected host-01 to remain paused after checkpoint, got %s", state)
+		t.FailNow()
+	}
+
+	if _, err := CreateCheckpoint("foo", "before", nil); err == nil {
+		t.Log("expected error creating duplicate checkpoint")
+		t.FailNow()
+	}
+
+	checkpoints, err := Checkpoints("foo")
+	if err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+
+	if len(checkpoints) != 1 || checkpoints[0].Name != "before" {
+		t.Logf("unexpected checkpoints: %v", checkpoints)
+		t.FailNow()
+	}
+
+	exp, _ := Get("foo")
+
+	exp.Status.SetAppStatus("foo", "baz")
+	exp.WriteToStore(true)
+
+	if err := RestoreCheckpoint("foo", "before", nil); err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+
+	if state, _ := mm.GetVMState(mm.NS("foo"), mm.VMName("host-00")); state != "RUNNING" {
+		t.Logf("expected host-00 to be running after restore, got %s", state)
+		t.FailNow()
+	}
+
+	exp, _ = Get("foo")
+
+	if status := exp.Status.AppStatus()["foo"]; status != "bar" {
+		t.Logf("expected app status to be restored, got %v", status)
+		t.FailNow()
+	}
+
+	if err := DeleteCheckpoint("foo", "before"); err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+
+	for _, f := range cluster.Files() {
+		if filepath.Dir(f) == "foo/files/checkpoints/before" {
+			t.Logf("expected checkpoint file %s to be deleted", f)
+			t.FailNow()
+		}
+	}
+
+	if err := RestoreCheckpoint("foo", "before", nil); err == nil {
+		t.Log("expected error restoring deleted checkpoint")
+		t.FailNow()
+	}
+}
+
+func TestCheckpointInvalidName(t *testing.T) {
+	for _, name := range []string{"../foo", "..", ".", "foo/bar", ""} {
+		if err := RestoreCheckpoint("foo", name, nil); err == nil || err.Error() != "invalid checkpoint name "+name {
+			t.Logf("expected invalid name error restoring checkpoint %q, got %v", name, err)
+			t.FailNow()
+		}
+
+		if err := DeleteCheckpoint("foo", name); err == nil || err.Error() != "invalid checkpoint name "+name {
+			t.Logf("expected invalid name error deleting checkpoint %q, got %v", name, err)
+			t.FailNow()
+		}
+	}
+}
@@ -0,0 +1,360 @@
+package experiment
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"phenix/api/config"
+	"phenix/store"
+	"phenix/types"
+	"phenix/types/version"
+	"phenix/util/common"
+	"phenix/util/plog"
+
+	"github.com/activeshadow/structs"
+	jsonpatch "github.com/evanphx/json-patch"
+)
+
+// Clone creates a new experiment named dst using the spec of the existing
+// experiment src. The new experiment's default bridge and any VLAN alias IDs
+// that conflict with existing experiments are regenerated so both experiments
+// can run at the same time, and files in the original experiment's base
+// directory are copied (or symlinked) to the new experiment's base directory.
+// Topology and scenario overrides can be applied using JSON patches. It returns
+// any errors encountered while cloning the experiment.
+func Clone(src, dst string, opts ...CloneOption) error {
+	o := newCloneOptions(opts...)
+
+	if dst == "" {
+		return fmt.Errorf("no experiment name provided")
+	}
+
+	if strings.ToLower(dst) == "all" {
+		return fmt.Errorf("cannot use 'all' for experiment name")
+	}
+
+	switch o.files {
+	case CloneFilesCopy, CloneFilesLink, CloneFilesNone:
+	default:
+		return fmt.Errorf("unknown clone files mode %s", o.files)
+	}
+
+	srcC, _ := store.NewConfig("experiment/" + src)
+
+	if err := store.Get(srcC); err != nil {
+		return fmt.Errorf("getting experiment %s: %w", src, ErrExperimentNotFound)
+	}
+
+	dstC, _ := store.NewConfig("experiment/" + dst)
+
+	if err := store.Get(dstC); err == nil {
+		return fmt.Errorf("experiment %s already exists", dst)
+	}
+
+	exp, err := types.DecodeExperimentFromConfig(*srcC)
+	if err != nil {
+		return fmt.Errorf("decoding experiment %s from config: %w", src, err)
+	}
+
+	existing, err := types.Experiments(false)
+	if err != nil {
+		return fmt.Errorf("getting existing experiments: %w", err)
+	}
+
+	srcDir := exp.Spec.BaseDir()
+	dstDir := o.baseDir
+
+	if dstDir == "" {
+		if filepath.Base(srcDir) == src {
+			// If the experiment's base directory is named after the experiment, use
+			// a sibling directory named after the new experiment.
+			dstDir = filepath.Join(filepath.Dir(srcDir), dst)
+		} else {
+			dstDir = common.PhenixBase + "/experiments/" + dst
+		}
+	}
+
+	if abs, err := filepath.Abs(dstDir); err == nil {
+		dstDir = abs
+	}
+
+	if filepath.Clean(dstDir) == filepath.Clean(srcDir) {
+		return fmt.Errorf("cloned experiment cannot share base directory %s with experiment %s", srcDir, src)
+	}
+
+	// The base directory is removed when the cloned experiment is deleted, so
+	// never let a clone take over an existing directory.
+	if _, err := os.Stat(dstDir); err == nil {
+		return fmt.Errorf("base directory %s for cloned experiment already exists", dstDir)
+	}
+
+	exp.Spec.SetExperimentName(dst)
+	exp.Spec.SetBaseDir(dstDir)
+	exp.Spec.SetDefaultBridge(cloneBridge(exp.Spec.DefaultBridge(), dst, o.defaultBridge, existing))
+	exp.Spec.VLANs().SetAliases(cloneVLANAliases(exp.Spec.VLANs().Aliases(), o.vlanAliases, existing))
+
+	if o.files != CloneFilesNone {
+		// Point injections of files in the original experiment's base directory to
+		// the copies (or links) in the new experiment's base directory.
+		for _, node := range exp.Spec.Topology().Nodes() {
+			for _, inject := range node.Injections() {
+				rel, err := filepath.Rel(srcDir, inject.Src())
+				if err != nil || strings.HasPrefix(rel, "..") {
+					continue
+				}
+
+				node.AddInject(filepath.Join(dstDir, rel), inject.Dst(), inject.Permissions(), inject.Description())
+			}
+		}
+	}
+
+	spec := structs.MapDefaultCase(exp.Spec, structs.CASESNAKE)
+
+	if o.topoPatch != nil {
+		if spec["topology"], err = applyJSONPatch(spec["topology"], o.topoPatch); err != nil {
+			return fmt.Errorf("applying topology patch: %w", err)
+		}
+	}
+
+	if o.scenarioPatch != nil {
+		if spec["scenario"], err = applyJSONPatch(spec["scenario"], o.scenarioPatch); err != nil {
+			return fmt.Errorf("applying scenario patch: %w", err)
+		}
+	}
+
+	meta := store.ConfigMetadata{
+		Name:        dst,
+		Annotations: map[string]string{"cloned-from": src},
+	}
+
+	for k, v := range srcC.Metadata.Annotations {
+		if _, ok := meta.Annotations[k]; !ok {
+			meta.Annotations[k] = v
+		}
+	}
+
+	delete(meta.Annotations, config.AnnotationOwner)
+	delete(meta.Annotations, config.AnnotationShares)
+
+	if o.owner != "" {
+		meta.Annotations[config.AnnotationOwner] = o.owner
+	}
+
+	for k, v := range o.annotations {
+		meta.Annotations[k] = v
+	}
+
+	c := &store.Config{
+		Version:  store.API_GROUP + "/" + version.StoredVersion["Experiment"],
+		Kind:     "Experiment",
+		Metadata: meta,
+		Spec:     spec,
+	}
+
+	if o.files != CloneFilesNone {
+		if err := cloneFiles(srcDir, dstDir, o.files); err != nil {
+			os.RemoveAll(dstDir)
+			return fmt.Errorf("cloning files from experiment %s: %w", src, err)
+		}
+	}
+
+	if _, err := config.Create(config.CreateFromConfig(c), config.CreateWithValidation()); err != nil {
+		if o.files != CloneFilesNone {
+			os.RemoveAll(dstDir)
+		}
+
+		return fmt.Errorf("creating experiment config: %w", err)
+	}
+
+	for _, hook := range hooks["create"] {
+		hook("create", dst)
+	}
+
+	return nil
+}
+
+// cloneBridge determines the default bridge to use for a cloned experiment.
+// Experiments using the shared `phenix` bridge keep using it, otherwise a new
+// bridge named after the cloned experiment is used, adding a numeric suffix if
+// needed to avoid conflicts with existing experiments.
+func cloneBridge(current, name, requested string, existing []*types.Experiment) string {
+	if requested != "" {
+		return requested
+	}
+
+	if current == "phenix" || common.BridgeMode == common.BRIDGE_MODE_AUTO {
+		// In auto bridge mode the bridge is set to the experiment name when the
+		// experiment config is created.
+		return current
+	}
+
+	used := make(map[string]struct{})
+
+	for _, other := range existing {
+		used[other.Spec.DefaultBridge()] = struct{}{}
+	}
+
+	bridge := truncate(name, 15)
+
+	for i := 1; ; i++ {
+		if _, ok := used[bridge]; !ok {
+			return bridge
+		}
+
+		suffix := fmt.Sprintf("-%d", i)
+		bridge = truncate(name, 15-len(suffix)) + suffix
+	}
+}
+
+// cloneVLANAliases copies the given VLAN aliases for a cloned experiment,
+// resetting any VLAN IDs already used by existing experiments so they're
+// allocated when the cloned experiment is started. Any overrides provided are
+// used as-is.
+func cloneVLANAliases(aliases, overrides map[string]int, existing []*types.Experiment) map[string]int {
+	used := make(map[int]string)
+
+	for _, other := range existing {
+		ids := other.Spec.VLANs().Aliases()
+
+		if other.Running() {
+			ids = other.Status.VLANs()
+		}
+
+		for _, id := range ids {
+			if id != 0 {
+				used[id] = other.Metadata.Name
+			}
+		}
+	}
+
+	cloned := make(map[string]int)
+
+	for alias, id := range aliases {
+		if other, ok := used[id]; ok {
+			plog.Info("regenerating VLAN ID for cloned experiment", "alias", alias, "id", id, "used-by", other)
+			id = 0
+		}
+
+		cloned[alias] = id
+	}
+
+	for alias, id := range overrides {
+		cloned[alias] = id
+	}
+
+	return cloned
+}
+
+func applyJSONPatch(doc any, patch []byte) (any, error) {
+	if doc == nil {
+		doc = map[string]any{}
+	}
+
+	p, err := jsonpatch.DecodePatch(patch)
+	if err != nil {
+		return nil, fmt.Errorf("decoding JSON patch: %w", err)
+	}
+
+	orig, err := json.Marshal(doc)
+	if err != nil {
+		return nil, fmt.Errorf("marshaling document to JSON: %w", err)
+	}
+
+	patched, err := p.Apply(orig)
+	if err != nil {
+		return nil, err
+	}
+
+	var updated map[string]any
+
+	if err := json.Unmarshal(patched, &updated); err != nil {
+		return nil, fmt.Errorf("unmarshaling patched document: %w", err)
+	}
+
+	return updated, nil
+}
+
+// cloneFiles copies (or symlinks) the files in the src directory to the dst
+// directory, recreating the directory structure.
+func cloneFiles(src, dst string, mode CloneFilesMode) error {
+	if _, err := os.Stat(src); errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+
+	return filepath.WalkDir(src, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+
+		target := filepath.Join(dst, rel)
+
+		if d.IsDir() {
+			info, err := d.Info()
+			if err != nil {
+				return err
+			}
+
+			return os.MkdirAll(target, info.Mode().Perm())
+		}
+
+		if d.Type()&fs.ModeSymlink != 0 {
+			link, err := os.Readlink(path)
+			if err != nil {
+				return err
+			}
+
+			return os.Symlink(link, target)
+		}
+
+		if mode == CloneFilesLink {
+			return os.Symlink(path, target)
+		}
+
+		return copyFile(path, target)
+	})
+}
+
+func copyFile(src, dst string) error {
+	info, err := os.Stat(src)
+	if err != nil {
+		return err
+	}
+
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_EXCL|os.O_WRONLY, info.Mode().Perm())
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+
+	return out.Close()
+}
+
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+
+	return s[:n]
+}
@@ -0,0 +1,153 @@
+package experiment
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"phenix/store"
+	"phenix/util/common"
+)
+
+func TestClone(t *testing.T) {
+	var (
+		base = t.TempDir()
+		src  = filepath.Join(base, "experiments", "foo")
+	)
+
+	common.PhenixBase = base
+	store.DefaultStore = store.NewBoltDB()
+
+	if err := store.DefaultStore.Init(store.Endpoint("bolt://" + filepath.Join(base, "phenix.bdb"))); err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+
+	if err := os.MkdirAll(filepath.Join(src, "configs"), 0755); err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+
+	if err := os.WriteFile(filepath.Join(src, "configs", "host.conf"), []byte("foo"), 0644); err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+
+	c := &store.Config{
+		Version:  "phenix.sandia.gov/v1",
+		Kind:     "Experiment",
+		Metadata: store.ConfigMetadata{Name: "foo", Annotations: map[string]string{"topology": "foo"}},
+		Spec: map[string]any{
+			"experimentName": "foo",
+			"baseDir":        src,
+			"defaultBridge":  "foo",
+			"vlans": map[string]any{
+				"aliases": map[string]any{"EXP": 101, "MGMT": 0},
+			},
+			"topology": map[string]any{
+				"nodes": []any{
+					map[string]any{
+						"type": "VirtualMachine",
+						"general": map[string]any{
+							"hostname": "host-00",
+						},
+						"hardware": map[string]any{
+							"os_type": "linux",
+							"drives":  []any{map[string]any{"image": "foo.qc2"}},
+						},
+						"network": map[string]any{
+							"interfaces": []any{
+								map[string]any{"name": "eth0", "vlan": "EXP", "address": "10.0.0.1", "mask": 24, "proto": "static", "type": "ethernet"},
+								map[string]any{"name": "eth1", "vlan": "MGMT", "address": "10.0.1.1", "mask": 24, "proto": "static", "type": "ethernet"},
+							},
+						},
+						"injections": []any{
+							map[string]any{"src": filepath.Join(src, "configs", "host.conf"), "dst": "/etc/host.conf"},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	if err := store.Create(c); err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+
+	patch := []byte(`[{"op": "replace", "path": "/nodes/0/general/hostname", "value": "host-01"}]`)
+
+	if err := Clone("foo", "bar", CloneWithTopologyPatch(patch)); err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+
+	exp, err := Get("bar")
+	if err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+
+	if exp.Metadata.Annotations["cloned-from"] != "foo" || exp.Metadata.Annotations["topology"] != "foo" {
+		t.Logf("unexpected annotations: %v", exp.Metadata.Annotations)
+		t.FailNow()
+	}
+
+	if exp.Spec.DefaultBridge() != "bar" {
+		t.Logf("expected default bridge bar, got %s", exp.Spec.DefaultBridge())
+		t.FailNow()
+	}
+
+	if aliases := exp.Spec.VLANs().Aliases(); aliases["EXP"] != 0 || len(aliases) != 2 {
+		t.Logf("expected conflicting VLAN ID to be reset, got %v", aliases)
+		t.FailNow()
+	}
+
+	node := exp.Spec.Topology().Nodes()[0]
+
+	if node.General().Hostname() != "host-01" {
+		t.Logf("expected patched hostname host-01, got %s", node.General().Hostname())
+		t.FailNow()
+	}
+
+	if bridge := node.Network().Interfaces()[0].Bridge(); bridge != "bar" {
+		t.Logf("expected interface bridge bar, got %s", bridge)
+		t.FailNow()
+	}
+
+	dst := filepath.Join(base, "experiments", "bar")
+
+	if exp.Spec.BaseDir() != dst {
+		t.Logf("expected base directory %s, got %s", dst, exp.Spec.BaseDir())
+		t.FailNow()
+	}
+
+	inject := filepath.Join(dst, "configs", "host.conf")
+
+	if src := node.Injections()[0].Src(); src != inject {
+		t.Logf("expected injection source %s, got %s", inject, src)
+		t.FailNow()
+	}
+
+	if body, err := os.ReadFile(inject); err != nil || string(body) != "foo" {
+		t.Logf("expected file to be copied (%v)", err)
+		t.FailNow()
+	}
+
+	if err := Clone("foo", "bar"); err == nil {
+		t.Log("expected error cloning to an existing experiment")
+		t.FailNow()
+	}
+
+	// Cloning into an existing directory must fail even when no files are
+	// cloned, since the directory is removed when the experiment is deleted.
+	if err := Clone("foo", "baz", CloneWithBaseDirectory(dst), CloneWithFiles(CloneFilesNone)); err == nil {
+		t.Log("expected error cloning to an existing base directory")
+		t.FailNow()
+	}
+
+	if _, err := Get("baz"); err == nil {
+		t.Log("expected experiment baz to not be created")
+		t.FailNow()
+	}
+}
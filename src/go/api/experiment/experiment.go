@@ -12,6 +12,7 @@ import (
 	"time"
 
 	"phenix/api/config"
+	"phenix/api/quota"
 	"phenix/app"
 	"phenix/scheduler"
 	"phenix/store"
@@ -22,7 +23,6 @@ import (
 	"phenix/util/common"
 	"phenix/util/file"
 	"phenix/util/mm"
-	"phenix/util/mm/mmcli"
 	"phenix/util/notes"
 	"phenix/util/plog"
 	"phenix/util/pubsub"
@@ -337,6 +337,10 @@ func Create(ctx context.Context, opts ...CreateOption) error {
 		}
 	}
 
+	if o.owner != "" {
+		meta.Annotations[config.AnnotationOwner] = o.owner
+	}
+
 	c := &store.Config{
 		Version:  store.API_GROUP + "/" + apiVersion,
 		Kind:     kind,
@@ -402,7 +406,10 @@ func Schedule(opts ...ScheduleOption) error {
 }
 
 // Start starts the experiment with the given name. It returns any errors
-// encountered while starting the experiment.
+// encountered while starting the experiment. If starting the experiment fails
+// midway, the steps already taken are rolled back and a RollbackError reporting
+// the outcome of the rollback is returned (or sent to the error channel if the
+// failure occurs in the background).
 func Start(ctx context.Context, opts ...StartOption) error {
 	o := newStartOptions(opts...)
 
@@ -431,8 +438,54 @@ func Start(ctx context.Context, opts ...StartOption) error {
 		exp.Spec.VLANs().SetMax(o.vlanMax)
 	}
 
+	if !o.dryrun {
+		// Hold the user's quota until the experiment is marked as started, at
+		// which point its resources are included in the user's usage.
+		defer quota.Lock(o.user)()
+
+		if err := quota.Check(o.user, quota.ExperimentUsage(exp)); err != nil {
+			return fmt.Errorf("starting experiment %s: %w", o.name, err)
+		}
+	}
+
+	if exp.Metadata.Annotations == nil {
+		exp.Metadata.Annotations = make(map[string]string)
+	}
+
+	// Record who started the experiment so its resources count against their
+	// quota while it's running.
+	if o.user == "" || o.dryrun {
+		delete(exp.Metadata.Annotations, quota.AnnotationUser)
+	} else {
+		exp.Metadata.Annotations[quota.AnnotationUser] = o.user
+	}
+
+	// Each step taken below that changes the state of the experiment, or of the
+	// cluster the experiment is running on, registers a compensating action with
+	// the rollback so the steps already taken can be undone (in reverse order) if
+	// a later step fails.
+	rb := newRollback(exp.Spec.ExperimentName())
+
+	rb.register("experiment status", func() error {
+		exp.Modify(func() {
+			exp.Status.SetStartTime("")
+			exp.Status.SetSchedule(nil)
+			exp.Status.SetVLANs(nil)
+		})
+
+		return exp.WriteToStore(true)
+	})
+
+	// Register before applying pre-start apps since some apps may have already
+	// been applied if one of them fails. The cleanup stage is applied before the
+	// VMs are killed and the namespace is cleared, just like when stopping an
+	// experiment, so it's unwound before the steps registered after it.
+	rb.registerFirst("pre-start apps", func() error {
+		return app.ApplyApps(context.TODO(), exp, app.Stage(app.ACTIONCLEANUP), app.DryRun(o.dryrun))
+	})
+
 	if err := app.ApplyApps(ctx, exp, app.Stage(app.ACTIONPRESTART), app.DryRun(o.dryrun)); err != nil {
-		return fmt.Errorf("applying apps to experiment: %w", err)
+		return rb.unwind(fmt.Errorf("applying apps to experiment: %w", err))
 	}
 
 	var (
@@ -441,12 +494,12 @@ func Start(ctx context.Context, opts ...StartOption) error {
 	)
 
 	if err := tmpl.CreateFileFromTemplate("minimega_script.tmpl", exp.Spec, mmScript); err != nil {
-		return fmt.Errorf("generating minimega script: %w", err)
+		return rb.unwind(fmt.Errorf("generating minimega script: %w", err))
 	}
 
 	if exp.Spec.Topology().HasCommands() {
 		if err := tmpl.CreateFileFromTemplate("minimega_cc_script.tmpl", exp.Spec.Topology().Nodes(), ccScript); err != nil {
-			return fmt.Errorf("generating minimega cc script: %w", err)
+			return rb.unwind(fmt.Errorf("generating minimega cc script: %w", err))
 		}
 	}
 
@@ -465,13 +518,19 @@ func Start(ctx context.Context, opts ...StartOption) error {
 		// snapshots for any reason, but we do clean them up when an experiment is
 		// deleted.
 		if err := deleteC2AndSnapshots(exp); err != nil {
-			return fmt.Errorf("deleting experiment snapshots and CC responses: %w", err)
+			return rb.unwind(fmt.Errorf("deleting experiment snapshots and CC responses: %w", err))
 		}
 
+		// Clearing the namespace removes the VMs, taps, bridges, and tunnels
+		// created by the minimega script (and anything else done within the
+		// namespace while starting the experiment).
+		rb.register("minimega script", func() error {
+			return mm.ClearNamespace(exp.Spec.ExperimentName())
+		})
+
 		if err := mm.ReadScriptFromFile(mmScript); err != nil {
 			if !o.mmErrAsWarn {
-				mm.ClearNamespace(exp.Spec.ExperimentName())
-				return fmt.Errorf("reading minimega script: %w", err)
+				return rb.unwind(fmt.Errorf("reading minimega script: %w", err))
 			}
 
 			if merr, ok := err.(*multierror.Error); ok {
@@ -539,10 +598,13 @@ func Start(ctx context.Context, opts ...StartOption) error {
 			start = nil
 		}
 
+		rb.register("VM launch", func() error {
+			return mm.KillVM(mm.NS(exp.Spec.ExperimentName()), mm.VMName("all"))
+		})
+
 		if err := mm.LaunchVMs(exp.Spec.ExperimentName(), start...); err != nil {
 			if !o.mmErrAsWarn {
-				mm.ClearNamespace(exp.Spec.ExperimentName())
-				return fmt.Errorf("launching experiment VMs: %w", err)
+				return rb.unwind(fmt.Errorf("launching experiment VMs: %w", err))
 			}
 
 			if merr, ok := err.(*multierror.Error); ok {
@@ -560,8 +622,7 @@ func Start(ctx context.Context, opts ...StartOption) error {
 		if exp.Spec.UseGREMesh() {
 			if err := mm.CreateBridge(mm.NS(exp.Metadata.Name), mm.Bridge(exp.Spec.DefaultBridge())); err != nil {
 				if !o.mmErrAsWarn {
-					mm.ClearNamespace(exp.Spec.ExperimentName())
-					return fmt.Errorf("creating experiment bridge: %w", err)
+					return rb.unwind(fmt.Errorf("creating experiment bridge: %w", err))
 				}
 
 				if merr, ok := err.(*multierror.Error); ok {
@@ -582,8 +643,7 @@ func Start(ctx context.Context, opts ...StartOption) error {
 
 		vlans, err := mm.GetVLANs(mm.NS(exp.Spec.ExperimentName()))
 		if err != nil {
-			mm.ClearNamespace(exp.Spec.ExperimentName())
-			return fmt.Errorf("processing experiment VLANs: %w", err)
+			return rb.unwind(fmt.Errorf("processing experiment VLANs: %w", err))
 		}
 
 		exp.Status.SetVLANs(vlans)
@@ -595,96 +655,90 @@ func Start(ctx context.Context, opts ...StartOption) error {
 		start += "-DRYRUN"
 	}
 
-	if o.errChan == nil {
+	// finish executes the steps of starting an experiment that are done in the
+	// background after the experiment has been marked as started when an error
+	// channel is provided.
+	finish := func() error {
 		if !o.dryrun {
 			if exp.Spec.Topology().HasCommands() {
 				if err := mm.ReadScriptFromFile(ccScript); err != nil {
-					errors := multierror.Append(nil, fmt.Errorf("reading minimega cc script: %w", err))
+					return fmt.Errorf("reading minimega cc script: %w", err)
+				}
+			}
 
-					if err := mm.ClearNamespace(exp.Spec.ExperimentName()); err != nil {
-						errors = multierror.Append(errors, fmt.Errorf("killing experiment VMs: %w", err))
-					}
+			rb.register("delayed VMs", func() error {
+				var (
+					hosts  []string
+					errors error
+				)
 
-					return errors
+				for host := range delays {
+					hosts = append(hosts, host)
 				}
-			}
 
-			if err := handleDelayedVMs(ctx, exp.Spec.ExperimentName(), delays, c2s); err != nil {
-				errors := multierror.Append(nil, fmt.Errorf("handling delayed VMs: %w", err))
+				for host := range c2s {
+					hosts = append(hosts, host)
+				}
 
-				if err := mm.ClearNamespace(exp.Spec.ExperimentName()); err != nil {
-					errors = multierror.Append(errors, fmt.Errorf("killing experiment VMs: %w", err))
+				for _, host := range hosts {
+					if err := mm.KillVM(mm.NS(exp.Spec.ExperimentName()), mm.VMName(host)); err != nil {
+						errors = multierror.Append(errors, err)
+					}
 				}
 
 				return errors
+			})
+
+			if err := handleDelayedVMs(ctx, exp.Spec.ExperimentName(), delays, c2s); err != nil {
+				return fmt.Errorf("handling delayed VMs: %w", err)
 			}
 		}
 
+		// The compensating action for post-start apps is the cleanup stage, which
+		// is already registered for the pre-start apps.
 		if err := app.ApplyApps(ctx, exp, app.Stage(app.ACTIONPOSTSTART), app.DryRun(o.dryrun)); err != nil {
-			errors := multierror.Append(nil, fmt.Errorf("applying apps to experiment: %w", err))
-
-			if err := app.ApplyApps(context.TODO(), exp, app.Stage(app.ACTIONCLEANUP), app.DryRun(o.dryrun)); err != nil {
-				errors = multierror.Append(errors, fmt.Errorf("cleaning up app experiments: %w", err))
-			}
+			return fmt.Errorf("applying apps to experiment: %w", err)
+		}
 
-			if err := mm.ClearNamespace(exp.Spec.ExperimentName()); err != nil {
-				errors = multierror.Append(errors, fmt.Errorf("killing experiment VMs: %w", err))
-			}
+		return nil
+	}
 
-			return errors
+	if o.errChan == nil {
+		if err := finish(); err != nil {
+			return rb.unwind(err)
 		}
-	} else {
-		go func() {
-			defer close(o.errChan)
-
-			if !o.dryrun {
-				if exp.Spec.Topology().HasCommands() {
-					if err := mm.ReadScriptFromFile(ccScript); err != nil {
-						o.errChan <- fmt.Errorf("reading minimega cc script: %w", err)
+	}
 
-						if err := Stop(exp.Spec.ExperimentName()); err != nil {
-							o.errChan <- fmt.Errorf("stopping experiment: %w", err)
-						}
+	exp.Status.SetStartTime(start)
 
-						return
-					}
-				}
+	// Apps may have written to the experiment in the store while it was being
+	// started, so let WriteToStore handle any conflicting updates.
+	if err := exp.WriteToStore(false); err != nil {
+		return rb.unwind(fmt.Errorf("updating experiment config: %w", err))
+	}
 
-				if err := handleDelayedVMs(ctx, exp.Spec.ExperimentName(), delays, c2s); err != nil {
-					o.errChan <- fmt.Errorf("handling delayed VMs: %w", err)
+	for _, hook := range hooks["start"] {
+		hook("start", o.name)
+	}
 
-					if err := Stop(exp.Spec.ExperimentName()); err != nil {
-						o.errChan <- fmt.Errorf("stopping experiment: %w", err)
-					}
+	rb.register("start hooks", func() error {
+		for _, hook := range hooks["stop"] {
+			hook("stop", o.name)
+		}
 
-					return
-				}
-			}
+		return nil
+	})
 
-			if err := app.ApplyApps(ctx, exp, app.Stage(app.ACTIONPOSTSTART), app.DryRun(o.dryrun)); err != nil {
-				o.errChan <- fmt.Errorf("applying apps to experiment: %w", err)
+	if o.errChan != nil {
+		go func() {
+			defer close(o.errChan)
 
-				if err := Stop(exp.Spec.ExperimentName()); err != nil {
-					o.errChan <- fmt.Errorf("stopping experiment: %w", err)
-				}
+			if err := finish(); err != nil {
+				o.errChan <- rb.unwind(err)
 			}
 		}()
 	}
 
-	exp.Status.SetStartTime(start)
-
-	c.Spec = structs.MapDefaultCase(exp.Spec, structs.CASESNAKE)
-	c.Status = structs.MapDefaultCase(exp.Status, structs.CASESNAKE)
-
-	if err := store.Update(c); err != nil {
-		mm.ClearNamespace(exp.Spec.ExperimentName())
-		return fmt.Errorf("updating experiment config: %w", err)
-	}
-
-	for _, hook := range hooks["start"] {
-		hook("start", o.name)
-	}
-
 	return nil
 }
 
@@ -722,10 +776,7 @@ func Stop(name string) error {
 
 	exp.Status.SetStartTime("")
 
-	c.Spec = structs.MapDefaultCase(exp.Spec, structs.CASESNAKE)
-	c.Status = structs.MapDefaultCase(exp.Status, structs.CASESNAKE)
-
-	if err := store.Update(c); err != nil {
+	if err := exp.WriteToStore(false); err != nil {
 		errors = multierror.Append(errors, fmt.Errorf("updating experiment config: %w", err))
 	}
 
@@ -1008,10 +1059,7 @@ func handleDelayedVMs(ctx context.Context, ns string, delays map[string]time.Dur
 				errors = multierror.Append(errors, ctx.Err())
 				return
 			case <-time.After(delay):
-				cmd := mmcli.NewNamespacedCommand(ns)
-				cmd.Command = "vm start " + host
-
-				if err := mmcli.ErrorResponse(mmcli.Run(cmd)); err != nil {
+				if err := mm.StartVM(mm.NS(ns), mm.VMName(host)); err != nil {
 					errors = multierror.Append(errors, NewDelayedVMError(host, err, "starting VM %s", host))
 					return
 				}
@@ -1053,10 +1101,7 @@ func handleDelayedVMs(ctx context.Context, ns string, delays map[string]time.Dur
 					}
 
 					if done {
-						cmd := mmcli.NewNamespacedCommand(ns)
-						cmd.Command = "vm start " + host
-
-						if err := mmcli.ErrorResponse(mmcli.Run(cmd)); err != nil {
+						if err := mm.StartVM(mm.NS(ns), mm.VMName(host)); err != nil {
 							errors = multierror.Append(errors, NewDelayedVMError(host, err, "starting VM %s", host))
 							return
 						}
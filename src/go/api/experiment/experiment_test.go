@@ -1,9 +1,19 @@
 package experiment
 
 import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
 	"testing"
 
+	"phenix/app"
 	"phenix/store"
+	"phenix/types"
+	"phenix/util/common"
+	"phenix/util/file"
+	"phenix/util/mm"
+	"phenix/util/mm/mmfake"
 
 	"github.com/golang/mock/gomock"
 )
@@ -40,3 +50,301 @@ func TestList(t *testing.T) {
 		t.FailNow()
 	}
 }
+
+// startableExperiment creates an experiment named foo in the store with the
+// given base directory that can be started against mmfake.
+func startableExperiment(t *testing.T, base string) {
+	// The startup app keeps VMs from booting if their disk image is missing.
+	image := filepath.Join(base, "images", "linux.qc2")
+
+	if err := os.MkdirAll(filepath.Dir(image), 0755); err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+
+	if err := os.WriteFile(image, nil, 0644); err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+
+	node := func(hostname, address string) map[string]any {
+		return map[string]any{
+			"type": "VirtualMachine",
+			"general": map[string]any{
+				"hostname": hostname,
+			},
+			"hardware": map[string]any{
+				"os_type": "linux",
+				"vcpus":   1,
+				"memory":  1024,
+				"drives":  []any{map[string]any{"image": image}},
+			},
+			"network": map[string]any{
+				"interfaces": []any{
+					map[string]any{"name": "eth0", "vlan": "EXP", "address": address, "mask": 24, "proto": "static", "type": "ethernet"},
+				},
+			},
+		}
+	}
+
+	c := &store.Config{
+		Version:  "phenix.sandia.gov/v1",
+		Kind:     "Experiment",
+		Metadata: store.ConfigMetadata{Name: "foo"},
+		Spec: map[string]any{
+			"experimentName": "foo",
+			"baseDir":        filepath.Join(base, "experiments", "foo"),
+			"defaultBridge":  "phenix",
+			"schedules":      map[string]any{"host-00": "compute1"},
+			"vlans": map[string]any{
+				"aliases": map[string]any{"EXP": 0},
+				"min":     200,
+				"max":     300,
+			},
+			"topology": map[string]any{
+				"nodes": []any{node("host-00", "10.0.0.1"), node("host-01", "10.0.0.2")},
+			},
+		},
+	}
+
+	if err := store.Create(c); err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+}
+
+// newStartCluster sets up the store and a fake minimega cluster for starting
+// experiments, restoring the default minimega implementation when the test
+// finishes.
+func newStartCluster(t *testing.T) (string, *mmfake.Minimega) {
+	base := t.TempDir()
+
+	common.PhenixBase = base
+	store.DefaultStore = store.NewBoltDB()
+
+	if err := store.DefaultStore.Init(store.Endpoint("bolt://" + filepath.Join(base, "phenix.bdb"))); err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+
+	cluster := mmfake.New(
+		mmfake.Headnode("compute0"),
+		mmfake.ClusterHost(mm.Host{Name: "compute0", CPUs: 8, MemTotal: 16384, Schedulable: true}),
+		mmfake.ClusterHost(mm.Host{Name: "compute1", CPUs: 8, MemTotal: 16384, Schedulable: true}),
+	)
+
+	var (
+		defaultMM    = mm.DefaultMM
+		defaultFiles = file.DefaultClusterFiles
+	)
+
+	mm.DefaultMM = cluster
+	file.DefaultClusterFiles = cluster
+
+	t.Cleanup(func() {
+		mm.DefaultMM = defaultMM
+		file.DefaultClusterFiles = defaultFiles
+	})
+
+	startableExperiment(t, base)
+
+	return base, cluster
+}
+
+func TestStartStop(t *testing.T) {
+	_, cluster := newStartCluster(t)
+
+	if err := Schedule(ScheduleForName("foo"), ScheduleWithAlgorithm("round-robin")); err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+
+	if err := Start(context.Background(), StartWithName("foo")); err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+
+	exp, err := Get("foo")
+	if err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+
+	if !exp.Running() {
+		t.Log("expected experiment to be running")
+		t.FailNow()
+	}
+
+	vms := mm.GetVMInfo(mm.NS("foo"))
+
+	if len(vms) != 2 {
+		t.Logf("expected 2 VMs to be launched from the minimega script, got %d", len(vms))
+		t.FailNow()
+	}
+
+	for _, vm := range vms {
+		if vm.State != "RUNNING" || vm.CPUs != 1 || vm.RAM != 1024 {
+			t.Logf("unexpected VM launched from the minimega script: %+v", vm)
+			t.FailNow()
+		}
+	}
+
+	if vlan := exp.Status.VLANs()["EXP"]; vlan < 200 || vlan > 300 {
+		t.Logf("expected EXP VLAN to be allocated from the experiment's range, got %d", vlan)
+		t.FailNow()
+	}
+
+	// VMs are launched on the hosts they were manually scheduled on, or chosen
+	// by the scheduler.
+	for vm, host := range map[string]string{"host-00": "compute1", "host-01": "compute0"} {
+		if scheduled := exp.Status.Schedules()[vm]; scheduled != host {
+			t.Logf("expected %s to be scheduled on %s, got %s", vm, host, scheduled)
+			t.FailNow()
+		}
+	}
+
+	if err := Stop("foo"); err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+
+	if Running("foo") {
+		t.Log("expected experiment to be stopped")
+		t.FailNow()
+	}
+
+	if ns := cluster.Namespaces(); len(ns) != 0 {
+		t.Logf("expected namespace to be cleared when stopping, got %v", ns)
+		t.FailNow()
+	}
+
+	// Stopped experiments can be started again.
+	if err := Start(context.Background(), StartWithName("foo")); err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+
+	if vms := mm.GetVMInfo(mm.NS("foo")); len(vms) != 2 {
+		t.Logf("expected 2 VMs after restarting, got %d", len(vms))
+		t.FailNow()
+	}
+}
+
+// recorderApp records how many of the experiment's VMs still exist when its
+// cleanup stage is applied, and fails its post-start stage if told to.
+type recorderApp struct {
+	failPostStart bool
+	cleanupVMs    int
+}
+
+func (this *recorderApp) Init(...app.Option) error { return nil }
+func (this *recorderApp) Name() string             { return "recorder" }
+
+func (this *recorderApp) Configure(context.Context, *types.Experiment) error { return nil }
+func (this *recorderApp) PreStart(context.Context, *types.Experiment) error  { return nil }
+func (this *recorderApp) Running(context.Context, *types.Experiment) error   { return nil }
+
+func (this *recorderApp) PostStart(context.Context, *types.Experiment) error {
+	if this.failPostStart {
+		return errors.New("post-start failed")
+	}
+
+	return nil
+}
+
+func (this *recorderApp) Cleanup(_ context.Context, exp *types.Experiment) error {
+	this.cleanupVMs = len(mm.GetVMInfo(mm.NS(exp.Metadata.Name)))
+	return nil
+}
+
+var recorder = new(recorderApp)
+
+func init() {
+	app.RegisterUserApp("recorder", func() app.App { return recorder })
+}
+
+func TestStartRollback(t *testing.T) {
+	_, cluster := newStartCluster(t)
+
+	c, _ := store.NewConfig("experiment/foo")
+
+	if err := store.Get(c); err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+
+	c.Spec["scenario"] = map[string]any{"apps": []any{map[string]any{"name": "recorder"}}}
+
+	if err := store.Update(c); err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+
+	failures := []struct {
+		name   string
+		inject func()
+	}{
+		{"VM launch", func() { cluster.FailOn("vm start", errors.New("boom")) }},
+		{"post-start apps", func() { recorder.failPostStart = true }},
+	}
+
+	for _, f := range failures {
+		cluster.ClearFailures()
+
+		recorder.failPostStart = false
+		recorder.cleanupVMs = -1
+
+		f.inject()
+
+		err := Start(context.Background(), StartWithName("foo"))
+
+		var rbErr RollbackError
+
+		if !errors.As(err, &rbErr) || rbErr.Failed() {
+			t.Logf("expected experiment start to be rolled back after %s failure, got %v", f.name, err)
+			t.FailNow()
+		}
+
+		// The cleanup stage of the apps is applied before the VMs are killed and
+		// the namespace is cleared, just like when stopping an experiment.
+		if recorder.cleanupVMs != 2 {
+			t.Logf("expected cleanup apps to be applied while VMs exist after %s failure, got %d VMs", f.name, recorder.cleanupVMs)
+			t.FailNow()
+		}
+
+		if ns := cluster.Namespaces(); len(ns) != 0 {
+			t.Logf("expected namespace to be cleared after %s failure, got %v", f.name, ns)
+			t.FailNow()
+		}
+
+		exp, err := Get("foo")
+		if err != nil {
+			t.Log(err)
+			t.FailNow()
+		}
+
+		if exp.Running() || len(exp.Status.VLANs()) != 0 || len(exp.Status.Schedules()) != 0 {
+			t.Logf("expected experiment status to be reset after %s failure, got %+v", f.name, exp.Status)
+			t.FailNow()
+		}
+
+		events, err := store.GetEventsBy(store.Event{Type: store.EventTypeHistory})
+		if err != nil {
+			t.Log(err)
+			t.FailNow()
+		}
+
+		var recorded bool
+
+		for _, e := range events {
+			if e.Metadata["action"] == "start-rollback" && e.Message == rbErr.Report() {
+				recorded = true
+			}
+		}
+
+		if !recorded {
+			t.Logf("expected rollback history event to be recorded after %s failure", f.name)
+			t.FailNow()
+		}
+	}
+}
@@ -21,6 +21,7 @@ type createOptions struct {
 	deployMode    common.DeploymentMode
 	useGREMesh    bool
 	defaultBridge string
+	owner         string
 }
 
 func newCreateOptions(opts ...CreateOption) createOptions {
@@ -123,6 +124,14 @@ func CreateWithDefaultBridge(b string) CreateOption {
 	}
 }
 
+// CreateWithOwner sets the user that owns the new experiment, which is used to
+// evaluate ownership policies.
+func CreateWithOwner(u string) CreateOption {
+	return func(o *createOptions) {
+		o.owner = u
+	}
+}
+
 type SaveOption func(*saveOptions)
 
 type saveOptions struct {
@@ -213,6 +222,10 @@ type startOptions struct {
 	vlanMax int
 	errChan chan error
 
+	// User starting the experiment, whose quota the experiment's resources count
+	// against. Quotas aren't enforced if empty.
+	user string
+
 	// Option to treat all errors generated by minimega as warnings when launching
 	// an experiment.
 	mmErrAsWarn bool
@@ -263,3 +276,104 @@ func StartWithMMErrorsAsWarnings(w bool) StartOption {
 		o.mmErrAsWarn = w
 	}
 }
+
+func StartWithUser(u string) StartOption {
+	return func(o *startOptions) {
+		o.user = u
+	}
+}
+
+// CloneFilesMode specifies how files in the base directory of the experiment
+// being cloned are made available to the new experiment.
+type CloneFilesMode string
+
+const (
+	// CloneFilesCopy copies files to the new experiment's base directory.
+	CloneFilesCopy CloneFilesMode = "copy"
+
+	// CloneFilesLink symlinks files in the new experiment's base directory to the
+	// files in the original experiment's base directory.
+	CloneFilesLink CloneFilesMode = "link"
+
+	// CloneFilesNone leaves the new experiment's base directory empty.
+	CloneFilesNone CloneFilesMode = "none"
+)
+
+type CloneOption func(*cloneOptions)
+
+type cloneOptions struct {
+	annotations   map[string]string
+	baseDir       string
+	defaultBridge string
+	vlanAliases   map[string]int
+	topoPatch     []byte
+	scenarioPatch []byte
+	files         CloneFilesMode
+	owner         string
+}
+
+func newCloneOptions(opts ...CloneOption) cloneOptions {
+	o := cloneOptions{files: CloneFilesCopy}
+
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	return o
+}
+
+func CloneWithAnnotations(a map[string]string) CloneOption {
+	return func(o *cloneOptions) {
+		o.annotations = a
+	}
+}
+
+func CloneWithBaseDirectory(b string) CloneOption {
+	return func(o *cloneOptions) {
+		o.baseDir = b
+	}
+}
+
+func CloneWithDefaultBridge(b string) CloneOption {
+	return func(o *cloneOptions) {
+		o.defaultBridge = b
+	}
+}
+
+func CloneWithVLANAliases(a map[string]int) CloneOption {
+	return func(o *cloneOptions) {
+		o.vlanAliases = a
+	}
+}
+
+// CloneWithTopologyPatch applies the given JSON patch (RFC 6902) to the
+// topology of the new experiment.
+func CloneWithTopologyPatch(p []byte) CloneOption {
+	return func(o *cloneOptions) {
+		o.topoPatch = p
+	}
+}
+
+// CloneWithScenarioPatch applies the given JSON patch (RFC 6902) to the
+// scenario of the new experiment.
+func CloneWithScenarioPatch(p []byte) CloneOption {
+	return func(o *cloneOptions) {
+		o.scenarioPatch = p
+	}
+}
+
+// CloneWithOwner sets the user that owns the cloned experiment. The owner of,
+// and shares for, the source experiment are never carried over to the clone.
+func CloneWithOwner(u string) CloneOption {
+	return func(o *cloneOptions) {
+		o.owner = u
+	}
+}
+
+func CloneWithFiles(m CloneFilesMode) CloneOption {
+	return func(o *cloneOptions) {
+		if m != "" {
+			o.files = m
+		}
+	}
+}
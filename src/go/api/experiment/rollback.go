@@ -0,0 +1,159 @@
+package experiment
+
+import (
+	"fmt"
+	"strings"
+
+	"phenix/store"
+	"phenix/util/plog"
+)
+
+// compensation is an action that undoes the effects of a step taken while
+// starting an experiment.
+type compensation struct {
+	step string
+	undo func() error
+}
+
+// rollback tracks the compensating actions registered by each step taken while
+// starting an experiment so they can be unwound (in reverse order) if a later
+// step fails.
+type rollback struct {
+	exp   string
+	first []compensation
+	steps []compensation
+}
+
+func newRollback(exp string) *rollback {
+	return &rollback{exp: exp}
+}
+
+// register adds a compensating action for the given step. Steps that can fail
+// midway, leaving partial state behind, should register their compensating
+// action before being executed.
+func (this *rollback) register(step string, undo func() error) {
+	this.steps = append(this.steps, compensation{step: step, undo: undo})
+}
+
+// registerFirst adds a compensating action for the given step that's unwound
+// before those of the steps added with register, even if they were registered
+// after it. This is used for steps that must be undone while the state created
+// by later steps still exists (e.g., the cleanup stage of apps is applied
+// before the experiment's namespace is cleared, like when it's stopped).
+func (this *rollback) registerFirst(step string, undo func() error) {
+	this.first = append(this.first, compensation{step: step, undo: undo})
+}
+
+// unwind executes all the registered compensating actions in reverse order,
+// continuing on even if some of them fail. The returned error wraps the given
+// cause and includes a report of each compensating action executed. The report
+// is also recorded in the store as a history event for the experiment.
+func (this *rollback) unwind(cause error) error {
+	var (
+		err   = RollbackError{Experiment: this.exp, src: cause}
+		steps = append(this.steps, this.first...)
+	)
+
+	for i := len(steps) - 1; i >= 0; i-- {
+		step := steps[i]
+
+		result := RollbackStep{Step: step.step, Err: step.undo()}
+
+		if result.Err != nil {
+			plog.Error("rolling back experiment start step", "exp", this.exp, "step", step.step, "err", result.Err)
+		}
+
+		err.Steps = append(err.Steps, result)
+	}
+
+	this.first = nil
+	this.steps = nil
+
+	event := store.NewHistoryEvent(err.Report()).
+		WithMetadata("experiment", this.exp).
+		WithMetadata("action", "start-rollback")
+
+	if err := store.AddEvent(*event); err != nil {
+		plog.Error("recording experiment start rollback event", "exp", this.exp, "err", err)
+	}
+
+	return err
+}
+
+// RollbackStep is the result of executing the compensating action for a single
+// step taken while starting an experiment.
+type RollbackStep struct {
+	Step string
+	Err  error
+}
+
+// RollbackError is returned when starting an experiment fails and the steps
+// already taken have been rolled back. It wraps the error that caused the
+// rollback.
+type RollbackError struct {
+	Experiment string
+	Steps      []RollbackStep
+
+	src error
+}
+
+func (this RollbackError) Error() string {
+	return fmt.Sprintf("%v (%s)", this.src, this.summary())
+}
+
+func (this RollbackError) Unwrap() error {
+	return this.src
+}
+
+// Failed returns true if any of the compensating actions failed, which means
+// the experiment may have been left in an inconsistent state.
+func (this RollbackError) Failed() bool {
+	for _, step := range this.Steps {
+		if step.Err != nil {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Report returns a multi-line, human-readable report of the rollback.
+func (this RollbackError) Report() string {
+	var report strings.Builder
+
+	fmt.Fprintf(&report, "starting experiment %s failed: %v\n", this.Experiment, this.src)
+
+	if len(this.Steps) == 0 {
+		report.WriteString("nothing to roll back\n")
+	}
+
+	for _, step := range this.Steps {
+		if step.Err == nil {
+			fmt.Fprintf(&report, "  [✓] %s rolled back\n", step.Step)
+		} else {
+			fmt.Fprintf(&report, "  [✗] %s not rolled back: %v\n", step.Step, step.Err)
+		}
+	}
+
+	return report.String()
+}
+
+func (this RollbackError) summary() string {
+	if len(this.Steps) == 0 {
+		return "nothing to roll back"
+	}
+
+	var failed []string
+
+	for _, step := range this.Steps {
+		if step.Err != nil {
+			failed = append(failed, fmt.Sprintf("%s: %v", step.Step, step.Err))
+		}
+	}
+
+	if len(failed) == 0 {
+		return fmt.Sprintf("rolled back %d steps", len(this.Steps))
+	}
+
+	return fmt.Sprintf("rolled back %d of %d steps; failed to roll back %s", len(this.Steps)-len(failed), len(this.Steps), strings.Join(failed, ", "))
+}
@@ -0,0 +1,99 @@
+package experiment
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"phenix/store"
+)
+
+func TestRollbackUnwind(t *testing.T) {
+	store.DefaultStore = store.NewBoltDB()
+
+	if err := store.DefaultStore.Init(store.Endpoint("bolt://" + filepath.Join(t.TempDir(), "phenix.bdb"))); err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+
+	var (
+		rb    = newRollback("test")
+		cause = errors.New("launching experiment VMs: boom")
+		order []string
+	)
+
+	rb.register("experiment status", func() error {
+		order = append(order, "experiment status")
+		return nil
+	})
+
+	// Unwound before the steps registered after it.
+	rb.registerFirst("pre-start apps", func() error {
+		order = append(order, "pre-start apps")
+		return nil
+	})
+
+	rb.register("minimega script", func() error {
+		order = append(order, "minimega script")
+		return errors.New("namespace not found")
+	})
+
+	rb.register("VM launch", func() error {
+		order = append(order, "VM launch")
+		return nil
+	})
+
+	err := rb.unwind(cause)
+
+	if !errors.Is(err, cause) {
+		t.Logf("expected rollback error to wrap cause, got %v", err)
+		t.FailNow()
+	}
+
+	if strings.Join(order, ",") != "pre-start apps,VM launch,minimega script,experiment status" {
+		t.Logf("expected compensating actions to be executed in reverse order, got %v", order)
+		t.FailNow()
+	}
+
+	var rbErr RollbackError
+
+	if !errors.As(err, &rbErr) {
+		t.Log("expected RollbackError")
+		t.FailNow()
+	}
+
+	if len(rbErr.Steps) != 4 || !rbErr.Failed() {
+		t.Logf("unexpected rollback steps: %v", rbErr.Steps)
+		t.FailNow()
+	}
+
+	if !strings.Contains(err.Error(), "failed to roll back minimega script: namespace not found") {
+		t.Logf("unexpected rollback error: %v", err)
+		t.FailNow()
+	}
+
+	events, err := store.GetEventsBy(store.Event{Type: store.EventTypeHistory})
+	if err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+
+	if len(events) != 1 {
+		t.Logf("expected 1 history event, got %d", len(events))
+		t.FailNow()
+	}
+
+	if events[0].Metadata["experiment"] != "test" || events[0].Message != rbErr.Report() {
+		t.Logf("unexpected history event: %+v", events[0])
+		t.FailNow()
+	}
+
+	// compensating actions are only executed once
+	order = nil
+
+	if rb.unwind(cause); len(order) != 0 {
+		t.Logf("expected no compensating actions to be executed, got %v", order)
+		t.FailNow()
+	}
+}
@@ -0,0 +1,141 @@
+package experiment
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"phenix/api/config"
+	"phenix/store"
+)
+
+var ErrShareExists = errors.New("experiment already shared")
+
+// maxShareAttempts is the number of times updating an experiment's shares will
+// be attempted when it conflicts with a concurrent write to the experiment.
+const maxShareAttempts = 5
+
+// Ownership returns the owner of the experiment with the given name (empty if
+// the experiment has no owner) and who it has been shared with.
+func Ownership(name string) (string, []config.Share, error) {
+	exp, err := Get(name)
+	if err != nil {
+		return "", nil, fmt.Errorf("getting experiment %s: %w", name, err)
+	}
+
+	var (
+		owner  = exp.Metadata.Annotations[config.AnnotationOwner]
+		shares = config.ParseShares(exp.Metadata.Annotations[config.AnnotationShares])
+	)
+
+	return owner, shares, nil
+}
+
+// Share grants the given user or role access to the experiment with the given
+// name via the `shared` ownership policies of their role. Roles can be given by
+// either their role name or config name, but are always shared by role name.
+func Share(name string, share config.Share) (config.Share, error) {
+	switch share.Kind {
+	case config.ShareKindUser:
+		if _, err := config.Get("user/"+share.Name, false); err != nil {
+			return share, fmt.Errorf("getting user %s: %w", share.Name, err)
+		}
+	case config.ShareKindRole:
+		role, err := roleName(share.Name)
+		if err != nil {
+			return share, fmt.Errorf("getting role %s: %w", share.Name, err)
+		}
+
+		share.Name = role
+	default:
+		return share, fmt.Errorf("unknown share kind %s", share.Kind)
+	}
+
+	err := updateShares(name, func(shares []config.Share) ([]config.Share, error) {
+		for _, s := range shares {
+			if s == share {
+				return nil, fmt.Errorf("sharing experiment %s with %s: %w", name, share, ErrShareExists)
+			}
+		}
+
+		return append(shares, share), nil
+	})
+
+	return share, err
+}
+
+// Unshare revokes access to the experiment with the given name previously
+// granted to the given user or role. It's not an error if the experiment
+// wasn't shared with them.
+func Unshare(name string, share config.Share) error {
+	return updateShares(name, func(shares []config.Share) ([]config.Share, error) {
+		var updated []config.Share
+
+		for _, s := range shares {
+			if s != share {
+				updated = append(updated, s)
+			}
+		}
+
+		return updated, nil
+	})
+}
+
+// updateShares updates the shares annotation of the experiment with the given
+// name using the given function, which is passed the experiment's current
+// shares. The update is versioned, and is retried with the latest shares if
+// the experiment was modified concurrently.
+func updateShares(name string, update func([]config.Share) ([]config.Share, error)) error {
+	for attempt := 1; ; attempt++ {
+		c, _ := store.NewConfig("experiment/" + name)
+
+		if err := store.Get(c); err != nil {
+			return fmt.Errorf("getting experiment %s: %w", name, err)
+		}
+
+		shares, err := update(config.ParseShares(c.Metadata.Annotations[config.AnnotationShares]))
+		if err != nil {
+			return err
+		}
+
+		if c.Metadata.Annotations == nil {
+			c.Metadata.Annotations = make(map[string]string)
+		}
+
+		if len(shares) == 0 {
+			delete(c.Metadata.Annotations, config.AnnotationShares)
+		} else {
+			c.Metadata.Annotations[config.AnnotationShares] = config.FormatShares(shares)
+		}
+
+		err = store.Update(c)
+		if err == nil {
+			return nil
+		}
+
+		if !errors.Is(err, store.ErrConflict) || attempt == maxShareAttempts {
+			return fmt.Errorf("updating shares for experiment %s: %w", name, err)
+		}
+
+		time.Sleep(time.Duration(attempt*10) * time.Millisecond)
+	}
+}
+
+// roleName returns the role name of the role with the given role name or
+// config name.
+func roleName(name string) (string, error) {
+	roles, err := config.List("role")
+	if err != nil {
+		return "", fmt.Errorf("getting roles: %w", err)
+	}
+
+	for _, c := range roles {
+		role, _ := c.Spec["roleName"].(string)
+
+		if c.Metadata.Name == name || role == name {
+			return role, nil
+		}
+	}
+
+	return "", fmt.Errorf("role %s not found", name)
+}
@@ -0,0 +1,168 @@
+package experiment
+
+import (
+	"errors"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"phenix/api/config"
+	"phenix/store"
+)
+
+func TestShare(t *testing.T) {
+	store.DefaultStore = store.NewBoltDB()
+
+	if err := store.DefaultStore.Init(store.Endpoint("bolt://" + filepath.Join(t.TempDir(), "phenix.bdb"))); err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+
+	configs := []*store.Config{
+		{
+			Version:  "phenix.sandia.gov/v1",
+			Kind:     "Experiment",
+			Metadata: store.ConfigMetadata{Name: "foo", Annotations: map[string]string{config.AnnotationOwner: "alice"}},
+			Spec:     map[string]any{"experimentName": "foo"},
+		},
+		{
+			Version:  "phenix.sandia.gov/v1",
+			Kind:     "User",
+			Metadata: store.ConfigMetadata{Name: "bob"},
+			Spec:     map[string]any{"username": "bob"},
+		},
+		{
+			Version:  "phenix.sandia.gov/v1",
+			Kind:     "Role",
+			Metadata: store.ConfigMetadata{Name: "experiment-viewer"},
+			Spec:     map[string]any{"roleName": "Experiment Viewer"},
+		},
+	}
+
+	for _, c := range configs {
+		if err := store.Create(c); err != nil {
+			t.Log(err)
+			t.FailNow()
+		}
+	}
+
+	if _, err := Share("foo", config.Share{Kind: config.ShareKindUser, Name: "bob"}); err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+
+	// Roles are always shared by role name.
+	share, err := Share("foo", config.Share{Kind: config.ShareKindRole, Name: "experiment-viewer"})
+	if err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+
+	if share.Name != "Experiment Viewer" {
+		t.Logf("expected role to be shared by role name, got %s", share.Name)
+		t.FailNow()
+	}
+
+	if _, err := Share("foo", config.Share{Kind: config.ShareKindUser, Name: "bob"}); !errors.Is(err, ErrShareExists) {
+		t.Logf("expected ErrShareExists sharing twice, got %v", err)
+		t.FailNow()
+	}
+
+	if _, err := Share("foo", config.Share{Kind: config.ShareKindUser, Name: "carol"}); err == nil {
+		t.Log("expected error sharing with unknown user")
+		t.FailNow()
+	}
+
+	owner, shares, err := Ownership("foo")
+	if err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+
+	if owner != "alice" || len(shares) != 2 {
+		t.Logf("unexpected ownership: owner %s, shares %v", owner, shares)
+		t.FailNow()
+	}
+
+	if err := Unshare("foo", config.Share{Kind: config.ShareKindUser, Name: "bob"}); err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+
+	if _, shares, _ := Ownership("foo"); len(shares) != 1 || shares[0].Kind != config.ShareKindRole {
+		t.Logf("expected only role share to remain, got %v", shares)
+		t.FailNow()
+	}
+}
+
+func TestShareConcurrent(t *testing.T) {
+	store.DefaultStore = store.NewBoltDB()
+
+	if err := store.DefaultStore.Init(store.Endpoint("bolt://" + filepath.Join(t.TempDir(), "phenix.bdb"))); err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+
+	users := []string{"alice", "bob", "carol", "dave"}
+
+	configs := []*store.Config{
+		{
+			Version:  "phenix.sandia.gov/v1",
+			Kind:     "Experiment",
+			Metadata: store.ConfigMetadata{Name: "foo"},
+			Spec:     map[string]any{"experimentName": "foo"},
+		},
+	}
+
+	for _, u := range users {
+		configs = append(configs, &store.Config{
+			Version:  "phenix.sandia.gov/v1",
+			Kind:     "User",
+			Metadata: store.ConfigMetadata{Name: u},
+			Spec:     map[string]any{"username": u},
+		})
+	}
+
+	for _, c := range configs {
+		if err := store.Create(c); err != nil {
+			t.Log(err)
+			t.FailNow()
+		}
+	}
+
+	var (
+		wg   sync.WaitGroup
+		errs = make(chan error, len(users))
+	)
+
+	for _, u := range users {
+		wg.Add(1)
+
+		go func(u string) {
+			defer wg.Done()
+
+			if _, err := Share("foo", config.Share{Kind: config.ShareKindUser, Name: u}); err != nil {
+				errs <- err
+			}
+		}(u)
+	}
+
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		t.Log(err)
+		t.FailNow()
+	}
+
+	_, shares, err := Ownership("foo")
+	if err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+
+	if len(shares) != len(users) {
+		t.Logf("expected %d shares after sharing concurrently, got %v", len(users), shares)
+		t.FailNow()
+	}
+}
@@ -0,0 +1,8 @@
+// Implementation of the phenix quota API.
+//
+// Quotas limit the cluster resources (running experiments, VMs, vCPUs, memory,
+// and packet captures) a user can consume. They're attached to roles and
+// users, and a user's usage is computed from the running experiments they
+// started. Experiments started without a user (e.g., via the CLI) don't count
+// against anyone's quota.
+package quota
@@ -0,0 +1,282 @@
+package quota
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"phenix/store"
+	"phenix/types"
+	ifaces "phenix/types/interfaces"
+	v1 "phenix/types/version/v1"
+	"phenix/util/mm"
+
+	"github.com/mitchellh/mapstructure"
+	"golang.org/x/exp/slices"
+)
+
+// AnnotationUser is the experiment annotation used to record the user that
+// started the experiment, which is who the experiment's resources count
+// against.
+const AnnotationUser = "phenix.quota/user"
+
+var ErrQuotaExceeded = errors.New("quota exceeded")
+
+var (
+	locksMu sync.Mutex
+	locks   = make(map[string]*sync.Mutex)
+)
+
+// Lock locks the quota of the given user, returning a function that unlocks
+// it. The lock should be held from checking the user's quota until the
+// requested resources have been consumed (and are therefore included in the
+// user's usage) so concurrent requests can't all pass the check. Users without
+// a name aren't limited, so nothing is locked for them.
+func Lock(username string) func() {
+	if username == "" {
+		return func() {}
+	}
+
+	locksMu.Lock()
+
+	lock, ok := locks[username]
+	if !ok {
+		lock = new(sync.Mutex)
+		locks[username] = lock
+	}
+
+	locksMu.Unlock()
+
+	lock.Lock()
+
+	return lock.Unlock
+}
+
+// Usage is the amount of cluster resources consumed (or requested).
+type Usage struct {
+	RunningExperiments int `json:"runningExperiments"`
+	VCPUs              int `json:"vcpus"`
+	Memory             int `json:"memory"` // in MB
+	VMs                int `json:"vms"`
+	Captures           int `json:"captures"`
+}
+
+// Add returns the sum of this and the given usage.
+func (this Usage) Add(other Usage) Usage {
+	return Usage{
+		RunningExperiments: this.RunningExperiments + other.RunningExperiments,
+		VCPUs:              this.VCPUs + other.VCPUs,
+		Memory:             this.Memory + other.Memory,
+		VMs:                this.VMs + other.VMs,
+		Captures:           this.Captures + other.Captures,
+	}
+}
+
+// ExperimentUsage returns the cluster resources the given experiment consumes
+// while running. External nodes aren't deployed by phenix, so they don't count.
+func ExperimentUsage(exp *types.Experiment) Usage {
+	usage := Usage{RunningExperiments: 1}
+
+	if exp.Spec.Topology() == nil {
+		return usage
+	}
+
+	for _, node := range exp.Spec.Topology().Nodes() {
+		if node.External() {
+			continue
+		}
+
+		usage.VMs++
+		usage.VCPUs += node.Hardware().VCPU()
+		usage.Memory += node.Hardware().Memory()
+	}
+
+	return usage
+}
+
+// runningUsage returns the cluster resources the given running experiment
+// consumes. The resources of deployed VMs are taken from minimega instead of
+// the experiment spec, since they differ from the spec if a VM was redeployed
+// with different vCPUs or memory.
+func runningUsage(exp *types.Experiment) Usage {
+	usage := ExperimentUsage(exp)
+
+	for _, vm := range mm.GetVMInfo(mm.NS(exp.Metadata.Name)) {
+		var node ifaces.NodeSpec
+
+		if exp.Spec.Topology() != nil {
+			node = exp.Spec.Topology().FindNodeByName(vm.Name)
+		}
+
+		if node == nil || node.External() {
+			// Not accounted for by the experiment spec.
+			usage.VMs++
+			usage.VCPUs += vm.CPUs
+			usage.Memory += vm.RAM
+
+			continue
+		}
+
+		usage.VCPUs += vm.CPUs - node.Hardware().VCPU()
+		usage.Memory += vm.RAM - node.Hardware().Memory()
+	}
+
+	return usage
+}
+
+// Get returns the current usage of the given user, computed from the running
+// experiments the user started. Experiments with the given names are excluded.
+func Get(username string, exclude ...string) (Usage, error) {
+	var usage Usage
+
+	configs, err := store.List("Experiment")
+	if err != nil {
+		return usage, fmt.Errorf("getting experiments from store: %w", err)
+	}
+
+	for _, c := range configs {
+		if c.Metadata.Annotations[AnnotationUser] != username {
+			continue
+		}
+
+		if slices.Contains(exclude, c.Metadata.Name) {
+			continue
+		}
+
+		exp, err := types.DecodeExperimentFromConfig(c)
+		if err != nil {
+			return usage, fmt.Errorf("decoding experiment %s: %w", c.Metadata.Name, err)
+		}
+
+		// Dry-run experiments don't consume any cluster resources.
+		if !exp.Running() || strings.HasSuffix(exp.Status.StartTime(), "-DRYRUN") {
+			continue
+		}
+
+		usage = usage.Add(runningUsage(exp))
+		usage.Captures += len(mm.GetExperimentCaptures(mm.NS(exp.Metadata.Name)))
+	}
+
+	return usage, nil
+}
+
+// Quota returns the effective quota for the given user. A nil quota (and nil
+// error) is returned if the user isn't limited, including when the user
+// doesn't exist (e.g., when authentication is disabled).
+func Quota(username string) (*v1.QuotaSpec, error) {
+	if username == "" {
+		return nil, nil
+	}
+
+	c, _ := store.NewConfig("user/" + username)
+
+	if err := store.Get(c); err != nil {
+		if errors.Is(err, store.ErrNotExist) {
+			return nil, nil
+		}
+
+		return nil, fmt.Errorf("getting user %s: %w", username, err)
+	}
+
+	var user v1.UserSpec
+
+	if err := mapstructure.Decode(c.Spec, &user); err != nil {
+		return nil, fmt.Errorf("decoding user %s: %w", username, err)
+	}
+
+	return Effective(&user), nil
+}
+
+// Effective returns the given user's effective quota, which is the quota of the
+// user's role with any limits set on the user itself taking precedence. A nil
+// quota means the user is not limited.
+func Effective(user *v1.UserSpec) *v1.QuotaSpec {
+	var role *v1.QuotaSpec
+
+	if user.Role != nil {
+		role = user.Role.Quota
+	}
+
+	if role == nil && user.Quota == nil {
+		return nil
+	}
+
+	var quota v1.QuotaSpec
+
+	if role != nil {
+		quota = *role
+	}
+
+	if user.Quota != nil {
+		override := func(dst *int, src int) {
+			if src != 0 {
+				*dst = src
+			}
+		}
+
+		override(&quota.MaxRunningExperiments, user.Quota.MaxRunningExperiments)
+		override(&quota.MaxVCPUs, user.Quota.MaxVCPUs)
+		override(&quota.MaxMemory, user.Quota.MaxMemory)
+		override(&quota.MaxVMs, user.Quota.MaxVMs)
+		override(&quota.MaxCaptures, user.Quota.MaxCaptures)
+	}
+
+	return &quota
+}
+
+// Check returns an error wrapping ErrQuotaExceeded if consuming the requested
+// resources, in addition to the resources the given user is already
+// consuming, would exceed the user's quota. Only the resources actually
+// requested are checked, so a user already over one limit (e.g., after their
+// quota was lowered) can still consume resources not subject to it. The usage
+// of experiments with the given names is excluded from the user's current
+// usage, which is useful when the requested resources replace them.
+func Check(username string, requested Usage, exclude ...string) error {
+	quota, err := Quota(username)
+	if err != nil {
+		return fmt.Errorf("getting quota: %w", err)
+	}
+
+	if quota == nil {
+		return nil
+	}
+
+	current, err := Get(username, exclude...)
+	if err != nil {
+		return fmt.Errorf("getting current usage: %w", err)
+	}
+
+	total := current.Add(requested)
+
+	limits := []struct {
+		name      string
+		requested int
+		total     int
+		max       int
+	}{
+		{"running experiments", requested.RunningExperiments, total.RunningExperiments, quota.MaxRunningExperiments},
+		{"vCPUs", requested.VCPUs, total.VCPUs, quota.MaxVCPUs},
+		{"memory (MB)", requested.Memory, total.Memory, quota.MaxMemory},
+		{"VMs", requested.VMs, total.VMs, quota.MaxVMs},
+		{"captures", requested.Captures, total.Captures, quota.MaxCaptures},
+	}
+
+	var exceeded []string
+
+	for _, l := range limits {
+		if l.max == 0 || l.requested <= 0 {
+			continue
+		}
+
+		if l.total > l.max {
+			exceeded = append(exceeded, fmt.Sprintf("%s (%d of %d)", l.name, l.total, l.max))
+		}
+	}
+
+	if len(exceeded) > 0 {
+		return fmt.Errorf("%w for user %s: %s", ErrQuotaExceeded, username, strings.Join(exceeded, ", "))
+	}
+
+	return nil
+}
@@ -0,0 +1,198 @@
+package quota
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"phenix/store"
+	"phenix/util/mm"
+	"phenix/util/mm/mmfake"
+)
+
+func TestCheck(t *testing.T) {
+	base := t.TempDir()
+
+	store.DefaultStore = store.NewBoltDB()
+
+	if err := store.DefaultStore.Init(store.Endpoint("bolt://" + filepath.Join(base, "phenix.bdb"))); err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+
+	cluster := mmfake.New(mmfake.Headnode("compute0"))
+	mm.DefaultMM = cluster
+
+	script := filepath.Join(base, "foo.mm")
+
+	if err := os.WriteFile(script, []byte("namespace foo\nvm config vcpus 2\nvm config memory 2048\nvm launch kvm host-00\n"), 0600); err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+
+	if err := cluster.ReadScriptFromFile(script); err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+
+	if err := cluster.StartVMCapture(mm.NS("foo"), mm.VMName("host-00"), mm.CaptureInterface(0), mm.CaptureFile("foo/files/host-00.pcap")); err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+
+	// The user's own limits override the role's limits.
+	user := &store.Config{
+		Version:  "phenix.sandia.gov/v1",
+		Kind:     "User",
+		Metadata: store.ConfigMetadata{Name: "foo@bar.com"},
+		Spec: map[string]any{
+			"username": "foo@bar.com",
+			"rbac": map[string]any{
+				"roleName": "Experiment User",
+				"quota":    map[string]any{"maxRunningExperiments": 2, "maxVCPUs": 4, "maxMemory": 4096, "maxCaptures": 1},
+			},
+			"quota": map[string]any{"maxVCPUs": 8},
+		},
+	}
+
+	if err := store.Create(user); err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+
+	experiments := []struct {
+		name, user, start string
+	}{
+		{"foo", "foo@bar.com", "2024-01-01T00:00:00Z"},
+		{"bar", "foo@bar.com", ""},                            // not running
+		{"baz", "foo@bar.com", "2024-01-01T00:00:00Z-DRYRUN"}, // dry run
+		{"qux", "admin", "2024-01-01T00:00:00Z"},              // someone else's
+	}
+
+	for _, e := range experiments {
+		c := &store.Config{
+			Version: "phenix.sandia.gov/v1",
+			Kind:    "Experiment",
+			Metadata: store.ConfigMetadata{
+				Name:        e.name,
+				Annotations: map[string]string{AnnotationUser: e.user},
+			},
+			Spec: map[string]any{
+				"experimentName": e.name,
+				"baseDir":        filepath.Join(base, "experiments", e.name),
+				"topology": map[string]any{
+					"nodes": []any{
+						map[string]any{"general": map[string]any{"hostname": "host-00"}, "hardware": map[string]any{"vcpus": 2, "memory": 2048}},
+						map[string]any{"general": map[string]any{"hostname": "ext-00"}, "hardware": map[string]any{"vcpus": 8, "memory": 8192}, "external": true},
+					},
+				},
+			},
+			Status: map[string]any{"startTime": e.start},
+		}
+
+		if err := store.Create(c); err != nil {
+			t.Log(err)
+			t.FailNow()
+		}
+	}
+
+	usage, err := Get("foo@bar.com")
+	if err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+
+	expected := Usage{RunningExperiments: 1, VCPUs: 2, Memory: 2048, VMs: 1, Captures: 1}
+
+	if usage != expected {
+		t.Logf("expected usage %+v, got %+v", expected, usage)
+		t.FailNow()
+	}
+
+	checks := []struct {
+		requested Usage
+		exceeded  bool
+	}{
+		{Usage{RunningExperiments: 1, VCPUs: 2, Memory: 2048, VMs: 1}, false},
+		{Usage{RunningExperiments: 1, VCPUs: 2, Memory: 4096, VMs: 1}, true},
+		{Usage{VCPUs: 6}, false}, // user's limit overrides the role's limit of 4
+		{Usage{VCPUs: 7}, true},
+		{Usage{Captures: 1}, true},
+		{Usage{VMs: 100}, false}, // VMs aren't limited
+	}
+
+	for _, c := range checks {
+		err := Check("foo@bar.com", c.requested)
+
+		if c.exceeded != errors.Is(err, ErrQuotaExceeded) {
+			t.Logf("unexpected result checking %+v: %v", c.requested, err)
+			t.FailNow()
+		}
+	}
+
+	// Excluded experiments (e.g., one being updated) aren't counted twice.
+	if err := Check("foo@bar.com", Usage{VCPUs: 7}, "foo"); err != nil {
+		t.Logf("expected excluded experiment not to count against quota, got %v", err)
+		t.FailNow()
+	}
+
+	// Usage reflects the resources of deployed VMs, not the experiment spec.
+	if err := cluster.RedeployVM(mm.NS("foo"), mm.VMName("host-00"), mm.CPU(4), mm.Mem(4096)); err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+
+	usage, err = Get("foo@bar.com")
+	if err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+
+	expected = Usage{RunningExperiments: 1, VCPUs: 4, Memory: 4096, VMs: 1, Captures: 1}
+
+	if usage != expected {
+		t.Logf("expected usage %+v after redeploy, got %+v", expected, usage)
+		t.FailNow()
+	}
+
+	// Users without a quota, or that don't exist, aren't limited.
+	for _, u := range []string{"", "admin"} {
+		if err := Check(u, Usage{RunningExperiments: 100}); err != nil {
+			t.Logf("expected no quota for user %q, got %v", u, err)
+			t.FailNow()
+		}
+	}
+}
+
+func TestLock(t *testing.T) {
+	var (
+		unlock = Lock("foo@bar.com")
+		locked = make(chan struct{})
+	)
+
+	go func() {
+		defer Lock("foo@bar.com")()
+		close(locked)
+	}()
+
+	// Other users' quotas aren't locked.
+	Lock("admin")()
+
+	select {
+	case <-locked:
+		t.Log("expected lock to be held")
+		t.FailNow()
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	unlock()
+
+	select {
+	case <-locked:
+	case <-time.After(time.Second):
+		t.Log("expected lock to be released")
+		t.FailNow()
+	}
+}
@@ -15,6 +15,7 @@ import (
 
 	"phenix/api/scorch/scorchexe"
 	"phenix/api/scorch/scorchmd"
+	"phenix/api/scorch/scorchresult"
 	"phenix/app"
 	"phenix/store"
 	"phenix/types"
@@ -132,16 +133,23 @@ func (this *Scorch) Running(ctx context.Context, exp *types.Experiment) error {
 	}
 
 	var (
-		errors error
-		run    = this.md.Runs[runID]
-		opts   = []Option{Experiment(*exp), RunID(runID), StartTime(start.Format(time.RubyDate))}
+		errors  error
+		run     = this.md.Runs[runID]
+		results = scorchresult.NewRun(exp.Metadata.Name, runID, this.md.RunName(runID), start)
+		opts    = []Option{Experiment(*exp), RunID(runID), StartTime(start.Format(time.RubyDate)), Record(results)}
 	)
 
-	for i := 0; i < run.Count; i++ {
-		opts := append(opts, LoopCount(i))
+	for i, params := range run.Iterations() {
+		opts := append(opts, LoopCount(i), Params(params))
 
 		if err := executor(ctx, this.md.ComponentSpecs(), run, opts...); err != nil {
-			errors = multierror.Append(errors, fmt.Errorf("executing Scorch for run %d, count %d: %w", runID, i, err))
+			iteration := fmt.Sprintf("count %d", i)
+
+			if len(params) > 0 {
+				iteration += fmt.Sprintf(" (%s)", params)
+			}
+
+			errors = multierror.Append(errors, fmt.Errorf("executing Scorch for run %d, %s: %w", runID, iteration, err))
 			break
 		}
 	}
@@ -164,6 +172,25 @@ func (this *Scorch) Running(ctx context.Context, exp *types.Experiment) error {
 		errors = multierror.Append(errors, err)
 	}
 
+	results.End = time.Now().UTC()
+	results.Status = "success"
+
+	if errors != nil {
+		results.Status = "failure"
+	}
+
+	// Include the results in the archive of data generated for the run, as well
+	// as persisting them for later querying and comparison.
+	if err := os.MkdirAll(runDir, 0755); err != nil {
+		errors = multierror.Append(errors, fmt.Errorf("creating %s directory for scorch run %d: %w", runDir, runID, err))
+	} else if err := results.Write(filepath.Join(runDir, "results.json")); err != nil {
+		errors = multierror.Append(errors, err)
+	}
+
+	if err := scorchresult.Save(exp.FilesDir(), results); err != nil {
+		errors = multierror.Append(errors, fmt.Errorf("saving results for run %d: %w", runID, err))
+	}
+
 	if _, err := os.Stat(runDir); err == nil {
 		archive := filepath.Join(exp.FilesDir(), fmt.Sprintf("scorch-run-%d_%s.tgz", runID, start.Format("2006-01-02T15-04-05Z0700")))
 
@@ -377,12 +404,22 @@ func (this Scorch) recordInfo(runID int, runDir string, md store.ConfigMetadata,
 	return nil
 }
 
+// paramsSuffix returns the given matrix parameters formatted for inclusion in
+// log messages, or an empty string if there are no parameters.
+func paramsSuffix(params scorchmd.Params) string {
+	if len(params) == 0 {
+		return ""
+	}
+
+	return " - PARAMS: " + params.String()
+}
+
 func executor(ctx context.Context, components scorchmd.ComponentSpecMap, exe *scorchmd.Loop, opts ...Option) error {
 	options := NewOptions(opts...)
 
 	var (
 		exp        = options.Exp.Spec.ExperimentName()
-		loopPrefix = fmt.Sprintf("[RUN: %d - LOOP: %d - COUNT: %d]", options.Run, options.Loop, options.Count)
+		loopPrefix = fmt.Sprintf("[RUN: %d - LOOP: %d - COUNT: %d%s]", options.Run, options.Loop, options.Count, paramsSuffix(options.Params))
 	)
 
 	logger := plog.LoggerFromContext(ctx)
@@ -392,188 +429,214 @@ func executor(ctx context.Context, components scorchmd.ComponentSpecMap, exe *sc
 	}
 
 	update := scorch.ComponentUpdate{
-		Exp:   exp,
-		Run:   options.Run,
-		Loop:  options.Loop,
-		Count: options.Count,
+		Exp:    exp,
+		Run:    options.Run,
+		Loop:   options.Loop,
+		Count:  options.Count,
+		Params: options.Params.String(),
 	}
 
 	logger.Info("starting scorch", "run", loopPrefix)
 
-	configure := func() error {
-		update.Stage = string(ACTIONCONFIG)
+	var (
+		results = make(componentResults)
 
-		if len(exe.Configure) == 0 {
-			update.CmpType = ""
-			update.CmpName = ""
-			update.Status = "success"
-			scorch.UpdatePipeline(update)
-			return nil
-		}
+		// tracks failure handlers currently running so a handler that fails
+		// doesn't end up running itself again
+		handling = make(map[string]bool)
 
-		logger.Info("running scorch configure stage")
+		// tracks started components that implement Reverter and haven't been
+		// stopped or cleaned up yet, in the order they were started
+		reverting []string
 
-		for _, name := range exe.Configure {
-			typ := components[name].Type
+		execute func(context.Context, Action, string) error
+	)
 
-			update.CmpType = typ
-			update.CmpName = name
-			update.Status = "start"
+	// record adds the result of the given component to the results of the run
+	// being executed.
+	record := func(stage Action, name string, result *ComponentResult) {
+		if options.Record == nil {
+			return
+		}
 
-			scorch.UpdateComponent(update)
+		cmp := scorchresult.Component{
+			Name:       name,
+			Type:       components[name].Type,
+			Stage:      string(stage),
+			Loop:       options.Loop,
+			Count:      options.Count,
+			Parents:    options.Parents,
+			Params:     options.Params.String(),
+			Status:     result.Status,
+			ExitCode:   result.ExitCode,
+			Error:      result.Error,
+			Start:      result.start,
+			Metrics:    result.Metrics,
+			Assertions: result.Assertions,
+		}
 
-			options := append(opts, Name(name), Type(typ), Stage(ACTIONCONFIG), Metadata(components[name].Metadata))
+		if result.start.IsZero() {
+			cmp.Start = time.Now().UTC()
+		} else {
+			cmp.Duration = time.Since(result.start).Seconds()
+		}
 
-			status := "running"
+		options.Record.Add(cmp)
+	}
 
-			if components[name].Background {
-				options = append(options, Background())
-				status = "background"
-			}
+	// fail records the failure of the given component and runs its failure
+	// handlers, returning an error unless the component is configured to
+	// continue on error (or a failure handler fails).
+	fail := func(ctx context.Context, stage Action, name string, result *ComponentResult, err error) error {
+		spec := components[name]
 
-			update.Status = status
-			scorch.UpdateComponent(update)
-			scorch.UpdatePipeline(update)
+		result.Status = "failure"
+		result.Error = err.Error()
 
-			logger.Debug("running scorch configure stage component", "component", name)
+		if result.ExitCode == 0 {
+			result.ExitCode = 1
+		}
 
-			if err := ExecuteComponent(ctx, options...); err != nil {
-				update.Status = "failure"
-				scorch.UpdateComponent(update)
-				scorch.UpdatePipeline(update)
+		results[name] = result
+		record(stage, name, result)
 
-				logger.Error("[✗] failed scorch configure stage component", "component", name, "err", err)
+		update.Stage = string(stage)
+		update.CmpType = spec.Type
+		update.CmpName = name
+		update.Status = "failure"
 
-				return fmt.Errorf("%s configuring component %s for experiment %s: %w", loopPrefix, name, exp, err)
-			}
+		scorch.UpdateComponent(update)
+		scorch.UpdatePipeline(update)
 
-			if !components[name].Background {
-				update.Status = "success"
-				scorch.UpdateComponent(update)
-				scorch.UpdatePipeline(update)
+		logger.Error(fmt.Sprintf("[✗] failed scorch %s stage component", stage), "component", name, "err", err)
 
-				logger.Debug("[✓] completed scorch configure stage component", "component", name)
-			}
+		var errors error
+
+		if spec.ContinueOnError {
+			logger.Warn(fmt.Sprintf("continuing scorch %s stage after component failure", stage), "component", name)
+		} else {
+			errors = multierror.Append(errors, fmt.Errorf("%s %s component %s for experiment %s: %w", loopPrefix, stageVerbs[stage], name, exp, err))
 		}
 
-		return nil
-	}
+		for _, handler := range spec.OnFailure {
+			if handling[handler] {
+				continue
+			}
 
-	start := func() error {
-		update.Stage = string(ACTIONSTART)
+			logger.Info(fmt.Sprintf("running scorch %s stage failure handler", stage), "component", name, "handler", handler)
 
-		if len(exe.Start) == 0 {
-			update.CmpType = ""
-			update.CmpName = ""
-			update.Status = "success"
-			scorch.UpdatePipeline(update)
-			return nil
-		}
+			handling[handler] = true
 
-		logger.Info("running scorch start stage")
-
-		for _, name := range exe.Start {
-			typ := components[name].Type
+			if err := execute(ctx, stage, handler); err != nil {
+				errors = multierror.Append(errors, err)
+			}
 
-			update.CmpType = typ
-			update.CmpName = name
-			update.Status = "start"
+			delete(handling, handler)
+		}
 
-			scorch.UpdateComponent(update)
+		return errors
+	}
 
-			options := append(opts, Name(name), Type(typ), Stage(ACTIONSTART), Metadata(components[name].Metadata))
+	execute = func(ctx context.Context, stage Action, name string) error {
+		spec := components[name]
 
-			status := "running"
+		update.Stage = string(stage)
+		update.CmpType = spec.Type
+		update.CmpName = name
 
-			if components[name].Background {
-				options = append(options, Background())
-				status = "background"
+		if spec.When != "" {
+			run, err := results.when(spec.When, options.Params)
+			if err != nil {
+				return fail(ctx, stage, name, new(ComponentResult), fmt.Errorf("evaluating when condition: %w", err))
 			}
 
-			update.Status = status
-			scorch.UpdateComponent(update)
-			scorch.UpdatePipeline(update)
-
-			logger.Debug("running scorch start stage component", "component", name)
+			if !run {
+				results[name] = &ComponentResult{Status: "skipped"}
+				record(stage, name, results[name])
 
-			if err := ExecuteComponent(ctx, options...); err != nil {
-				update.Status = "failure"
+				update.Status = "skipped"
 				scorch.UpdateComponent(update)
 				scorch.UpdatePipeline(update)
 
-				logger.Error("[✗] failed scorch start stage component", "component", name, "err", err)
+				logger.Info(fmt.Sprintf("skipping scorch %s stage component (when condition not met)", stage), "component", name)
 
-				return fmt.Errorf("%s starting component %s for experiment %s: %w", loopPrefix, name, exp, err)
+				return nil
 			}
+		}
 
-			if !components[name].Background {
-				update.Status = "success"
-				scorch.UpdateComponent(update)
-				scorch.UpdatePipeline(update)
+		update.Status = "start"
+		scorch.UpdateComponent(update)
 
-				logger.Debug("[✓] completed scorch start stage component", "component", name)
-			}
+		var (
+			result     = &ComponentResult{start: time.Now().UTC()}
+			options    = append(opts, Name(name), Type(spec.Type), Stage(stage), Metadata(spec.Metadata))
+			status     = "running"
+			background = spec.Background && (stage == ACTIONCONFIG || stage == ACTIONSTART)
+		)
+
+		// Components running in the background outlive the stage they were
+		// started in, so their results can't be recorded.
+		if background {
+			options = append(options, Background())
+			status = "background"
+		} else {
+			options = append(options, Result(result))
 		}
 
-		return nil
-	}
+		update.Status = status
+		scorch.UpdateComponent(update)
+		scorch.UpdatePipeline(update)
 
-	stop := func() error {
-		update.Stage = string(ACTIONSTOP)
+		logger.Debug(fmt.Sprintf("running scorch %s stage component", stage), "component", name)
 
-		if len(exe.Stop) == 0 {
-			update.CmpType = ""
-			update.CmpName = ""
-			update.Status = "success"
-			scorch.UpdatePipeline(update)
-			return nil
+		if err := ExecuteComponent(ctx, options...); err != nil {
+			return fail(ctx, stage, name, result, err)
 		}
 
-		var errors error
-
-		logger.Info("running scorch stop stage")
+		if err := result.failedAssertions(); err != nil {
+			return fail(ctx, stage, name, result, err)
+		}
 
-		for _, name := range exe.Stop {
-			typ := components[name].Type
+		if _, ok := GetComponent(spec.Type).(Reverter); ok {
+			for i, n := range reverting {
+				if n == name {
+					reverting = append(reverting[:i], reverting[i+1:]...)
+					break
+				}
+			}
 
-			update.CmpType = typ
-			update.CmpName = name
-			update.Status = "start"
+			if stage == ACTIONSTART {
+				reverting = append(reverting, name)
+			}
+		}
 
-			scorch.UpdateComponent(update)
+		result.Status = "success"
+		results[name] = result
+		record(stage, name, result)
 
-			options := append(opts, Name(name), Type(typ), Stage(ACTIONSTOP), Metadata(components[name].Metadata))
+		if !background {
+			// failure handlers run from `fail` update the same shared update
+			update.Stage = string(stage)
+			update.CmpType = spec.Type
+			update.CmpName = name
+			update.Status = "success"
 
-			update.Status = "running"
 			scorch.UpdateComponent(update)
 			scorch.UpdatePipeline(update)
 
-			logger.Debug("running stop stage component", "component", name)
-
-			if err := ExecuteComponent(ctx, options...); err != nil {
-				update.Status = "failure"
-				scorch.UpdateComponent(update)
-				scorch.UpdatePipeline(update)
-
-				logger.Error("[✗] failed scorch stop stage component", "component", name, "err", err)
-
-				errors = multierror.Append(errors, fmt.Errorf("%s stopping component %s for experiment %s: %w", loopPrefix, name, exp, err))
-			} else {
-				update.Status = "success"
-				scorch.UpdateComponent(update)
-				scorch.UpdatePipeline(update)
-
-				logger.Debug("[✓] completed scorch stop stage component", "component", name)
-			}
+			logger.Debug(fmt.Sprintf("[✓] completed scorch %s stage component", stage), "component", name)
 		}
 
-		return errors
+		return nil
 	}
 
-	cleanup := func() error {
-		update.Stage = string(ACTIONCLEANUP)
+	// runStage runs the given components for the given stage. The configure and
+	// start stages stop at the first failed component, while all components are
+	// run for the other stages.
+	runStage := func(ctx context.Context, stage Action, names []string) error {
+		update.Stage = string(stage)
 
-		if len(exe.Cleanup) == 0 {
+		if len(names) == 0 {
 			update.CmpType = ""
 			update.CmpName = ""
 			update.Status = "success"
@@ -581,108 +644,92 @@ func executor(ctx context.Context, components scorchmd.ComponentSpecMap, exe *sc
 			return nil
 		}
 
-		var errors error
-
-		logger.Info("running scorch cleanup stage")
-
-		for _, name := range exe.Cleanup {
-			typ := components[name].Type
-
-			update.CmpType = typ
-			update.CmpName = name
-			update.Status = "start"
+		logger.Info(fmt.Sprintf("running scorch %s stage", stage))
 
-			scorch.UpdateComponent(update)
-
-			options := append(opts, Name(name), Type(typ), Stage(ACTIONCLEANUP), Metadata(components[name].Metadata))
-
-			update.Status = "running"
-			scorch.UpdateComponent(update)
-			scorch.UpdatePipeline(update)
-
-			logger.Debug("running cleanup stage component", "component", name)
-
-			err := ExecuteComponent(ctx, options...)
-			if err != nil {
-				update.Status = "failure"
-				scorch.UpdateComponent(update)
-				scorch.UpdatePipeline(update)
-
-				logger.Error("[✗] failed scorch cleanup stage component", "component", name, "err", err)
+		var errors error
 
-				errors = multierror.Append(errors, fmt.Errorf("%s cleaning up component %s for experiment %s: %w", loopPrefix, name, exp, err))
-			} else {
-				update.Status = "success"
-				scorch.UpdateComponent(update)
-				scorch.UpdatePipeline(update)
+		for _, name := range names {
+			if err := execute(ctx, stage, name); err != nil {
+				errors = multierror.Append(errors, err)
 
-				logger.Debug("[✓] completed scorch cleanup stage component", "component", name)
+				if stage == ACTIONCONFIG || stage == ACTIONSTART {
+					return errors
+				}
 			}
 		}
 
 		return errors
 	}
 
-	if err := configure(); err != nil {
-		errors := multierror.Append(nil, err)
+	var errors error
 
-		if err := cleanup(); err != nil {
+	if err := runStage(ctx, ACTIONCONFIG, exe.Configure); err != nil {
+		errors = multierror.Append(errors, err)
+	} else {
+		if err := runStage(ctx, ACTIONSTART, exe.Start); err != nil {
 			errors = multierror.Append(errors, err)
-		}
+		} else if exe.Loop != nil {
+			update := scorch.ComponentUpdate{
+				Exp:    exp,
+				Run:    options.Run,
+				Loop:   options.Loop,
+				Params: options.Params.String(),
+				Stage:  string(ACTIONLOOP),
+				Status: "running",
+			}
 
-		return errors
-	}
+			scorch.UpdatePipeline(update)
 
-	if err := start(); err != nil {
-		errors := multierror.Append(nil, err)
+			update.Status = "success"
 
-		if err := stop(); err != nil {
-			errors = multierror.Append(errors, err)
+			// Parameters of nested loops are added to (and override) the parameters
+			// of the current loop.
+			for i, params := range exe.Loop.Iterations() {
+				parents := append(append([]int{}, options.Parents...), options.Count)
+				opts := append(opts, CurrentLoop(options.Loop+1), LoopCount(i), ParentCounts(parents), Params(options.Params.Merge(params)))
+
+				if err := executor(ctx, components, exe.Loop, opts...); err != nil {
+					errors = multierror.Append(errors, err)
+					update.Status = "failure"
+					break
+				}
+			}
+
+			scorch.UpdatePipeline(update)
 		}
 
-		if err := cleanup(); err != nil {
+		if err := runStage(ctx, ACTIONSTOP, exe.Stop); err != nil {
 			errors = multierror.Append(errors, err)
 		}
-
-		return errors
 	}
 
-	var errors error
-
-	if exe.Loop != nil {
-		update := scorch.ComponentUpdate{
-			Exp:   exp,
-			Loop:  options.Loop,
-			Stage: string(ACTIONLOOP),
-		}
-
-		update.Status = "running"
-		scorch.UpdatePipeline(update)
+	if err := runStage(ctx, ACTIONCLEANUP, exe.Cleanup); err != nil {
+		errors = multierror.Append(errors, err)
+	}
 
-		for i := 0; i < exe.Loop.Count; i++ {
-			opts := append(opts, CurrentLoop(options.Loop+1), LoopCount(i))
+	// Changes made by started components that implement Reverter (like network
+	// impairments applied by netem components) are always reverted, even if the
+	// components weren't included in the stop or cleanup stages or the run was
+	// canceled.
+	for i := len(reverting) - 1; i >= 0; i-- {
+		var (
+			name    = reverting[i]
+			spec    = components[name]
+			options = append(opts, Name(name), Type(spec.Type), Stage(ACTIONCLEANUP), Metadata(spec.Metadata))
+		)
 
-			if err := executor(ctx, components, exe.Loop, opts...); err != nil {
-				errors = multierror.Append(errors, err)
-				break
-			}
-		}
+		logger.Info("reverting scorch component", "component", name)
 
-		if errors != nil {
-			update.Status = "failure"
-		} else {
-			update.Status = "success"
+		if err := RevertComponent(detachedContext{ctx}, options...); err != nil {
+			errors = multierror.Append(errors, fmt.Errorf("%s reverting component %s for experiment %s: %w", loopPrefix, name, exp, err))
 		}
-
-		scorch.UpdatePipeline(update)
-	}
-
-	if err := stop(); err != nil {
-		errors = multierror.Append(errors, err)
 	}
 
-	if err := cleanup(); err != nil {
-		errors = multierror.Append(errors, err)
+	if len(exe.Finally) > 0 {
+		// The finally stage runs even if the run was canceled.
+		if err := runStage(detachedContext{ctx}, ACTIONFINALLY, exe.Finally); err != nil {
+			errors = multierror.Append(errors, err)
+		}
 	}
 
 	if update.Loop != 0 {
@@ -696,3 +743,12 @@ func executor(ctx context.Context, components scorchmd.ComponentSpecMap, exe *sc
 
 	return errors
 }
+
+// stageVerbs is used to describe components that failed in a stage.
+var stageVerbs = map[Action]string{
+	ACTIONCONFIG:  "configuring",
+	ACTIONSTART:   "starting",
+	ACTIONSTOP:    "stopping",
+	ACTIONCLEANUP: "cleaning up",
+	ACTIONFINALLY: "finalizing",
+}
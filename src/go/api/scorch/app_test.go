@@ -5,37 +5,74 @@ import (
 	"fmt"
 
 	"phenix/api/scorch/scorchmd"
+	"phenix/types"
+	v1 "phenix/types/version/v1"
 
 	"github.com/mitchellh/mapstructure"
 	"gopkg.in/yaml.v3"
 )
 
 var data = `
-execute:
-  configure: [break]
+runs:
+- configure: [break]
   start: [break]
   stop: [break]
   cleanup: [break]
   loop:
-    execute:
-      configure: []
-      start: [break]
-      stop: [break]
-      cleanup: []
-      loop:
-        execute:
-          configure: [break]
-          start: []
-          stop: []
-          cleanup: [break]
-        count: 3
+    configure: []
+    start: [break]
+    stop: [break]
+    cleanup: []
+    loop:
+      configure: [break]
+      start: []
+      stop: []
+      cleanup: [break]
+      count: 3
     count: 2
 components:
 - name: break
+  type: print
   metadata: {}
 `
 
-func ExampleExecuteLoop() {
+// printComponent prints the lifecycle stage and name of the component each
+// time it's executed.
+type printComponent struct {
+	options Options
+}
+
+func (this *printComponent) Init(opts ...Option) error {
+	this.options = NewOptions(opts...)
+	return nil
+}
+
+func (printComponent) Type() string {
+	return "print"
+}
+
+func (this printComponent) Configure(context.Context) error {
+	return this.print(ACTIONCONFIG)
+}
+
+func (this printComponent) Start(context.Context) error {
+	return this.print(ACTIONSTART)
+}
+
+func (this printComponent) Stop(context.Context) error {
+	return this.print(ACTIONSTOP)
+}
+
+func (this printComponent) Cleanup(context.Context) error {
+	return this.print(ACTIONCLEANUP)
+}
+
+func (this printComponent) print(stage Action) error {
+	fmt.Println(stage, this.options.Name)
+	return nil
+}
+
+func Example_executor() {
 	ms := make(map[string]interface{})
 
 	if err := yaml.Unmarshal([]byte(data), &ms); err != nil {
@@ -50,13 +87,18 @@ func ExampleExecuteLoop() {
 		return
 	}
 
-	components := make(map[string]scorchmd.ComponentSpec)
+	components["print"] = new(printComponent)
+	defer delete(components, "print")
+
+	specs := make(scorchmd.ComponentSpecMap)
 
 	for _, c := range md.Components {
-		components[c.Name] = c
+		specs[c.Name] = c
 	}
 
-	if err := executor(context.Background(), components, md.Execute); err != nil {
+	exp := types.Experiment{Spec: &v1.ExperimentSpec{ExperimentNameF: "foo"}}
+
+	if err := executor(context.Background(), specs, md.Runs[0], Experiment(exp)); err != nil {
 		fmt.Println(err)
 		return
 	}
@@ -83,3 +125,50 @@ func ExampleExecuteLoop() {
 	// stop break
 	// cleanup break
 }
+
+// revertComponent is a printComponent that prints the name of the component
+// when its start stage is reverted.
+type revertComponent struct {
+	printComponent
+}
+
+func (revertComponent) Type() string {
+	return "revert"
+}
+
+func (this revertComponent) Revert(context.Context) error {
+	fmt.Println("revert", this.options.Name)
+	return nil
+}
+
+func Example_executorReverter() {
+	components["print"] = new(printComponent)
+	defer delete(components, "print")
+
+	components["revert"] = new(revertComponent)
+	defer delete(components, "revert")
+
+	specs := scorchmd.ComponentSpecMap{
+		"x":     {Name: "x", Type: "revert"},
+		"y":     {Name: "y", Type: "revert"},
+		"print": {Name: "print", Type: "print"},
+	}
+
+	// Started components that implement Reverter are reverted at the end of the
+	// run unless they've already been stopped or cleaned up.
+	loop := &scorchmd.Loop{Start: []string{"x", "y", "print"}, Stop: []string{"y"}}
+
+	exp := types.Experiment{Spec: &v1.ExperimentSpec{ExperimentNameF: "foo"}}
+
+	if err := executor(context.Background(), specs, loop, Experiment(exp)); err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	// Output:
+	// start x
+	// start y
+	// start print
+	// stop y
+	// revert x
+}
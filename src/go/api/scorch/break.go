@@ -77,14 +77,23 @@ func (this Break) breakPoint(ctx context.Context, stage Action) error {
 			return fmt.Errorf("setting up tap for break: %w", err)
 		}
 
-		var status scorchmd.ScorchStatus
-		if err := this.options.Exp.Status.ParseAppStatus("scorch", &status); err != nil {
+		var err error
+
+		this.options.Exp.Modify(func() {
+			var status scorchmd.ScorchStatus
+			if err = this.options.Exp.Status.ParseAppStatus("scorch", &status); err != nil {
+				return
+			}
+
+			status.Taps[this.options.Name] = md.Tap
+
+			this.options.Exp.Status.SetAppStatus("scorch", status)
+		})
+
+		if err != nil {
 			return fmt.Errorf("getting experiment status for scorch app: %w", err)
 		}
 
-		status.Taps[this.options.Name] = md.Tap
-
-		this.options.Exp.Status.SetAppStatus("scorch", status)
 		this.options.Exp.WriteToStore(true)
 
 		defer md.Tap.Delete(mm.Headnode())
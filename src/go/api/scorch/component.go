@@ -15,6 +15,7 @@ const (
 	ACTIONCLEANUP Action = "cleanup"
 	ACTIONDONE    Action = "done"
 	ACTIONLOOP    Action = "loop"
+	ACTIONFINALLY Action = "finally"
 )
 
 // Component is the interface that identifies all the required functionality
@@ -43,15 +44,28 @@ type Component interface {
 	Cleanup(context.Context) error
 }
 
+// Reverter is an optional interface implemented by components whose start
+// stage leaves changes in place (e.g., network impairments) that must be
+// reverted once a run is done with them. The SCORCH executor reverts started
+// components that implement it at the end of a run if they weren't already
+// stopped or cleaned up, including when the run is canceled.
+type Reverter interface {
+	// Revert undoes the changes made by the component's `start` stage.
+	Revert(context.Context) error
+}
+
 var components map[string]Component
 
 func init() {
 	components = map[string]Component{
 		"break":      new(Break),
+		"exec":       new(Exec),
+		"netem":      new(Netem),
 		"pause":      new(Pause),
 		"soh":        new(SOH),
 		"tap":        new(Tap),
 		"user-shell": new(UserComponent),
+		"vm":         new(VM),
 	}
 }
 
@@ -67,15 +81,11 @@ func GetComponent(name string) Component {
 func ExecuteComponent(ctx context.Context, opts ...Option) error {
 	options := NewOptions(opts...)
 
-	cmp, ok := components[options.Type]
-	if !ok {
-		cmp = components["user-shell"]
+	cmp, err := initComponent(options, opts...)
+	if err != nil {
+		return err
 	}
 
-	cmp.Init(opts...)
-
-	var err error
-
 	switch options.Stage {
 	case ACTIONCONFIG:
 		err = cmp.Configure(ctx)
@@ -83,7 +93,8 @@ func ExecuteComponent(ctx context.Context, opts ...Option) error {
 		err = cmp.Start(ctx)
 	case ACTIONSTOP:
 		err = cmp.Stop(ctx)
-	case ACTIONCLEANUP:
+	case ACTIONCLEANUP, ACTIONFINALLY:
+		// Components in the finally stage use their cleanup hook.
 		err = cmp.Cleanup(ctx)
 	}
 
@@ -93,3 +104,45 @@ func ExecuteComponent(ctx context.Context, opts ...Option) error {
 
 	return nil
 }
+
+// RevertComponent reverts the changes made by the `start` stage of the
+// component configured by the given options. It's a no-op for components that
+// don't implement Reverter.
+func RevertComponent(ctx context.Context, opts ...Option) error {
+	options := NewOptions(opts...)
+
+	cmp, err := initComponent(options, opts...)
+	if err != nil {
+		return err
+	}
+
+	reverter, ok := cmp.(Reverter)
+	if !ok {
+		return nil
+	}
+
+	if err := reverter.Revert(ctx); err != nil {
+		return fmt.Errorf("reverting component %s: %w", options.Type, err)
+	}
+
+	return nil
+}
+
+// initComponent initializes the component of the type in the given options.
+func initComponent(options Options, opts ...Option) (Component, error) {
+	// Render the component metadata using the parameters of the current matrix
+	// loop iteration, if any.
+	if len(options.Params) > 0 {
+		meta, err := options.Meta.Render(options.Params)
+		if err != nil {
+			return nil, fmt.Errorf("rendering metadata for component %s: %w", options.Name, err)
+		}
+
+		opts = append(opts, Metadata(meta))
+	}
+
+	cmp := GetComponent(options.Type)
+	cmp.Init(opts...)
+
+	return cmp, nil
+}
@@ -0,0 +1,676 @@
+package scorch
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	ifaces "phenix/types/interfaces"
+	"phenix/util"
+	"phenix/util/mm"
+	"phenix/web/scorch"
+
+	"github.com/hashicorp/go-multierror"
+	"github.com/mitchellh/mapstructure"
+)
+
+// exitCodeRegex matches the line written to STDERR by the scripts generated
+// for commands to report the exit code of the command. The scripts write a
+// newline before the line, since the command's own output to STDERR may not
+// end with one.
+var exitCodeRegex = regexp.MustCompile(`\r?\nPHENIX_EXIT_CODE=(-?\d+)(\r?\n|$)`)
+
+// ExecMetadata represents the metadata for the `exec` component, which runs
+// commands in VMs via minimega's C2 (cc). Target VMs are selected by hostname
+// or glob pattern (`vms`) and/or by topology labels (`labels`). Commands are
+// configured per stage, and each stage can also send files to the VMs before
+// running its commands and extract files from the VMs into the run directory
+// after running them.
+//
+//	metadata:
+//	  vms: [client-*, server]
+//	  labels: [traffic]
+//	  timeout: 5m
+//	  start:
+//	    send: [scripts/iperf.sh]
+//	    commands:
+//	    - command: bash /tmp/miniccc/files/scorch/iperf/iperf.sh
+//	      timeout: 10m
+//	      stdout: 'receiver'
+//	      exitCode: 0
+//	    extract: [/tmp/iperf.json]
+type ExecMetadata struct {
+	VMs       []string   `mapstructure:"vms"`
+	Labels    []string   `mapstructure:"labels"`
+	Timeout   string     `mapstructure:"timeout"`
+	Configure *ExecStage `mapstructure:"configure"`
+	Start     *ExecStage `mapstructure:"start"`
+	Stop      *ExecStage `mapstructure:"stop"`
+	Cleanup   *ExecStage `mapstructure:"cleanup"`
+
+	timeout time.Duration
+}
+
+// ExecStage represents what the `exec` component does in a stage. Files to
+// send are paths on the headnode, either absolute or relative to the
+// experiment files directory, and are available in the VMs at
+// /tmp/miniccc/files/scorch/<component name>/<file name>. Files to extract are
+// paths in the VMs, and are written to the run directory at
+// <component name>/<loop iteration>/<vm>/<file name>.
+type ExecStage struct {
+	Send     []string      `mapstructure:"send"`
+	Commands []ExecCommand `mapstructure:"commands"`
+	Extract  []string      `mapstructure:"extract"`
+}
+
+// ExecCommand represents a command to run in the target VMs. The command fails
+// if it doesn't exit with the expected exit code (0 by default), or if its
+// STDOUT or STDERR doesn't match the given regular expressions (if any).
+type ExecCommand struct {
+	Command  string `mapstructure:"command"`
+	Timeout  string `mapstructure:"timeout"`
+	Stdout   string `mapstructure:"stdout"`
+	Stderr   string `mapstructure:"stderr"`
+	ExitCode int    `mapstructure:"exitCode"`
+
+	timeout time.Duration
+	stdout  *regexp.Regexp
+	stderr  *regexp.Regexp
+}
+
+type Exec struct {
+	options Options
+}
+
+func (this *Exec) Init(opts ...Option) error {
+	this.options = NewOptions(opts...)
+	return nil
+}
+
+func (Exec) Type() string {
+	return "exec"
+}
+
+func (this Exec) Configure(ctx context.Context) error {
+	if this.options.Background {
+		ctx = background(ctx, ACTIONCONFIG, this.options)
+		go this.run(ctx, ACTIONCONFIG)
+		return nil
+	}
+
+	return this.run(ctx, ACTIONCONFIG)
+}
+
+func (this Exec) Start(ctx context.Context) error {
+	if this.options.Background {
+		ctx = background(ctx, ACTIONSTART, this.options)
+		go this.run(ctx, ACTIONSTART)
+		return nil
+	}
+
+	return this.run(ctx, ACTIONSTART)
+}
+
+func (this Exec) Stop(ctx context.Context) error {
+	if handleBackgrounded(ACTIONSTOP, this.options) {
+		return nil
+	}
+
+	return this.run(ctx, ACTIONSTOP)
+}
+
+func (this Exec) Cleanup(ctx context.Context) error {
+	if handleBackgrounded(ACTIONCLEANUP, this.options) {
+		return nil
+	}
+
+	return this.run(ctx, ACTIONCLEANUP)
+}
+
+func (this Exec) run(ctx context.Context, stage Action) error {
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+
+	md, err := this.metadata()
+	if err != nil {
+		return err
+	}
+
+	var s *ExecStage
+
+	switch stage {
+	case ACTIONCONFIG:
+		s = md.Configure
+	case ACTIONSTART:
+		s = md.Start
+	case ACTIONSTOP:
+		s = md.Stop
+	case ACTIONCLEANUP:
+		s = md.Cleanup
+	}
+
+	if s == nil {
+		return nil
+	}
+
+	nodes, err := targetVMs(this.options.Exp.Spec.Topology(), md.VMs, md.Labels)
+	if err != nil {
+		return fmt.Errorf("finding target VMs for exec component: %w", err)
+	}
+
+	update := scorch.ComponentUpdate{
+		Exp:     this.options.Exp.Spec.ExperimentName(),
+		CmpName: this.options.Name,
+		CmpType: this.options.Type,
+		Run:     this.options.Run,
+		Loop:    this.options.Loop,
+		Count:   this.options.Count,
+		Params:  this.options.Params.String(),
+		Stage:   string(this.options.Stage),
+		Status:  "running",
+	}
+
+	// VMs are handled in parallel, so each output update gets its own copy of
+	// the component update.
+	output := func(format string, args ...interface{}) {
+		u := update
+		u.Output = []byte(fmt.Sprintf(format, args...))
+		scorch.UpdateComponent(u)
+	}
+
+	sends, err := this.stageFiles(s.Send)
+	if err != nil {
+		return err
+	}
+
+	output("Running %s stage in %d VM(s): %s\n", stage, len(nodes), strings.Join(hostnames(nodes), ", "))
+
+	var (
+		wg      sync.WaitGroup
+		mu      sync.Mutex
+		errs    error
+		results []*ComponentResult
+	)
+
+	for _, node := range nodes {
+		wg.Add(1)
+
+		go func(node ifaces.NodeSpec) {
+			defer wg.Done()
+
+			// Each VM records its assertions and metrics to its own result so they
+			// can be added to the component result in order once all VMs are done.
+			result := new(ComponentResult)
+
+			if err := this.runInVM(ctx, stage, md, s, sends, node, result, output); err != nil {
+				mu.Lock()
+				errs = multierror.Append(errs, fmt.Errorf("VM %s: %w", node.General().Hostname(), err))
+				mu.Unlock()
+			}
+
+			mu.Lock()
+			results = append(results, result)
+			mu.Unlock()
+		}(node)
+	}
+
+	wg.Wait()
+
+	if r := this.options.Result; r != nil {
+		for _, result := range results {
+			r.Metrics = append(r.Metrics, result.Metrics...)
+			r.Assertions = append(r.Assertions, result.Assertions...)
+		}
+
+		sort.SliceStable(r.Metrics, func(i, j int) bool { return r.Metrics[i].Name < r.Metrics[j].Name })
+		sort.SliceStable(r.Assertions, func(i, j int) bool { return r.Assertions[i].Name < r.Assertions[j].Name })
+	}
+
+	if errs != nil {
+		return fmt.Errorf("executing commands for %s stage: %w", stage, errs)
+	}
+
+	return nil
+}
+
+func (this Exec) runInVM(ctx context.Context, stage Action, md ExecMetadata, s *ExecStage, sends []string, node ifaces.NodeSpec, result *ComponentResult, output func(string, ...interface{})) error {
+	var (
+		ns   = this.options.Exp.Spec.ExperimentName()
+		host = node.General().Hostname()
+	)
+
+	for _, send := range sends {
+		opts := []mm.C2Option{mm.C2NS(ns), mm.C2VM(host), mm.C2SendFile(send), mm.C2Wait(), mm.C2Context(ctx), mm.C2Timeout(md.timeout)}
+
+		if _, err := mm.ExecC2Command(opts...); err != nil {
+			return fmt.Errorf("sending file %s: %w", path.Base(send), err)
+		}
+
+		output("[%s] sent file %s\n", host, "/tmp/miniccc/files/"+send)
+	}
+
+	for i, cmd := range s.Commands {
+		name := fmt.Sprintf("%s %s command %d", host, stage, i+1)
+		start := time.Now()
+
+		code, stdout, stderr, err := this.execute(ctx, stage, i, cmd, node)
+
+		result.Metric(name+" duration", time.Since(start).Seconds(), "s")
+
+		if err != nil {
+			result.Assert(name, false, err.Error())
+			output("[%s] $ %s\n%v\n", host, cmd.Command, err)
+
+			return fmt.Errorf("running command '%s': %w", cmd.Command, err)
+		}
+
+		output("[%s] $ %s\n%s%s[%s] exit code %d\n", host, cmd.Command, withNewline(stdout), withNewline(stderr), host, code)
+
+		if err := this.writeRunFile(host, fmt.Sprintf("%s-%d.stdout", stage, i+1), []byte(stdout)); err != nil {
+			output("[%s] %v\n", host, err)
+		}
+
+		if err := this.writeRunFile(host, fmt.Sprintf("%s-%d.stderr", stage, i+1), []byte(stderr)); err != nil {
+			output("[%s] %v\n", host, err)
+		}
+
+		var failures []string
+
+		if code != cmd.ExitCode {
+			failures = append(failures, fmt.Sprintf("exit code %d (expected %d)", code, cmd.ExitCode))
+		}
+
+		if cmd.stdout != nil && !cmd.stdout.MatchString(stdout) {
+			failures = append(failures, fmt.Sprintf("STDOUT did not match %s", cmd.Stdout))
+		}
+
+		if cmd.stderr != nil && !cmd.stderr.MatchString(stderr) {
+			failures = append(failures, fmt.Sprintf("STDERR did not match %s", cmd.Stderr))
+		}
+
+		if len(failures) > 0 {
+			msg := strings.Join(failures, ", ")
+			result.Assert(name, false, msg)
+
+			return fmt.Errorf("command '%s' failed: %s", cmd.Command, msg)
+		}
+
+		result.Assert(name, true, "")
+	}
+
+	for _, src := range s.Extract {
+		if err := this.extract(ctx, md, node, src); err != nil {
+			return fmt.Errorf("extracting file %s: %w", src, err)
+		}
+
+		output("[%s] extracted file %s\n", host, src)
+	}
+
+	return nil
+}
+
+// execute runs the given command in the given VM, returning its exit code,
+// STDOUT, and STDERR.
+func (this Exec) execute(ctx context.Context, stage Action, idx int, cmd ExecCommand, node ifaces.NodeSpec) (int, string, string, error) {
+	var (
+		ns     = this.options.Exp.Spec.ExperimentName()
+		script = fmt.Sprintf("scorch/%s/%s-%s-%d", this.options.Name, node.General().Hostname(), stage, idx+1)
+	)
+
+	return runScript(ctx, ns, node, script, cmd.Command, "sh", cmd.timeout)
+}
+
+// runScript runs the given command in the given VM, returning its exit code,
+// STDOUT, and STDERR. The command is written to a script (at the given path,
+// relative to the experiment's directory in the minimega files directory)
+// that's sent to the VM and run, since C2 doesn't report the exit code of
+// commands itself. Scripts are run with the given shell in Linux VMs and with
+// PowerShell in Windows VMs.
+func runScript(ctx context.Context, ns string, node ifaces.NodeSpec, script, command, shell string, timeout time.Duration) (int, string, string, error) {
+	var (
+		host = node.General().Hostname()
+		body string
+		exe  string
+	)
+
+	if strings.EqualFold(node.Hardware().OSType(), "windows") {
+		script += ".ps1"
+		exe = "powershell -NoProfile -ExecutionPolicy bypass -File"
+
+		body = fmt.Sprintf("$global:LASTEXITCODE = 0\r\n& {\r\n%s\r\n}\r\n$ok = $?\r\n$code = if ($LASTEXITCODE) { $LASTEXITCODE } elseif ($ok) { 0 } else { 1 }\r\n[Console]::Error.WriteLine()\r\n[Console]::Error.WriteLine(\"PHENIX_EXIT_CODE=$code\")\r\n", command)
+	} else {
+		exe = shell
+		body = fmt.Sprintf("(\n%s\n)\nprintf '\\nPHENIX_EXIT_CODE=%%d\\n' \"$?\" 1>&2\n", command)
+	}
+
+	local := util.GetMMFullPath(filepath.Join(ns, script))
+
+	if err := os.MkdirAll(filepath.Dir(local), 0755); err != nil {
+		return 0, "", "", fmt.Errorf("creating directory for command script: %w", err)
+	}
+
+	if err := os.WriteFile(local, []byte(body), 0600); err != nil {
+		return 0, "", "", fmt.Errorf("writing command script: %w", err)
+	}
+
+	defer os.Remove(local)
+
+	cmd := fmt.Sprintf("%s /tmp/miniccc/files/%s", exe, script)
+	opts := []mm.C2Option{mm.C2NS(ns), mm.C2VM(host), mm.C2SendFile(script), mm.C2Command(cmd), mm.C2Context(ctx), mm.C2Timeout(timeout)}
+
+	id, err := mm.ExecC2Command(opts...)
+	if err != nil {
+		return 0, "", "", err
+	}
+
+	opts = []mm.C2Option{mm.C2NS(ns), mm.C2Context(ctx), mm.C2CommandID(id), mm.C2Timeout(timeout)}
+
+	if _, err := mm.WaitForC2Response(opts...); err != nil {
+		return 0, "", "", fmt.Errorf("waiting for response (timeout %v): %w", timeout, err)
+	}
+
+	opts = []mm.C2Option{mm.C2NS(ns), mm.C2VM(host), mm.C2CommandID(id)}
+
+	stdout, err := mm.GetC2Response(append(opts, mm.C2ResponseTypeStdout())...)
+	if err != nil {
+		return 0, "", "", fmt.Errorf("getting STDOUT: %w", err)
+	}
+
+	stderr, err := mm.GetC2Response(append(opts, mm.C2ResponseTypeStderr())...)
+	if err != nil {
+		return 0, "", "", fmt.Errorf("getting STDERR: %w", err)
+	}
+
+	code, stderr, err := parseExitCode(stderr)
+	if err != nil {
+		return 0, stdout, stderr, err
+	}
+
+	return code, stdout, stderr, nil
+}
+
+// extract copies the file at the given path in the given VM to the run
+// directory. The file is base64 encoded in the VM so binary files survive
+// being returned as command output.
+func (this Exec) extract(ctx context.Context, md ExecMetadata, node ifaces.NodeSpec, src string) error {
+	var (
+		ns   = this.options.Exp.Spec.ExperimentName()
+		host = node.General().Hostname()
+	)
+
+	cmd, err := extractCommand(node.Hardware().OSType(), src)
+	if err != nil {
+		return err
+	}
+
+	opts := []mm.C2Option{mm.C2NS(ns), mm.C2VM(host), mm.C2Command(cmd), mm.C2Wait(), mm.C2Context(ctx), mm.C2Timeout(md.timeout)}
+
+	id, err := mm.ExecC2Command(opts...)
+	if err != nil {
+		return err
+	}
+
+	opts = []mm.C2Option{mm.C2NS(ns), mm.C2VM(host), mm.C2CommandID(id)}
+
+	resp, err := mm.GetC2Response(append(opts, mm.C2ResponseTypeStdout())...)
+	if err != nil {
+		return fmt.Errorf("getting file contents: %w", err)
+	}
+
+	if strings.TrimSpace(resp) == "" {
+		stderr, _ := mm.GetC2Response(append(opts, mm.C2ResponseTypeStderr())...)
+		return fmt.Errorf("no file contents returned: %s", strings.TrimSpace(stderr))
+	}
+
+	body, err := base64.StdEncoding.DecodeString(strings.Join(strings.Fields(resp), ""))
+	if err != nil {
+		return fmt.Errorf("decoding file contents: %w", err)
+	}
+
+	// The file could be from a Windows VM.
+	name := path.Base(strings.ReplaceAll(src, `\`, "/"))
+
+	return this.writeRunFile(host, name, body)
+}
+
+// stageFiles copies the given files to the experiment's directory in the
+// minimega files directory so they can be sent to VMs, returning their paths
+// relative to the experiment's directory.
+func (this Exec) stageFiles(files []string) ([]string, error) {
+	var (
+		ns    = this.options.Exp.Spec.ExperimentName()
+		sends []string
+	)
+
+	for _, src := range files {
+		if !filepath.IsAbs(src) {
+			src = filepath.Join(this.options.Exp.FilesDir(), src)
+		}
+
+		body, err := os.ReadFile(src)
+		if err != nil {
+			return nil, fmt.Errorf("reading file to send: %w", err)
+		}
+
+		send := path.Join("scorch", this.options.Name, filepath.Base(src))
+		dst := util.GetMMFullPath(filepath.Join(ns, send))
+
+		if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+			return nil, fmt.Errorf("creating directory for file to send: %w", err)
+		}
+
+		if err := os.WriteFile(dst, body, 0644); err != nil {
+			return nil, fmt.Errorf("staging file to send: %w", err)
+		}
+
+		sends = append(sends, send)
+	}
+
+	return sends, nil
+}
+
+// writeRunFile writes the given file for the given VM to the run directory.
+func (this Exec) writeRunFile(host, name string, body []byte) error {
+	var (
+		runDir = filepath.Join(this.options.Exp.FilesDir(), "scorch", fmt.Sprintf("run-%d", this.options.Run))
+		dst    = filepath.Join(runDir, this.options.Name, this.options.IterationName(), host, name)
+	)
+
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return fmt.Errorf("creating directory for %s: %w", name, err)
+	}
+
+	if err := os.WriteFile(dst, body, 0644); err != nil {
+		return fmt.Errorf("writing %s to run directory: %w", name, err)
+	}
+
+	return nil
+}
+
+// metadata decodes and validates the component metadata.
+func (this Exec) metadata() (ExecMetadata, error) {
+	var md ExecMetadata
+
+	if err := mapstructure.Decode(this.options.Meta, &md); err != nil {
+		return md, fmt.Errorf("decoding exec component metadata: %w", err)
+	}
+
+	md.timeout = 5 * time.Minute
+
+	if md.Timeout != "" {
+		var err error
+
+		if md.timeout, err = time.ParseDuration(md.Timeout); err != nil {
+			return md, fmt.Errorf("parsing exec component timeout: %w", err)
+		}
+	}
+
+	for _, s := range []*ExecStage{md.Configure, md.Start, md.Stop, md.Cleanup} {
+		if s == nil {
+			continue
+		}
+
+		for i := range s.Commands {
+			cmd := &s.Commands[i]
+
+			if cmd.Command == "" {
+				return md, fmt.Errorf("exec component command %d is missing the command to run", i+1)
+			}
+
+			cmd.timeout = md.timeout
+
+			if cmd.Timeout != "" {
+				var err error
+
+				if cmd.timeout, err = time.ParseDuration(cmd.Timeout); err != nil {
+					return md, fmt.Errorf("parsing timeout for command '%s': %w", cmd.Command, err)
+				}
+			}
+
+			if cmd.Stdout != "" {
+				var err error
+
+				if cmd.stdout, err = regexp.Compile(cmd.Stdout); err != nil {
+					return md, fmt.Errorf("parsing STDOUT regex for command '%s': %w", cmd.Command, err)
+				}
+			}
+
+			if cmd.Stderr != "" {
+				var err error
+
+				if cmd.stderr, err = regexp.Compile(cmd.Stderr); err != nil {
+					return md, fmt.Errorf("parsing STDERR regex for command '%s': %w", cmd.Command, err)
+				}
+			}
+		}
+	}
+
+	return md, nil
+}
+
+// targetVMs returns the VMs in the given topology with hostnames matching the
+// given glob patterns or with any of the given labels, sorted by hostname.
+func targetVMs(topo ifaces.TopologySpec, patterns, labels []string) ([]ifaces.NodeSpec, error) {
+	targets := make(map[string]ifaces.NodeSpec)
+
+	for _, pattern := range patterns {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("invalid VM pattern %s: %w", pattern, err)
+		}
+
+		var matched bool
+
+		for _, node := range topo.Nodes() {
+			if node.External() {
+				continue
+			}
+
+			if ok, _ := path.Match(pattern, node.General().Hostname()); ok {
+				targets[node.General().Hostname()] = node
+				matched = true
+			}
+		}
+
+		if !matched {
+			return nil, fmt.Errorf("no VMs found matching %s", pattern)
+		}
+	}
+
+	if len(labels) > 0 {
+		for _, node := range topo.FindNodesWithLabels(labels...) {
+			if !node.External() {
+				targets[node.General().Hostname()] = node
+			}
+		}
+	}
+
+	if len(targets) == 0 {
+		return nil, fmt.Errorf("no target VMs found")
+	}
+
+	nodes := make([]ifaces.NodeSpec, 0, len(targets))
+
+	for _, node := range targets {
+		nodes = append(nodes, node)
+	}
+
+	sort.Slice(nodes, func(i, j int) bool {
+		return nodes[i].General().Hostname() < nodes[j].General().Hostname()
+	})
+
+	return nodes, nil
+}
+
+// parseExitCode returns the exit code reported in the given STDERR output of a
+// command script, along with the output with the exit code line removed.
+func parseExitCode(stderr string) (int, string, error) {
+	matches := exitCodeRegex.FindAllStringSubmatchIndex(stderr, -1)
+	if matches == nil {
+		return 0, stderr, fmt.Errorf("command did not report an exit code")
+	}
+
+	// The exit code is reported after the command's own output, so use the last
+	// match in case the command wrote something similar itself.
+	match := matches[len(matches)-1]
+
+	code, err := strconv.Atoi(stderr[match[2]:match[3]])
+	if err != nil {
+		return 0, stderr, fmt.Errorf("parsing exit code %q: %w", stderr[match[2]:match[3]], err)
+	}
+
+	return code, stderr[:match[0]] + stderr[match[1]:], nil
+}
+
+// extractCommand returns the command used to base64 encode the file at the
+// given path in a VM running the given OS.
+func extractCommand(osType, src string) (string, error) {
+	if !strings.EqualFold(osType, "windows") {
+		return "base64 -w 0 " + shellQuote(src), nil
+	}
+
+	// The path is passed to PowerShell inside a double quoted argument, which a
+	// double quote would break out of. Double quotes aren't valid in Windows
+	// paths anyway, so such paths are rejected rather than escaped.
+	if strings.Contains(src, `"`) {
+		return "", fmt.Errorf("invalid Windows path %s", src)
+	}
+
+	// Single quotes are escaped in PowerShell strings by doubling them.
+	src = strings.ReplaceAll(src, "'", "''")
+
+	return fmt.Sprintf(`powershell -NoProfile -Command "[Convert]::ToBase64String([IO.File]::ReadAllBytes('%s'))"`, src), nil
+}
+
+// shellQuote quotes the given string for use as a single argument in a shell
+// command.
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+func hostnames(nodes []ifaces.NodeSpec) []string {
+	names := make([]string, len(nodes))
+
+	for i, node := range nodes {
+		names[i] = node.General().Hostname()
+	}
+
+	return names
+}
+
+func withNewline(s string) string {
+	if s == "" || strings.HasSuffix(s, "\n") {
+		return s
+	}
+
+	return s + "\n"
+}
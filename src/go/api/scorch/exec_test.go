@@ -0,0 +1,203 @@
+package scorch
+
+import (
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+
+	"phenix/api/scorch/scorchmd"
+	v1 "phenix/types/version/v1"
+)
+
+func testTopology() *v1.TopologySpec {
+	external := true
+
+	return &v1.TopologySpec{NodesF: []*v1.Node{
+		{GeneralF: &v1.General{HostnameF: "server"}, LabelsF: map[string]string{"traffic": "true"}},
+		{GeneralF: &v1.General{HostnameF: "client-2"}},
+		{GeneralF: &v1.General{HostnameF: "client-1"}, LabelsF: map[string]string{"traffic": "true"}},
+		{GeneralF: &v1.General{HostnameF: "client-ext"}, LabelsF: map[string]string{"traffic": "true"}, ExternalF: &external},
+		{GeneralF: &v1.General{HostnameF: "router"}, LabelsF: map[string]string{"core": "true"}},
+	}}
+}
+
+func TestParseExitCode(t *testing.T) {
+	tests := []struct {
+		stderr string
+		code   int
+		output string
+	}{
+		{"\nPHENIX_EXIT_CODE=0\n", 0, ""},
+		{"\nPHENIX_EXIT_CODE=2", 2, ""},
+		{"oops\n\nPHENIX_EXIT_CODE=1\n", 1, "oops\n"},
+		{"oops\r\n\r\nPHENIX_EXIT_CODE=-1\r\n", -1, "oops\r\n"},
+		{"oops\nPHENIX_EXIT_CODE=3\n", 3, "oops"},
+		{"\nPHENIX_EXIT_CODE=4\nmore\nPHENIX_EXIT_CODE=5\n", 5, "\nPHENIX_EXIT_CODE=4\nmore"},
+	}
+
+	for _, test := range tests {
+		code, output, err := parseExitCode(test.stderr)
+		if err != nil {
+			t.Logf("parsing exit code from %q: %v", test.stderr, err)
+			t.FailNow()
+		}
+
+		if code != test.code || output != test.output {
+			t.Logf("expected exit code %d and output %q from %q, got %d and %q", test.code, test.output, test.stderr, code, output)
+			t.FailNow()
+		}
+	}
+
+	for _, stderr := range []string{"", "oops\n", "\nPHENIX_EXIT_CODE=\n", "\nPHENIX_EXIT_CODE=-\n", "exit PHENIX_EXIT_CODE=1\n"} {
+		if _, output, err := parseExitCode(stderr); err == nil || output != stderr {
+			t.Logf("expected error and unchanged output parsing exit code from %q", stderr)
+			t.FailNow()
+		}
+	}
+}
+
+func TestShellQuote(t *testing.T) {
+	for _, s := range []string{"/tmp/foo.json", "/tmp/foo bar.json", "/tmp/it's.json", "/tmp/$(reboot);`id`"} {
+		out, err := exec.Command("sh", "-c", "printf %s "+shellQuote(s)).Output()
+		if err != nil {
+			t.Logf("running quoted %q: %v", s, err)
+			t.FailNow()
+		}
+
+		if string(out) != s {
+			t.Logf("expected %q to be passed as a single argument, got %q", s, out)
+			t.FailNow()
+		}
+	}
+}
+
+func TestExtractCommand(t *testing.T) {
+	tests := []struct {
+		os, src, cmd string
+	}{
+		{"linux", "/tmp/it's.json", `base64 -w 0 '/tmp/it'\''s.json'`},
+		{"Windows", `C:\it's.json`, `powershell -NoProfile -Command "[Convert]::ToBase64String([IO.File]::ReadAllBytes('C:\it''s.json'))"`},
+	}
+
+	for _, test := range tests {
+		cmd, err := extractCommand(test.os, test.src)
+		if err != nil {
+			t.Logf("building extract command for %q: %v", test.src, err)
+			t.FailNow()
+		}
+
+		if cmd != test.cmd {
+			t.Logf("expected extract command %q, got %q", test.cmd, cmd)
+			t.FailNow()
+		}
+	}
+
+	if _, err := extractCommand("windows", `C:\foo"; Restart-Computer; ".json`); err == nil {
+		t.Log("expected error building extract command for Windows path containing a double quote")
+		t.FailNow()
+	}
+}
+
+func TestExecMetadata(t *testing.T) {
+	exec := Exec{options: NewOptions(Metadata(scorchmd.ComponentMetadata{
+		"vms":     []string{"server"},
+		"timeout": "1m",
+		"start": map[string]interface{}{
+			"commands": []map[string]interface{}{
+				{"command": "ping -c 1 client", "stdout": "1 received", "exitCode": 1},
+				{"command": "sleep 90", "timeout": "2m"},
+			},
+		},
+	}))}
+
+	md, err := exec.metadata()
+	if err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+
+	cmds := md.Start.Commands
+
+	if cmds[0].timeout != time.Minute || cmds[1].timeout != 2*time.Minute {
+		t.Logf("expected command timeouts of 1m and 2m, got %v and %v", cmds[0].timeout, cmds[1].timeout)
+		t.FailNow()
+	}
+
+	if cmds[0].ExitCode != 1 || cmds[0].stdout == nil || cmds[0].stderr != nil || cmds[1].stdout != nil {
+		t.Logf("unexpected command metadata: %+v", cmds)
+		t.FailNow()
+	}
+
+	// Commands default to a 5 minute timeout.
+	exec = Exec{options: NewOptions(Metadata(scorchmd.ComponentMetadata{
+		"cleanup": map[string]interface{}{"commands": []map[string]interface{}{{"command": "true"}}},
+	}))}
+
+	if md, err := exec.metadata(); err != nil || md.Cleanup.Commands[0].timeout != 5*time.Minute {
+		t.Logf("expected default command timeout of 5m, got %+v (%v)", md.Cleanup, err)
+		t.FailNow()
+	}
+
+	invalid := map[string]scorchmd.ComponentMetadata{
+		"parsing exec component timeout": {"timeout": "soon"},
+		"parsing timeout for command":    {"start": map[string]interface{}{"commands": []map[string]interface{}{{"command": "true", "timeout": "10"}}}},
+		"parsing STDOUT regex":           {"start": map[string]interface{}{"commands": []map[string]interface{}{{"command": "true", "stdout": "("}}}},
+		"parsing STDERR regex":           {"stop": map[string]interface{}{"commands": []map[string]interface{}{{"command": "true", "stderr": "[a-"}}}},
+		"missing the command to run":     {"configure": map[string]interface{}{"commands": []map[string]interface{}{{"stdout": "ok"}}}},
+	}
+
+	for expected, meta := range invalid {
+		exec := Exec{options: NewOptions(Metadata(meta))}
+
+		if _, err := exec.metadata(); err == nil || !strings.Contains(err.Error(), expected) {
+			t.Logf("expected error containing %q, got %v", expected, err)
+			t.FailNow()
+		}
+	}
+}
+
+func TestTargetVMs(t *testing.T) {
+	tests := []struct {
+		patterns []string
+		labels   []string
+		expected []string
+	}{
+		{[]string{"server"}, nil, []string{"server"}},
+		{[]string{"client-*"}, nil, []string{"client-1", "client-2"}},
+		{nil, []string{"traffic"}, []string{"client-1", "server"}},
+		{[]string{"client-*"}, []string{"traffic", "core"}, []string{"client-1", "client-2", "router", "server"}},
+	}
+
+	for _, test := range tests {
+		nodes, err := targetVMs(testTopology(), test.patterns, test.labels)
+		if err != nil {
+			t.Logf("finding target VMs for %v and %v: %v", test.patterns, test.labels, err)
+			t.FailNow()
+		}
+
+		if names := hostnames(nodes); strings.Join(names, ",") != strings.Join(test.expected, ",") {
+			t.Logf("expected target VMs %v for %v and %v, got %v", test.expected, test.patterns, test.labels, names)
+			t.FailNow()
+		}
+	}
+
+	errors := []struct {
+		patterns []string
+		labels   []string
+		expected string
+	}{
+		{[]string{"client-ext"}, nil, "no VMs found matching client-ext"}, // external nodes aren't deployed
+		{[]string{"server", "db-*"}, nil, "no VMs found matching db-*"},
+		{[]string{"[client"}, nil, "invalid VM pattern"},
+		{nil, []string{"missing"}, "no target VMs found"},
+		{nil, nil, "no target VMs found"},
+	}
+
+	for _, test := range errors {
+		if _, err := targetVMs(testTopology(), test.patterns, test.labels); err == nil || !strings.Contains(err.Error(), test.expected) {
+			t.Logf("expected error containing %q for %v and %v, got %v", test.expected, test.patterns, test.labels, err)
+			t.FailNow()
+		}
+	}
+}
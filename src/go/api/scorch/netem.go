@@ -0,0 +1,390 @@
+package scorch
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"phenix/app"
+	ifaces "phenix/types/interfaces"
+	"phenix/util/mm"
+	"phenix/web/scorch"
+
+	"github.com/mitchellh/mapstructure"
+)
+
+var bandwidthRegex = regexp.MustCompile(`^(\d+)\s*(kbit|mbit|gbit)$`)
+
+// NetemMetadata represents the metadata for the `netem` component, which
+// impairs the network traffic of a VM. Impairments are applied either to an
+// interface of a VM (`interface`, by name or index) using minimega's QoS
+// support, or to an existing network emulator of a VyOS router configured via
+// the `vrouter` app (`emulator`).
+//
+// Impairments are applied in the start stage and removed in the stop and
+// cleanup stages. If a duration is provided, the impairments are removed at
+// the end of the start stage once the duration has elapsed instead. Either
+// way, the executor removes any impairments still applied once the loop the
+// component was started in is cleaned up, even if the run was canceled.
+//
+//	metadata:
+//	  vm: router
+//	  interface: eth0
+//	  delay: 100ms
+//	  loss: 5%
+//	  bandwidth: 10mbit
+type NetemMetadata struct {
+	VM        string `mapstructure:"vm"`
+	Interface string `mapstructure:"interface"`
+	Emulator  string `mapstructure:"emulator"`
+	Delay     string `mapstructure:"delay"`
+	Loss      string `mapstructure:"loss"`
+	Bandwidth string `mapstructure:"bandwidth"`
+	Duration  string `mapstructure:"duration"`
+}
+
+func (this *NetemMetadata) Validate() error {
+	if this.VM == "" {
+		return fmt.Errorf("missing VM")
+	}
+
+	if (this.Interface == "") == (this.Emulator == "") {
+		return fmt.Errorf("exactly one of interface or emulator must be provided")
+	}
+
+	if this.Delay == "" && this.Loss == "" && this.Bandwidth == "" {
+		return fmt.Errorf("at least one of delay, loss, or bandwidth must be provided")
+	}
+
+	if this.Delay != "" {
+		if _, err := time.ParseDuration(this.Delay); err != nil {
+			return fmt.Errorf("invalid delay: %w", err)
+		}
+	}
+
+	if this.Loss != "" {
+		this.Loss = strings.TrimSpace(strings.TrimSuffix(this.Loss, "%"))
+
+		loss, err := strconv.ParseFloat(this.Loss, 64)
+		if err != nil {
+			return fmt.Errorf("invalid loss: %w", err)
+		}
+
+		if loss < 0 || loss > 100 {
+			return fmt.Errorf("invalid loss: must be a percentage between 0 and 100")
+		}
+	}
+
+	if this.Bandwidth != "" {
+		this.Bandwidth = strings.ToLower(this.Bandwidth)
+
+		if !bandwidthRegex.MatchString(this.Bandwidth) {
+			return fmt.Errorf("invalid bandwidth: must be in kbit, mbit, or gbit (e.g. 10mbit)")
+		}
+	}
+
+	if this.Duration != "" {
+		if _, err := time.ParseDuration(this.Duration); err != nil {
+			return fmt.Errorf("invalid duration: %w", err)
+		}
+	}
+
+	return nil
+}
+
+type Netem struct {
+	options Options
+}
+
+func (this *Netem) Init(opts ...Option) error {
+	this.options = NewOptions(opts...)
+	return nil
+}
+
+func (Netem) Type() string {
+	return "netem"
+}
+
+func (Netem) Configure(context.Context) error {
+	return nil
+}
+
+func (this Netem) Start(ctx context.Context) error {
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+
+	md, node, err := this.metadata()
+	if err != nil {
+		return err
+	}
+
+	if err := this.apply(ctx, md, node); err != nil {
+		// Don't leave a partially applied impairment in place.
+		this.revert(detachedContext{ctx}, md, node)
+		return err
+	}
+
+	if md.Duration == "" {
+		return nil
+	}
+
+	d, _ := time.ParseDuration(md.Duration)
+
+	this.output("impairing for %v\n", d)
+
+	select {
+	case <-ctx.Done():
+	case <-time.After(d):
+	}
+
+	// Revert even if the run was canceled while waiting.
+	if err := this.revert(detachedContext{ctx}, md, node); err != nil {
+		return err
+	}
+
+	return ctx.Err()
+}
+
+func (this Netem) Stop(ctx context.Context) error {
+	md, node, err := this.metadata()
+	if err != nil {
+		return err
+	}
+
+	return this.revert(ctx, md, node)
+}
+
+func (this Netem) Cleanup(ctx context.Context) error {
+	// Impairments are always removed at cleanup, even if the run was canceled.
+	return this.Revert(ctx)
+}
+
+// Revert removes the impairments applied by the component, even if the run was
+// canceled.
+func (this Netem) Revert(ctx context.Context) error {
+	md, node, err := this.metadata()
+	if err != nil {
+		return err
+	}
+
+	return this.revert(detachedContext{ctx}, md, node)
+}
+
+func (this Netem) apply(ctx context.Context, md NetemMetadata, node ifaces.NodeSpec) error {
+	if md.Emulator != "" {
+		emulator := app.Emulator{Name: md.Emulator, Delay: md.Delay, Loss: md.Loss, Bandwidth: md.Bandwidth}
+
+		if err := this.configureEmulator(ctx, node, emulator); err != nil {
+			return fmt.Errorf("applying impairments to emulator %s on VM %s: %w", md.Emulator, md.VM, err)
+		}
+
+		this.output("applied impairments to emulator %s on VM %s\n", md.Emulator, md.VM)
+
+		return nil
+	}
+
+	iface, err := interfaceIndex(node, md.Interface)
+	if err != nil {
+		return err
+	}
+
+	opts := []mm.Option{
+		mm.NS(this.options.Exp.Spec.ExperimentName()),
+		mm.VMName(md.VM),
+		mm.QoSInterface(iface),
+		mm.QoSDelay(md.Delay),
+		mm.QoSLoss(md.Loss),
+	}
+
+	if md.Bandwidth != "" {
+		match := bandwidthRegex.FindStringSubmatch(md.Bandwidth)
+		opts = append(opts, mm.QoSRate(match[1]+" "+match[2]))
+	}
+
+	if err := mm.AddVMInterfaceQoS(opts...); err != nil {
+		return fmt.Errorf("applying impairments to interface %s on VM %s: %w", md.Interface, md.VM, err)
+	}
+
+	this.output("applied impairments to interface %s on VM %s\n", md.Interface, md.VM)
+
+	return nil
+}
+
+func (this Netem) revert(ctx context.Context, md NetemMetadata, node ifaces.NodeSpec) error {
+	if md.Emulator != "" {
+		// Restore the emulator to how it was configured by the vrouter app.
+		emulator := app.Emulator{Name: md.Emulator}
+
+		for _, e := range this.emulators(md.VM) {
+			if e.Name == md.Emulator {
+				emulator = e
+				break
+			}
+		}
+
+		if err := this.configureEmulator(ctx, node, emulator); err != nil {
+			return fmt.Errorf("removing impairments from emulator %s on VM %s: %w", md.Emulator, md.VM, err)
+		}
+
+		this.output("removed impairments from emulator %s on VM %s\n", md.Emulator, md.VM)
+
+		return nil
+	}
+
+	iface, err := interfaceIndex(node, md.Interface)
+	if err != nil {
+		return err
+	}
+
+	opts := []mm.Option{mm.NS(this.options.Exp.Spec.ExperimentName()), mm.VMName(md.VM), mm.QoSInterface(iface)}
+
+	if err := mm.ClearVMInterfaceQoS(opts...); err != nil {
+		return fmt.Errorf("removing impairments from interface %s on VM %s: %w", md.Interface, md.VM, err)
+	}
+
+	this.output("removed impairments from interface %s on VM %s\n", md.Interface, md.VM)
+
+	return nil
+}
+
+// configureEmulator sets the delay, loss, and bandwidth of the given network
+// emulator on the given VyOS router via C2, deleting any settings that are
+// empty.
+func (this Netem) configureEmulator(ctx context.Context, node ifaces.NodeSpec, emulator app.Emulator) error {
+	var (
+		ns       = this.options.Exp.Spec.ExperimentName()
+		base     = "traffic-policy network-emulator " + emulator.Name
+		commands = []string{"source /opt/vyatta/etc/functions/script-template", "configure"}
+	)
+
+	settings := [][2]string{
+		{"network-delay", emulator.Delay},
+		{"packet-loss", strings.TrimSuffix(emulator.Loss, "%")},
+		{"bandwidth", emulator.Bandwidth},
+	}
+
+	for _, setting := range settings {
+		if setting[1] == "" {
+			// Deleting a setting that doesn't exist isn't an error worth failing for.
+			commands = append(commands, fmt.Sprintf("delete %s %s || true", base, setting[0]))
+		} else {
+			commands = append(commands, fmt.Sprintf("set %s %s %s", base, setting[0], setting[1]))
+		}
+	}
+
+	commands = append(commands, "commit", "exit")
+
+	script := fmt.Sprintf("scorch/%s/%s-netem", this.options.Name, node.General().Hostname())
+
+	code, stdout, stderr, err := runScript(ctx, ns, node, script, strings.Join(commands, "\n"), "vbash", time.Minute)
+	if err != nil {
+		return err
+	}
+
+	if code != 0 {
+		return fmt.Errorf("configuring emulator exited with code %d: %s", code, strings.TrimSpace(stdout+"\n"+stderr))
+	}
+
+	return nil
+}
+
+// emulators returns the network emulators configured for the given VM via the
+// `vrouter` app.
+func (this Netem) emulators(host string) []app.Emulator {
+	for _, a := range this.options.Exp.Apps() {
+		if a.Name() != "vrouter" {
+			continue
+		}
+
+		for _, h := range a.Hosts() {
+			if h.Hostname() != host {
+				continue
+			}
+
+			var emulators []app.Emulator
+
+			if e, ok := h.Metadata()["emulators"]; ok {
+				mapstructure.Decode(e, &emulators)
+			}
+
+			return emulators
+		}
+	}
+
+	return nil
+}
+
+// metadata decodes and validates the component metadata, returning it along
+// with the VM it targets.
+func (this Netem) metadata() (NetemMetadata, ifaces.NodeSpec, error) {
+	var md NetemMetadata
+
+	if err := mapstructure.Decode(this.options.Meta, &md); err != nil {
+		return md, nil, fmt.Errorf("decoding netem component metadata: %w", err)
+	}
+
+	if err := md.Validate(); err != nil {
+		return md, nil, fmt.Errorf("validating netem component metadata: %w", err)
+	}
+
+	node := this.options.Exp.Spec.Topology().FindNodeByName(md.VM)
+	if node == nil {
+		return md, nil, fmt.Errorf("VM %s not found in experiment topology", md.VM)
+	}
+
+	if md.Emulator != "" {
+		var found bool
+
+		for _, e := range this.emulators(md.VM) {
+			if e.Name == md.Emulator {
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			return md, nil, fmt.Errorf("emulator %s not configured for VM %s in vrouter app", md.Emulator, md.VM)
+		}
+	}
+
+	return md, node, nil
+}
+
+func (this Netem) output(format string, args ...interface{}) {
+	update := scorch.ComponentUpdate{
+		Exp:     this.options.Exp.Spec.ExperimentName(),
+		CmpName: this.options.Name,
+		CmpType: this.options.Type,
+		Run:     this.options.Run,
+		Loop:    this.options.Loop,
+		Count:   this.options.Count,
+		Params:  this.options.Params.String(),
+		Stage:   string(this.options.Stage),
+		Status:  "running",
+		Output:  []byte(fmt.Sprintf(format, args...)),
+	}
+
+	scorch.UpdateComponent(update)
+}
+
+// interfaceIndex returns the index of the given interface of the given VM,
+// which can be either the name or index of the interface.
+func interfaceIndex(node ifaces.NodeSpec, name string) (int, error) {
+	interfaces := node.Network().Interfaces()
+
+	for i, iface := range interfaces {
+		if iface.Name() == name {
+			return i, nil
+		}
+	}
+
+	if i, err := strconv.Atoi(name); err == nil && i >= 0 && i < len(interfaces) {
+		return i, nil
+	}
+
+	return 0, fmt.Errorf("interface %s not found for VM %s", name, node.General().Hostname())
+}
@@ -0,0 +1,231 @@
+package scorch
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"phenix/api/scorch/scorchmd"
+	"phenix/types"
+	v1 "phenix/types/version/v1"
+	"phenix/util/mm"
+	"phenix/util/mm/mmfake"
+)
+
+func TestNetemMetadataValidate(t *testing.T) {
+	valid := []struct {
+		md        NetemMetadata
+		loss      string
+		bandwidth string
+	}{
+		{NetemMetadata{VM: "router", Interface: "eth0", Delay: "100ms"}, "", ""},
+		{NetemMetadata{VM: "router", Interface: "eth0", Loss: "5%"}, "5", ""},
+		{NetemMetadata{VM: "router", Interface: "eth0", Loss: "0.5"}, "0.5", ""},
+		{NetemMetadata{VM: "router", Interface: "eth0", Loss: "100 %"}, "100", ""},
+		{NetemMetadata{VM: "router", Emulator: "wan", Bandwidth: "10Mbit"}, "", "10mbit"},
+		{NetemMetadata{VM: "router", Emulator: "wan", Bandwidth: "512 kbit", Duration: "30s"}, "", "512 kbit"},
+		{NetemMetadata{VM: "router", Interface: "1", Delay: "1s", Loss: "0", Bandwidth: "1gbit"}, "0", "1gbit"},
+	}
+
+	for _, test := range valid {
+		md := test.md
+
+		if err := md.Validate(); err != nil {
+			t.Logf("validating %+v: %v", test.md, err)
+			t.FailNow()
+		}
+
+		if md.Loss != test.loss || md.Bandwidth != test.bandwidth {
+			t.Logf("expected loss %q and bandwidth %q for %+v, got %q and %q", test.loss, test.bandwidth, test.md, md.Loss, md.Bandwidth)
+			t.FailNow()
+		}
+	}
+
+	invalid := []struct {
+		md       NetemMetadata
+		expected string
+	}{
+		{NetemMetadata{Interface: "eth0", Delay: "100ms"}, "missing VM"},
+		{NetemMetadata{VM: "router", Delay: "100ms"}, "exactly one of interface or emulator"},
+		{NetemMetadata{VM: "router", Interface: "eth0", Emulator: "wan", Delay: "100ms"}, "exactly one of interface or emulator"},
+		{NetemMetadata{VM: "router", Interface: "eth0"}, "at least one of delay, loss, or bandwidth"},
+		{NetemMetadata{VM: "router", Interface: "eth0", Delay: "100"}, "invalid delay"},
+		{NetemMetadata{VM: "router", Interface: "eth0", Loss: "lots"}, "invalid loss"},
+		{NetemMetadata{VM: "router", Interface: "eth0", Loss: "-1%"}, "invalid loss"},
+		{NetemMetadata{VM: "router", Interface: "eth0", Loss: "100.1"}, "invalid loss"},
+		{NetemMetadata{VM: "router", Interface: "eth0", Bandwidth: "10"}, "invalid bandwidth"},
+		{NetemMetadata{VM: "router", Interface: "eth0", Bandwidth: "10mbps"}, "invalid bandwidth"},
+		{NetemMetadata{VM: "router", Interface: "eth0", Bandwidth: "1.5mbit"}, "invalid bandwidth"},
+		{NetemMetadata{VM: "router", Interface: "eth0", Delay: "100ms", Duration: "forever"}, "invalid duration"},
+	}
+
+	for _, test := range invalid {
+		md := test.md
+
+		if err := md.Validate(); err == nil || !strings.Contains(err.Error(), test.expected) {
+			t.Logf("expected error containing %q validating %+v, got %v", test.expected, test.md, err)
+			t.FailNow()
+		}
+	}
+}
+
+func TestInterfaceIndex(t *testing.T) {
+	node := &v1.Node{
+		GeneralF: &v1.General{HostnameF: "router"},
+		NetworkF: &v1.Network{InterfacesF: []*v1.Interface{{NameF: "eth0"}, {NameF: "eth1"}, {NameF: "2"}}},
+	}
+
+	tests := map[string]int{"eth0": 0, "eth1": 1, "1": 1, "0": 0, "2": 2}
+
+	for name, expected := range tests {
+		idx, err := interfaceIndex(node, name)
+		if err != nil {
+			t.Logf("getting index of interface %s: %v", name, err)
+			t.FailNow()
+		}
+
+		if idx != expected {
+			t.Logf("expected index %d for interface %s, got %d", expected, name, idx)
+			t.FailNow()
+		}
+	}
+
+	for _, name := range []string{"eth2", "3", "-1", ""} {
+		if _, err := interfaceIndex(node, name); err == nil || !strings.Contains(err.Error(), "not found for VM router") {
+			t.Logf("expected error getting index of interface %q, got %v", name, err)
+			t.FailNow()
+		}
+	}
+
+	// VMs without any interfaces don't have any indexes either.
+	if _, err := interfaceIndex(&v1.Node{GeneralF: &v1.General{HostnameF: "lonely"}}, "0"); err == nil {
+		t.Log("expected error getting index of interface for VM without interfaces")
+		t.FailNow()
+	}
+}
+
+// cancelComponent cancels the run once started, using the `cancel` function in
+// its metadata.
+type cancelComponent struct {
+	options Options
+}
+
+func (this *cancelComponent) Init(opts ...Option) error {
+	this.options = NewOptions(opts...)
+	return nil
+}
+
+func (cancelComponent) Type() string {
+	return "cancel"
+}
+
+func (cancelComponent) Configure(context.Context) error {
+	return nil
+}
+
+func (this cancelComponent) Start(context.Context) error {
+	this.options.Meta["cancel"].(context.CancelFunc)()
+	return nil
+}
+
+func (cancelComponent) Stop(context.Context) error {
+	return nil
+}
+
+func (cancelComponent) Cleanup(context.Context) error {
+	return nil
+}
+
+func TestExecutorRevertsNetem(t *testing.T) {
+	defer func(m mm.MM) { mm.DefaultMM = m }(mm.DefaultMM)
+
+	components["cancel"] = new(cancelComponent)
+	defer delete(components, "cancel")
+
+	exp := types.Experiment{Spec: &v1.ExperimentSpec{
+		ExperimentNameF: "foo",
+		TopologyF: &v1.TopologySpec{NodesF: []*v1.Node{{
+			GeneralF: &v1.General{HostnameF: "router"},
+			NetworkF: &v1.Network{InterfacesF: []*v1.Interface{{NameF: "eth0"}, {NameF: "eth1"}}},
+		}}},
+	}}
+
+	tests := []struct {
+		name     string
+		loop     *scorchmd.Loop
+		failed   bool
+		expected []string
+	}{
+		{
+			name:     "reverted when not in stop or cleanup stages",
+			loop:     &scorchmd.Loop{Start: []string{"wan", "lan"}},
+			expected: []string{"qos add router 0 delay 100ms", "qos add router 1 loss 5", "qos add router 1 rate 10 mbit", "clear qos router 1", "clear qos router 0"},
+		},
+		{
+			name:     "not reverted again once stopped",
+			loop:     &scorchmd.Loop{Start: []string{"wan", "lan"}, Stop: []string{"wan"}, Cleanup: []string{"lan"}},
+			expected: []string{"qos add router 0 delay 100ms", "qos add router 1 loss 5", "qos add router 1 rate 10 mbit", "clear qos router 0", "clear qos router 1"},
+		},
+		{
+			name:     "reverted when the run is canceled",
+			loop:     &scorchmd.Loop{Start: []string{"wan", "canceler", "lan"}, Cleanup: []string{"lan"}},
+			failed:   true,
+			expected: []string{"qos add router 0 delay 100ms", "clear qos router 1", "clear qos router 0"},
+		},
+	}
+
+	for _, test := range tests {
+		cluster := mmfake.New()
+		mm.DefaultMM = cluster
+
+		script := filepath.Join(t.TempDir(), "foo.mm")
+
+		if err := os.WriteFile(script, []byte("namespace foo\nvm config net a b\nvm launch kvm router\n"), 0600); err != nil {
+			t.Log(err)
+			t.FailNow()
+		}
+
+		if err := cluster.ReadScriptFromFile(script); err != nil {
+			t.Log(err)
+			t.FailNow()
+		}
+
+		ctx, cancel := context.WithCancel(context.Background())
+
+		specs := scorchmd.ComponentSpecMap{
+			"wan":      {Name: "wan", Type: "netem", Metadata: scorchmd.ComponentMetadata{"vm": "router", "interface": "eth0", "delay": "100ms"}},
+			"lan":      {Name: "lan", Type: "netem", Metadata: scorchmd.ComponentMetadata{"vm": "router", "interface": "eth1", "loss": "5%", "bandwidth": "10mbit"}},
+			"canceler": {Name: "canceler", Type: "cancel", Metadata: scorchmd.ComponentMetadata{"cancel": cancel}},
+		}
+
+		err := executor(ctx, specs, test.loop, Experiment(exp))
+		cancel()
+
+		if test.failed != (err != nil) {
+			t.Logf("%s: unexpected error result: %v", test.name, err)
+			t.FailNow()
+		}
+
+		var qos []string
+
+		for _, cmd := range cluster.History() {
+			if strings.Contains(cmd, "qos") {
+				qos = append(qos, strings.TrimPrefix(cmd, "namespace foo "))
+			}
+		}
+
+		if strings.Join(qos, ", ") != strings.Join(test.expected, ", ") {
+			t.Logf("%s: expected QoS commands %v, got %v", test.name, test.expected, qos)
+			t.FailNow()
+		}
+
+		for iface := 0; iface < 2; iface++ {
+			if applied := cluster.QoS("foo", "router", iface); len(applied) != 0 {
+				t.Logf("%s: expected impairments on interface %d to be removed, got %v", test.name, iface, applied)
+				t.FailNow()
+			}
+		}
+	}
+}
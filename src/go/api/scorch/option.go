@@ -1,7 +1,10 @@
 package scorch
 
 import (
+	"fmt"
+
 	"phenix/api/scorch/scorchmd"
+	"phenix/api/scorch/scorchresult"
 	"phenix/types"
 )
 
@@ -20,7 +23,11 @@ type Options struct {
 	Run        int
 	Loop       int
 	Count      int
+	Parents    []int
+	Params     scorchmd.Params
 	Background bool
+	Result     *ComponentResult
+	Record     *scorchresult.Run
 }
 
 // NewOptions returns an Options struct initialized with the given option list.
@@ -97,9 +104,61 @@ func LoopCount(c int) Option {
 	}
 }
 
+// ParentCounts sets the counts of the loops the currently running loop is
+// nested in, outermost first.
+func ParentCounts(c []int) Option {
+	return func(o *Options) {
+		o.Parents = c
+	}
+}
+
+// Params sets the matrix parameters of the currently running loop iteration.
+func Params(p scorchmd.Params) Option {
+	return func(o *Options) {
+		o.Params = p
+	}
+}
+
 // Background marks the component to be run in the background.
 func Background() Option {
 	return func(o *Options) {
 		o.Background = true
 	}
 }
+
+// Result sets the result the component can record its output, exit code,
+// metrics, and assertions to.
+func Result(r *ComponentResult) Option {
+	return func(o *Options) {
+		o.Result = r
+	}
+}
+
+// Record sets the run results the executor records the results of each
+// component to.
+func Record(r *scorchresult.Run) Option {
+	return func(o *Options) {
+		o.Record = r
+	}
+}
+
+// IterationName returns the name to use for the current loop iteration in
+// file and directory names, prefixed with the iterations of the loops it is
+// nested in and labeled with the matrix parameters (if any) of the iteration
+// (e.g. `loop-0-count-1`, `loop-0-count-1_loop-1-count-0`, or
+// `loop-0-count-1_delay-10ms_hosts-3`).
+func (this Options) IterationName() string {
+	var name string
+
+	for i, count := range this.Parents {
+		name += fmt.Sprintf("loop-%d-count-%d_", i, count)
+	}
+
+	name += fmt.Sprintf("loop-%d-count-%d", this.Loop, this.Count)
+
+	if len(this.Params) > 0 {
+		name += "_" + this.Params.Label()
+	}
+
+	return name
+}
@@ -0,0 +1,144 @@
+package scorch
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"phenix/api/scorch/scorchmd"
+	"phenix/api/scorch/scorchresult"
+)
+
+// ComponentResult represents the result of running a component in a loop
+// iteration. Components can set the output and exit code of the result passed
+// to them via their options, and record metrics and assertions using its
+// methods.
+type ComponentResult struct {
+	Status     string // success, failure, or skipped
+	ExitCode   int
+	Output     string
+	Error      string
+	Metrics    []scorchresult.Metric
+	Assertions []scorchresult.Assertion
+
+	start time.Time
+}
+
+// Metric records a named metric for the component. It's a no-op if the result
+// is nil, so components can call it without checking if a result was passed
+// to them.
+func (this *ComponentResult) Metric(name string, value float64, unit string) {
+	if this == nil {
+		return
+	}
+
+	this.Metrics = append(this.Metrics, scorchresult.Metric{Name: name, Value: value, Unit: unit})
+}
+
+// Assert records a named assertion for the component. A failed assertion
+// causes the component to fail. It's a no-op if the result is nil.
+func (this *ComponentResult) Assert(name string, passed bool, msg string) {
+	if this == nil {
+		return
+	}
+
+	this.Assertions = append(this.Assertions, scorchresult.Assertion{Name: name, Passed: passed, Message: msg})
+}
+
+// failedAssertions returns an error describing the failed assertions recorded
+// for the component, if any.
+func (this ComponentResult) failedAssertions() error {
+	var failed []string
+
+	for _, a := range this.Assertions {
+		if a.Passed {
+			continue
+		}
+
+		if a.Message == "" {
+			failed = append(failed, a.Name)
+		} else {
+			failed = append(failed, fmt.Sprintf("%s (%s)", a.Name, a.Message))
+		}
+	}
+
+	if len(failed) == 0 {
+		return nil
+	}
+
+	return fmt.Errorf("failed assertions: %s", strings.Join(failed, ", "))
+}
+
+type componentResults map[string]*ComponentResult
+
+// failed returns true if any of the components failed.
+func (this componentResults) failed() bool {
+	for _, r := range this {
+		if r.Status == "failure" {
+			return true
+		}
+	}
+
+	return false
+}
+
+// when evaluates the given `when` condition against the given matrix
+// parameters and component results.
+func (this componentResults) when(cond string, params scorchmd.Params) (bool, error) {
+	tmpl, err := template.New("when").Parse(cond)
+	if err != nil {
+		return false, fmt.Errorf("parsing condition %q: %w", cond, err)
+	}
+
+	components := make(map[string]interface{})
+
+	for name, r := range this {
+		components[name] = map[string]interface{}{
+			"status":   r.Status,
+			"exitCode": r.ExitCode,
+			"output":   r.Output,
+			"error":    r.Error,
+		}
+	}
+
+	data := map[string]interface{}{
+		"params":     map[string]string(params),
+		"components": components,
+		"failed":     this.failed(),
+	}
+
+	var buf bytes.Buffer
+
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return false, fmt.Errorf("executing condition %q: %w", cond, err)
+	}
+
+	result, err := strconv.ParseBool(strings.TrimSpace(buf.String()))
+	if err != nil {
+		return false, fmt.Errorf("condition %q evaluated to %q instead of a boolean", cond, buf.String())
+	}
+
+	return result, nil
+}
+
+// detachedContext carries the values of its parent context but is never
+// canceled, so the finally stage of a loop runs even if the run was canceled.
+type detachedContext struct {
+	context.Context
+}
+
+func (detachedContext) Deadline() (time.Time, bool) {
+	return time.Time{}, false
+}
+
+func (detachedContext) Done() <-chan struct{} {
+	return nil
+}
+
+func (detachedContext) Err() error {
+	return nil
+}
@@ -0,0 +1,136 @@
+package scorchmd
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"text/template"
+)
+
+var nonAlphanumeric = regexp.MustCompile(`[^a-zA-Z0-9]+`)
+
+// Params represents the values of the matrix parameters for a single
+// iteration of a loop, keyed by parameter name.
+type Params map[string]string
+
+// Merge returns a copy of the parameters with the given parameters added,
+// replacing any existing parameters with the same name.
+func (this Params) Merge(other Params) Params {
+	merged := make(Params, len(this)+len(other))
+
+	for k, v := range this {
+		merged[k] = v
+	}
+
+	for k, v := range other {
+		merged[k] = v
+	}
+
+	return merged
+}
+
+// String returns the parameters as a comma-separated list of `name=value`
+// pairs sorted by name (e.g. `delay=10ms,hosts=3`).
+func (this Params) String() string {
+	var pairs []string
+
+	for _, k := range this.names() {
+		pairs = append(pairs, k+"="+this[k])
+	}
+
+	return strings.Join(pairs, ",")
+}
+
+// Label returns the parameters in a form safe to use in file and directory
+// names (e.g. `delay-10ms_hosts-3`).
+func (this Params) Label() string {
+	var pairs []string
+
+	for _, k := range this.names() {
+		pairs = append(pairs, sanitize(k)+"-"+sanitize(this[k]))
+	}
+
+	return strings.Join(pairs, "_")
+}
+
+// Env returns the parameters as environment variables of the form
+// `PHENIX_SCORCH_PARAM_<NAME>=<value>`, with the name upper-cased and any
+// non-alphanumeric characters replaced with underscores.
+func (this Params) Env() []string {
+	var env []string
+
+	for _, k := range this.names() {
+		name := strings.ToUpper(nonAlphanumeric.ReplaceAllString(k, "_"))
+		env = append(env, fmt.Sprintf("PHENIX_SCORCH_PARAM_%s=%s", name, this[k]))
+	}
+
+	return env
+}
+
+func (this Params) names() []string {
+	var names []string
+
+	for k := range this {
+		names = append(names, k)
+	}
+
+	sort.Strings(names)
+
+	return names
+}
+
+func sanitize(s string) string {
+	return strings.Trim(nonAlphanumeric.ReplaceAllString(s, "."), ".")
+}
+
+func renderValue(value interface{}, params Params) (interface{}, error) {
+	switch v := value.(type) {
+	case string:
+		if !strings.Contains(v, "{{") {
+			return v, nil
+		}
+
+		tmpl, err := template.New("metadata").Option("missingkey=error").Parse(v)
+		if err != nil {
+			return nil, fmt.Errorf("parsing metadata template %q: %w", v, err)
+		}
+
+		var buf bytes.Buffer
+
+		if err := tmpl.Execute(&buf, map[string]string(params)); err != nil {
+			return nil, fmt.Errorf("executing metadata template %q: %w", v, err)
+		}
+
+		return buf.String(), nil
+	case map[string]interface{}:
+		rendered := make(map[string]interface{}, len(v))
+
+		for k, e := range v {
+			r, err := renderValue(e, params)
+			if err != nil {
+				return nil, err
+			}
+
+			rendered[k] = r
+		}
+
+		return rendered, nil
+	case []interface{}:
+		rendered := make([]interface{}, len(v))
+
+		for i, e := range v {
+			r, err := renderValue(e, params)
+			if err != nil {
+				return nil, err
+			}
+
+			rendered[i] = r
+		}
+
+		return rendered, nil
+	default:
+		return v, nil
+	}
+}
@@ -1,6 +1,9 @@
 package scorchmd
 
 import (
+	"fmt"
+	"sort"
+
 	"phenix/util"
 	"phenix/util/tap"
 )
@@ -25,10 +28,14 @@ spec:
           filebeat.shutdown_timeout: 60s
       runs:
       - count: 1
+        matrix:
+          delay: [10ms, 50ms]
+          hosts: [1, 3]
         configure: []
         start: [mooncake_topo, break]
         stop: [mooncake_topo]
         cleanup: []
+        finally: [collect]
         loop:
           count: 2
           configure: []
@@ -61,11 +68,17 @@ spec:
             networks: onenet twonet
       - name: break
         metadata: {}
+      - name: collect
+        type: mooncake_apps
+        continueOnError: true
+        onFailure: [break]
+        when: '{{ eq .components.mooncake_topo.status "success" }}'
+        metadata: {}
       - name: mooncake_apps
         metadata:
           inject:
           - test-one: [test.yml]
-          run_start: "bash foo /test.yml"
+          run_start: "bash foo /test.yml {{ .delay }}"
           extract:
           - test-one: [test.yml]
           run_stop: "bash cleanup"
@@ -134,6 +147,57 @@ type Loop struct {
 	Stop      []string      `mapstructure:"stop"`
 	Cleanup   []string      `mapstructure:"cleanup"`
 	Loop      *Loop         `mapstructure:"loop"` // using a pointer here to avoid cyclical references
+
+	// Finally lists components to run (using their cleanup hook) after the
+	// cleanup stage, no matter how the loop ended (including if the run was
+	// canceled).
+	Finally []string `mapstructure:"finally"`
+
+	// Matrix maps parameter names to the values the loop should be executed
+	// with. The loop is executed `count` times for each combination of values.
+	Matrix map[string][]interface{} `mapstructure:"matrix"`
+}
+
+// Iterations returns the parameters for each iteration of the loop, in order.
+// The Cartesian product of the loop's matrix is expanded (with parameter names
+// sorted alphabetically, ignoring names without values) and each combination is
+// repeated `count` times. If the loop has no matrix, `count` empty parameter
+// sets are returned.
+func (this Loop) Iterations() []Params {
+	var names []string
+
+	for name, values := range this.Matrix {
+		if len(values) > 0 {
+			names = append(names, name)
+		}
+	}
+
+	sort.Strings(names)
+
+	combos := []Params{{}}
+
+	for _, name := range names {
+		var expanded []Params
+
+		for _, combo := range combos {
+			for _, value := range this.Matrix[name] {
+				params := combo.Merge(Params{name: fmt.Sprint(value)})
+				expanded = append(expanded, params)
+			}
+		}
+
+		combos = expanded
+	}
+
+	var iterations []Params
+
+	for _, combo := range combos {
+		for i := 0; i < this.Count; i++ {
+			iterations = append(iterations, combo)
+		}
+	}
+
+	return iterations
 }
 
 func (this Loop) ContainsComponent(name string) bool {
@@ -153,6 +217,10 @@ func (this Loop) ContainsComponent(name string) bool {
 		return true
 	}
 
+	if util.StringSliceContains(this.Finally, name) {
+		return true
+	}
+
 	if this.Loop != nil {
 		return this.Loop.ContainsComponent(name)
 	}
@@ -165,6 +233,22 @@ type ComponentSpec struct {
 	Type       string            `mapstructure:"type"`
 	Background bool              `mapstructure:"background"`
 	Metadata   ComponentMetadata `mapstructure:"metadata"`
+
+	// ContinueOnError keeps a failure of the component from failing the stage
+	// it's running in.
+	ContinueOnError bool `mapstructure:"continueOnError" structs:"continueOnError"`
+
+	// OnFailure lists components to run (in the same stage) if the component
+	// fails.
+	OnFailure []string `mapstructure:"onFailure" structs:"onFailure"`
+
+	// When is a Go template evaluated before the component is run, and the
+	// component is skipped unless it evaluates to `true`. The template has access
+	// to the current matrix parameters (`.params`), the results of components
+	// already run in the current loop iteration (`.components.<name>.status`,
+	// `.exitCode`, `.output`, and `.error`), and whether any of them failed
+	// (`.failed`).
+	When string `mapstructure:"when"`
 }
 
 type FilebeatSpec struct {
@@ -174,6 +258,19 @@ type FilebeatSpec struct {
 }
 
 type ComponentMetadata map[string]interface{}
+
+// Render returns a copy of the component metadata with each string value
+// executed as a Go template using the given parameters as data (e.g.
+// `{{ .delay }}`).
+func (this ComponentMetadata) Render(params Params) (ComponentMetadata, error) {
+	rendered, err := renderValue(map[string]interface{}(this), params)
+	if err != nil {
+		return nil, err
+	}
+
+	return ComponentMetadata(rendered.(map[string]interface{})), nil
+}
+
 type ComponentSpecMap map[string]ComponentSpec
 
 type ScorchStatus struct {
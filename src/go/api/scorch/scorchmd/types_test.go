@@ -0,0 +1,91 @@
+package scorchmd
+
+import (
+	"testing"
+)
+
+func TestLoopIterations(t *testing.T) {
+	loop := Loop{
+		Count: 2,
+		Matrix: map[string][]interface{}{
+			"hosts": {1, 3},
+			"delay": {"10ms", "50ms", "100ms"},
+			"empty": {},
+		},
+	}
+
+	iterations := loop.Iterations()
+
+	if len(iterations) != 12 {
+		t.Logf("expected 12 iterations, got %d", len(iterations))
+		t.FailNow()
+	}
+
+	expected := []string{
+		"delay=10ms,hosts=1", "delay=10ms,hosts=1",
+		"delay=10ms,hosts=3", "delay=10ms,hosts=3",
+		"delay=50ms,hosts=1", "delay=50ms,hosts=1",
+	}
+
+	for i, e := range expected {
+		if iterations[i].String() != e {
+			t.Logf("expected iteration %d to be %s, got %s", i, e, iterations[i])
+			t.FailNow()
+		}
+	}
+
+	if label := iterations[0].Label(); label != "delay-10ms_hosts-1" {
+		t.Logf("expected label delay-10ms_hosts-1, got %s", label)
+		t.FailNow()
+	}
+
+	if iterations := (Loop{Count: 3}).Iterations(); len(iterations) != 3 || len(iterations[0]) != 0 {
+		t.Logf("expected 3 iterations without parameters, got %v", iterations)
+		t.FailNow()
+	}
+}
+
+func TestComponentMetadataRender(t *testing.T) {
+	md := ComponentMetadata{
+		"run_start": "netem delay {{ .delay }}",
+		"count":     3,
+		"inject": []interface{}{
+			map[string]interface{}{"test-one": "hosts-{{ .hosts }}.yml"},
+		},
+	}
+
+	rendered, err := md.Render(Params{"delay": "10ms", "hosts": "3"})
+	if err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+
+	if rendered["run_start"] != "netem delay 10ms" || rendered["count"] != 3 {
+		t.Logf("unexpected rendered metadata: %v", rendered)
+		t.FailNow()
+	}
+
+	inject := rendered["inject"].([]interface{})[0].(map[string]interface{})
+
+	if inject["test-one"] != "hosts-3.yml" {
+		t.Logf("expected nested values to be rendered, got %v", inject)
+		t.FailNow()
+	}
+
+	if md["run_start"] != "netem delay {{ .delay }}" {
+		t.Log("expected original metadata to be left unchanged")
+		t.FailNow()
+	}
+
+	if _, err := md.Render(Params{"delay": "10ms"}); err == nil {
+		t.Log("expected error rendering metadata with missing parameter")
+		t.FailNow()
+	}
+
+	env := Params{"link-delay": "10ms"}.Env()
+
+	if len(env) != 1 || env[0] != "PHENIX_SCORCH_PARAM_LINK_DELAY=10ms" {
+		t.Logf("unexpected environment variables: %v", env)
+		t.FailNow()
+	}
+}
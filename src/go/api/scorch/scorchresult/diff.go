@@ -0,0 +1,168 @@
+package scorchresult
+
+import (
+	"sort"
+)
+
+// Difference represents a component status, metric, or assertion that differs
+// between two runs. A is the value in the first run and B the value in the
+// second run, either of which is empty if the component, metric, or assertion
+// doesn't exist in that run.
+type Difference struct {
+	Iteration string   `json:"iteration"`
+	Stage     string   `json:"stage"`
+	Component string   `json:"component"`
+	Kind      string   `json:"kind"` // status, metric, or assertion
+	Name      string   `json:"name,omitempty"`
+	A         string   `json:"a"`
+	B         string   `json:"b"`
+	Delta     *float64 `json:"delta,omitempty"` // B - A, for metrics in both runs
+}
+
+// Diff compares the results of two runs, matching components by loop
+// iteration, stage, and name. Only component statuses, metrics, and assertions
+// that differ between the runs are returned.
+func Diff(a, b *Run) []Difference {
+	var (
+		keys  []string
+		left  = make(map[string]Component)
+		right = make(map[string]Component)
+	)
+
+	for _, c := range a.Components {
+		if _, ok := left[c.key()]; !ok {
+			keys = append(keys, c.key())
+		}
+
+		left[c.key()] = c
+	}
+
+	for _, c := range b.Components {
+		if _, ok := left[c.key()]; !ok {
+			if _, ok := right[c.key()]; !ok {
+				keys = append(keys, c.key())
+			}
+		}
+
+		right[c.key()] = c
+	}
+
+	var diffs []Difference
+
+	for _, key := range keys {
+		l, ok := left[key]
+		r := right[key]
+
+		cmp := l
+		if !ok {
+			cmp = r
+		}
+
+		diff := func(kind, name, a, b string, delta *float64) {
+			diffs = append(diffs, Difference{
+				Iteration: cmp.Iteration(),
+				Stage:     cmp.Stage,
+				Component: cmp.Name,
+				Kind:      kind,
+				Name:      name,
+				A:         a,
+				B:         b,
+				Delta:     delta,
+			})
+		}
+
+		if l.Status != r.Status {
+			diff("status", "", l.Status, r.Status, nil)
+		}
+
+		var (
+			lm    = make(map[string]Metric)
+			rm    = make(map[string]Metric)
+			names []string
+		)
+
+		for _, m := range l.Metrics {
+			lm[m.Name] = m
+			names = append(names, m.Name)
+		}
+
+		for _, m := range r.Metrics {
+			rm[m.Name] = m
+			names = append(names, m.Name)
+		}
+
+		for _, name := range unique(names) {
+			lv, lok := lm[name]
+			rv, rok := rm[name]
+
+			switch {
+			case lok && rok:
+				if lv.Value != rv.Value {
+					delta := rv.Value - lv.Value
+					diff("metric", name, formatMetric(lv), formatMetric(rv), &delta)
+				}
+			case lok:
+				diff("metric", name, formatMetric(lv), "", nil)
+			case rok:
+				diff("metric", name, "", formatMetric(rv), nil)
+			}
+		}
+
+		var (
+			la = make(map[string]Assertion)
+			ra = make(map[string]Assertion)
+		)
+
+		names = nil
+
+		for _, a := range l.Assertions {
+			la[a.Name] = a
+			names = append(names, a.Name)
+		}
+
+		for _, a := range r.Assertions {
+			ra[a.Name] = a
+			names = append(names, a.Name)
+		}
+
+		for _, name := range unique(names) {
+			lv, lok := la[name]
+			rv, rok := ra[name]
+
+			var lp, rp string
+
+			if lok {
+				lp = passFail(lv.Passed)
+			}
+
+			if rok {
+				rp = passFail(rv.Passed)
+			}
+
+			if lp != rp {
+				diff("assertion", name, lp, rp, nil)
+			}
+		}
+	}
+
+	return diffs
+}
+
+// unique returns the sorted, unique values in the given slice.
+func unique(values []string) []string {
+	var (
+		seen   = make(map[string]struct{})
+		result []string
+	)
+
+	for _, v := range values {
+		if _, ok := seen[v]; !ok {
+			seen[v] = struct{}{}
+			result = append(result, v)
+		}
+	}
+
+	sort.Strings(result)
+
+	return result
+}
@@ -0,0 +1,114 @@
+package scorchresult
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDiff(t *testing.T) {
+	a := testRun()
+
+	b := testRun()
+	b.Components[0].Metrics = []Metric{{Name: "latency", Value: 20, Unit: "ms"}, {Name: "jitter", Value: 1, Unit: "ms"}}
+	b.Components[1].Status = "success"
+	b.Components[1].Assertions[0].Passed = true
+	b.Components = b.Components[:2]
+
+	diffs := Diff(a, b)
+
+	expected := []Difference{
+		{Component: "ping", Kind: "metric", Name: "jitter", A: "", B: "1 ms"},
+		{Component: "ping", Kind: "metric", Name: "latency", A: "12.5 ms", B: "20 ms"},
+		{Component: "iperf", Kind: "status", A: "failure", B: "success"},
+		{Component: "iperf", Kind: "assertion", Name: "bandwidth", A: "fail", B: "pass"},
+		{Component: "collect", Kind: "status", A: "skipped", B: ""},
+	}
+
+	if len(diffs) != len(expected) {
+		t.Logf("expected %d differences, got %d: %+v", len(expected), len(diffs), diffs)
+		t.FailNow()
+	}
+
+	for i, e := range expected {
+		d := diffs[i]
+
+		if d.Component != e.Component || d.Kind != e.Kind || d.Name != e.Name || d.A != e.A || d.B != e.B {
+			t.Logf("expected difference %+v, got %+v", e, d)
+			t.FailNow()
+		}
+	}
+
+	if diffs[1].Delta == nil || *diffs[1].Delta != 7.5 {
+		t.Logf("expected latency delta of 7.5, got %v", diffs[1].Delta)
+		t.FailNow()
+	}
+
+	// Iterations of nested loops under different counts of the outer loop are
+	// compared separately.
+	nestedA, nestedB := testRun(), testRun()
+
+	for _, r := range []*Run{nestedA, nestedB} {
+		r.Add(Component{Name: "probe", Stage: "start", Loop: 1, Count: 0, Parents: []int{0}, Status: "success"})
+		r.Add(Component{Name: "probe", Stage: "start", Loop: 1, Count: 0, Parents: []int{1}, Status: "success"})
+	}
+
+	nestedB.Components[len(nestedB.Components)-1].Status = "failure"
+
+	if diffs := Diff(nestedA, nestedB); len(diffs) != 1 || diffs[0].Iteration != "loop-0-count-1/loop-1-count-0" || diffs[0].B != "failure" {
+		t.Logf("expected nested loop iteration difference, got %+v", diffs)
+		t.FailNow()
+	}
+
+	if diffs := Diff(a, testRun()); len(diffs) != 0 {
+		t.Logf("expected no differences between identical runs, got %+v", diffs)
+		t.FailNow()
+	}
+}
+
+func TestSaveListGet(t *testing.T) {
+	dir := t.TempDir()
+
+	first := testRun()
+
+	second := NewRun("foo", 1, "tuned", first.Start.Add(time.Hour))
+	second.Add(Component{Name: "ping", Stage: "start", Status: "success"})
+
+	for _, r := range []*Run{second, first} {
+		if err := Save(dir, r); err != nil {
+			t.Log(err)
+			t.FailNow()
+		}
+	}
+
+	runs, err := List(dir, -1)
+	if err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+
+	if len(runs) != 2 || runs[0].ID != first.ID || runs[1].ID != second.ID {
+		t.Logf("expected results ordered by start time, got %v", runs)
+		t.FailNow()
+	}
+
+	if runs, _ := List(dir, 1); len(runs) != 1 || runs[0].Name != "tuned" {
+		t.Logf("expected results for run 1 only, got %v", runs)
+		t.FailNow()
+	}
+
+	got, err := Get(dir, first.ID)
+	if err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+
+	if len(got.Components) != 3 || got.Components[0].Metrics[0].Value != 12.5 {
+		t.Logf("expected results to round trip, got %+v", got)
+		t.FailNow()
+	}
+
+	if _, err := Get(dir, "../foo"); err == nil {
+		t.Log("expected error getting results with invalid ID")
+		t.FailNow()
+	}
+}
@@ -0,0 +1,70 @@
+package scorchresult
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// emitted represents a single line written by a user component to its results
+// file. Each line is a JSON object describing either a metric, for example
+//
+//	{"metric": "latency", "value": 12.5, "unit": "ms"}
+//
+// or an assertion, for example
+//
+//	{"assertion": "hosts-reachable", "passed": false, "message": "host-3 unreachable"}
+type emitted struct {
+	Metric    string   `json:"metric"`
+	Assertion string   `json:"assertion"`
+	Value     *float64 `json:"value"`
+	Unit      string   `json:"unit"`
+	Passed    bool     `json:"passed"`
+	Message   string   `json:"message"`
+}
+
+// Decode reads the metrics and assertions emitted by a user component from the
+// given reader, which contains one JSON object per line. Blank lines are
+// ignored.
+func Decode(r io.Reader) ([]Metric, []Assertion, error) {
+	var (
+		scanner    = bufio.NewScanner(r)
+		metrics    []Metric
+		assertions []Assertion
+	)
+
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+
+		if text == "" {
+			continue
+		}
+
+		var e emitted
+
+		if err := json.Unmarshal([]byte(text), &e); err != nil {
+			return nil, nil, fmt.Errorf("parsing results line %d: %w", line, err)
+		}
+
+		switch {
+		case e.Metric != "":
+			if e.Value == nil {
+				return nil, nil, fmt.Errorf("metric %s on results line %d is missing a value", e.Metric, line)
+			}
+
+			metrics = append(metrics, Metric{Name: e.Metric, Value: *e.Value, Unit: e.Unit})
+		case e.Assertion != "":
+			assertions = append(assertions, Assertion{Name: e.Assertion, Passed: e.Passed, Message: e.Message})
+		default:
+			return nil, nil, fmt.Errorf("results line %d is neither a metric nor an assertion", line)
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, nil, fmt.Errorf("reading results: %w", err)
+	}
+
+	return metrics, assertions, nil
+}
@@ -0,0 +1,221 @@
+package scorchresult
+
+import (
+	"encoding/csv"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+)
+
+type junitSuites struct {
+	XMLName  xml.Name     `xml:"testsuites"`
+	Name     string       `xml:"name,attr"`
+	Tests    int          `xml:"tests,attr"`
+	Failures int          `xml:"failures,attr"`
+	Skipped  int          `xml:"skipped,attr"`
+	Time     string       `xml:"time,attr"`
+	Suites   []junitSuite `xml:"testsuite"`
+}
+
+type junitSuite struct {
+	Name      string      `xml:"name,attr"`
+	Tests     int         `xml:"tests,attr"`
+	Failures  int         `xml:"failures,attr"`
+	Skipped   int         `xml:"skipped,attr"`
+	Time      string      `xml:"time,attr"`
+	Timestamp string      `xml:"timestamp,attr,omitempty"`
+	Cases     []junitCase `xml:"testcase"`
+
+	duration float64
+}
+
+type junitCase struct {
+	ClassName  string         `xml:"classname,attr"`
+	Name       string         `xml:"name,attr"`
+	Time       string         `xml:"time,attr"`
+	Properties *junitPropList `xml:"properties,omitempty"`
+	Failure    *junitFailure  `xml:"failure,omitempty"`
+	Skipped    *struct{}      `xml:"skipped,omitempty"`
+	SystemOut  string         `xml:"system-out,omitempty"`
+}
+
+type junitPropList struct {
+	Properties []junitProperty `xml:"property"`
+}
+
+type junitProperty struct {
+	Name  string `xml:"name,attr"`
+	Value string `xml:"value,attr"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Body    string `xml:",chardata"`
+}
+
+// JUnit writes the run results to the given writer as JUnit XML. Each loop
+// iteration is a test suite, and each component executed in the iteration is
+// a test case (named for the stage and component), as is each assertion
+// emitted by the component. Component metrics are included as test case
+// properties.
+func (this Run) JUnit(w io.Writer) error {
+	var (
+		suites = junitSuites{Name: fmt.Sprintf("%s/%s", this.Experiment, this.Name)}
+		index  = make(map[string]int)
+	)
+
+	for _, c := range this.Components {
+		idx, ok := index[c.Iteration()]
+		if !ok {
+			idx = len(suites.Suites)
+			index[c.Iteration()] = idx
+
+			suites.Suites = append(suites.Suites, junitSuite{
+				Name:      fmt.Sprintf("%s %s", this.Name, c.Iteration()),
+				Timestamp: c.Start.UTC().Format(time.RFC3339),
+			})
+		}
+
+		suite := &suites.Suites[idx]
+		class := fmt.Sprintf("%s.%s.%s", this.Experiment, this.Name, c.Stage)
+
+		tc := junitCase{
+			ClassName: class,
+			Name:      c.Name,
+			Time:      formatSeconds(c.Duration),
+		}
+
+		if len(c.Metrics) > 0 {
+			tc.Properties = new(junitPropList)
+
+			for _, m := range c.Metrics {
+				tc.Properties.Properties = append(tc.Properties.Properties, junitProperty{Name: m.Name, Value: formatMetric(m)})
+			}
+		}
+
+		switch c.Status {
+		case "failure":
+			tc.Failure = &junitFailure{Message: c.Error, Body: fmt.Sprintf("exit code %d: %s", c.ExitCode, c.Error)}
+			suite.Failures++
+		case "skipped":
+			tc.Skipped = new(struct{})
+			suite.Skipped++
+		}
+
+		suite.Cases = append(suite.Cases, tc)
+		suite.duration += c.Duration
+
+		for _, a := range c.Assertions {
+			ac := junitCase{ClassName: class + "." + c.Name, Name: a.Name, Time: formatSeconds(0)}
+
+			if !a.Passed {
+				ac.Failure = &junitFailure{Message: a.Message, Body: a.Message}
+				suite.Failures++
+			} else if a.Message != "" {
+				ac.SystemOut = a.Message
+			}
+
+			suite.Cases = append(suite.Cases, ac)
+		}
+	}
+
+	for i := range suites.Suites {
+		suite := &suites.Suites[i]
+
+		suite.Tests = len(suite.Cases)
+		suite.Time = formatSeconds(suite.duration)
+
+		suites.Tests += suite.Tests
+		suites.Failures += suite.Failures
+		suites.Skipped += suite.Skipped
+	}
+
+	suites.Time = formatSeconds(this.End.Sub(this.Start).Seconds())
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return fmt.Errorf("writing JUnit XML: %w", err)
+	}
+
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+
+	if err := enc.Encode(suites); err != nil {
+		return fmt.Errorf("encoding JUnit XML: %w", err)
+	}
+
+	return nil
+}
+
+// CSVHeader is the header row written by `Run.CSV`.
+var CSVHeader = []string{
+	"id", "experiment", "run", "loop", "count", "params", "stage", "component",
+	"type", "status", "exit_code", "duration", "kind", "name", "value", "unit",
+	"message",
+}
+
+// CSV writes the run results to the given writer as CSV. Each component has a
+// row of kind `component`, followed by a row of kind `metric` or `assertion`
+// for each metric and assertion it emitted.
+func (this Run) CSV(w io.Writer) error {
+	enc := csv.NewWriter(w)
+
+	if err := enc.Write(CSVHeader); err != nil {
+		return fmt.Errorf("writing CSV header: %w", err)
+	}
+
+	for _, c := range this.Components {
+		prefix := []string{
+			this.ID, this.Experiment, strconv.Itoa(this.Run), strconv.Itoa(c.Loop),
+			strconv.Itoa(c.Count), c.Params, c.Stage, c.Name, c.Type, c.Status,
+			strconv.Itoa(c.ExitCode), formatSeconds(c.Duration),
+		}
+
+		rows := [][]string{{"component", "", "", "", c.Error}}
+
+		for _, m := range c.Metrics {
+			rows = append(rows, []string{"metric", m.Name, strconv.FormatFloat(m.Value, 'f', -1, 64), m.Unit, ""})
+		}
+
+		for _, a := range c.Assertions {
+			rows = append(rows, []string{"assertion", a.Name, passFail(a.Passed), "", a.Message})
+		}
+
+		for _, row := range rows {
+			if err := enc.Write(append(append([]string{}, prefix...), row...)); err != nil {
+				return fmt.Errorf("writing CSV row: %w", err)
+			}
+		}
+	}
+
+	enc.Flush()
+
+	if err := enc.Error(); err != nil {
+		return fmt.Errorf("writing CSV: %w", err)
+	}
+
+	return nil
+}
+
+func formatSeconds(s float64) string {
+	return strconv.FormatFloat(s, 'f', 3, 64)
+}
+
+func formatMetric(m Metric) string {
+	v := strconv.FormatFloat(m.Value, 'f', -1, 64)
+
+	if m.Unit == "" {
+		return v
+	}
+
+	return v + " " + m.Unit
+}
+
+func passFail(passed bool) string {
+	if passed {
+		return "pass"
+	}
+
+	return "fail"
+}
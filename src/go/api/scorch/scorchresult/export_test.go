@@ -0,0 +1,143 @@
+package scorchresult
+
+import (
+	"bytes"
+	"encoding/csv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testRun() *Run {
+	start := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	run := NewRun("foo", 0, "baseline", start)
+	run.End = start.Add(time.Minute)
+
+	run.Add(Component{
+		Name: "ping", Type: "user-shell", Stage: "start", Count: 0, Params: "delay=10ms",
+		Status: "success", Start: start, Duration: 1.5,
+		Metrics:    []Metric{{Name: "latency", Value: 12.5, Unit: "ms"}},
+		Assertions: []Assertion{{Name: "reachable", Passed: true}},
+	})
+
+	run.Add(Component{
+		Name: "iperf", Type: "user-shell", Stage: "start", Count: 0, Params: "delay=10ms",
+		Status: "failure", ExitCode: 2, Error: "bandwidth too low", Start: start,
+		Assertions: []Assertion{{Name: "bandwidth", Passed: false, Message: "5 < 10 Mbps"}},
+	})
+
+	run.Add(Component{Name: "collect", Type: "user-shell", Stage: "finally", Count: 1, Status: "skipped", Start: start})
+
+	return run
+}
+
+func TestRunJUnit(t *testing.T) {
+	var buf bytes.Buffer
+
+	if err := testRun().JUnit(&buf); err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+
+	out := buf.String()
+
+	expected := []string{
+		`<testsuites name="foo/baseline" tests="5" failures="2" skipped="1" time="60.000">`,
+		`<testsuite name="baseline loop-0-count-0 [delay=10ms]" tests="4" failures="2" skipped="0" time="1.500"`,
+		`<testcase classname="foo.baseline.start" name="ping" time="1.500">`,
+		`<property name="latency" value="12.5 ms"></property>`,
+		`<testcase classname="foo.baseline.start.iperf" name="bandwidth" time="0.000">`,
+		`<failure message="5 &lt; 10 Mbps">`,
+		`<testsuite name="baseline loop-0-count-1" tests="1" failures="0" skipped="1"`,
+	}
+
+	for _, e := range expected {
+		if !strings.Contains(out, e) {
+			t.Logf("expected JUnit XML to contain %s, got:\n%s", e, out)
+			t.FailNow()
+		}
+	}
+}
+
+func TestRunJUnitNestedLoops(t *testing.T) {
+	run := NewRun("foo", 0, "baseline", time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC))
+
+	for _, outer := range []int{0, 1} {
+		run.Add(Component{Name: "probe", Stage: "start", Loop: 1, Count: 0, Parents: []int{outer}, Status: "success"})
+	}
+
+	var buf bytes.Buffer
+
+	if err := run.JUnit(&buf); err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+
+	out := buf.String()
+
+	for _, e := range []string{`name="baseline loop-0-count-0/loop-1-count-0"`, `name="baseline loop-0-count-1/loop-1-count-0"`} {
+		if !strings.Contains(out, e) {
+			t.Logf("expected JUnit XML to contain %s, got:\n%s", e, out)
+			t.FailNow()
+		}
+	}
+}
+
+func TestRunCSV(t *testing.T) {
+	var buf bytes.Buffer
+
+	if err := testRun().CSV(&buf); err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+
+	rows, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+
+	// header, 3 components, 1 metric, 2 assertions
+	if len(rows) != 7 {
+		t.Logf("expected 7 rows, got %d", len(rows))
+		t.FailNow()
+	}
+
+	metric := strings.Join(rows[2], ",")
+
+	if metric != "run-0_2024-01-02T03-04-05Z,foo,0,0,0,delay=10ms,start,ping,user-shell,success,0,1.500,metric,latency,12.5,ms," {
+		t.Logf("unexpected metric row: %s", metric)
+		t.FailNow()
+	}
+}
+
+func TestDecode(t *testing.T) {
+	input := `{"metric": "latency", "value": 12.5, "unit": "ms"}
+
+{"assertion": "reachable", "passed": true}
+`
+
+	metrics, assertions, err := Decode(strings.NewReader(input))
+	if err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+
+	if len(metrics) != 1 || metrics[0] != (Metric{Name: "latency", Value: 12.5, Unit: "ms"}) {
+		t.Logf("unexpected metrics: %v", metrics)
+		t.FailNow()
+	}
+
+	if len(assertions) != 1 || !assertions[0].Passed {
+		t.Logf("unexpected assertions: %v", assertions)
+		t.FailNow()
+	}
+
+	for _, bad := range []string{`{"metric": "latency"}`, `{"value": 1}`, `not json`} {
+		if _, _, err := Decode(strings.NewReader(bad)); err == nil {
+			t.Logf("expected error decoding %s", bad)
+			t.FailNow()
+		}
+	}
+}
@@ -0,0 +1,109 @@
+package scorchresult
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// ErrResultsNotFound is returned when the requested SCORCH run results don't
+// exist.
+var ErrResultsNotFound = errors.New("scorch run results not found")
+
+// Dir returns the directory SCORCH run results are persisted to in the given
+// experiment files directory.
+func Dir(filesDir string) string {
+	return filepath.Join(filesDir, "scorch", "results")
+}
+
+// Save persists the given run results to the given experiment files directory.
+func Save(filesDir string, run *Run) error {
+	dir := Dir(filesDir)
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("creating scorch results directory %s: %w", dir, err)
+	}
+
+	return run.Write(filepath.Join(dir, run.ID+".json"))
+}
+
+// Write writes the given run results as JSON to the given path.
+func (this Run) Write(path string) error {
+	body, err := json.MarshalIndent(this, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshaling scorch run results: %w", err)
+	}
+
+	if err := os.WriteFile(path, body, 0644); err != nil {
+		return fmt.Errorf("writing scorch run results to %s: %w", path, err)
+	}
+
+	return nil
+}
+
+// List returns the results persisted to the given experiment files directory
+// for the given run, ordered by start time. Results for all runs are returned
+// if the given run is negative.
+func List(filesDir string, run int) ([]Run, error) {
+	entries, err := os.ReadDir(Dir(filesDir))
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, nil
+		}
+
+		return nil, fmt.Errorf("reading scorch results directory: %w", err)
+	}
+
+	var runs []Run
+
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != ".json" {
+			continue
+		}
+
+		r, err := Get(filesDir, strings.TrimSuffix(entry.Name(), ".json"))
+		if err != nil {
+			return nil, err
+		}
+
+		if run < 0 || r.Run == run {
+			runs = append(runs, *r)
+		}
+	}
+
+	sort.SliceStable(runs, func(i, j int) bool {
+		return runs[i].Start.Before(runs[j].Start)
+	})
+
+	return runs, nil
+}
+
+// Get returns the results with the given ID persisted to the given experiment
+// files directory.
+func Get(filesDir, id string) (*Run, error) {
+	if id == "" || filepath.Base(id) != id {
+		return nil, fmt.Errorf("invalid scorch results ID %s", id)
+	}
+
+	body, err := os.ReadFile(filepath.Join(Dir(filesDir), id+".json"))
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, fmt.Errorf("getting scorch results %s: %w", id, ErrResultsNotFound)
+		}
+
+		return nil, fmt.Errorf("reading scorch results %s: %w", id, err)
+	}
+
+	var run Run
+
+	if err := json.Unmarshal(body, &run); err != nil {
+		return nil, fmt.Errorf("parsing scorch results %s: %w", id, err)
+	}
+
+	return &run, nil
+}
@@ -0,0 +1,99 @@
+package scorchresult
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Run represents the results of a single execution of a SCORCH run.
+type Run struct {
+	ID         string      `json:"id"`
+	Experiment string      `json:"experiment"`
+	Run        int         `json:"run"`
+	Name       string      `json:"name"`
+	Status     string      `json:"status"` // success or failure
+	Start      time.Time   `json:"start"`
+	End        time.Time   `json:"end"`
+	Components []Component `json:"components"`
+}
+
+// NewRun returns results for the given experiment run started at the given
+// time. The ID of the results matches the timestamp used for the archive of
+// data generated by the run.
+func NewRun(exp string, run int, name string, start time.Time) *Run {
+	return &Run{
+		ID:         fmt.Sprintf("run-%d_%s", run, start.Format("2006-01-02T15-04-05Z0700")),
+		Experiment: exp,
+		Run:        run,
+		Name:       name,
+		Status:     "running",
+		Start:      start,
+	}
+}
+
+// Add adds the given component results to the run.
+func (this *Run) Add(c Component) {
+	this.Components = append(this.Components, c)
+}
+
+// Component represents the results of a component executed in a stage of a
+// loop iteration.
+type Component struct {
+	Name       string      `json:"name"`
+	Type       string      `json:"type"`
+	Stage      string      `json:"stage"`
+	Loop       int         `json:"loop"`
+	Count      int         `json:"count"`
+	Parents    []int       `json:"parents,omitempty"` // counts of the loops the loop is nested in, outermost first
+	Params     string      `json:"params,omitempty"`
+	Status     string      `json:"status"` // success, failure, or skipped
+	ExitCode   int         `json:"exitCode"`
+	Error      string      `json:"error,omitempty"`
+	Start      time.Time   `json:"start"`
+	Duration   float64     `json:"duration"` // seconds
+	Metrics    []Metric    `json:"metrics,omitempty"`
+	Assertions []Assertion `json:"assertions,omitempty"`
+}
+
+// Iteration returns a name for the loop iteration the component was executed
+// in, including the iterations of the loops it is nested in (e.g.
+// `loop-0-count-1`, `loop-0-count-1/loop-1-count-0`, or
+// `loop-0-count-1 [delay=10ms]`).
+func (this Component) Iteration() string {
+	var loops []string
+
+	for i, count := range this.Parents {
+		loops = append(loops, fmt.Sprintf("loop-%d-count-%d", i, count))
+	}
+
+	loops = append(loops, fmt.Sprintf("loop-%d-count-%d", this.Loop, this.Count))
+
+	name := strings.Join(loops, "/")
+
+	if this.Params != "" {
+		name += " [" + this.Params + "]"
+	}
+
+	return name
+}
+
+// key uniquely identifies a component execution within a run.
+func (this Component) key() string {
+	return strings.Join([]string{this.Iteration(), this.Stage, this.Name}, "|")
+}
+
+// Metric is a named value emitted by a component.
+type Metric struct {
+	Name  string  `json:"name"`
+	Value float64 `json:"value"`
+	Unit  string  `json:"unit,omitempty"`
+}
+
+// Assertion is a named pass/fail check emitted by a component. A component
+// with a failed assertion is considered to have failed.
+type Assertion struct {
+	Name    string `json:"name"`
+	Passed  bool   `json:"passed"`
+	Message string `json:"message,omitempty"`
+}
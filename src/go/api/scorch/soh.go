@@ -14,6 +14,7 @@ import (
 	"phenix/util/plog"
 	"phenix/web/scorch"
 
+	"github.com/hashicorp/go-multierror"
 	"github.com/mitchellh/mapstructure"
 )
 
@@ -168,7 +169,7 @@ func (this SOH) check(ctx context.Context, stage Action) error {
 
 	var (
 		runDir = filepath.Join(exp.FilesDir(), "scorch", fmt.Sprintf("run-%d", this.options.Run))
-		path   = filepath.Join(runDir, this.options.Name, fmt.Sprintf("loop-%d-count-%d", this.options.Loop, this.options.Count), "soh.json")
+		path   = filepath.Join(runDir, this.options.Name, this.options.IterationName(), "soh.json")
 	)
 
 	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
@@ -181,6 +182,16 @@ func (this SOH) check(ctx context.Context, stage Action) error {
 		updateComponent(fmt.Sprintf("State of health results written to %s\n", path))
 	}
 
+	var errCount int
+
+	if merr, ok := appErr.(*multierror.Error); ok {
+		errCount = len(merr.Errors)
+	} else if appErr != nil {
+		errCount = 1
+	}
+
+	this.options.Result.Metric("errors", float64(errCount), "")
+
 	if appErr != nil && md.FailOnError {
 		return fmt.Errorf("state of health checks: %w", appErr)
 	}
@@ -58,14 +58,23 @@ func (this Tap) Start(ctx context.Context) error {
 		return fmt.Errorf("setting up tap: %w", err)
 	}
 
-	var status scorchmd.ScorchStatus
-	if err := this.options.Exp.Status.ParseAppStatus("scorch", &status); err != nil {
+	var err error
+
+	this.options.Exp.Modify(func() {
+		var status scorchmd.ScorchStatus
+		if err = this.options.Exp.Status.ParseAppStatus("scorch", &status); err != nil {
+			return
+		}
+
+		status.Taps[this.options.Name] = t
+
+		this.options.Exp.Status.SetAppStatus("scorch", status)
+	})
+
+	if err != nil {
 		return fmt.Errorf("getting experiment status for scorch app: %w", err)
 	}
 
-	status.Taps[this.options.Name] = t
-
-	this.options.Exp.Status.SetAppStatus("scorch", status)
 	this.options.Exp.WriteToStore(true)
 
 	return nil
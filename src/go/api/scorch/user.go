@@ -3,14 +3,20 @@ package scorch
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"os"
+	"os/exec"
 	"strconv"
 
+	"phenix/api/scorch/scorchresult"
 	"phenix/store"
 	"phenix/util"
 	"phenix/util/common"
 	"phenix/util/shell"
 	"phenix/web/scorch"
+
+	"github.com/hashicorp/go-multierror"
 )
 
 var ErrUserComponentNotFound = fmt.Errorf("user component not found")
@@ -89,12 +95,24 @@ func (this UserComponent) run(ctx context.Context, stage Action, cmd string, dat
 		Run:     this.options.Run,
 		Loop:    this.options.Loop,
 		Count:   this.options.Count,
-		Stage:   string(stage),
+		Params:  this.options.Params.String(),
+		Stage:   string(this.options.Stage), // differs from `stage` in the finally stage
 		Status:  "running",
 	}
 
 	stdout := make(chan []byte)
 
+	// User components record metrics and assertions by writing them, one JSON
+	// object per line, to the file given in the PHENIX_SCORCH_RESULTS
+	// environment variable.
+	results, err := os.CreateTemp("", "phenix-scorch-results-")
+	if err != nil {
+		return fmt.Errorf("creating results file for user component %s: %w", this.options.Name, err)
+	}
+
+	results.Close()
+	defer os.Remove(results.Name())
+
 	opts := []shell.Option{
 		shell.Command(cmd),
 		shell.Args(string(stage), this.options.Name, strconv.Itoa(this.options.Run), strconv.Itoa(this.options.Loop), strconv.Itoa(this.options.Count)),
@@ -107,7 +125,11 @@ func (this UserComponent) run(ctx context.Context, stage Action, cmd string, dat
 			"PHENIX_LOG_FILE="+util.GetEnv("PHENIX_LOG_FILE", common.LogFile),
 			"PHENIX_DRYRUN="+strconv.FormatBool(this.options.Exp.DryRun()),
 			"PHENIX_SCORCH_STARTTIME="+this.options.StartTime,
+			"PHENIX_SCORCH_ITERATION="+this.options.IterationName(),
+			"PHENIX_SCORCH_PARAMS="+this.options.Params.String(),
+			"PHENIX_SCORCH_RESULTS="+results.Name(),
 		),
+		shell.Env(this.options.Params.Env()...),
 	}
 
 	go func() {
@@ -118,6 +140,17 @@ func (this UserComponent) run(ctx context.Context, stage Action, cmd string, dat
 	}()
 
 	stdoutBytes, stderrBytes, err := shell.ExecCommand(ctx, opts...)
+
+	if this.options.Result != nil {
+		this.options.Result.Output = string(stdoutBytes)
+		this.options.Result.ExitCode = exitCode(err)
+
+		// Failure of the command itself takes precedence over invalid results.
+		if resultsErr := this.readResults(results.Name()); resultsErr != nil && err == nil {
+			return fmt.Errorf("reading results of external user component %s (command %s): %w", this.options.Type, cmd, resultsErr)
+		}
+	}
+
 	if err != nil {
 		// FIXME: improve on this
 		fmt.Println(string(stderrBytes))
@@ -140,3 +173,45 @@ func (this UserComponent) run(ctx context.Context, stage Action, cmd string, dat
 
 	return nil
 }
+
+// readResults adds the metrics and assertions written by the user component to
+// the given results file to the component's result.
+func (this UserComponent) readResults(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("opening results file: %w", err)
+	}
+
+	defer f.Close()
+
+	metrics, assertions, err := scorchresult.Decode(f)
+	if err != nil {
+		return err
+	}
+
+	this.options.Result.Metrics = append(this.options.Result.Metrics, metrics...)
+	this.options.Result.Assertions = append(this.options.Result.Assertions, assertions...)
+
+	return nil
+}
+
+// exitCode returns the exit code of the command that produced the given error
+// (returned from `shell.ExecCommand`), or 0 if it didn't exit with an error.
+func exitCode(err error) int {
+	errs := []error{err}
+
+	// The version of go-multierror in use doesn't support `errors.As`.
+	if merr, ok := err.(*multierror.Error); ok {
+		errs = merr.Errors
+	}
+
+	for _, err := range errs {
+		var exitErr *exec.ExitError
+
+		if errors.As(err, &exitErr) {
+			return exitErr.ExitCode()
+		}
+	}
+
+	return 0
+}
@@ -0,0 +1,191 @@
+package scorch
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"phenix/api/vm"
+	"phenix/web/scorch"
+
+	"github.com/mitchellh/mapstructure"
+)
+
+// VMMetadata represents the metadata for the `vm` component, which changes
+// the state of VMs in the experiment. Target VMs are selected by hostname or
+// glob pattern (`vms`) and/or by topology labels (`labels`), and the action to
+// take is configured per stage.
+//
+//	metadata:
+//	  vms: [server]
+//	  configure:
+//	    action: snapshot
+//	  start:
+//	    action: kill
+//	  cleanup:
+//	    action: restore
+type VMMetadata struct {
+	VMs       []string  `mapstructure:"vms"`
+	Labels    []string  `mapstructure:"labels"`
+	Configure *VMAction `mapstructure:"configure"`
+	Start     *VMAction `mapstructure:"start"`
+	Stop      *VMAction `mapstructure:"stop"`
+	Cleanup   *VMAction `mapstructure:"cleanup"`
+}
+
+// VMAction represents the action the `vm` component takes in a stage. The
+// action is one of start, stop, pause, resume, restart, kill, snapshot,
+// restore, or redeploy. The snapshot name is used by the snapshot and restore
+// actions, and defaults to `scorch-<component name>` so a snapshot taken in
+// one stage can be restored in another without naming it. The CPU, memory,
+// disk, and inject settings are used by the redeploy action.
+type VMAction struct {
+	Action   string `mapstructure:"action"`
+	Snapshot string `mapstructure:"snapshot"`
+	CPU      int    `mapstructure:"cpu"`
+	Memory   int    `mapstructure:"memory"`
+	Disk     string `mapstructure:"disk"`
+	Inject   bool   `mapstructure:"inject"`
+}
+
+type VM struct {
+	options Options
+}
+
+func (this *VM) Init(opts ...Option) error {
+	this.options = NewOptions(opts...)
+	return nil
+}
+
+func (VM) Type() string {
+	return "vm"
+}
+
+func (this VM) Configure(ctx context.Context) error {
+	return this.run(ctx, ACTIONCONFIG)
+}
+
+func (this VM) Start(ctx context.Context) error {
+	return this.run(ctx, ACTIONSTART)
+}
+
+func (this VM) Stop(ctx context.Context) error {
+	return this.run(ctx, ACTIONSTOP)
+}
+
+func (this VM) Cleanup(ctx context.Context) error {
+	return this.run(ctx, ACTIONCLEANUP)
+}
+
+func (this VM) run(ctx context.Context, stage Action) error {
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+
+	var md VMMetadata
+
+	if err := mapstructure.Decode(this.options.Meta, &md); err != nil {
+		return fmt.Errorf("decoding vm component metadata: %w", err)
+	}
+
+	var action *VMAction
+
+	switch stage {
+	case ACTIONCONFIG:
+		action = md.Configure
+	case ACTIONSTART:
+		action = md.Start
+	case ACTIONSTOP:
+		action = md.Stop
+	case ACTIONCLEANUP:
+		action = md.Cleanup
+	}
+
+	if action == nil {
+		return nil
+	}
+
+	nodes, err := targetVMs(this.options.Exp.Spec.Topology(), md.VMs, md.Labels)
+	if err != nil {
+		return fmt.Errorf("finding target VMs for vm component: %w", err)
+	}
+
+	update := scorch.ComponentUpdate{
+		Exp:     this.options.Exp.Spec.ExperimentName(),
+		CmpName: this.options.Name,
+		CmpType: this.options.Type,
+		Run:     this.options.Run,
+		Loop:    this.options.Loop,
+		Count:   this.options.Count,
+		Params:  this.options.Params.String(),
+		Stage:   string(this.options.Stage),
+		Status:  "running",
+	}
+
+	for _, node := range nodes {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		host := node.General().Hostname()
+
+		update.Output = []byte(fmt.Sprintf("[%s] %s\n", host, action.Action))
+		scorch.UpdateComponent(update)
+
+		start := time.Now()
+
+		if err := this.do(action, host, update); err != nil {
+			return fmt.Errorf("running %s action for VM %s: %w", action.Action, host, err)
+		}
+
+		this.options.Result.Metric(fmt.Sprintf("%s %s duration", host, action.Action), time.Since(start).Seconds(), "s")
+	}
+
+	return nil
+}
+
+func (this VM) do(action *VMAction, host string, update scorch.ComponentUpdate) error {
+	var (
+		exp  = this.options.Exp.Spec.ExperimentName()
+		snap = action.Snapshot
+	)
+
+	if snap == "" {
+		snap = "scorch-" + this.options.Name
+	}
+
+	switch strings.ToLower(action.Action) {
+	case "start", "resume":
+		return vm.Resume(exp, host)
+	case "stop":
+		return vm.Shutdown(exp, host)
+	case "pause":
+		return vm.Pause(exp, host)
+	case "restart":
+		return vm.Restart(exp, host)
+	case "kill":
+		return vm.Kill(exp, host)
+	case "snapshot":
+		cb := func(status string) {
+			update.Output = []byte(fmt.Sprintf("[%s] snapshot %s\n", host, status))
+			scorch.UpdateComponent(update)
+		}
+
+		return vm.Snapshot(exp, host, snap, cb)
+	case "restore":
+		// Snapshots are named for the VM they were taken of.
+		return vm.Restore(exp, host, fmt.Sprintf("%s__%s", host, snap))
+	case "redeploy":
+		opts := []vm.RedeployOption{
+			vm.CPU(action.CPU),
+			vm.Memory(action.Memory),
+			vm.Disk(action.Disk),
+			vm.Inject(action.Inject),
+		}
+
+		return vm.Redeploy(exp, host, opts...)
+	default:
+		return fmt.Errorf("unknown vm component action '%s'", action.Action)
+	}
+}
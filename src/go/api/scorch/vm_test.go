@@ -0,0 +1,91 @@
+package scorch
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"phenix/types"
+	v1 "phenix/types/version/v1"
+	"phenix/util/mm"
+	"phenix/util/mm/mmfake"
+	"phenix/web/scorch"
+)
+
+func TestVMDo(t *testing.T) {
+	cluster := mmfake.New(mmfake.Headnode("compute0"))
+	mm.DefaultMM = cluster
+
+	script := filepath.Join(t.TempDir(), "foo.mm")
+
+	if err := os.WriteFile(script, []byte("namespace foo\nvm config disk base.qc2\nvm launch kvm server\nvm start server\n"), 0600); err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+
+	if err := cluster.ReadScriptFromFile(script); err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+
+	var (
+		exp = types.Experiment{Spec: &v1.ExperimentSpec{ExperimentNameF: "foo"}}
+		cmp = VM{options: NewOptions(Experiment(exp), Name("vms"))}
+	)
+
+	tests := []struct {
+		action   VMAction
+		expected []string
+	}{
+		{VMAction{Action: "pause"}, []string{"namespace foo vm stop server"}},
+		{VMAction{Action: "start"}, []string{"namespace foo vm start server"}},
+		{VMAction{Action: "pause"}, []string{"namespace foo vm stop server"}},
+		{VMAction{Action: "Resume"}, []string{"namespace foo vm start server"}},
+		{VMAction{Action: "redeploy", Disk: "new.qc2"}, []string{"namespace foo vm kill server", "namespace foo vm launch kvm server", "namespace foo vm start server"}},
+		{VMAction{Action: "kill"}, []string{"namespace foo vm kill server", "namespace foo vm flush"}},
+	}
+
+	for _, test := range tests {
+		before := len(cluster.History())
+
+		if err := cmp.do(&test.action, "server", scorch.ComponentUpdate{}); err != nil {
+			t.Logf("running %s action: %v", test.action.Action, err)
+			t.FailNow()
+		}
+
+		history := strings.Join(cluster.History()[before:], "\n")
+
+		for _, e := range test.expected {
+			if !strings.Contains(history, e) {
+				t.Logf("expected %s action to run '%s', got:\n%s", test.action.Action, e, history)
+				t.FailNow()
+			}
+		}
+
+		if test.action.Action == "redeploy" {
+			if vms := mm.GetVMInfo(mm.NS("foo"), mm.VMName("server")); len(vms) != 1 || vms[0].Disk != "new.qc2" {
+				t.Logf("expected server to be redeployed with disk new.qc2, got %+v", vms)
+				t.FailNow()
+			}
+		}
+	}
+
+	if vms := mm.GetVMInfo(mm.NS("foo"), mm.VMName("server")); len(vms) != 0 {
+		t.Logf("expected server to be killed, got %+v", vms)
+		t.FailNow()
+	}
+
+	before := len(cluster.History())
+
+	err := cmp.do(&VMAction{Action: "explode"}, "server", scorch.ComponentUpdate{})
+	if err == nil || err.Error() != "unknown vm component action 'explode'" {
+		t.Logf("expected unknown action error, got %v", err)
+		t.FailNow()
+	}
+
+	if len(cluster.History()) != before {
+		t.Logf("expected unknown action not to run any commands, got %v", cluster.History()[before:])
+		t.FailNow()
+	}
+}
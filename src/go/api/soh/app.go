@@ -579,35 +579,41 @@ func (this *SOH) gatherNodeIPs(node ifaces.NodeSpec) {
 }
 
 func (this SOH) writeResults(exp *types.Experiment) {
-	// we do this to make sure we don't overwrite the `initialized` status
-	status := make(map[string]any)
-	exp.Status.ParseAppStatus("soh", &status)
+	var states []map[string]any
 
-	if len(this.status) > 0 {
-		var states []map[string]any
+	for _, state := range this.status {
+		states = append(states, structs.Map(state))
+	}
+
+	exp.Modify(func() {
+		// we do this to make sure we don't overwrite the `initialized` status
+		status := make(map[string]any)
+		exp.Status.ParseAppStatus("soh", &status)
 
-		for _, state := range this.status {
-			states = append(states, structs.Map(state))
+		if len(states) > 0 {
+			status["hosts"] = states
 		}
 
-		status["hosts"] = states
-	}
+		if len(this.packetCapture) > 0 {
+			status["packetCapture"] = this.packetCapture
+		}
 
-	if len(this.packetCapture) > 0 {
-		status["packetCapture"] = this.packetCapture
-	}
+		exp.Status.SetAppStatus("soh", status)
+	})
 
-	exp.Status.SetAppStatus("soh", status)
 	exp.WriteToStore(true)
 }
 
 func (this SOH) writeInitialized(exp *types.Experiment) {
-	// we do this to make sure we don't overwrite the existing app status
-	status := make(map[string]any)
-	exp.Status.ParseAppStatus("soh", &status)
+	exp.Modify(func() {
+		// we do this to make sure we don't overwrite the existing app status
+		status := make(map[string]any)
+		exp.Status.ParseAppStatus("soh", &status)
+
+		status["initialized"] = true
 
-	status["initialized"] = true
+		exp.Status.SetAppStatus("soh", status)
+	})
 
-	exp.Status.SetAppStatus("soh", status)
 	exp.WriteToStore(true)
 }
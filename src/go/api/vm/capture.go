@@ -7,6 +7,7 @@ import (
 	"path/filepath"
 
 	"phenix/api/experiment"
+	"phenix/api/quota"
 	"phenix/util/mm"
 )
 
@@ -19,7 +20,7 @@ var (
 // in the given experiment. The captured packets are written to the experiment's
 // files directory using the base name of the provided output file in PCAP
 // format. It returns any errors encountered while starting the packet capture.
-func StartCapture(expName, vmName string, iface int, out string) error {
+func StartCapture(expName, vmName string, iface int, out string, opts ...CaptureOption) error {
 	if expName == "" {
 		return fmt.Errorf("no experiment name provided")
 	}
@@ -49,6 +50,14 @@ func StartCapture(expName, vmName string, iface int, out string) error {
 		return fmt.Errorf("cannot capture on a disconnected interface")
 	}
 
+	o := newCaptureOptions(opts...)
+
+	defer quota.Lock(o.user)()
+
+	if err := quota.Check(o.user, quota.Usage{Captures: 1}); err != nil {
+		return fmt.Errorf("starting capture on VM %s in experiment %s: %w", vmName, expName, err)
+	}
+
 	if ext := filepath.Ext(out); ext != ".pcap" {
 		out = out + ".pcap"
 	}
@@ -18,6 +18,7 @@ type updateOptions struct {
 	iface     *iface
 	host      *string
 	snapshot  *bool
+	user      string
 }
 
 func newUpdateOptions(opts ...UpdateOption) updateOptions {
@@ -84,6 +85,14 @@ func UpdateWithSnapshot(b bool) UpdateOption {
 	}
 }
 
+// UpdateWithUser sets the user updating the VM, whose quota the updated VM's
+// resources are checked against.
+func UpdateWithUser(u string) UpdateOption {
+	return func(o *updateOptions) {
+		o.user = u
+	}
+}
+
 func UpdateWithHost(h string) UpdateOption {
 	return func(o *updateOptions) {
 		o.host = &h
@@ -100,6 +109,7 @@ type redeployOptions struct {
 	disk   string
 	inject bool
 	part   int
+	user   string
 }
 
 func newRedeployOptions(opts ...RedeployOption) redeployOptions {
@@ -155,3 +165,36 @@ func InjectPartition(p int) RedeployOption {
 		o.part = p
 	}
 }
+
+// User sets the user redeploying the VM, whose quota any additional resources
+// used by the redeployed VM are checked against.
+func User(u string) RedeployOption {
+	return func(o *redeployOptions) {
+		o.user = u
+	}
+}
+
+// CaptureOption is a function that configures options for a VM packet capture.
+type CaptureOption func(*captureOptions)
+
+type captureOptions struct {
+	user string
+}
+
+func newCaptureOptions(opts ...CaptureOption) captureOptions {
+	var o captureOptions
+
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	return o
+}
+
+// CaptureWithUser sets the user starting the capture, whose quota the capture
+// is checked against.
+func CaptureWithUser(u string) CaptureOption {
+	return func(o *captureOptions) {
+		o.user = u
+	}
+}
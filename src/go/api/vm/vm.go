@@ -10,11 +10,11 @@ import (
 	"os/exec"
 	"path/filepath"
 	"regexp"
-	"strconv"
 	"strings"
 	"time"
 
 	"phenix/api/experiment"
+	"phenix/api/quota"
 	"phenix/util"
 	"phenix/util/common"
 	"phenix/util/file"
@@ -342,6 +342,17 @@ func Update(opts ...UpdateOption) error {
 		vm.General().SetSnapshot(*o.snapshot)
 	}
 
+	// Make sure the experiment can still be started with the updated VM without
+	// exceeding the user's quota. The experiment itself is excluded from the
+	// user's current usage so it isn't counted twice if it's already running.
+	if o.cpu != 0 || o.mem != 0 {
+		usage := quota.ExperimentUsage(exp)
+
+		if err := quota.Check(o.user, quota.Usage{VCPUs: usage.VCPUs, Memory: usage.Memory}, o.exp); err != nil {
+			return fmt.Errorf("updating VM %s in experiment %s: %w", o.vm, o.exp, err)
+		}
+	}
+
 	err = experiment.Save(experiment.SaveWithName(o.exp), experiment.SaveWithSpec(exp.Spec))
 	if err != nil {
 		return fmt.Errorf("unable to save experiment with updated VM: %w", err)
@@ -607,6 +618,33 @@ func Redeploy(expName, vmName string, opts ...RedeployOption) error {
 
 	o := newRedeployOptions(opts...)
 
+	if o.cpu != 0 || o.mem != 0 {
+		current, err := Get(expName, vmName)
+		if err != nil {
+			return fmt.Errorf("getting VM details: %w", err)
+		}
+
+		// Hold the user's quota until the VM is redeployed, at which point its
+		// new resources are included in the user's usage.
+		defer quota.Lock(o.user)()
+
+		// Only the additional resources used by the redeployed VM count against
+		// the user's quota, since the current VM is already accounted for.
+		var requested quota.Usage
+
+		if o.cpu != 0 {
+			requested.VCPUs = o.cpu - current.CPUs
+		}
+
+		if o.mem != 0 {
+			requested.Memory = o.mem - current.RAM
+		}
+
+		if err := quota.Check(o.user, requested); err != nil {
+			return fmt.Errorf("redeploying VM %s in experiment %s: %w", vmName, expName, err)
+		}
+	}
+
 	var injects []string
 
 	if o.inject {
@@ -701,137 +739,21 @@ func Snapshot(expName, vmName, out string, cb func(string)) error {
 	out = strings.TrimSuffix(out, filepath.Ext(out))
 	out = fmt.Sprintf("%s_%s__%s", expName, vmName, out)
 
-	// ***** BEGIN: SNAPSHOT VM *****
-
-	// Get minimega's snapshot path for VM
-
-	cmd := mmcli.NewNamespacedCommand(expName)
-	cmd.Command = "vm info"
-	cmd.Columns = []string{"host", "id"}
-	cmd.Filters = []string{"name=" + vmName}
-
-	status := mmcli.RunTabular(cmd)
-
-	if len(status) == 0 {
-		return fmt.Errorf("VM %s not found", vmName)
-	}
-
-	cmd.Columns = nil
-	cmd.Filters = nil
-
-	var (
-		host = status[0]["host"]
-		fp   = fmt.Sprintf("%s/%s", common.MinimegaBase, status[0]["id"])
-	)
-
-	qmp := fmt.Sprintf(`{ "execute": "query-block" }`)
-	cmd.Command = fmt.Sprintf("vm qmp %s '%s'", vmName, qmp)
-
-	res, err := mmcli.SingleResponse(mmcli.Run(cmd))
-	if err != nil {
-		return fmt.Errorf("querying for block device details for VM %s: %w", vmName, err)
-	}
-
-	var v map[string][]mm.BlockDevice
-	json.Unmarshal([]byte(res), &v)
-
-	var device string
-
-	for _, dev := range v["return"] {
-		if dev.Inserted != nil {
-			if strings.HasPrefix(dev.Inserted.File, fp) {
-				device = dev.Device
-				break
-			}
-		}
-	}
-
-	target := fmt.Sprintf("%s/images/%s.qc2", common.PhenixBase, out)
-
-	qmp = fmt.Sprintf(`{ "execute": "drive-backup", "arguments": { "device": "%s", "sync": "top", "target": "%s" } }`, device, target)
-	cmd.Command = fmt.Sprintf(`vm qmp %s '%s'`, vmName, qmp)
-
-	if err := mmcli.ErrorResponse(mmcli.Run(cmd)); err != nil {
-		return fmt.Errorf("starting disk snapshot for VM %s: %w", vmName, err)
-	}
-
-	qmp = fmt.Sprintf(`{ "execute": "query-block-jobs" }`)
-	cmd.Command = fmt.Sprintf(`vm qmp %s '%s'`, vmName, qmp)
-
-	for {
-		res, err := mmcli.SingleResponse(mmcli.Run(cmd))
-		if err != nil {
-			return fmt.Errorf("querying for block device jobs for VM %s: %w", vmName, err)
-		}
-
-		var v map[string][]mm.BlockDeviceJobs
-		json.Unmarshal([]byte(res), &v)
-
-		if len(v["return"]) == 0 {
-			break
-		}
-
-		for _, job := range v["return"] {
-			if job.Device != device {
-				continue
-			}
-
-			if cb != nil {
-				// Cut progress in half since drive backup is 1 of 2 steps.
-				progress := float64(job.Offset) / float64(job.Length)
-				progress = progress * 0.5
+	opts := []mm.Option{mm.NS(expName), mm.VMName(vmName), mm.SnapshotFile(out)}
 
-				cb(fmt.Sprintf("%f", progress))
-			}
-		}
-
-		time.Sleep(1 * time.Second)
+	if cb != nil {
+		opts = append(opts, mm.SnapshotProgress(func(p float64) { cb(fmt.Sprintf("%f", p)) }))
 	}
 
-	// ***** END: SNAPSHOT VM *****
-
-	// ***** BEGIN: MIGRATE VM *****
-
-	cmd.Command = fmt.Sprintf("vm migrate %s %s.SNAP", vmName, out)
-
-	if err := mmcli.ErrorResponse(mmcli.Run(cmd)); err != nil {
-		return fmt.Errorf("starting memory snapshot for VM %s: %w", vmName, err)
+	if err := mm.SnapshotVM(opts...); err != nil {
+		return err
 	}
 
-	cmd.Command = "vm migrate"
-	cmd.Columns = []string{"name", "status", "complete (%)"}
-	cmd.Filters = []string{"name=" + vmName}
-	//Adding a 1 second delay before calling "vm migrate"
-	//for a status update appears to prevent the status call
-	//from crashing minimega
-	time.Sleep(1 * time.Second)
-	for {
-		status := mmcli.RunTabular(cmd)[0]
-
-		if cb != nil {
-			if status["status"] == "completed" {
-				cb("completed")
-			} else {
-				// Cut progress in half and add 0.5 to it since migrate is 2 of 2 steps.
-				progress, _ := strconv.ParseFloat(status["complete (%)"], 64)
-				progress = 0.5 + (progress * 0.5)
-
-				cb(fmt.Sprintf("%f", progress))
-			}
-		}
-
-		if status["status"] == "completed" {
-			break
-		}
-
-		time.Sleep(1 * time.Second)
+	if cb != nil {
+		cb("completed")
 	}
 
-	// ***** END: MIGRATE VM *****
-
-	cmd.Command = fmt.Sprintf("vm start %s", vmName)
-
-	if err := mmcli.ErrorResponse(mmcli.Run(cmd)); err != nil {
+	if err := mm.StartVM(mm.NS(expName), mm.VMName(vmName)); err != nil {
 		return fmt.Errorf("resuming VM %s after snapshot: %w", vmName, err)
 	}
 
@@ -840,11 +762,11 @@ func Snapshot(expName, vmName, out string, cb func(string)) error {
 		cmdPrefix string
 	)
 
-	if !mm.IsHeadnode(host) {
-		cmdPrefix = "mesh send " + host
+	if !mm.IsHeadnode(vm.Host) {
+		cmdPrefix = "mesh send " + vm.Host
 	}
 
-	cmd = mmcli.NewCommand()
+	cmd := mmcli.NewCommand()
 	cmd.Command = fmt.Sprintf("%s shell mkdir -p %s", cmdPrefix, dst)
 
 	if err := mmcli.ErrorResponse(mmcli.Run(cmd)); err != nil {
@@ -892,54 +814,11 @@ func Restore(expName, vmName, snap string) error {
 
 	snap = fmt.Sprintf("%s/files/%s", expName, snap)
 
-	cmd := mmcli.NewNamespacedCommand(expName)
-	cmd.Command = fmt.Sprintf("vm config clone %s", vmName)
-
-	if err := mmcli.ErrorResponse(mmcli.Run(cmd)); err != nil {
-		return fmt.Errorf("cloning config for VM %s: %w", vmName, err)
-	}
-
-	cmd.Command = fmt.Sprintf("vm config migrate %s.SNAP", snap)
-
-	if err := mmcli.ErrorResponse(mmcli.Run(cmd)); err != nil {
-		return fmt.Errorf("configuring migrate file for VM %s: %w", vmName, err)
+	if err := mm.RestoreVM(mm.NS(expName), mm.VMName(vmName), mm.SnapshotFile(snap)); err != nil {
+		return err
 	}
 
-	cmd.Command = fmt.Sprintf("vm config disk %s.qc2,writeback", snap)
-
-	if err := mmcli.ErrorResponse(mmcli.Run(cmd)); err != nil {
-		return fmt.Errorf("configuring disk file for VM %s: %w", vmName, err)
-	}
-
-	cmd.Command = fmt.Sprintf("vm kill %s", vmName)
-
-	if err := mmcli.ErrorResponse(mmcli.Run(cmd)); err != nil {
-		return fmt.Errorf("killing VM %s: %w", vmName, err)
-	}
-
-	// TODO: explicitly flush killed VM by name once we start using that version
-	// of minimega.
-	cmd.Command = "vm flush"
-
-	if err := mmcli.ErrorResponse(mmcli.Run(cmd)); err != nil {
-		return fmt.Errorf("flushing VMs: %w", err)
-	}
-
-	cmd.Command = fmt.Sprintf("vm launch kvm %s", vmName)
-
-	if err := mmcli.ErrorResponse(mmcli.Run(cmd)); err != nil {
-		return fmt.Errorf("relaunching VM %s: %w", vmName, err)
-	}
-
-	cmd.Command = "vm launch"
-
-	if err := mmcli.ErrorResponse(mmcli.Run(cmd)); err != nil {
-		return fmt.Errorf("scheduling VM %s: %w", vmName, err)
-	}
-
-	cmd.Command = fmt.Sprintf("vm start %s", vmName)
-
-	if err := mmcli.ErrorResponse(mmcli.Run(cmd)); err != nil {
+	if err := mm.StartVM(mm.NS(expName), mm.VMName(vmName)); err != nil {
 		return fmt.Errorf("starting VM %s: %w", vmName, err)
 	}
 
@@ -1274,7 +1153,7 @@ func MemorySnapshot(expName, vmName, out string, cb func(string)) (string, error
 			if cb != nil {
 				cb("failed")
 			}
-			return "", fmt.Errorf("no status available for %s: %s", vmName, v)
+			return "", fmt.Errorf("no status available for %s: %v", vmName, v)
 
 		}
 
@@ -1282,7 +1161,7 @@ func MemorySnapshot(expName, vmName, out string, cb func(string)) (string, error
 			if cb != nil {
 				cb("failed")
 			}
-			return "failed", fmt.Errorf("failed to create memory snapshot for %s: %s", vmName, v)
+			return "failed", fmt.Errorf("failed to create memory snapshot for %s: %v", vmName, v)
 
 		}
 
@@ -0,0 +1,162 @@
+package vm
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"phenix/api/experiment"
+	"phenix/api/quota"
+	"phenix/store"
+	"phenix/util/common"
+	"phenix/util/file"
+	"phenix/util/mm"
+	"phenix/util/mm/mmfake"
+)
+
+// startExperiment starts an experiment named foo with a single VM against a
+// fake minimega cluster, restoring the default minimega implementation when
+// the test finishes.
+func startExperiment(t *testing.T, user string) *mmfake.Minimega {
+	base := t.TempDir()
+
+	common.PhenixBase = base
+	store.DefaultStore = store.NewBoltDB()
+
+	if err := store.DefaultStore.Init(store.Endpoint("bolt://" + filepath.Join(base, "phenix.bdb"))); err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+
+	cluster := mmfake.New(
+		mmfake.Headnode("compute0"),
+		mmfake.ClusterHost(mm.Host{Name: "compute0", CPUs: 8, MemTotal: 16384}),
+	)
+
+	var (
+		defaultMM    = mm.DefaultMM
+		defaultFiles = file.DefaultClusterFiles
+	)
+
+	mm.DefaultMM = cluster
+	file.DefaultClusterFiles = cluster
+
+	t.Cleanup(func() {
+		mm.DefaultMM = defaultMM
+		file.DefaultClusterFiles = defaultFiles
+	})
+
+	// The startup app keeps VMs from booting if their disk image is missing.
+	image := filepath.Join(base, "images", "linux.qc2")
+
+	if err := os.MkdirAll(filepath.Dir(image), 0755); err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+
+	if err := os.WriteFile(image, nil, 0644); err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+
+	configs := []*store.Config{
+		{
+			Version:  "phenix.sandia.gov/v1",
+			Kind:     "User",
+			Metadata: store.ConfigMetadata{Name: "foo@bar.com"},
+			Spec: map[string]any{
+				"username": "foo@bar.com",
+				"quota":    map[string]any{"maxVCPUs": 4, "maxMemory": 4096},
+			},
+		},
+		{
+			Version:  "phenix.sandia.gov/v1",
+			Kind:     "Experiment",
+			Metadata: store.ConfigMetadata{Name: "foo"},
+			Spec: map[string]any{
+				"experimentName": "foo",
+				"baseDir":        filepath.Join(base, "experiments", "foo"),
+				"defaultBridge":  "phenix",
+				"vlans":          map[string]any{"aliases": map[string]any{"EXP": 0}},
+				"topology": map[string]any{
+					"nodes": []any{
+						map[string]any{
+							"type":    "VirtualMachine",
+							"general": map[string]any{"hostname": "host-00"},
+							"hardware": map[string]any{
+								"os_type": "linux",
+								"vcpus":   1,
+								"memory":  1024,
+								"drives":  []any{map[string]any{"image": image}},
+							},
+							"network": map[string]any{
+								"interfaces": []any{
+									map[string]any{"name": "eth0", "vlan": "EXP", "address": "10.0.0.1", "mask": 24, "proto": "static", "type": "ethernet"},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	for _, c := range configs {
+		if err := store.Create(c); err != nil {
+			t.Log(err)
+			t.FailNow()
+		}
+	}
+
+	if err := experiment.Start(context.Background(), experiment.StartWithName("foo"), experiment.StartWithUser(user)); err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+
+	return cluster
+}
+
+func TestRedeploy(t *testing.T) {
+	cluster := startExperiment(t, "foo@bar.com")
+
+	if err := Redeploy("foo", "host-00", CPU(2), Memory(2048), User("foo@bar.com")); err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+
+	after, err := Get("foo", "host-00")
+	if err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+
+	if after.CPUs != 2 || after.RAM != 2048 || !after.Running {
+		t.Logf("unexpected VM after redeploy: %+v", after)
+		t.FailNow()
+	}
+
+	var (
+		history  = cluster.History()
+		expected = []string{"namespace foo vm kill host-00", "namespace foo vm launch kvm host-00", "namespace foo vm start host-00"}
+	)
+
+	if got := history[len(history)-3:]; strings.Join(got, "\n") != strings.Join(expected, "\n") {
+		t.Logf("expected redeployed VM to be killed, relaunched and started, got %v", got)
+		t.FailNow()
+	}
+
+	// Only the additional resources of the redeployed VM count against the
+	// user's quota.
+	if err := Redeploy("foo", "host-00", CPU(4), User("foo@bar.com")); err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+
+	if err := Redeploy("foo", "host-00", CPU(5), User("foo@bar.com")); !errors.Is(err, quota.ErrQuotaExceeded) {
+		t.Logf("expected ErrQuotaExceeded redeploying over quota, got %v", err)
+		t.FailNow()
+	}
+}
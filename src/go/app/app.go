@@ -8,7 +8,6 @@ import (
 	"time"
 
 	"phenix/types"
-	"phenix/util/notes"
 	"phenix/util/plog"
 	"phenix/util/pubsub"
 	"phenix/util/shell"
@@ -169,8 +168,9 @@ type App interface {
 }
 
 // ApplyApps applies all the default phenix apps and any configured user apps to
-// the given experiment for the given lifecycle phase. It returns any errors
-// encountered while applying the apps.
+// the given experiment for the given lifecycle phase. Default apps are always
+// applied first, one at a time. Scenario apps are then applied as described in
+// applyScenarioApps. It returns any errors encountered while applying the apps.
 func ApplyApps(ctx context.Context, exp *types.Experiment, opts ...Option) error {
 	var (
 		options = NewOptions(opts...)
@@ -234,105 +234,8 @@ func ApplyApps(ctx context.Context, exp *types.Experiment, opts ...Option) error
 	}
 
 	if exp.Spec.Scenario() != nil {
-		for _, app := range exp.Spec.Scenario().Apps() {
-			if ctx.Err() != nil {
-				return ctx.Err()
-			}
-
-			// Don't apply default apps again if configured via the Scenario.
-			if _, ok := defaultApps[app.Name()]; ok {
-				continue
-			}
-
-			// Skip app if disabled, unless stage is ACTIONRUNNING
-			if app.Disabled() && options.Stage != ACTIONRUNNING {
-				continue
-			}
-
-			a := GetApp(app.Name())
-			a.Init(Name(app.Name()), DryRun(options.DryRun))
-
-			publish(a.Name(), "start", nil)
-
-			switch options.Stage {
-			case ACTIONCONFIG:
-				exp.Status.SetAppRunning(app.Name(), true)
-				exp.WriteToStore(true)
-
-				err = a.Configure(ctx, exp)
-
-				exp.Status.SetAppRunning(app.Name(), false)
-				exp.WriteToStore(true)
-			case ACTIONPRESTART:
-				exp.Status.SetAppRunning(app.Name(), true)
-				exp.WriteToStore(true)
-
-				err = a.PreStart(ctx, exp)
-
-				exp.Status.SetAppRunning(app.Name(), false)
-				exp.WriteToStore(true)
-			case ACTIONPOSTSTART:
-				exp.Status.SetAppRunning(app.Name(), true)
-				exp.WriteToStore(true)
-
-				err = a.PostStart(ctx, exp)
-
-				exp.Status.SetAppRunning(app.Name(), false)
-				exp.WriteToStore(true)
-			case ACTIONRUNNING:
-				if len(options.Filter) > 0 {
-					if _, ok := options.Filter[app.Name()]; !ok {
-						plog.Warn(fmt.Sprintf("Skipping '%s' experiment app (%s)", app.Name(), options.Stage))
-						continue
-					}
-				}
-
-				// Check to make sure this app isn't already running via an automatic
-				// periodic execution.
-				if running := exp.Status.AppRunning()[app.Name()]; running {
-					notes.AddInfo(ctx, false, fmt.Sprintf("app %s is currently already executing its running stage -- skipping", app.Name()))
-					continue
-				}
-
-				exp.Status.SetAppRunning(app.Name(), true)
-
-				if err := exp.WriteToStore(true); err != nil {
-					notes.AddErrors(ctx, false, fmt.Errorf("error updating store with experiment (%s): %v", exp.Spec.ExperimentName(), err))
-				}
-
-				err = a.Running(ctx, exp)
-
-				exp.Reload() // reload experiment from store in case status was updated during run
-				exp.Status.SetAppRunning(app.Name(), false)
-
-				if err := exp.WriteToStore(true); err != nil {
-					notes.AddErrors(ctx, false, fmt.Errorf("error updating store with experiment (%s): %v", exp.Spec.ExperimentName(), err))
-				}
-			case ACTIONCLEANUP:
-				exp.Status.SetAppRunning(app.Name(), true)
-				exp.WriteToStore(true)
-
-				err = a.Cleanup(ctx, exp)
-
-				exp.Status.SetAppRunning(app.Name(), false)
-				exp.WriteToStore(true)
-			}
-
-			if err != nil {
-				publish(a.Name(), "error", err)
-
-				if errors.Is(err, ErrUserAppNotFound) {
-					plog.Warn(fmt.Sprintf("[?] '%s' user app (%s)", a.Name(), options.Stage))
-					continue
-				}
-
-				plog.Error(fmt.Sprintf("[✗] '%s' user app (%s)", a.Name(), options.Stage))
-				return fmt.Errorf("applying user app %s for action %s: %w", a.Name(), options.Stage, err)
-			}
-
-			publish(a.Name(), "success", nil)
-
-			plog.Info(fmt.Sprintf("[✓] '%s' user app (%s)", a.Name(), options.Stage))
+		if err := applyScenarioApps(ctx, exp, options, publish); err != nil {
+			return err
 		}
 	}
 
@@ -345,8 +248,9 @@ func ApplyApps(ctx context.Context, exp *types.Experiment, opts ...Option) error
 }
 
 // PeriodicallyRunApps checks the configuration for each app in the scenario to
-// see if it's configured to have its "running" stage run periodically. A
-// Goroutine is scheduled for each applicable app.
+// see if it's configured to have its "running" stage run periodically or on a
+// cron schedule. A Goroutine is scheduled for each applicable app. The frequency
+// and next scheduled run time for each app are tracked in the experiment status.
 func PeriodicallyRunApps(ctx context.Context, wg *sync.WaitGroup, exp *types.Experiment) error {
 	if exp.Spec.Scenario() != nil {
 		for _, app := range exp.Spec.Scenario().Apps() {
@@ -355,95 +259,135 @@ func PeriodicallyRunApps(ctx context.Context, wg *sync.WaitGroup, exp *types.Exp
 				continue
 			}
 
-			if app.RunPeriodically() != "" {
-				duration, err := time.ParseDuration(app.RunPeriodically())
-				if err != nil {
-					plog.Error("[✗] invalid periodic duration for app", "app", app.Name(), "duration", app.RunPeriodically())
-					continue
-				}
+			sched, err := newRunSchedule(app, time.Now())
+			if err != nil {
+				plog.Error("[✗] invalid running stage schedule for app", "app", app.Name(), "err", err)
+				continue
+			}
+
+			if sched == nil {
+				continue
+			}
+
+			plog.Info("[✓] scheduling 'running' stage for app", "app", app.Name(), "frequency", sched.frequency, "next", sched.first)
 
-				plog.Info("[✓] scheduling 'running' stage for app", "app", app.Name(), "duration", app.RunPeriodically())
+			wg.Add(1)
 
-				wg.Add(1)
+			go func(exp *types.Experiment, app ifaces.ScenarioApp, sched *runSchedule) {
+				defer wg.Done()
 
-				go func(exp *types.Experiment, app ifaces.ScenarioApp, duration time.Duration) {
-					defer wg.Done()
+				var (
+					next = sched.first
+					runs int
+				)
 
-					exp.Status.SetAppFrequency(app.Name(), app.RunPeriodically())
-					exp.Status.SetAppRunning(app.Name(), false)
+				idle := func() {
+					exp.Modify(func() { exp.Status.SetAppRunning(app.Name(), false) })
+				}
+
+				// update persists the app's schedule to the experiment status. A zero
+				// next run time means the app is no longer scheduled.
+				update := func() {
+					exp.Modify(func() {
+						if next.IsZero() {
+							exp.Status.SetAppFrequency(app.Name(), "")
+							exp.Status.SetAppNextRun(app.Name(), "")
+						} else {
+							exp.Status.SetAppFrequency(app.Name(), sched.frequency)
+							exp.Status.SetAppNextRun(app.Name(), next.Format(time.RFC3339))
+						}
+					})
 
 					if err := exp.WriteToStore(true); err != nil {
 						plog.Error("[✗] error updating store with experiment", "exp", exp.Metadata.Name, "err", err)
 					}
+				}
 
-					timer := time.NewTimer(duration)
-
-					for {
-						select {
-						case <-ctx.Done():
-							if !timer.Stop() {
-								<-timer.C
+				idle()
+				update()
+
+				timer := time.NewTimer(time.Until(next))
+				defer timer.Stop()
+
+				for {
+					select {
+					case <-ctx.Done():
+						next = time.Time{}
+
+						idle()
+						update()
+
+						return
+					case <-timer.C:
+						// Check to make sure this app wasn't triggered manually between
+						// periodic runs, marking it as running in the same atomic update
+						// so a manual trigger can't sneak in between the check and the
+						// update.
+						err := exp.UpdateStatus(func(status ifaces.ExperimentStatus) error {
+							if running := status.AppRunning()[app.Name()]; running {
+								return types.ErrWriteAborted
 							}
 
-							exp.Status.SetAppFrequency(app.Name(), "")
-							exp.Status.SetAppRunning(app.Name(), false)
+							status.SetAppRunning(app.Name(), true)
+							return nil
+						})
 
-							if err := exp.WriteToStore(true); err != nil {
-								plog.Error("[✗] error updating store with experiment", "exp", exp.Metadata.Name, "err", err)
-							}
+						if errors.Is(err, types.ErrWriteAborted) {
+							plog.Info("[✓] app is currently already executing its running stage -- skipping", "app", app.Name())
 
-							return
-						case <-timer.C:
-							// Check to make sure this app wasn't triggered manually between
-							// periodic runs.
-							if running := exp.Status.AppRunning()[app.Name()]; running {
-								plog.Info("[✓] app is currently already executing its running stage -- skipping", "app", app.Name())
-								continue
-							}
+							next = sched.next(time.Now())
+							update()
 
-							// NOTE: there is a chance for a race condition here. Between the
-							// time we pull the experiment config from the store and read its
-							// app running status above, a manual trigger could have set
-							// running too. As of now, if this happens, multiple instances of
-							// the running stage will be executing at the same time. This
-							// might be a good place for optimistic locking.
+							timer.Reset(time.Until(next))
+							continue
+						}
 
-							a := GetApp(app.Name())
-							a.Init(Name(app.Name()))
+						if err != nil {
+							plog.Error("[✗] error updating store with experiment", "exp", exp.Metadata.Name, "err", err)
+						}
 
-							exp.Status.SetAppRunning(app.Name(), true)
+						a := GetApp(app.Name())
+						a.Init(Name(app.Name()))
 
-							if err := exp.WriteToStore(true); err != nil {
-								plog.Error("[✗] error updating store with experiment", "exp", exp.Metadata.Name, "err", err)
-							}
+						pubsub.Publish("trigger-app", TriggerPublication{
+							Experiment: exp.Spec.ExperimentName(), App: app.Name(), State: "start",
+						})
 
+						err = runWithPolicy(ctx, exp, app, ACTIONRUNNING, func(ctx context.Context) error { return a.Running(ctx, exp) })
+						if err != nil {
 							pubsub.Publish("trigger-app", TriggerPublication{
-								Experiment: exp.Spec.ExperimentName(), App: app.Name(), State: "start",
+								Experiment: exp.Spec.ExperimentName(), App: app.Name(), State: "error", Error: err,
 							})
 
-							if err := a.Running(ctx, exp); err != nil {
-								pubsub.Publish("trigger-app", TriggerPublication{
-									Experiment: exp.Spec.ExperimentName(), App: app.Name(), State: "error", Error: err,
-								})
+							plog.Error("[✗] error periodically running app", "app", app.Name(), "err", err)
+						}
 
-								plog.Error("[✗] error periodically running app", "app", app.Name(), "err", err)
-							}
+						pubsub.Publish("trigger-app", TriggerPublication{
+							Experiment: exp.Spec.ExperimentName(), App: app.Name(), State: "success",
+						})
 
-							pubsub.Publish("trigger-app", TriggerPublication{
-								Experiment: exp.Spec.ExperimentName(), App: app.Name(), State: "success",
-							})
+						runs++
 
-							exp.Status.SetAppRunning(app.Name(), false)
+						if sched.maxRuns > 0 && runs >= sched.maxRuns {
+							plog.Info("[✓] app reached max number of 'running' stage runs -- unscheduling", "app", app.Name(), "runs", runs)
 
-							if err := exp.WriteToStore(true); err != nil {
-								plog.Error("[✗] error updating store with experiment", "exp", exp.Metadata.Name, "err", err)
-							}
+							next = time.Time{}
+
+							idle()
+							update()
 
-							timer.Reset(duration)
+							return
 						}
+
+						next = sched.next(time.Now())
+
+						idle()
+						update()
+
+						timer.Reset(time.Until(next))
 					}
-				}(exp, app, duration)
-			}
+				}
+			}(exp, app, sched)
 		}
 	}
 
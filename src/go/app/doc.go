@@ -40,6 +40,43 @@ each user app to add a top-level key to the `apps` JSON object with the
 name of the user app as the key and any metadata in a JSON object as the
 value.
 
+Scenario App Ordering
+
+Scenario apps are applied one at a time in the order they're listed in the
+scenario by default. An app can list other apps it depends on in its `after`
+setting, in which case it won't be applied until those apps have been applied.
+Setting `parallelism` in the scenario to a value greater than 1 allows up to
+that many apps that aren't waiting on other apps to be applied concurrently.
+The experiment spec returned by a custom user app is merged with changes made
+to the spec by other apps applied at the same time. If both changed the same
+field (including the same list, such as the topology's nodes) differently, the
+user app fails, so apps that modify the same parts of the spec should be
+ordered using `after` if they could otherwise run at the same time.
+
+Scenario App Timeouts and Retries
+
+A scenario app can set a `timeout` (e.g. "5m") to limit how long each stage is
+allowed to run for, `retries` to retry a failed stage that many times, and
+`retryBackoff` (e.g. "10s") to wait between retries, doubling after each retry.
+Any of these settings can be overridden for specific stages via the app's
+`stages` setting, keyed by stage name. Custom user apps are sent a SIGTERM when
+they time out (and killed if they haven't exited 10 seconds later). The number
+of attempts made and the last failure encountered for each app are tracked in
+the experiment status as `appAttempts` and `appLastFailure`.
+
+Scenario App Running Stage Schedules
+
+Besides being triggered manually, a scenario app's `running` stage can be run
+in the background while the experiment is running. Setting `runPeriodically` to
+a duration (e.g. "5m") runs it every time the duration elapses, while setting
+`runCron` to a cron expression (e.g. "0 9 * * MON-FRI") runs it whenever the
+expression fires. Either can be combined with `runStartOffset` to delay the first
+run until the offset has elapsed since the experiment started (e.g. a
+`runStartOffset` of "30m" and a `runPeriodically` of "5m" runs the app at T+30m
+and every 5m after that), and with `runMaxRuns` to stop running the app after
+that many runs. The schedule and next run time for each app are tracked in the
+experiment status as `appRunningStageFrequency` and `appRunningStageNextRun`.
+
 Example Custom User App
 
   import json, sys
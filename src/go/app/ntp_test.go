@@ -1,6 +1,7 @@
 package app
 
 import (
+	"context"
 	"fmt"
 	"io/ioutil"
 	"os"
@@ -11,7 +12,9 @@ import (
 	v1 "phenix/types/version/v1"
 )
 
-func TestNTPAppRouter(t *testing.T) {
+// testNTPClient configures NTP for a topology with a Linux NTP server and the
+// given client node, checking the client gets the expected injection.
+func testNTPClient(t *testing.T, client *v1.Node, expected func(string) *v1.Injection) {
 	baseDir, err := ioutil.TempDir("", "ntp-app-test")
 	if err != nil {
 		t.Log(err)
@@ -21,52 +24,28 @@ func TestNTPAppRouter(t *testing.T) {
 	defer os.RemoveAll(baseDir)
 
 	nodes := []*v1.Node{
-		{
-			TypeF: "Router",
-			LabelsF: map[string]string{
-				"ntp-server": "true",
-			},
-			GeneralF: &v1.General{
-				HostnameF: "router",
-			},
-		},
 		{
 			TypeF: "VirtualMachine",
 			LabelsF: map[string]string{
-				"ntp-server": "true",
+				"ntp-server": "eth0",
 			},
 			GeneralF: &v1.General{
-				HostnameF: "linux",
+				HostnameF: "ntp",
 			},
 			HardwareF: &v1.Hardware{
 				OSTypeF: "linux",
 			},
-		},
-		{
-			TypeF: "VirtualMachine",
-			LabelsF: map[string]string{
-				"ntp-server": "true",
-			},
-			GeneralF: &v1.General{
-				HostnameF: "win",
-			},
-			HardwareF: &v1.Hardware{
-				OSTypeF: "windows",
+			NetworkF: &v1.Network{
+				InterfacesF: []*v1.Interface{
+					{NameF: "eth0", AddressF: "10.0.0.254"},
+				},
 			},
 		},
+		client,
 	}
 
-	// only first node w/ ntp-server tag should be configured
-	expected := [][]ifaces.NodeInjection{
-		{
-			&v1.Injection{
-				SrcF: fmt.Sprintf("%s/ntp/router_ntp", baseDir),
-				DstF: "/opt/vyatta/etc/ntp.conf",
-			},
-		},
-		nil,
-		nil,
-	}
+	// the NTP server itself isn't configured as a client
+	exp := [][]ifaces.NodeInjection{nil, {expected(baseDir)}}
 
 	spec := &v1.ExperimentSpec{
 		BaseDirF: baseDir,
@@ -75,189 +54,63 @@ func TestNTPAppRouter(t *testing.T) {
 		},
 	}
 
-	exp := &types.Experiment{Spec: spec}
-
 	app := GetApp("ntp")
 
-	if err := app.Configure(exp); err != nil {
-		t.Log(err)
-		t.FailNow()
-	}
-
-	checkConfigureExpected(t, spec.Topology().Nodes(), expected)
-
-	if err := app.PreStart(exp); err != nil {
+	if err := app.PreStart(context.Background(), &types.Experiment{Spec: spec}); err != nil {
 		t.Log(err)
 		t.FailNow()
 	}
 
-	checkStartExpected(t, spec.Topology().Nodes(), expected)
+	checkConfigureExpected(t, spec.Topology().Nodes(), exp)
+	checkStartExpected(t, spec.Topology().Nodes(), exp)
 }
 
-func TestNTPAppLinux(t *testing.T) {
-	baseDir, err := ioutil.TempDir("", "ntp-app-test")
-	if err != nil {
-		t.Log(err)
-		t.FailNow()
-	}
-
-	defer os.RemoveAll(baseDir)
-
-	nodes := []*v1.Node{
-		{
-			TypeF: "VirtualMachine",
-			LabelsF: map[string]string{
-				"ntp-server": "true",
-			},
-			GeneralF: &v1.General{
-				HostnameF: "linux",
-			},
-			HardwareF: &v1.Hardware{
-				OSTypeF: "linux",
-			},
-		},
-		{
-			TypeF: "VirtualMachine",
-			LabelsF: map[string]string{
-				"ntp-server": "true",
-			},
-			GeneralF: &v1.General{
-				HostnameF: "win",
-			},
-			HardwareF: &v1.Hardware{
-				OSTypeF: "windows",
-			},
+func TestNTPAppRouter(t *testing.T) {
+	client := &v1.Node{
+		TypeF: "Router",
+		GeneralF: &v1.General{
+			HostnameF: "router",
 		},
-		{
-			TypeF: "Router",
-			LabelsF: map[string]string{
-				"ntp-server": "true",
-			},
-			GeneralF: &v1.General{
-				HostnameF: "router",
-			},
+		HardwareF: &v1.Hardware{
+			OSTypeF: "linux",
 		},
 	}
 
-	// only first node w/ ntp-server tag should be configured
-	expected := [][]ifaces.NodeInjection{
-		{
-			&v1.Injection{
-				SrcF: fmt.Sprintf("%s/ntp/linux_ntp", baseDir),
-				DstF: "/etc/ntp.conf",
-			},
-		},
-		nil,
-		nil,
-	}
+	testNTPClient(t, client, func(baseDir string) *v1.Injection {
+		return &v1.Injection{SrcF: fmt.Sprintf("%s/ntp/router_ntp", baseDir), DstF: "/opt/vyatta/etc/ntp.conf"}
+	})
+}
 
-	spec := &v1.ExperimentSpec{
-		BaseDirF: baseDir,
-		TopologyF: &v1.TopologySpec{
-			NodesF: nodes,
+func TestNTPAppLinux(t *testing.T) {
+	client := &v1.Node{
+		TypeF: "VirtualMachine",
+		GeneralF: &v1.General{
+			HostnameF: "linux",
+		},
+		HardwareF: &v1.Hardware{
+			OSTypeF: "linux",
 		},
 	}
 
-	exp := &types.Experiment{Spec: spec}
-
-	app := GetApp("ntp")
-
-	if err := app.Configure(exp); err != nil {
-		t.Log(err)
-		t.FailNow()
-	}
-
-	checkConfigureExpected(t, spec.Topology().Nodes(), expected)
-
-	if err := app.PreStart(exp); err != nil {
-		t.Log(err)
-		t.FailNow()
-	}
-
-	checkStartExpected(t, spec.Topology().Nodes(), expected)
+	testNTPClient(t, client, func(baseDir string) *v1.Injection {
+		return &v1.Injection{SrcF: fmt.Sprintf("%s/ntp/linux_ntp", baseDir), DstF: "/etc/ntp.conf"}
+	})
 }
 
 func TestNTPAppWindows(t *testing.T) {
-	baseDir, err := ioutil.TempDir("", "ntp-app-test")
-	if err != nil {
-		t.Log(err)
-		t.FailNow()
-	}
-
-	defer os.RemoveAll(baseDir)
-
-	nodes := []*v1.Node{
-		{
-			TypeF: "VirtualMachine",
-			LabelsF: map[string]string{
-				"ntp-server": "true",
-			},
-			GeneralF: &v1.General{
-				HostnameF: "win",
-			},
-			HardwareF: &v1.Hardware{
-				OSTypeF: "windows",
-			},
+	client := &v1.Node{
+		TypeF: "VirtualMachine",
+		GeneralF: &v1.General{
+			HostnameF: "win",
 		},
-		{
-			TypeF: "VirtualMachine",
-			LabelsF: map[string]string{
-				"ntp-server": "true",
-			},
-			GeneralF: &v1.General{
-				HostnameF: "linux",
-			},
-			HardwareF: &v1.Hardware{
-				OSTypeF: "linux",
-			},
-		},
-		{
-			TypeF: "Router",
-			LabelsF: map[string]string{
-				"ntp-server": "true",
-			},
-			GeneralF: &v1.General{
-				HostnameF: "router",
-			},
-		},
-	}
-
-	// only first node w/ ntp-server tag should be configured
-	expected := [][]ifaces.NodeInjection{
-		{
-			&v1.Injection{
-				SrcF: fmt.Sprintf("%s/ntp/win_ntp", baseDir),
-				DstF: "ntp.ps1",
-			},
-		},
-		nil,
-		nil,
-	}
-
-	spec := &v1.ExperimentSpec{
-		BaseDirF: baseDir,
-		TopologyF: &v1.TopologySpec{
-			NodesF: nodes,
+		HardwareF: &v1.Hardware{
+			OSTypeF: "windows",
 		},
 	}
 
-	exp := &types.Experiment{Spec: spec}
-
-	app := GetApp("ntp")
-
-	if err := app.Configure(exp); err != nil {
-		t.Log(err)
-		t.FailNow()
-	}
-
-	checkConfigureExpected(t, spec.Topology().Nodes(), expected)
-
-	if err := app.PreStart(exp); err != nil {
-		t.Log(err)
-		t.FailNow()
-	}
-
-	checkStartExpected(t, spec.Topology().Nodes(), expected)
+	testNTPClient(t, client, func(baseDir string) *v1.Injection {
+		return &v1.Injection{SrcF: fmt.Sprintf("%s/ntp/win_ntp", baseDir), DstF: "/phenix/startup/25-ntp.ps1"}
+	})
 }
 
 func TestNTPAppNone(t *testing.T) {
@@ -310,14 +163,14 @@ func TestNTPAppNone(t *testing.T) {
 
 	app := GetApp("ntp")
 
-	if err := app.Configure(exp); err != nil {
+	if err := app.Configure(context.Background(), exp); err != nil {
 		t.Log(err)
 		t.FailNow()
 	}
 
 	checkConfigureExpected(t, spec.Topology().Nodes(), expected)
 
-	if err := app.PreStart(exp); err != nil {
+	if err := app.PreStart(context.Background(), exp); err != nil {
 		t.Log(err)
 		t.FailNow()
 	}
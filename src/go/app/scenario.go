@@ -0,0 +1,363 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"phenix/types"
+	"phenix/util/notes"
+	"phenix/util/plog"
+
+	ifaces "phenix/types/interfaces"
+)
+
+var ErrAppTimeout = errors.New("app timed out")
+
+type appResult struct {
+	name string
+	err  error
+}
+
+// applyScenarioApps applies the scenario apps configured for the given
+// experiment for the given lifecycle phase. Apps are applied in the order they
+// are listed in the scenario, except an app is not applied until all the apps
+// listed in its `after` setting have been applied. Up to `parallelism` apps
+// (configured in the scenario, defaulting to 1) that aren't waiting on other
+// apps are applied concurrently. Once an app fails, no new apps are applied and
+// the first error encountered is returned after any apps already being applied
+// finish.
+func applyScenarioApps(ctx context.Context, exp *types.Experiment, options Options, publish func(string, string, error)) error {
+	scenario := exp.Spec.Scenario()
+
+	if err := validateAppDependencies(scenario.Apps()); err != nil {
+		return fmt.Errorf("validating scenario app dependencies: %w", err)
+	}
+
+	var (
+		pending []ifaces.ScenarioApp
+
+		// Apps that won't be applied for this stage are considered done so apps
+		// that depend on them aren't blocked.
+		done = make(map[string]bool)
+	)
+
+	for name := range defaultApps {
+		done[name] = true
+	}
+
+	for _, app := range scenario.Apps() {
+		// Don't apply default apps again if configured via the Scenario.
+		if _, ok := defaultApps[app.Name()]; ok {
+			continue
+		}
+
+		// Skip app if disabled, unless stage is ACTIONRUNNING
+		if app.Disabled() && options.Stage != ACTIONRUNNING {
+			done[app.Name()] = true
+			continue
+		}
+
+		if options.Stage == ACTIONRUNNING && len(options.Filter) > 0 {
+			if _, ok := options.Filter[app.Name()]; !ok {
+				plog.Warn(fmt.Sprintf("Skipping '%s' experiment app (%s)", app.Name(), options.Stage))
+
+				done[app.Name()] = true
+				continue
+			}
+		}
+
+		pending = append(pending, app)
+	}
+
+	var (
+		limit   = scenario.Parallelism()
+		results = make(chan appResult)
+		running int
+		errs    error
+	)
+
+	ready := func(app ifaces.ScenarioApp) bool {
+		for _, dep := range app.After() {
+			if !done[dep] {
+				return false
+			}
+		}
+
+		return true
+	}
+
+	for {
+		// Don't start any new apps once an error has occurred or the context has
+		// been canceled.
+		if errs == nil && ctx.Err() == nil {
+			for i := 0; i < len(pending) && running < limit; {
+				app := pending[i]
+
+				if !ready(app) {
+					i++
+					continue
+				}
+
+				pending = append(pending[:i], pending[i+1:]...)
+				running++
+
+				go func(app ifaces.ScenarioApp) {
+					results <- appResult{name: app.Name(), err: applyScenarioApp(ctx, exp, app, options, publish)}
+				}(app)
+			}
+		}
+
+		if running == 0 {
+			break
+		}
+
+		result := <-results
+
+		running--
+		done[result.name] = true
+
+		if result.err != nil && errs == nil {
+			errs = result.err
+		}
+	}
+
+	if errs != nil {
+		return errs
+	}
+
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+
+	// This should never happen since dependencies are validated above, but
+	// better safe than sorry.
+	if len(pending) > 0 {
+		return fmt.Errorf("unable to resolve dependencies for app %s", pending[0].Name())
+	}
+
+	return nil
+}
+
+// applyScenarioApp applies a single scenario app for the given lifecycle
+// phase, keeping the app's running status in the experiment up to date.
+func applyScenarioApp(ctx context.Context, exp *types.Experiment, app ifaces.ScenarioApp, options Options, publish func(string, string, error)) error {
+	a := GetApp(app.Name())
+	a.Init(Name(app.Name()), DryRun(options.DryRun))
+
+	setRunning := func(running bool) error {
+		exp.Modify(func() { exp.Status.SetAppRunning(app.Name(), running) })
+		return exp.WriteToStore(true)
+	}
+
+	publish(a.Name(), "start", nil)
+
+	var err error
+
+	switch options.Stage {
+	case ACTIONCONFIG:
+		setRunning(true)
+		err = runWithPolicy(ctx, exp, app, options.Stage, func(ctx context.Context) error { return a.Configure(ctx, exp) })
+		setRunning(false)
+	case ACTIONPRESTART:
+		setRunning(true)
+		err = runWithPolicy(ctx, exp, app, options.Stage, func(ctx context.Context) error { return a.PreStart(ctx, exp) })
+		setRunning(false)
+	case ACTIONPOSTSTART:
+		setRunning(true)
+		err = runWithPolicy(ctx, exp, app, options.Stage, func(ctx context.Context) error { return a.PostStart(ctx, exp) })
+		setRunning(false)
+	case ACTIONRUNNING:
+		// Check to make sure this app isn't already running via an automatic
+		// periodic execution, marking it as running in the same atomic update so
+		// a periodic execution can't sneak in between the check and the update.
+		err := exp.UpdateStatus(func(status ifaces.ExperimentStatus) error {
+			if running := status.AppRunning()[app.Name()]; running {
+				return types.ErrWriteAborted
+			}
+
+			status.SetAppRunning(app.Name(), true)
+			return nil
+		})
+
+		if errors.Is(err, types.ErrWriteAborted) {
+			notes.AddInfo(ctx, false, fmt.Sprintf("app %s is currently already executing its running stage -- skipping", app.Name()))
+			return nil
+		}
+
+		if err != nil {
+			notes.AddErrors(ctx, false, fmt.Errorf("error updating store with experiment (%s): %v", exp.Spec.ExperimentName(), err))
+		}
+
+		err = runWithPolicy(ctx, exp, app, options.Stage, func(ctx context.Context) error { return a.Running(ctx, exp) })
+
+		// Status updates made to the experiment in the store during the run are
+		// merged in by WriteToStore, so there's no need to reload the experiment
+		// first (which would also drop the attempts recorded above).
+		if err := setRunning(false); err != nil {
+			notes.AddErrors(ctx, false, fmt.Errorf("error updating store with experiment (%s): %v", exp.Spec.ExperimentName(), err))
+		}
+	case ACTIONCLEANUP:
+		setRunning(true)
+		err = runWithPolicy(ctx, exp, app, options.Stage, func(ctx context.Context) error { return a.Cleanup(ctx, exp) })
+		setRunning(false)
+	}
+
+	if err != nil {
+		publish(a.Name(), "error", err)
+
+		if errors.Is(err, ErrUserAppNotFound) {
+			plog.Warn(fmt.Sprintf("[?] '%s' user app (%s)", a.Name(), options.Stage))
+			return nil
+		}
+
+		plog.Error(fmt.Sprintf("[✗] '%s' user app (%s)", a.Name(), options.Stage))
+		return fmt.Errorf("applying user app %s for action %s: %w", a.Name(), options.Stage, err)
+	}
+
+	publish(a.Name(), "success", nil)
+
+	plog.Info(fmt.Sprintf("[✓] '%s' user app (%s)", a.Name(), options.Stage))
+
+	return nil
+}
+
+// runWithPolicy executes the given app stage function, enforcing the timeout
+// and retry policy configured for the app and stage. Each attempt is given its
+// own timeout, and retries are delayed by the configured backoff, doubling
+// after each retry. The number of attempts made and the last failure
+// encountered are recorded in the experiment status.
+func runWithPolicy(ctx context.Context, exp *types.Experiment, app ifaces.ScenarioApp, stage Action, stageFn func(context.Context) error) error {
+	var (
+		policy  = app.Policy(string(stage))
+		timeout time.Duration
+		backoff time.Duration
+		err     error
+	)
+
+	if policy.Timeout() != "" {
+		if timeout, err = time.ParseDuration(policy.Timeout()); err != nil {
+			return fmt.Errorf("parsing timeout for app %s: %w", app.Name(), err)
+		}
+	}
+
+	if policy.RetryBackoff() != "" {
+		if backoff, err = time.ParseDuration(policy.RetryBackoff()); err != nil {
+			return fmt.Errorf("parsing retry backoff for app %s: %w", app.Name(), err)
+		}
+	}
+
+	exp.Modify(func() {
+		exp.Status.SetAppAttempts(app.Name(), 0)
+		exp.Status.SetAppLastFailure(app.Name(), "")
+	})
+
+	for attempt := 1; ; attempt++ {
+		attemptCtx, cancel := ctx, context.CancelFunc(func() {})
+
+		if timeout > 0 {
+			attemptCtx, cancel = context.WithTimeout(ctx, timeout)
+		}
+
+		err = stageFn(attemptCtx)
+
+		if err != nil && errors.Is(attemptCtx.Err(), context.DeadlineExceeded) && ctx.Err() == nil {
+			err = fmt.Errorf("%w after %v: %v", ErrAppTimeout, timeout, err)
+		}
+
+		cancel()
+
+		exp.Modify(func() {
+			exp.Status.SetAppAttempts(app.Name(), attempt)
+
+			if err != nil && !errors.Is(err, ErrUserAppNotFound) {
+				exp.Status.SetAppLastFailure(app.Name(), fmt.Sprintf("%s (attempt %d): %v", stage, attempt, err))
+			}
+		})
+
+		if err == nil {
+			return nil
+		}
+
+		// Don't retry if the app doesn't exist or the experiment action was
+		// canceled.
+		if errors.Is(err, ErrUserAppNotFound) || ctx.Err() != nil || attempt > policy.Retries() {
+			return err
+		}
+
+		plog.Warn(fmt.Sprintf("[!] '%s' user app (%s) failed -- retrying", app.Name(), stage), "attempt", attempt, "err", err)
+
+		// Persist the failure so users can see the app is being retried.
+		if err := exp.WriteToStore(true); err != nil {
+			plog.Error("[✗] error updating store with experiment", "exp", exp.Metadata.Name, "err", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(backoff << (attempt - 1)):
+		}
+	}
+}
+
+// validateAppDependencies ensures all the apps listed in the `after` setting of
+// the given apps exist and that there are no dependency cycles.
+func validateAppDependencies(apps []ifaces.ScenarioApp) error {
+	deps := make(map[string][]string)
+
+	for _, app := range apps {
+		deps[app.Name()] = app.After()
+	}
+
+	for _, app := range apps {
+		for _, dep := range app.After() {
+			if _, ok := defaultApps[dep]; ok {
+				continue // default apps are always applied first
+			}
+
+			if _, ok := deps[dep]; !ok {
+				return fmt.Errorf("app %s depends on unknown app %s", app.Name(), dep)
+			}
+		}
+	}
+
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+
+	state := make(map[string]int)
+
+	var visit func(string, []string) error
+
+	visit = func(name string, path []string) error {
+		switch state[name] {
+		case visiting:
+			return fmt.Errorf("dependency cycle detected: %v", append(path, name))
+		case visited:
+			return nil
+		}
+
+		state[name] = visiting
+
+		for _, dep := range deps[name] {
+			if err := visit(dep, append(path, name)); err != nil {
+				return err
+			}
+		}
+
+		state[name] = visited
+
+		return nil
+	}
+
+	for _, app := range apps {
+		if err := visit(app.Name(), nil); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,400 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"phenix/store"
+	"phenix/types"
+	"phenix/util/mm"
+	"phenix/util/mm/mmfake"
+	"phenix/util/shell"
+
+	v2 "phenix/types/version/v2"
+
+	"github.com/activeshadow/structs"
+	gomock "github.com/golang/mock/gomock"
+)
+
+type orderedApp struct {
+	name string
+	log  *appLog
+}
+
+func (this *orderedApp) Init(opts ...Option) error { return nil }
+func (this orderedApp) Name() string               { return this.name }
+
+func (this orderedApp) Configure(ctx context.Context, exp *types.Experiment) error {
+	return this.log.run(this.name)
+}
+
+func (orderedApp) PreStart(context.Context, *types.Experiment) error  { return nil }
+func (orderedApp) PostStart(context.Context, *types.Experiment) error { return nil }
+func (orderedApp) Running(context.Context, *types.Experiment) error   { return nil }
+func (orderedApp) Cleanup(context.Context, *types.Experiment) error   { return nil }
+
+type appLog struct {
+	sync.Mutex
+
+	running  int
+	max      int
+	finished []string
+	fail     string
+}
+
+func (this *appLog) run(name string) error {
+	this.Lock()
+	this.running++
+
+	if this.running > this.max {
+		this.max = this.running
+	}
+
+	this.Unlock()
+
+	time.Sleep(50 * time.Millisecond)
+
+	this.Lock()
+	defer this.Unlock()
+
+	this.running--
+	this.finished = append(this.finished, name)
+
+	if name == this.fail {
+		return fmt.Errorf("%s failed", name)
+	}
+
+	return nil
+}
+
+func (this *appLog) index(name string) int {
+	for i, n := range this.finished {
+		if n == name {
+			return i
+		}
+	}
+
+	return -1
+}
+
+func newScenarioExperiment(t *testing.T, log *appLog, parallelism int, scenarioApps ...*v2.ScenarioApp) *types.Experiment {
+	// ensure no external user apps are found
+	t.Setenv("PATH", "")
+
+	store.DefaultStore = store.NewBoltDB()
+
+	if err := store.DefaultStore.Init(store.Endpoint("bolt://" + filepath.Join(t.TempDir(), "phenix.bdb"))); err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+
+	for _, app := range scenarioApps {
+		name := app.Name()
+		apps[name] = func() App { return &orderedApp{name: name, log: log} }
+	}
+
+	exp := types.NewExperiment(store.ConfigMetadata{Name: "test"})
+	exp.Spec.SetScenario(&v2.ScenarioSpec{AppsF: scenarioApps, ParallelismF: parallelism})
+
+	c, _ := store.NewConfig("experiment/test")
+	c.Spec = structs.MapDefaultCase(exp.Spec, structs.CASESNAKE)
+	c.Status = structs.MapDefaultCase(exp.Status, structs.CASESNAKE)
+
+	if err := store.Create(c); err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+
+	return exp
+}
+
+func TestApplyScenarioAppsDependencies(t *testing.T) {
+	log := new(appLog)
+
+	exp := newScenarioExperiment(t, log, 2,
+		&v2.ScenarioApp{NameF: "test-d", AfterF: []string{"test-b", "test-c"}},
+		&v2.ScenarioApp{NameF: "test-a"},
+		&v2.ScenarioApp{NameF: "test-b", AfterF: []string{"test-a"}},
+		&v2.ScenarioApp{NameF: "test-c"},
+	)
+
+	if err := applyScenarioApps(context.Background(), exp, NewOptions(Stage(ACTIONCONFIG)), func(string, string, error) {}); err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+
+	if len(log.finished) != 4 {
+		t.Logf("expected 4 apps to be applied, got %v", log.finished)
+		t.FailNow()
+	}
+
+	if log.index("test-b") < log.index("test-a") {
+		t.Logf("expected test-b to be applied after test-a, got %v", log.finished)
+		t.FailNow()
+	}
+
+	if log.index("test-d") != 3 {
+		t.Logf("expected test-d to be applied last, got %v", log.finished)
+		t.FailNow()
+	}
+
+	if log.max != 2 {
+		t.Logf("expected 2 apps to be applied concurrently, got %d", log.max)
+		t.FailNow()
+	}
+
+	if running := exp.Status.AppRunning()["test-d"]; running {
+		t.Log("expected test-d to not be running")
+		t.FailNow()
+	}
+}
+
+func TestApplyScenarioAppsSequentialByDefault(t *testing.T) {
+	log := new(appLog)
+
+	exp := newScenarioExperiment(t, log, 0,
+		&v2.ScenarioApp{NameF: "test-a"},
+		&v2.ScenarioApp{NameF: "test-b"},
+		&v2.ScenarioApp{NameF: "test-c"},
+	)
+
+	if err := applyScenarioApps(context.Background(), exp, NewOptions(Stage(ACTIONCONFIG)), func(string, string, error) {}); err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+
+	if fmt.Sprint(log.finished) != "[test-a test-b test-c]" || log.max != 1 {
+		t.Logf("expected apps to be applied sequentially in order, got %v", log.finished)
+		t.FailNow()
+	}
+}
+
+func TestApplyScenarioAppsError(t *testing.T) {
+	log := &appLog{fail: "test-a"}
+
+	exp := newScenarioExperiment(t, log, 2,
+		&v2.ScenarioApp{NameF: "test-a"},
+		&v2.ScenarioApp{NameF: "test-b", AfterF: []string{"test-a"}},
+	)
+
+	if err := applyScenarioApps(context.Background(), exp, NewOptions(Stage(ACTIONCONFIG)), func(string, string, error) {}); err == nil {
+		t.Log("expected error")
+		t.FailNow()
+	}
+
+	if log.index("test-b") != -1 {
+		t.Log("expected test-b to not be applied")
+		t.FailNow()
+	}
+}
+
+func TestValidateAppDependencies(t *testing.T) {
+	valid := []*v2.ScenarioApp{
+		{NameF: "a", AfterF: []string{"ntp"}},
+		{NameF: "b", AfterF: []string{"a"}},
+	}
+
+	if err := validateAppDependencies((&v2.ScenarioSpec{AppsF: valid}).Apps()); err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+
+	unknown := []*v2.ScenarioApp{
+		{NameF: "a", AfterF: []string{"foobar"}},
+	}
+
+	if err := validateAppDependencies((&v2.ScenarioSpec{AppsF: unknown}).Apps()); err == nil {
+		t.Log("expected unknown app error")
+		t.FailNow()
+	}
+
+	cycle := []*v2.ScenarioApp{
+		{NameF: "a", AfterF: []string{"c"}},
+		{NameF: "b", AfterF: []string{"a"}},
+		{NameF: "c", AfterF: []string{"b"}},
+	}
+
+	if err := validateAppDependencies((&v2.ScenarioSpec{AppsF: cycle}).Apps()); err == nil {
+		t.Log("expected dependency cycle error")
+		t.FailNow()
+	}
+}
+
+type flakyApp struct {
+	orderedApp
+
+	failures int
+	calls    int
+	block    bool
+}
+
+func (this *flakyApp) Configure(ctx context.Context, exp *types.Experiment) error {
+	this.calls++
+
+	if this.block {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+
+	if this.calls <= this.failures {
+		return fmt.Errorf("failure %d", this.calls)
+	}
+
+	return nil
+}
+
+func TestApplyScenarioAppRetries(t *testing.T) {
+	exp := newScenarioExperiment(t, new(appLog), 1,
+		&v2.ScenarioApp{NameF: "test-a", RetriesF: 2, RetryBackoffF: "10ms"},
+	)
+
+	flaky := &flakyApp{orderedApp: orderedApp{name: "test-a"}, failures: 2}
+	apps["test-a"] = func() App { return flaky }
+
+	if err := applyScenarioApps(context.Background(), exp, NewOptions(Stage(ACTIONCONFIG)), func(string, string, error) {}); err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+
+	if flaky.calls != 3 {
+		t.Logf("expected 3 attempts, got %d", flaky.calls)
+		t.FailNow()
+	}
+
+	if attempts := exp.Status.AppAttempts()["test-a"]; attempts != 3 {
+		t.Logf("expected 3 attempts recorded in status, got %d", attempts)
+		t.FailNow()
+	}
+
+	if failure := exp.Status.AppLastFailure()["test-a"]; failure != "configure (attempt 2): failure 2" {
+		t.Logf("unexpected last failure recorded in status: %s", failure)
+		t.FailNow()
+	}
+}
+
+func TestApplyScenarioAppTimeout(t *testing.T) {
+	exp := newScenarioExperiment(t, new(appLog), 1,
+		&v2.ScenarioApp{
+			NameF:    "test-a",
+			TimeoutF: "1h",
+			RetriesF: 1,
+			StagesF:  map[string]*v2.ScenarioAppPolicy{"configure": {TimeoutF: "50ms"}},
+		},
+	)
+
+	flaky := &flakyApp{orderedApp: orderedApp{name: "test-a"}, block: true}
+	apps["test-a"] = func() App { return flaky }
+
+	err := applyScenarioApps(context.Background(), exp, NewOptions(Stage(ACTIONCONFIG)), func(string, string, error) {})
+
+	if !errors.Is(err, ErrAppTimeout) {
+		t.Logf("expected app timeout error, got %v", err)
+		t.FailNow()
+	}
+
+	if flaky.calls != 2 {
+		t.Logf("expected 2 attempts, got %d", flaky.calls)
+		t.FailNow()
+	}
+}
+
+func TestApplyScenarioAppStageDisablesRetries(t *testing.T) {
+	retries := 0
+
+	exp := newScenarioExperiment(t, new(appLog), 1,
+		&v2.ScenarioApp{
+			NameF:         "test-a",
+			RetriesF:      2,
+			RetryBackoffF: "10ms",
+			StagesF:       map[string]*v2.ScenarioAppPolicy{"configure": {RetriesF: &retries}},
+		},
+	)
+
+	flaky := &flakyApp{orderedApp: orderedApp{name: "test-a"}, failures: 1}
+	apps["test-a"] = func() App { return flaky }
+
+	if err := applyScenarioApps(context.Background(), exp, NewOptions(Stage(ACTIONCONFIG)), func(string, string, error) {}); err == nil {
+		t.Log("expected app to fail without retries")
+		t.FailNow()
+	}
+
+	if flaky.calls != 1 {
+		t.Logf("expected 1 attempt, got %d", flaky.calls)
+		t.FailNow()
+	}
+}
+
+func TestApplyScenarioUserAppsMergeSpec(t *testing.T) {
+	scenario := &v2.ScenarioSpec{
+		AppsF:        []*v2.ScenarioApp{{NameF: "user-a"}, {NameF: "user-b"}},
+		ParallelismF: 2,
+	}
+
+	exp := newScenarioExperiment(t, new(appLog), 2)
+	exp.Spec.SetScenario(scenario)
+
+	origMM, origShell := mm.DefaultMM, shell.DefaultShell
+
+	t.Cleanup(func() {
+		mm.DefaultMM = origMM
+		shell.DefaultShell = origShell
+	})
+
+	mm.DefaultMM = mmfake.New()
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	m := shell.NewMockShell(ctrl)
+	shell.DefaultShell = m
+
+	var (
+		md    = exp.Metadata
+		mu    sync.Mutex
+		calls int
+	)
+
+	// Each user app adds a different VLAN alias to the spec it was given, and
+	// both run at the same time.
+	m.EXPECT().CommandExists(gomock.Any()).Return(true).AnyTimes()
+	m.EXPECT().ExecCommand(gomock.Any(), gomock.Any()).Times(2).DoAndReturn(
+		func(context.Context, ...shell.Option) ([]byte, []byte, error) {
+			mu.Lock()
+			calls++
+			alias := fmt.Sprintf("VLAN%d", calls)
+			mu.Unlock()
+
+			result := types.NewExperiment(md)
+			result.Spec.SetScenario(scenario)
+			result.Spec.SetVLANAlias(alias, 100+len(alias), false)
+
+			time.Sleep(50 * time.Millisecond)
+
+			body, err := json.Marshal(result)
+			return body, nil, err
+		},
+	)
+
+	if err := applyScenarioApps(context.Background(), exp, NewOptions(Stage(ACTIONCONFIG)), func(string, string, error) {}); err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+
+	aliases := exp.Spec.VLANs().Aliases()
+
+	if _, ok := aliases["VLAN1"]; !ok {
+		t.Logf("expected VLAN alias from first user app, got %v", aliases)
+		t.FailNow()
+	}
+
+	if _, ok := aliases["VLAN2"]; !ok {
+		t.Logf("expected VLAN alias from second user app, got %v", aliases)
+		t.FailNow()
+	}
+}
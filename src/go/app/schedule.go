@@ -0,0 +1,92 @@
+package app
+
+import (
+	"fmt"
+	"time"
+
+	"phenix/util/cron"
+
+	ifaces "phenix/types/interfaces"
+)
+
+// runSchedule determines when an app's running stage is run in the
+// background.
+type runSchedule struct {
+	// Either the periodic duration or the cron expression configured for the
+	// app, tracked in the experiment status as the app's frequency.
+	frequency string
+
+	first   time.Time
+	next    func(time.Time) time.Time
+	maxRuns int
+}
+
+// newRunSchedule returns the schedule for the given app's running stage,
+// relative to the given start time. A nil schedule is returned if the app isn't
+// configured to have its running stage run in the background.
+//
+// Apps can either be configured with `runPeriodically` (a duration) or
+// `runCron` (a cron expression). By default, periodic apps first run one
+// duration after the start time and cron apps first run at the first time the
+// expression fires after the start time. If `runStartOffset` is configured,
+// periodic apps first run at the start time plus the offset, and cron apps
+// first run at the first time the expression fires after that. If `runMaxRuns`
+// is configured, the app stops being run after that many runs.
+func newRunSchedule(app ifaces.ScenarioApp, start time.Time) (*runSchedule, error) {
+	if app.RunPeriodically() == "" && app.RunCron() == "" {
+		return nil, nil
+	}
+
+	if app.RunPeriodically() != "" && app.RunCron() != "" {
+		return nil, fmt.Errorf("only one of runPeriodically and runCron can be configured")
+	}
+
+	if app.RunMaxRuns() < 0 {
+		return nil, fmt.Errorf("invalid max runs %d", app.RunMaxRuns())
+	}
+
+	var offset time.Duration
+
+	if app.RunStartOffset() != "" {
+		var err error
+
+		if offset, err = time.ParseDuration(app.RunStartOffset()); err != nil || offset < 0 {
+			return nil, fmt.Errorf("invalid start offset %s", app.RunStartOffset())
+		}
+	}
+
+	sched := &runSchedule{maxRuns: app.RunMaxRuns()}
+
+	if app.RunCron() != "" {
+		expr, err := cron.Parse(app.RunCron())
+		if err != nil {
+			return nil, err
+		}
+
+		sched.frequency = app.RunCron()
+		sched.next = expr.Next
+		sched.first = expr.Next(start.Add(offset))
+
+		if sched.first.IsZero() {
+			return nil, fmt.Errorf("cron expression %s never fires", app.RunCron())
+		}
+
+		return sched, nil
+	}
+
+	duration, err := time.ParseDuration(app.RunPeriodically())
+	if err != nil || duration <= 0 {
+		return nil, fmt.Errorf("invalid periodic duration %s", app.RunPeriodically())
+	}
+
+	sched.frequency = app.RunPeriodically()
+	sched.next = func(t time.Time) time.Time { return t.Add(duration) }
+
+	if offset > 0 {
+		sched.first = start.Add(offset)
+	} else {
+		sched.first = start.Add(duration)
+	}
+
+	return sched, nil
+}
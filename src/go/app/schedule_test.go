@@ -0,0 +1,70 @@
+package app
+
+import (
+	"testing"
+	"time"
+
+	v2 "phenix/types/version/v2"
+)
+
+func TestNewRunSchedule(t *testing.T) {
+	// Friday, October 16th, 2026
+	start := time.Date(2026, time.October, 16, 8, 0, 0, 0, time.UTC)
+
+	sched, err := newRunSchedule(&v2.ScenarioApp{NameF: "test", RunPeriodicallyF: "5m", RunStartOffsetF: "30m"}, start)
+	if err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+
+	if expected := start.Add(30 * time.Minute); !sched.first.Equal(expected) {
+		t.Logf("expected first run at %v, got %v", expected, sched.first)
+		t.FailNow()
+	}
+
+	if expected := start.Add(35 * time.Minute); !sched.next(sched.first).Equal(expected) {
+		t.Logf("expected next run at %v, got %v", expected, sched.next(sched.first))
+		t.FailNow()
+	}
+
+	sched, err = newRunSchedule(&v2.ScenarioApp{NameF: "test", RunCronF: "0 9 * * MON-FRI", RunMaxRunsF: 3}, start)
+	if err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+
+	if expected := start.Add(time.Hour); !sched.first.Equal(expected) {
+		t.Logf("expected first run at %v, got %v", expected, sched.first)
+		t.FailNow()
+	}
+
+	// next weekday is Monday
+	if expected := start.Add(73 * time.Hour); !sched.next(sched.first).Equal(expected) {
+		t.Logf("expected next run at %v, got %v", expected, sched.next(sched.first))
+		t.FailNow()
+	}
+
+	if sched.frequency != "0 9 * * MON-FRI" || sched.maxRuns != 3 {
+		t.Logf("unexpected schedule: %+v", sched)
+		t.FailNow()
+	}
+
+	if sched, _ := newRunSchedule(&v2.ScenarioApp{NameF: "test"}, start); sched != nil {
+		t.Log("expected no schedule")
+		t.FailNow()
+	}
+
+	invalid := []*v2.ScenarioApp{
+		{NameF: "test", RunPeriodicallyF: "5m", RunCronF: "* * * * *"},
+		{NameF: "test", RunPeriodicallyF: "foo"},
+		{NameF: "test", RunCronF: "0 0 30 2 *"},
+		{NameF: "test", RunCronF: "* * * * *", RunStartOffsetF: "-5m"},
+	}
+
+	for _, app := range invalid {
+		if _, err := newRunSchedule(app, start); err == nil {
+			t.Logf("expected error for app %+v", app)
+			t.FailNow()
+		}
+	}
+}
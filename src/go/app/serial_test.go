@@ -1,6 +1,7 @@
 package app
 
 import (
+	"context"
 	"fmt"
 	"io/ioutil"
 	"os"
@@ -100,14 +101,14 @@ func TestSerialApp(t *testing.T) {
 
 	app := GetApp("serial")
 
-	if err := app.Configure(exp); err != nil {
+	if err := app.Configure(context.Background(), exp); err != nil {
 		t.Log(err)
 		t.FailNow()
 	}
 
 	checkConfigureExpected(t, spec.Topology().Nodes(), expected)
 
-	if err := app.PreStart(exp); err != nil {
+	if err := app.PreStart(context.Background(), exp); err != nil {
 		t.Log(err)
 		t.FailNow()
 	}
@@ -1,6 +1,7 @@
 package app
 
 import (
+	"context"
 	"fmt"
 	"io/ioutil"
 	"os"
@@ -23,6 +24,9 @@ func TestStartupApp(t *testing.T) {
 	nodes := []*v1.Node{
 		{
 			TypeF: "Router",
+			GeneralF: &v1.General{
+				HostnameF: "router",
+			},
 			HardwareF: &v1.Hardware{
 				OSTypeF: "linux",
 				DrivesF: []*v1.Drive{
@@ -100,11 +104,6 @@ func TestStartupApp(t *testing.T) {
 					},
 				},
 			},
-			InjectionsF: []*v1.Injection{
-				{
-					DstF: "startup.ps1",
-				},
-			},
 		},
 	}
 
@@ -120,12 +119,8 @@ func TestStartupApp(t *testing.T) {
 				DstF: "/etc/phenix/startup/2_timezone-start.sh",
 			},
 			&v1.Injection{
-				SrcF: fmt.Sprintf("%s/startup/interfaces-centos-linux-eth0", baseDir),
-				DstF: "/etc/sysconfig/network-scripts/ifcfg-eth0",
-			},
-			&v1.Injection{
-				SrcF: fmt.Sprintf("%s/startup/interfaces-centos-linux-eth1", baseDir),
-				DstF: "/etc/sysconfig/network-scripts/ifcfg-eth1",
+				SrcF: fmt.Sprintf("%s/startup/centos-linux-interfaces.sh", baseDir),
+				DstF: "/etc/phenix/startup/3_interfaces-start.sh",
 			},
 		},
 		{ // rhel-linux
@@ -138,16 +133,8 @@ func TestStartupApp(t *testing.T) {
 				DstF: "/etc/phenix/startup/2_timezone-start.sh",
 			},
 			&v1.Injection{
-				SrcF: fmt.Sprintf("%s/startup/interfaces-rhel-linux-eth0", baseDir),
-				DstF: "/etc/sysconfig/network-scripts/ifcfg-eth0",
-			},
-			&v1.Injection{
-				SrcF: fmt.Sprintf("%s/startup/interfaces-rhel-linux-eth1", baseDir),
-				DstF: "/etc/sysconfig/network-scripts/ifcfg-eth1",
-			},
-			&v1.Injection{
-				SrcF: fmt.Sprintf("%s/startup/interfaces-rhel-linux-eth2", baseDir),
-				DstF: "/etc/sysconfig/network-scripts/ifcfg-eth2",
+				SrcF: fmt.Sprintf("%s/startup/rhel-linux-interfaces.sh", baseDir),
+				DstF: "/etc/phenix/startup/3_interfaces-start.sh",
 			},
 		},
 		{ // linux
@@ -161,17 +148,21 @@ func TestStartupApp(t *testing.T) {
 			},
 			&v1.Injection{
 				SrcF: fmt.Sprintf("%s/startup/linux-interfaces.sh", baseDir),
-				DstF: "/etc/network/interfaces",
+				DstF: "/etc/phenix/startup/3_interfaces-start.sh",
 			},
 		},
 		{ // windows
 			&v1.Injection{
 				SrcF: fmt.Sprintf("%s/startup/windows-startup.ps1", baseDir),
-				DstF: "startup.ps1",
+				DstF: "/phenix/startup/20-startup.ps1",
+			},
+			&v1.Injection{
+				SrcF: fmt.Sprintf("%s/startup/phenix-startup.ps1", baseDir),
+				DstF: "/phenix/phenix-startup.ps1",
 			},
 			&v1.Injection{
 				SrcF: fmt.Sprintf("%s/startup/startup-scheduler.cmd", baseDir),
-				DstF: "ProgramData/Microsoft/Windows/Start Menu/Programs/StartUp/startup_scheduler.cmd",
+				DstF: "ProgramData/Microsoft/Windows/Start Menu/Programs/Startup/startup_scheduler.cmd",
 			},
 		},
 	}
@@ -187,14 +178,14 @@ func TestStartupApp(t *testing.T) {
 
 	app := GetApp("startup")
 
-	if err := app.PreStart(exp); err != nil {
+	if err := app.PreStart(context.Background(), exp); err != nil {
 		t.Log(err)
 		t.FailNow()
 	}
 
 	checkConfigureExpected(t, spec.Topology().Nodes(), expected)
 
-	if err := app.PreStart(exp); err != nil {
+	if err := app.PreStart(context.Background(), exp); err != nil {
 		t.Log(err)
 		t.FailNow()
 	}
@@ -105,7 +105,7 @@ func (this *Tap) PostStart(ctx context.Context, exp *types.Experiment) error {
 		vlans = append(vlans, t.VLAN)
 	}
 
-	exp.Status.SetAppStatus(this.Name(), status)
+	exp.Modify(func() { exp.Status.SetAppStatus(this.Name(), status) })
 
 	return nil
 }
@@ -115,8 +115,14 @@ func (Tap) Running(ctx context.Context, exp *types.Experiment) error {
 }
 
 func (this *Tap) Cleanup(ctx context.Context, exp *types.Experiment) error {
-	var status TapAppStatus
-	if err := exp.Status.ParseAppStatus(this.Name(), &status); err != nil {
+	var (
+		status TapAppStatus
+		err    error
+	)
+
+	exp.Modify(func() { err = exp.Status.ParseAppStatus(this.Name(), &status) })
+
+	if err != nil {
 		return fmt.Errorf("getting experiment status for %s app: %w", this.Name(), err)
 	}
 
@@ -10,6 +10,7 @@ import (
 	"strings"
 
 	"phenix/scheduler"
+	"phenix/store"
 	"phenix/types"
 	"phenix/util"
 	"phenix/util/common"
@@ -92,9 +93,25 @@ func (this UserApp) shellOut(ctx context.Context, action Action, exp *types.Expe
 		return fmt.Errorf("getting cluster hosts: %w", err)
 	}
 
-	exp.Hosts = cluster
+	var (
+		data, base []byte
+		md         store.ConfigMetadata
+		filesDir   string
+	)
+
+	// The spec sent to the user app is kept as the base for merging the spec it
+	// returns.
+	exp.Modify(func() {
+		exp.Hosts = cluster
+
+		md = exp.Metadata
+		filesDir = exp.FilesDir()
+
+		if data, err = json.Marshal(exp); err == nil {
+			base, err = json.Marshal(exp.Spec)
+		}
+	})
 
-	data, err := json.Marshal(exp)
 	if err != nil {
 		return fmt.Errorf("marshaling experiment to JSON: %w", err)
 	}
@@ -106,7 +123,7 @@ func (this UserApp) shellOut(ctx context.Context, action Action, exp *types.Expe
 		shell.SplitBytes(),
 		shell.Env(
 			"PHENIX_DIR="+common.PhenixBase,
-			"PHENIX_FILES_DIR="+exp.FilesDir(),
+			"PHENIX_FILES_DIR="+filesDir,
 			"PHENIX_LOG_LEVEL="+util.GetEnv("PHENIX_LOG_LEVEL", "DEBUG"),
 			"PHENIX_LOG_FILE="+util.GetEnv("PHENIX_LOG_FILE", common.LogFile),
 			"PHENIX_DRYRUN="+strconv.FormatBool(this.options.DryRun),
@@ -125,7 +142,9 @@ func (this UserApp) shellOut(ctx context.Context, action Action, exp *types.Expe
 			case EXIT_SCHEDULE:
 				sched := strings.TrimSpace(string(stdOut))
 
-				if err := scheduler.Schedule(sched, exp.Spec); err != nil {
+				exp.Modify(func() { err = scheduler.Schedule(sched, exp.Spec) })
+
+				if err != nil {
 					return fmt.Errorf("scheduling experiment with %s: %w", sched, err)
 				}
 
@@ -147,24 +166,25 @@ func (this UserApp) shellOut(ctx context.Context, action Action, exp *types.Expe
 		return nil
 	}
 
-	result := types.NewExperiment(exp.Metadata)
+	result := types.NewExperiment(md)
 
 	if err := json.Unmarshal(stdOut, &result); err != nil {
 		return fmt.Errorf("unmarshaling experiment from JSON: %w", err)
 	}
 
 	switch action {
-	case ACTIONCONFIG, ACTIONPRESTART:
-		exp.SetSpec(result.Spec)
-	case ACTIONPOSTSTART, ACTIONRUNNING:
-		if metadata, ok := result.Status.AppStatus()[this.options.Name]; ok {
-			exp.Status.SetAppStatus(this.options.Name, metadata)
+	case ACTIONCONFIG, ACTIONPRESTART, ACTIONCLEANUP:
+		// Merge rather than replace the spec so changes made by other apps
+		// applied concurrently aren't lost.
+		if err := exp.MergeSpec(base, result.Spec); err != nil {
+			return fmt.Errorf("merging experiment spec from user app %s: %w", this.options.Name, err)
 		}
-	case ACTIONCLEANUP:
-		exp.SetSpec(result.Spec)
+	}
 
+	switch action {
+	case ACTIONPOSTSTART, ACTIONRUNNING, ACTIONCLEANUP:
 		if metadata, ok := result.Status.AppStatus()[this.options.Name]; ok {
-			exp.Status.SetAppStatus(this.options.Name, metadata)
+			exp.Modify(func() { exp.Status.SetAppStatus(this.options.Name, metadata) })
 		}
 	}
 
@@ -6,6 +6,8 @@ import (
 	"testing"
 
 	"phenix/types"
+	"phenix/util/mm"
+	"phenix/util/mm/mmfake"
 	"phenix/util/shell"
 
 	gomock "github.com/golang/mock/gomock"
@@ -13,6 +15,7 @@ import (
 
 func TestUserAppNotFound(t *testing.T) {
 	app := GetApp("foobar")
+	app.Init(Name("foobar"))
 
 	if app.Name() != "foobar" {
 		t.Logf("unexpected user app %s", app.Name())
@@ -22,13 +25,15 @@ func TestUserAppNotFound(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
 
+	defer func(s shell.Shell) { shell.DefaultShell = s }(shell.DefaultShell)
+
 	m := shell.NewMockShell(ctrl)
 
 	shell.DefaultShell = m
 
 	m.EXPECT().CommandExists(gomock.Eq("phenix-app-foobar")).Return(false)
 
-	err := app.Configure(new(types.Experiment))
+	err := app.Configure(context.Background(), new(types.Experiment))
 
 	if err == nil {
 		t.Log("expected error")
@@ -43,6 +48,7 @@ func TestUserAppNotFound(t *testing.T) {
 
 func TestUserAppFound(t *testing.T) {
 	app := GetApp("foobar")
+	app.Init(Name("foobar"))
 
 	if app.Name() != "foobar" {
 		t.Logf("unexpected user app %s", app.Name())
@@ -52,6 +58,12 @@ func TestUserAppFound(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
 
+	defer func(s shell.Shell) { shell.DefaultShell = s }(shell.DefaultShell)
+	defer func(m mm.MM) { mm.DefaultMM = m }(mm.DefaultMM)
+
+	// User apps are passed the cluster hosts.
+	mm.DefaultMM = mmfake.New(mmfake.Headnode("compute0"), mmfake.ClusterHost(mm.Host{Name: "compute0"}))
+
 	m := shell.NewMockShell(ctrl)
 	m.EXPECT().CommandExists(gomock.Eq("phenix-app-foobar")).Return(true)
 
@@ -61,7 +73,7 @@ func TestUserAppFound(t *testing.T) {
 
 	shell.DefaultShell = m
 
-	err := app.Configure(new(types.Experiment))
+	err := app.Configure(context.Background(), new(types.Experiment))
 
 	if err != nil {
 		t.Logf("unexpected error %v", err)
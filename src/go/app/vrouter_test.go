@@ -1,6 +1,7 @@
 package app
 
 import (
+	"context"
 	"fmt"
 	"io/ioutil"
 	"os"
@@ -26,6 +27,9 @@ func TestVrouterApp(t *testing.T) {
 			GeneralF: &v1.General{
 				HostnameF: "router",
 			},
+			HardwareF: &v1.Hardware{
+				OSTypeF: "vyatta",
+			},
 		},
 		{
 			TypeF: "VirtualMachine",
@@ -72,17 +76,17 @@ func TestVrouterApp(t *testing.T) {
 
 	app := GetApp("vrouter")
 
-	if err := app.Configure(exp); err != nil {
+	if err := app.Configure(context.Background(), exp); err != nil {
 		t.Log(err)
 		t.FailNow()
 	}
 
-	checkConfigureExpected(t, spec.Topology().Nodes(), expected)
-
-	if err := app.PreStart(exp); err != nil {
+	if err := app.PreStart(context.Background(), exp); err != nil {
 		t.Log(err)
 		t.FailNow()
 	}
 
+	// router configs are injected at pre-start
+	checkConfigureExpected(t, spec.Topology().Nodes(), expected)
 	checkStartExpected(t, spec.Topology().Nodes(), expected)
 }
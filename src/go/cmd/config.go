@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
 
 	"phenix/api/config"
@@ -331,6 +332,122 @@ func newConfigDeleteCmd() *cobra.Command {
 	return cmd
 }
 
+func newConfigHistoryCmd() *cobra.Command {
+	desc := `Show the revision history of a configuration
+
+  This subcommand is used to show the prior revisions of a configuration kept
+  in the store, along with the current revision. A revision is recorded each
+  time the spec or annotations of a configuration are changed.`
+
+	cmd := &cobra.Command{
+		Use:   "history <kind/name>",
+		Short: "Show the revision history of a configuration",
+		Long:  desc,
+		Args:  configKindArgsValidator(false, false),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			history, err := config.History(args[0])
+			if err != nil {
+				err := util.HumanizeError(err, "Unable to get the revision history for the "+args[0]+" configuration")
+				return err.Humanized()
+			}
+
+			printer.PrintTableOfConfigRevisions(os.Stdout, history)
+
+			return nil
+		},
+	}
+
+	return cmd
+}
+
+func newConfigDiffCmd() *cobra.Command {
+	desc := `Show the differences between two revisions of a configuration
+
+  This subcommand is used to show a unified diff of two revisions of a
+  configuration. Revision numbers can be found using the history subcommand.`
+
+	cmd := &cobra.Command{
+		Use:   "diff <kind/name> <revision> <revision>",
+		Short: "Show the differences between two revisions of a configuration",
+		Long:  desc,
+		Args: func(cmd *cobra.Command, args []string) error {
+			if len(args) != 3 {
+				return fmt.Errorf("Must provide a configuration and two revisions")
+			}
+
+			return configKindArgsValidator(false, false)(cmd, args[:1])
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			from, err := strconv.ParseInt(args[1], 10, 64)
+			if err != nil {
+				return fmt.Errorf("Revisions must be integers")
+			}
+
+			to, err := strconv.ParseInt(args[2], 10, 64)
+			if err != nil {
+				return fmt.Errorf("Revisions must be integers")
+			}
+
+			diff, err := config.Diff(args[0], from, to)
+			if err != nil {
+				err := util.HumanizeError(err, "Unable to diff revisions of the "+args[0]+" configuration")
+				return err.Humanized()
+			}
+
+			if diff == "" {
+				fmt.Printf("Revisions %d and %d of the %s configuration are the same\n", from, to, args[0])
+				return nil
+			}
+
+			fmt.Print(diff)
+
+			return nil
+		},
+	}
+
+	return cmd
+}
+
+func newConfigRollbackCmd() *cobra.Command {
+	desc := `Rollback a configuration to a prior revision
+
+  This subcommand is used to update a configuration to match a prior revision
+  of it. The revision being replaced is kept in the revision history, so a
+  rollback can itself be rolled back. Configurations for running experiments
+  cannot be rolled back.`
+
+	cmd := &cobra.Command{
+		Use:   "rollback <kind/name> <revision>",
+		Short: "Rollback a configuration to a prior revision",
+		Long:  desc,
+		Args: func(cmd *cobra.Command, args []string) error {
+			if len(args) != 2 {
+				return fmt.Errorf("Must provide a configuration and a revision")
+			}
+
+			return configKindArgsValidator(false, false)(cmd, args[:1])
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			rev, err := strconv.ParseInt(args[1], 10, 64)
+			if err != nil {
+				return fmt.Errorf("The revision must be an integer")
+			}
+
+			c, err := config.Rollback(args[0], rev)
+			if err != nil {
+				err := util.HumanizeError(err, "Unable to rollback the "+args[0]+" configuration")
+				return err.Humanized()
+			}
+
+			fmt.Printf("The %s configuration was rolled back to revision %d (now revision %d)\n", args[0], rev, c.Metadata.ResourceVersion)
+
+			return nil
+		},
+	}
+
+	return cmd
+}
+
 func init() {
 	configCmd := newConfigCmd()
 
@@ -339,6 +456,9 @@ func init() {
 	configCmd.AddCommand(newConfigCreateCmd())
 	configCmd.AddCommand(newConfigEditCmd())
 	configCmd.AddCommand(newConfigDeleteCmd())
+	configCmd.AddCommand(newConfigHistoryCmd())
+	configCmd.AddCommand(newConfigDiffCmd())
+	configCmd.AddCommand(newConfigRollbackCmd())
 
 	rootCmd.AddCommand(configCmd)
 }
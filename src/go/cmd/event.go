@@ -3,12 +3,16 @@ package cmd
 import (
 	"fmt"
 	"os"
+	"strings"
+	"time"
 
+	"phenix/api/audit"
 	"phenix/store"
 	"phenix/util"
 	"phenix/util/printer"
 
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
 )
 
 func newEventCmd() *cobra.Command {
@@ -24,31 +28,54 @@ func newEventCmd() *cobra.Command {
 }
 
 func newEventListCmd() *cobra.Command {
+	example := `
+  phenix event list --type error --since 24h
+  phenix event list --source compute1 --metadata exp=foo --limit 50
+  phenix event list --since 2024-01-01T00:00:00Z --until 2024-01-02T00:00:00Z`
+
 	cmd := &cobra.Command{
-		Use:   "list",
-		Short: "Display a table of events",
+		Use:     "list",
+		Short:   "Display a table of events",
+		Example: example,
 		RunE: func(cmd *cobra.Command, args []string) error {
-			events, err := store.GetEvents()
+			query, err := eventQueryFromFlags(cmd.Flags())
+			if err != nil {
+				return err
+			}
+
+			events, err := store.QueryEvents(query)
 			if err != nil {
 				err := util.HumanizeError(err, "Unable to get list of events")
 				return err.Humanized()
 			}
 
-			if len(events) == 0 {
-				fmt.Println("\nThere are no recorded events\n")
+			var show store.Events
+
+			// History events are only shown if asked for, either explicitly or via
+			// the type filter. Audit events are only shown via the type filter (or
+			// the audit subcommand).
+			if len(query.Types) > 0 {
+				show = events
 			} else {
-				var show store.Events
-
-				if MustGetBool(cmd.Flags(), "show-history") {
-					show = events
-				} else {
-					for _, event := range events {
-						if event.Type != store.EventTypeHistory {
-							show = append(show, event)
+				showHistory := MustGetBool(cmd.Flags(), "show-history")
+
+				for _, event := range events {
+					switch event.Type {
+					case store.EventTypeAudit:
+						continue
+					case store.EventTypeHistory:
+						if !showHistory {
+							continue
 						}
 					}
+
+					show = append(show, event)
 				}
+			}
 
+			if len(show) == 0 {
+				fmt.Print("\nThere are no recorded events\n\n")
+			} else {
 				show.SortByTimestamp(true)
 				printer.PrintTableOfEvents(os.Stdout, show, MustGetBool(cmd.Flags(), "show-id"))
 			}
@@ -59,6 +86,12 @@ func newEventListCmd() *cobra.Command {
 
 	cmd.Flags().Bool("show-id", false, "Include event IDs in table")
 	cmd.Flags().Bool("show-history", false, "Include history events in table")
+	cmd.Flags().StringSlice("type", nil, "Only include events of the given type(s) (info, error, unknown, history, audit)")
+	cmd.Flags().String("source", "", "Only include events from the given source")
+	cmd.Flags().StringSlice("metadata", nil, "Only include events with the given metadata (key=value)")
+	cmd.Flags().String("since", "", "Only include events since the given time (RFC3339 timestamp or duration ago)")
+	cmd.Flags().String("until", "", "Only include events before the given time (RFC3339 timestamp or duration ago)")
+	cmd.Flags().Int("limit", 0, "Only include the given number of most recent events")
 
 	return cmd
 }
@@ -83,11 +116,174 @@ func newEventShowCmd() *cobra.Command {
 	return cmd
 }
 
+func newEventAuditCmd() *cobra.Command {
+	desc := `Display a table of audit entries
+
+  Audit entries are recorded for every mutating REST API call, WebSocket broker
+  request, and CLI command, and include who did what, when, from where (api,
+  broker, or cli), and whether it succeeded.`
+
+	example := `
+  phenix event audit --user admin@foo.com --since 24h
+  phenix event audit --experiment foobar --outcome failure
+  phenix event audit --since 2024-01-01T00:00:00Z --until 2024-01-02T00:00:00Z --limit 100`
+
+	cmd := &cobra.Command{
+		Use:     "audit",
+		Short:   "Display a table of audit entries",
+		Long:    desc,
+		Example: example,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			opts := []audit.Option{
+				audit.User(MustGetString(cmd.Flags(), "user")),
+				audit.Experiment(MustGetString(cmd.Flags(), "experiment")),
+				audit.Resource(MustGetString(cmd.Flags(), "resource")),
+				audit.Outcome(MustGetString(cmd.Flags(), "outcome")),
+				audit.Limit(MustGetInt(cmd.Flags(), "limit")),
+			}
+
+			since, err := parseEventTime(MustGetString(cmd.Flags(), "since"))
+			if err != nil {
+				return err
+			}
+
+			until, err := parseEventTime(MustGetString(cmd.Flags(), "until"))
+			if err != nil {
+				return err
+			}
+
+			opts = append(opts, audit.Since(since), audit.Until(until))
+
+			entries, err := audit.List(opts...)
+			if err != nil {
+				err := util.HumanizeError(err, "Unable to get list of audit entries")
+				return err.Humanized()
+			}
+
+			if len(entries) == 0 {
+				fmt.Printf("\nThere are no matching audit entries\n\n")
+			} else {
+				printer.PrintTableOfAuditEntries(os.Stdout, entries)
+			}
+
+			return nil
+		},
+	}
+
+	cmd.Flags().String("user", "", "Only include entries for the given user")
+	cmd.Flags().String("experiment", "", "Only include entries for the given experiment")
+	cmd.Flags().String("resource", "", "Only include entries for the given resource (for example, experiments/start)")
+	cmd.Flags().String("outcome", "", "Only include entries with the given outcome (success, failure)")
+	cmd.Flags().String("since", "", "Only include entries since the given time (RFC3339 timestamp or duration ago)")
+	cmd.Flags().String("until", "", "Only include entries before the given time (RFC3339 timestamp or duration ago)")
+	cmd.Flags().Int("limit", 0, "Only include the given number of most recent entries")
+
+	return cmd
+}
+
+func newEventPruneCmd() *cobra.Command {
+	desc := `Prune old events
+
+  This subcommand is used to delete all events older than the given age from
+  the store. When using a SQLite store, events can also be pruned automatically
+  by adding a retention period to the store endpoint (for example,
+  sqlite:///etc/phenix/store.db?retention=720h).`
+
+	cmd := &cobra.Command{
+		Use:   "prune <age>",
+		Short: "Delete events older than the given age (for example, 720h)",
+		Long:  desc,
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			age, err := time.ParseDuration(args[0])
+			if err != nil {
+				return fmt.Errorf("The age must be a duration (for example, 720h)")
+			}
+
+			pruned, err := store.PruneEvents(time.Now().Add(-age))
+			if err != nil {
+				err := util.HumanizeError(err, "Unable to prune events")
+				return err.Humanized()
+			}
+
+			fmt.Printf("Pruned %d events older than %s\n", pruned, age)
+
+			return nil
+		},
+	}
+
+	return cmd
+}
+
+// eventQueryFromFlags builds an event query from the filter flags of the event
+// list command.
+func eventQueryFromFlags(flags *pflag.FlagSet) (store.EventQuery, error) {
+	query := store.EventQuery{
+		Source: MustGetString(flags, "source"),
+		Limit:  MustGetInt(flags, "limit"),
+	}
+
+	types, _ := flags.GetStringSlice("type")
+
+	for _, t := range types {
+		query.Types = append(query.Types, store.EventType(t))
+	}
+
+	metadata, _ := flags.GetStringSlice("metadata")
+
+	for _, md := range metadata {
+		k, v, ok := strings.Cut(md, "=")
+		if !ok {
+			return query, fmt.Errorf("Metadata filters must be of the form key=value")
+		}
+
+		if query.Metadata == nil {
+			query.Metadata = make(map[string]string)
+		}
+
+		query.Metadata[k] = v
+	}
+
+	var err error
+
+	if query.Since, err = parseEventTime(MustGetString(flags, "since")); err != nil {
+		return query, err
+	}
+
+	if query.Until, err = parseEventTime(MustGetString(flags, "until")); err != nil {
+		return query, err
+	}
+
+	return query, nil
+}
+
+// parseEventTime parses the given time, which can either be an RFC3339
+// timestamp or a duration relative to now (for example, 24h means 24 hours
+// ago). An empty string results in a zero time.
+func parseEventTime(t string) (time.Time, error) {
+	if t == "" {
+		return time.Time{}, nil
+	}
+
+	if d, err := time.ParseDuration(t); err == nil {
+		return time.Now().Add(-d), nil
+	}
+
+	ts, err := time.Parse(time.RFC3339, t)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("Times must be RFC3339 timestamps or durations (for example, 24h)")
+	}
+
+	return ts, nil
+}
+
 func init() {
 	eventCmd := newEventCmd()
 
 	eventCmd.AddCommand(newEventListCmd())
 	eventCmd.AddCommand(newEventShowCmd())
+	eventCmd.AddCommand(newEventAuditCmd())
+	eventCmd.AddCommand(newEventPruneCmd())
 
 	rootCmd.AddCommand(eventCmd)
 }
@@ -8,6 +8,7 @@ import (
 	"path/filepath"
 	"strings"
 	"sync"
+	"time"
 
 	"phenix/api/config"
 	"phenix/api/experiment"
@@ -21,6 +22,7 @@ import (
 	"phenix/util/printer"
 	"phenix/util/sigterm"
 
+	"github.com/olekukonko/tablewriter"
 	"github.com/spf13/cobra"
 )
 
@@ -176,6 +178,7 @@ func newExperimentCreateCmd() *cobra.Command {
 				experiment.CreateWithVLANMax(MustGetInt(cmd.Flags(), "vlan-max")),
 				experiment.CreatedWithDisabledApplications(disabledApps),
 				experiment.CreateWithDefaultBridge(MustGetString(cmd.Flags(), "default-bridge")),
+				experiment.CreateWithOwner(MustGetString(cmd.Flags(), "owner")),
 			}
 
 			ctx := notes.Context(context.Background(), false)
@@ -201,9 +204,176 @@ func newExperimentCreateCmd() *cobra.Command {
 	cmd.Flags().Int("vlan-min", 0, "VLAN pool minimum")
 	cmd.Flags().Int("vlan-max", 0, "VLAN pool maximum")
 	cmd.Flags().StringSlice("disabled-apps", []string{}, "Comma separated ist of apps to disable")
+	cmd.Flags().String("owner", "", "Username of the user that owns the experiment (optional)")
 	return cmd
 }
 
+func newExperimentCloneCmd() *cobra.Command {
+	desc := `Clone an experiment
+
+  Used to create a new experiment from the spec of an existing experiment.
+  The default bridge and any VLAN IDs already in use by other experiments
+  are regenerated for the new experiment, and files in the existing
+  experiment's base directory are copied (or linked) to the new experiment's
+  base directory. Topology and scenario overrides can be provided as JSON
+  patches (RFC 6902), either inline or as paths to files.`
+
+	example := `
+  phenix experiment clone <existing experiment name> <new experiment name>
+  phenix experiment clone <existing experiment name> <new experiment name> -d </path/to/dir/> --files link
+  phenix experiment clone <existing experiment name> <new experiment name> --topology-patch '[{"op": "replace", "path": "/nodes/0/general/hostname", "value": "foo"}]'
+  phenix experiment clone <existing experiment name> <new experiment name> --scenario-patch /path/to/patch.json`
+
+	cmd := &cobra.Command{
+		Use:     "clone <existing experiment name> <new experiment name>",
+		Short:   "Clone an experiment",
+		Long:    desc,
+		Example: example,
+		Args:    cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			opts := []experiment.CloneOption{
+				experiment.CloneWithBaseDirectory(MustGetString(cmd.Flags(), "base-dir")),
+				experiment.CloneWithDefaultBridge(MustGetString(cmd.Flags(), "default-bridge")),
+				experiment.CloneWithFiles(experiment.CloneFilesMode(MustGetString(cmd.Flags(), "files"))),
+				experiment.CloneWithOwner(MustGetString(cmd.Flags(), "owner")),
+			}
+
+			if patch := MustGetString(cmd.Flags(), "topology-patch"); patch != "" {
+				p, err := readJSONPatch(patch)
+				if err != nil {
+					err := util.HumanizeError(err, "Unable to read topology patch")
+					return err.Humanized()
+				}
+
+				opts = append(opts, experiment.CloneWithTopologyPatch(p))
+			}
+
+			if patch := MustGetString(cmd.Flags(), "scenario-patch"); patch != "" {
+				p, err := readJSONPatch(patch)
+				if err != nil {
+					err := util.HumanizeError(err, "Unable to read scenario patch")
+					return err.Humanized()
+				}
+
+				opts = append(opts, experiment.CloneWithScenarioPatch(p))
+			}
+
+			if err := experiment.Clone(args[0], args[1], opts...); err != nil {
+				err := util.HumanizeError(err, "Unable to clone the "+args[0]+" experiment")
+				return err.Humanized()
+			}
+
+			plog.Info("experiment cloned", "exp", args[1], "from", args[0])
+
+			return nil
+		},
+	}
+
+	cmd.Flags().StringP("base-dir", "d", "", "Base directory to use for new experiment (optional)")
+	cmd.Flags().StringP("default-bridge", "b", "", "Default bridge name to use for new experiment (optional)")
+	cmd.Flags().String("files", "copy", "How to clone files in the experiment base directory (copy, link, or none)")
+	cmd.Flags().String("topology-patch", "", "JSON patch (or path to JSON patch file) to apply to the topology (optional)")
+	cmd.Flags().String("scenario-patch", "", "JSON patch (or path to JSON patch file) to apply to the scenario (optional)")
+	cmd.Flags().String("owner", "", "Username of the user that owns the new experiment (optional)")
+
+	return cmd
+}
+
+func newExperimentShareCmd() *cobra.Command {
+	desc := `Share an experiment with other users or roles
+
+  Used to grant other users, or every user with a given role, access to an
+  experiment. What access is granted is determined by the 'shared' ownership
+  policies of each user's role. Shares are given as user:<username> or
+  role:<role name>. If no shares are given, the experiment's owner and current
+  shares are listed.`
+
+	example := `
+  phenix experiment share <experiment name>
+  phenix experiment share <experiment name> user:alice@example.com "role:Experiment Viewer"
+  phenix experiment share <experiment name> user:alice@example.com --revoke`
+
+	cmd := &cobra.Command{
+		Use:     "share <experiment name> [<user|role>:<name>...]",
+		Short:   "Share an experiment with other users or roles",
+		Long:    desc,
+		Example: example,
+		Args:    cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			name := args[0]
+
+			if len(args) == 1 {
+				owner, shares, err := experiment.Ownership(name)
+				if err != nil {
+					err := util.HumanizeError(err, "Unable to get shares for the "+name+" experiment")
+					return err.Humanized()
+				}
+
+				if owner == "" {
+					owner = "(none)"
+				}
+
+				fmt.Printf("Owner: %s\n", owner)
+
+				if len(shares) == 0 {
+					fmt.Println("Not shared with anyone")
+				}
+
+				for _, s := range shares {
+					fmt.Printf("Shared with %s\n", s)
+				}
+
+				return nil
+			}
+
+			revoke := MustGetBool(cmd.Flags(), "revoke")
+
+			for _, arg := range args[1:] {
+				share, err := config.ParseShare(arg)
+				if err != nil {
+					err := util.HumanizeError(err, "Invalid share "+arg)
+					return err.Humanized()
+				}
+
+				if revoke {
+					if err := experiment.Unshare(name, share); err != nil {
+						err := util.HumanizeError(err, "Unable to unshare the "+name+" experiment with "+arg)
+						return err.Humanized()
+					}
+
+					plog.Info("experiment unshared", "exp", name, "share", share.String())
+
+					continue
+				}
+
+				share, err = experiment.Share(name, share)
+				if err != nil {
+					err := util.HumanizeError(err, "Unable to share the "+name+" experiment with "+arg)
+					return err.Humanized()
+				}
+
+				plog.Info("experiment shared", "exp", name, "share", share.String())
+			}
+
+			return nil
+		},
+	}
+
+	cmd.Flags().Bool("revoke", false, "Revoke the given shares instead of granting them")
+
+	return cmd
+}
+
+// readJSONPatch returns the given JSON patch if it's inline JSON, otherwise it
+// returns the contents of the file at the given path.
+func readJSONPatch(patch string) ([]byte, error) {
+	if strings.HasPrefix(strings.TrimSpace(patch), "[") {
+		return []byte(patch), nil
+	}
+
+	return os.ReadFile(patch)
+}
+
 func newExperimentEditCmd() *cobra.Command {
 	desc := `Edit an experiment
 
@@ -720,6 +890,126 @@ func newExperimentScorchCmd() *cobra.Command {
 	return cmd
 }
 
+func newExperimentCheckpointCmd() *cobra.Command {
+	desc := `Checkpoint and restore all the VMs in an experiment
+
+  Used to create point-in-time checkpoints of all the VMs in a running
+  experiment and to restore the experiment to them later on; see command help
+  for create, restore, list, or delete for additional arguments.`
+
+	cmd := &cobra.Command{
+		Use:   "checkpoint",
+		Short: "Checkpoint and restore all the VMs in an experiment",
+		Long:  desc,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return cmd.Help()
+		},
+	}
+
+	progress := func(p experiment.CheckpointProgress) {
+		if p.VM != "" {
+			plog.Info("checkpoint progress", "checkpoint", p.Checkpoint, "vm", p.VM, "percent", fmt.Sprintf("%.0f%%", p.Progress*100))
+		}
+	}
+
+	create := &cobra.Command{
+		Use:   "create <experiment name> [<checkpoint name>]",
+		Short: "Checkpoint all the VMs in a running experiment",
+		Long: `Checkpoint all the VMs in a running experiment
+
+  All the VMs in the experiment are paused while their disk and memory are
+  snapshotted. VMs that were running are resumed once the checkpoint is
+  complete. If a checkpoint name is not provided, one will be generated using
+  the current time.`,
+		Args: cobra.RangeArgs(1, 2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var name string
+
+			if len(args) > 1 {
+				name = args[1]
+			}
+
+			checkpoint, err := experiment.CreateCheckpoint(args[0], name, progress)
+			if err != nil {
+				err := util.HumanizeError(err, "Unable to checkpoint the "+args[0]+" experiment")
+				return err.Humanized()
+			}
+
+			plog.Info("experiment checkpoint created", "exp", args[0], "checkpoint", checkpoint.Name, "vms", len(checkpoint.VMs))
+
+			return nil
+		},
+	}
+
+	restore := &cobra.Command{
+		Use:   "restore <experiment name> <checkpoint name>",
+		Short: "Restore all the VMs in a running experiment to a checkpoint",
+		Args:  cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := experiment.RestoreCheckpoint(args[0], args[1], progress); err != nil {
+				err := util.HumanizeError(err, "Unable to restore the "+args[0]+" experiment to the "+args[1]+" checkpoint")
+				return err.Humanized()
+			}
+
+			plog.Info("experiment checkpoint restored", "exp", args[0], "checkpoint", args[1])
+
+			return nil
+		},
+	}
+
+	list := &cobra.Command{
+		Use:   "list <experiment name>",
+		Short: "Display a table of checkpoints for an experiment",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			checkpoints, err := experiment.Checkpoints(args[0])
+			if err != nil {
+				err := util.HumanizeError(err, "Unable to list checkpoints for the "+args[0]+" experiment")
+				return err.Humanized()
+			}
+
+			if len(checkpoints) == 0 {
+				plog.Warn("no checkpoints available", "exp", args[0])
+				return nil
+			}
+
+			table := tablewriter.NewWriter(os.Stdout)
+			table.SetHeader([]string{"Name", "Created", "VM Count"})
+
+			for _, checkpoint := range checkpoints {
+				table.Append([]string{checkpoint.Name, checkpoint.Created.Format(time.RFC3339), fmt.Sprintf("%d", len(checkpoint.VMs))})
+			}
+
+			table.Render()
+
+			return nil
+		},
+	}
+
+	del := &cobra.Command{
+		Use:   "delete <experiment name> <checkpoint name>",
+		Short: "Delete an experiment checkpoint",
+		Args:  cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := experiment.DeleteCheckpoint(args[0], args[1]); err != nil {
+				err := util.HumanizeError(err, "Unable to delete the "+args[1]+" checkpoint for the "+args[0]+" experiment")
+				return err.Humanized()
+			}
+
+			plog.Info("experiment checkpoint deleted", "exp", args[0], "checkpoint", args[1])
+
+			return nil
+		},
+	}
+
+	cmd.AddCommand(create)
+	cmd.AddCommand(restore)
+	cmd.AddCommand(list)
+	cmd.AddCommand(del)
+
+	return cmd
+}
+
 func init() {
 	experimentCmd := newExperimentCmd()
 
@@ -727,6 +1017,8 @@ func init() {
 	experimentCmd.AddCommand(newExperimentAppsCmd())
 	experimentCmd.AddCommand(newExperimentSchedulersCmd())
 	experimentCmd.AddCommand(newExperimentCreateCmd())
+	experimentCmd.AddCommand(newExperimentCloneCmd())
+	experimentCmd.AddCommand(newExperimentShareCmd())
 	experimentCmd.AddCommand(newExperimentEditCmd())
 	experimentCmd.AddCommand(newExperimentDeleteCmd())
 	experimentCmd.AddCommand(newExperimentScheduleCmd())
@@ -736,6 +1028,7 @@ func init() {
 	experimentCmd.AddCommand(newExperimentReconfigureCmd())
 	experimentCmd.AddCommand(newExperimentTriggerRunningCmd())
 	experimentCmd.AddCommand(newExperimentScorchCmd())
+	experimentCmd.AddCommand(newExperimentCheckpointCmd())
 
 	rootCmd.AddCommand(experimentCmd)
 }
@@ -54,7 +54,7 @@ func newImageListCmd() *cobra.Command {
 			}
 
 			if len(imgs) == 0 {
-				fmt.Println("\nThere are no image configurations available\n")
+				fmt.Print("\nThere are no image configurations available\n\n")
 			} else {
 				printer.PrintTableOfImageConfigs(os.Stdout, optional, imgs...)
 			}
@@ -11,14 +11,18 @@ import (
 	"os/user"
 	"path/filepath"
 	"strings"
+	"time"
 
+	"phenix/api/audit"
 	"phenix/api/config"
 	_ "phenix/api/scorch"
+	"phenix/scheduler"
 	"phenix/store"
 	"phenix/util"
 	"phenix/util/common"
 	"phenix/util/plog"
 	"phenix/web"
+	"phenix/web/rbac"
 
 	"github.com/fsnotify/fsnotify"
 	"github.com/spf13/cobra"
@@ -26,6 +30,9 @@ import (
 )
 
 var (
+	// set once the store has been initialized so CLI commands can be audited
+	storeInitialized bool
+
 	phenixBase       string
 	minimegaBase     string
 	hostnameSuffixes string
@@ -103,6 +110,21 @@ var rootCmd = &cobra.Command{
 		common.MinimegaBase = viper.GetString("base-dir.minimega")
 		common.HostnameSuffixes = viper.GetString("hostname-suffixes")
 
+		var overCommit []scheduler.Option
+
+		for _, ratios := range viper.GetStringSlice("scheduler.overcommit") {
+			host, r, err := scheduler.ParseOverCommit(ratios)
+			if err != nil {
+				return fmt.Errorf("parsing scheduler over-commit ratios: %w", err)
+			}
+
+			overCommit = append(overCommit, scheduler.OverCommitRatios(host, r))
+		}
+
+		if err := scheduler.Init("best-fit", overCommit...); err != nil {
+			return fmt.Errorf("initializing best-fit scheduler: %w", err)
+		}
+
 		var (
 			endpoint = viper.GetString("store.endpoint")
 			errFile  = viper.GetString("log.error-file")
@@ -116,6 +138,8 @@ var rootCmd = &cobra.Command{
 			return fmt.Errorf("initializing storage: %w", err)
 		}
 
+		storeInitialized = true
+
 		if err := util.InitFatalLogWriter(errFile, errOut); err != nil {
 			return fmt.Errorf("unable to initialize fatal log writer: %w", err)
 		}
@@ -137,7 +161,13 @@ var rootCmd = &cobra.Command{
 }
 
 func Execute() {
-	if err := rootCmd.Execute(); err != nil {
+	start := time.Now()
+
+	cmd, err := rootCmd.ExecuteC()
+
+	auditCommand(cmd, start, err)
+
+	if err != nil {
 		os.Exit(1)
 	}
 }
@@ -197,6 +227,7 @@ func init() {
 	rootCmd.PersistentFlags().String("bridge-mode", "", "bridge naming mode for experiments ('auto' uses experiment name for bridge; 'manual' uses user-specified bridge name, or 'phenix' if not specified) (options: manual | auto)")
 	rootCmd.PersistentFlags().String("deploy-mode", "", "deploy mode for minimega VMs (options: all | no-headnode | only-headnode)")
 	rootCmd.PersistentFlags().Bool("use-gre-mesh", false, "use GRE tunnels between mesh nodes for VLAN trunking")
+	rootCmd.PersistentFlags().StringSlice("scheduler.overcommit", nil, "CPU, memory, and disk over-commit ratios used by the best-fit scheduler, optionally per host (format: [<host>=]<cpu>:<memory>:<disk>)")
 	rootCmd.PersistentFlags().String("unix-socket", "/tmp/phenix.sock", "phēnix unix socket to listen on (ui subcommand) or connect to")
 
 	if uid == "0" {
@@ -245,3 +276,97 @@ func getCurrentUserInfo() (string, string) {
 
 	return uid, home
 }
+
+// Commands that don't change anything (or, like the UI server, audit their
+// own actions) are not audited.
+var auditSkipCommands = map[string]struct{}{
+	"list": {}, "get": {}, "show": {}, "info": {}, "diff": {}, "history": {},
+	"apps": {}, "schedulers": {}, "export": {}, "audit": {}, "app-json": {},
+	"role-table": {}, "help": {}, "version": {}, "completion": {}, "ui": {},
+	"usage": {},
+}
+
+// auditCommand records an audit entry for the given executed command if it's
+// a command that changes things.
+func auditCommand(cmd *cobra.Command, start time.Time, err error) {
+	if cmd == nil || !storeInitialized || cmd.HasSubCommands() {
+		return
+	}
+
+	if _, ok := auditSkipCommands[cmd.Name()]; ok {
+		return
+	}
+
+	var (
+		path = strings.Fields(cmd.CommandPath())[1:]
+		args = cmd.Flags().Args()
+		user = currentUsername()
+	)
+
+	entry := audit.Entry{
+		Origin:   audit.OriginCLI,
+		User:     user,
+		Role:     cliRole(user),
+		Verb:     cmd.Name(),
+		Resource: strings.Join(path[:len(path)-1], "/"),
+		Digest:   audit.Digest([]byte(strings.Join(os.Args[1:], "\x00"))),
+		Outcome:  audit.OutcomeSuccess,
+		Duration: time.Since(start),
+	}
+
+	if entry.Resource == "" {
+		entry.Resource = cmd.Name()
+	}
+
+	if len(args) > 0 {
+		entry.ResourceName = args[0]
+
+		switch path[0] {
+		case "experiment":
+			entry.Experiment = args[0]
+		case "vm":
+			entry.Experiment = args[0]
+
+			if len(args) > 1 {
+				entry.ResourceName = args[0] + "/" + args[1]
+			}
+		}
+	}
+
+	if err != nil {
+		entry.Outcome = audit.OutcomeFailure
+	}
+
+	if err := audit.Record(entry); err != nil {
+		plog.Debug("recording audit entry for command", "command", cmd.CommandPath(), "err", err)
+	}
+}
+
+// currentUsername returns the name of the user running phenix, preferring the
+// user that ran sudo (if any).
+// The CLI isn't subject to RBAC, so commands run by local users that don't
+// have a matching phenix user are audited as being run with this role.
+const unrestrictedRole = "unrestricted"
+
+// cliRole returns the name of the role of the phenix user with the same name
+// as the given local user, if there is one.
+func cliRole(username string) string {
+	user, err := rbac.GetUser(username)
+	if err != nil || user.Spec.Role == nil {
+		return unrestrictedRole
+	}
+
+	return user.RoleName()
+}
+
+func currentUsername() string {
+	if sudo := os.Getenv("SUDO_USER"); sudo != "" && os.Geteuid() == 0 {
+		return sudo
+	}
+
+	if u, err := user.Current(); err == nil {
+		return u.Username
+	}
+
+	return "unknown"
+}
@@ -0,0 +1,120 @@
+package cmd
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"phenix/api/audit"
+	"phenix/store"
+	v1 "phenix/types/version/v1"
+	"phenix/web/rbac"
+
+	"github.com/spf13/cobra"
+)
+
+// executeTestCommand executes the given command line against a minimal phenix
+// command tree, returning the command that ran.
+func executeTestCommand(t *testing.T, args ...string) *cobra.Command {
+	root := &cobra.Command{Use: "phenix"}
+
+	for _, parent := range []string{"experiment", "vm"} {
+		p := &cobra.Command{Use: parent}
+
+		for _, child := range []string{"start", "kill", "list"} {
+			p.AddCommand(&cobra.Command{Use: child, RunE: func(*cobra.Command, []string) error { return nil }})
+		}
+
+		root.AddCommand(p)
+	}
+
+	root.SetArgs(args)
+
+	cmd, err := root.ExecuteC()
+	if err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+
+	return cmd
+}
+
+func TestAuditCommand(t *testing.T) {
+	store.DefaultStore = store.NewBoltDB()
+
+	if err := store.DefaultStore.Init(store.Endpoint("bolt://" + filepath.Join(t.TempDir(), "phenix.bdb"))); err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+
+	storeInitialized = true
+	defer func() { storeInitialized = false }()
+
+	// None of these should be audited.
+	auditCommand(executeTestCommand(t, "experiment", "list"), time.Now(), nil)
+	auditCommand(executeTestCommand(t, "experiment"), time.Now(), nil)
+
+	entries, err := audit.List()
+	if err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+
+	if len(entries) != 0 {
+		t.Logf("expected no audit entries, got %+v", entries)
+		t.FailNow()
+	}
+
+	auditCommand(executeTestCommand(t, "experiment", "start", "foo"), time.Now(), nil)
+
+	// Local users with a matching phenix user are audited with that user's role.
+	user := rbac.NewUser(currentUsername(), "password")
+
+	if err := user.SetRole(&rbac.Role{Spec: &v1.RoleSpec{Name: "VM Viewer"}}); err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+
+	auditCommand(executeTestCommand(t, "vm", "kill", "foo", "bar"), time.Now(), errors.New("kill failed"))
+
+	entries, err = audit.List()
+	if err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+
+	if len(entries) != 2 {
+		t.Logf("expected 2 audit entries, got %d", len(entries))
+		t.FailNow()
+	}
+
+	expected := []audit.Entry{
+		{
+			Origin: audit.OriginCLI, User: currentUsername(), Role: "VM Viewer", Verb: "kill",
+			Resource: "vm", ResourceName: "foo/bar", Experiment: "foo", Outcome: audit.OutcomeFailure,
+		},
+		{
+			Origin: audit.OriginCLI, User: currentUsername(), Role: unrestrictedRole, Verb: "start",
+			Resource: "experiment", ResourceName: "foo", Experiment: "foo", Outcome: audit.OutcomeSuccess,
+		},
+	}
+
+	// Entries are listed most recent first.
+	for i, entry := range entries {
+		if entry.Digest == "" {
+			t.Logf("expected audit entry for %s to include digest of command", entry.Verb)
+			t.FailNow()
+		}
+
+		entry.ID = ""
+		entry.Timestamp = time.Time{}
+		entry.Digest = ""
+		entry.Duration = 0
+
+		if entry != expected[i] {
+			t.Logf("expected audit entry %+v, got %+v", expected[i], entry)
+			t.FailNow()
+		}
+	}
+}
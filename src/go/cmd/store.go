@@ -0,0 +1,243 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"phenix/api/config"
+	"phenix/store"
+	"phenix/util"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+func newStoreCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "store",
+		Short: "Config and event store management",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return cmd.Help()
+		},
+	}
+
+	return cmd
+}
+
+func newStoreMigrateCmd() *cobra.Command {
+	desc := `Migrate configs and events between stores
+
+  This subcommand is used to copy all configurations and events from one store
+  to another, for example when moving from a BoltDB store to a SQLite store.
+  Store endpoints use the same format as the global --store.endpoint flag
+  (bolt://, etcd://, or sqlite://). Configurations and events that already
+  exist in the destination store are skipped, so an interrupted migration can
+  be run again. Configuration revision history is not migrated.`
+
+	example := `
+  phenix store migrate --from bolt:///etc/phenix/store.bdb --to sqlite:///etc/phenix/store.db`
+
+	cmd := &cobra.Command{
+		Use:     "migrate",
+		Short:   "Migrate configs and events between stores",
+		Long:    desc,
+		Example: example,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var (
+				from = MustGetString(cmd.Flags(), "from")
+				to   = MustGetString(cmd.Flags(), "to")
+			)
+
+			if from == "" || to == "" {
+				return fmt.Errorf("Must provide both --from and --to store endpoints")
+			}
+
+			if from == to {
+				return fmt.Errorf("The --from and --to store endpoints must be different")
+			}
+
+			src, err := store.Open(store.Endpoint(from))
+			if err != nil {
+				err := util.HumanizeError(err, "Unable to open the "+from+" store")
+				return err.Humanized()
+			}
+
+			dst, err := store.Open(store.Endpoint(to))
+			if err != nil {
+				err := util.HumanizeError(err, "Unable to open the "+to+" store")
+				return err.Humanized()
+			}
+
+			result, err := store.Migrate(src, dst, config.AllKinds...)
+			if err != nil {
+				err := util.HumanizeError(err, "Unable to migrate the "+from+" store")
+				return err.Humanized()
+			}
+
+			fmt.Printf(
+				"Migrated %d configs and %d events to %s (%d already existed)\n",
+				result.Configs, result.Events, to, result.Skipped,
+			)
+
+			return nil
+		},
+	}
+
+	cmd.Flags().String("from", "", "Endpoint of the store to migrate from")
+	cmd.Flags().String("to", "", "Endpoint of the store to migrate to")
+
+	return cmd
+}
+
+func newStoreExportCmd() *cobra.Command {
+	desc := `Export configs and events to an archive
+
+  This subcommand is used to back up the store by writing all configurations
+  and events to a versioned, gzipped tarball. The archive is written to STDOUT
+  if no output file is provided. Use the --kind and --name flags to limit the
+  configurations exported (names can be of the form name or kind/name).`
+
+	example := `
+  phenix store export --output phenix-backup.tgz
+  phenix store export --kind topology --kind scenario --no-events > configs.tgz`
+
+	cmd := &cobra.Command{
+		Use:     "export",
+		Short:   "Export configs and events to an archive",
+		Long:    desc,
+		Example: example,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			out := os.Stdout
+
+			if path := MustGetString(cmd.Flags(), "output"); path != "" && path != "-" {
+				// The archive includes user configs (with password and API token
+				// hashes), so don't let anyone else read it.
+				f, err := os.OpenFile(path, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+				if err != nil {
+					return fmt.Errorf("Unable to create output file %s: %w", path, err)
+				}
+
+				defer f.Close()
+
+				out = f
+			}
+
+			result, err := config.Export(out, archiveOptionsFromFlags(cmd.Flags())...)
+			if err != nil {
+				err := util.HumanizeError(err, "Unable to export the store")
+				return err.Humanized()
+			}
+
+			if out != os.Stdout {
+				fmt.Printf("Exported %d configs and %d events to %s\n", result.Configs, result.Events, out.Name())
+			}
+
+			return nil
+		},
+	}
+
+	cmd.Flags().StringP("output", "o", "", "File to write the archive to (defaults to STDOUT)")
+	cmd.Flags().StringSlice("kind", nil, "Only export configs of the given kind(s)")
+	cmd.Flags().StringSlice("name", nil, "Only export configs with the given name(s)")
+	cmd.Flags().Bool("no-events", false, "Don't export events")
+
+	return cmd
+}
+
+func newStoreImportCmd() *cobra.Command {
+	desc := `Import configs and events from an archive
+
+  This subcommand is used to restore configurations and events from an archive
+  created by the export subcommand. The archive is read from STDIN if the file
+  is "-". By default, configurations that already exist in the store are left
+  as-is (merged); use --replace to overwrite them with the archived versions.
+  Use --upgrade to upgrade configurations from older versions of phenix to the
+  latest version when possible.`
+
+	example := `
+  phenix store import phenix-backup.tgz
+  phenix store import --replace --upgrade --kind scenario phenix-backup.tgz`
+
+	cmd := &cobra.Command{
+		Use:     "import <archive>",
+		Short:   "Import configs and events from an archive",
+		Long:    desc,
+		Example: example,
+		Args:    cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			in := os.Stdin
+
+			if args[0] != "-" {
+				f, err := os.Open(args[0])
+				if err != nil {
+					return fmt.Errorf("Unable to open archive %s: %w", args[0], err)
+				}
+
+				defer f.Close()
+
+				in = f
+			}
+
+			opts := archiveOptionsFromFlags(cmd.Flags())
+
+			if MustGetBool(cmd.Flags(), "upgrade") {
+				opts = append(opts, config.ArchiveWithUpgrade())
+			}
+
+			if MustGetBool(cmd.Flags(), "replace") {
+				opts = append(opts, config.ArchiveWithReplace())
+			}
+
+			result, err := config.Import(in, opts...)
+			if err != nil {
+				err := util.HumanizeError(err, "Unable to import the "+args[0]+" archive")
+				return err.Humanized()
+			}
+
+			fmt.Printf(
+				"Imported %d new configs, replaced %d configs, upgraded %d configs, and imported %d events (%d skipped)\n",
+				result.Created, result.Replaced, result.Upgraded, result.Events, result.Skipped,
+			)
+
+			return nil
+		},
+	}
+
+	cmd.Flags().StringSlice("kind", nil, "Only import configs of the given kind(s)")
+	cmd.Flags().StringSlice("name", nil, "Only import configs with the given name(s)")
+	cmd.Flags().Bool("no-events", false, "Don't import events")
+	cmd.Flags().Bool("upgrade", false, "Upgrade configs to the latest version when possible")
+	cmd.Flags().Bool("replace", false, "Replace existing configs instead of keeping them")
+
+	return cmd
+}
+
+// archiveOptionsFromFlags builds the archive options shared by the export and
+// import subcommands.
+func archiveOptionsFromFlags(flags *pflag.FlagSet) []config.ArchiveOption {
+	var opts []config.ArchiveOption
+
+	if kinds, _ := flags.GetStringSlice("kind"); len(kinds) > 0 {
+		opts = append(opts, config.ArchiveKinds(kinds...))
+	}
+
+	if names, _ := flags.GetStringSlice("name"); len(names) > 0 {
+		opts = append(opts, config.ArchiveNames(names...))
+	}
+
+	if MustGetBool(flags, "no-events") {
+		opts = append(opts, config.ArchiveWithoutEvents())
+	}
+
+	return opts
+}
+
+func init() {
+	storeCmd := newStoreCmd()
+
+	storeCmd.AddCommand(newStoreMigrateCmd())
+	storeCmd.AddCommand(newStoreExportCmd())
+	storeCmd.AddCommand(newStoreImportCmd())
+
+	rootCmd.AddCommand(storeCmd)
+}
@@ -9,6 +9,7 @@ import (
 	"phenix/util/common"
 	"phenix/util/plog"
 	"phenix/web"
+	"phenix/web/oidc"
 
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
@@ -29,6 +30,7 @@ func newUICmd() *cobra.Command {
 
 			opts := []web.ServerOption{
 				web.ServeOnEndpoint(viper.GetString("ui.listen-endpoint")),
+				web.ServeGRPCOnEndpoint(viper.GetString("ui.grpc-endpoint")),
 				web.ServeBasePath(viper.GetString("ui.base-path")),
 				web.ServeWithJWTKey(viper.GetString("ui.jwt-signing-key")),
 				web.ServeWithJWTLifetime(viper.GetDuration("ui.jwt-lifetime")),
@@ -40,6 +42,29 @@ func newUICmd() *cobra.Command {
 				web.ServeWithUnixSocketGid(viper.GetInt("unix-socket-gid")),
 			}
 
+			if issuer := viper.GetString("ui.oidc.issuer"); issuer != "" {
+				config := oidc.Config{
+					Issuer:        issuer,
+					ClientID:      viper.GetString("ui.oidc.client-id"),
+					ClientSecret:  viper.GetString("ui.oidc.client-secret"),
+					RedirectURL:   viper.GetString("ui.oidc.redirect-url"),
+					Scopes:        viper.GetStringSlice("ui.oidc.scopes"),
+					UsernameClaim: viper.GetString("ui.oidc.username-claim"),
+					DefaultRole:   viper.GetString("ui.oidc.default-role"),
+				}
+
+				for _, m := range viper.GetStringSlice("ui.oidc.role-mappings") {
+					mapping, err := oidc.ParseRoleMapping(m)
+					if err != nil {
+						return fmt.Errorf("parsing OIDC role mapping: %w", err)
+					}
+
+					config.RoleMappings = append(config.RoleMappings, mapping)
+				}
+
+				opts = append(opts, web.ServeWithOIDC(config))
+			}
+
 			if endpoint := viper.GetString("ui.unix-socket-endpoint"); endpoint != "" {
 				plog.Warn("The --ui.unix-socket-endpoint option for the ui subcommand is DEPRECATED. Use the root phenix --unix-socket option instead.")
 
@@ -95,11 +120,20 @@ func newUICmd() *cobra.Command {
 	}
 
 	cmd.Flags().StringP("listen-endpoint", "e", "0.0.0.0:3000", "endpoint to listen on")
+	cmd.Flags().String("grpc-endpoint", "", "endpoint to serve the gRPC API on (disabled if empty)")
 	cmd.Flags().String("unix-socket-endpoint", "", "unix socket path to listen on - DEPRECATED (use root --unix-socket option instead)")
 	cmd.Flags().StringP("base-path", "b", "/", "base path to use for UI (must run behind proxy if not '/')")
 	cmd.Flags().StringP("jwt-signing-key", "k", "", "Secret key used to sign JWT for authentication")
 	cmd.Flags().Duration("jwt-lifetime", 24*time.Hour, "Lifetime of JWT authentication tokens")
 	cmd.Flags().String("proxy-auth-header", "", "header containing username when using proxy authentication")
+	cmd.Flags().String("oidc.issuer", "", "OpenID Connect issuer URL to allow UI logins from (disabled if empty)")
+	cmd.Flags().String("oidc.client-id", "", "OpenID Connect client ID")
+	cmd.Flags().String("oidc.client-secret", "", "OpenID Connect client secret (optional for public clients)")
+	cmd.Flags().String("oidc.redirect-url", "", "OpenID Connect redirect URL (must end in /api/v1/login/oidc/callback)")
+	cmd.Flags().StringSlice("oidc.scopes", nil, "OpenID Connect scopes to request in addition to openid (default profile,email)")
+	cmd.Flags().String("oidc.username-claim", "", "ID token claim to use as username (default sub)")
+	cmd.Flags().StringSlice("oidc.role-mappings", nil, "list of ID token claim to role mappings of the form <claim>=<value>:<role>[:<resource name>...]")
+	cmd.Flags().String("oidc.default-role", "", "role to give OpenID Connect users not matching a role mapping (denied if empty)")
 	cmd.Flags().StringSlice("users", nil, "pipe-delimited list of initial users to add")
 	cmd.Flags().String("tls-key", "", "path to TLS key file")
 	cmd.Flags().String("tls-cert", "", "path to TLS cert file")
@@ -114,11 +148,20 @@ func newUICmd() *cobra.Command {
 	cmd.Flags().Bool("minimega-console", false, "enable minimega console access in UI")
 
 	viper.BindPFlag("ui.listen-endpoint", cmd.Flags().Lookup("listen-endpoint"))
+	viper.BindPFlag("ui.grpc-endpoint", cmd.Flags().Lookup("grpc-endpoint"))
 	viper.BindPFlag("ui.unix-socket-endpoint", cmd.Flags().Lookup("unix-socket-endpoint"))
 	viper.BindPFlag("ui.base-path", cmd.Flags().Lookup("base-path"))
 	viper.BindPFlag("ui.jwt-signing-key", cmd.Flags().Lookup("jwt-signing-key"))
 	viper.BindPFlag("ui.jwt-lifetime", cmd.Flags().Lookup("jwt-lifetime"))
 	viper.BindPFlag("ui.proxy-auth-header", cmd.Flags().Lookup("proxy-auth-header"))
+	viper.BindPFlag("ui.oidc.issuer", cmd.Flags().Lookup("oidc.issuer"))
+	viper.BindPFlag("ui.oidc.client-id", cmd.Flags().Lookup("oidc.client-id"))
+	viper.BindPFlag("ui.oidc.client-secret", cmd.Flags().Lookup("oidc.client-secret"))
+	viper.BindPFlag("ui.oidc.redirect-url", cmd.Flags().Lookup("oidc.redirect-url"))
+	viper.BindPFlag("ui.oidc.scopes", cmd.Flags().Lookup("oidc.scopes"))
+	viper.BindPFlag("ui.oidc.username-claim", cmd.Flags().Lookup("oidc.username-claim"))
+	viper.BindPFlag("ui.oidc.role-mappings", cmd.Flags().Lookup("oidc.role-mappings"))
+	viper.BindPFlag("ui.oidc.default-role", cmd.Flags().Lookup("oidc.default-role"))
 	viper.BindPFlag("ui.users", cmd.Flags().Lookup("users"))
 	viper.BindPFlag("ui.tls-key", cmd.Flags().Lookup("tls-key"))
 	viper.BindPFlag("ui.tls-cert", cmd.Flags().Lookup("tls-cert"))
@@ -132,11 +175,20 @@ func newUICmd() *cobra.Command {
 	viper.BindPFlag("ui.minimega-console", cmd.Flags().Lookup("minimega-console"))
 
 	viper.BindEnv("ui.listen-endpoint")
+	viper.BindEnv("ui.grpc-endpoint")
 	viper.BindEnv("ui.unix-socket-endpoint")
 	viper.BindEnv("ui.base-path")
 	viper.BindEnv("ui.jwt-signing-key")
 	viper.BindEnv("ui.jwt-lifetime")
 	viper.BindEnv("ui.proxy-auth-header")
+	viper.BindEnv("ui.oidc.issuer")
+	viper.BindEnv("ui.oidc.client-id")
+	viper.BindEnv("ui.oidc.client-secret")
+	viper.BindEnv("ui.oidc.redirect-url")
+	viper.BindEnv("ui.oidc.scopes")
+	viper.BindEnv("ui.oidc.username-claim")
+	viper.BindEnv("ui.oidc.role-mappings")
+	viper.BindEnv("ui.oidc.default-role")
 	viper.BindEnv("ui.users")
 	viper.BindEnv("ui.tls-key")
 	viper.BindEnv("ui.tls-cert")
@@ -0,0 +1,254 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"phenix/api/quota"
+	v1 "phenix/types/version/v1"
+	"phenix/util"
+	"phenix/util/printer"
+	"phenix/web/rbac"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+func newUserCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "user",
+		Short: "User management",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return cmd.Help()
+		},
+	}
+
+	return cmd
+}
+
+func newUserUsageCmd() *cobra.Command {
+	desc := `Show resource usage for a user
+
+  This subcommand is used to show the cluster resources a user is currently
+  consuming, computed from the running experiments the user started, along
+  with the limits of the user's quota (if any). Quotas are configured on roles
+  and users via their configs (e.g., ` + "`phenix config edit user/<username>`" + `).`
+
+	cmd := &cobra.Command{
+		Use:   "usage <username>",
+		Short: "Show resource usage and quota for a user",
+		Long:  desc,
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			user, err := rbac.GetUser(args[0])
+			if err != nil {
+				err := util.HumanizeError(err, "Unable to get the "+args[0]+" user")
+				return err.Humanized()
+			}
+
+			usage, err := quota.Get(args[0])
+			if err != nil {
+				err := util.HumanizeError(err, "Unable to get usage for the "+args[0]+" user")
+				return err.Humanized()
+			}
+
+			printer.PrintTableOfUsage(os.Stdout, user.Quota(), usage)
+
+			return nil
+		},
+	}
+
+	return cmd
+}
+
+func newUserTokenCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "token",
+		Short: "Manage user API tokens",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return cmd.Help()
+		},
+	}
+
+	return cmd
+}
+
+func newUserTokenListCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "list <username>",
+		Short: "List API tokens for a user",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			user, err := rbac.GetUser(args[0])
+			if err != nil {
+				err := util.HumanizeError(err, "Unable to get the "+args[0]+" user")
+				return err.Humanized()
+			}
+
+			if len(user.Spec.APITokens) == 0 {
+				fmt.Printf("\nThere are no API tokens for the %s user\n\n", args[0])
+			} else {
+				printer.PrintTableOfAPITokens(os.Stdout, user.Spec.APITokens)
+			}
+
+			return nil
+		},
+	}
+
+	return cmd
+}
+
+func newUserTokenCreateCmd() *cobra.Command {
+	desc := `Create an API token for a user
+
+  This subcommand is used to create a named API token for a user. API tokens
+  must have a lifetime, given as a duration (e.g. 12h) or a number of days, and
+  can be restricted to a subset of the user's role using one or more --policy
+  flags of the form <resources>:<verbs>[:<resource names>], where each part is
+  a comma-separated list. The token is signed using the UI's JWT signing key
+  and is only displayed once.`
+
+	example := `
+  phenix user token create admin@foo.com --name ci --lifetime 30
+  phenix user token create admin@foo.com --name screenshots --lifetime 12h --policy vms,vms/screenshot:list,get:exp1_*`
+
+	cmd := &cobra.Command{
+		Use:     "create <username>",
+		Short:   "Create an API token for a user",
+		Long:    desc,
+		Example: example,
+		Args:    cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var (
+				name     = MustGetString(cmd.Flags(), "name")
+				lifetime = MustGetString(cmd.Flags(), "lifetime")
+				key      = MustGetString(cmd.Flags(), "jwt-signing-key")
+			)
+
+			if name == "" {
+				return fmt.Errorf("Must provide a token name")
+			}
+
+			if key == "" {
+				key = viper.GetString("ui.jwt-signing-key")
+			}
+
+			if key == "" {
+				return fmt.Errorf("Must provide the UI's JWT signing key")
+			}
+
+			dur, err := time.ParseDuration(lifetime)
+			if err != nil {
+				days, err := strconv.Atoi(lifetime)
+				if err != nil {
+					return fmt.Errorf("Invalid token lifetime %s", lifetime)
+				}
+
+				dur = time.Duration(days) * 24 * time.Hour
+			}
+
+			var policies []*v1.PolicySpec
+
+			flags, _ := cmd.Flags().GetStringArray("policy")
+
+			for _, p := range flags {
+				policy, err := parseTokenPolicy(p)
+				if err != nil {
+					return err
+				}
+
+				policies = append(policies, policy)
+			}
+
+			user, err := rbac.GetUser(args[0])
+			if err != nil {
+				err := util.HumanizeError(err, "Unable to get the "+args[0]+" user")
+				return err.Humanized()
+			}
+
+			signed, token, err := user.CreateAPIToken(key, name, dur, nil, policies...)
+			if err != nil {
+				err := util.HumanizeError(err, "Unable to create API token for the "+args[0]+" user")
+				return err.Humanized()
+			}
+
+			fmt.Printf("Created API token %s (%s) for the %s user, expiring %s:\n\n%s\n", token.Name, token.ID, args[0], token.Expires, signed)
+
+			return nil
+		},
+	}
+
+	cmd.Flags().StringP("name", "n", "", "Name of the token")
+	cmd.Flags().StringP("lifetime", "l", "", "Lifetime of the token as a duration or number of days (required)")
+	cmd.Flags().StringArrayP("policy", "p", nil, "Policy to restrict the token to (can be repeated)")
+	cmd.Flags().String("jwt-signing-key", "", "Secret key used to sign JWT (defaults to ui.jwt-signing-key)")
+
+	cmd.MarkFlagRequired("lifetime")
+
+	return cmd
+}
+
+func newUserTokenRevokeCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "revoke <username> <token id>",
+		Short: "Revoke an API token for a user",
+		Args:  cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			user, err := rbac.GetUser(args[0])
+			if err != nil {
+				err := util.HumanizeError(err, "Unable to get the "+args[0]+" user")
+				return err.Humanized()
+			}
+
+			if err := user.RevokeAPIToken(args[1]); err != nil {
+				err := util.HumanizeError(err, "Unable to revoke the "+args[1]+" API token")
+				return err.Humanized()
+			}
+
+			fmt.Printf("The %s API token for the %s user was revoked\n", args[1], args[0])
+
+			return nil
+		},
+	}
+
+	return cmd
+}
+
+// parseTokenPolicy parses a policy of the form
+// <resources>:<verbs>[:<resource names>], where each part is a comma-separated
+// list.
+func parseTokenPolicy(p string) (*v1.PolicySpec, error) {
+	parts := strings.Split(p, ":")
+
+	if len(parts) < 2 || len(parts) > 3 || parts[0] == "" || parts[1] == "" {
+		return nil, fmt.Errorf("Policy %s must be of the form <resources>:<verbs>[:<resource names>]", p)
+	}
+
+	policy := &v1.PolicySpec{
+		Resources: strings.Split(parts[0], ","),
+		Verbs:     strings.Split(parts[1], ","),
+	}
+
+	if len(parts) == 3 && parts[2] != "" {
+		policy.ResourceNames = strings.Split(parts[2], ",")
+	}
+
+	return policy, nil
+}
+
+func init() {
+	userCmd := newUserCmd()
+	tokenCmd := newUserTokenCmd()
+
+	tokenCmd.AddCommand(newUserTokenListCmd())
+	tokenCmd.AddCommand(newUserTokenCreateCmd())
+	tokenCmd.AddCommand(newUserTokenRevokeCmd())
+
+	userCmd.AddCommand(newUserUsageCmd())
+	userCmd.AddCommand(tokenCmd)
+
+	rootCmd.AddCommand(userCmd)
+}
@@ -0,0 +1,360 @@
+package scheduler
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	ifaces "phenix/types/interfaces"
+	"phenix/util/mm"
+	"phenix/util/plog"
+)
+
+// ErrInsufficientResources is returned by the best-fit scheduler when a VM
+// cannot be placed on any cluster host.
+var ErrInsufficientResources = errors.New("insufficient cluster resources")
+
+// HostImages returns the sizes (in bytes) of the VM disk images present on each
+// cluster host, keyed by host name and then image file name. It's set by the
+// api/cluster package, which can't be imported here without an import cycle.
+// Disk is not considered by the best-fit scheduler if it isn't set.
+var HostImages func() (map[string]map[string]int, error)
+
+func init() {
+	schedulers["best-fit"] = &bestFit{options: NewOptions()}
+}
+
+// bestFit packs VMs onto cluster hosts by considering vCPUs, memory, and disk
+// together. VMs are placed largest first on the host that has the least
+// capacity left over after the VM is placed, where capacity is the host's
+// physical resources multiplied by its over-commit ratios.
+type bestFit struct {
+	options Options
+}
+
+func (this *bestFit) Init(opts ...Option) error {
+	this.options = NewOptions(opts...)
+
+	return nil
+}
+
+func (bestFit) Name() string {
+	return "best-fit"
+}
+
+func (this bestFit) Schedule(spec ifaces.ExperimentSpec) error {
+	var nodes []ifaces.NodeSpec
+
+	for _, node := range spec.Topology().Nodes() {
+		if !node.External() {
+			nodes = append(nodes, node)
+		}
+	}
+
+	if len(nodes) == 0 {
+		return fmt.Errorf("no VMs defined for experiment")
+	}
+
+	cluster, err := mm.GetClusterHosts(true)
+	if err != nil {
+		return fmt.Errorf("getting cluster hosts: %w", err)
+	}
+
+	if len(cluster) == 0 {
+		return fmt.Errorf("no schedulable cluster hosts: %w", ErrInsufficientResources)
+	}
+
+	var (
+		hosts          = make([]*fitHost, len(cluster))
+		sizes, present = imageSizes()
+		vms            []*fitVM
+	)
+
+	for i, host := range cluster {
+		hosts[i] = newFitHost(host, this.overCommit(host.Name), present[host.Name])
+	}
+
+	// Account for VMs manually scheduled before placing the rest. Manual
+	// schedules are always honored, even if they over-commit a host.
+	for _, node := range nodes {
+		vm := newFitVM(node, sizes)
+
+		name, ok := spec.Schedules()[vm.name]
+		if !ok {
+			vms = append(vms, vm)
+			continue
+		}
+
+		for _, host := range hosts {
+			if host.name != name {
+				continue
+			}
+
+			if reason := host.fits(vm); reason != "" {
+				plog.Warn("manually scheduled VM over-commits host", "vm", vm.name, "host", name, "reason", reason)
+			}
+
+			host.add(vm)
+		}
+	}
+
+	// Place the largest VMs first so smaller VMs can fill in the gaps.
+	sort.SliceStable(vms, func(i, j int) bool {
+		if vms[i].mem != vms[j].mem {
+			return vms[i].mem > vms[j].mem
+		}
+
+		if vms[i].cpu != vms[j].cpu {
+			return vms[i].cpu > vms[j].cpu
+		}
+
+		return vms[i].name < vms[j].name
+	})
+
+	// Only update the experiment schedule once every VM has been placed.
+	schedule := make(map[string]string)
+
+	for _, vm := range vms {
+		var (
+			best    *fitHost
+			slack   float64
+			reasons []string
+		)
+
+		for _, host := range hosts {
+			if reason := host.fits(vm); reason != "" {
+				reasons = append(reasons, host.name+" "+reason)
+				continue
+			}
+
+			if s := host.slack(vm); best == nil || s < slack {
+				best, slack = host, s
+			}
+		}
+
+		if best == nil {
+			return fmt.Errorf("unable to place VM %s (%s) on any host (%s): %w", vm.name, vm, strings.Join(reasons, "; "), ErrInsufficientResources)
+		}
+
+		best.add(vm)
+		schedule[vm.name] = best.name
+	}
+
+	for vm, host := range schedule {
+		spec.Schedules()[vm] = host
+	}
+
+	return nil
+}
+
+// overCommit returns the over-commit ratios for the given host, falling back
+// to the default ratios (if set) and finally to no over-commit at all.
+func (this bestFit) overCommit(host string) OverCommit {
+	if r, ok := this.options.OverCommit[host]; ok {
+		return r
+	}
+
+	if r, ok := this.options.OverCommit["*"]; ok {
+		return r
+	}
+
+	return OverCommit{CPU: 1, Memory: 1, Disk: 1}
+}
+
+// imageSizes returns the sizes (in bytes) of the disk images available in the
+// cluster, keyed by image file name, along with the images present on each
+// cluster host. Disk is not considered when scheduling if the image sizes
+// cannot be determined.
+func imageSizes() (map[string]int, map[string]map[string]int) {
+	if HostImages == nil {
+		return nil, nil
+	}
+
+	present, err := HostImages()
+	if err != nil {
+		plog.Warn("unable to get image sizes -- not considering disk when scheduling", "err", err)
+		return nil, nil
+	}
+
+	sizes := make(map[string]int)
+
+	for _, images := range present {
+		for image, size := range images {
+			sizes[image] = size
+		}
+	}
+
+	return sizes, present
+}
+
+type fitVM struct {
+	name string
+	cpu  int
+	mem  int
+
+	// images maps the base name of each of the VM's disk images to its size
+	images map[string]int
+}
+
+func newFitVM(node ifaces.NodeSpec, sizes map[string]int) *fitVM {
+	vm := &fitVM{
+		name:   node.General().Hostname(),
+		cpu:    node.Hardware().VCPU(),
+		mem:    node.Hardware().Memory(),
+		images: make(map[string]int),
+	}
+
+	for _, drive := range node.Hardware().Drives() {
+		image := filepath.Base(drive.Image())
+
+		if size, ok := sizes[image]; ok {
+			vm.images[image] = size
+		}
+	}
+
+	return vm
+}
+
+func (this fitVM) String() string {
+	var disk int
+
+	for _, size := range this.images {
+		disk += size
+	}
+
+	if disk == 0 {
+		return fmt.Sprintf("%d vCPUs, %d MB memory", this.cpu, this.mem)
+	}
+
+	return fmt.Sprintf("%d vCPUs, %d MB memory, %s disk", this.cpu, this.mem, formatBytes(float64(disk)))
+}
+
+type fitHost struct {
+	name string
+
+	cpu  resource
+	mem  resource
+	disk resource
+
+	// images tracks the disk images already on the host, since an image only
+	// has to be copied to a host once no matter how many VMs use it. Images
+	// present before scheduling are already counted in the host's disk usage.
+	images map[string]struct{}
+}
+
+// resource tracks the amount of a host resource in use and the host's
+// capacity for it. A capacity of zero means the capacity is unknown and the
+// resource is not considered when placing VMs.
+type resource struct {
+	used     float64
+	capacity float64
+}
+
+func (this resource) free() float64 {
+	return math.Max(this.capacity-this.used, 0)
+}
+
+func newFitHost(host mm.Host, ratios OverCommit, present map[string]int) *fitHost {
+	size := float64(host.DiskUsage.MinimegaSize)
+
+	fit := &fitHost{
+		name:   host.Name,
+		cpu:    resource{used: float64(host.CPUCommit), capacity: float64(host.CPUs) * ratios.CPU},
+		mem:    resource{used: float64(host.MemCommit), capacity: float64(host.MemTotal) * ratios.Memory},
+		disk:   resource{used: size * host.DiskUsage.Minimega / 100, capacity: size * ratios.Disk},
+		images: make(map[string]struct{}),
+	}
+
+	for image := range present {
+		fit.images[image] = struct{}{}
+	}
+
+	return fit
+}
+
+// diskFor returns the amount of disk (in bytes) the given VM would consume on
+// the host, not counting images already on the host.
+func (this fitHost) diskFor(vm *fitVM) float64 {
+	var disk float64
+
+	for image, size := range vm.images {
+		if _, ok := this.images[image]; !ok {
+			disk += float64(size)
+		}
+	}
+
+	return disk
+}
+
+// fits returns an empty string if the given VM fits on the host, or an
+// explanation of which resources are insufficient if it doesn't.
+func (this fitHost) fits(vm *fitVM) string {
+	var reasons []string
+
+	if this.cpu.capacity > 0 && float64(vm.cpu) > this.cpu.free() {
+		reasons = append(reasons, fmt.Sprintf("%.0f of %.0f vCPUs available", this.cpu.free(), this.cpu.capacity))
+	}
+
+	if this.mem.capacity > 0 && float64(vm.mem) > this.mem.free() {
+		reasons = append(reasons, fmt.Sprintf("%.0f of %.0f MB memory available", this.mem.free(), this.mem.capacity))
+	}
+
+	if disk := this.diskFor(vm); this.disk.capacity > 0 && disk > this.disk.free() {
+		reasons = append(reasons, fmt.Sprintf("%s of %s disk available", formatBytes(this.disk.free()), formatBytes(this.disk.capacity)))
+	}
+
+	if len(reasons) == 0 {
+		return ""
+	}
+
+	return "has " + strings.Join(reasons, ", ")
+}
+
+// slack returns the sum of the fractions of each host resource that would be
+// left unused if the given VM were placed on the host. The host with the least
+// slack is the best fit.
+func (this fitHost) slack(vm *fitVM) float64 {
+	var slack float64
+
+	demands := []struct {
+		resource resource
+		demand   float64
+	}{
+		{this.cpu, float64(vm.cpu)},
+		{this.mem, float64(vm.mem)},
+		{this.disk, this.diskFor(vm)},
+	}
+
+	for _, d := range demands {
+		if d.resource.capacity > 0 {
+			slack += (d.resource.free() - d.demand) / d.resource.capacity
+		}
+	}
+
+	return slack
+}
+
+func (this *fitHost) add(vm *fitVM) {
+	this.cpu.used += float64(vm.cpu)
+	this.mem.used += float64(vm.mem)
+	this.disk.used += this.diskFor(vm)
+
+	for image := range vm.images {
+		this.images[image] = struct{}{}
+	}
+}
+
+func formatBytes(b float64) string {
+	units := []string{"B", "KB", "MB", "GB", "TB"}
+
+	var i int
+
+	for ; b >= 1024 && i < len(units)-1; i++ {
+		b /= 1024
+	}
+
+	return fmt.Sprintf("%.1f %s", b, units[i])
+}
@@ -0,0 +1,294 @@
+package scheduler
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	v1 "phenix/types/version/v1"
+	"phenix/util/mm"
+
+	"github.com/golang/mock/gomock"
+)
+
+// hostImages sets the disk images present on each cluster host for the
+// duration of the test.
+func hostImages(t *testing.T, images map[string]map[string]int) {
+	defaultImages := HostImages
+
+	HostImages = func() (map[string]map[string]int, error) {
+		return images, nil
+	}
+
+	t.Cleanup(func() { HostImages = defaultImages })
+}
+
+func TestBestFitSchedulerPacking(t *testing.T) {
+	spec := &v1.ExperimentSpec{
+		TopologyF: &v1.TopologySpec{
+			NodesF: nodes,
+		},
+		SchedulesF: make(map[string]string),
+	}
+
+	hosts := mm.Hosts(
+		[]mm.Host{
+			{
+				Name:     "compute0",
+				CPUs:     4,
+				MemTotal: 4096,
+			},
+			{
+				Name:     "compute1",
+				CPUs:     8,
+				MemTotal: 16384,
+			},
+		},
+	)
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	m := mm.NewMockMM(ctrl)
+	m.EXPECT().GetClusterHosts(true).Return(hosts, nil)
+
+	mm.DefaultMM = m
+	hostImages(t, nil)
+
+	if err := Schedule("best-fit", spec); err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+
+	// Every VM fits on compute1 (exactly filling its CPUs), so none should be
+	// spread onto compute0.
+	for _, vm := range []string{"foo", "bar", "sucka", "fish"} {
+		if spec.SchedulesF[vm] != "compute1" {
+			t.Logf("expected %s -> compute1, got %s -> %s", vm, vm, spec.SchedulesF[vm])
+			t.FailNow()
+		}
+	}
+
+	if _, ok := spec.SchedulesF["external"]; ok {
+		t.Log("expected external VM to not be scheduled")
+		t.FailNow()
+	}
+}
+
+func TestBestFitSchedulerOverCommit(t *testing.T) {
+	hosts := mm.Hosts(
+		[]mm.Host{
+			{
+				Name:     "compute0",
+				CPUs:     2,
+				MemTotal: 16384,
+			},
+		},
+	)
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	m := mm.NewMockMM(ctrl)
+	m.EXPECT().GetClusterHosts(true).Return(hosts, nil).Times(2)
+
+	mm.DefaultMM = m
+	hostImages(t, nil)
+
+	spec := &v1.ExperimentSpec{
+		TopologyF: &v1.TopologySpec{
+			NodesF: nodes,
+		},
+		SchedulesF: make(map[string]string),
+	}
+
+	err := Schedule("best-fit", spec)
+	if !errors.Is(err, ErrInsufficientResources) {
+		t.Logf("expected ErrInsufficientResources, got %v", err)
+		t.FailNow()
+	}
+
+	if !strings.Contains(err.Error(), "sucka (4 vCPUs, 8192 MB memory) on any host (compute0 has 2 of 2 vCPUs available)") {
+		t.Logf("expected error to explain why placement failed, got %v", err)
+		t.FailNow()
+	}
+
+	if len(spec.SchedulesF) != 0 {
+		t.Logf("expected no VMs to be scheduled on failure, got %v", spec.SchedulesF)
+		t.FailNow()
+	}
+
+	if err := Init("best-fit", OverCommitRatios("compute0", OverCommit{CPU: 4, Memory: 1, Disk: 1})); err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+
+	defer Init("best-fit")
+
+	if err := Schedule("best-fit", spec); err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+
+	if len(spec.SchedulesF) != 4 {
+		t.Logf("expected 4 scheduled VMs, got %d", len(spec.SchedulesF))
+		t.FailNow()
+	}
+}
+
+func TestBestFitSchedulerDiskManual(t *testing.T) {
+	const gb = 1024 * 1024 * 1024
+
+	spec := &v1.ExperimentSpec{
+		TopologyF: &v1.TopologySpec{
+			NodesF: []*v1.Node{
+				diskNode("alpha", "/phenix/images/bennu.qc2"),
+				diskNode("bravo", "bennu.qc2"),
+				diskNode("charlie", "kali.qc2"),
+			},
+		},
+		SchedulesF: map[string]string{"charlie": "compute0"},
+	}
+
+	hosts := mm.Hosts(
+		[]mm.Host{
+			{
+				Name:      "compute0",
+				CPUs:      16,
+				MemTotal:  49152,
+				DiskUsage: mm.DiskUsage{Minimega: 50, MinimegaSize: 100 * gb},
+			},
+			{
+				Name:      "compute1",
+				CPUs:      16,
+				MemTotal:  49152,
+				DiskUsage: mm.DiskUsage{Minimega: 50, MinimegaSize: 100 * gb},
+			},
+		},
+	)
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	m := mm.NewMockMM(ctrl)
+	m.EXPECT().GetClusterHosts(true).Return(hosts, nil)
+
+	mm.DefaultMM = m
+
+	// The images are only on the headnode, so they have to be copied to
+	// whichever compute hosts the VMs are placed on.
+	hostImages(t, map[string]map[string]int{
+		"headnode": {"bennu.qc2": 40 * gb, "kali.qc2": 30 * gb},
+	})
+
+	if err := Schedule("best-fit", spec); err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+
+	// charlie's image leaves too little disk on compute0 for bennu, and bravo
+	// shares alpha's image so it fits on compute1 too.
+	expected := map[string]string{
+		"alpha":   "compute1",
+		"bravo":   "compute1",
+		"charlie": "compute0",
+	}
+
+	for vm, host := range expected {
+		if spec.SchedulesF[vm] != host {
+			t.Logf("expected %s -> %s, got %s -> %s", vm, host, vm, spec.SchedulesF[vm])
+			t.FailNow()
+		}
+	}
+}
+
+func TestBestFitSchedulerDiskPresent(t *testing.T) {
+	const gb = 1024 * 1024 * 1024
+
+	spec := &v1.ExperimentSpec{
+		TopologyF: &v1.TopologySpec{
+			NodesF: []*v1.Node{diskNode("alpha", "bennu.qc2")},
+		},
+		SchedulesF: make(map[string]string),
+	}
+
+	hosts := mm.Hosts(
+		[]mm.Host{
+			{
+				Name:      "compute0",
+				CPUs:      16,
+				MemTotal:  49152,
+				DiskUsage: mm.DiskUsage{Minimega: 80, MinimegaSize: 100 * gb},
+			},
+			{
+				Name:      "compute1",
+				CPUs:      16,
+				MemTotal:  49152,
+				DiskUsage: mm.DiskUsage{Minimega: 70, MinimegaSize: 100 * gb},
+			},
+		},
+	)
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	m := mm.NewMockMM(ctrl)
+	m.EXPECT().GetClusterHosts(true).Return(hosts, nil)
+
+	mm.DefaultMM = m
+
+	// compute0 doesn't have room for another copy of the image, but it already
+	// has it (and it's already counted in its disk usage), so the VM fits there.
+	hostImages(t, map[string]map[string]int{
+		"compute0": {"bennu.qc2": 40 * gb},
+	})
+
+	if err := Schedule("best-fit", spec); err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+
+	if spec.SchedulesF["alpha"] != "compute0" {
+		t.Logf("expected alpha -> compute0, got alpha -> %s", spec.SchedulesF["alpha"])
+		t.FailNow()
+	}
+}
+
+func TestParseOverCommit(t *testing.T) {
+	host, ratios, err := ParseOverCommit("compute1=4:1.5:1")
+	if err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+
+	if host != "compute1" || ratios != (OverCommit{CPU: 4, Memory: 1.5, Disk: 1}) {
+		t.Logf("unexpected over-commit ratios for %s: %+v", host, ratios)
+		t.FailNow()
+	}
+
+	if host, _, _ := ParseOverCommit("2:1:1"); host != "*" {
+		t.Logf("expected default host, got %s", host)
+		t.FailNow()
+	}
+
+	for _, bad := range []string{"2:1", "=2:1:1", "a:1:1", "0:1:1"} {
+		if _, _, err := ParseOverCommit(bad); err == nil {
+			t.Logf("expected error parsing %s", bad)
+			t.FailNow()
+		}
+	}
+}
+
+func diskNode(name, image string) *v1.Node {
+	return &v1.Node{
+		TypeF: "VirtualMachine",
+		GeneralF: &v1.General{
+			HostnameF: name,
+		},
+		HardwareF: &v1.Hardware{
+			VCPUF:   1,
+			MemoryF: 1024,
+			DrivesF: []*v1.Drive{{ImageF: image}},
+		},
+	}
+}
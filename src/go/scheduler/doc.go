@@ -4,6 +4,10 @@ requirements, network configurations, etc. and cluster node capacity.
 
 Default Schedulers
 
+  * best-fit.go:           packs experiment VMs onto cluster nodes based on
+                           their vCPU, memory, and disk image requirements and
+                           cluster node capacity (including over-commit ratios
+                           configured via the `scheduler.overcommit` option)
   * isolate-experiment.go: isolates all experiment VMs on a single cluster node
   * round-robin.go:        assigns experiment VMs to cluster nodes in a
                            round-robin fashion
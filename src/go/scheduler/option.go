@@ -1,5 +1,11 @@
 package scheduler
 
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
 // Option is a function that configures options for a phenix scheduler. It is
 // used in `scheduler.Init`.
 type Option func(*Options)
@@ -7,11 +13,25 @@ type Option func(*Options)
 // Options represents a set of options generic to all schedulers.
 type Options struct {
 	Name string // used to set the scheduler name
+
+	// OverCommit maps cluster host names to the ratios by which the host's
+	// resources can be over-committed. The `*` key applies to all hosts not
+	// explicitly listed.
+	OverCommit map[string]OverCommit
+}
+
+// OverCommit represents the ratios by which a cluster host's CPU, memory, and
+// disk capacity can be over-committed. For example, a CPU ratio of 2 allows
+// twice as many vCPUs to be committed to a host as it has physical CPUs.
+type OverCommit struct {
+	CPU    float64
+	Memory float64
+	Disk   float64
 }
 
 // NewOptions returns an Options struct initialized with the given option list.
 func NewOptions(opts ...Option) Options {
-	o := Options{}
+	o := Options{OverCommit: make(map[string]OverCommit)}
 
 	for _, opt := range opts {
 		opt(&o)
@@ -26,3 +46,41 @@ func Name(n string) Option {
 		o.Name = n
 	}
 }
+
+// OverCommitRatios sets the over-commit ratios for the given cluster host. Use
+// `*` as the host to set the default ratios for all hosts.
+func OverCommitRatios(host string, r OverCommit) Option {
+	return func(o *Options) {
+		o.OverCommit[host] = r
+	}
+}
+
+// ParseOverCommit parses over-commit ratios of the form
+// [<host>=]<cpu>:<memory>:<disk> (e.g. `compute1=4:1.5:1`), returning the host
+// the ratios apply to (`*` if no host is given) and the ratios.
+func ParseOverCommit(s string) (string, OverCommit, error) {
+	host := "*"
+
+	if tokens := strings.SplitN(s, "=", 2); len(tokens) == 2 {
+		host, s = tokens[0], tokens[1]
+	}
+
+	ratios := strings.Split(s, ":")
+
+	if host == "" || len(ratios) != 3 {
+		return "", OverCommit{}, fmt.Errorf("over-commit ratios %s must be of the form [<host>=]<cpu>:<memory>:<disk>", s)
+	}
+
+	var values [3]float64
+
+	for i, r := range ratios {
+		v, err := strconv.ParseFloat(r, 64)
+		if err != nil || v <= 0 {
+			return "", OverCommit{}, fmt.Errorf("invalid over-commit ratio %s", r)
+		}
+
+		values[i] = v
+	}
+
+	return host, OverCommit{CPU: values[0], Memory: values[1], Disk: values[2]}, nil
+}
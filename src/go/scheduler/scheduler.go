@@ -1,6 +1,8 @@
 package scheduler
 
 import (
+	"fmt"
+
 	ifaces "phenix/types/interfaces"
 	"phenix/util/shell"
 )
@@ -35,6 +37,17 @@ func List() []string {
 	return names
 }
 
+// Init initializes the built-in phenix scheduler with the given name using the
+// given options. Custom user schedulers are initialized when they're used.
+func Init(name string, opts ...Option) error {
+	scheduler, ok := schedulers[name]
+	if !ok {
+		return fmt.Errorf("built-in scheduler %s does not exist", name)
+	}
+
+	return scheduler.Init(opts...)
+}
+
 func Schedule(name string, spec ifaces.ExperimentSpec) error {
 	scheduler, ok := schedulers[name]
 	if !ok {
@@ -1,15 +1,37 @@
 package store
 
 import (
+	"context"
+	"encoding/binary"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/url"
+	"strings"
 	"sync"
 	"time"
 
 	"go.etcd.io/bbolt"
 )
 
+const (
+	// changelogBucket is the Bolt bucket config changes are recorded in for
+	// watchers to consume.
+	changelogBucket = "changelog"
+
+	// changelogSize is the maximum number of changes kept in the changelog.
+	changelogSize = 1000
+
+	// revisionsBucket is the Bolt bucket prior config revisions are recorded in.
+	// Each config gets its own nested bucket, keyed by kind and name, with each
+	// revision keyed by its resource version.
+	revisionsBucket = "revisions"
+)
+
+// BoltWatchInterval is how often watchers of a BoltDB store poll the changelog
+// for new config changes.
+var BoltWatchInterval = 1 * time.Second
+
 type BoltDB struct {
 	sync.Mutex
 
@@ -138,13 +160,28 @@ func (this *BoltDB) Create(c *Config) error {
 	}
 
 	c.Metadata.Updated = now
+	c.Metadata.ResourceVersion = 1
 
 	v, err := json.Marshal(c)
 	if err != nil {
 		return fmt.Errorf("marshaling config JSON: %w", err)
 	}
 
-	if err := this.put(c.Kind, c.Metadata.Name, v); err != nil {
+	if err := this.ensureBucket(c.Kind); err != nil {
+		return err
+	}
+
+	err = this.db.Update(func(tx *bbolt.Tx) error {
+		b := tx.Bucket([]byte(c.Kind))
+
+		if err := b.Put([]byte(c.Metadata.Name), v); err != nil {
+			return err
+		}
+
+		return this.logChange(tx, ConfigEventCreate, *c, nil)
+	})
+
+	if err != nil {
 		return fmt.Errorf("writing config JSON to Bolt: %w", err)
 	}
 
@@ -152,29 +189,93 @@ func (this *BoltDB) Create(c *Config) error {
 }
 
 func (this *BoltDB) Update(c *Config) error {
+	return this.update(c, func(Config) (Config, error) { return *c, nil })
+}
+
+func (this *BoltDB) Patch(c *Config, patch []byte, opts ...PatchOption) error {
+	o := NewPatchOptions(opts...)
+
+	return this.update(c, func(existing Config) (Config, error) {
+		return applyPatch(existing, patch, o)
+	})
+}
+
+// update replaces the given config in the store with the config returned by
+// the given function, which is passed the config currently in the store. The
+// resource version check, function call, and write all happen in the same
+// transaction so no other writer can sneak in between them.
+func (this *BoltDB) update(c *Config, fn func(Config) (Config, error)) error {
 	this.open()
 	defer this.Close()
 
-	if _, err := this.get(c.Kind, c.Metadata.Name); err != nil {
-		return ErrNotExist
+	if err := this.ensureBucket(c.Kind); err != nil {
+		return err
 	}
 
-	c.Metadata.Updated = time.Now().Format(time.RFC3339)
+	var (
+		updated Config
+		fnErr   error
+	)
+
+	err := this.db.Update(func(tx *bbolt.Tx) error {
+		b := tx.Bucket([]byte(c.Kind))
+		v := b.Get([]byte(c.Metadata.Name))
+
+		if v == nil {
+			return ErrNotExist
+		}
+
+		var existing Config
+
+		if err := json.Unmarshal(v, &existing); err != nil {
+			return fmt.Errorf("unmarshaling config JSON: %w", err)
+		}
+
+		version := existing.Metadata.ResourceVersion
+
+		if c.Metadata.ResourceVersion != 0 && c.Metadata.ResourceVersion != version {
+			return ConflictError{
+				Kind: c.Kind, Name: c.Metadata.Name, Expected: c.Metadata.ResourceVersion, Actual: version,
+			}
+		}
+
+		updated, fnErr = fn(existing)
+		if fnErr != nil {
+			return fnErr
+		}
+
+		updated.Metadata.Updated = time.Now().Format(time.RFC3339)
+		updated.Metadata.ResourceVersion = version + 1
+
+		v, err := json.Marshal(updated)
+		if err != nil {
+			return fmt.Errorf("marshaling config JSON: %w", err)
+		}
+
+		if err := b.Put([]byte(c.Metadata.Name), v); err != nil {
+			return err
+		}
+
+		if revisionChanged(existing, updated) {
+			if err := this.recordRevision(tx, existing); err != nil {
+				return err
+			}
+		}
+
+		return this.logChange(tx, ConfigEventUpdate, updated, &existing)
+	})
 
-	v, err := json.Marshal(c)
 	if err != nil {
-		return fmt.Errorf("marshaling config JSON: %w", err)
-	}
+		if fnErr != nil || errors.Is(err, ErrNotExist) || errors.Is(err, ErrConflict) {
+			return err
+		}
 
-	if err := this.put(c.Kind, c.Metadata.Name, v); err != nil {
 		return fmt.Errorf("writing config JSON to Bolt: %w", err)
 	}
 
-	return nil
-}
+	*c = updated
 
-func (this *BoltDB) Patch(*Config, map[string]interface{}) error {
-	return fmt.Errorf("BoltDB.Patch not implemented")
+	return nil
 }
 
 func (this *BoltDB) Delete(c *Config) error {
@@ -193,7 +294,31 @@ func (this *BoltDB) Delete(c *Config) error {
 			return ErrNotExist
 		}
 
-		return b.Delete([]byte(c.Metadata.Name))
+		var existing Config
+
+		if err := json.Unmarshal(v, &existing); err != nil {
+			return fmt.Errorf("unmarshaling config JSON: %w", err)
+		}
+
+		if err := b.Delete([]byte(c.Metadata.Name)); err != nil {
+			return err
+		}
+
+		if revs := tx.Bucket([]byte(revisionsBucket)); revs != nil {
+			if revs.Bucket(revisionKey(*c)) != nil {
+				if err := revs.DeleteBucket(revisionKey(*c)); err != nil {
+					return fmt.Errorf("deleting config revisions: %w", err)
+				}
+			}
+		}
+
+		deleted := Config{
+			Version:  existing.Version,
+			Kind:     c.Kind,
+			Metadata: ConfigMetadata{Name: c.Metadata.Name},
+		}
+
+		return this.logChange(tx, ConfigEventDelete, deleted, &existing)
 	})
 
 	if err != nil {
@@ -203,6 +328,43 @@ func (this *BoltDB) Delete(c *Config) error {
 	return nil
 }
 
+func (this *BoltDB) Revisions(c *Config) (Configs, error) {
+	this.open()
+	defer this.Close()
+
+	var revisions Configs
+
+	err := this.db.View(func(tx *bbolt.Tx) error {
+		revs := tx.Bucket([]byte(revisionsBucket))
+		if revs == nil {
+			return nil
+		}
+
+		b := revs.Bucket(revisionKey(*c))
+		if b == nil {
+			return nil
+		}
+
+		return b.ForEach(func(_, v []byte) error {
+			var rev Config
+
+			if err := json.Unmarshal(v, &rev); err != nil {
+				return fmt.Errorf("unmarshaling config JSON: %w", err)
+			}
+
+			revisions = append(revisions, rev)
+
+			return nil
+		})
+	})
+
+	if err != nil {
+		return nil, fmt.Errorf("getting config revisions from store: %w", err)
+	}
+
+	return revisions, nil
+}
+
 func (this *BoltDB) GetEvents() (Events, error) {
 	this.open()
 	defer this.Close()
@@ -352,6 +514,252 @@ func (this *BoltDB) AddEvent(e Event) error {
 	return nil
 }
 
+func (this *BoltDB) QueryEvents(q EventQuery) (Events, error) {
+	// Bolt has no secondary indexes, so all the events have to be scanned.
+	events, err := this.GetEvents()
+	if err != nil {
+		return nil, err
+	}
+
+	return q.Filter(events), nil
+}
+
+func (this *BoltDB) PruneEvents(before time.Time) (int, error) {
+	this.open()
+	defer this.Close()
+
+	if err := this.ensureBucket("events"); err != nil {
+		return 0, err
+	}
+
+	var pruned int
+
+	err := this.db.Update(func(tx *bbolt.Tx) error {
+		var (
+			b    = tx.Bucket([]byte("events"))
+			keys [][]byte
+		)
+
+		err := b.ForEach(func(k, v []byte) error {
+			var e Event
+
+			if err := json.Unmarshal(v, &e); err != nil {
+				return fmt.Errorf("unmarshaling event JSON: %w", err)
+			}
+
+			if e.Timestamp.Before(before) {
+				keys = append(keys, k)
+			}
+
+			return nil
+		})
+
+		if err != nil {
+			return fmt.Errorf("iterating event bucket: %w", err)
+		}
+
+		// Keys can't be deleted while iterating over a bucket.
+		for _, k := range keys {
+			if err := b.Delete(k); err != nil {
+				return err
+			}
+		}
+
+		pruned = len(keys)
+
+		return nil
+	})
+
+	if err != nil {
+		return 0, fmt.Errorf("pruning events from store: %w", err)
+	}
+
+	return pruned, nil
+}
+
+func (this *BoltDB) Watch(kinds ...string) (<-chan ConfigEvent, context.CancelFunc) {
+	var (
+		ctx, cancel = context.WithCancel(context.Background())
+		events      = make(chan ConfigEvent)
+	)
+
+	// Only stream changes made after Watch was called.
+	last := this.changelogSequence()
+
+	go func() {
+		defer close(events)
+
+		// Bolt has no way of notifying us about writes made by other processes, so
+		// the changelog has to be polled.
+		ticker := time.NewTicker(BoltWatchInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+
+			var changes []ConfigEvent
+
+			changes, last = this.changesSince(last)
+
+			for _, change := range changes {
+				if !watchingKind(kinds, change.Config.Kind) {
+					continue
+				}
+
+				select {
+				case events <- change:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+
+	return events, cancel
+}
+
+// logChange records the given config change in the changelog bucket so it can
+// be picked up by watchers. Only the most recent changes are kept in the
+// changelog. It must be called from within a writable transaction.
+func (this *BoltDB) logChange(tx *bbolt.Tx, typ ConfigEventType, c Config, prev *Config) error {
+	b, err := tx.CreateBucketIfNotExists([]byte(changelogBucket))
+	if err != nil {
+		return fmt.Errorf("creating changelog bucket in Bolt: %w", err)
+	}
+
+	seq, err := b.NextSequence()
+	if err != nil {
+		return fmt.Errorf("getting next changelog sequence: %w", err)
+	}
+
+	v, err := json.Marshal(ConfigEvent{Type: typ, Config: c, Previous: prev})
+	if err != nil {
+		return fmt.Errorf("marshaling config event JSON: %w", err)
+	}
+
+	if err := b.Put(itob(seq), v); err != nil {
+		return fmt.Errorf("writing config event to changelog: %w", err)
+	}
+
+	if seq > changelogSize {
+		if err := b.Delete(itob(seq - changelogSize)); err != nil {
+			return fmt.Errorf("pruning changelog: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// recordRevision records the given config as a prior revision, pruning the
+// oldest revisions of the config if there are more than RevisionLimit. It must
+// be called from within a writable transaction.
+func (this *BoltDB) recordRevision(tx *bbolt.Tx, c Config) error {
+	revs, err := tx.CreateBucketIfNotExists([]byte(revisionsBucket))
+	if err != nil {
+		return fmt.Errorf("creating revisions bucket in Bolt: %w", err)
+	}
+
+	b, err := revs.CreateBucketIfNotExists(revisionKey(c))
+	if err != nil {
+		return fmt.Errorf("creating config revisions bucket in Bolt: %w", err)
+	}
+
+	v, err := json.Marshal(c)
+	if err != nil {
+		return fmt.Errorf("marshaling config JSON: %w", err)
+	}
+
+	if err := b.Put(itob(uint64(c.Metadata.ResourceVersion)), v); err != nil {
+		return fmt.Errorf("writing config revision: %w", err)
+	}
+
+	var keys [][]byte
+
+	b.ForEach(func(k, _ []byte) error {
+		keys = append(keys, k)
+		return nil
+	})
+
+	// Keys are sorted by resource version, so the oldest revisions come first.
+	for len(keys) > RevisionLimit {
+		if err := b.Delete(keys[0]); err != nil {
+			return fmt.Errorf("pruning config revisions: %w", err)
+		}
+
+		keys = keys[1:]
+	}
+
+	return nil
+}
+
+// changelogSequence returns the sequence number of the most recent change
+// recorded in the changelog.
+func (this *BoltDB) changelogSequence() uint64 {
+	if err := this.open(); err != nil {
+		this.Close()
+		return 0
+	}
+
+	defer this.Close()
+
+	var seq uint64
+
+	this.db.View(func(tx *bbolt.Tx) error {
+		if b := tx.Bucket([]byte(changelogBucket)); b != nil {
+			seq = b.Sequence()
+		}
+
+		return nil
+	})
+
+	return seq
+}
+
+// changesSince returns all the changes recorded in the changelog after the
+// given sequence number, along with the sequence number of the most recent
+// change returned.
+func (this *BoltDB) changesSince(seq uint64) ([]ConfigEvent, uint64) {
+	// Don't take down the watcher if the database can't be opened (e.g., it was
+	// moved or deleted); just try again on the next poll.
+	if err := this.open(); err != nil {
+		this.Close()
+		return nil, seq
+	}
+
+	defer this.Close()
+
+	var changes []ConfigEvent
+
+	this.db.View(func(tx *bbolt.Tx) error {
+		b := tx.Bucket([]byte(changelogBucket))
+		if b == nil {
+			return nil
+		}
+
+		c := b.Cursor()
+
+		for k, v := c.Seek(itob(seq + 1)); k != nil; k, v = c.Next() {
+			seq = binary.BigEndian.Uint64(k)
+
+			var change ConfigEvent
+
+			if err := json.Unmarshal(v, &change); err != nil {
+				continue
+			}
+
+			changes = append(changes, change)
+		}
+
+		return nil
+	})
+
+	return changes, seq
+}
+
 func (this *BoltDB) get(b, k string) ([]byte, error) {
 	if err := this.ensureBucket(b); err != nil {
 		return nil, err
@@ -399,3 +807,27 @@ func (this *BoltDB) ensureBucket(name string) error {
 		return nil
 	})
 }
+
+func revisionKey(c Config) []byte {
+	return []byte(c.Kind + "/" + c.Metadata.Name)
+}
+
+func itob(v uint64) []byte {
+	b := make([]byte, 8)
+	binary.BigEndian.PutUint64(b, v)
+	return b
+}
+
+func watchingKind(kinds []string, kind string) bool {
+	if len(kinds) == 0 {
+		return true
+	}
+
+	for _, k := range kinds {
+		if strings.EqualFold(k, kind) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -1,9 +1,11 @@
 package store
 
 import (
+	"errors"
 	"io/ioutil"
 	"os"
 	"testing"
+	"time"
 
 	"gopkg.in/yaml.v3"
 )
@@ -132,3 +134,351 @@ func TestConfigDelete(t *testing.T) {
 		t.FailNow()
 	}
 }
+
+func TestConfigUpdateConflict(t *testing.T) {
+	f, err := ioutil.TempFile("/tmp", "phenix")
+	if err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+
+	defer os.Remove(f.Name())
+
+	b := NewBoltDB()
+
+	if err := b.Init(Endpoint("bolt://" + f.Name())); err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+
+	var c Config
+
+	if err := yaml.Unmarshal([]byte(topology), &c); err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+
+	if err := b.Create(&c); err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+
+	if c.Metadata.ResourceVersion != 1 {
+		t.Logf("expected resource version 1, got %d", c.Metadata.ResourceVersion)
+		t.FailNow()
+	}
+
+	stale := c
+
+	if err := b.Update(&c); err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+
+	if c.Metadata.ResourceVersion != 2 {
+		t.Logf("expected resource version 2, got %d", c.Metadata.ResourceVersion)
+		t.FailNow()
+	}
+
+	err = b.Update(&stale)
+
+	var conflict ConflictError
+
+	if !errors.As(err, &conflict) || !errors.Is(err, ErrConflict) {
+		t.Logf("expected conflict error, got %v", err)
+		t.FailNow()
+	}
+
+	if conflict.Expected != 1 || conflict.Actual != 2 {
+		t.Logf("unexpected conflict details: %+v", conflict)
+		t.FailNow()
+	}
+
+	// A zero resource version disables the version check.
+	stale.Metadata.ResourceVersion = 0
+
+	if err := b.Update(&stale); err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+
+	if stale.Metadata.ResourceVersion != 3 {
+		t.Logf("expected resource version 3, got %d", stale.Metadata.ResourceVersion)
+		t.FailNow()
+	}
+}
+
+func TestConfigPatch(t *testing.T) {
+	f, err := ioutil.TempFile("/tmp", "phenix")
+	if err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+
+	defer os.Remove(f.Name())
+
+	b := NewBoltDB()
+
+	if err := b.Init(Endpoint("bolt://" + f.Name())); err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+
+	var c Config
+
+	if err := yaml.Unmarshal([]byte(topology), &c); err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+
+	if err := b.Create(&c); err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+
+	merge := []byte(`{"spec": {"nodes": [{"type": "VirtualMachine", "general": {"hostname": "turbine-02"}}]}, "status": {"foo": "bar"}}`)
+
+	if err := b.Patch(&c, merge); err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+
+	if c.Metadata.ResourceVersion != 2 || c.Status["foo"] != "bar" {
+		t.Logf("unexpected patched config: %+v", c)
+		t.FailNow()
+	}
+
+	ops := []byte(`[{"op": "replace", "path": "/spec/nodes/0/general/hostname", "value": "turbine-03"}, {"op": "remove", "path": "/status/foo"}]`)
+
+	if err := b.Patch(&c, ops, PatchWithType(PatchTypeJSON)); err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+
+	if err := b.Get(&c); err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+
+	hostname := c.Spec["nodes"].([]any)[0].(map[string]any)["general"].(map[string]any)["hostname"]
+
+	if hostname != "turbine-03" || c.Status != nil {
+		t.Logf("unexpected patched config: %+v", c)
+		t.FailNow()
+	}
+
+	invalid := errors.New("invalid")
+
+	validator := PatchWithValidator(func(Config) error { return invalid })
+
+	if err := b.Patch(&c, []byte(`{"spec": {"nodes": []}}`), validator); !errors.Is(err, invalid) {
+		t.Logf("expected validation error, got %v", err)
+		t.FailNow()
+	}
+
+	if err := b.Patch(&c, []byte(`{"metadata": {"name": "foo"}}`)); err == nil {
+		t.Log("expected error patching config metadata")
+		t.FailNow()
+	}
+
+	if err := b.Get(&c); err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+
+	if c.Metadata.ResourceVersion != 3 {
+		t.Logf("expected failed patches to leave config untouched, got resource version %d", c.Metadata.ResourceVersion)
+		t.FailNow()
+	}
+}
+
+func TestConfigRevisions(t *testing.T) {
+	f, err := ioutil.TempFile("/tmp", "phenix")
+	if err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+
+	defer os.Remove(f.Name())
+
+	b := NewBoltDB()
+
+	if err := b.Init(Endpoint("bolt://" + f.Name())); err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+
+	defer func(limit int) { RevisionLimit = limit }(RevisionLimit)
+
+	RevisionLimit = 2
+
+	var c Config
+
+	if err := yaml.Unmarshal([]byte(topology), &c); err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+
+	if err := b.Create(&c); err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+
+	// Status-only updates don't record a revision.
+	c.Status = map[string]any{"foo": "bar"}
+
+	if err := b.Update(&c); err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+
+	for _, name := range []string{"turbine-02", "turbine-03", "turbine-04"} {
+		c.Spec["nodes"].([]any)[0].(map[string]any)["general"].(map[string]any)["hostname"] = name
+
+		if err := b.Update(&c); err != nil {
+			t.Log(err)
+			t.FailNow()
+		}
+	}
+
+	revs, err := b.Revisions(&c)
+	if err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+
+	if len(revs) != 2 {
+		t.Logf("expected 2 revisions, got %d", len(revs))
+		t.FailNow()
+	}
+
+	if revs[0].Metadata.ResourceVersion != 3 || revs[1].Metadata.ResourceVersion != 4 {
+		t.Logf("expected revisions 3 and 4, got %d and %d", revs[0].Metadata.ResourceVersion, revs[1].Metadata.ResourceVersion)
+		t.FailNow()
+	}
+
+	if err := b.Delete(&c); err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+
+	if revs, _ := b.Revisions(&c); len(revs) != 0 {
+		t.Logf("expected revisions to be deleted, got %d", len(revs))
+		t.FailNow()
+	}
+}
+
+func TestConfigWatch(t *testing.T) {
+	f, err := ioutil.TempFile("/tmp", "phenix")
+	if err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+
+	defer os.Remove(f.Name())
+
+	BoltWatchInterval = 10 * time.Millisecond
+
+	b := NewBoltDB()
+
+	if err := b.Init(Endpoint("bolt://" + f.Name())); err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+
+	events, cancel := b.Watch("topology")
+	defer cancel()
+
+	var c Config
+
+	if err := yaml.Unmarshal([]byte(topology), &c); err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+
+	if err := b.Create(&c); err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+
+	// Changes to configs of other kinds should not be streamed.
+	s, _ := NewConfig("scenario/foobar")
+
+	if err := b.Create(s); err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+
+	if err := b.Update(&c); err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+
+	if err := b.Delete(&c); err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+
+	expected := []ConfigEventType{ConfigEventCreate, ConfigEventUpdate, ConfigEventDelete}
+
+	for _, typ := range expected {
+		select {
+		case event := <-events:
+			if event.Type != typ || event.Config.FullName() != "Topology/foobar" {
+				t.Logf("expected %s event for Topology/foobar, got %s event for %s", typ, event.Type, event.Config.FullName())
+				t.FailNow()
+			}
+
+			if typ != ConfigEventCreate && event.Previous == nil {
+				t.Logf("expected previous config for %s event", typ)
+				t.FailNow()
+			}
+		case <-time.After(time.Second):
+			t.Logf("timed out waiting for %s event", typ)
+			t.FailNow()
+		}
+	}
+
+	cancel()
+
+	if _, ok := <-events; ok {
+		t.Log("expected events channel to be closed")
+		t.FailNow()
+	}
+}
+
+func TestConfigWatchMissingDatabase(t *testing.T) {
+	dir, err := ioutil.TempDir("/tmp", "phenix")
+	if err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+
+	defer os.RemoveAll(dir)
+
+	BoltWatchInterval = 10 * time.Millisecond
+
+	b := NewBoltDB()
+
+	if err := b.Init(Endpoint("bolt://" + dir + "/phenix.bdb")); err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+
+	events, cancel := b.Watch()
+	defer cancel()
+
+	// Watchers should keep polling, rather than panic, while the database can't
+	// be opened.
+	if err := os.RemoveAll(dir); err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+
+	select {
+	case event := <-events:
+		t.Logf("unexpected %s event for %s", event.Type, event.Config.FullName())
+		t.FailNow()
+	case <-time.After(100 * time.Millisecond):
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"net/url"
 	"strings"
+	"sync"
 	"time"
 
 	"go.etcd.io/etcd/v3/clientv3"
@@ -101,13 +102,14 @@ func (this Etcd) Create(c *Config) error {
 	key := fmt.Sprintf("%s/%s", strings.ToLower(c.Kind), c.Metadata.Name)
 
 	if resp, _ := this.cli.Get(context.Background(), key); resp.Count != 0 {
-		return fmt.Errorf("config %s/%s already exists", c.Kind, c.Metadata.Name)
+		return fmt.Errorf("config %s/%s: %w", c.Kind, c.Metadata.Name, ErrExist)
 	}
 
 	now := time.Now().Format(time.RFC3339)
 
 	c.Metadata.Created = now
 	c.Metadata.Updated = now
+	c.Metadata.ResourceVersion = 1
 
 	v, err := json.Marshal(c)
 	if err != nil {
@@ -122,30 +124,131 @@ func (this Etcd) Create(c *Config) error {
 }
 
 func (this Etcd) Update(c *Config) error {
+	return this.update(c, func(Config) (Config, error) { return *c, nil })
+}
+
+func (this Etcd) Patch(c *Config, patch []byte, opts ...PatchOption) error {
+	o := NewPatchOptions(opts...)
+
+	return this.update(c, func(existing Config) (Config, error) {
+		return applyPatch(existing, patch, o)
+	})
+}
+
+// update replaces the given config in the store with the config returned by
+// the given function, which is passed the config currently in the store. If
+// the caller provided a resource version, the write only happens if the config
+// hasn't been modified since that version. Otherwise, the config is re-read and
+// the write retried if the config is modified concurrently, matching the
+// overwrite semantics of the other stores.
+func (this Etcd) update(c *Config, fn func(Config) (Config, error)) error {
+	for {
+		done, err := this.tryUpdate(c, fn)
+		if err != nil {
+			return err
+		}
+
+		if done {
+			return nil
+		}
+	}
+}
+
+// tryUpdate makes a single attempt at updating the given config. It returns
+// false with a nil error if the config was modified between the read and the
+// write and the caller didn't request a version check, in which case the update
+// should be retried.
+func (this Etcd) tryUpdate(c *Config, fn func(Config) (Config, error)) (bool, error) {
 	key := fmt.Sprintf("%s/%s", strings.ToLower(c.Kind), c.Metadata.Name)
 
-	if resp, _ := this.cli.Get(context.Background(), key); resp.Count == 0 {
-		return fmt.Errorf("config %s/%s doesn't exist", c.Kind, c.Metadata.Name)
+	resp, err := this.cli.Get(context.Background(), key)
+	if err != nil {
+		return false, fmt.Errorf("getting config %s from Etcd: %w", key, err)
 	}
 
-	now := time.Now().Format(time.RFC3339)
+	if resp.Count == 0 {
+		return false, fmt.Errorf("config %s/%s doesn't exist: %w", c.Kind, c.Metadata.Name, ErrNotExist)
+	}
 
-	c.Metadata.Updated = now
+	var existing Config
 
-	v, err := json.Marshal(c)
+	if err := json.Unmarshal(resp.Kvs[0].Value, &existing); err != nil {
+		return false, fmt.Errorf("unmarshaling config JSON: %w", err)
+	}
+
+	version := existing.Metadata.ResourceVersion
+
+	if c.Metadata.ResourceVersion != 0 && c.Metadata.ResourceVersion != version {
+		return false, ConflictError{
+			Kind: c.Kind, Name: c.Metadata.Name, Expected: c.Metadata.ResourceVersion, Actual: version,
+		}
+	}
+
+	updated, err := fn(existing)
 	if err != nil {
-		return fmt.Errorf("marshaling config JSON: %w", err)
+		return false, err
 	}
 
-	if _, err := this.cli.Put(context.Background(), key, string(v)); err != nil {
-		return fmt.Errorf("writing config JSON to Etcd: %w", err)
+	updated.Metadata.Updated = time.Now().Format(time.RFC3339)
+	updated.Metadata.ResourceVersion = version + 1
+
+	v, err := json.Marshal(updated)
+	if err != nil {
+		return false, fmt.Errorf("marshaling config JSON: %w", err)
 	}
 
-	return nil
-}
+	ops := []clientv3.Op{clientv3.OpPut(key, string(v))}
+
+	record := revisionChanged(existing, updated)
+
+	if record {
+		// The previous config is stored as-is since it's exactly what was read
+		// from Etcd above.
+		ops = append(ops, clientv3.OpPut(etcdRevisionKey(existing), string(resp.Kvs[0].Value)))
+	}
+
+	// Only write the config if the key hasn't been modified since it was read
+	// above, otherwise a concurrent update could be lost.
+	txn, err := this.cli.Txn(context.Background()).
+		If(clientv3.Compare(clientv3.ModRevision(key), "=", resp.Kvs[0].ModRevision)).
+		Then(ops...).
+		Else(clientv3.OpGet(key)).
+		Commit()
+
+	if err != nil {
+		return false, fmt.Errorf("writing config JSON to Etcd: %w", err)
+	}
+
+	if !txn.Succeeded {
+		// No version check was requested, so retry against the config as it is
+		// now (if it still exists).
+		if c.Metadata.ResourceVersion == 0 {
+			return false, nil
+		}
+
+		// The config was updated (or deleted) between the read and the write, so
+		// the version we compared against above is already stale. The expected
+		// version reported is the one the caller provided, like above.
+		var actual int64
+
+		if kvs := txn.Responses[0].GetResponseRange().Kvs; len(kvs) > 0 {
+			if err := json.Unmarshal(kvs[0].Value, &existing); err == nil {
+				actual = existing.Metadata.ResourceVersion
+			}
+		}
+
+		return false, ConflictError{Kind: c.Kind, Name: c.Metadata.Name, Expected: c.Metadata.ResourceVersion, Actual: actual}
+	}
+
+	if record {
+		if err := this.pruneRevisions(updated); err != nil {
+			return false, err
+		}
+	}
+
+	*c = updated
 
-func (this Etcd) Patch(c *Config, u map[string]interface{}) error {
-	return fmt.Errorf("not implemented")
+	return true, nil
 }
 
 func (this Etcd) Delete(c *Config) error {
@@ -155,6 +258,58 @@ func (this Etcd) Delete(c *Config) error {
 		return fmt.Errorf("deleting key %s: %w", key, err)
 	}
 
+	if _, err := this.cli.Delete(context.Background(), etcdRevisionPrefix(*c), clientv3.WithPrefix()); err != nil {
+		return fmt.Errorf("deleting revisions for key %s: %w", key, err)
+	}
+
+	return nil
+}
+
+func (this Etcd) Revisions(c *Config) (Configs, error) {
+	// Revision keys include a zero-padded resource version, so sorting by key
+	// sorts the revisions oldest first.
+	resp, err := this.cli.Get(
+		context.Background(), etcdRevisionPrefix(*c),
+		clientv3.WithPrefix(), clientv3.WithSort(clientv3.SortByKey, clientv3.SortAscend),
+	)
+
+	if err != nil {
+		return nil, fmt.Errorf("getting config revisions from Etcd: %w", err)
+	}
+
+	var revisions Configs
+
+	for _, kv := range resp.Kvs {
+		var rev Config
+
+		if err := json.Unmarshal(kv.Value, &rev); err != nil {
+			return nil, fmt.Errorf("unmarshaling config JSON: %w", err)
+		}
+
+		revisions = append(revisions, rev)
+	}
+
+	return revisions, nil
+}
+
+// pruneRevisions deletes the oldest revisions of the given config if there are
+// more than RevisionLimit.
+func (this Etcd) pruneRevisions(c Config) error {
+	resp, err := this.cli.Get(
+		context.Background(), etcdRevisionPrefix(c),
+		clientv3.WithPrefix(), clientv3.WithKeysOnly(), clientv3.WithSort(clientv3.SortByKey, clientv3.SortAscend),
+	)
+
+	if err != nil {
+		return fmt.Errorf("getting config revisions from Etcd: %w", err)
+	}
+
+	for i := 0; i < len(resp.Kvs)-RevisionLimit; i++ {
+		if _, err := this.cli.Delete(context.Background(), string(resp.Kvs[i].Key)); err != nil {
+			return fmt.Errorf("pruning config revisions: %w", err)
+		}
+	}
+
 	return nil
 }
 
@@ -222,3 +377,147 @@ func (this Etcd) AddEvent(e Event) error {
 
 	return nil
 }
+
+func (this Etcd) QueryEvents(q EventQuery) (Events, error) {
+	events, err := this.GetEvents()
+	if err != nil {
+		return nil, err
+	}
+
+	return q.Filter(events), nil
+}
+
+func (this Etcd) PruneEvents(before time.Time) (int, error) {
+	resp, err := this.cli.Get(context.Background(), "events/", clientv3.WithPrefix())
+	if err != nil {
+		return 0, fmt.Errorf("getting list of events from Etcd: %w", err)
+	}
+
+	var pruned int
+
+	for _, kv := range resp.Kvs {
+		var e Event
+
+		if err := json.Unmarshal(kv.Value, &e); err != nil {
+			return pruned, fmt.Errorf("unmarshaling event JSON: %w", err)
+		}
+
+		if !e.Timestamp.Before(before) {
+			continue
+		}
+
+		if _, err := this.cli.Delete(context.Background(), string(kv.Key)); err != nil {
+			return pruned, fmt.Errorf("deleting event %s: %w", e.ID, err)
+		}
+
+		pruned++
+	}
+
+	return pruned, nil
+}
+
+func (this Etcd) Watch(kinds ...string) (<-chan ConfigEvent, context.CancelFunc) {
+	var (
+		ctx, cancel = context.WithCancel(context.Background())
+		events      = make(chan ConfigEvent)
+		prefixes    []string
+		wg          sync.WaitGroup
+	)
+
+	for _, kind := range kinds {
+		prefixes = append(prefixes, strings.ToLower(kind)+"/")
+	}
+
+	// An empty prefix watches every key in Etcd.
+	if len(prefixes) == 0 {
+		prefixes = []string{""}
+	}
+
+	for _, prefix := range prefixes {
+		watcher := this.cli.Watch(ctx, prefix, clientv3.WithPrefix(), clientv3.WithPrevKV())
+
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			for resp := range watcher {
+				for _, ev := range resp.Events {
+					// Events and config revisions are stored alongside configs in Etcd.
+					if strings.HasPrefix(string(ev.Kv.Key), "events/") || strings.HasPrefix(string(ev.Kv.Key), "revisions/") {
+						continue
+					}
+
+					event, err := configEventFromEtcd(ev)
+					if err != nil {
+						continue
+					}
+
+					select {
+					case events <- event:
+					case <-ctx.Done():
+						return
+					}
+				}
+			}
+		}()
+	}
+
+	go func() {
+		wg.Wait()
+		close(events)
+	}()
+
+	return events, cancel
+}
+
+func etcdRevisionPrefix(c Config) string {
+	return fmt.Sprintf("revisions/%s/%s/", strings.ToLower(c.Kind), c.Metadata.Name)
+}
+
+func etcdRevisionKey(c Config) string {
+	return fmt.Sprintf("%s%020d", etcdRevisionPrefix(c), c.Metadata.ResourceVersion)
+}
+
+func configEventFromEtcd(ev *clientv3.Event) (ConfigEvent, error) {
+	var event ConfigEvent
+
+	if ev.PrevKv != nil {
+		var prev Config
+
+		if err := json.Unmarshal(ev.PrevKv.Value, &prev); err == nil {
+			event.Previous = &prev
+		}
+	}
+
+	if ev.Type == clientv3.EventTypeDelete {
+		key := strings.SplitN(string(ev.Kv.Key), "/", 2)
+
+		if len(key) != 2 {
+			return event, fmt.Errorf("invalid config key %s", ev.Kv.Key)
+		}
+
+		event.Type = ConfigEventDelete
+		event.Config = Config{Kind: strings.Title(key[0]), Metadata: ConfigMetadata{Name: key[1]}}
+
+		// Etcd keys are lowercase, so use the previous config's kind if we have it.
+		if event.Previous != nil {
+			event.Config.Version = event.Previous.Version
+			event.Config.Kind = event.Previous.Kind
+		}
+
+		return event, nil
+	}
+
+	if err := json.Unmarshal(ev.Kv.Value, &event.Config); err != nil {
+		return event, fmt.Errorf("unmarshaling config JSON: %w", err)
+	}
+
+	if ev.IsCreate() {
+		event.Type = ConfigEventCreate
+	} else {
+		event.Type = ConfigEventUpdate
+	}
+
+	return event, nil
+}
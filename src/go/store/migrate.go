@@ -0,0 +1,78 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+)
+
+// MigrationResult summarizes the data copied by `Migrate`.
+type MigrationResult struct {
+	Configs int
+	Events  int
+
+	// Skipped is the number of configs and events that already existed in the
+	// destination store and were left as-is.
+	Skipped int
+}
+
+// Migrate copies all the configs of the given kinds, along with all events,
+// from one store to another. Configs and events that already exist in the
+// destination store are skipped, so an interrupted migration can safely be
+// run again. Config creation timestamps are preserved, but resource versions
+// start over and revision history is not copied.
+func Migrate(from, to Store, kinds ...string) (MigrationResult, error) {
+	var result MigrationResult
+
+	configs, err := from.List(kinds...)
+	if err != nil {
+		return result, fmt.Errorf("listing configs to migrate: %w", err)
+	}
+
+	for _, c := range configs {
+		c.Metadata.ResourceVersion = 0
+
+		if err := to.Create(&c); err != nil {
+			if errors.Is(err, ErrExist) {
+				result.Skipped++
+				continue
+			}
+
+			return result, fmt.Errorf("migrating config %s/%s: %w", c.Kind, c.Metadata.Name, err)
+		}
+
+		result.Configs++
+	}
+
+	events, err := from.GetEvents()
+	if err != nil {
+		return result, fmt.Errorf("getting events to migrate: %w", err)
+	}
+
+	existing, err := to.GetEvents()
+	if err != nil {
+		return result, fmt.Errorf("getting existing events: %w", err)
+	}
+
+	ids := make(map[string]struct{})
+
+	for _, e := range existing {
+		ids[e.ID] = struct{}{}
+	}
+
+	events.SortByTimestamp(true)
+
+	for _, e := range events {
+		if _, ok := ids[e.ID]; ok {
+			result.Skipped++
+			continue
+		}
+
+		if err := to.AddEvent(e); err != nil {
+			return result, fmt.Errorf("migrating event %s: %w", e.ID, err)
+		}
+
+		result.Events++
+	}
+
+	return result, nil
+}
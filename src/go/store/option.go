@@ -24,3 +24,49 @@ func Endpoint(e string) Option {
 		o.Endpoint = e
 	}
 }
+
+type PatchType string
+
+const (
+	// PatchTypeMerge identifies an RFC 7386 JSON merge patch.
+	PatchTypeMerge PatchType = "merge"
+
+	// PatchTypeJSON identifies an RFC 6902 JSON patch (a list of operations).
+	PatchTypeJSON PatchType = "json"
+)
+
+// PatchOption is a function that configures options for patching a config. It
+// is used in `store.Patch`.
+type PatchOption func(*PatchOptions)
+
+type PatchOptions struct {
+	Type     PatchType
+	Validate func(Config) error
+}
+
+func NewPatchOptions(opts ...PatchOption) PatchOptions {
+	o := PatchOptions{Type: PatchTypeMerge}
+
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	return o
+}
+
+// PatchWithType sets the type of patch being applied. Patches default to JSON
+// merge patches.
+func PatchWithType(t PatchType) PatchOption {
+	return func(o *PatchOptions) {
+		o.Type = t
+	}
+}
+
+// PatchWithValidator sets a function to validate the patched config before it
+// is written to the store. If the function returns an error, the store is left
+// untouched and the error is returned from `store.Patch`.
+func PatchWithValidator(v func(Config) error) PatchOption {
+	return func(o *PatchOptions) {
+		o.Validate = v
+	}
+}
@@ -1,30 +1,54 @@
 package store
 
 import (
+	"context"
 	"fmt"
 	"net/url"
+	"time"
 )
 
 var DefaultStore Store = NewBoltDB()
 
 func Init(opts ...Option) error {
+	s, err := Open(opts...)
+	if err != nil {
+		return err
+	}
+
+	DefaultStore = s
+
+	return nil
+}
+
+// Open creates and initializes a new store based on the scheme of the endpoint
+// provided in the given options. Unlike Init, the default store is left
+// untouched.
+func Open(opts ...Option) (Store, error) {
 	options := NewOptions(opts...)
 
 	u, err := url.Parse(options.Endpoint)
 	if err != nil {
-		return fmt.Errorf("parsing store endpoint: %w", err)
+		return nil, fmt.Errorf("parsing store endpoint: %w", err)
 	}
 
+	var s Store
+
 	switch u.Scheme {
 	case "bolt":
-		DefaultStore = NewBoltDB()
+		s = NewBoltDB()
 	case "etcd":
-		DefaultStore = NewEtcd()
+		s = NewEtcd()
+	case "sqlite":
+		s = NewSQLite()
 	default:
-		return fmt.Errorf("unknown store scheme '%s'", u.Scheme)
+		return nil, fmt.Errorf("unknown store scheme '%s'", u.Scheme)
+	}
+
+	if err := s.Init(opts...); err != nil {
+		return nil, err
 	}
 
-	return DefaultStore.Init(opts...)
+	return s, nil
 }
 
 func Close() error {
@@ -47,14 +71,18 @@ func Update(config *Config) error {
 	return DefaultStore.Update(config)
 }
 
-func Patch(config *Config, data map[string]interface{}) error {
-	return DefaultStore.Patch(config, data)
+func Patch(config *Config, patch []byte, opts ...PatchOption) error {
+	return DefaultStore.Patch(config, patch, opts...)
 }
 
 func Delete(config *Config) error {
 	return DefaultStore.Delete(config)
 }
 
+func Revisions(config *Config) (Configs, error) {
+	return DefaultStore.Revisions(config)
+}
+
 func GetEvents() (Events, error) {
 	return DefaultStore.GetEvents()
 }
@@ -70,3 +98,15 @@ func GetEvent(e *Event) error {
 func AddEvent(e Event) error {
 	return DefaultStore.AddEvent(e)
 }
+
+func QueryEvents(q EventQuery) (Events, error) {
+	return DefaultStore.QueryEvents(q)
+}
+
+func PruneEvents(before time.Time) (int, error) {
+	return DefaultStore.PruneEvents(before)
+}
+
+func Watch(kinds ...string) (<-chan ConfigEvent, context.CancelFunc) {
+	return DefaultStore.Watch(kinds...)
+}
@@ -0,0 +1,91 @@
+package store
+
+import (
+	"encoding/json"
+	"fmt"
+
+	jsonpatch "github.com/evanphx/json-patch"
+)
+
+// applyPatch returns a copy of the given config with the given patch applied to
+// its spec and status. The patched config is validated using the validator in
+// the given options, if one was provided.
+func applyPatch(c Config, patch []byte, o PatchOptions) (Config, error) {
+	doc := map[string]any{"spec": c.Spec, "status": c.Status}
+
+	// Use empty objects instead of nulls so JSON patch operations can add to
+	// them.
+	for k, v := range doc {
+		if v == nil || len(v.(map[string]any)) == 0 {
+			doc[k] = map[string]any{}
+		}
+	}
+
+	orig, err := json.Marshal(doc)
+	if err != nil {
+		return c, fmt.Errorf("marshaling config JSON: %w", err)
+	}
+
+	var patched []byte
+
+	switch o.Type {
+	case PatchTypeMerge:
+		patched, err = jsonpatch.MergePatch(orig, patch)
+		if err != nil {
+			return c, fmt.Errorf("applying merge patch: %w", err)
+		}
+	case PatchTypeJSON:
+		p, err := jsonpatch.DecodePatch(patch)
+		if err != nil {
+			return c, fmt.Errorf("decoding JSON patch: %w", err)
+		}
+
+		patched, err = p.Apply(orig)
+		if err != nil {
+			return c, fmt.Errorf("applying JSON patch: %w", err)
+		}
+	default:
+		return c, fmt.Errorf("unknown patch type %s", o.Type)
+	}
+
+	var result map[string]json.RawMessage
+
+	if err := json.Unmarshal(patched, &result); err != nil {
+		return c, fmt.Errorf("unmarshaling patched config JSON: %w", err)
+	}
+
+	var (
+		spec   map[string]any
+		status map[string]any
+	)
+
+	for k, v := range result {
+		switch k {
+		case "spec":
+			err = json.Unmarshal(v, &spec)
+		case "status":
+			err = json.Unmarshal(v, &status)
+		default:
+			return c, fmt.Errorf("patch can only modify config spec and status (modified %s)", k)
+		}
+
+		if err != nil {
+			return c, fmt.Errorf("config %s must be an object: %w", k, err)
+		}
+	}
+
+	if len(status) == 0 {
+		status = nil
+	}
+
+	c.Spec = spec
+	c.Status = status
+
+	if o.Validate != nil {
+		if err := o.Validate(c); err != nil {
+			return c, err
+		}
+	}
+
+	return c, nil
+}
@@ -0,0 +1,803 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"phenix/util/plog"
+
+	_ "modernc.org/sqlite"
+)
+
+// SQLiteWatchInterval is how often watchers of a SQLite store poll the
+// changelog for new config changes.
+var SQLiteWatchInterval = 1 * time.Second
+
+// sqlitePruneInterval is the minimum amount of time between automatic event
+// pruning runs when a retention period is configured.
+const sqlitePruneInterval = 1 * time.Hour
+
+const sqliteSchema = `
+CREATE TABLE IF NOT EXISTS configs (
+	kind TEXT NOT NULL,
+	name TEXT NOT NULL,
+	data TEXT NOT NULL,
+	PRIMARY KEY (kind, name)
+);
+
+CREATE TABLE IF NOT EXISTS revisions (
+	kind    TEXT NOT NULL,
+	name    TEXT NOT NULL,
+	version INTEGER NOT NULL,
+	data    TEXT NOT NULL,
+	PRIMARY KEY (kind, name, version)
+);
+
+CREATE TABLE IF NOT EXISTS changelog (
+	seq  INTEGER PRIMARY KEY AUTOINCREMENT,
+	data TEXT NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS events (
+	id        TEXT PRIMARY KEY,
+	timestamp INTEGER NOT NULL,
+	type      TEXT NOT NULL,
+	source    TEXT NOT NULL,
+	data      TEXT NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS events_timestamp ON events (timestamp);
+CREATE INDEX IF NOT EXISTS events_type_timestamp ON events (type, timestamp);
+CREATE INDEX IF NOT EXISTS events_source ON events (source);
+
+CREATE TABLE IF NOT EXISTS event_metadata (
+	event_id TEXT NOT NULL,
+	key      TEXT NOT NULL,
+	value    TEXT NOT NULL,
+	PRIMARY KEY (event_id, key)
+);
+
+CREATE INDEX IF NOT EXISTS event_metadata_key_value ON event_metadata (key, value);
+`
+
+// SQLite is a config store backed by a SQLite database file. Configs are
+// stored as JSON documents keyed by kind and name, and events are stored in an
+// indexed table so they can be queried efficiently. The endpoint is of the
+// form `sqlite:///path/to/phenix.db`, with an optional `retention` query
+// parameter (a duration, like `720h`) that causes events older than the
+// retention period to be pruned periodically.
+type SQLite struct {
+	sync.Mutex
+
+	db        *sql.DB
+	path      string
+	retention time.Duration
+	lastPrune time.Time
+}
+
+func NewSQLite() Store {
+	return new(SQLite)
+}
+
+func (this *SQLite) Init(opts ...Option) error {
+	options := NewOptions(opts...)
+
+	u, err := url.Parse(options.Endpoint)
+	if err != nil {
+		return fmt.Errorf("parsing SQLite endpoint: %w", err)
+	}
+
+	if u.Scheme != "sqlite" {
+		return fmt.Errorf("invalid scheme '%s' for SQLite endpoint", u.Scheme)
+	}
+
+	this.path = u.Host + u.Path
+
+	if r := u.Query().Get("retention"); r != "" {
+		this.retention, err = time.ParseDuration(r)
+		if err != nil {
+			return fmt.Errorf("parsing SQLite event retention period: %w", err)
+		}
+	}
+
+	// The pure Go SQLite driver is used so phenix can be built without cgo.
+	dsn := fmt.Sprintf("file:%s?_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)&_txlock=immediate", this.path)
+
+	this.db, err = sql.Open("sqlite", dsn)
+	if err != nil {
+		return fmt.Errorf("opening SQLite database: %w", err)
+	}
+
+	// SQLite only supports a single writer at a time, so limit the pool to a
+	// single connection to avoid lock contention within this process. Other
+	// processes are handled by the busy timeout.
+	this.db.SetMaxOpenConns(1)
+
+	if _, err := this.db.Exec(sqliteSchema); err != nil {
+		return fmt.Errorf("creating SQLite schema: %w", err)
+	}
+
+	this.pruneExpiredEvents()
+
+	return nil
+}
+
+func (this *SQLite) Close() error {
+	if this.db == nil {
+		return nil
+	}
+
+	return this.db.Close()
+}
+
+func (this *SQLite) List(kinds ...string) (Configs, error) {
+	var configs Configs
+
+	for _, kind := range kinds {
+		rows, err := this.db.Query(`SELECT data FROM configs WHERE kind = ? ORDER BY name`, strings.ToLower(kind))
+		if err != nil {
+			return nil, fmt.Errorf("getting configs from store: %w", err)
+		}
+
+		for rows.Next() {
+			var (
+				v string
+				c Config
+			)
+
+			if err := rows.Scan(&v); err != nil {
+				rows.Close()
+				return nil, fmt.Errorf("scanning config row: %w", err)
+			}
+
+			if err := json.Unmarshal([]byte(v), &c); err != nil {
+				rows.Close()
+				return nil, fmt.Errorf("unmarshaling config JSON: %w", err)
+			}
+
+			configs = append(configs, c)
+		}
+
+		if err := rows.Close(); err != nil {
+			return nil, fmt.Errorf("getting configs from store: %w", err)
+		}
+	}
+
+	return configs, nil
+}
+
+func (this *SQLite) Get(c *Config) error {
+	var v string
+
+	err := this.db.QueryRow(
+		`SELECT data FROM configs WHERE kind = ? AND name = ?`, strings.ToLower(c.Kind), c.Metadata.Name,
+	).Scan(&v)
+
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("getting config: %w: %s/%s", ErrNotExist, c.Kind, c.Metadata.Name)
+		}
+
+		return fmt.Errorf("getting config: %w", err)
+	}
+
+	if err := json.Unmarshal([]byte(v), c); err != nil {
+		return fmt.Errorf("unmarshaling config JSON: %w", err)
+	}
+
+	return nil
+}
+
+func (this *SQLite) Create(c *Config) error {
+	tx, err := this.db.Begin()
+	if err != nil {
+		return fmt.Errorf("starting SQLite transaction: %w", err)
+	}
+
+	defer tx.Rollback()
+
+	var exists int
+
+	err = tx.QueryRow(
+		`SELECT COUNT(*) FROM configs WHERE kind = ? AND name = ?`, strings.ToLower(c.Kind), c.Metadata.Name,
+	).Scan(&exists)
+
+	if err != nil {
+		return fmt.Errorf("checking for existing config: %w", err)
+	}
+
+	if exists != 0 {
+		return ErrExist
+	}
+
+	now := time.Now().Format(time.RFC3339)
+
+	// See the BoltDB store for why the created timestamp may already be set.
+	if c.Metadata.Created == "" {
+		c.Metadata.Created = now
+	}
+
+	c.Metadata.Updated = now
+	c.Metadata.ResourceVersion = 1
+
+	v, err := json.Marshal(c)
+	if err != nil {
+		return fmt.Errorf("marshaling config JSON: %w", err)
+	}
+
+	_, err = tx.Exec(
+		`INSERT INTO configs (kind, name, data) VALUES (?, ?, ?)`, strings.ToLower(c.Kind), c.Metadata.Name, string(v),
+	)
+
+	if err != nil {
+		return fmt.Errorf("writing config JSON to SQLite: %w", err)
+	}
+
+	if err := this.logChange(tx, ConfigEventCreate, *c, nil); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("writing config JSON to SQLite: %w", err)
+	}
+
+	return nil
+}
+
+func (this *SQLite) Update(c *Config) error {
+	return this.update(c, func(Config) (Config, error) { return *c, nil })
+}
+
+func (this *SQLite) Patch(c *Config, patch []byte, opts ...PatchOption) error {
+	o := NewPatchOptions(opts...)
+
+	return this.update(c, func(existing Config) (Config, error) {
+		return applyPatch(existing, patch, o)
+	})
+}
+
+// update replaces the given config in the store with the config returned by
+// the given function, which is passed the config currently in the store. The
+// resource version check, function call, and write all happen in the same
+// (immediate) transaction so no other writer can sneak in between them.
+func (this *SQLite) update(c *Config, fn func(Config) (Config, error)) error {
+	tx, err := this.db.Begin()
+	if err != nil {
+		return fmt.Errorf("starting SQLite transaction: %w", err)
+	}
+
+	defer tx.Rollback()
+
+	var (
+		kind = strings.ToLower(c.Kind)
+		v    string
+	)
+
+	err = tx.QueryRow(`SELECT data FROM configs WHERE kind = ? AND name = ?`, kind, c.Metadata.Name).Scan(&v)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrNotExist
+		}
+
+		return fmt.Errorf("getting config from SQLite: %w", err)
+	}
+
+	var existing Config
+
+	if err := json.Unmarshal([]byte(v), &existing); err != nil {
+		return fmt.Errorf("unmarshaling config JSON: %w", err)
+	}
+
+	version := existing.Metadata.ResourceVersion
+
+	if c.Metadata.ResourceVersion != 0 && c.Metadata.ResourceVersion != version {
+		return ConflictError{
+			Kind: c.Kind, Name: c.Metadata.Name, Expected: c.Metadata.ResourceVersion, Actual: version,
+		}
+	}
+
+	updated, err := fn(existing)
+	if err != nil {
+		return err
+	}
+
+	updated.Metadata.Updated = time.Now().Format(time.RFC3339)
+	updated.Metadata.ResourceVersion = version + 1
+
+	body, err := json.Marshal(updated)
+	if err != nil {
+		return fmt.Errorf("marshaling config JSON: %w", err)
+	}
+
+	_, err = tx.Exec(`UPDATE configs SET data = ? WHERE kind = ? AND name = ?`, string(body), kind, c.Metadata.Name)
+	if err != nil {
+		return fmt.Errorf("writing config JSON to SQLite: %w", err)
+	}
+
+	if revisionChanged(existing, updated) {
+		// The previous config is stored as-is since it's exactly what was read
+		// from SQLite above.
+		if err := this.recordRevision(tx, existing, v); err != nil {
+			return err
+		}
+	}
+
+	if err := this.logChange(tx, ConfigEventUpdate, updated, &existing); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("writing config JSON to SQLite: %w", err)
+	}
+
+	*c = updated
+
+	return nil
+}
+
+func (this *SQLite) Delete(c *Config) error {
+	tx, err := this.db.Begin()
+	if err != nil {
+		return fmt.Errorf("starting SQLite transaction: %w", err)
+	}
+
+	defer tx.Rollback()
+
+	var (
+		kind = strings.ToLower(c.Kind)
+		v    string
+	)
+
+	err = tx.QueryRow(`SELECT data FROM configs WHERE kind = ? AND name = ?`, kind, c.Metadata.Name).Scan(&v)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("deleting config %s/%s: %w", c.Kind, c.Metadata.Name, ErrNotExist)
+		}
+
+		return fmt.Errorf("getting config from SQLite: %w", err)
+	}
+
+	var existing Config
+
+	if err := json.Unmarshal([]byte(v), &existing); err != nil {
+		return fmt.Errorf("unmarshaling config JSON: %w", err)
+	}
+
+	if _, err := tx.Exec(`DELETE FROM configs WHERE kind = ? AND name = ?`, kind, c.Metadata.Name); err != nil {
+		return fmt.Errorf("deleting config %s/%s: %w", c.Kind, c.Metadata.Name, err)
+	}
+
+	if _, err := tx.Exec(`DELETE FROM revisions WHERE kind = ? AND name = ?`, kind, c.Metadata.Name); err != nil {
+		return fmt.Errorf("deleting config revisions: %w", err)
+	}
+
+	deleted := Config{
+		Version:  existing.Version,
+		Kind:     existing.Kind,
+		Metadata: ConfigMetadata{Name: c.Metadata.Name},
+	}
+
+	if err := this.logChange(tx, ConfigEventDelete, deleted, &existing); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("deleting config %s/%s: %w", c.Kind, c.Metadata.Name, err)
+	}
+
+	return nil
+}
+
+func (this *SQLite) Revisions(c *Config) (Configs, error) {
+	rows, err := this.db.Query(
+		`SELECT data FROM revisions WHERE kind = ? AND name = ? ORDER BY version`,
+		strings.ToLower(c.Kind), c.Metadata.Name,
+	)
+
+	if err != nil {
+		return nil, fmt.Errorf("getting config revisions from store: %w", err)
+	}
+
+	defer rows.Close()
+
+	var revisions Configs
+
+	for rows.Next() {
+		var (
+			v   string
+			rev Config
+		)
+
+		if err := rows.Scan(&v); err != nil {
+			return nil, fmt.Errorf("scanning config revision row: %w", err)
+		}
+
+		if err := json.Unmarshal([]byte(v), &rev); err != nil {
+			return nil, fmt.Errorf("unmarshaling config JSON: %w", err)
+		}
+
+		revisions = append(revisions, rev)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("getting config revisions from store: %w", err)
+	}
+
+	return revisions, nil
+}
+
+func (this *SQLite) GetEvents() (Events, error) {
+	return this.queryEvents(`SELECT data FROM events ORDER BY timestamp`)
+}
+
+func (this *SQLite) GetEventsBy(e Event) (Events, error) {
+	if e.ID != "" {
+		if err := this.GetEvent(&e); err != nil {
+			return nil, err
+		}
+
+		return Events{e}, nil
+	}
+
+	return this.QueryEvents(eventQueryFromEvent(e))
+}
+
+func (this *SQLite) GetEvent(e *Event) error {
+	var v string
+
+	if err := this.db.QueryRow(`SELECT data FROM events WHERE id = ?`, e.ID).Scan(&v); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("getting event: %w: event %s", ErrNotExist, e.ID)
+		}
+
+		return fmt.Errorf("getting event: %w", err)
+	}
+
+	if err := json.Unmarshal([]byte(v), e); err != nil {
+		return fmt.Errorf("unmarshaling event JSON: %w", err)
+	}
+
+	return nil
+}
+
+func (this *SQLite) AddEvent(e Event) error {
+	v, err := json.Marshal(e)
+	if err != nil {
+		return fmt.Errorf("marshaling event JSON: %w", err)
+	}
+
+	tx, err := this.db.Begin()
+	if err != nil {
+		return fmt.Errorf("starting SQLite transaction: %w", err)
+	}
+
+	defer tx.Rollback()
+
+	_, err = tx.Exec(
+		`INSERT INTO events (id, timestamp, type, source, data) VALUES (?, ?, ?, ?, ?)`,
+		e.ID, e.Timestamp.UnixNano(), string(e.Type), e.Source, string(v),
+	)
+
+	if err != nil {
+		return fmt.Errorf("writing event JSON to SQLite: %w", err)
+	}
+
+	for k, val := range e.Metadata {
+		_, err := tx.Exec(`INSERT INTO event_metadata (event_id, key, value) VALUES (?, ?, ?)`, e.ID, k, val)
+		if err != nil {
+			return fmt.Errorf("writing event metadata to SQLite: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("writing event JSON to SQLite: %w", err)
+	}
+
+	this.pruneExpiredEvents()
+
+	return nil
+}
+
+func (this *SQLite) QueryEvents(q EventQuery) (Events, error) {
+	var (
+		query = `SELECT data FROM events WHERE 1 = 1`
+		args  []any
+	)
+
+	if len(q.Types) > 0 {
+		params := make([]string, len(q.Types))
+
+		for i, t := range q.Types {
+			params[i] = "?"
+			args = append(args, string(t))
+		}
+
+		query += fmt.Sprintf(` AND type IN (%s)`, strings.Join(params, ", "))
+	}
+
+	if len(q.ExcludeTypes) > 0 {
+		params := make([]string, len(q.ExcludeTypes))
+
+		for i, t := range q.ExcludeTypes {
+			params[i] = "?"
+			args = append(args, string(t))
+		}
+
+		query += fmt.Sprintf(` AND type NOT IN (%s)`, strings.Join(params, ", "))
+	}
+
+	if q.Source != "" {
+		query += ` AND source = ?`
+		args = append(args, q.Source)
+	}
+
+	if !q.Since.IsZero() {
+		query += ` AND timestamp >= ?`
+		args = append(args, q.Since.UnixNano())
+	}
+
+	if !q.Until.IsZero() {
+		query += ` AND timestamp < ?`
+		args = append(args, q.Until.UnixNano())
+	}
+
+	for k, v := range q.Metadata {
+		query += ` AND id IN (SELECT event_id FROM event_metadata WHERE key = ? AND value = ?)`
+		args = append(args, k, v)
+	}
+
+	if q.Ascending {
+		query += ` ORDER BY timestamp ASC`
+	} else {
+		query += ` ORDER BY timestamp DESC`
+	}
+
+	if q.Limit > 0 {
+		query += ` LIMIT ?`
+		args = append(args, q.Limit)
+	}
+
+	return this.queryEvents(query, args...)
+}
+
+func (this *SQLite) PruneEvents(before time.Time) (int, error) {
+	tx, err := this.db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("starting SQLite transaction: %w", err)
+	}
+
+	defer tx.Rollback()
+
+	_, err = tx.Exec(
+		`DELETE FROM event_metadata WHERE event_id IN (SELECT id FROM events WHERE timestamp < ?)`, before.UnixNano(),
+	)
+
+	if err != nil {
+		return 0, fmt.Errorf("pruning event metadata from store: %w", err)
+	}
+
+	res, err := tx.Exec(`DELETE FROM events WHERE timestamp < ?`, before.UnixNano())
+	if err != nil {
+		return 0, fmt.Errorf("pruning events from store: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("pruning events from store: %w", err)
+	}
+
+	pruned, _ := res.RowsAffected()
+
+	return int(pruned), nil
+}
+
+func (this *SQLite) Watch(kinds ...string) (<-chan ConfigEvent, context.CancelFunc) {
+	var (
+		ctx, cancel = context.WithCancel(context.Background())
+		events      = make(chan ConfigEvent)
+	)
+
+	// Only stream changes made after Watch was called.
+	last := this.changelogSequence()
+
+	go func() {
+		defer close(events)
+
+		// SQLite has no way of notifying us about writes made by other processes,
+		// so the changelog has to be polled.
+		ticker := time.NewTicker(SQLiteWatchInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+
+			var changes []ConfigEvent
+
+			changes, last = this.changesSince(last)
+
+			for _, change := range changes {
+				if !watchingKind(kinds, change.Config.Kind) {
+					continue
+				}
+
+				select {
+				case events <- change:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+
+	return events, cancel
+}
+
+// logChange records the given config change in the changelog table so it can
+// be picked up by watchers. Only the most recent changes are kept in the
+// changelog.
+func (this *SQLite) logChange(tx *sql.Tx, typ ConfigEventType, c Config, prev *Config) error {
+	v, err := json.Marshal(ConfigEvent{Type: typ, Config: c, Previous: prev})
+	if err != nil {
+		return fmt.Errorf("marshaling config event JSON: %w", err)
+	}
+
+	res, err := tx.Exec(`INSERT INTO changelog (data) VALUES (?)`, string(v))
+	if err != nil {
+		return fmt.Errorf("writing config event to changelog: %w", err)
+	}
+
+	seq, err := res.LastInsertId()
+	if err != nil {
+		return fmt.Errorf("getting changelog sequence: %w", err)
+	}
+
+	if _, err := tx.Exec(`DELETE FROM changelog WHERE seq <= ?`, seq-changelogSize); err != nil {
+		return fmt.Errorf("pruning changelog: %w", err)
+	}
+
+	return nil
+}
+
+// recordRevision records the given config (and its JSON representation) as a
+// prior revision, pruning the oldest revisions of the config if there are more
+// than RevisionLimit.
+func (this *SQLite) recordRevision(tx *sql.Tx, c Config, v string) error {
+	kind := strings.ToLower(c.Kind)
+
+	_, err := tx.Exec(
+		`INSERT OR REPLACE INTO revisions (kind, name, version, data) VALUES (?, ?, ?, ?)`,
+		kind, c.Metadata.Name, c.Metadata.ResourceVersion, v,
+	)
+
+	if err != nil {
+		return fmt.Errorf("writing config revision: %w", err)
+	}
+
+	_, err = tx.Exec(
+		`DELETE FROM revisions WHERE kind = ? AND name = ? AND version NOT IN (
+			SELECT version FROM revisions WHERE kind = ? AND name = ? ORDER BY version DESC LIMIT ?
+		)`,
+		kind, c.Metadata.Name, kind, c.Metadata.Name, RevisionLimit,
+	)
+
+	if err != nil {
+		return fmt.Errorf("pruning config revisions: %w", err)
+	}
+
+	return nil
+}
+
+// changelogSequence returns the sequence number of the most recent change
+// recorded in the changelog.
+func (this *SQLite) changelogSequence() int64 {
+	var seq int64
+
+	this.db.QueryRow(`SELECT COALESCE(MAX(seq), 0) FROM changelog`).Scan(&seq)
+
+	return seq
+}
+
+// changesSince returns all the changes recorded in the changelog after the
+// given sequence number, along with the sequence number of the most recent
+// change returned.
+func (this *SQLite) changesSince(seq int64) ([]ConfigEvent, int64) {
+	rows, err := this.db.Query(`SELECT seq, data FROM changelog WHERE seq > ? ORDER BY seq`, seq)
+	if err != nil {
+		return nil, seq
+	}
+
+	defer rows.Close()
+
+	var changes []ConfigEvent
+
+	for rows.Next() {
+		var (
+			v      string
+			change ConfigEvent
+		)
+
+		if err := rows.Scan(&seq, &v); err != nil {
+			continue
+		}
+
+		if err := json.Unmarshal([]byte(v), &change); err != nil {
+			continue
+		}
+
+		changes = append(changes, change)
+	}
+
+	return changes, seq
+}
+
+// pruneExpiredEvents prunes events older than the configured retention period,
+// at most once every sqlitePruneInterval.
+func (this *SQLite) pruneExpiredEvents() {
+	if this.retention <= 0 {
+		return
+	}
+
+	this.Lock()
+
+	if time.Since(this.lastPrune) < sqlitePruneInterval {
+		this.Unlock()
+		return
+	}
+
+	this.lastPrune = time.Now()
+	this.Unlock()
+
+	pruned, err := this.PruneEvents(time.Now().Add(-this.retention))
+	if err != nil {
+		plog.Error("pruning expired events from SQLite store", "err", err)
+		return
+	}
+
+	if pruned > 0 {
+		plog.Debug("pruned expired events from SQLite store", "count", pruned, "retention", this.retention)
+	}
+}
+
+func (this *SQLite) queryEvents(query string, args ...any) (Events, error) {
+	rows, err := this.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("getting events from store: %w", err)
+	}
+
+	defer rows.Close()
+
+	var events Events
+
+	for rows.Next() {
+		var (
+			v string
+			e Event
+		)
+
+		if err := rows.Scan(&v); err != nil {
+			return nil, fmt.Errorf("scanning event row: %w", err)
+		}
+
+		if err := json.Unmarshal([]byte(v), &e); err != nil {
+			return nil, fmt.Errorf("unmarshaling event JSON: %w", err)
+		}
+
+		events = append(events, e)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("getting events from store: %w", err)
+	}
+
+	return events, nil
+}
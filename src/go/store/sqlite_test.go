@@ -0,0 +1,317 @@
+package store
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+func newTestSQLite(t *testing.T) Store {
+	s := NewSQLite()
+
+	if err := s.Init(Endpoint("sqlite://" + filepath.Join(t.TempDir(), "phenix.db"))); err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+
+	t.Cleanup(func() { s.Close() })
+
+	return s
+}
+
+func TestSQLiteConfig(t *testing.T) {
+	s := newTestSQLite(t)
+
+	var c Config
+
+	if err := yaml.Unmarshal([]byte(topology), &c); err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+
+	if err := s.Create(&c); err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+
+	if err := s.Create(&c); !errors.Is(err, ErrExist) {
+		t.Logf("expected ErrExist creating duplicate config, got %v", err)
+		t.FailNow()
+	}
+
+	configs, err := s.List("Topology")
+	if err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+
+	if len(configs) != 1 || configs[0].Metadata.Name != "foobar" {
+		t.Logf("unexpected configs: %v", configs)
+		t.FailNow()
+	}
+
+	stale := c
+
+	c.Spec["nodes"] = []any{}
+
+	if err := s.Update(&c); err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+
+	if err := s.Update(&stale); !errors.Is(err, ErrConflict) {
+		t.Logf("expected ErrConflict updating stale config, got %v", err)
+		t.FailNow()
+	}
+
+	if err := s.Patch(&c, []byte(`{"status":{"foo":"bar"}}`)); err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+
+	got := Config{Kind: "Topology", Metadata: ConfigMetadata{Name: "foobar"}}
+
+	if err := s.Get(&got); err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+
+	if got.Metadata.ResourceVersion != 3 || got.Status["foo"] != "bar" {
+		t.Logf("unexpected config after patch: %v", got)
+		t.FailNow()
+	}
+
+	// Only the spec update results in a revision.
+	revs, err := s.Revisions(&got)
+	if err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+
+	if len(revs) != 1 || revs[0].Metadata.ResourceVersion != 1 {
+		t.Logf("unexpected revisions: %v", revs)
+		t.FailNow()
+	}
+
+	if err := s.Delete(&got); err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+
+	if err := s.Get(&got); !errors.Is(err, ErrNotExist) {
+		t.Logf("expected ErrNotExist getting deleted config, got %v", err)
+		t.FailNow()
+	}
+
+	if revs, _ := s.Revisions(&got); len(revs) != 0 {
+		t.Logf("expected revisions to be deleted, got %v", revs)
+		t.FailNow()
+	}
+}
+
+func TestSQLiteConfigWatch(t *testing.T) {
+	SQLiteWatchInterval = 10 * time.Millisecond
+
+	s := newTestSQLite(t)
+
+	events, cancel := s.Watch("Topology")
+	defer cancel()
+
+	var c Config
+
+	if err := yaml.Unmarshal([]byte(topology), &c); err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+
+	if err := s.Create(&c); err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+
+	if err := s.Delete(&c); err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+
+	for _, expected := range []ConfigEventType{ConfigEventCreate, ConfigEventDelete} {
+		select {
+		case event := <-events:
+			if event.Type != expected || event.Config.Kind != "Topology" {
+				t.Logf("expected %s event for Topology, got %s event for %s", expected, event.Type, event.Config.Kind)
+				t.FailNow()
+			}
+		case <-time.After(time.Second):
+			t.Logf("timed out waiting for %s event", expected)
+			t.FailNow()
+		}
+	}
+}
+
+func TestSQLiteEventQuery(t *testing.T) {
+	s := newTestSQLite(t)
+
+	now := time.Now()
+
+	events := []Event{
+		{ID: "1", Timestamp: now.Add(-3 * time.Hour), Type: EventTypeInfo, Source: "foo"},
+		{ID: "2", Timestamp: now.Add(-2 * time.Hour), Type: EventTypeError, Source: "foo", Metadata: map[string]string{"exp": "bar"}},
+		{ID: "3", Timestamp: now.Add(-1 * time.Hour), Type: EventTypeHistory, Source: "baz", Metadata: map[string]string{"exp": "bar", "user": "admin"}},
+		{ID: "4", Timestamp: now, Type: EventTypeInfo, Source: "baz", Metadata: map[string]string{"exp": "sucka"}},
+	}
+
+	for _, e := range events {
+		if err := s.AddEvent(e); err != nil {
+			t.Log(err)
+			t.FailNow()
+		}
+	}
+
+	ids := func(events Events) string {
+		var ids string
+
+		for _, e := range events {
+			ids += e.ID
+		}
+
+		return ids
+	}
+
+	queries := []struct {
+		query    EventQuery
+		expected string
+	}{
+		{EventQuery{}, "4321"},
+		{EventQuery{Types: []EventType{EventTypeInfo}}, "41"},
+		{EventQuery{Types: []EventType{EventTypeInfo, EventTypeError}}, "421"},
+		{EventQuery{Source: "foo"}, "21"},
+		{EventQuery{Metadata: map[string]string{"exp": "bar"}}, "32"},
+		{EventQuery{Metadata: map[string]string{"exp": "bar", "user": "admin"}}, "3"},
+		{EventQuery{Since: now.Add(-2 * time.Hour), Until: now}, "32"},
+		{EventQuery{Limit: 2}, "43"},
+		{EventQuery{Since: now.Add(-2 * time.Hour), Limit: 2, Ascending: true}, "23"},
+	}
+
+	for _, q := range queries {
+		got, err := s.QueryEvents(q.query)
+		if err != nil {
+			t.Log(err)
+			t.FailNow()
+		}
+
+		if ids(got) != q.expected {
+			t.Logf("expected events %s for query %+v, got %s", q.expected, q.query, ids(got))
+			t.FailNow()
+		}
+
+		// The BoltDB store scans events instead of querying indexes, so make sure
+		// both approaches agree.
+		if filtered := q.query.Filter(events); ids(filtered) != q.expected {
+			t.Logf("expected filtered events %s for query %+v, got %s", q.expected, q.query, ids(filtered))
+			t.FailNow()
+		}
+	}
+
+	got, err := s.GetEventsBy(Event{Type: EventTypeInfo, Source: "baz"})
+	if err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+
+	if ids(got) != "4" {
+		t.Logf("expected event 4 for GetEventsBy, got %s", ids(got))
+		t.FailNow()
+	}
+
+	pruned, err := s.PruneEvents(now.Add(-90 * time.Minute))
+	if err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+
+	if pruned != 2 {
+		t.Logf("expected 2 events to be pruned, got %d", pruned)
+		t.FailNow()
+	}
+
+	if got, _ := s.GetEvents(); ids(got) != "34" {
+		t.Logf("expected events 34 to remain after pruning, got %s", ids(got))
+		t.FailNow()
+	}
+
+	if got, _ := s.QueryEvents(EventQuery{Metadata: map[string]string{"exp": "bar"}}); ids(got) != "3" {
+		t.Logf("expected event metadata to be pruned, got %s", ids(got))
+		t.FailNow()
+	}
+}
+
+func TestMigrateBoltToSQLite(t *testing.T) {
+	bolt := NewBoltDB()
+
+	if err := bolt.Init(Endpoint("bolt://" + filepath.Join(t.TempDir(), "phenix.bdb"))); err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+
+	var c Config
+
+	if err := yaml.Unmarshal([]byte(topology), &c); err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+
+	if err := bolt.Create(&c); err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+
+	if err := bolt.AddEvent(*NewInfoEvent("foo").WithMetadata("exp", "bar")); err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+
+	sqlite := newTestSQLite(t)
+
+	result, err := Migrate(bolt, sqlite, "Topology", "Scenario")
+	if err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+
+	if result.Configs != 1 || result.Events != 1 || result.Skipped != 0 {
+		t.Logf("unexpected migration result: %+v", result)
+		t.FailNow()
+	}
+
+	got := Config{Kind: "Topology", Metadata: ConfigMetadata{Name: "foobar"}}
+
+	if err := sqlite.Get(&got); err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+
+	if got.Metadata.Created != c.Metadata.Created {
+		t.Logf("expected created timestamp %s to be preserved, got %s", c.Metadata.Created, got.Metadata.Created)
+		t.FailNow()
+	}
+
+	if events, _ := sqlite.QueryEvents(EventQuery{Metadata: map[string]string{"exp": "bar"}}); len(events) != 1 {
+		t.Logf("expected migrated event to be queryable, got %v", events)
+		t.FailNow()
+	}
+
+	// Running the migration again should skip everything.
+	result, err = Migrate(bolt, sqlite, "Topology", "Scenario")
+	if err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+
+	if result.Configs != 0 || result.Events != 0 || result.Skipped != 2 {
+		t.Logf("unexpected migration result: %+v", result)
+		t.FailNow()
+	}
+}
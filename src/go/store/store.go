@@ -1,12 +1,65 @@
 package store
 
-import "fmt"
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"time"
+)
 
 var (
 	ErrExist    = fmt.Errorf("config already exists")
 	ErrNotExist = fmt.Errorf("config does not exist")
+	ErrConflict = fmt.Errorf("config resource version conflict")
+)
+
+// RevisionLimit is the maximum number of prior revisions of each config kept by
+// the store. The oldest revisions are pruned once the limit is reached. A limit
+// of zero disables revision history.
+var RevisionLimit = 25
+
+// ConflictError is returned by Update when the resource version of the config
+// being updated doesn't match the resource version of the config currently in
+// the store, meaning the config was modified by someone else since it was last
+// read. It wraps ErrConflict, so callers can check for it using `errors.Is`.
+type ConflictError struct {
+	Kind     string
+	Name     string
+	Expected int64
+	Actual   int64
+}
+
+func (this ConflictError) Error() string {
+	return fmt.Sprintf(
+		"%v: %s/%s has resource version %d (expected %d)",
+		ErrConflict, this.Kind, this.Name, this.Actual, this.Expected,
+	)
+}
+
+func (this ConflictError) Unwrap() error {
+	return ErrConflict
+}
+
+type ConfigEventType string
+
+const (
+	ConfigEventCreate ConfigEventType = "create"
+	ConfigEventUpdate ConfigEventType = "update"
+	ConfigEventDelete ConfigEventType = "delete"
 )
 
+// ConfigEvent describes a change made to a config in the store. For update and
+// delete events, Previous holds the config as it was before the change (if the
+// store implementation is able to provide it). For delete events, Config only
+// has its kind and name set.
+type ConfigEvent struct {
+	Type     ConfigEventType `json:"type"`
+	Config   Config          `json:"config"`
+	Previous *Config         `json:"previous,omitempty"`
+}
+
 // Store is the interface that identifies all the required functionality for a
 // config store. Not all functions are required to be implemented. If not
 // implemented, they should return an error stating such.
@@ -27,16 +80,31 @@ type Store interface {
 	// Create persists the given config to the store if it doesn't already exist.
 	Create(*Config) error
 
-	// Update persists the given config to the store if it already exists.
+	// Update persists the given config to the store if it already exists. If the
+	// given config has a non-zero resource version that doesn't match the
+	// resource version of the config in the store, a ConflictError is returned
+	// and the store is left untouched. On success, the resource version of the
+	// given config is incremented to match the store.
 	Update(*Config) error
 
-	// Patch modifies the given config in the store with the given data if the
-	// config already exists.
-	Patch(*Config, map[string]interface{}) error
+	// Patch applies the given patch to the spec and status of the given config in
+	// the store if it already exists. Patches are applied to a JSON document
+	// containing only the config's `spec` and `status` fields, and are RFC 7386
+	// merge patches unless another type is given as an option. The resource
+	// version check and result are the same as for Update, with the given config
+	// being set to the patched config on success.
+	Patch(*Config, []byte, ...PatchOption) error
 
-	// Delete removes the given config from the config store.
+	// Delete removes the given config, along with its revision history, from
+	// the config store.
 	Delete(*Config) error
 
+	// Revisions returns the prior revisions of the given config kept by the
+	// store, oldest first. A revision is recorded each time the spec or
+	// annotations of a config are changed by an update, and is identified by its
+	// resource version.
+	Revisions(*Config) (Configs, error)
+
 	// GetEvents gets all the events from the store.
 	GetEvents() (Events, error)
 
@@ -48,4 +116,44 @@ type Store interface {
 
 	// AddEvent adds the given event to the store.
 	AddEvent(Event) error
+
+	// QueryEvents gets the events from the store that match the given query,
+	// sorted newest first.
+	QueryEvents(EventQuery) (Events, error)
+
+	// PruneEvents deletes all the events in the store with a timestamp before
+	// the given time, returning the number of events deleted.
+	PruneEvents(time.Time) (int, error)
+
+	// Watch streams changes made to configs of the given kind(s) in the store,
+	// including changes made by other processes using the same store. If no
+	// kinds are given, changes to configs of all kinds are streamed. The
+	// returned channel is closed once the returned cancel function is called.
+	Watch(...string) (<-chan ConfigEvent, context.CancelFunc)
+}
+
+// revisionChanged returns true if the given updated config should result in a
+// new revision being recorded for the previous config. Updates that only
+// modify a config's status (like experiment app status changes) don't result
+// in new revisions so they don't push meaningful revisions out of the history.
+func revisionChanged(prev, updated Config) bool {
+	if RevisionLimit <= 0 {
+		return false
+	}
+
+	if prev.Version != updated.Version {
+		return true
+	}
+
+	if !reflect.DeepEqual(prev.Metadata.Annotations, updated.Metadata.Annotations) {
+		return true
+	}
+
+	// The updated spec may contain typed values that haven't been through a JSON
+	// round trip yet, so compare the JSON representations of the specs instead
+	// of the specs themselves.
+	p, _ := json.Marshal(prev.Spec)
+	u, _ := json.Marshal(updated.Spec)
+
+	return !bytes.Equal(p, u)
 }
@@ -0,0 +1,147 @@
+package store
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"go.etcd.io/etcd/v3/embed"
+	"gopkg.in/yaml.v3"
+)
+
+// testStores returns an initialized instance of each store implementation,
+// backed by temporary files (or an embedded Etcd server) that are removed when
+// the test completes. The BoltDB store opens and closes its database on each
+// call, so it isn't closed here.
+func testStores(t *testing.T) map[string]Store {
+	dir, err := ioutil.TempDir("", "phenix")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	stores := make(map[string]Store)
+
+	bolt := NewBoltDB()
+
+	if err := bolt.Init(Endpoint("bolt://" + dir + "/phenix.bdb")); err != nil {
+		t.Fatal(err)
+	}
+
+	stores["bolt"] = bolt
+
+	sqlite := NewSQLite()
+
+	if err := sqlite.Init(Endpoint("sqlite://" + dir + "/phenix.db")); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() { sqlite.Close() })
+
+	stores["sqlite"] = sqlite
+
+	etcd := testEtcd(t, dir+"/etcd")
+
+	t.Cleanup(func() { etcd.Close() })
+
+	stores["etcd"] = etcd
+
+	return stores
+}
+
+// testEtcd starts an embedded Etcd server using the given data directory and
+// returns a store connected to it.
+func testEtcd(t *testing.T, dir string) Store {
+	cfg := embed.NewConfig()
+
+	cfg.Dir = dir
+	cfg.LogLevel = "error"
+
+	client, _ := url.Parse("http://127.0.0.1:0")
+	peer, _ := url.Parse("http://127.0.0.1:0")
+
+	cfg.LCUrls = []url.URL{*client}
+	cfg.LPUrls = []url.URL{*peer}
+
+	srv, err := embed.StartEtcd(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(srv.Close)
+
+	select {
+	case <-srv.Server.ReadyNotify():
+	case <-time.After(30 * time.Second):
+		t.Fatal("timed out waiting for embedded Etcd server")
+	}
+
+	endpoint := srv.Clients[0].Addr().String()
+
+	etcd := NewEtcd()
+
+	if err := etcd.Init(Endpoint("etcd://" + endpoint)); err != nil {
+		t.Fatal(err)
+	}
+
+	return etcd
+}
+
+func TestConfigUpdateUnversionedConcurrent(t *testing.T) {
+	const writers = 10
+
+	for name, s := range testStores(t) {
+		t.Run(name, func(t *testing.T) {
+			var c Config
+
+			if err := yaml.Unmarshal([]byte(topology), &c); err != nil {
+				t.Fatal(err)
+			}
+
+			if err := s.Create(&c); err != nil {
+				t.Fatal(err)
+			}
+
+			var (
+				wg   sync.WaitGroup
+				errs = make(chan error, writers)
+			)
+
+			for i := 0; i < writers; i++ {
+				wg.Add(1)
+
+				go func(i int) {
+					defer wg.Done()
+
+					update := c
+					update.Metadata.ResourceVersion = 0
+					update.Metadata.Annotations = map[string]string{"writer": fmt.Sprint(i)}
+
+					errs <- s.Update(&update)
+				}(i)
+			}
+
+			wg.Wait()
+			close(errs)
+
+			for err := range errs {
+				if err != nil {
+					t.Fatalf("unversioned update failed: %v", err)
+				}
+			}
+
+			if err := s.Get(&c); err != nil {
+				t.Fatal(err)
+			}
+
+			if c.Metadata.ResourceVersion != writers+1 {
+				t.Fatalf("expected resource version %d, got %d", writers+1, c.Metadata.ResourceVersion)
+			}
+		})
+	}
+}
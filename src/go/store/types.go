@@ -38,6 +38,11 @@ type ConfigMetadata struct {
 	Created     string      `json:"created" yaml:"created"`
 	Updated     string      `json:"updated" yaml:"updated"`
 	Annotations Annotations `json:"annotations,omitempty" yaml:"annotations,omitempty"`
+
+	// ResourceVersion is incremented by the store every time the config is
+	// written, and is used to detect concurrent modifications of a config. A
+	// value of zero (the default) disables the version check on update.
+	ResourceVersion int64 `json:"resourceVersion,omitempty" yaml:"resourceVersion,omitempty"`
 }
 
 func NewConfig(name string) (*Config, error) {
@@ -205,6 +210,7 @@ const (
 	EventTypeError   EventType = "error"
 	EventTypeUnknown EventType = "unknown"
 	EventTypeHistory EventType = "history"
+	EventTypeAudit   EventType = "audit"
 )
 
 type Event struct {
@@ -268,3 +274,101 @@ func (this Events) SortByTimestamp(asc bool) {
 		return this[i].Timestamp.After(this[j].Timestamp)
 	})
 }
+
+// EventQuery filters the events returned by `QueryEvents`. Zero-valued fields
+// match all events. Events must match all the given metadata key/value pairs,
+// and at least one of the given types if any are given, and must not be any of
+// the given excluded types. Since is inclusive and Until is exclusive.
+type EventQuery struct {
+	Types        []EventType
+	ExcludeTypes []EventType
+	Source       string
+	Metadata     map[string]string
+	Since        time.Time
+	Until        time.Time
+
+	// Limit caps the number of events returned (newest first). Zero means no
+	// limit.
+	Limit int
+
+	// Ascending returns events oldest first instead, so Limit caps the number of
+	// oldest events returned. Combined with Since, it allows paging through
+	// events in the order they happened.
+	Ascending bool
+}
+
+// Matches returns true if the given event matches the query.
+func (this EventQuery) Matches(e Event) bool {
+	if len(this.Types) > 0 {
+		var found bool
+
+		for _, t := range this.Types {
+			if e.Type == t {
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			return false
+		}
+	}
+
+	for _, t := range this.ExcludeTypes {
+		if e.Type == t {
+			return false
+		}
+	}
+
+	if this.Source != "" && e.Source != this.Source {
+		return false
+	}
+
+	for k, v := range this.Metadata {
+		if e.Metadata[k] != v {
+			return false
+		}
+	}
+
+	if !this.Since.IsZero() && e.Timestamp.Before(this.Since) {
+		return false
+	}
+
+	if !this.Until.IsZero() && !e.Timestamp.Before(this.Until) {
+		return false
+	}
+
+	return true
+}
+
+// Filter returns the given events matching the query, sorted newest first (or
+// oldest first if the query is ascending) and capped at the query's limit.
+func (this EventQuery) Filter(events Events) Events {
+	var matched Events
+
+	for _, e := range events {
+		if this.Matches(e) {
+			matched = append(matched, e)
+		}
+	}
+
+	matched.SortByTimestamp(this.Ascending)
+
+	if this.Limit > 0 && len(matched) > this.Limit {
+		matched = matched[:this.Limit]
+	}
+
+	return matched
+}
+
+// eventQueryFromEvent converts the filter given to `GetEventsBy` to an event
+// query. The event ID is not part of the query.
+func eventQueryFromEvent(e Event) EventQuery {
+	q := EventQuery{Source: e.Source, Metadata: e.Metadata}
+
+	if e.Type != EventTypeNotSet {
+		q.Types = []EventType{e.Type}
+	}
+
+	return q
+}
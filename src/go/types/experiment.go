@@ -1,9 +1,14 @@
 package types
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
 	"path/filepath"
+	"reflect"
 	"strings"
+	"sync"
+	"time"
 
 	"phenix/store"
 	ifaces "phenix/types/interfaces"
@@ -22,8 +27,34 @@ type Experiment struct {
 
 	// used for user apps
 	Hosts mm.Hosts `json:"hosts,omitempty" yaml:"hosts,omitempty"` // cluster host details
+
+	// status as it was last read from or written to the store, used as the base
+	// when merging concurrent status updates
+	status map[string]any
+
+	// spec and annotations as they were last read from or written to the store,
+	// used as the base when merging concurrent spec and annotation updates
+	spec        map[string]any
+	annotations map[string]string
+
+	// serializes modifications made to the experiment by concurrently applied
+	// apps (see Modify)
+	mu *sync.Mutex
 }
 
+// maxWriteAttempts is the number of times an experiment write will be
+// attempted when it conflicts with a concurrent write to the same experiment.
+const maxWriteAttempts = 5
+
+// ErrWriteAborted can be returned by functions passed to UpdateStatus to signal
+// that the status should be left as-is. UpdateStatus returns it unwrapped so
+// callers can check for it.
+var ErrWriteAborted = errors.New("experiment write aborted")
+
+// ErrSpecConflict is returned by MergeSpec when the experiment spec was changed
+// concurrently in a way that conflicts with the spec being merged.
+var ErrSpecConflict = errors.New("conflicting experiment spec changes")
+
 func NewExperiment(md store.ConfigMetadata) *Experiment {
 	ver := version.StoredVersion["Experiment"]
 
@@ -37,6 +68,31 @@ func NewExperiment(md store.ConfigMetadata) *Experiment {
 		Metadata: md,
 		Spec:     spec.(ifaces.ExperimentSpec),
 		Status:   status.(ifaces.ExperimentStatus),
+		mu:       new(sync.Mutex),
+	}
+}
+
+// Modify calls the given function with the experiment locked, so it can
+// safely modify the experiment while other apps are being applied to it
+// concurrently. The function must not call any of the experiment's store
+// methods (Reload, WriteToStore, UpdateStatus), since they lock the experiment
+// themselves.
+func (this *Experiment) Modify(fn func()) {
+	this.lock()
+	defer this.unlock()
+
+	fn()
+}
+
+func (this *Experiment) lock() {
+	if this.mu != nil {
+		this.mu.Lock()
+	}
+}
+
+func (this *Experiment) unlock() {
+	if this.mu != nil {
+		this.mu.Unlock()
 	}
 }
 
@@ -55,42 +111,401 @@ func (this *Experiment) Reload() error {
 		return fmt.Errorf("decoding experiment %s: %w", this.Metadata.Name, err)
 	}
 
+	this.lock()
+	defer this.unlock()
+
 	this.Metadata = exp.Metadata
 	this.Spec = exp.Spec
 	this.Status = exp.Status
+	this.status = exp.status
+	this.spec = exp.spec
+	this.annotations = exp.annotations
 
 	return nil
 }
 
-func (this Experiment) WriteToStore(statusOnly bool) error {
-	name := this.Metadata.Name
+// WriteToStore persists the experiment to the store. If the experiment was
+// modified in the store since it was last read or written by this instance, the
+// status changes made by this instance are merged with the status currently in
+// the store (changes made by this instance win when both modified the same
+// status field) and this instance's status is updated to reflect the merge.
+// Unless statusOnly is true, spec and annotation changes are merged the same
+// way, except changes made by both to the same spec field or annotation result
+// in a store.ErrConflict error. Status-only writes leave the spec and
+// annotations in the store as-is. The write is retried if another writer sneaks
+// in while it's in progress.
+func (this *Experiment) WriteToStore(statusOnly bool) error {
+	this.lock()
+	defer this.unlock()
 
-	c, _ := store.NewConfig("experiment/" + name)
+	name := this.Metadata.Name
 
-	if err := store.Get(c); err != nil {
-		return fmt.Errorf("getting experiment %s from store: %w", name, err)
+	ours, err := normalizeStatus(structs.MapDefaultCase(this.Status, structs.CASESNAKE))
+	if err != nil {
+		return fmt.Errorf("normalizing experiment status: %w", err)
 	}
 
-	// limit metadata updates to annotations so name doesn't accidentally get changed
-	c.Metadata.Annotations = this.Metadata.Annotations
+	var spec map[string]any
 
 	if !statusOnly {
-		c.Spec = structs.MapDefaultCase(this.Spec, structs.CASESNAKE)
+		if spec, err = canonicalSpec(this.Spec); err != nil {
+			return fmt.Errorf("normalizing experiment spec: %w", err)
+		}
+	}
+
+	for attempt := 1; ; attempt++ {
+		c, _ := store.NewConfig("experiment/" + name)
+
+		if err := store.Get(c); err != nil {
+			return fmt.Errorf("getting experiment %s from store: %w", name, err)
+		}
+
+		var specMerged, annotationsMerged bool
+
+		// Only annotations are updated from this instance's metadata so the name
+		// doesn't accidentally get changed.
+		if !statusOnly {
+			if specMerged, err = this.mergeSpecInto(c, spec); err != nil {
+				return fmt.Errorf("saving experiment config: %w", err)
+			}
+
+			if annotationsMerged, err = this.mergeAnnotationsInto(c); err != nil {
+				return fmt.Errorf("saving experiment config: %w", err)
+			}
+		}
+
+		var (
+			merged = ours
+			theirs bool
+		)
+
+		// Someone else has written to the experiment since this instance last
+		// read or wrote it, so don't blindly clobber their status changes.
+		if this.status != nil && this.Metadata.ResourceVersion != c.Metadata.ResourceVersion {
+			merged = mergeStatus(this.status, ours, c.Status)
+			theirs = true
+		}
+
+		c.Status = merged
+
+		err := store.Update(c)
+		if err == nil {
+			if err := this.syncStatus(c, theirs); err != nil {
+				return fmt.Errorf("syncing merged experiment status: %w", err)
+			}
+
+			if !statusOnly {
+				if err := this.syncSpec(c, specMerged, annotationsMerged); err != nil {
+					return fmt.Errorf("syncing merged experiment spec: %w", err)
+				}
+			}
+
+			return nil
+		}
+
+		if !errors.Is(err, store.ErrConflict) || attempt == maxWriteAttempts {
+			return fmt.Errorf("saving experiment config: %w", err)
+		}
+
+		time.Sleep(time.Duration(attempt*10) * time.Millisecond)
+	}
+}
+
+// UpdateStatus atomically updates the experiment's status in the store using
+// the given function. The function is passed the status currently in the store
+// and may be called multiple times if the update conflicts with a concurrent
+// write. If the function returns an error the update is aborted and the error
+// is returned. On success, this instance's status is updated to match the
+// store.
+func (this *Experiment) UpdateStatus(update func(ifaces.ExperimentStatus) error) error {
+	name := this.Metadata.Name
+
+	for attempt := 1; ; attempt++ {
+		c, _ := store.NewConfig("experiment/" + name)
+
+		if err := store.Get(c); err != nil {
+			return fmt.Errorf("getting experiment %s from store: %w", name, err)
+		}
+
+		exp, err := DecodeExperimentFromConfig(*c)
+		if err != nil {
+			return fmt.Errorf("decoding experiment %s: %w", name, err)
+		}
+
+		if err := update(exp.Status); err != nil {
+			return err
+		}
+
+		c.Status = structs.MapDefaultCase(exp.Status, structs.CASESNAKE)
+
+		err = store.Update(c)
+		if err == nil {
+			this.lock()
+			defer this.unlock()
+
+			if err := this.syncStatus(c, true); err != nil {
+				return fmt.Errorf("syncing experiment status: %w", err)
+			}
+
+			return nil
+		}
+
+		if !errors.Is(err, store.ErrConflict) || attempt == maxWriteAttempts {
+			return fmt.Errorf("saving experiment config: %w", err)
+		}
+
+		time.Sleep(time.Duration(attempt*10) * time.Millisecond)
 	}
+}
 
-	c.Status = structs.MapDefaultCase(this.Status, structs.CASESNAKE)
+// syncStatus updates this instance's metadata and status to match the given
+// config just written to the store. This instance's status is only replaced if
+// decode is true (i.e., the status written includes changes made by others) so
+// references to the current status held by apps aren't invalidated needlessly.
+// Callers must hold the lock.
+func (this *Experiment) syncStatus(c *store.Config, decode bool) error {
+	status, err := normalizeStatus(c.Status)
+	if err != nil {
+		return err
+	}
+
+	this.Metadata.Updated = c.Metadata.Updated
+	this.Metadata.ResourceVersion = c.Metadata.ResourceVersion
+	this.status = status
 
-	if err := store.Update(c); err != nil {
-		return fmt.Errorf("saving experiment config: %w", err)
+	if !decode {
+		return nil
 	}
 
+	iface, err := version.GetVersionedStatusForKind(c.Kind, c.APIVersion())
+	if err != nil {
+		return fmt.Errorf("getting versioned status for config: %w", err)
+	}
+
+	if err := mapstructure.Decode(status, &iface); err != nil {
+		return fmt.Errorf("decoding versioned status: %w", err)
+	}
+
+	decoded, ok := iface.(ifaces.ExperimentStatus)
+	if !ok {
+		return fmt.Errorf("invalid status in config")
+	}
+
+	this.Status = decoded
+
 	return nil
 }
 
+// mergeSpecInto sets the spec of the given config, just read from the store, to
+// this instance's spec (normalized as spec). If the spec in the store has been
+// changed since this instance last read or wrote it, the changes are merged,
+// returning true. Callers must hold the lock.
+func (this *Experiment) mergeSpecInto(c *store.Config, spec map[string]any) (bool, error) {
+	if this.spec == nil {
+		c.Spec = spec
+		return false, nil
+	}
+
+	stored, err := decodeSpec(*c)
+	if err != nil {
+		return false, fmt.Errorf("decoding stored experiment spec: %w", err)
+	}
+
+	theirs, err := canonicalSpec(stored)
+	if err != nil {
+		return false, fmt.Errorf("normalizing stored experiment spec: %w", err)
+	}
+
+	if reflect.DeepEqual(this.spec, theirs) {
+		c.Spec = spec
+		return false, nil
+	}
+
+	merged, err := mergeSpec("", this.spec, spec, theirs)
+	if err != nil {
+		return false, fmt.Errorf("%w: %w", store.ErrConflict, err)
+	}
+
+	c.Spec = merged
+
+	return true, nil
+}
+
+// mergeAnnotationsInto sets the annotations of the given config, just read
+// from the store, to this instance's annotations. If the annotations in the
+// store have been changed since this instance last read or wrote them, the
+// changes are merged, returning true. Callers must hold the lock.
+func (this *Experiment) mergeAnnotationsInto(c *store.Config) (bool, error) {
+	theirs := c.Metadata.Annotations
+
+	if this.annotations == nil || reflect.DeepEqual(this.annotations, copyAnnotations(theirs)) {
+		c.Metadata.Annotations = this.Metadata.Annotations
+		return false, nil
+	}
+
+	merged := copyAnnotations(theirs)
+
+	keys := make(map[string]struct{})
+
+	for k := range this.annotations {
+		keys[k] = struct{}{}
+	}
+
+	for k := range this.Metadata.Annotations {
+		keys[k] = struct{}{}
+	}
+
+	for k := range keys {
+		b, inBase := this.annotations[k]
+		o, inOurs := this.Metadata.Annotations[k]
+		t, inTheirs := theirs[k]
+
+		if inBase == inOurs && b == o {
+			continue // unchanged by us, so keep theirs
+		}
+
+		if inOurs == inTheirs && o == t {
+			continue // changed the same way by both
+		}
+
+		if inBase != inTheirs || b != t {
+			return false, fmt.Errorf("%w: conflicting changes to annotation %s", store.ErrConflict, k)
+		}
+
+		if inOurs {
+			merged[k] = o
+		} else {
+			delete(merged, k)
+		}
+	}
+
+	c.Metadata.Annotations = merged
+
+	return true, nil
+}
+
+// syncSpec updates this instance's spec and annotations bases to match the
+// given config just written to the store, also replacing this instance's spec
+// and annotations if they were merged with changes made by others. Callers must
+// hold the lock.
+func (this *Experiment) syncSpec(c *store.Config, merged, annotations bool) error {
+	spec, err := decodeSpec(*c)
+	if err != nil {
+		return err
+	}
+
+	if this.spec, err = canonicalSpec(spec); err != nil {
+		return err
+	}
+
+	this.annotations = copyAnnotations(c.Metadata.Annotations)
+
+	if annotations {
+		this.Metadata.Annotations = copyAnnotations(c.Metadata.Annotations)
+	}
+
+	if merged {
+		this.Spec = spec
+	}
+
+	return nil
+}
+
+// decodeSpec decodes the versioned experiment spec in the given config.
+func decodeSpec(c store.Config) (ifaces.ExperimentSpec, error) {
+	iface, err := version.GetVersionedSpecForKind(c.Kind, c.APIVersion())
+	if err != nil {
+		return nil, fmt.Errorf("getting versioned spec for config: %w", err)
+	}
+
+	if err := mapstructure.Decode(c.Spec, &iface); err != nil {
+		return nil, fmt.Errorf("decoding versioned spec: %w", err)
+	}
+
+	spec, ok := iface.(ifaces.ExperimentSpec)
+	if !ok {
+		return nil, fmt.Errorf("invalid spec in config")
+	}
+
+	return spec, nil
+}
+
+// canonicalSpec converts the given spec to the generic form it's written to
+// the store in, so specs read from the store (which may have been written by
+// hand) can be compared field by field with specs about to be written.
+func canonicalSpec(spec ifaces.ExperimentSpec) (map[string]any, error) {
+	return normalizeStatus(structs.MapDefaultCase(spec, structs.CASESNAKE))
+}
+
 func (this *Experiment) SetSpec(spec ifaces.ExperimentSpec) {
 	this.Spec = spec
 }
 
+// MergeSpec does a three-way merge of the given spec into the experiment's
+// current spec, where base is the JSON encoding of the spec the given spec was
+// derived from. This keeps changes made to the spec by other apps being applied
+// concurrently. If a field changed in the given spec was also changed (to
+// something else) in the current spec, ErrSpecConflict is returned and the
+// experiment's spec is left as-is. Like Modify, the experiment is locked while
+// merging, so this must not be called from a function passed to Modify.
+func (this *Experiment) MergeSpec(base []byte, spec ifaces.ExperimentSpec) error {
+	this.lock()
+	defer this.unlock()
+
+	var b, o, t map[string]any
+
+	if err := json.Unmarshal(base, &b); err != nil {
+		return fmt.Errorf("decoding base experiment spec: %w", err)
+	}
+
+	if err := remarshal(spec, &o); err != nil {
+		return fmt.Errorf("normalizing updated experiment spec: %w", err)
+	}
+
+	if reflect.DeepEqual(b, o) {
+		return nil // nothing changed, so nothing to merge
+	}
+
+	if err := remarshal(this.Spec, &t); err != nil {
+		return fmt.Errorf("normalizing current experiment spec: %w", err)
+	}
+
+	if reflect.DeepEqual(b, t) {
+		this.Spec = spec
+		return nil
+	}
+
+	merged, err := mergeSpec("", b, o, t)
+	if err != nil {
+		return err
+	}
+
+	body, err := json.Marshal(merged)
+	if err != nil {
+		return fmt.Errorf("marshaling merged experiment spec: %w", err)
+	}
+
+	iface, err := version.GetVersionedSpecForKind("Experiment", version.StoredVersion["Experiment"])
+	if err != nil {
+		return fmt.Errorf("getting versioned spec for experiment: %w", err)
+	}
+
+	decoded, ok := iface.(ifaces.ExperimentSpec)
+	if !ok {
+		return fmt.Errorf("invalid versioned spec for experiment")
+	}
+
+	decoded.Init()
+
+	if err := json.Unmarshal(body, decoded); err != nil {
+		return fmt.Errorf("decoding merged experiment spec: %w", err)
+	}
+
+	this.Spec = decoded
+
+	return nil
+}
+
 func (this Experiment) Apps() []ifaces.ScenarioApp {
 	if this.Spec.Scenario() != nil {
 		return this.Spec.Scenario().Apps()
@@ -243,11 +658,188 @@ func DecodeExperimentFromConfig(c store.Config) (*Experiment, error) {
 		return nil, fmt.Errorf("invalid status in config")
 	}
 
+	base, err := normalizeStatus(c.Status)
+	if err != nil {
+		return nil, fmt.Errorf("normalizing experiment status: %w", err)
+	}
+
+	stored, err := canonicalSpec(spec)
+	if err != nil {
+		return nil, fmt.Errorf("normalizing experiment spec: %w", err)
+	}
+
 	exp := &Experiment{
-		Metadata: c.Metadata,
-		Spec:     spec,
-		Status:   status,
+		Metadata:    c.Metadata,
+		Spec:        spec,
+		Status:      status,
+		status:      base,
+		spec:        stored,
+		annotations: copyAnnotations(c.Metadata.Annotations),
+		mu:          new(sync.Mutex),
 	}
 
 	return exp, nil
 }
+
+// normalizeStatus round-trips the given status through JSON so it only
+// contains generic maps, slices, and scalars, just like a status read from the
+// store does. This makes it possible to compare statuses field by field.
+func normalizeStatus(status map[string]any) (map[string]any, error) {
+	if status == nil {
+		return nil, nil
+	}
+
+	body, err := json.Marshal(status)
+	if err != nil {
+		return nil, err
+	}
+
+	var normalized map[string]any
+
+	if err := json.Unmarshal(body, &normalized); err != nil {
+		return nil, err
+	}
+
+	return normalized, nil
+}
+
+// mergeStatus does a three-way merge of experiment statuses. Fields changed in
+// ours relative to base are taken from ours, and all other fields are taken
+// from theirs. Nested maps (like the per-app status map) are merged
+// recursively so concurrent updates to different keys are both kept.
+func mergeStatus(base, ours, theirs map[string]any) map[string]any {
+	merged := make(map[string]any)
+
+	for k, v := range theirs {
+		merged[k] = v
+	}
+
+	keys := make(map[string]struct{})
+
+	for k := range base {
+		keys[k] = struct{}{}
+	}
+
+	for k := range ours {
+		keys[k] = struct{}{}
+	}
+
+	for k := range keys {
+		b, inBase := base[k]
+		o, inOurs := ours[k]
+
+		if inBase == inOurs && reflect.DeepEqual(b, o) {
+			continue // unchanged by us, so keep theirs
+		}
+
+		if !inOurs {
+			delete(merged, k)
+			continue
+		}
+
+		bm, bok := b.(map[string]any)
+		om, ook := o.(map[string]any)
+		tm, tok := theirs[k].(map[string]any)
+
+		if bok && ook && tok {
+			merged[k] = mergeStatus(bm, om, tm)
+			continue
+		}
+
+		merged[k] = o
+	}
+
+	return merged
+}
+
+// mergeSpec does a three-way merge of experiment specs. Fields changed in ours
+// relative to base are taken from ours, and all other fields are taken from
+// theirs. Nested maps are merged recursively, but any other field (including
+// lists) changed differently in ours and theirs is a conflict.
+func mergeSpec(path string, base, ours, theirs map[string]any) (map[string]any, error) {
+	merged := make(map[string]any)
+
+	for k, v := range theirs {
+		merged[k] = v
+	}
+
+	keys := make(map[string]struct{})
+
+	for k := range base {
+		keys[k] = struct{}{}
+	}
+
+	for k := range ours {
+		keys[k] = struct{}{}
+	}
+
+	for k := range keys {
+		b, inBase := base[k]
+		o, inOurs := ours[k]
+		t, inTheirs := theirs[k]
+
+		if inBase == inOurs && reflect.DeepEqual(b, o) {
+			continue // unchanged by us, so keep theirs
+		}
+
+		if inOurs == inTheirs && reflect.DeepEqual(o, t) {
+			continue // changed the same way by both
+		}
+
+		if inBase == inTheirs && reflect.DeepEqual(b, t) {
+			// unchanged by them, so take ours
+			if inOurs {
+				merged[k] = o
+			} else {
+				delete(merged, k)
+			}
+
+			continue
+		}
+
+		bm, bok := b.(map[string]any)
+		om, ook := o.(map[string]any)
+		tm, tok := t.(map[string]any)
+
+		// Maps added by both (e.g., set from null) are merged as well.
+		if b == nil {
+			bok = true
+		}
+
+		if bok && ook && tok {
+			m, err := mergeSpec(path+"/"+k, bm, om, tm)
+			if err != nil {
+				return nil, err
+			}
+
+			merged[k] = m
+			continue
+		}
+
+		return nil, fmt.Errorf("%w: %s", ErrSpecConflict, path+"/"+k)
+	}
+
+	return merged, nil
+}
+
+// copyAnnotations returns a copy of the given annotations, which is never nil so
+// annotations that have been read can be told apart from ones that haven't.
+func copyAnnotations(annotations map[string]string) map[string]string {
+	copied := make(map[string]string, len(annotations))
+
+	for k, v := range annotations {
+		copied[k] = v
+	}
+
+	return copied
+}
+
+// remarshal converts v to its generic JSON representation, storing it in out.
+func remarshal(v, out any) error {
+	body, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(body, out)
+}
@@ -0,0 +1,298 @@
+package types
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+
+	"phenix/store"
+)
+
+func TestMergeStatus(t *testing.T) {
+	base := map[string]any{
+		"startTime":  "",
+		"appRunning": map[string]any{"foo": false, "bar": false},
+		"apps":       map[string]any{"foo": "old"},
+	}
+
+	// we started the experiment and finished running app foo...
+	ours := map[string]any{
+		"startTime":  "now",
+		"appRunning": map[string]any{"foo": false, "bar": false},
+		"apps":       map[string]any{"foo": "new"},
+	}
+
+	// ...while someone else triggered app bar and set a VLAN alias
+	theirs := map[string]any{
+		"startTime":  "",
+		"appRunning": map[string]any{"foo": false, "bar": true},
+		"apps":       map[string]any{"foo": "old"},
+		"vlans":      map[string]any{"EXP": float64(101)},
+	}
+
+	expected := map[string]any{
+		"startTime":  "now",
+		"appRunning": map[string]any{"foo": false, "bar": true},
+		"apps":       map[string]any{"foo": "new"},
+		"vlans":      map[string]any{"EXP": float64(101)},
+	}
+
+	if merged := mergeStatus(base, ours, theirs); !reflect.DeepEqual(merged, expected) {
+		t.Logf("unexpected merged status: %v", merged)
+		t.FailNow()
+	}
+}
+
+func TestNormalizeStatus(t *testing.T) {
+	status, err := normalizeStatus(map[string]any{"appRunning": map[string]bool{"foo": true}, "vlans": map[string]int{"EXP": 101}})
+	if err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+
+	expected := map[string]any{
+		"appRunning": map[string]any{"foo": true},
+		"vlans":      map[string]any{"EXP": float64(101)},
+	}
+
+	if !reflect.DeepEqual(status, expected) {
+		t.Logf("unexpected normalized status: %v", status)
+		t.FailNow()
+	}
+}
+
+func TestMergeSpec(t *testing.T) {
+	base := map[string]any{
+		"experimentName": "foo",
+		"vlans":          map[string]any{"aliases": map[string]any{"EXP": float64(101)}},
+		"schedules":      map[string]any{"vm1": "host1"},
+	}
+
+	// we added a VLAN alias...
+	ours := map[string]any{
+		"experimentName": "foo",
+		"vlans":          map[string]any{"aliases": map[string]any{"EXP": float64(101), "MGMT": float64(102)}},
+		"schedules":      map[string]any{"vm1": "host1"},
+	}
+
+	// ...while someone else changed a schedule and set the default bridge
+	theirs := map[string]any{
+		"experimentName": "foo",
+		"defaultBridge":  "phenix",
+		"vlans":          map[string]any{"aliases": map[string]any{"EXP": float64(101)}},
+		"schedules":      map[string]any{"vm1": "host2"},
+	}
+
+	expected := map[string]any{
+		"experimentName": "foo",
+		"defaultBridge":  "phenix",
+		"vlans":          map[string]any{"aliases": map[string]any{"EXP": float64(101), "MGMT": float64(102)}},
+		"schedules":      map[string]any{"vm1": "host2"},
+	}
+
+	merged, err := mergeSpec("", base, ours, theirs)
+	if err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+
+	if !reflect.DeepEqual(merged, expected) {
+		t.Logf("unexpected merged spec: %v", merged)
+		t.FailNow()
+	}
+
+	// both changed the same schedule differently
+	ours["schedules"] = map[string]any{"vm1": "host3"}
+
+	if _, err := mergeSpec("", base, ours, theirs); !errors.Is(err, ErrSpecConflict) {
+		t.Logf("expected spec conflict, got %v", err)
+		t.FailNow()
+	}
+}
+
+func TestExperimentMergeSpec(t *testing.T) {
+	exp := NewExperiment(store.ConfigMetadata{Name: "foo"})
+	exp.Spec.SetExperimentName("foo")
+	exp.Spec.SetVLANAlias("EXP", 101, false)
+
+	base, err := json.Marshal(exp.Spec)
+	if err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+
+	// a user app returns the spec with a new VLAN alias...
+	result := NewExperiment(exp.Metadata)
+
+	if err := json.Unmarshal(base, result.Spec); err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+
+	result.Spec.SetVLANAlias("MGMT", 102, false)
+
+	// ...while another app sets the default bridge
+	exp.Modify(func() { exp.Spec.SetDefaultBridge("phenix") })
+
+	if err := exp.MergeSpec(base, result.Spec); err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+
+	if exp.Spec.DefaultBridge() != "phenix" {
+		t.Logf("expected default bridge to be kept, got %s", exp.Spec.DefaultBridge())
+		t.FailNow()
+	}
+
+	expected := map[string]int{"EXP": 101, "MGMT": 102}
+
+	if aliases := exp.Spec.VLANs().Aliases(); !reflect.DeepEqual(aliases, expected) {
+		t.Logf("unexpected VLAN aliases: %v", aliases)
+		t.FailNow()
+	}
+}
+
+func TestExperimentWriteToStoreConcurrent(t *testing.T) {
+	store.DefaultStore = store.NewBoltDB()
+
+	if err := store.DefaultStore.Init(store.Endpoint("bolt://" + filepath.Join(t.TempDir(), "phenix.bdb"))); err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+
+	c := &store.Config{
+		Version:  "phenix.sandia.gov/v1",
+		Kind:     "Experiment",
+		Metadata: store.ConfigMetadata{Name: "foo", Annotations: map[string]string{"topology": "foo"}},
+		Spec:     map[string]any{"experimentName": "foo"},
+	}
+
+	if err := store.Create(c); err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+
+	load := func() *Experiment {
+		c, _ := store.NewConfig("experiment/foo")
+
+		if err := store.Get(c); err != nil {
+			t.Log(err)
+			t.FailNow()
+		}
+
+		exp, err := DecodeExperimentFromConfig(*c)
+		if err != nil {
+			t.Log(err)
+			t.FailNow()
+		}
+
+		return exp
+	}
+
+	// Each writer loads the experiment before any of them write, and changes a
+	// different VLAN alias and annotation.
+	var (
+		writers = make([]*Experiment, 5)
+		errs    = make(chan error, len(writers))
+		wg      sync.WaitGroup
+	)
+
+	for i := range writers {
+		writers[i] = load()
+	}
+
+	for i, exp := range writers {
+		wg.Add(1)
+
+		go func(i int, exp *Experiment) {
+			defer wg.Done()
+
+			exp.Spec.SetVLANAlias(fmt.Sprintf("VLAN%d", i), 101+i, false)
+			exp.Metadata.Annotations[fmt.Sprintf("writer-%d", i)] = "true"
+
+			errs <- exp.WriteToStore(false)
+		}(i, exp)
+	}
+
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		if err != nil {
+			t.Log(err)
+			t.FailNow()
+		}
+	}
+
+	exp := load()
+
+	if aliases := exp.Spec.VLANs().Aliases(); len(aliases) != len(writers) {
+		t.Logf("expected VLAN aliases from all writers, got %v", aliases)
+		t.FailNow()
+	}
+
+	for i := range writers {
+		if exp.Metadata.Annotations[fmt.Sprintf("writer-%d", i)] != "true" {
+			t.Logf("expected annotations from all writers, got %v", exp.Metadata.Annotations)
+			t.FailNow()
+		}
+	}
+
+	if exp.Metadata.Annotations["topology"] != "foo" {
+		t.Logf("expected existing annotations to be kept, got %v", exp.Metadata.Annotations)
+		t.FailNow()
+	}
+
+	// Status-only writes don't touch the spec or annotations.
+	stale := load()
+	stale.Spec.SetDefaultBridge("stale")
+	stale.Metadata.Annotations["topology"] = "stale"
+	stale.Status.SetStartTime("now")
+
+	if err := stale.WriteToStore(true); err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+
+	exp = load()
+
+	if exp.Spec.DefaultBridge() == "stale" || exp.Metadata.Annotations["topology"] != "foo" || exp.Status.StartTime() != "now" {
+		t.Logf("expected status-only write to only change status, got bridge %q, annotations %v, start time %q", exp.Spec.DefaultBridge(), exp.Metadata.Annotations, exp.Status.StartTime())
+		t.FailNow()
+	}
+
+	// Conflicting changes to the same spec field or annotation aren't merged.
+	first, second := load(), load()
+
+	first.Spec.SetDefaultBridge("first")
+	second.Spec.SetDefaultBridge("second")
+
+	if err := first.WriteToStore(false); err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+
+	if err := second.WriteToStore(false); !errors.Is(err, store.ErrConflict) {
+		t.Logf("expected conflict writing spec, got %v", err)
+		t.FailNow()
+	}
+
+	first, second = load(), load()
+
+	first.Metadata.Annotations["topology"] = "first"
+	second.Metadata.Annotations["topology"] = "second"
+
+	if err := first.WriteToStore(false); err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+
+	if err := second.WriteToStore(false); !errors.Is(err, store.ErrConflict) {
+		t.Logf("expected conflict writing annotations, got %v", err)
+		t.FailNow()
+	}
+}
@@ -48,14 +48,20 @@ type ExperimentStatus interface {
 	StartTime() string
 	AppStatus() map[string]any
 	AppFrequency() map[string]string
+	AppNextRun() map[string]string
 	AppRunning() map[string]bool
+	AppAttempts() map[string]int
+	AppLastFailure() map[string]string
 	VLANs() map[string]int
 	Schedules() map[string]string
 
 	SetStartTime(string)
 	SetAppStatus(string, any)
 	SetAppFrequency(string, string)
+	SetAppNextRun(string, string)
 	SetAppRunning(string, bool)
+	SetAppAttempts(string, int)
+	SetAppLastFailure(string, string)
 	SetVLANs(map[string]int)
 	SetSchedule(map[string]string)
 
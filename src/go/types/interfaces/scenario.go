@@ -3,6 +3,7 @@ package ifaces
 type ScenarioSpec interface {
 	Apps() []ScenarioApp
 	App(string) ScenarioApp
+	Parallelism() int
 }
 
 type ScenarioApp interface {
@@ -12,18 +13,31 @@ type ScenarioApp interface {
 	Metadata() map[string]any
 	Hosts() []ScenarioAppHost
 	RunPeriodically() string
+	RunCron() string
+	RunStartOffset() string
+	RunMaxRuns() int
 	Disabled() bool
+	After() []string
+	Policy(string) ScenarioAppPolicy
 
 	SetAssetDir(string)
 	SetMetadata(map[string]any)
 	SetHosts([]ScenarioAppHost)
 	SetRunPeriodically(string)
+	SetRunCron(string)
 	SetDisabled(bool)
+	SetAfter([]string)
 
 	ParseMetadata(any) error
 	ParseHostMetadata(string, any) error
 }
 
+type ScenarioAppPolicy interface {
+	Timeout() string
+	Retries() int
+	RetryBackoff() string
+}
+
 type ScenarioAppHost interface {
 	Hostname() string
 	Metadata() map[string]any
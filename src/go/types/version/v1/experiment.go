@@ -307,6 +307,13 @@ type ExperimentStatus struct {
 	// manually via the CLI or UI.
 	FrequencyF map[string]string `json:"appRunningStageFrequency,omitempty" yaml:"appRunningStageFrequency,omitempty" structs:"appRunningStageFrequency" mapstructure:"appRunningStageFrequency"`
 	RunningF   map[string]bool   `json:"appRunningStageStatus,omitempty" yaml:"appRunningStageStatus,omitempty" structs:"appRunningStageStatus" mapstructure:"appRunningStageStatus"`
+	NextRunF   map[string]string `json:"appRunningStageNextRun,omitempty" yaml:"appRunningStageNextRun,omitempty" structs:"appRunningStageNextRun" mapstructure:"appRunningStageNextRun"`
+
+	// Used to track the number of attempts made (including retries) the last
+	// time an app stage was executed, and the last failure encountered (e.g., a
+	// timeout) while executing it.
+	AttemptsF map[string]int    `json:"appAttempts,omitempty" yaml:"appAttempts,omitempty" structs:"appAttempts" mapstructure:"appAttempts"`
+	FailuresF map[string]string `json:"appLastFailure,omitempty" yaml:"appLastFailure,omitempty" structs:"appLastFailure" mapstructure:"appLastFailure"`
 }
 
 func (this *ExperimentStatus) Init() error {
@@ -345,6 +352,14 @@ func (this ExperimentStatus) AppFrequency() map[string]string {
 	return this.FrequencyF
 }
 
+func (this ExperimentStatus) AppNextRun() map[string]string {
+	if this.NextRunF == nil {
+		return make(map[string]string)
+	}
+
+	return this.NextRunF
+}
+
 func (this ExperimentStatus) AppRunning() map[string]bool {
 	if this.RunningF == nil {
 		return make(map[string]bool)
@@ -353,6 +368,22 @@ func (this ExperimentStatus) AppRunning() map[string]bool {
 	return this.RunningF
 }
 
+func (this ExperimentStatus) AppAttempts() map[string]int {
+	if this.AttemptsF == nil {
+		return make(map[string]int)
+	}
+
+	return this.AttemptsF
+}
+
+func (this ExperimentStatus) AppLastFailure() map[string]string {
+	if this.FailuresF == nil {
+		return make(map[string]string)
+	}
+
+	return this.FailuresF
+}
+
 func (this ExperimentStatus) VLANs() map[string]int {
 	if this.VLANsF == nil {
 		return make(map[string]int)
@@ -404,6 +435,19 @@ func (this *ExperimentStatus) SetAppFrequency(a, f string) {
 	this.FrequencyF[a] = f
 }
 
+func (this *ExperimentStatus) SetAppNextRun(a, t string) {
+	if this.NextRunF == nil {
+		this.NextRunF = make(map[string]string)
+	}
+
+	if t == "" {
+		delete(this.NextRunF, a)
+		return
+	}
+
+	this.NextRunF[a] = t
+}
+
 func (this *ExperimentStatus) SetAppRunning(a string, r bool) {
 	if this.RunningF == nil {
 		this.RunningF = make(map[string]bool)
@@ -412,6 +456,27 @@ func (this *ExperimentStatus) SetAppRunning(a string, r bool) {
 	this.RunningF[a] = r
 }
 
+func (this *ExperimentStatus) SetAppAttempts(a string, n int) {
+	if this.AttemptsF == nil {
+		this.AttemptsF = make(map[string]int)
+	}
+
+	this.AttemptsF[a] = n
+}
+
+func (this *ExperimentStatus) SetAppLastFailure(a, f string) {
+	if this.FailuresF == nil {
+		this.FailuresF = make(map[string]string)
+	}
+
+	if f == "" {
+		delete(this.FailuresF, a)
+		return
+	}
+
+	this.FailuresF[a] = f
+}
+
 func (this *ExperimentStatus) SetVLANs(v map[string]int) {
 	if this.VLANsF == nil {
 		this.VLANsF = make(map[string]int)
@@ -449,5 +514,8 @@ func (this *ExperimentStatus) ResetAppStatus() {
 	this.AppsF = make(map[string]any)
 
 	this.FrequencyF = nil
+	this.NextRunF = nil
 	this.RunningF = nil
+	this.AttemptsF = nil
+	this.FailuresF = nil
 }
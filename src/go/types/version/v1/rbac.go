@@ -3,10 +3,26 @@ package v1
 type RoleSpec struct {
 	Name     string        `yaml:"roleNname" json:"roleName" structs:"roleName" mapstructure:"roleName"`
 	Policies []*PolicySpec `yaml:"policies" json:"policies" structs:"policies" mapstructure:"policies"`
+	Quota    *QuotaSpec    `yaml:"quota,omitempty" json:"quota,omitempty" structs:"quota,omitempty" mapstructure:"quota"`
 }
 
 type PolicySpec struct {
 	Resources     []string `yaml:"resources" json:"resources" structs:"resources" mapstructure:"resources"`
 	ResourceNames []string `yaml:"resourceNames" json:"resourceNames" structs:"resourceNames" mapstructure:"resourceNames"`
 	Verbs         []string `yaml:"verbs" json:"verbs" structs:"verbs" mapstructure:"verbs"`
+
+	// Ownership, if set, limits the policy to experiments (and their VMs) the
+	// user owns (`owned`) or that have been shared with the user or their role
+	// (`shared`). Resource names are optional for such policies.
+	Ownership string `yaml:"ownership,omitempty" json:"ownership,omitempty" structs:"ownership,omitempty" mapstructure:"ownership"`
+}
+
+// QuotaSpec limits the cluster resources a user can consume across all the
+// experiments they have running. A limit of zero means unlimited.
+type QuotaSpec struct {
+	MaxRunningExperiments int `yaml:"maxRunningExperiments" json:"maxRunningExperiments" structs:"maxRunningExperiments" mapstructure:"maxRunningExperiments"`
+	MaxVCPUs              int `yaml:"maxVCPUs" json:"maxVCPUs" structs:"maxVCPUs" mapstructure:"maxVCPUs"`
+	MaxMemory             int `yaml:"maxMemory" json:"maxMemory" structs:"maxMemory" mapstructure:"maxMemory"` // in MB
+	MaxVMs                int `yaml:"maxVMs" json:"maxVMs" structs:"maxVMs" mapstructure:"maxVMs"`
+	MaxCaptures           int `yaml:"maxCaptures" json:"maxCaptures" structs:"maxCaptures" mapstructure:"maxCaptures"`
 }
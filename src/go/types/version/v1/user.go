@@ -7,5 +7,42 @@ type UserSpec struct {
 	LastName  string    `yaml:"lastName" json:"last_name" structs:"last_name" mapstructure:"last_name"`
 	Role      *RoleSpec `yaml:"rbac" json:"rbac" structs:"rbac" mapstructure:"rbac"`
 
-	Tokens map[string]string `yaml:"tokens" json:"tokens" structs:"tokens" mapstructure:"tokens"`
+	// Quota, if set, overrides the limits of the user's role quota. Only limits
+	// that are set (non-zero) override the role's limits.
+	Quota *QuotaSpec `yaml:"quota,omitempty" json:"quota,omitempty" structs:"quota,omitempty" mapstructure:"quota"`
+
+	Tokens    map[string]string `yaml:"tokens" json:"tokens" structs:"tokens" mapstructure:"tokens"`
+	APITokens []*APITokenSpec   `yaml:"apiTokens" json:"api_tokens" structs:"api_tokens" mapstructure:"api_tokens"`
+}
+
+type APITokenSpec struct {
+	ID   string `yaml:"id" json:"id" structs:"id" mapstructure:"id"`
+	Name string `yaml:"name" json:"name" structs:"name" mapstructure:"name"`
+
+	// Hash is the hex encoded SHA-256 hash of the signed token. The token itself
+	// is never stored.
+	Hash string `yaml:"hash" json:"hash" structs:"hash" mapstructure:"hash"`
+
+	// Policies the token is restricted to, in addition to the user's role. The
+	// token has the user's full role if empty.
+	Policies []*PolicySpec `yaml:"policies" json:"policies" structs:"policies" mapstructure:"policies"`
+
+	Created string `yaml:"created" json:"created" structs:"created" mapstructure:"created"`
+	Expires string `yaml:"expires" json:"expires" structs:"expires" mapstructure:"expires"`
+
+	// LastUsed and LastUsedFrom are recorded in the user config's status rather
+	// than its spec, so token usage doesn't create config revisions. They're
+	// populated from the status when the user is read from the store.
+	LastUsed     string `yaml:"-" json:"-" structs:"-" mapstructure:"-"`
+	LastUsedFrom string `yaml:"-" json:"-" structs:"-" mapstructure:"-"`
+}
+
+type UserStatus struct {
+	// APITokens tracks the usage of the user's API tokens, keyed by token ID.
+	APITokens map[string]*APITokenUsage `yaml:"apiTokens" json:"api_tokens" structs:"api_tokens" mapstructure:"api_tokens"`
+}
+
+type APITokenUsage struct {
+	LastUsed     string `yaml:"lastUsed" json:"last_used" structs:"last_used" mapstructure:"last_used"`
+	LastUsedFrom string `yaml:"lastUsedFrom" json:"last_used_from" structs:"last_used_from" mapstructure:"last_used_from"`
 }
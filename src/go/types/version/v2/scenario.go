@@ -8,7 +8,8 @@ import (
 )
 
 type ScenarioSpec struct {
-	AppsF []*ScenarioApp `json:"apps" yaml:"apps" structs:"apps" mapstructure:"apps"`
+	AppsF        []*ScenarioApp `json:"apps" yaml:"apps" structs:"apps" mapstructure:"apps"`
+	ParallelismF int            `json:"parallelism,omitempty" yaml:"parallelism,omitempty" structs:"parallelism" mapstructure:"parallelism"`
 }
 
 func (this *ScenarioSpec) Apps() []ifaces.ScenarioApp {
@@ -39,6 +40,14 @@ func (this *ScenarioSpec) App(name string) ifaces.ScenarioApp {
 	return nil
 }
 
+func (this *ScenarioSpec) Parallelism() int {
+	if this == nil || this.ParallelismF < 1 {
+		return 1
+	}
+
+	return this.ParallelismF
+}
+
 type ScenarioApp struct {
 	NameF            string             `json:"name" yaml:"name" structs:"name" mapstructure:"name"`
 	FromScenarioF    string             `json:"fromScenario,omitempty" yaml:"fromScenario,omitempty" structs:"fromScenario" mapstructure:"fromScenario"`
@@ -47,6 +56,19 @@ type ScenarioApp struct {
 	HostsF           []*ScenarioAppHost `json:"hosts,omitempty" yaml:"hosts,omitempty" structs:"hosts" mapstructure:"hosts"`
 	RunPeriodicallyF string             `json:"runPeriodically,omitempty" yaml:"runPeriodically,omitempty" structs:"runPeriodically" mapstructure:"runPeriodically"`
 	DisabledF        bool               `json:"disabled,omitempty" yaml:"disabled,omitempty" structs:"disabled" mapstructure:"disabled"`
+	AfterF           []string           `json:"after,omitempty" yaml:"after,omitempty" structs:"after" mapstructure:"after"`
+
+	// Cron-style alternative to runPeriodically, along with settings that apply
+	// to either.
+	RunCronF        string `json:"runCron,omitempty" yaml:"runCron,omitempty" structs:"runCron" mapstructure:"runCron"`
+	RunStartOffsetF string `json:"runStartOffset,omitempty" yaml:"runStartOffset,omitempty" structs:"runStartOffset" mapstructure:"runStartOffset"`
+	RunMaxRunsF     int    `json:"runMaxRuns,omitempty" yaml:"runMaxRuns,omitempty" structs:"runMaxRuns" mapstructure:"runMaxRuns"`
+
+	// Execution policy for all stages, optionally overridden per stage.
+	TimeoutF      string                        `json:"timeout,omitempty" yaml:"timeout,omitempty" structs:"timeout" mapstructure:"timeout"`
+	RetriesF      int                           `json:"retries,omitempty" yaml:"retries,omitempty" structs:"retries" mapstructure:"retries"`
+	RetryBackoffF string                        `json:"retryBackoff,omitempty" yaml:"retryBackoff,omitempty" structs:"retryBackoff" mapstructure:"retryBackoff"`
+	StagesF       map[string]*ScenarioAppPolicy `json:"stages,omitempty" yaml:"stages,omitempty" structs:"stages" mapstructure:"stages"`
 }
 
 func (this ScenarioApp) Name() string {
@@ -79,10 +101,55 @@ func (this ScenarioApp) RunPeriodically() string {
 	return this.RunPeriodicallyF
 }
 
+func (this ScenarioApp) RunCron() string {
+	return this.RunCronF
+}
+
+func (this ScenarioApp) RunStartOffset() string {
+	return this.RunStartOffsetF
+}
+
+func (this ScenarioApp) RunMaxRuns() int {
+	return this.RunMaxRunsF
+}
+
 func (this ScenarioApp) Disabled() bool {
 	return this.DisabledF
 }
 
+func (this ScenarioApp) After() []string {
+	return this.AfterF
+}
+
+// Policy returns the execution policy for the given stage. Any settings not
+// configured for the stage specifically default to the app-wide settings.
+func (this ScenarioApp) Policy(stage string) ifaces.ScenarioAppPolicy {
+	retries := this.RetriesF
+
+	policy := &ScenarioAppPolicy{
+		TimeoutF:      this.TimeoutF,
+		RetriesF:      &retries,
+		RetryBackoffF: this.RetryBackoffF,
+	}
+
+	if override, ok := this.StagesF[stage]; ok && override != nil {
+		if override.TimeoutF != "" {
+			policy.TimeoutF = override.TimeoutF
+		}
+
+		// Retries is a pointer so a stage can disable retries by setting it to 0.
+		if override.RetriesF != nil {
+			policy.RetriesF = override.RetriesF
+		}
+
+		if override.RetryBackoffF != "" {
+			policy.RetryBackoffF = override.RetryBackoffF
+		}
+	}
+
+	return policy
+}
+
 func (this *ScenarioApp) SetAssetDir(dir string) {
 	this.AssetDirF = dir
 }
@@ -105,10 +172,18 @@ func (this *ScenarioApp) SetRunPeriodically(d string) {
 	this.RunPeriodicallyF = d
 }
 
+func (this *ScenarioApp) SetRunCron(c string) {
+	this.RunCronF = c
+}
+
 func (this *ScenarioApp) SetDisabled(d bool) {
 	this.DisabledF = d
 }
 
+func (this *ScenarioApp) SetAfter(a []string) {
+	this.AfterF = a
+}
+
 func (this ScenarioApp) ParseMetadata(md any) error {
 	if this.MetadataF == nil {
 		return fmt.Errorf("missing metadata for app %s", this.NameF)
@@ -135,6 +210,28 @@ func (this ScenarioApp) ParseHostMetadata(name string, md any) error {
 	return fmt.Errorf("missing host %s for app %s", name, this.NameF)
 }
 
+type ScenarioAppPolicy struct {
+	TimeoutF      string `json:"timeout,omitempty" yaml:"timeout,omitempty" structs:"timeout" mapstructure:"timeout"`
+	RetriesF      *int   `json:"retries,omitempty" yaml:"retries,omitempty" structs:"retries" mapstructure:"retries"`
+	RetryBackoffF string `json:"retryBackoff,omitempty" yaml:"retryBackoff,omitempty" structs:"retryBackoff" mapstructure:"retryBackoff"`
+}
+
+func (this ScenarioAppPolicy) Timeout() string {
+	return this.TimeoutF
+}
+
+func (this ScenarioAppPolicy) Retries() int {
+	if this.RetriesF == nil {
+		return 0
+	}
+
+	return *this.RetriesF
+}
+
+func (this ScenarioAppPolicy) RetryBackoff() string {
+	return this.RetryBackoffF
+}
+
 type ScenarioAppHost struct {
 	HostnameF string         `json:"hostname" yaml:"hostname" structs:"hostname" mapstructure:"hostname"`
 	MetadataF map[string]any `json:"metadata" yaml:"metadata" structs:"metadata" mapstructure:"metadata"`
@@ -149,6 +149,10 @@ components:
       required:
       - apps
       properties:
+        parallelism:
+          type: integer
+          minimum: 1
+          example: 4
         apps:
           type: array
           nullable: true
@@ -160,6 +164,51 @@ components:
               name:
                 type: string
                 example: example-app
+              after:
+                type: array
+                nullable: true
+                items:
+                  type: string
+                example:
+                - other-app
+              timeout:
+                type: string
+                example: 5m
+              retries:
+                type: integer
+                minimum: 0
+                example: 2
+              retryBackoff:
+                type: string
+                example: 10s
+              stages:
+                type: object
+                nullable: true
+                additionalProperties: false
+                properties:
+                  configure:
+                    $ref: '#/components/schemas/app_stage_policy'
+                  pre-start:
+                    $ref: '#/components/schemas/app_stage_policy'
+                  post-start:
+                    $ref: '#/components/schemas/app_stage_policy'
+                  running:
+                    $ref: '#/components/schemas/app_stage_policy'
+                  cleanup:
+                    $ref: '#/components/schemas/app_stage_policy'
+              runPeriodically:
+                type: string
+                example: 5m
+              runCron:
+                type: string
+                example: 0 9 * * MON-FRI
+              runStartOffset:
+                type: string
+                example: 30m
+              runMaxRuns:
+                type: integer
+                minimum: 0
+                example: 10
               assetDir:
                 type: string
                 example: /phenix/topologies/example-topo/assets
@@ -654,6 +703,20 @@ components:
                   vlan:
                     type: string
                     example: EXP-1
+    app_stage_policy:
+      type: object
+      nullable: true
+      properties:
+        timeout:
+          type: string
+          example: 5m
+        retries:
+          type: integer
+          minimum: 0
+          example: 0
+        retryBackoff:
+          type: string
+          example: 10s
     iface:
       type: object
       required:
@@ -74,3 +74,47 @@ func TestSchema(t *testing.T) {
 		t.FailNow()
 	}
 }
+
+var scenario = `
+apps:
+- name: test
+  retries: 2
+  stages:
+    configure:
+      retries: 0
+    running:
+      timeout: 5m
+`
+
+func TestScenarioStagesSchema(t *testing.T) {
+	s, err := openapi3.NewLoader().LoadFromData(v2.OpenAPI)
+	if err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+
+	ref, ok := s.Components.Schemas["Scenario"]
+	if !ok {
+		t.Log("missing Scenario schema")
+		t.FailNow()
+	}
+
+	var spec map[string]interface{}
+	if err := yaml.Unmarshal([]byte(scenario), &spec); err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+
+	if err := ref.Value.VisitJSON(spec); err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+
+	app := spec["apps"].([]interface{})[0].(map[string]interface{})
+	app["stages"].(map[string]interface{})["prestart"] = map[string]interface{}{"retries": 1}
+
+	if err := ref.Value.VisitJSON(spec); err == nil {
+		t.Log("expected unknown stage to fail schema validation")
+		t.FailNow()
+	}
+}
@@ -0,0 +1,243 @@
+package cron
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var macros = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+type field struct {
+	name  string
+	min   int
+	max   int
+	names map[string]int
+}
+
+var (
+	minutes = field{name: "minute", min: 0, max: 59}
+	hours   = field{name: "hour", min: 0, max: 23}
+	doms    = field{name: "day of month", min: 1, max: 31}
+
+	months = field{
+		name: "month", min: 1, max: 12,
+		names: map[string]int{
+			"JAN": 1, "FEB": 2, "MAR": 3, "APR": 4, "MAY": 5, "JUN": 6,
+			"JUL": 7, "AUG": 8, "SEP": 9, "OCT": 10, "NOV": 11, "DEC": 12,
+		},
+	}
+
+	dows = field{
+		name: "day of week", min: 0, max: 7,
+		names: map[string]int{
+			"SUN": 0, "MON": 1, "TUE": 2, "WED": 3, "THU": 4, "FRI": 5, "SAT": 6,
+		},
+	}
+)
+
+// Schedule is a parsed cron expression.
+type Schedule struct {
+	expr string
+
+	minute uint64
+	hour   uint64
+	dom    uint64
+	month  uint64
+	dow    uint64
+
+	// Track whether the day fields start with `*`, since it changes how days
+	// are matched.
+	domAny bool
+	dowAny bool
+}
+
+// Parse parses the given cron expression.
+func Parse(expr string) (*Schedule, error) {
+	spec := strings.TrimSpace(expr)
+
+	if macro, ok := macros[strings.ToLower(spec)]; ok {
+		spec = macro
+	}
+
+	fields := strings.Fields(spec)
+
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("invalid cron expression %q: expected 5 fields, got %d", expr, len(fields))
+	}
+
+	var (
+		sched = &Schedule{expr: expr}
+		err   error
+	)
+
+	if sched.minute, err = minutes.parse(fields[0]); err != nil {
+		return nil, fmt.Errorf("invalid cron expression %q: %w", expr, err)
+	}
+
+	if sched.hour, err = hours.parse(fields[1]); err != nil {
+		return nil, fmt.Errorf("invalid cron expression %q: %w", expr, err)
+	}
+
+	if sched.dom, err = doms.parse(fields[2]); err != nil {
+		return nil, fmt.Errorf("invalid cron expression %q: %w", expr, err)
+	}
+
+	if sched.month, err = months.parse(fields[3]); err != nil {
+		return nil, fmt.Errorf("invalid cron expression %q: %w", expr, err)
+	}
+
+	if sched.dow, err = dows.parse(fields[4]); err != nil {
+		return nil, fmt.Errorf("invalid cron expression %q: %w", expr, err)
+	}
+
+	// Sunday can be specified as either 0 or 7.
+	if sched.dow&(1<<7) != 0 {
+		sched.dow |= 1
+	}
+
+	sched.domAny = strings.HasPrefix(fields[2], "*")
+	sched.dowAny = strings.HasPrefix(fields[4], "*")
+
+	return sched, nil
+}
+
+// String returns the cron expression the schedule was parsed from.
+func (this Schedule) String() string {
+	return this.expr
+}
+
+// Next returns the first time after the given time the schedule fires, in the
+// given time's location. The zero time is returned if the schedule never fires
+// (e.g., `0 0 30 2 *`).
+func (this Schedule) Next(t time.Time) time.Time {
+	loc := t.Location()
+
+	t = t.Truncate(time.Minute).Add(time.Minute)
+
+	// Give up if nothing matches within five years (enough to cover leap days).
+	limit := t.AddDate(5, 0, 0)
+
+	for t.Before(limit) {
+		if !has(this.month, int(t.Month())) {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, loc)
+			continue
+		}
+
+		if !this.matchDay(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, loc)
+			continue
+		}
+
+		if !has(this.hour, t.Hour()) {
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, loc)
+			continue
+		}
+
+		if !has(this.minute, t.Minute()) {
+			t = t.Add(time.Minute)
+			continue
+		}
+
+		return t
+	}
+
+	return time.Time{}
+}
+
+func (this Schedule) matchDay(t time.Time) bool {
+	var (
+		dom = has(this.dom, t.Day())
+		dow = has(this.dow, int(t.Weekday()))
+	)
+
+	if this.domAny || this.dowAny {
+		return dom && dow
+	}
+
+	return dom || dow
+}
+
+func (this field) parse(expr string) (uint64, error) {
+	var bits uint64
+
+	for _, part := range strings.Split(expr, ",") {
+		var (
+			rng  = part
+			step = 1
+		)
+
+		if idx := strings.Index(part, "/"); idx != -1 {
+			var err error
+
+			rng = part[:idx]
+
+			if step, err = strconv.Atoi(part[idx+1:]); err != nil || step < 1 {
+				return 0, fmt.Errorf("invalid step %q for %s", part[idx+1:], this.name)
+			}
+		}
+
+		var (
+			start = this.min
+			end   = this.max
+		)
+
+		if rng != "*" {
+			var err error
+
+			bounds := strings.SplitN(rng, "-", 2)
+
+			if start, err = this.value(bounds[0]); err != nil {
+				return 0, err
+			}
+
+			end = start
+
+			if len(bounds) == 2 {
+				if end, err = this.value(bounds[1]); err != nil {
+					return 0, err
+				}
+			} else if step != 1 {
+				// A single value with a step (e.g., `5/10`) means every step
+				// starting at the value.
+				end = this.max
+			}
+
+			if start > end {
+				return 0, fmt.Errorf("invalid range %q for %s", rng, this.name)
+			}
+		}
+
+		for i := start; i <= end; i += step {
+			bits |= 1 << i
+		}
+	}
+
+	return bits, nil
+}
+
+func (this field) value(v string) (int, error) {
+	if n, ok := this.names[strings.ToUpper(v)]; ok {
+		return n, nil
+	}
+
+	n, err := strconv.Atoi(v)
+	if err != nil || n < this.min || n > this.max {
+		return 0, fmt.Errorf("invalid value %q for %s (must be %d-%d)", v, this.name, this.min, this.max)
+	}
+
+	return n, nil
+}
+
+func has(bits uint64, i int) bool {
+	return bits&(1<<i) != 0
+}
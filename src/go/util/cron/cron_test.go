@@ -0,0 +1,46 @@
+package cron
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNext(t *testing.T) {
+	// Friday, October 16th, 2026
+	now := time.Date(2026, time.October, 16, 9, 30, 15, 0, time.UTC)
+
+	tests := map[string]time.Time{
+		"*/5 * * * *":         time.Date(2026, time.October, 16, 9, 35, 0, 0, time.UTC),
+		"0 9 * * MON-FRI":     time.Date(2026, time.October, 19, 9, 0, 0, 0, time.UTC),
+		"30 9 * * 1-5":        time.Date(2026, time.October, 19, 9, 30, 0, 0, time.UTC),
+		"0 0 1 * *":           time.Date(2026, time.November, 1, 0, 0, 0, 0, time.UTC),
+		"0 12 29 feb *":       time.Date(2028, time.February, 29, 12, 0, 0, 0, time.UTC),
+		"0 0 13 * 5":          time.Date(2026, time.October, 23, 0, 0, 0, 0, time.UTC),
+		"15,45 10-12/2 * * *": time.Date(2026, time.October, 16, 10, 15, 0, 0, time.UTC),
+		"0 0 * * 7":           time.Date(2026, time.October, 18, 0, 0, 0, 0, time.UTC),
+		"@hourly":             time.Date(2026, time.October, 16, 10, 0, 0, 0, time.UTC),
+		"0 0 30 2 *":          {},
+	}
+
+	for expr, expected := range tests {
+		sched, err := Parse(expr)
+		if err != nil {
+			t.Log(err)
+			t.FailNow()
+		}
+
+		if next := sched.Next(now); !next.Equal(expected) {
+			t.Logf("expected next time for %s to be %v, got %v", expr, expected, next)
+			t.FailNow()
+		}
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	for _, expr := range []string{"", "* * * *", "60 * * * *", "* * 0 * *", "* * * foo *", "*/0 * * * *", "5-1 * * * *"} {
+		if _, err := Parse(expr); err == nil {
+			t.Logf("expected error parsing %q", expr)
+			t.FailNow()
+		}
+	}
+}
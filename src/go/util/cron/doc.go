@@ -0,0 +1,13 @@
+// Package cron parses standard five-field cron expressions and computes when
+// they're next scheduled to fire.
+//
+// Expressions consist of minute (0-59), hour (0-23), day of month (1-31), month
+// (1-12 or JAN-DEC) and day of week (0-7 or SUN-SAT, where both 0 and 7 are
+// Sunday) fields. Each field can be `*`, a single value, a range (`1-5`), a
+// step (`*/15` or `0-30/10`), or a comma-separated list of any of these. The
+// @yearly (or @annually), @monthly, @weekly, @daily (or @midnight), and
+// @hourly macros are also supported.
+//
+// As with standard cron, when both the day of month and day of week fields are
+// restricted (not `*`), a day matches if either field matches.
+package cron
@@ -4,6 +4,7 @@ import (
 	"bufio"
 	"context"
 	"encoding/base64"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"os"
@@ -486,6 +487,182 @@ func (Minimega) GetVMState(opts ...Option) (string, error) {
 	return status[0]["state"], nil
 }
 
+// SnapshotVM snapshots the disk and memory of the VM to the snapshot files
+// set via the SnapshotFile option. The VM is paused once the snapshot
+// completes and must be started again by the caller.
+func (Minimega) SnapshotVM(opts ...Option) error {
+	o := NewOptions(opts...)
+
+	if o.snapshotFile == "" {
+		return fmt.Errorf("no snapshot file provided")
+	}
+
+	// Get minimega's snapshot path for VM
+
+	cmd := mmcli.NewNamespacedCommand(o.ns)
+	cmd.Command = "vm info"
+	cmd.Columns = []string{"host", "id"}
+	cmd.Filters = []string{"name=" + o.vm}
+
+	status := mmcli.RunTabular(cmd)
+
+	if len(status) == 0 {
+		return fmt.Errorf("VM %s not found", o.vm)
+	}
+
+	cmd.Columns = nil
+	cmd.Filters = nil
+
+	fp := fmt.Sprintf("%s/%s", common.MinimegaBase, status[0]["id"])
+
+	qmp := `{ "execute": "query-block" }`
+	cmd.Command = fmt.Sprintf("vm qmp %s '%s'", o.vm, qmp)
+
+	res, err := mmcli.SingleResponse(mmcli.Run(cmd))
+	if err != nil {
+		return fmt.Errorf("querying for block device details for VM %s: %w", o.vm, err)
+	}
+
+	var v map[string][]BlockDevice
+	json.Unmarshal([]byte(res), &v)
+
+	var device string
+
+	for _, dev := range v["return"] {
+		if dev.Inserted != nil {
+			if strings.HasPrefix(dev.Inserted.File, fp) {
+				device = dev.Device
+				break
+			}
+		}
+	}
+
+	target := fmt.Sprintf("%s/images/%s.qc2", common.PhenixBase, o.snapshotFile)
+
+	qmp = fmt.Sprintf(`{ "execute": "drive-backup", "arguments": { "device": "%s", "sync": "top", "target": "%s" } }`, device, target)
+	cmd.Command = fmt.Sprintf(`vm qmp %s '%s'`, o.vm, qmp)
+
+	if err := mmcli.ErrorResponse(mmcli.Run(cmd)); err != nil {
+		return fmt.Errorf("starting disk snapshot for VM %s: %w", o.vm, err)
+	}
+
+	qmp = `{ "execute": "query-block-jobs" }`
+	cmd.Command = fmt.Sprintf(`vm qmp %s '%s'`, o.vm, qmp)
+
+	for {
+		res, err := mmcli.SingleResponse(mmcli.Run(cmd))
+		if err != nil {
+			return fmt.Errorf("querying for block device jobs for VM %s: %w", o.vm, err)
+		}
+
+		var v map[string][]BlockDeviceJobs
+		json.Unmarshal([]byte(res), &v)
+
+		if len(v["return"]) == 0 {
+			break
+		}
+
+		for _, job := range v["return"] {
+			if job.Device != device {
+				continue
+			}
+
+			// Cut progress in half since drive backup is 1 of 2 steps.
+			o.SnapshotProgress(float64(job.Offset) / float64(job.Length) * 0.5)
+		}
+
+		time.Sleep(1 * time.Second)
+	}
+
+	cmd.Command = fmt.Sprintf("vm migrate %s %s.SNAP", o.vm, o.snapshotFile)
+
+	if err := mmcli.ErrorResponse(mmcli.Run(cmd)); err != nil {
+		return fmt.Errorf("starting memory snapshot for VM %s: %w", o.vm, err)
+	}
+
+	cmd.Command = "vm migrate"
+	cmd.Columns = []string{"name", "status", "complete (%)"}
+	cmd.Filters = []string{"name=" + o.vm}
+
+	// Adding a 1 second delay before calling "vm migrate" for a status update
+	// appears to prevent the status call from crashing minimega.
+	time.Sleep(1 * time.Second)
+
+	for {
+		status := mmcli.RunTabular(cmd)[0]
+
+		if status["status"] == "completed" {
+			break
+		}
+
+		// Cut progress in half and add 0.5 to it since migrate is 2 of 2 steps.
+		progress, _ := strconv.ParseFloat(status["complete (%)"], 64)
+		o.SnapshotProgress(0.5 + (progress * 0.5))
+
+		time.Sleep(1 * time.Second)
+	}
+
+	return nil
+}
+
+// RestoreVM relaunches the VM using the disk and memory snapshot files set via
+// the SnapshotFile option. The VM is left paused and must be started by the
+// caller.
+func (Minimega) RestoreVM(opts ...Option) error {
+	o := NewOptions(opts...)
+
+	if o.snapshotFile == "" {
+		return fmt.Errorf("no snapshot file provided")
+	}
+
+	cmd := mmcli.NewNamespacedCommand(o.ns)
+	cmd.Command = fmt.Sprintf("vm config clone %s", o.vm)
+
+	if err := mmcli.ErrorResponse(mmcli.Run(cmd)); err != nil {
+		return fmt.Errorf("cloning config for VM %s: %w", o.vm, err)
+	}
+
+	cmd.Command = fmt.Sprintf("vm config migrate %s.SNAP", o.snapshotFile)
+
+	if err := mmcli.ErrorResponse(mmcli.Run(cmd)); err != nil {
+		return fmt.Errorf("configuring migrate file for VM %s: %w", o.vm, err)
+	}
+
+	cmd.Command = fmt.Sprintf("vm config disk %s.qc2,writeback", o.snapshotFile)
+
+	if err := mmcli.ErrorResponse(mmcli.Run(cmd)); err != nil {
+		return fmt.Errorf("configuring disk file for VM %s: %w", o.vm, err)
+	}
+
+	cmd.Command = fmt.Sprintf("vm kill %s", o.vm)
+
+	if err := mmcli.ErrorResponse(mmcli.Run(cmd)); err != nil {
+		return fmt.Errorf("killing VM %s: %w", o.vm, err)
+	}
+
+	// TODO: explicitly flush killed VM by name once we start using that version
+	// of minimega.
+	cmd.Command = "vm flush"
+
+	if err := mmcli.ErrorResponse(mmcli.Run(cmd)); err != nil {
+		return fmt.Errorf("flushing VMs: %w", err)
+	}
+
+	cmd.Command = fmt.Sprintf("vm launch kvm %s", o.vm)
+
+	if err := mmcli.ErrorResponse(mmcli.Run(cmd)); err != nil {
+		return fmt.Errorf("relaunching VM %s: %w", o.vm, err)
+	}
+
+	cmd.Command = "vm launch"
+
+	if err := mmcli.ErrorResponse(mmcli.Run(cmd)); err != nil {
+		return fmt.Errorf("scheduling VM %s: %w", o.vm, err)
+	}
+
+	return nil
+}
+
 func (Minimega) ConnectVMInterface(opts ...Option) error {
 	o := NewOptions(opts...)
 
@@ -512,6 +689,49 @@ func (Minimega) DisconnectVMInterface(opts ...Option) error {
 	return nil
 }
 
+func (Minimega) AddVMInterfaceQoS(opts ...Option) error {
+	o := NewOptions(opts...)
+
+	var qos []string
+
+	if o.qosDelay != "" {
+		qos = append(qos, "delay "+o.qosDelay)
+	}
+
+	if o.qosLoss != "" {
+		qos = append(qos, "loss "+o.qosLoss)
+	}
+
+	if o.qosRate != "" {
+		qos = append(qos, "rate "+o.qosRate)
+	}
+
+	cmd := mmcli.NewNamespacedCommand(o.ns)
+
+	for _, q := range qos {
+		cmd.Command = fmt.Sprintf("qos add %s %d %s", o.vm, o.qosIface, q)
+
+		if err := mmcli.ErrorResponse(mmcli.Run(cmd)); err != nil {
+			return fmt.Errorf("adding QoS %s to interface %d on VM %s in namespace %s: %w", q, o.qosIface, o.vm, o.ns, err)
+		}
+	}
+
+	return nil
+}
+
+func (Minimega) ClearVMInterfaceQoS(opts ...Option) error {
+	o := NewOptions(opts...)
+
+	cmd := mmcli.NewNamespacedCommand(o.ns)
+	cmd.Command = fmt.Sprintf("clear qos %s %d", o.vm, o.qosIface)
+
+	if err := mmcli.ErrorResponse(mmcli.Run(cmd)); err != nil {
+		return fmt.Errorf("clearing QoS from interface %d on VM %s in namespace %s: %w", o.qosIface, o.vm, o.ns, err)
+	}
+
+	return nil
+}
+
 func (Minimega) CreateBridge(opts ...Option) error {
 	o := NewOptions(opts...)
 
@@ -755,8 +975,8 @@ func (this Minimega) GetClusterHosts(schedOnly bool) (Hosts, error) {
 		host.Schedulable = true
 
 		// Add disk info
-		host.DiskUsage.Phenix = this.getDiskUsage(host.Name, common.PhenixBase)
-		host.DiskUsage.Minimega = this.getDiskUsage(host.Name, common.MinimegaBase)
+		host.DiskUsage.Phenix, _ = this.getDiskUsage(host.Name, common.PhenixBase)
+		host.DiskUsage.Minimega, host.DiskUsage.MinimegaSize = this.getDiskUsage(host.Name, common.MinimegaBase)
 
 		cluster = append(cluster, host)
 	}
@@ -768,8 +988,8 @@ func (this Minimega) GetClusterHosts(schedOnly bool) (Hosts, error) {
 	head.Name = common.TrimHostnameSuffixes(head.Name)
 
 	// Add disk info
-	head.DiskUsage.Phenix = this.getDiskUsage(head.Name, common.PhenixBase)
-	head.DiskUsage.Minimega = this.getDiskUsage(head.Name, common.MinimegaBase)
+	head.DiskUsage.Phenix, _ = this.getDiskUsage(head.Name, common.PhenixBase)
+	head.DiskUsage.Minimega, head.DiskUsage.MinimegaSize = this.getDiskUsage(head.Name, common.MinimegaBase)
 
 	cluster = append(cluster, head)
 
@@ -789,8 +1009,8 @@ func (this Minimega) GetNamespaceHosts(ns string) (Hosts, error) {
 		host.Name = common.TrimHostnameSuffixes(host.Name)
 
 		// Add disk info
-		host.DiskUsage.Phenix = this.getDiskUsage(host.Name, common.PhenixBase)
-		host.DiskUsage.Minimega = this.getDiskUsage(host.Name, common.MinimegaBase)
+		host.DiskUsage.Phenix, _ = this.getDiskUsage(host.Name, common.PhenixBase)
+		host.DiskUsage.Minimega, host.DiskUsage.MinimegaSize = this.getDiskUsage(host.Name, common.MinimegaBase)
 
 		hosts = append(hosts, host)
 	}
@@ -1396,18 +1616,28 @@ func processNamespaceHosts(namespace string) (Hosts, error) {
 	return hosts, nil
 }
 
-// Run shell command to get disk usage for `path` on `host`
-func (this Minimega) getDiskUsage(host string, path string) float64 {
-	diskUsage := 0.0
+// Run shell command to get disk usage (percent used) and size (in bytes) of
+// the partition containing `path` on `host`
+func (this Minimega) getDiskUsage(host string, path string) (float64, int) {
+	var (
+		diskUsage = 0.0
+		diskSize  = 0
+	)
 
-	cmd := fmt.Sprintf(`bash -c "echo $(df %s | awk '{print $(NF-1)}' | tail -1)"`, path)
+	cmd := fmt.Sprintf(`bash -c "echo $(df -P -B1 %s | tail -1 | awk '{print $(NF-1), $2}')"`, path)
 	resp, err := this.MeshShellResponse(host, cmd)
 
 	if (resp == "") || (err != nil) {
-		return diskUsage
+		return diskUsage, diskSize
 	}
 
-	diskUsage, _ = strconv.ParseFloat(strings.TrimSuffix(resp, "%"), 64)
+	fields := strings.Fields(resp)
+
+	diskUsage, _ = strconv.ParseFloat(strings.TrimSuffix(fields[0], "%"), 64)
+
+	if len(fields) > 1 {
+		diskSize, _ = strconv.Atoi(fields[1])
+	}
 
-	return diskUsage
+	return diskUsage, diskSize
 }
@@ -18,10 +18,15 @@ type MM interface {
 	KillVM(...Option) error
 	GetVMHost(...Option) (string, error)
 	GetVMState(...Option) (string, error)
+	SnapshotVM(...Option) error
+	RestoreVM(...Option) error
 
 	ConnectVMInterface(...Option) error
 	DisconnectVMInterface(...Option) error
 
+	AddVMInterfaceQoS(...Option) error
+	ClearVMInterfaceQoS(...Option) error
+
 	CreateBridge(...Option) error
 
 	CreateTunnel(...Option) error
@@ -0,0 +1,19 @@
+/*
+In-memory fake of the minimega API.
+
+The Minimega struct in this package implements both the mm.MM and the
+file.ClusterFiles interfaces without talking to a minimega instance. It tracks
+namespaces, VMs, VM state, VLANs, captures, tunnels, bridges, taps, cluster
+hosts and C2 commands/responses in memory, and it interprets the minimega
+scripts generated by phenix (see `minimega_script.tmpl` and
+`minimega_cc_script.tmpl`) so experiments can be started and stopped in tests
+without a hypervisor.
+
+Example usage:
+
+	fake := mmfake.New(mmfake.Headnode("mm0"), mmfake.ClusterHost(mm.Host{Name: "compute0", CPUs: 16, MemTotal: 65536}))
+
+	mm.DefaultMM = fake
+	file.DefaultClusterFiles = fake
+*/
+package mmfake
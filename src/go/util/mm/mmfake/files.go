@@ -0,0 +1,192 @@
+package mmfake
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"phenix/util/file"
+)
+
+var _ file.ClusterFiles = (*Minimega)(nil)
+
+// AddFile adds a file with the given path (relative to the minimega files
+// directory) and size to the fake cluster.
+func (this *Minimega) AddFile(path string, size int) {
+	this.Lock()
+	defer this.Unlock()
+
+	this.files[path] = size
+}
+
+// Files returns the sorted paths of all the files that exist in the fake
+// cluster, including disk snapshots created by `disk snapshot` commands.
+func (this *Minimega) Files() []string {
+	this.Lock()
+	defer this.Unlock()
+
+	var paths []string
+
+	for path := range this.files {
+		paths = append(paths, path)
+	}
+
+	sort.Strings(paths)
+
+	return paths
+}
+
+func (this *Minimega) GetImages(kind file.ImageKind) ([]file.ImageDetails, error) {
+	this.Lock()
+	defer this.Unlock()
+
+	var images []file.ImageDetails
+
+	for path, size := range this.files {
+		// Only looking in the base directory, just like the minimega
+		// implementation does.
+		if strings.Contains(path, "/") {
+			continue
+		}
+
+		image := file.ImageDetails{Name: path, FullPath: "/" + path, Size: size}
+
+		if strings.HasSuffix(path, ".qc2") || strings.HasSuffix(path, ".qcow2") {
+			image.Kind = file.VM_IMAGE
+		} else if strings.HasSuffix(path, "_rootfs.tgz") {
+			image.Kind = file.CONTAINER_IMAGE
+		} else {
+			continue
+		}
+
+		images = append(images, image)
+	}
+
+	sort.Slice(images, func(i, j int) bool { return images[i].Name < images[j].Name })
+
+	return images, nil
+}
+
+func (this *Minimega) GetExperimentFiles(exp, filter string) (file.Files, error) {
+	this.Lock()
+	defer this.Unlock()
+
+	var (
+		root  = fmt.Sprintf("%s/files/", exp)
+		tree  = file.BuildTree(filter)
+		files file.Files
+	)
+
+	for path, size := range this.files {
+		if !strings.HasPrefix(path, root) {
+			continue
+		}
+
+		f := file.File{
+			Name:       filepath.Base(path),
+			Path:       strings.TrimPrefix(path, root),
+			Size:       int64(size),
+			Categories: []string{"Unknown"},
+		}
+
+		if filter != "" && (tree == nil || !tree.Evaluate(&f)) {
+			continue
+		}
+
+		files = append(files, f)
+	}
+
+	files.SortByName(true)
+
+	return files, nil
+}
+
+func (this *Minimega) GetExperimentSnapshots(exp string) ([]string, error) {
+	files, err := this.GetExperimentFiles(exp, "")
+	if err != nil {
+		return nil, fmt.Errorf("getting experiment file names: %w", err)
+	}
+
+	// Only include snapshots that have both a memory snapshot and a disk
+	// snapshot, just like the minimega implementation does.
+	var (
+		disks     = make(map[string]bool)
+		memory    = make(map[string]bool)
+		snapshots []string
+	)
+
+	for _, f := range files {
+		ext := filepath.Ext(f.Name)
+		ss := strings.TrimSuffix(f.Name, ext)
+
+		switch ext {
+		case ".qc2", ".qcow2":
+			disks[ss] = true
+		case ".SNAP", ".snap":
+			memory[ss] = true
+		}
+	}
+
+	for ss := range disks {
+		if memory[ss] {
+			snapshots = append(snapshots, ss)
+		}
+	}
+
+	sort.Strings(snapshots)
+
+	return snapshots, nil
+}
+
+func (this *Minimega) CopyFile(path, dest string, status file.CopyStatus) error {
+	this.Lock()
+	defer this.Unlock()
+
+	if err := this.record("", fmt.Sprintf("mesh send %s file get %s", dest, path)); err != nil {
+		return fmt.Errorf("copying file to destination: %w", err)
+	}
+
+	if status != nil {
+		status(1.0)
+	}
+
+	return nil
+}
+
+func (this *Minimega) SyncFile(path string, status file.CopyStatus) error {
+	this.Lock()
+	defer this.Unlock()
+
+	if err := this.record("", "mesh send all file get "+path); err != nil {
+		return fmt.Errorf("syncing file to cluster nodes: %w", err)
+	}
+
+	if status != nil {
+		status(1.0)
+	}
+
+	return nil
+}
+
+func (this *Minimega) DeleteFile(path string) error {
+	this.Lock()
+	defer this.Unlock()
+
+	if err := this.record("", "file delete "+path); err != nil {
+		return fmt.Errorf("deleting file from cluster nodes: %w", err)
+	}
+
+	// Paths ending in a slash (or matching a directory) delete everything under
+	// them, just like `file delete` does in minimega.
+	dir := strings.TrimSuffix(path, "/") + "/"
+
+	for existing := range this.files {
+		if existing == path || strings.HasPrefix(existing, dir) {
+			delete(this.files, existing)
+			delete(this.snapshots, existing)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,1565 @@
+package mmfake
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/png"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"phenix/util/mm"
+
+	"github.com/gofrs/uuid"
+)
+
+const defaultVLANMin = 101
+
+var _ mm.MM = (*Minimega)(nil)
+
+// C2Handler is called by the fake each time a C2 command is executed in a VM.
+// The returned stdout and stderr strings are stored as the response for the
+// command.
+type C2Handler func(ns, vm, command string) (stdout, stderr string)
+
+// ShellHandler is called by the fake each time a shell command is run on a
+// cluster host via `MeshShell` or `MeshShellResponse`.
+type ShellHandler func(host, command string) (string, error)
+
+type Option func(*options)
+
+type options struct {
+	headnode string
+	hosts    mm.Hosts
+
+	c2Handler    C2Handler
+	shellHandler ShellHandler
+}
+
+// Headnode sets the name of the cluster headnode. It defaults to `headnode`.
+func Headnode(h string) Option {
+	return func(o *options) {
+		o.headnode = h
+	}
+}
+
+// ClusterHost adds a compute host to the fake cluster. The headnode will only
+// be schedulable if it's also added as a cluster host.
+func ClusterHost(h mm.Host) Option {
+	return func(o *options) {
+		o.hosts = append(o.hosts, h)
+	}
+}
+
+// WithC2Handler sets the handler used to generate C2 command responses.
+func WithC2Handler(h C2Handler) Option {
+	return func(o *options) {
+		o.c2Handler = h
+	}
+}
+
+// WithShellHandler sets the handler used to generate mesh shell responses.
+func WithShellHandler(h ShellHandler) Option {
+	return func(o *options) {
+		o.shellHandler = h
+	}
+}
+
+type vm struct {
+	id       int
+	name     string
+	uuid     string
+	vmType   string
+	host     string
+	state    string
+	cpus     int
+	mem      int
+	disk     string
+	cdrom    string
+	snapshot bool
+	networks []string
+	taps     []string
+	qos      map[int]map[string]string
+	tags     map[string]string
+	config   map[string]string
+	c2       *bool
+	started  time.Time
+}
+
+type tunnel struct {
+	id      int
+	vm      string
+	srcPort int
+	dstHost string
+	dstPort int
+}
+
+type tap struct {
+	host   string
+	bridge string
+	vlan   string
+	ip     string
+	netns  string
+}
+
+type c2Command struct {
+	id      string
+	vm      string
+	command string
+	stdout  string
+	stderr  string
+}
+
+type namespace struct {
+	name     string
+	queueing bool
+	hosts    []string
+	queue    []*vm
+	vms      []*vm
+	nextID   int
+
+	vlanMin int
+	vlanMax int
+	vlans   map[string]int
+
+	bridges  []string
+	captures []mm.Capture
+	tunnels  []tunnel
+	taps     map[string]tap
+
+	config   vmConfig
+	c2Filter string
+	c2       []c2Command
+}
+
+// Minimega is an in-memory implementation of the mm.MM and file.ClusterFiles
+// interfaces.
+type Minimega struct {
+	sync.Mutex
+
+	options options
+
+	namespaces map[string]*namespace
+	active     string
+
+	files     map[string]int
+	snapshots map[string]string
+
+	tapIdx    int
+	tunnelIdx int
+	c2Idx     int
+
+	failures []failure
+	history  []string
+}
+
+type failure struct {
+	prefix string
+	err    error
+}
+
+// New returns a new fake minimega cluster configured with the given options.
+// If no cluster hosts are provided, the cluster will consist of a single,
+// schedulable headnode.
+func New(opts ...Option) *Minimega {
+	o := options{headnode: "headnode"}
+
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	if len(o.hosts) == 0 {
+		o.hosts = mm.Hosts{{Name: o.headnode, CPUs: 16, MemTotal: 65536}}
+	}
+
+	for idx, host := range o.hosts {
+		host.Schedulable = true
+		host.Headnode = host.Name == o.headnode
+
+		o.hosts[idx] = host
+	}
+
+	return &Minimega{
+		options:    o,
+		namespaces: make(map[string]*namespace),
+		files:      make(map[string]int),
+		snapshots:  make(map[string]string),
+	}
+}
+
+// FailOn causes any minimega command that starts with the given prefix to
+// fail with the given error. Commands are matched against the minimega command
+// that would have been run by the real implementation (for example, `vm start
+// foo` or `cc exec whoami`).
+func (this *Minimega) FailOn(prefix string, err error) {
+	this.Lock()
+	defer this.Unlock()
+
+	this.failures = append(this.failures, failure{prefix: prefix, err: err})
+}
+
+// ClearFailures removes all the failures previously registered via FailOn.
+func (this *Minimega) ClearFailures() {
+	this.Lock()
+	defer this.Unlock()
+
+	this.failures = nil
+}
+
+// History returns every minimega command processed by the fake, in order.
+// Namespaced commands are prefixed with `namespace <name>`, just like they
+// would be when sent to minimega by the mmcli package.
+func (this *Minimega) History() []string {
+	this.Lock()
+	defer this.Unlock()
+
+	history := make([]string, len(this.history))
+	copy(history, this.history)
+
+	return history
+}
+
+// Namespaces returns the sorted names of all the namespaces that currently
+// exist.
+func (this *Minimega) Namespaces() []string {
+	this.Lock()
+	defer this.Unlock()
+
+	var names []string
+
+	for name := range this.namespaces {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	return names
+}
+
+// Bridges returns the bridges created for the given namespace.
+func (this *Minimega) Bridges(ns string) []string {
+	this.Lock()
+	defer this.Unlock()
+
+	n, ok := this.namespaces[ns]
+	if !ok {
+		return nil
+	}
+
+	bridges := make([]string, len(n.bridges))
+	copy(bridges, n.bridges)
+
+	return bridges
+}
+
+// Taps returns the names of the taps created for the given namespace.
+func (this *Minimega) Taps(ns string) []string {
+	this.Lock()
+	defer this.Unlock()
+
+	n, ok := this.namespaces[ns]
+	if !ok {
+		return nil
+	}
+
+	var taps []string
+
+	for name := range n.taps {
+		taps = append(taps, name)
+	}
+
+	sort.Strings(taps)
+
+	return taps
+}
+
+// C2Commands returns the C2 commands executed in the given namespace, in the
+// form they would have been sent to minimega (for example, `exec whoami`).
+func (this *Minimega) C2Commands(ns string) []string {
+	this.Lock()
+	defer this.Unlock()
+
+	n, ok := this.namespaces[ns]
+	if !ok {
+		return nil
+	}
+
+	var commands []string
+
+	for _, cmd := range n.c2 {
+		commands = append(commands, cmd.command)
+	}
+
+	return commands
+}
+
+// QoS returns the QoS settings (for example, `delay 100ms`) added to the given
+// interface of the given VM, sorted by type.
+func (this *Minimega) QoS(ns, name string, iface int) []string {
+	this.Lock()
+	defer this.Unlock()
+
+	v := this.findVM(ns, name)
+	if v == nil {
+		return nil
+	}
+
+	var qos []string
+
+	for typ, value := range v.qos[iface] {
+		qos = append(qos, typ+" "+value)
+	}
+
+	sort.Strings(qos)
+
+	return qos
+}
+
+// SetC2Active overrides whether or not the C2 client for the given VM is
+// considered active. By default, C2 clients are active while a VM is running.
+func (this *Minimega) SetC2Active(ns, name string, active bool) error {
+	this.Lock()
+	defer this.Unlock()
+
+	v := this.findVM(ns, name)
+	if v == nil {
+		return mm.ErrVMNotFound
+	}
+
+	v.c2 = &active
+
+	return nil
+}
+
+func (this *Minimega) ReadScriptFromFile(filename string) error {
+	this.Lock()
+	defer this.Unlock()
+
+	return this.readScript(filename)
+}
+
+func (this *Minimega) ClearNamespace(ns string) error {
+	this.Lock()
+	defer this.Unlock()
+
+	if err := this.record("", "clear namespace "+ns); err != nil {
+		return fmt.Errorf("clearing minimega namespace: %w", err)
+	}
+
+	delete(this.namespaces, ns)
+
+	if this.active == ns {
+		this.active = ""
+	}
+
+	return nil
+}
+
+func (this *Minimega) LaunchVMs(ns string, start ...string) error {
+	this.Lock()
+	defer this.Unlock()
+
+	if err := this.exec(ns, "vm launch"); err != nil {
+		return fmt.Errorf("launching VMs: %w", err)
+	}
+
+	if start == nil {
+		if err := this.exec(ns, "vm start all"); err != nil {
+			return fmt.Errorf("starting VMs: %w", err)
+		}
+	} else {
+		for _, name := range start {
+			if err := this.exec(ns, "vm start "+name); err != nil {
+				return fmt.Errorf("starting VM %s: %w", name, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+func (this *Minimega) GetLaunchProgress(ns string, expected int) (float64, error) {
+	this.Lock()
+	defer this.Unlock()
+
+	n, ok := this.namespaces[ns]
+	if !ok || expected == 0 {
+		return 0.0, nil
+	}
+
+	return float64(len(n.queue)) / float64(expected), nil
+}
+
+func (this *Minimega) GetVMInfo(opts ...mm.Option) mm.VMs {
+	this.Lock()
+	defer this.Unlock()
+
+	o := mm.NewOptions(opts...)
+
+	var vms mm.VMs
+
+	for _, n := range this.namespacesFor(o.Namespace()) {
+		for _, v := range n.vms {
+			if o.VM() != "" && v.name != o.VM() {
+				continue
+			}
+
+			vms = append(vms, this.vmInfo(n, v))
+		}
+	}
+
+	return vms
+}
+
+func (this *Minimega) GetVMScreenshot(opts ...mm.Option) ([]byte, error) {
+	this.Lock()
+	defer this.Unlock()
+
+	o := mm.NewOptions(opts...)
+
+	v := this.findVM(o.Namespace(), o.VM())
+	if v == nil || v.state != "RUNNING" {
+		return nil, mm.ErrVMNotFound
+	}
+
+	var buf bytes.Buffer
+
+	if err := png.Encode(&buf, image.NewGray(image.Rect(0, 0, 1, 1))); err != nil {
+		return nil, fmt.Errorf("encoding screenshot: %w", err)
+	}
+
+	return buf.Bytes(), nil
+}
+
+func (this *Minimega) GetVNCEndpoint(opts ...mm.Option) (string, error) {
+	this.Lock()
+	defer this.Unlock()
+
+	o := mm.NewOptions(opts...)
+
+	v := this.findVM(o.Namespace(), o.VM())
+	if v == nil || v.vmType != "kvm" {
+		return "", fmt.Errorf("not found")
+	}
+
+	return fmt.Sprintf("%s:%d", v.host, 5900+v.id), nil
+}
+
+func (this *Minimega) StartVM(opts ...mm.Option) error {
+	this.Lock()
+	defer this.Unlock()
+
+	o := mm.NewOptions(opts...)
+
+	if err := this.exec(o.Namespace(), "vm start "+o.VM()); err != nil {
+		return fmt.Errorf("starting VM %s in namespace %s: %w", o.VM(), o.Namespace(), err)
+	}
+
+	return nil
+}
+
+func (this *Minimega) StopVM(opts ...mm.Option) error {
+	this.Lock()
+	defer this.Unlock()
+
+	o := mm.NewOptions(opts...)
+
+	if err := this.exec(o.Namespace(), "vm stop "+o.VM()); err != nil {
+		return fmt.Errorf("stopping VM %s in namespace %s: %w", o.VM(), o.Namespace(), err)
+	}
+
+	return nil
+}
+
+func (this *Minimega) RedeployVM(opts ...mm.Option) error {
+	this.Lock()
+	defer this.Unlock()
+
+	o := mm.NewOptions(opts...)
+
+	v := this.findVM(o.Namespace(), o.VM())
+	if v == nil {
+		return fmt.Errorf("no info found for VM %s in namespace %s", o.VM(), o.Namespace())
+	}
+
+	if err := this.record(o.Namespace(), "vm kill "+o.VM()); err != nil {
+		return fmt.Errorf("killing VM %s in namespace %s: %w", o.VM(), o.Namespace(), err)
+	}
+
+	if o.CPU() != 0 {
+		v.cpus = o.CPU()
+	}
+
+	if o.Mem() != 0 {
+		v.mem = o.Mem()
+	}
+
+	if o.Disk() != "" {
+		disk := strings.Split(o.Disk(), ",")[0]
+
+		if len(o.Injects()) > 0 && v.snapshot {
+			// Mimic minimega by re-snapshotting the new disk to the VM's original
+			// snapshot file before injecting files into it.
+			this.snapshots[v.disk] = disk
+		} else {
+			v.disk = disk
+		}
+	}
+
+	if err := this.record(o.Namespace(), "vm launch kvm "+o.VM()); err != nil {
+		return fmt.Errorf("scheduling VM %s in namespace %s: %w", o.VM(), o.Namespace(), err)
+	}
+
+	v.uuid = newUUID()
+	v.state = "PAUSED"
+
+	if err := this.exec(o.Namespace(), "vm start "+o.VM()); err != nil {
+		return fmt.Errorf("starting VM %s in namespace %s: %w", o.VM(), o.Namespace(), err)
+	}
+
+	return nil
+}
+
+func (this *Minimega) KillVM(opts ...mm.Option) error {
+	this.Lock()
+	defer this.Unlock()
+
+	o := mm.NewOptions(opts...)
+
+	if err := this.exec(o.Namespace(), "vm kill "+o.VM()); err != nil {
+		return fmt.Errorf("killing VM %s in namespace %s: %w", o.VM(), o.Namespace(), err)
+	}
+
+	if err := this.exec(o.Namespace(), "vm flush"); err != nil {
+		return fmt.Errorf("flushing VMs in namespace %s: %w", o.Namespace(), err)
+	}
+
+	return nil
+}
+
+func (this *Minimega) GetVMHost(opts ...mm.Option) (string, error) {
+	this.Lock()
+	defer this.Unlock()
+
+	o := mm.NewOptions(opts...)
+
+	v := this.findVM(o.Namespace(), o.VM())
+	if v == nil {
+		return "", fmt.Errorf("VM %s not found", o.VM())
+	}
+
+	return v.host, nil
+}
+
+func (this *Minimega) GetVMState(opts ...mm.Option) (string, error) {
+	this.Lock()
+	defer this.Unlock()
+
+	o := mm.NewOptions(opts...)
+
+	v := this.findVM(o.Namespace(), o.VM())
+	if v == nil {
+		return "", fmt.Errorf("VM %s not found", o.VM())
+	}
+
+	return v.state, nil
+}
+
+func (this *Minimega) SnapshotVM(opts ...mm.Option) error {
+	this.Lock()
+	defer this.Unlock()
+
+	o := mm.NewOptions(opts...)
+
+	if o.SnapshotFile() == "" {
+		return fmt.Errorf("no snapshot file provided")
+	}
+
+	v := this.findVM(o.Namespace(), o.VM())
+	if v == nil {
+		return fmt.Errorf("VM %s not found", o.VM())
+	}
+
+	cmd := fmt.Sprintf(`vm qmp %s '{ "execute": "drive-backup" }'`, o.VM())
+
+	if err := this.record(o.Namespace(), cmd); err != nil {
+		return fmt.Errorf("starting disk snapshot for VM %s: %w", o.VM(), err)
+	}
+
+	this.files[o.SnapshotFile()+".qc2"] = this.files[v.disk]
+	o.SnapshotProgress(0.5)
+
+	if err := this.record(o.Namespace(), fmt.Sprintf("vm migrate %s %s.SNAP", o.VM(), o.SnapshotFile())); err != nil {
+		return fmt.Errorf("starting memory snapshot for VM %s: %w", o.VM(), err)
+	}
+
+	this.files[o.SnapshotFile()+".SNAP"] = v.mem
+
+	// Migrating a VM leaves it paused, just like minimega does.
+	if v.state == "RUNNING" {
+		v.state = "PAUSED"
+	}
+
+	return nil
+}
+
+func (this *Minimega) RestoreVM(opts ...mm.Option) error {
+	this.Lock()
+	defer this.Unlock()
+
+	o := mm.NewOptions(opts...)
+
+	if o.SnapshotFile() == "" {
+		return fmt.Errorf("no snapshot file provided")
+	}
+
+	v := this.findVM(o.Namespace(), o.VM())
+	if v == nil {
+		return fmt.Errorf("VM %s not found", o.VM())
+	}
+
+	for _, ext := range []string{".qc2", ".SNAP"} {
+		if _, ok := this.files[o.SnapshotFile()+ext]; !ok {
+			return fmt.Errorf("snapshot file %s%s not found", o.SnapshotFile(), ext)
+		}
+	}
+
+	if err := this.record(o.Namespace(), "vm config migrate "+o.SnapshotFile()+".SNAP"); err != nil {
+		return fmt.Errorf("configuring migrate file for VM %s: %w", o.VM(), err)
+	}
+
+	if err := this.record(o.Namespace(), "vm kill "+o.VM()); err != nil {
+		return fmt.Errorf("killing VM %s: %w", o.VM(), err)
+	}
+
+	if err := this.record(o.Namespace(), "vm launch kvm "+o.VM()); err != nil {
+		return fmt.Errorf("relaunching VM %s: %w", o.VM(), err)
+	}
+
+	v.disk = o.SnapshotFile() + ".qc2"
+	v.uuid = newUUID()
+	v.state = "PAUSED"
+
+	return nil
+}
+
+func (this *Minimega) ConnectVMInterface(opts ...mm.Option) error {
+	this.Lock()
+	defer this.Unlock()
+
+	o := mm.NewOptions(opts...)
+
+	cmd := fmt.Sprintf("vm net connect %s %d %s", o.VM(), o.ConnectInterface(), o.ConnectVLAN())
+
+	if err := this.record(o.Namespace(), cmd); err != nil {
+		return fmt.Errorf("connecting interface %d on VM %s to VLAN %s in namespace %s: %w", o.ConnectInterface(), o.VM(), o.ConnectVLAN(), o.Namespace(), err)
+	}
+
+	n := this.namespaces[o.Namespace()]
+	v := this.findVM(o.Namespace(), o.VM())
+
+	if v == nil {
+		return fmt.Errorf("connecting interface %d on VM %s to VLAN %s in namespace %s: %w", o.ConnectInterface(), o.VM(), o.ConnectVLAN(), o.Namespace(), mm.ErrVMNotFound)
+	}
+
+	if o.ConnectInterface() < 0 || o.ConnectInterface() >= len(v.networks) {
+		return fmt.Errorf("no such interface %d for VM %s", o.ConnectInterface(), o.VM())
+	}
+
+	if _, err := this.allocateVLAN(n, o.ConnectVLAN()); err != nil {
+		return fmt.Errorf("connecting interface %d on VM %s to VLAN %s in namespace %s: %w", o.ConnectInterface(), o.VM(), o.ConnectVLAN(), o.Namespace(), err)
+	}
+
+	v.networks[o.ConnectInterface()] = o.ConnectVLAN()
+
+	return nil
+}
+
+func (this *Minimega) DisconnectVMInterface(opts ...mm.Option) error {
+	this.Lock()
+	defer this.Unlock()
+
+	o := mm.NewOptions(opts...)
+
+	cmd := fmt.Sprintf("vm net disconnect %s %d", o.VM(), o.ConnectInterface())
+
+	if err := this.record(o.Namespace(), cmd); err != nil {
+		return fmt.Errorf("disconnecting interface %d on VM %s in namespace %s: %w", o.ConnectInterface(), o.VM(), o.Namespace(), err)
+	}
+
+	v := this.findVM(o.Namespace(), o.VM())
+	if v == nil {
+		return fmt.Errorf("disconnecting interface %d on VM %s in namespace %s: %w", o.ConnectInterface(), o.VM(), o.Namespace(), mm.ErrVMNotFound)
+	}
+
+	if o.ConnectInterface() < 0 || o.ConnectInterface() >= len(v.networks) {
+		return fmt.Errorf("no such interface %d for VM %s", o.ConnectInterface(), o.VM())
+	}
+
+	v.networks[o.ConnectInterface()] = ""
+
+	return nil
+}
+
+func (this *Minimega) AddVMInterfaceQoS(opts ...mm.Option) error {
+	this.Lock()
+	defer this.Unlock()
+
+	o := mm.NewOptions(opts...)
+
+	v := this.findVM(o.Namespace(), o.VM())
+
+	for _, q := range [][2]string{{"delay", o.QoSDelay()}, {"loss", o.QoSLoss()}, {"rate", o.QoSRate()}} {
+		if q[1] == "" {
+			continue
+		}
+
+		cmd := fmt.Sprintf("qos add %s %d %s %s", o.VM(), o.QoSInterface(), q[0], q[1])
+
+		if err := this.record(o.Namespace(), cmd); err != nil {
+			return fmt.Errorf("adding QoS %s %s to interface %d on VM %s in namespace %s: %w", q[0], q[1], o.QoSInterface(), o.VM(), o.Namespace(), err)
+		}
+
+		if v == nil {
+			return fmt.Errorf("adding QoS %s %s to interface %d on VM %s in namespace %s: %w", q[0], q[1], o.QoSInterface(), o.VM(), o.Namespace(), mm.ErrVMNotFound)
+		}
+
+		if o.QoSInterface() < 0 || o.QoSInterface() >= len(v.networks) {
+			return fmt.Errorf("no such interface %d for VM %s", o.QoSInterface(), o.VM())
+		}
+
+		if v.qos == nil {
+			v.qos = make(map[int]map[string]string)
+		}
+
+		if v.qos[o.QoSInterface()] == nil {
+			v.qos[o.QoSInterface()] = make(map[string]string)
+		}
+
+		// Like minimega, adding a QoS setting replaces any existing setting of the
+		// same type.
+		v.qos[o.QoSInterface()][q[0]] = q[1]
+	}
+
+	return nil
+}
+
+func (this *Minimega) ClearVMInterfaceQoS(opts ...mm.Option) error {
+	this.Lock()
+	defer this.Unlock()
+
+	o := mm.NewOptions(opts...)
+
+	cmd := fmt.Sprintf("clear qos %s %d", o.VM(), o.QoSInterface())
+
+	if err := this.record(o.Namespace(), cmd); err != nil {
+		return fmt.Errorf("clearing QoS from interface %d on VM %s in namespace %s: %w", o.QoSInterface(), o.VM(), o.Namespace(), err)
+	}
+
+	v := this.findVM(o.Namespace(), o.VM())
+	if v == nil {
+		return fmt.Errorf("clearing QoS from interface %d on VM %s in namespace %s: %w", o.QoSInterface(), o.VM(), o.Namespace(), mm.ErrVMNotFound)
+	}
+
+	if o.QoSInterface() < 0 || o.QoSInterface() >= len(v.networks) {
+		return fmt.Errorf("no such interface %d for VM %s", o.QoSInterface(), o.VM())
+	}
+
+	delete(v.qos, o.QoSInterface())
+
+	return nil
+}
+
+func (this *Minimega) CreateBridge(opts ...mm.Option) error {
+	this.Lock()
+	defer this.Unlock()
+
+	o := mm.NewOptions(opts...)
+
+	if err := this.exec(o.Namespace(), fmt.Sprintf("ns bridge %s gre", o.Bridge())); err != nil {
+		return fmt.Errorf("creating bridge %s with GRE mesh between namespace hosts: %w", o.Bridge(), err)
+	}
+
+	return nil
+}
+
+func (this *Minimega) CreateTunnel(opts ...mm.Option) error {
+	this.Lock()
+	defer this.Unlock()
+
+	o := mm.NewOptions(opts...)
+
+	if this.findVM(o.Namespace(), o.VM()) == nil {
+		return fmt.Errorf("unable to determine what host the VM is scheduled on: VM %s not found", o.VM())
+	}
+
+	cmd := fmt.Sprintf("cc tunnel %s %d %s %d", o.VM(), o.TunnelSourcePort(), o.TunnelDestHost(), o.TunnelDestPort())
+
+	if err := this.record(o.Namespace(), cmd); err != nil {
+		return fmt.Errorf("creating tunnel to %s (%d:%s:%d): %w", o.VM(), o.TunnelSourcePort(), o.TunnelDestHost(), o.TunnelDestPort(), err)
+	}
+
+	this.tunnelIdx++
+
+	n := this.namespaces[o.Namespace()]
+	n.tunnels = append(n.tunnels, tunnel{
+		id:      this.tunnelIdx,
+		vm:      o.VM(),
+		srcPort: o.TunnelSourcePort(),
+		dstHost: o.TunnelDestHost(),
+		dstPort: o.TunnelDestPort(),
+	})
+
+	return nil
+}
+
+func (this *Minimega) GetTunnels(opts ...mm.Option) []map[string]string {
+	this.Lock()
+	defer this.Unlock()
+
+	o := mm.NewOptions(opts...)
+
+	var rows []map[string]string
+
+	for _, n := range this.namespacesFor(o.Namespace()) {
+		for _, t := range this.matchTunnels(n, o) {
+			rows = append(rows, map[string]string{
+				"id":       strconv.Itoa(t.id),
+				"vm":       t.vm,
+				"src port": strconv.Itoa(t.srcPort),
+				"dst":      t.dstHost,
+				"dst port": strconv.Itoa(t.dstPort),
+			})
+		}
+	}
+
+	return rows
+}
+
+func (this *Minimega) CloseTunnel(opts ...mm.Option) error {
+	this.Lock()
+	defer this.Unlock()
+
+	o := mm.NewOptions(opts...)
+
+	if this.findVM(o.Namespace(), o.VM()) == nil {
+		return fmt.Errorf("unable to determine what host the VM is scheduled on: VM %s not found", o.VM())
+	}
+
+	n := this.namespaces[o.Namespace()]
+
+	var (
+		closing = this.matchTunnels(n, o)
+		keep    []tunnel
+	)
+
+	for _, t := range closing {
+		if err := this.record(o.Namespace(), fmt.Sprintf("cc tunnel close %s %d", o.VM(), t.id)); err != nil {
+			return fmt.Errorf("closing tunnel to %s (%s:%d): %w", o.VM(), o.TunnelDestHost(), o.TunnelDestPort(), err)
+		}
+	}
+
+	for _, t := range n.tunnels {
+		var closed bool
+
+		for _, c := range closing {
+			if c.id == t.id {
+				closed = true
+				break
+			}
+		}
+
+		if !closed {
+			keep = append(keep, t)
+		}
+	}
+
+	n.tunnels = keep
+
+	return nil
+}
+
+func (this *Minimega) StartVMCapture(opts ...mm.Option) error {
+	this.Lock()
+	defer this.Unlock()
+
+	o := mm.NewOptions(opts...)
+
+	if n, ok := this.namespaces[o.Namespace()]; ok {
+		for _, capture := range n.captures {
+			if capture.VM == o.VM() && capture.Interface == o.CaptureInterface() {
+				return mm.ErrCaptureExists
+			}
+		}
+	}
+
+	if filepath.IsAbs(o.CaptureFile()) {
+		return fmt.Errorf("path for capture file should not be absolute")
+	}
+
+	if this.findVM(o.Namespace(), o.VM()) == nil {
+		return fmt.Errorf("unable to determine what host the VM is scheduled on: VM %s not found", o.VM())
+	}
+
+	cmd := fmt.Sprintf("capture pcap vm %s %d %s", o.VM(), o.CaptureInterface(), o.CaptureFile())
+
+	if err := this.record(o.Namespace(), cmd); err != nil {
+		return fmt.Errorf("starting VM capture for interface %d on VM %s in namespace %s: %w", o.CaptureInterface(), o.VM(), o.Namespace(), err)
+	}
+
+	n := this.namespaces[o.Namespace()]
+	n.captures = append(n.captures, mm.Capture{VM: o.VM(), Interface: o.CaptureInterface(), Filepath: o.CaptureFile()})
+
+	return nil
+}
+
+func (this *Minimega) StopVMCapture(opts ...mm.Option) error {
+	this.Lock()
+	defer this.Unlock()
+
+	o := mm.NewOptions(opts...)
+
+	n, ok := this.namespaces[o.Namespace()]
+	if !ok {
+		return mm.ErrNoCaptures
+	}
+
+	var keep []mm.Capture
+
+	for _, capture := range n.captures {
+		if capture.VM != o.VM() {
+			keep = append(keep, capture)
+		}
+	}
+
+	if len(keep) == len(n.captures) {
+		return mm.ErrNoCaptures
+	}
+
+	if err := this.record(o.Namespace(), "capture pcap delete vm "+o.VM()); err != nil {
+		return fmt.Errorf("deleting VM captures for VM %s in namespace %s: %w", o.VM(), o.Namespace(), err)
+	}
+
+	n.captures = keep
+
+	return nil
+}
+
+func (this *Minimega) GetExperimentCaptures(opts ...mm.Option) []mm.Capture {
+	this.Lock()
+	defer this.Unlock()
+
+	o := mm.NewOptions(opts...)
+
+	var captures []mm.Capture
+
+	for _, n := range this.namespacesFor(o.Namespace()) {
+		captures = append(captures, n.captures...)
+	}
+
+	return captures
+}
+
+func (this *Minimega) GetVMCaptures(opts ...mm.Option) []mm.Capture {
+	this.Lock()
+	defer this.Unlock()
+
+	o := mm.NewOptions(opts...)
+
+	n, ok := this.namespaces[o.Namespace()]
+	if !ok {
+		return nil
+	}
+
+	return vmCaptures(n, o.VM())
+}
+
+func (this *Minimega) GetClusterHosts(schedOnly bool) (mm.Hosts, error) {
+	this.Lock()
+	defer this.Unlock()
+
+	var hosts mm.Hosts
+
+	for _, host := range this.clusterHosts() {
+		if schedOnly && !host.Schedulable {
+			continue
+		}
+
+		hosts = append(hosts, host)
+	}
+
+	if len(hosts) == 0 && !schedOnly {
+		return mm.Hosts{}, fmt.Errorf("no cluster hosts found")
+	}
+
+	return hosts, nil
+}
+
+func (this *Minimega) GetNamespaceHosts(ns string) (mm.Hosts, error) {
+	this.Lock()
+	defer this.Unlock()
+
+	n, ok := this.namespaces[ns]
+	if !ok {
+		return nil, nil
+	}
+
+	var hosts mm.Hosts
+
+	for _, host := range this.clusterHosts() {
+		for _, name := range this.hostsFor(n) {
+			if host.Name == name {
+				hosts = append(hosts, host)
+				break
+			}
+		}
+	}
+
+	return hosts, nil
+}
+
+func (this *Minimega) Headnode() string {
+	return this.options.headnode
+}
+
+func (this *Minimega) IsHeadnode(node string) bool {
+	return node == this.options.headnode
+}
+
+func (this *Minimega) GetVLANs(opts ...mm.Option) (map[string]int, error) {
+	this.Lock()
+	defer this.Unlock()
+
+	o := mm.NewOptions(opts...)
+
+	vlans := make(map[string]int)
+
+	if n, ok := this.namespaces[o.Namespace()]; ok {
+		for alias, id := range n.vlans {
+			vlans[alias] = id
+		}
+	}
+
+	return vlans, nil
+}
+
+func (this *Minimega) IsC2ClientActive(opts ...mm.C2Option) error {
+	this.Lock()
+	defer this.Unlock()
+
+	return this.c2Active(mm.NewC2Options(opts...))
+}
+
+func (this *Minimega) ExecC2Command(opts ...mm.C2Option) (string, error) {
+	this.Lock()
+	defer this.Unlock()
+
+	o := mm.NewC2Options(opts...)
+
+	if err := this.c2Active(o); err != nil {
+		return "", fmt.Errorf("cannot execute command: %w", err)
+	}
+
+	if o.TestConn() != "" {
+		return this.execC2(o.Namespace(), o.VM(), "test-conn "+o.TestConn())
+	}
+
+	if o.SendFile() != "" {
+		id, err := this.execC2(o.Namespace(), o.VM(), "send "+o.SendFile())
+		if err != nil {
+			return "", fmt.Errorf("sending file '%s' to vm %s: %w", o.SendFile(), o.VM(), err)
+		}
+
+		if o.Command() == "" {
+			return id, nil
+		}
+	}
+
+	if o.Command() != "" {
+		return this.execC2(o.Namespace(), o.VM(), "exec "+o.Command())
+	}
+
+	if o.Mount() != nil {
+		if *o.Mount() {
+			return this.execC2(o.Namespace(), o.VM(), fmt.Sprintf("mount %s %s", o.VM(), mm.GetLocalMountPath(o.Namespace(), o.VM())))
+		}
+
+		return this.execC2(o.Namespace(), o.VM(), "clear mount "+o.VM())
+	}
+
+	return "", fmt.Errorf("no options to execute were provided")
+}
+
+func (this *Minimega) GetC2Response(opts ...mm.C2Option) (string, error) {
+	this.Lock()
+	defer this.Unlock()
+
+	o := mm.NewC2Options(opts...)
+
+	cmd, err := this.findC2(o.Namespace(), o.CommandID())
+	if err != nil {
+		return "", err
+	}
+
+	switch o.ResponseType() {
+	case mm.C2ResponseStdout:
+		return cmd.stdout, nil
+	case mm.C2ResponseStderr:
+		return cmd.stderr, nil
+	}
+
+	return cmd.stdout + cmd.stderr, nil
+}
+
+func (this *Minimega) WaitForC2Response(opts ...mm.C2Option) (string, error) {
+	this.Lock()
+	defer this.Unlock()
+
+	o := mm.NewC2Options(opts...)
+
+	if err := o.Context().Err(); err != nil {
+		return "", err
+	}
+
+	// Responses are generated synchronously when commands are executed, so
+	// there's never anything to wait for.
+	cmd, err := this.findC2(o.Namespace(), o.CommandID())
+	if err != nil {
+		return "", err
+	}
+
+	return cmd.stdout + cmd.stderr, nil
+}
+
+func (this *Minimega) ClearC2Responses(opts ...mm.C2Option) error {
+	this.Lock()
+	defer this.Unlock()
+
+	o := mm.NewC2Options(opts...)
+
+	if err := this.exec(o.Namespace(), "clear cc responses"); err != nil {
+		return fmt.Errorf("clearing C2 responses for namespace %s: %w", o.Namespace(), err)
+	}
+
+	return nil
+}
+
+func (this *Minimega) TapVLAN(opts ...mm.TapOption) error {
+	o := mm.NewTapOptions(opts...)
+
+	this.Lock()
+	defer this.Unlock()
+
+	if o.Untap() {
+		if err := this.exec(o.Namespace(), "tap delete "+o.Name()); err != nil {
+			return fmt.Errorf("deleting tap %s on node %s: %w", o.Name(), o.Host(), err)
+		}
+
+		return nil
+	}
+
+	var cmd string
+
+	if o.IP() == "" || o.NetNS() != "" {
+		cmd = fmt.Sprintf("tap create %s bridge %s name %s", o.VLAN(), o.Bridge(), o.Name())
+	} else {
+		cmd = fmt.Sprintf("tap create %s bridge %s ip %s %s", o.VLAN(), o.Bridge(), o.IP(), o.Name())
+	}
+
+	if err := this.exec(o.Namespace(), cmd); err != nil {
+		return fmt.Errorf("creating tap %s on node %s: %w", o.Name(), o.Host(), err)
+	}
+
+	n := this.namespaces[o.Namespace()]
+
+	t := n.taps[o.Name()]
+	t.host = o.Host()
+	t.ip = o.IP()
+	t.netns = o.NetNS()
+
+	n.taps[o.Name()] = t
+
+	return nil
+}
+
+func (this *Minimega) MeshShell(host, command string) error {
+	_, err := this.MeshShellResponse(host, command)
+	return err
+}
+
+func (this *Minimega) MeshShellResponse(host, command string) (string, error) {
+	this.Lock()
+	defer this.Unlock()
+
+	if host == "" {
+		host = this.options.headnode
+	}
+
+	if err := this.record("", this.meshPrefix(host)+"shell "+command); err != nil {
+		return "", fmt.Errorf("running shell command (host %s) %s: %w", host, command, err)
+	}
+
+	if this.options.shellHandler == nil {
+		return "", nil
+	}
+
+	return this.options.shellHandler(host, command)
+}
+
+func (this *Minimega) MeshSend(ns, host, command string) error {
+	this.Lock()
+	defer this.Unlock()
+
+	if host == "" {
+		host = this.options.headnode
+	}
+
+	// Commands sent to other hosts are only recorded, but commands for the
+	// headnode are processed the same way as if they were read from a script.
+	// Commands the fake doesn't know how to process are only recorded.
+	if prefix := this.meshPrefix(host); prefix != "" {
+		if err := this.record(ns, prefix+command); err != nil {
+			return fmt.Errorf("executing mesh send (%s): %w", prefix+command, err)
+		}
+
+		return nil
+	}
+
+	if err := this.exec(ns, command); err != nil && !errors.Is(err, errUnknownCommand) {
+		return fmt.Errorf("executing mesh send (%s): %w", command, err)
+	}
+
+	return nil
+}
+
+func (this *Minimega) meshPrefix(host string) string {
+	if this.IsHeadnode(host) {
+		return ""
+	}
+
+	return fmt.Sprintf("mesh send %s ", host)
+}
+
+// record adds the given command to the history, returning an error if the
+// command has been configured to fail via FailOn. Callers must hold the lock.
+func (this *Minimega) record(ns, cmd string) error {
+	if ns == "" {
+		this.history = append(this.history, cmd)
+	} else {
+		this.history = append(this.history, fmt.Sprintf("namespace %s %s", ns, cmd))
+	}
+
+	for _, f := range this.failures {
+		if strings.HasPrefix(cmd, f.prefix) {
+			return f.err
+		}
+	}
+
+	return nil
+}
+
+// namespace returns the namespace with the given name, creating it if it
+// doesn't exist yet (just like minimega does). Callers must hold the lock.
+func (this *Minimega) namespace(name string) *namespace {
+	if n, ok := this.namespaces[name]; ok {
+		return n
+	}
+
+	n := &namespace{
+		name:   name,
+		vlans:  make(map[string]int),
+		taps:   make(map[string]tap),
+		config: newVMConfig(),
+	}
+
+	this.namespaces[name] = n
+
+	return n
+}
+
+// namespacesFor returns the namespace with the given name, or all namespaces
+// sorted by name if the given name is empty. Callers must hold the lock.
+func (this *Minimega) namespacesFor(name string) []*namespace {
+	if name != "" {
+		if n, ok := this.namespaces[name]; ok {
+			return []*namespace{n}
+		}
+
+		return nil
+	}
+
+	var names []string
+
+	for name := range this.namespaces {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	namespaces := make([]*namespace, len(names))
+
+	for i, name := range names {
+		namespaces[i] = this.namespaces[name]
+	}
+
+	return namespaces
+}
+
+// findVM returns the launched VM with the given name in the given namespace,
+// or nil if it doesn't exist. Callers must hold the lock.
+func (this *Minimega) findVM(ns, name string) *vm {
+	n, ok := this.namespaces[ns]
+	if !ok {
+		return nil
+	}
+
+	for _, v := range n.vms {
+		if v.name == name {
+			return v
+		}
+	}
+
+	return nil
+}
+
+// hostsFor returns the names of the hosts VMs in the given namespace can be
+// scheduled on. Callers must hold the lock.
+func (this *Minimega) hostsFor(n *namespace) []string {
+	if len(n.hosts) > 0 {
+		return n.hosts
+	}
+
+	var hosts []string
+
+	for _, host := range this.options.hosts {
+		if host.Schedulable {
+			hosts = append(hosts, host.Name)
+		}
+	}
+
+	return hosts
+}
+
+// clusterHosts returns the configured cluster hosts updated with the resources
+// committed to the VMs currently scheduled on them. Callers must hold the lock.
+func (this *Minimega) clusterHosts() mm.Hosts {
+	hosts := make(mm.Hosts, len(this.options.hosts))
+	copy(hosts, this.options.hosts)
+
+	for _, n := range this.namespaces {
+		for _, v := range n.vms {
+			hosts.IncrHostVMs(v.host, 1)
+			hosts.IncrHostCPUCommit(v.host, v.cpus)
+			hosts.IncrHostMemCommit(v.host, v.mem)
+		}
+	}
+
+	return hosts
+}
+
+func (this *Minimega) vmInfo(n *namespace, v *vm) mm.VM {
+	info := mm.VM{
+		ID:         v.id,
+		Name:       v.name,
+		Type:       v.vmType,
+		Experiment: n.name,
+		Host:       v.host,
+		CPUs:       v.cpus,
+		RAM:        v.mem,
+		Disk:       v.disk,
+		State:      v.state,
+		Running:    v.state == "RUNNING",
+		CCActive:   isC2Active(v),
+		CdRom:      v.cdrom,
+		Snapshot:   v.snapshot,
+		UUID:       v.uuid,
+		Captures:   vmCaptures(n, v.name),
+	}
+
+	if backing, ok := this.snapshots[v.disk]; ok {
+		info.Disk = backing
+	}
+
+	for _, alias := range v.networks {
+		if alias == "" {
+			info.Networks = append(info.Networks, "disconnected")
+			continue
+		}
+
+		info.Networks = append(info.Networks, fmt.Sprintf("%s (%d)", alias, n.vlans[alias]))
+	}
+
+	info.Taps = append(info.Taps, v.taps...)
+
+	var labels []string
+
+	for k := range v.tags {
+		labels = append(labels, k)
+	}
+
+	sort.Strings(labels)
+
+	for _, k := range labels {
+		info.Tags = append(info.Tags, fmt.Sprintf("%s:%s", k, v.tags[k]))
+	}
+
+	if !v.started.IsZero() && info.Running {
+		info.Uptime = time.Since(v.started).Seconds()
+	}
+
+	return info
+}
+
+func (this *Minimega) matchTunnels(n *namespace, o interface {
+	VM() string
+	TunnelDestHost() string
+	TunnelDestPort() int
+}) []tunnel {
+	var tunnels []tunnel
+
+	for _, t := range n.tunnels {
+		if o.VM() != "" && t.vm != o.VM() {
+			continue
+		}
+
+		if o.TunnelDestHost() != "" && t.dstHost != o.TunnelDestHost() {
+			continue
+		}
+
+		if o.TunnelDestPort() != 0 && t.dstPort != o.TunnelDestPort() {
+			continue
+		}
+
+		tunnels = append(tunnels, t)
+	}
+
+	return tunnels
+}
+
+// allocateVLAN returns the VLAN ID for the given alias in the given namespace,
+// allocating a new ID from the namespace's VLAN range (or the default minimega
+// range) if needed. Callers must hold the lock.
+func (this *Minimega) allocateVLAN(n *namespace, alias string) (int, error) {
+	if id, ok := n.vlans[alias]; ok {
+		return id, nil
+	}
+
+	min, max := n.vlanMin, n.vlanMax
+
+	if min == 0 {
+		min = defaultVLANMin
+	}
+
+	if max == 0 {
+		max = 4095
+	}
+
+	used := make(map[int]bool)
+
+	for _, other := range this.namespaces {
+		for _, id := range other.vlans {
+			used[id] = true
+		}
+	}
+
+	for id := min; id <= max; id++ {
+		if !used[id] {
+			n.vlans[alias] = id
+			return id, nil
+		}
+	}
+
+	return 0, fmt.Errorf("no more VLANs available in range %d-%d", min, max)
+}
+
+func (this *Minimega) c2Active(o interface {
+	Namespace() string
+	VM() string
+	SkipActiveClientCheck() bool
+}) error {
+	if o.SkipActiveClientCheck() {
+		return nil
+	}
+
+	v := this.findVM(o.Namespace(), o.VM())
+	if v == nil {
+		return fmt.Errorf("VM %s does not exist", o.VM())
+	}
+
+	if !isC2Active(v) {
+		return mm.ErrC2ClientNotActive
+	}
+
+	return nil
+}
+
+// execC2 executes the given C2 command against the given VM, generating the
+// response for it immediately. Callers must hold the lock.
+func (this *Minimega) execC2(ns, name, command string) (string, error) {
+	if err := this.record(ns, "cc filter name="+name); err != nil {
+		return "", fmt.Errorf("setting host filter to %s: %w", name, err)
+	}
+
+	if err := this.record(ns, "cc "+command); err != nil {
+		return "", fmt.Errorf("running 'cc %s' in vm %s: %w", command, name, err)
+	}
+
+	return this.queueC2(this.namespace(ns), name, command), nil
+}
+
+// queueC2 stores a C2 command and its response, returning the command ID.
+// Callers must hold the lock.
+func (this *Minimega) queueC2(n *namespace, name, command string) string {
+	this.c2Idx++
+
+	cmd := c2Command{id: strconv.Itoa(this.c2Idx), vm: name, command: command}
+
+	if h := this.options.c2Handler; h != nil && strings.HasPrefix(command, "exec ") {
+		cmd.stdout, cmd.stderr = h(n.name, name, strings.TrimPrefix(command, "exec "))
+	}
+
+	n.c2 = append(n.c2, cmd)
+
+	return cmd.id
+}
+
+func (this *Minimega) findC2(ns, id string) (c2Command, error) {
+	if n, ok := this.namespaces[ns]; ok {
+		for _, cmd := range n.c2 {
+			if cmd.id == id {
+				return cmd, nil
+			}
+		}
+	}
+
+	return c2Command{}, fmt.Errorf("getting response for command %s: command not found", id)
+}
+
+func isC2Active(v *vm) bool {
+	if v.c2 != nil {
+		return *v.c2
+	}
+
+	return v.state == "RUNNING"
+}
+
+func vmCaptures(n *namespace, name string) []mm.Capture {
+	var captures []mm.Capture
+
+	for _, capture := range n.captures {
+		if capture.VM == name {
+			captures = append(captures, capture)
+		}
+	}
+
+	return captures
+}
+
+func newUUID() string {
+	return uuid.Must(uuid.NewV4()).String()
+}
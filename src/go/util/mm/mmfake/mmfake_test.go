@@ -0,0 +1,294 @@
+package mmfake
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"phenix/util/mm"
+)
+
+var script = `
+namespace foobar
+ns queueing true
+vlans range 200 300
+vlans add MGMT 250
+
+## VM: host-00 ##
+disk snapshot linux.qc2 headnode_foobar_host-00_snapshot
+clear vm config
+vm config schedule compute1
+vm config vcpus 2
+vm config memory 4096
+vm config snapshot true
+vm config disk headnode_foobar_host-00_snapshot,writeback
+vm config net phenix,MGMT,00:00:00:00:00:01 phenix,EXP
+vm config tags foo bar
+vm launch kvm host-00
+
+## VM: host-01 ##
+clear vm config
+vm config vcpus 1
+vm config memory 2048
+vm config snapshot false
+vm config disk linux.qc2
+vm config net phenix,EXP
+vm launch kvm host-01
+`
+
+func writeScript(t *testing.T, contents string) string {
+	path := filepath.Join(t.TempDir(), "foobar.mm")
+
+	if err := os.WriteFile(path, []byte(contents), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	return path
+}
+
+func newCluster() *Minimega {
+	return New(
+		Headnode("compute0"),
+		ClusterHost(mm.Host{Name: "compute0", CPUs: 8, MemTotal: 16384}),
+		ClusterHost(mm.Host{Name: "compute1", CPUs: 8, MemTotal: 16384}),
+	)
+}
+
+func TestReadScriptAndLaunch(t *testing.T) {
+	m := newCluster()
+
+	if err := m.ReadScriptFromFile(writeScript(t, script)); err != nil {
+		t.Fatal(err)
+	}
+
+	if vms := m.GetVMInfo(mm.NS("foobar")); len(vms) != 0 {
+		t.Fatalf("expected queued VMs to not be launched, got %d VMs", len(vms))
+	}
+
+	if progress, _ := m.GetLaunchProgress("foobar", 2); progress != 1.0 {
+		t.Fatalf("expected launch progress of 1.0, got %f", progress)
+	}
+
+	if err := m.LaunchVMs("foobar"); err != nil {
+		t.Fatal(err)
+	}
+
+	vms := m.GetVMInfo(mm.NS("foobar"))
+
+	if len(vms) != 2 {
+		t.Fatalf("expected 2 VMs, got %d", len(vms))
+	}
+
+	vm := vms[0]
+
+	if vm.Name != "host-00" || vm.Host != "compute1" || vm.CPUs != 2 || vm.RAM != 4096 {
+		t.Fatalf("unexpected VM details: %+v", vm)
+	}
+
+	if !vm.Running || !vm.CCActive {
+		t.Fatalf("expected VM to be running with active C2, got state %s", vm.State)
+	}
+
+	if vm.Disk != "linux.qc2" {
+		t.Fatalf("expected snapshot backing disk linux.qc2, got %s", vm.Disk)
+	}
+
+	if len(vm.Networks) != 2 || vm.Networks[0] != "MGMT (250)" || vm.Networks[1] != "EXP (200)" {
+		t.Fatalf("unexpected VM networks: %v", vm.Networks)
+	}
+
+	if len(vm.Tags) != 1 || vm.Tags[0] != "foo:bar" {
+		t.Fatalf("unexpected VM tags: %v", vm.Tags)
+	}
+
+	vlans, _ := m.GetVLANs(mm.NS("foobar"))
+
+	if vlans["MGMT"] != 250 || vlans["EXP"] != 200 {
+		t.Fatalf("unexpected VLANs: %v", vlans)
+	}
+
+	hosts, _ := m.GetClusterHosts(true)
+
+	if host := hosts.FindHostByName("compute1"); host == nil || host.VMs != 2 || host.CPUCommit != 3 || host.MemCommit != 6144 {
+		t.Fatalf("unexpected host commit details: %+v", host)
+	}
+}
+
+func TestVMLifecycle(t *testing.T) {
+	m := newCluster()
+
+	if err := m.ReadScriptFromFile(writeScript(t, script)); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := m.LaunchVMs("foobar", "host-01"); err != nil {
+		t.Fatal(err)
+	}
+
+	if state, _ := m.GetVMState(mm.NS("foobar"), mm.VMName("host-00")); state != "PAUSED" {
+		t.Fatalf("expected host-00 to be paused, got %s", state)
+	}
+
+	if err := m.StartVM(mm.NS("foobar"), mm.VMName("host-00")); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := m.RedeployVM(mm.NS("foobar"), mm.VMName("host-01"), mm.CPU(4), mm.Mem(8192)); err != nil {
+		t.Fatal(err)
+	}
+
+	vms := m.GetVMInfo(mm.NS("foobar"), mm.VMName("host-01"))
+
+	if len(vms) != 1 || vms[0].CPUs != 4 || vms[0].RAM != 8192 || !vms[0].Running {
+		t.Fatalf("unexpected redeployed VM details: %+v", vms)
+	}
+
+	if err := m.KillVM(mm.NS("foobar"), mm.VMName("host-01")); err != nil {
+		t.Fatal(err)
+	}
+
+	if vms := m.GetVMInfo(mm.NS("foobar")); len(vms) != 1 {
+		t.Fatalf("expected 1 VM after kill, got %d", len(vms))
+	}
+
+	if err := m.ClearNamespace("foobar"); err != nil {
+		t.Fatal(err)
+	}
+
+	if ns := m.Namespaces(); len(ns) != 0 {
+		t.Fatalf("expected no namespaces, got %v", ns)
+	}
+}
+
+func TestCapturesAndTunnels(t *testing.T) {
+	m := newCluster()
+
+	if err := m.ReadScriptFromFile(writeScript(t, script)); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := m.LaunchVMs("foobar"); err != nil {
+		t.Fatal(err)
+	}
+
+	opts := []mm.Option{mm.NS("foobar"), mm.VMName("host-00"), mm.CaptureInterface(0), mm.CaptureFile("foobar/files/host-00.pcap")}
+
+	if err := m.StartVMCapture(opts...); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := m.StartVMCapture(opts...); !errors.Is(err, mm.ErrCaptureExists) {
+		t.Fatalf("expected capture exists error, got %v", err)
+	}
+
+	if captures := m.GetExperimentCaptures(mm.NS("foobar")); len(captures) != 1 {
+		t.Fatalf("expected 1 capture, got %d", len(captures))
+	}
+
+	if err := m.StopVMCapture(mm.NS("foobar"), mm.VMName("host-00")); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := m.StopVMCapture(mm.NS("foobar"), mm.VMName("host-00")); !errors.Is(err, mm.ErrNoCaptures) {
+		t.Fatalf("expected no captures error, got %v", err)
+	}
+
+	opts = []mm.Option{mm.NS("foobar"), mm.VMName("host-00"), mm.TunnelSourcePort(8080), mm.TunnelDestinationHost("127.0.0.1"), mm.TunnelDestinationPort(80)}
+
+	if err := m.CreateTunnel(opts...); err != nil {
+		t.Fatal(err)
+	}
+
+	if tunnels := m.GetTunnels(mm.NS("foobar"), mm.VMName("host-00"), mm.TunnelDestinationPort(80)); len(tunnels) != 1 {
+		t.Fatalf("expected 1 tunnel, got %d", len(tunnels))
+	}
+
+	if err := m.CloseTunnel(opts...); err != nil {
+		t.Fatal(err)
+	}
+
+	if tunnels := m.GetTunnels(mm.NS("foobar")); len(tunnels) != 0 {
+		t.Fatalf("expected no tunnels, got %d", len(tunnels))
+	}
+}
+
+func TestC2(t *testing.T) {
+	handler := func(ns, vm, command string) (string, string) {
+		if command == "hostname" {
+			return vm, ""
+		}
+
+		return "", "command not found"
+	}
+
+	m := New(Headnode("compute1"), WithC2Handler(handler))
+
+	if err := m.ReadScriptFromFile(writeScript(t, script)); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := m.ExecC2Command(mm.C2NS("foobar"), mm.C2VM("host-00"), mm.C2Command("hostname")); err == nil {
+		t.Fatal("expected error executing command in VM that isn't launched")
+	}
+
+	if err := m.LaunchVMs("foobar"); err != nil {
+		t.Fatal(err)
+	}
+
+	id, err := m.ExecC2Command(mm.C2NS("foobar"), mm.C2VM("host-00"), mm.C2Command("hostname"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err := m.WaitForC2Response(mm.C2NS("foobar"), mm.C2CommandID(id))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if resp != "host-00" {
+		t.Fatalf("expected response host-00, got %s", resp)
+	}
+
+	id, _ = m.ExecC2Command(mm.C2NS("foobar"), mm.C2VM("host-00"), mm.C2Command("foo"))
+
+	resp, _ = m.GetC2Response(mm.C2NS("foobar"), mm.C2VM("host-00"), mm.C2CommandID(id), mm.C2ResponseTypeStderr())
+
+	if resp != "command not found" {
+		t.Fatalf("expected stderr response, got %s", resp)
+	}
+
+	m.SetC2Active("foobar", "host-01", false)
+
+	if err := m.IsC2ClientActive(mm.C2NS("foobar"), mm.C2VM("host-01")); !errors.Is(err, mm.ErrC2ClientNotActive) {
+		t.Fatalf("expected C2 client not active error, got %v", err)
+	}
+}
+
+func TestFailOn(t *testing.T) {
+	m := newCluster()
+
+	m.FailOn("vm launch", errors.New("boom"))
+
+	if err := m.ReadScriptFromFile(writeScript(t, script)); err == nil {
+		t.Fatal("expected error reading script")
+	}
+
+	m.ClearFailures()
+
+	if err := m.ClearNamespace("foobar"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := m.ReadScriptFromFile(writeScript(t, script)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestUnknownScriptCommand(t *testing.T) {
+	m := newCluster()
+
+	if err := m.ReadScriptFromFile(writeScript(t, "namespace foobar\nbogus command\n")); !errors.Is(err, errUnknownCommand) {
+		t.Fatalf("expected unknown command error, got %v", err)
+	}
+}
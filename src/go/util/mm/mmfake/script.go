@@ -0,0 +1,654 @@
+package mmfake
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"phenix/util/mm"
+)
+
+var errUnknownCommand = errors.New("unknown command")
+
+// vmConfig tracks the current `vm config` settings for a namespace, which get
+// applied to VMs when they're launched.
+type vmConfig struct {
+	schedule string
+	vcpus    int
+	memory   int
+	disk     string
+	cdrom    string
+	snapshot bool
+	networks []string
+	tags     map[string]string
+	other    map[string]string
+}
+
+func newVMConfig() vmConfig {
+	return vmConfig{
+		vcpus:    1,
+		memory:   2048,
+		snapshot: true,
+		tags:     make(map[string]string),
+		other:    make(map[string]string),
+	}
+}
+
+// readScript processes each line in the given minimega script file. Callers
+// must hold the lock.
+func (this *Minimega) readScript(filename string) error {
+	f, err := os.Open(filename)
+	if err != nil {
+		return fmt.Errorf("reading mmcli script: %w", err)
+	}
+
+	defer f.Close()
+
+	if err := this.record("", "read "+filename); err != nil {
+		return fmt.Errorf("reading mmcli script: %w", err)
+	}
+
+	scanner := bufio.NewScanner(f)
+
+	for line := 1; scanner.Scan(); line++ {
+		cmd := strings.TrimSpace(scanner.Text())
+
+		if cmd == "" || strings.HasPrefix(cmd, "#") {
+			continue
+		}
+
+		if err := this.exec(this.active, cmd); err != nil {
+			return fmt.Errorf("reading mmcli script: line %d (%s): %w", line, cmd, err)
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("reading mmcli script: %w", err)
+	}
+
+	return nil
+}
+
+// exec processes a single minimega command in the given namespace. Callers
+// must hold the lock.
+func (this *Minimega) exec(ns, cmd string) error {
+	fields := strings.Fields(cmd)
+
+	if len(fields) == 0 {
+		return nil
+	}
+
+	// `namespace <name>` changes the active namespace, while `namespace <name>
+	// <command>` runs the command in the given namespace.
+	if fields[0] == "namespace" {
+		if len(fields) < 2 {
+			return fmt.Errorf("namespace name required")
+		}
+
+		if len(fields) == 2 {
+			if err := this.record("", cmd); err != nil {
+				return err
+			}
+
+			this.namespace(fields[1])
+			this.active = fields[1]
+
+			return nil
+		}
+
+		return this.exec(fields[1], strings.Join(fields[2:], " "))
+	}
+
+	if err := this.record(ns, cmd); err != nil {
+		return err
+	}
+
+	switch fields[0] {
+	case "clear":
+		return this.execClear(ns, fields[1:])
+	case "ns":
+		return this.execNS(ns, fields[1:])
+	case "vlans":
+		return this.execVLANs(ns, fields[1:])
+	case "disk":
+		return this.execDisk(fields[1:])
+	case "vm":
+		return this.execVM(ns, fields[1:])
+	case "cc":
+		return this.execCC(ns, fields[1:])
+	case "tap":
+		return this.execTap(ns, fields[1:])
+	case "router", "shell", "file":
+		// Nothing to track for these commands.
+		return nil
+	}
+
+	return fmt.Errorf("%w: %s", errUnknownCommand, cmd)
+}
+
+func (this *Minimega) execClear(ns string, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("%w: clear", errUnknownCommand)
+	}
+
+	n := this.namespace(ns)
+
+	switch strings.Join(args, " ") {
+	case "vm config":
+		n.config = newVMConfig()
+	case "cc filter":
+		n.c2Filter = ""
+	case "cc responses":
+		n.c2 = nil
+	default:
+		if args[0] == "namespace" && len(args) == 2 {
+			delete(this.namespaces, args[1])
+			return nil
+		}
+
+		return fmt.Errorf("%w: clear %s", errUnknownCommand, strings.Join(args, " "))
+	}
+
+	return nil
+}
+
+func (this *Minimega) execNS(ns string, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("%w: ns", errUnknownCommand)
+	}
+
+	n := this.namespace(ns)
+
+	switch args[0] {
+	case "queueing":
+		if len(args) != 2 {
+			return fmt.Errorf("usage: ns queueing <true|false>")
+		}
+
+		queueing, err := strconv.ParseBool(args[1])
+		if err != nil {
+			return fmt.Errorf("parsing queueing setting: %w", err)
+		}
+
+		n.queueing = queueing
+	case "queue":
+	case "add-host":
+		for _, host := range args[1:] {
+			if host == "localhost" {
+				host = this.options.headnode
+			}
+
+			if this.clusterHosts().FindHostByName(host) == nil {
+				return fmt.Errorf("%w: %s", mm.ErrHostNotFound, host)
+			}
+
+			n.hosts = appendUnique(n.hosts, host)
+		}
+	case "del-host":
+		for _, host := range args[1:] {
+			if host == "all" {
+				n.hosts = nil
+				continue
+			}
+
+			n.hosts = remove(n.hosts, host)
+		}
+	case "bridge":
+		if len(args) < 2 {
+			return fmt.Errorf("usage: ns bridge <name> [type]")
+		}
+
+		n.bridges = appendUnique(n.bridges, args[1])
+	case "del-bridge":
+		if len(args) < 2 {
+			return fmt.Errorf("usage: ns del-bridge <name>")
+		}
+
+		if len(remove(n.bridges, args[1])) == len(n.bridges) {
+			return fmt.Errorf("bridge %s not found", args[1])
+		}
+
+		n.bridges = remove(n.bridges, args[1])
+	default:
+		return fmt.Errorf("%w: ns %s", errUnknownCommand, strings.Join(args, " "))
+	}
+
+	return nil
+}
+
+func (this *Minimega) execVLANs(ns string, args []string) error {
+	n := this.namespace(ns)
+
+	if len(args) == 0 {
+		return nil
+	}
+
+	switch args[0] {
+	case "range":
+		if len(args) != 3 {
+			return fmt.Errorf("usage: vlans range <min> <max>")
+		}
+
+		min, err := strconv.Atoi(args[1])
+		if err != nil {
+			return fmt.Errorf("parsing VLAN range min: %w", err)
+		}
+
+		max, err := strconv.Atoi(args[2])
+		if err != nil {
+			return fmt.Errorf("parsing VLAN range max: %w", err)
+		}
+
+		if min > max {
+			return fmt.Errorf("invalid VLAN range %d-%d", min, max)
+		}
+
+		n.vlanMin, n.vlanMax = min, max
+	case "add":
+		if len(args) != 3 {
+			return fmt.Errorf("usage: vlans add <alias> <id>")
+		}
+
+		id, err := strconv.Atoi(args[2])
+		if err != nil {
+			return fmt.Errorf("parsing VLAN ID: %w", err)
+		}
+
+		for name, other := range this.namespaces {
+			for alias, used := range other.vlans {
+				if used == id && (name != ns || alias != args[1]) {
+					return fmt.Errorf("VLAN %d already in use by %s//%s", id, name, alias)
+				}
+			}
+		}
+
+		n.vlans[args[1]] = id
+	default:
+		return fmt.Errorf("%w: vlans %s", errUnknownCommand, strings.Join(args, " "))
+	}
+
+	return nil
+}
+
+func (this *Minimega) execDisk(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("%w: disk", errUnknownCommand)
+	}
+
+	switch args[0] {
+	case "snapshot":
+		if len(args) != 3 {
+			return fmt.Errorf("usage: disk snapshot <image> <dst>")
+		}
+
+		this.snapshots[args[2]] = args[1]
+		this.files[args[2]] = 0
+	case "inject":
+		if len(args) < 2 {
+			return fmt.Errorf("usage: disk inject <image>[:<partition>] files <files>")
+		}
+
+		image := strings.Split(args[1], ":")[0]
+
+		if _, ok := this.files[image]; !ok {
+			if _, ok := this.snapshots[image]; !ok {
+				return fmt.Errorf("disk image %s not found", image)
+			}
+		}
+	default:
+		return fmt.Errorf("%w: disk %s", errUnknownCommand, strings.Join(args, " "))
+	}
+
+	return nil
+}
+
+func (this *Minimega) execVM(ns string, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("%w: vm", errUnknownCommand)
+	}
+
+	n := this.namespace(ns)
+
+	switch args[0] {
+	case "config":
+		return this.execVMConfig(n, args[1:])
+	case "launch":
+		if len(args) == 1 {
+			return this.launch(n)
+		}
+
+		if len(args) != 3 {
+			return fmt.Errorf("usage: vm launch <type> <name>")
+		}
+
+		return this.queue(n, args[1], args[2])
+	case "start", "stop", "kill":
+		if len(args) != 2 {
+			return fmt.Errorf("usage: vm %s <name|all>", args[0])
+		}
+
+		var found bool
+
+		for _, v := range n.vms {
+			if args[1] != "all" && v.name != args[1] {
+				continue
+			}
+
+			found = true
+
+			switch args[0] {
+			case "start":
+				if v.state != "RUNNING" {
+					v.state = "RUNNING"
+					v.started = time.Now()
+				}
+			case "stop":
+				if v.state == "RUNNING" {
+					v.state = "PAUSED"
+				}
+			case "kill":
+				v.state = "QUIT"
+			}
+		}
+
+		if !found && args[1] != "all" {
+			return fmt.Errorf("vm not found: %s", args[1])
+		}
+	case "flush":
+		var keep []*vm
+
+		for _, v := range n.vms {
+			if v.state != "QUIT" && v.state != "ERROR" {
+				keep = append(keep, v)
+			}
+		}
+
+		n.vms = keep
+	default:
+		return fmt.Errorf("%w: vm %s", errUnknownCommand, strings.Join(args, " "))
+	}
+
+	return nil
+}
+
+func (this *Minimega) execVMConfig(n *namespace, args []string) error {
+	if len(args) == 0 {
+		return nil
+	}
+
+	var (
+		key   = args[0]
+		value = strings.Join(args[1:], " ")
+	)
+
+	switch key {
+	case "schedule":
+		n.config.schedule = value
+	case "vcpus":
+		vcpus, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("parsing vcpus: %w", err)
+		}
+
+		n.config.vcpus = vcpus
+	case "memory", "mem":
+		memory, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("parsing memory: %w", err)
+		}
+
+		n.config.memory = memory
+	case "snapshot":
+		snapshot, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("parsing snapshot: %w", err)
+		}
+
+		n.config.snapshot = snapshot
+	case "disk", "disks":
+		if len(args) < 2 {
+			return fmt.Errorf("usage: vm config disk <disk spec>")
+		}
+
+		// Multiple disks are separated by whitespace, and each disk spec can
+		// include multiple settings separated by a comma. The path to the first
+		// disk is all that's tracked.
+		n.config.disk = strings.Split(args[1], ",")[0]
+	case "cdrom":
+		n.config.cdrom = value
+	case "net":
+		n.config.networks = nil
+
+		for _, spec := range args[1:] {
+			// [<bridge>,]<vlan>[,<mac>][,<driver>][,qinq]
+			tokens := strings.Split(spec, ",")
+
+			alias := tokens[0]
+
+			if len(tokens) > 1 {
+				alias = tokens[1]
+			}
+
+			n.config.networks = append(n.config.networks, alias)
+		}
+	case "tags":
+		if len(args) < 3 {
+			return fmt.Errorf("usage: vm config tags <key> <value>")
+		}
+
+		n.config.tags[args[1]] = strings.Join(args[2:], " ")
+	default:
+		n.config.other[key] = value
+	}
+
+	return nil
+}
+
+// queue adds a new VM to the given namespace using the namespace's current VM
+// config. If queueing is enabled for the namespace, the VM won't be created
+// until `vm launch` is called. Callers must hold the lock.
+func (this *Minimega) queue(n *namespace, vmType, name string) error {
+	for _, v := range append(n.vms, n.queue...) {
+		if v.name == name {
+			return fmt.Errorf("vm already exists: %s", name)
+		}
+	}
+
+	if vmType != "kvm" && vmType != "container" && vmType != "rkvm" {
+		return fmt.Errorf("invalid VM type: %s", vmType)
+	}
+
+	v := &vm{
+		name:     name,
+		vmType:   vmType,
+		host:     n.config.schedule,
+		cpus:     n.config.vcpus,
+		mem:      n.config.memory,
+		disk:     n.config.disk,
+		cdrom:    n.config.cdrom,
+		snapshot: n.config.snapshot,
+		networks: make([]string, len(n.config.networks)),
+		tags:     make(map[string]string),
+		config:   make(map[string]string),
+	}
+
+	copy(v.networks, n.config.networks)
+
+	for k, val := range n.config.tags {
+		v.tags[k] = val
+	}
+
+	for k, val := range n.config.other {
+		v.config[k] = val
+	}
+
+	n.queue = append(n.queue, v)
+
+	if n.queueing {
+		return nil
+	}
+
+	return this.launch(n)
+}
+
+// launch creates all the VMs queued in the given namespace, scheduling them on
+// the namespace's hosts and allocating VLANs for their interfaces. Launched VMs
+// start out paused. Callers must hold the lock.
+func (this *Minimega) launch(n *namespace) error {
+	hosts := this.hostsFor(n)
+
+	if len(n.queue) > 0 && len(hosts) == 0 {
+		return fmt.Errorf("no hosts available in namespace %s", n.name)
+	}
+
+	for i, v := range n.queue {
+		if v.host == "" {
+			v.host = hosts[(len(n.vms)+i)%len(hosts)]
+		} else if this.clusterHosts().FindHostByName(v.host) == nil {
+			return fmt.Errorf("%w: %s", mm.ErrHostNotFound, v.host)
+		}
+
+		for _, alias := range v.networks {
+			if _, err := this.allocateVLAN(n, alias); err != nil {
+				return fmt.Errorf("launching VM %s: %w", v.name, err)
+			}
+
+			this.tapIdx++
+			v.taps = append(v.taps, fmt.Sprintf("mega_tap%d", this.tapIdx))
+		}
+	}
+
+	for _, v := range n.queue {
+		v.id = n.nextID
+		v.uuid = newUUID()
+		v.state = "PAUSED"
+
+		n.nextID++
+		n.vms = append(n.vms, v)
+	}
+
+	n.queue = nil
+
+	return nil
+}
+
+func (this *Minimega) execCC(ns string, args []string) error {
+	if len(args) == 0 {
+		return nil
+	}
+
+	n := this.namespace(ns)
+
+	switch args[0] {
+	case "filter":
+		n.c2Filter = strings.TrimPrefix(strings.Join(args[1:], " "), "name=")
+		return nil
+	case "responses", "commands", "client", "clients", "tunnel":
+		return nil
+	}
+
+	this.queueC2(n, n.c2Filter, strings.Join(args, " "))
+
+	return nil
+}
+
+func (this *Minimega) execTap(ns string, args []string) error {
+	if len(args) < 2 {
+		return fmt.Errorf("%w: tap %s", errUnknownCommand, strings.Join(args, " "))
+	}
+
+	n := this.namespace(ns)
+
+	switch args[0] {
+	case "create":
+		// tap create <vlan> [bridge <bridge>] [ip <ip>] [name] <name>
+		var (
+			t    = tap{vlan: args[1]}
+			name string
+		)
+
+		for i := 2; i < len(args); i++ {
+			switch args[i] {
+			case "bridge", "ip", "name":
+				if i+1 >= len(args) {
+					return fmt.Errorf("missing value for tap %s", args[i])
+				}
+
+				switch args[i] {
+				case "bridge":
+					t.bridge = args[i+1]
+				case "ip":
+					t.ip = args[i+1]
+				case "name":
+					name = args[i+1]
+				}
+
+				i++
+			default:
+				name = args[i]
+			}
+		}
+
+		if name == "" {
+			this.tapIdx++
+			name = fmt.Sprintf("mega_tap%d", this.tapIdx)
+		}
+
+		if _, ok := n.taps[name]; ok {
+			return fmt.Errorf("tap already exists: %s", name)
+		}
+
+		alias := t.vlan
+
+		if tokens := strings.SplitN(alias, "//", 2); len(tokens) == 2 {
+			alias = tokens[1]
+		}
+
+		if _, err := this.allocateVLAN(n, alias); err != nil {
+			return fmt.Errorf("creating tap %s: %w", name, err)
+		}
+
+		n.taps[name] = t
+	case "delete":
+		if args[1] == "all" {
+			n.taps = make(map[string]tap)
+			return nil
+		}
+
+		if _, ok := n.taps[args[1]]; !ok {
+			return fmt.Errorf("tap not found: %s", args[1])
+		}
+
+		delete(n.taps, args[1])
+	default:
+		return fmt.Errorf("%w: tap %s", errUnknownCommand, strings.Join(args, " "))
+	}
+
+	return nil
+}
+
+func appendUnique(list []string, item string) []string {
+	for _, existing := range list {
+		if existing == item {
+			return list
+		}
+	}
+
+	return append(list, item)
+}
+
+func remove(list []string, item string) []string {
+	var keep []string
+
+	for _, existing := range list {
+		if existing != item {
+			keep = append(keep, existing)
+		}
+	}
+
+	return keep
+}
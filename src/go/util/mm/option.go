@@ -26,8 +26,16 @@ type options struct {
 	captureIface int
 	captureFile  string
 
+	qosIface int
+	qosDelay string
+	qosLoss  string
+	qosRate  string
+
 	screenshotSize string
 
+	snapshotFile     string
+	snapshotProgress func(float64)
+
 	// tunnels
 	srcPort int
 	dstPort int
@@ -110,6 +118,35 @@ func DisonnectInterface(i int) Option {
 	}
 }
 
+func QoSInterface(i int) Option {
+	return func(o *options) {
+		o.qosIface = i
+	}
+}
+
+// QoSDelay sets the delay (e.g., 100ms) to add to traffic leaving a VM
+// interface.
+func QoSDelay(d string) Option {
+	return func(o *options) {
+		o.qosDelay = d
+	}
+}
+
+// QoSLoss sets the percentage of packets to drop leaving a VM interface.
+func QoSLoss(l string) Option {
+	return func(o *options) {
+		o.qosLoss = l
+	}
+}
+
+// QoSRate sets the rate to limit traffic leaving a VM interface to, given as a
+// value and unit (kbit, mbit, or gbit) separated by a space (e.g., 10 mbit).
+func QoSRate(r string) Option {
+	return func(o *options) {
+		o.qosRate = r
+	}
+}
+
 func CaptureInterface(i int) Option {
 	return func(o *options) {
 		o.captureIface = i
@@ -128,6 +165,23 @@ func ScreenshotSize(s string) Option {
 	}
 }
 
+// SnapshotFile sets the path, relative to the minimega files directory and
+// without an extension, of the disk (.qc2) and memory (.SNAP) snapshot files
+// for a VM.
+func SnapshotFile(f string) Option {
+	return func(o *options) {
+		o.snapshotFile = f
+	}
+}
+
+// SnapshotProgress sets a callback that's periodically called with the
+// progress (0.0 - 1.0) of a VM snapshot.
+func SnapshotProgress(p func(float64)) Option {
+	return func(o *options) {
+		o.snapshotProgress = p
+	}
+}
+
 func TunnelSourcePort(p int) Option {
 	return func(o *options) {
 		o.srcPort = p
@@ -367,3 +421,57 @@ func TapDelete() TapOption {
 		o.untap = true
 	}
 }
+
+// The following accessors expose the values of an options struct to MM
+// implementations that live outside of this package (such as the in-memory
+// fake in the `mmfake` package).
+
+func (this options) Namespace() string      { return this.ns }
+func (this options) VM() string             { return this.vm }
+func (this options) CPU() int               { return this.cpu }
+func (this options) Mem() int               { return this.mem }
+func (this options) Disk() string           { return this.disk }
+func (this options) Bridge() string         { return this.bridge }
+func (this options) InjectPartition() int   { return this.injectPart }
+func (this options) Injects() []string      { return this.injects }
+func (this options) ConnectInterface() int  { return this.connectIface }
+func (this options) ConnectVLAN() string    { return this.connectVLAN }
+func (this options) QoSInterface() int      { return this.qosIface }
+func (this options) QoSDelay() string       { return this.qosDelay }
+func (this options) QoSLoss() string        { return this.qosLoss }
+func (this options) QoSRate() string        { return this.qosRate }
+func (this options) CaptureInterface() int  { return this.captureIface }
+func (this options) CaptureFile() string    { return this.captureFile }
+func (this options) ScreenshotSize() string { return this.screenshotSize }
+func (this options) SnapshotFile() string   { return this.snapshotFile }
+func (this options) SnapshotProgress(p float64) {
+	if this.snapshotProgress != nil {
+		this.snapshotProgress(p)
+	}
+}
+func (this options) TunnelSourcePort() int  { return this.srcPort }
+func (this options) TunnelDestPort() int    { return this.dstPort }
+func (this options) TunnelDestHost() string { return this.dstHost }
+
+func (this c2Options) Context() context.Context     { return this.ctx }
+func (this c2Options) Namespace() string            { return this.ns }
+func (this c2Options) VM() string                   { return this.vm }
+func (this c2Options) Command() string              { return this.command }
+func (this c2Options) CommandID() string            { return this.commandID }
+func (this c2Options) TestConn() string             { return this.testConn }
+func (this c2Options) SendFile() string             { return this.sendFile }
+func (this c2Options) Mount() *bool                 { return this.mount }
+func (this c2Options) Timeout() time.Duration       { return this.timeout }
+func (this c2Options) Wait() bool                   { return this.wait }
+func (this c2Options) SkipActiveClientCheck() bool  { return this.skipActiveClientCheck }
+func (this c2Options) ResponseType() C2ResponseType { return this.responseType }
+func (this c2Options) IDClientsByUUID() bool        { return this.idByUUID }
+
+func (this tapOptions) Namespace() string { return this.ns }
+func (this tapOptions) Name() string      { return this.name }
+func (this tapOptions) Host() string      { return this.host }
+func (this tapOptions) Bridge() string    { return this.bridge }
+func (this tapOptions) VLAN() string      { return this.vlan }
+func (this tapOptions) NetNS() string     { return this.netns }
+func (this tapOptions) IP() string        { return this.ip }
+func (this tapOptions) Untap() bool       { return this.untap }
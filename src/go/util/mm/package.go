@@ -52,6 +52,14 @@ func GetVMState(opts ...Option) (string, error) {
 	return DefaultMM.GetVMState(opts...)
 }
 
+func SnapshotVM(opts ...Option) error {
+	return DefaultMM.SnapshotVM(opts...)
+}
+
+func RestoreVM(opts ...Option) error {
+	return DefaultMM.RestoreVM(opts...)
+}
+
 func ConnectVMInterface(opts ...Option) error {
 	return DefaultMM.ConnectVMInterface(opts...)
 }
@@ -60,6 +68,14 @@ func DisconnectVMInterface(opts ...Option) error {
 	return DefaultMM.DisconnectVMInterface(opts...)
 }
 
+func AddVMInterfaceQoS(opts ...Option) error {
+	return DefaultMM.AddVMInterfaceQoS(opts...)
+}
+
+func ClearVMInterfaceQoS(opts ...Option) error {
+	return DefaultMM.ClearVMInterfaceQoS(opts...)
+}
+
 func CreateBridge(opts ...Option) error {
 	return DefaultMM.CreateBridge(opts...)
 }
@@ -143,6 +143,10 @@ type Host struct {
 type DiskUsage struct {
 	Phenix   float64 `json:"diskphenix"`
 	Minimega float64 `json:"diskminimega"`
+
+	// MinimegaSize is the size, in bytes, of the partition containing the
+	// minimega base directory.
+	MinimegaSize int `json:"diskminimegasize"`
 }
 
 type VMs []VM
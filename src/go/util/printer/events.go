@@ -2,7 +2,9 @@ package printer
 
 import (
 	"io"
+	"strconv"
 
+	"phenix/api/audit"
 	"phenix/store"
 
 	"github.com/olekukonko/tablewriter"
@@ -58,3 +60,30 @@ func PrintEventTable(writer io.Writer, event store.Event) {
 
 	table.Render()
 }
+
+// PrintTableOfAuditEntries writes the given audit entries to the given writer
+// as an ASCII table, oldest first.
+func PrintTableOfAuditEntries(writer io.Writer, entries audit.Entries) {
+	table := tablewriter.NewWriter(writer)
+	table.SetAutoWrapText(false)
+
+	table.SetHeader([]string{"Timestamp", "Origin", "User", "Verb", "Resource", "Name", "Outcome", "Status", "Duration"})
+
+	for i := len(entries) - 1; i >= 0; i-- {
+		var (
+			e      = entries[i]
+			status string
+		)
+
+		if e.Status != 0 {
+			status = strconv.Itoa(e.Status)
+		}
+
+		table.Append([]string{
+			e.Timestamp.Format("01/02/2006 15:04:05 MST"),
+			e.Origin, e.User, e.Verb, e.Resource, e.ResourceName, e.Outcome, status, e.Duration.String(),
+		})
+	}
+
+	table.Render()
+}
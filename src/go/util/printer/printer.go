@@ -9,8 +9,10 @@ import (
 	"strings"
 	"time"
 
+	"phenix/api/quota"
 	"phenix/store"
 	"phenix/types"
+	v1 "phenix/types/version/v1"
 	"phenix/util/mm"
 
 	"github.com/olekukonko/tablewriter"
@@ -30,6 +32,28 @@ func PrintTableOfConfigs(writer io.Writer, configs store.Configs) {
 	table.Render()
 }
 
+// PrintTableOfConfigRevisions writes the given config revisions to the given
+// writer as an ASCII table. The revisions are expected to be ordered oldest
+// first, with the current config last (as returned by `config.History`). The
+// table headers are set to Revision, Version, Updated, and Current.
+func PrintTableOfConfigRevisions(writer io.Writer, revisions store.Configs) {
+	table := tablewriter.NewWriter(writer)
+
+	table.SetHeader([]string{"Revision", "Version", "Updated", "Current"})
+
+	for i, c := range revisions {
+		var current string
+
+		if i == len(revisions)-1 {
+			current = "*"
+		}
+
+		table.Append([]string{strconv.FormatInt(c.Metadata.ResourceVersion, 10), c.Version, c.Metadata.Updated, current})
+	}
+
+	table.Render()
+}
+
 // PrintTableOfExperiments writes the given experiments to the given writer as
 // an ASCII table. The table headers are set to Name, Topology, Scenario,
 // Started, VM Count, VLAN Count, and Apps.
@@ -42,7 +66,11 @@ func PrintTableOfExperiments(writer io.Writer, exps ...types.Experiment) {
 		var apps []string
 
 		for _, app := range exp.Apps() {
-			apps = append(apps, app.Name())
+			if next, ok := exp.Status.AppNextRun()[app.Name()]; ok {
+				apps = append(apps, fmt.Sprintf("%s (next run: %s)", app.Name(), next))
+			} else {
+				apps = append(apps, app.Name())
+			}
 		}
 
 		table.Append([]string{
@@ -242,3 +270,71 @@ func PrintTableOfSubnetCaptures(writer io.Writer, captures []mm.Capture) {
 
 	table.Render()
 }
+
+// PrintTableOfAPITokens writes the given user API tokens to the given writer as
+// an ASCII table. The table headers are set to ID, Name, Policies, Created,
+// Expires, and Last Used. Tokens with no policies have the user's full role.
+// PrintTableOfUsage writes the given usage, and the limits of the given quota,
+// to the given writer as an ASCII table. A nil quota, or a limit of zero, is
+// shown as unlimited.
+func PrintTableOfUsage(writer io.Writer, q *v1.QuotaSpec, usage quota.Usage) {
+	if q == nil {
+		q = new(v1.QuotaSpec)
+	}
+
+	table := tablewriter.NewWriter(writer)
+
+	table.SetAutoWrapText(false)
+	table.SetHeader([]string{"Resource", "Usage", "Limit"})
+
+	limit := func(l int) string {
+		if l == 0 {
+			return "unlimited"
+		}
+
+		return strconv.Itoa(l)
+	}
+
+	table.Append([]string{"Running Experiments", strconv.Itoa(usage.RunningExperiments), limit(q.MaxRunningExperiments)})
+	table.Append([]string{"VMs", strconv.Itoa(usage.VMs), limit(q.MaxVMs)})
+	table.Append([]string{"vCPUs", strconv.Itoa(usage.VCPUs), limit(q.MaxVCPUs)})
+	table.Append([]string{"Memory (MB)", strconv.Itoa(usage.Memory), limit(q.MaxMemory)})
+	table.Append([]string{"Captures", strconv.Itoa(usage.Captures), limit(q.MaxCaptures)})
+
+	table.Render()
+}
+
+func PrintTableOfAPITokens(writer io.Writer, tokens []*v1.APITokenSpec) {
+	table := tablewriter.NewWriter(writer)
+
+	table.SetAutoWrapText(false)
+	table.SetHeader([]string{"ID", "Name", "Policies", "Created", "Expires", "Last Used"})
+
+	for _, t := range tokens {
+		policies := []string{"(full role)"}
+
+		if len(t.Policies) > 0 {
+			policies = nil
+
+			for _, p := range t.Policies {
+				policy := fmt.Sprintf("%s %s", strings.Join(p.Verbs, ","), strings.Join(p.Resources, ","))
+
+				if len(p.ResourceNames) > 0 {
+					policy += " " + strings.Join(p.ResourceNames, ",")
+				}
+
+				policies = append(policies, policy)
+			}
+		}
+
+		var used string
+
+		if t.LastUsed != "" {
+			used = fmt.Sprintf("%s (%s)", t.LastUsed, t.LastUsedFrom)
+		}
+
+		table.Append([]string{t.ID, t.Name, strings.Join(policies, "\n"), t.Created, t.Expires, used})
+	}
+
+	table.Render()
+}
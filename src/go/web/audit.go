@@ -0,0 +1,106 @@
+package web
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"phenix/api/audit"
+	"phenix/util/plog"
+	"phenix/web/rbac"
+	"phenix/web/util"
+	"phenix/web/weberror"
+)
+
+// GET /audit?user={user}&experiment={exp}&resource={resource}&outcome={outcome}&since={RFC3339}&until={RFC3339}&limit={n}
+func GetAudit(w http.ResponseWriter, r *http.Request) error {
+	plog.Debug("HTTP handler called", "handler", "GetAudit")
+
+	var (
+		ctx  = r.Context()
+		role = ctx.Value("role").(rbac.Role)
+	)
+
+	if !role.Allowed("audit", "list") {
+		err := weberror.NewWebError(nil, "listing audit entries not allowed for %s", ctx.Value("user").(string))
+		return err.SetStatus(http.StatusForbidden)
+	}
+
+	opts, err := auditOptions(r)
+	if err != nil {
+		return weberror.NewWebError(err, "invalid audit query provided")
+	}
+
+	entries, err := audit.List(opts...)
+	if err != nil {
+		err := weberror.NewWebError(err, "unable to get audit entries")
+		return err.SetStatus(http.StatusInternalServerError)
+	}
+
+	body, err := json.Marshal(util.WithRoot("audit", entries))
+	if err != nil {
+		err := weberror.NewWebError(err, "unable to process audit entries")
+		return err.SetStatus(http.StatusInternalServerError)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(body)
+
+	return nil
+}
+
+// auditOptions converts the query parameters of the given request to audit
+// list options.
+func auditOptions(r *http.Request) ([]audit.Option, error) {
+	var (
+		params = r.URL.Query()
+		opts   []audit.Option
+	)
+
+	if user := params.Get("user"); user != "" {
+		opts = append(opts, audit.User(user))
+	}
+
+	if exp := params.Get("experiment"); exp != "" {
+		opts = append(opts, audit.Experiment(exp))
+	}
+
+	if resource := params.Get("resource"); resource != "" {
+		opts = append(opts, audit.Resource(resource))
+	}
+
+	if outcome := params.Get("outcome"); outcome != "" {
+		opts = append(opts, audit.Outcome(outcome))
+	}
+
+	if since := params.Get("since"); since != "" {
+		t, err := time.Parse(time.RFC3339, since)
+		if err != nil {
+			return nil, fmt.Errorf("parsing since parameter: %w", err)
+		}
+
+		opts = append(opts, audit.Since(t))
+	}
+
+	if until := params.Get("until"); until != "" {
+		t, err := time.Parse(time.RFC3339, until)
+		if err != nil {
+			return nil, fmt.Errorf("parsing until parameter: %w", err)
+		}
+
+		opts = append(opts, audit.Until(t))
+	}
+
+	if limit := params.Get("limit"); limit != "" {
+		l, err := strconv.Atoi(limit)
+		if err != nil {
+			return nil, fmt.Errorf("parsing limit parameter: %w", err)
+		}
+
+		opts = append(opts, audit.Limit(l))
+	}
+
+	return opts, nil
+}
@@ -2,12 +2,14 @@ package web
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"strconv"
 	"time"
 
+	v1 "phenix/types/version/v1"
 	"phenix/util/plog"
 	"phenix/web/rbac"
 	"phenix/web/util"
@@ -329,40 +331,153 @@ func CreateUserToken(w http.ResponseWriter, r *http.Request) {
 		dur = time.Duration(days) * 24 * time.Hour
 	}
 
-	exp := time.Now().Add(dur)
+	if dur <= 0 {
+		http.Error(w, "token lifetime must be positive", http.StatusBadRequest)
+		return
+	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
-		"sub": u.Username(),
-		"exp": exp.Unix(),
-	})
+	name := req.Name
+	if name == "" {
+		name = req.Description
+	}
 
-	// Sign and get the complete encoded token as a string using the secret
-	signed, err := token.SignedString([]byte(o.jwtKey))
-	if err != nil {
-		http.Error(w, "failed to sign JWT", http.StatusInternalServerError)
-		return
+	if name == "" {
+		name = fmt.Sprintf("manually generated - %s", time.Now().Format(time.RFC3339))
 	}
 
-	note := fmt.Sprintf("manually generated - %s", time.Now().Format(time.RFC3339))
-	if desc := req.Description; desc != "" {
-		note = req.Description
+	policies := make([]*v1.PolicySpec, len(req.Policies))
+	for i, p := range req.Policies {
+		policies[i] = &v1.PolicySpec{
+			Resources:     p.Resources,
+			ResourceNames: p.ResourceNames,
+			Verbs:         p.Verbs,
+		}
 	}
 
-	if err := u.AddToken(signed, note); err != nil {
-		http.Error(w, "", http.StatusInternalServerError)
+	signed, token, err := u.CreateAPIToken(o.jwtKey, name, dur, &role, policies...)
+	if err != nil {
+		if errors.Is(err, rbac.ErrTokenScope) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		plog.Error("creating API token", "user", uname, "err", err)
+		http.Error(w, "unable to create token", http.StatusInternalServerError)
 		return
 	}
 
 	resp := CreateTokenResponse{
+		APIToken:    apiTokenFromRBAC(token),
 		Token:       signed,
-		Description: note,
-		Expiration:  exp.Format(time.RFC3339),
+		Description: token.Name,
+		Expiration:  token.Expires,
 	}
 
 	body, _ = json.Marshal(resp)
 	w.Write(body)
 }
 
+// GET /users/{username}/tokens
+func GetUserTokens(w http.ResponseWriter, r *http.Request) {
+	plog.Debug("HTTP handler called", "handler", "GetUserTokens")
+
+	var (
+		ctx   = r.Context()
+		role  = ctx.Value("role").(rbac.Role)
+		vars  = mux.Vars(r)
+		uname = vars["username"]
+	)
+
+	if !role.Allowed("users", "get", uname) {
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return
+	}
+
+	u, err := rbac.GetUser(uname)
+	if err != nil {
+		http.Error(w, "unable to get user", http.StatusNotFound)
+		return
+	}
+
+	tokens := make([]APIToken, len(u.Spec.APITokens))
+	for i, t := range u.Spec.APITokens {
+		tokens[i] = apiTokenFromRBAC(t)
+	}
+
+	body, _ := json.Marshal(util.WithRoot("tokens", tokens))
+	w.Write(body)
+}
+
+// GET /users/{username}/tokens/{id}
+func GetUserToken(w http.ResponseWriter, r *http.Request) {
+	plog.Debug("HTTP handler called", "handler", "GetUserToken")
+
+	var (
+		ctx   = r.Context()
+		role  = ctx.Value("role").(rbac.Role)
+		vars  = mux.Vars(r)
+		uname = vars["username"]
+		id    = vars["id"]
+	)
+
+	if !role.Allowed("users", "get", uname) {
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return
+	}
+
+	u, err := rbac.GetUser(uname)
+	if err != nil {
+		http.Error(w, "unable to get user", http.StatusNotFound)
+		return
+	}
+
+	token, err := u.APIToken(id)
+	if err != nil {
+		http.Error(w, "token not found", http.StatusNotFound)
+		return
+	}
+
+	body, _ := json.Marshal(apiTokenFromRBAC(token))
+	w.Write(body)
+}
+
+// DELETE /users/{username}/tokens/{id}
+func DeleteUserToken(w http.ResponseWriter, r *http.Request) {
+	plog.Debug("HTTP handler called", "handler", "DeleteUserToken")
+
+	var (
+		ctx   = r.Context()
+		role  = ctx.Value("role").(rbac.Role)
+		vars  = mux.Vars(r)
+		uname = vars["username"]
+		id    = vars["id"]
+	)
+
+	if !role.Allowed("users", "patch", uname) {
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return
+	}
+
+	u, err := rbac.GetUser(uname)
+	if err != nil {
+		http.Error(w, "unable to get user", http.StatusNotFound)
+		return
+	}
+
+	if err := u.RevokeAPIToken(id); err != nil {
+		if errors.Is(err, rbac.ErrTokenNotFound) {
+			http.Error(w, "token not found", http.StatusNotFound)
+			return
+		}
+
+		plog.Error("revoking API token", "user", uname, "token", id, "err", err)
+		http.Error(w, "unable to revoke token", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
 // GET /roles
 func GetRoles(w http.ResponseWriter, r *http.Request) {
 	plog.Debug("ListRoles HTTP handler called")
@@ -5,11 +5,14 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"reflect"
 	"strings"
 
 	"phenix/api/vm"
 	"phenix/app"
+	"phenix/store"
 	"phenix/util/pubsub"
+	"phenix/web/rbac"
 	"phenix/web/util"
 
 	putil "phenix/util"
@@ -21,14 +24,51 @@ var (
 	broadcast  = make(chan bt.Publish, 1024)
 	register   = make(chan *Client, 1024)
 	unregister = make(chan *Client, 1024)
+
+	subscribers = make(map[*subscriber]bool)
+	subscribe   = make(chan *subscriber, 1024)
+	unsubscribe = make(chan *subscriber, 1024)
 )
 
+// subscriber receives publications outside of a WebSocket connection (e.g., a
+// gRPC stream).
+type subscriber struct {
+	role    rbac.Role
+	publish chan bt.Publish
+}
+
+// Subscribe returns a channel that receives all the publications broadcast to
+// clients that the given role is allowed to see, along with a function that
+// must be called to unsubscribe. The channel is closed when unsubscribing, or
+// if the subscriber falls too far behind on receiving publications.
+func Subscribe(role rbac.Role) (<-chan bt.Publish, func()) {
+	sub := &subscriber{role: role, publish: make(chan bt.Publish, 256)}
+
+	subscribe <- sub
+
+	return sub.publish, func() { unsubscribe <- sub }
+}
+
 func Start() {
 	triggerSub := pubsub.Subscribe("trigger-app")
 	delayedSub := pubsub.Subscribe("delayed-start")
 
+	// Watch the store for config changes so changes made outside of the web
+	// server (e.g., via the CLI) are pushed to clients too.
+	configs, cancel := store.Watch()
+	defer cancel()
+
 	for {
 		select {
+		case event, ok := <-configs:
+			if !ok {
+				configs = nil // stop selecting on the closed channel
+				continue
+			}
+
+			if pub := configPublication(event); pub != nil {
+				broadcast <- *pub
+			}
 		case pub := <-triggerSub:
 			var (
 				trigger = pub.(app.TriggerPublication)
@@ -92,22 +132,16 @@ func Start() {
 				cli.Stop()
 				delete(clients, cli)
 			}
+		case sub := <-subscribe:
+			subscribers[sub] = true
+		case sub := <-unsubscribe:
+			if _, ok := subscribers[sub]; ok {
+				close(sub.publish)
+				delete(subscribers, sub)
+			}
 		case pub := <-broadcast:
 			for cli := range clients {
-				var (
-					policy = pub.RequestPolicy
-					allow  bool
-				)
-
-				if policy == nil {
-					allow = true
-				} else if policy.ResourceName == "" {
-					allow = cli.role.Allowed(policy.Resource, policy.Verb)
-				} else {
-					allow = cli.role.Allowed(policy.Resource, policy.Verb, policy.ResourceName)
-				}
-
-				if allow {
+				if allowed(cli.role, pub.RequestPolicy) {
 					select {
 					case cli.publish <- pub:
 					default:
@@ -116,8 +150,67 @@ func Start() {
 					}
 				}
 			}
+
+			for sub := range subscribers {
+				if allowed(sub.role, pub.RequestPolicy) {
+					select {
+					case sub.publish <- pub:
+					default:
+						close(sub.publish)
+						delete(subscribers, sub)
+					}
+				}
+			}
+		}
+	}
+}
+
+func allowed(role rbac.Role, policy *bt.RequestPolicy) bool {
+	if policy == nil {
+		return true
+	}
+
+	if policy.ResourceName == "" {
+		return role.Allowed(policy.Resource, policy.Verb)
+	}
+
+	return role.Allowed(policy.Resource, policy.Verb, policy.ResourceName)
+}
+
+// configPublication converts the given config event into a publication for
+// clients. Updates that only modify a config's status (like experiment status
+// updates made by apps) are not published since they're published separately as
+// experiment updates.
+func configPublication(event store.ConfigEvent) *bt.Publish {
+	c := event.Config
+
+	if event.Type == store.ConfigEventUpdate && event.Previous != nil {
+		prev := event.Previous
+
+		if c.Metadata.Name == prev.Metadata.Name && reflect.DeepEqual(c.Metadata.Annotations, prev.Metadata.Annotations) && reflect.DeepEqual(c.Spec, prev.Spec) {
+			return nil
 		}
 	}
+
+	var body []byte
+
+	if event.Type != store.ConfigEventDelete {
+		c.Spec = nil
+		c.Status = nil
+
+		var err error
+
+		body, err = json.Marshal(c)
+		if err != nil {
+			return nil
+		}
+	}
+
+	return &bt.Publish{
+		RequestPolicy: bt.NewRequestPolicy("configs", "list", c.FullName()),
+		Resource:      bt.NewResource("config", c.FullName(), string(event.Type)),
+		Result:        body,
+	}
 }
 
 func Broadcast(policy *bt.RequestPolicy, resource *bt.Resource, msg json.RawMessage) {
@@ -10,6 +10,7 @@ import (
 	"sync"
 	"time"
 
+	"phenix/api/audit"
 	"phenix/api/experiment"
 	"phenix/api/vm"
 	"phenix/util/cache"
@@ -28,6 +29,10 @@ import (
 
 var marshaler = protojson.MarshalOptions{EmitUnpopulated: true}
 
+// readOnlyActions are the WebSocket request resource actions that don't
+// change anything and therefore aren't audited.
+var readOnlyActions = map[string]struct{}{"list": {}, "search": {}}
+
 type vmScope struct {
 	exp  string
 	name string
@@ -49,6 +54,7 @@ var (
 )
 
 type Client struct {
+	user   string
 	role   rbac.Role
 	conn   *websocket.Conn
 	connMu sync.Mutex
@@ -64,8 +70,9 @@ type Client struct {
 	vmMu sync.RWMutex
 }
 
-func NewClient(role rbac.Role, conn *websocket.Conn) *Client {
+func NewClient(user string, role rbac.Role, conn *websocket.Conn) *Client {
 	return &Client{
+		user:    user,
 		role:    role,
 		conn:    conn,
 		publish: make(chan interface{}, 256),
@@ -140,6 +147,12 @@ func (this *Client) read() {
 				continue
 			}
 
+			// All supported WebSocket requests are read-only, so anything else is
+			// rejected below. Audit such requests so attempts are still recorded.
+			if _, ok := readOnlyActions[req.Resource.Action]; !ok {
+				this.audit(req, msg)
+			}
+
 			switch req.Resource.Type {
 			case "experiment/vms":
 			case "experiment/topology":
@@ -502,6 +515,27 @@ func (this *Client) screenshots() {
 	}
 }
 
+func (this *Client) audit(req bt.Request, msg []byte) {
+	entry := audit.Entry{
+		Origin:       audit.OriginBroker,
+		User:         this.user,
+		Role:         this.role.Spec.Name,
+		Verb:         req.Resource.Action,
+		Resource:     req.Resource.Type,
+		ResourceName: req.Resource.Name,
+		Digest:       audit.Digest(msg),
+		Outcome:      audit.OutcomeFailure,
+	}
+
+	if strings.HasPrefix(req.Resource.Type, "experiment") {
+		entry.Experiment, _, _ = strings.Cut(req.Resource.Name, "/")
+	}
+
+	if err := audit.Record(entry); err != nil {
+		plog.Error("recording audit entry", "user", this.user, "err", err)
+	}
+}
+
 func ServeWS(w http.ResponseWriter, r *http.Request) {
 	upgrader.CheckOrigin = func(*http.Request) bool { return true }
 
@@ -511,7 +545,10 @@ func ServeWS(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	role := r.Context().Value("role").(rbac.Role)
+	var (
+		user, _ = r.Context().Value("user").(string)
+		role    = r.Context().Value("role").(rbac.Role)
+	)
 
-	NewClient(role, conn).Go()
+	NewClient(user, role, conn).Go()
 }
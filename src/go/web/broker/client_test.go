@@ -0,0 +1,108 @@
+package broker
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"phenix/api/audit"
+	"phenix/store"
+	v1 "phenix/types/version/v1"
+	bt "phenix/web/broker/brokertypes"
+	"phenix/web/rbac"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestClientAudit(t *testing.T) {
+	store.DefaultStore = store.NewBoltDB()
+
+	if err := store.DefaultStore.Init(store.Endpoint("bolt://" + filepath.Join(t.TempDir(), "phenix.bdb"))); err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+
+	role := rbac.Role{Spec: &v1.RoleSpec{Name: "Experiment User"}}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+
+		NewClient("foo@bar.com", role, conn).Go()
+	}))
+
+	defer server.Close()
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	if err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+
+	defer conn.Close()
+
+	requests := []bt.Request{
+		{Resource: bt.NewResource("experiment/vms", "foo", "list")}, // read-only
+		{Resource: bt.NewResource("experiment/vm", "foo/bar", "delete")},
+	}
+
+	for _, req := range requests {
+		if err := conn.WriteJSON(req); err != nil {
+			t.Log(err)
+			t.FailNow()
+		}
+	}
+
+	var entries audit.Entries
+
+	// Requests are read and audited asynchronously.
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		entries, err = audit.List()
+		if err != nil {
+			t.Log(err)
+			t.FailNow()
+		}
+
+		if len(entries) > 0 {
+			break
+		}
+	}
+
+	if len(entries) != 1 {
+		t.Logf("expected only the delete request to be audited, got %+v", entries)
+		t.FailNow()
+	}
+
+	expected := audit.Entry{
+		Origin:       audit.OriginBroker,
+		User:         "foo@bar.com",
+		Role:         "Experiment User",
+		Verb:         "delete",
+		Resource:     "experiment/vm",
+		ResourceName: "foo/bar",
+		Experiment:   "foo",
+		Outcome:      audit.OutcomeFailure,
+	}
+
+	entry := entries[0]
+
+	if entry.Digest == "" {
+		t.Log("expected audit entry to include digest of request")
+		t.FailNow()
+	}
+
+	entry.ID = ""
+	entry.Timestamp = time.Time{}
+	entry.Digest = ""
+	entry.Duration = 0
+
+	if entry != expected {
+		t.Logf("expected audit entry %+v, got %+v", expected, entry)
+		t.FailNow()
+	}
+}
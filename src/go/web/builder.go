@@ -91,8 +91,7 @@ func CreateExperimentFromBuilder(w http.ResponseWriter, r *http.Request) error {
 	topo.Metadata.Annotations = store.Annotations{"builder-xml": req.XML}
 	topo.Spec = req.Topology
 
-	config, err := config.Create(config.CreateFromConfig(topo), config.CreateWithValidation())
-	if err != nil {
+	if _, err := config.Create(config.CreateFromConfig(topo), config.CreateWithValidation()); err != nil {
 		if errors.Is(err, store.ErrExist) {
 			return weberror.NewWebError(err, "topology with same name already exists").WithMetadata("type", "topology", true)
 		}
@@ -107,23 +106,6 @@ func CreateExperimentFromBuilder(w http.ResponseWriter, r *http.Request) error {
 		return weberror.NewWebError(err, "unable to create new topology").WithMetadata("type", "topology", true)
 	}
 
-	// publish new topology
-
-	config.Spec = nil
-	config.Status = nil
-
-	body, err = json.Marshal(config)
-	if err != nil {
-		err := weberror.NewWebError(err, "marshaling topology %s", req.Name)
-		return err.SetStatus(http.StatusInternalServerError)
-	}
-
-	broker.Broadcast(
-		bt.NewRequestPolicy("configs", "list", config.FullName()),
-		bt.NewResource("config", config.FullName(), "create"),
-		body,
-	)
-
 	if err := cache.LockExperimentForCreation(req.Name); err != nil {
 		err := weberror.NewWebError(err, "locking experiment for creation")
 		return err.SetStatus(http.StatusConflict)
@@ -158,6 +140,7 @@ func CreateExperimentFromBuilder(w http.ResponseWriter, r *http.Request) error {
 		experiment.CreateWithTopology(req.Name),
 		experiment.CreateWithScenario(req.Scenario),
 		experiment.CreateWithVLANAliases(req.VLANs),
+		experiment.CreateWithOwner(ctx.Value("user").(string)),
 	}
 
 	if err := experiment.Create(ctx, opts...); err != nil {
@@ -189,17 +172,6 @@ func CreateExperimentFromBuilder(w http.ResponseWriter, r *http.Request) error {
 		return err.SetStatus(http.StatusInternalServerError)
 	}
 
-	config, _ = store.NewConfig("experiment/" + req.Name)
-	config.Metadata = exp.Metadata
-
-	body, _ = json.Marshal(config)
-
-	broker.Broadcast(
-		bt.NewRequestPolicy("configs", "list", config.FullName()),
-		bt.NewResource("config", config.FullName(), "create"),
-		body,
-	)
-
 	vms, _ := vm.List(req.Name)
 
 	body, err = marshaler.Marshal(util.ExperimentToProtobuf(*exp, "", vms))
@@ -272,23 +244,6 @@ func UpdateExperimentFromBuilder(w http.ResponseWriter, r *http.Request) error {
 		return err.SetStatus(http.StatusInternalServerError)
 	}
 
-	// publish updated topology
-
-	topo.Spec = nil
-	topo.Status = nil
-
-	body, err = json.Marshal(topo)
-	if err != nil {
-		err := weberror.NewWebError(err, "marshaling topology %s", req.Name)
-		return err.SetStatus(http.StatusInternalServerError)
-	}
-
-	broker.Broadcast(
-		bt.NewRequestPolicy("configs", "list", topo.FullName()),
-		bt.NewResource("config", topo.FullName(), "update"),
-		body,
-	)
-
 	// Create or update experiment using updated topology. It's possible that the
 	// topology already existed (so it's being updated), but an experiment with
 	// the same name doesn't exist yet (e.g., they created just the topology the
@@ -375,6 +330,7 @@ func UpdateExperimentFromBuilder(w http.ResponseWriter, r *http.Request) error {
 			experiment.CreateWithTopology(req.Name),
 			experiment.CreateWithScenario(req.Scenario),
 			experiment.CreateWithVLANAliases(req.VLANs),
+			experiment.CreateWithOwner(ctx.Value("user").(string)),
 		}
 
 		if err := experiment.Create(ctx, opts...); err != nil {
@@ -407,22 +363,11 @@ func UpdateExperimentFromBuilder(w http.ResponseWriter, r *http.Request) error {
 		return err.SetStatus(http.StatusInternalServerError)
 	}
 
-	config, _ := store.NewConfig("experiment/" + req.Name)
-	config.Metadata = exp.Metadata
-
-	body, _ = json.Marshal(config)
-
 	action := "create"
 	if exists {
 		action = "update"
 	}
 
-	broker.Broadcast(
-		bt.NewRequestPolicy("configs", "list", config.FullName()),
-		bt.NewResource("config", config.FullName(), action),
-		body,
-	)
-
 	vms, _ := vm.List(req.Name)
 
 	body, err = marshaler.Marshal(util.ExperimentToProtobuf(*exp, "", vms))
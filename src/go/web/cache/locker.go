@@ -79,6 +79,26 @@ func LockExperimentForStopping(name string) error {
 	return nil
 }
 
+func LockExperimentForSnapshotting(name string) error {
+	key := "experiment|" + name
+
+	if status := Lock(key, StatusSnapshotting, 30*time.Minute); status != "" {
+		return fmt.Errorf("experiment %s is locked with status %s", name, status)
+	}
+
+	return nil
+}
+
+func LockExperimentForRestoring(name string) error {
+	key := "experiment|" + name
+
+	if status := Lock(key, StatusRestoring, 30*time.Minute); status != "" {
+		return fmt.Errorf("experiment %s is locked with status %s", name, status)
+	}
+
+	return nil
+}
+
 func LockVMForStarting(exp, name string) error {
 	key := fmt.Sprintf("vm|%s/%s", exp, name)
 
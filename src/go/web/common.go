@@ -10,6 +10,7 @@ import (
 	"time"
 
 	"phenix/api/experiment"
+	"phenix/api/quota"
 	"phenix/api/vm"
 	"phenix/app"
 	"phenix/types"
@@ -30,7 +31,7 @@ var (
 	waiters   = make(map[string]*sync.WaitGroup)
 )
 
-func startExperiment(name string) ([]byte, error) {
+func startExperiment(name, user string) ([]byte, error) {
 	if err := cache.LockExperimentForStarting(name); err != nil {
 		err := weberror.NewWebError(err, "unable to lock experiment %s for starting", name)
 		return nil, err.SetStatus(http.StatusConflict)
@@ -60,7 +61,7 @@ func startExperiment(name string) ([]byte, error) {
 
 		ch := make(chan error)
 
-		if err := experiment.Start(ctx, experiment.StartWithName(name), experiment.StartWithErrorChannel(ch)); err != nil {
+		if err := experiment.Start(ctx, experiment.StartWithName(name), experiment.StartWithErrorChannel(ch), experiment.StartWithUser(user)); err != nil {
 			cancel() // avoid leakage
 			delete(cancelers, name)
 
@@ -128,6 +129,11 @@ func startExperiment(name string) ([]byte, error) {
 				)
 
 				err := weberror.NewWebError(s.err, "unable to start experiment %s", name)
+
+				if errors.Is(s.err, quota.ErrQuotaExceeded) {
+					return nil, err.SetStatus(http.StatusForbidden)
+				}
+
 				return nil, err.SetStatus(http.StatusBadRequest)
 			}
 
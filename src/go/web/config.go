@@ -9,6 +9,7 @@ import (
 	"io"
 	"net/http"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"time"
 
@@ -18,13 +19,10 @@ import (
 	"phenix/types"
 	"phenix/types/version"
 	"phenix/util/plog"
-	"phenix/web/broker"
 	"phenix/web/rbac"
 	"phenix/web/util"
 	"phenix/web/weberror"
 
-	bt "phenix/web/broker/brokertypes"
-
 	"github.com/gorilla/mux"
 	"gopkg.in/yaml.v3"
 )
@@ -206,7 +204,7 @@ func CreateConfig(w http.ResponseWriter, r *http.Request) error {
 
 	var (
 		typ  = r.Header.Get("Content-Type")
-		opts = []config.CreateOption{config.CreateWithValidation()}
+		opts = []config.CreateOption{config.CreateWithValidation(), config.CreateWithOwner(ctx.Value("user").(string))}
 	)
 
 	switch {
@@ -289,20 +287,7 @@ func CreateConfig(w http.ResponseWriter, r *http.Request) error {
 	w.Header().Set("Location", strings.ToLower(fmt.Sprintf("/api/v1/configs/%s/%s", c.Kind, c.Metadata.Name)))
 	w.WriteHeader(http.StatusCreated)
 
-	c.Spec = nil
-	c.Status = nil
-
-	body, err := json.Marshal(c)
-	if err != nil {
-		plog.Error("marshaling config", "config", c.FullName(), "err", err)
-		return nil
-	}
-
-	broker.Broadcast(
-		bt.NewRequestPolicy("configs", "list", c.FullName()),
-		bt.NewResource("config", c.FullName(), "create"),
-		body,
-	)
+	// The config creation is published to clients by the broker's store watcher.
 
 	return nil
 }
@@ -470,6 +455,15 @@ func UpdateConfig(w http.ResponseWriter, r *http.Request) error {
 			return weberror.NewWebError(err, "config to update (%s) does not exist", name)
 		}
 
+		if errors.Is(err, store.ErrConflict) {
+			return weberror.NewWebError(err, "config %s was modified while being updated", name).SetStatus(http.StatusConflict)
+		}
+
+		if errors.Is(err, config.ErrProtectedAnnotation) {
+			err := weberror.NewWebError(err, "experiment ownership and shares cannot be changed by updating config %s", name)
+			return err.SetStatus(http.StatusForbidden)
+		}
+
 		if errors.Is(err, types.ErrValidationFailed) {
 			cause := errors.Unwrap(err)
 			lines := strings.Split(cause.Error(), "\n")
@@ -494,21 +488,243 @@ func UpdateConfig(w http.ResponseWriter, r *http.Request) error {
 	w.Header().Set("Location", strings.ToLower(fmt.Sprintf("/api/v1/configs/%s/%s", c.Kind, c.Metadata.Name)))
 	w.WriteHeader(http.StatusNoContent)
 
-	c.Spec = nil
-	c.Status = nil
+	// The config update is published to clients by the broker's store watcher.
 
-	body, err := json.Marshal(c)
+	return nil
+}
+
+// PATCH /configs/{kind}/{name}
+func PatchConfig(w http.ResponseWriter, r *http.Request) error {
+	plog.Debug("HTTP handler called", "handler", "PatchConfig")
+
+	var (
+		ctx  = r.Context()
+		role = ctx.Value("role").(rbac.Role)
+		vars = mux.Vars(r)
+		name = store.ConfigFullName(vars["kind"], vars["name"])
+	)
+
+	if !role.Allowed("configs", "patch", name) {
+		err := weberror.NewWebError(nil, "patching config %s not allowed for %s", name, ctx.Value("user").(string))
+		return err.SetStatus(http.StatusForbidden)
+	}
+
+	var typ store.PatchType
+
+	switch ct := r.Header.Get("Content-Type"); ct {
+	case "application/merge-patch+json":
+		typ = store.PatchTypeMerge
+	case "application/json-patch+json":
+		typ = store.PatchTypeJSON
+	default:
+		err := weberror.NewWebError(nil, "unknown content type provided when patching config: %s", ct)
+		return err.SetStatus(http.StatusUnsupportedMediaType)
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		err := weberror.NewWebError(err, "unable to parse request")
+		return err.SetStatus(http.StatusInternalServerError)
+	}
+
+	c, err := config.Patch(name, body, typ)
+	if err != nil {
+		if errors.Is(err, store.ErrNotExist) {
+			return weberror.NewWebError(err, "config to patch (%s) does not exist", name).SetStatus(http.StatusNotFound)
+		}
+
+		if errors.Is(err, store.ErrConflict) {
+			return weberror.NewWebError(err, "config %s was modified while being patched", name).SetStatus(http.StatusConflict)
+		}
+
+		if errors.Is(err, types.ErrValidationFailed) {
+			cause := errors.Unwrap(err)
+			lines := strings.Split(cause.Error(), "\n")
+
+			return weberror.NewWebError(cause, lines[0]).WithMetadata("validation", cause.Error(), true)
+		}
+
+		return weberror.NewWebError(err, "unable to patch config %s", name).SetStatus(http.StatusBadRequest)
+	}
+
+	if c.Kind == "Experiment" {
+		if err := experiment.Reconfigure(c.Metadata.Name); err != nil {
+			return weberror.NewWebError(err, "unable to reconfigure patched experiment %s", c.Metadata.Name)
+		}
+
+		// Clear experiment name... not applicable to end users.
+		delete(c.Spec, "experimentName")
+	}
+
+	body, err = json.Marshal(c)
+	if err != nil {
+		err := weberror.NewWebError(err, "unable to process config %s", name)
+		return err.SetStatus(http.StatusInternalServerError)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(body)
+
+	// The config update is published to clients by the broker's store watcher.
+
+	return nil
+}
+
+// GET /configs/{kind}/{name}/revisions
+func GetConfigRevisions(w http.ResponseWriter, r *http.Request) error {
+	plog.Debug("HTTP handler called", "handler", "GetConfigRevisions")
+
+	var (
+		ctx  = r.Context()
+		role = ctx.Value("role").(rbac.Role)
+		vars = mux.Vars(r)
+		name = store.ConfigFullName(vars["kind"], vars["name"])
+	)
+
+	if !role.Allowed("configs", "get", name) {
+		err := weberror.NewWebError(nil, "getting config %s not allowed for %s", name, ctx.Value("user").(string))
+		return err.SetStatus(http.StatusForbidden)
+	}
+
+	history, err := config.History(name)
+	if err != nil {
+		return weberror.NewWebError(err, "unable to get revision history for config %s", name)
+	}
+
+	body, err := json.Marshal(map[string]any{"revisions": history})
+	if err != nil {
+		err := weberror.NewWebError(err, "unable to process revision history for config %s", name)
+		return err.SetStatus(http.StatusInternalServerError)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(body)
+
+	return nil
+}
+
+// GET /configs/{kind}/{name}/revisions/{revision}
+func GetConfigRevision(w http.ResponseWriter, r *http.Request) error {
+	plog.Debug("HTTP handler called", "handler", "GetConfigRevision")
+
+	var (
+		ctx  = r.Context()
+		role = ctx.Value("role").(rbac.Role)
+		vars = mux.Vars(r)
+		name = store.ConfigFullName(vars["kind"], vars["name"])
+	)
+
+	if !role.Allowed("configs", "get", name) {
+		err := weberror.NewWebError(nil, "getting config %s not allowed for %s", name, ctx.Value("user").(string))
+		return err.SetStatus(http.StatusForbidden)
+	}
+
+	rev, err := strconv.ParseInt(vars["revision"], 10, 64)
 	if err != nil {
-		plog.Error("marshaling config", "config", c.FullName(), "err", err)
+		err := weberror.NewWebError(err, "invalid revision %s for config %s", vars["revision"], name)
+		return err.SetStatus(http.StatusBadRequest)
+	}
+
+	// If another revision to compare against is provided, respond with a unified
+	// diff of the two revisions instead of the revision itself.
+	if other := r.URL.Query().Get("diff"); other != "" {
+		to, err := strconv.ParseInt(other, 10, 64)
+		if err != nil {
+			err := weberror.NewWebError(err, "invalid revision %s for config %s", other, name)
+			return err.SetStatus(http.StatusBadRequest)
+		}
+
+		diff, err := config.Diff(name, rev, to)
+		if err != nil {
+			if errors.Is(err, config.ErrRevisionNotExist) {
+				return weberror.NewWebError(err, "unable to diff revisions of config %s", name).SetStatus(http.StatusNotFound)
+			}
+
+			return weberror.NewWebError(err, "unable to diff revisions of config %s", name)
+		}
+
+		w.Header().Set("Content-Type", "text/x-diff")
+		w.Write([]byte(diff))
+
 		return nil
 	}
 
-	broker.Broadcast(
-		bt.NewRequestPolicy("configs", "list", c.FullName()),
-		bt.NewResource("config", name, "update"), // use old name in broadcast so client knows what to update
-		body,
+	cfg, err := config.Revision(name, rev)
+	if err != nil {
+		if errors.Is(err, config.ErrRevisionNotExist) {
+			return weberror.NewWebError(err, "unable to get revision %d of config %s", rev, name).SetStatus(http.StatusNotFound)
+		}
+
+		return weberror.NewWebError(err, "unable to get revision %d of config %s", rev, name)
+	}
+
+	body, err := json.Marshal(cfg)
+	if err != nil {
+		err := weberror.NewWebError(err, "unable to process revision %d of config %s", rev, name)
+		return err.SetStatus(http.StatusInternalServerError)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(body)
+
+	return nil
+}
+
+// POST /configs/{kind}/{name}/revisions/{revision}
+func RollbackConfig(w http.ResponseWriter, r *http.Request) error {
+	plog.Debug("HTTP handler called", "handler", "RollbackConfig")
+
+	var (
+		ctx  = r.Context()
+		role = ctx.Value("role").(rbac.Role)
+		vars = mux.Vars(r)
+		name = store.ConfigFullName(vars["kind"], vars["name"])
 	)
 
+	if !role.Allowed("configs", "update", name) {
+		err := weberror.NewWebError(nil, "updating config %s not allowed for %s", name, ctx.Value("user").(string))
+		return err.SetStatus(http.StatusForbidden)
+	}
+
+	rev, err := strconv.ParseInt(vars["revision"], 10, 64)
+	if err != nil {
+		err := weberror.NewWebError(err, "invalid revision %s for config %s", vars["revision"], name)
+		return err.SetStatus(http.StatusBadRequest)
+	}
+
+	c, err := config.Rollback(name, rev)
+	if err != nil {
+		if errors.Is(err, config.ErrRevisionNotExist) {
+			return weberror.NewWebError(err, "unable to rollback config %s", name).SetStatus(http.StatusNotFound)
+		}
+
+		if errors.Is(err, types.ErrValidationFailed) {
+			cause := errors.Unwrap(err)
+			lines := strings.Split(cause.Error(), "\n")
+
+			return weberror.NewWebError(cause, lines[0]).WithMetadata("validation", cause.Error(), true)
+		}
+
+		return weberror.NewWebError(err, "unable to rollback config %s", name)
+	}
+
+	if c.Kind == "Experiment" {
+		if err := experiment.Reconfigure(c.Metadata.Name); err != nil {
+			return weberror.NewWebError(err, "unable to reconfigure rolled back experiment %s", c.Metadata.Name)
+		}
+	}
+
+	body, err := json.Marshal(c)
+	if err != nil {
+		err := weberror.NewWebError(err, "unable to process config %s", name)
+		return err.SetStatus(http.StatusInternalServerError)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(body)
+
+	// The config update is published to clients by the broker's store watcher.
+
 	return nil
 }
 
@@ -534,11 +750,7 @@ func DeleteConfig(w http.ResponseWriter, r *http.Request) error {
 
 	w.WriteHeader(http.StatusNoContent)
 
-	broker.Broadcast(
-		bt.NewRequestPolicy("configs", "list", name),
-		bt.NewResource("config", name, "delete"),
-		nil,
-	)
+	// The config deletion is published to clients by the broker's store watcher.
 
 	return nil
 }
@@ -22,6 +22,7 @@ import (
 	"phenix/api/cluster"
 	"phenix/api/config"
 	"phenix/api/experiment"
+	"phenix/api/quota"
 	"phenix/api/scenario"
 	"phenix/api/vm"
 	"phenix/app"
@@ -188,6 +189,7 @@ func CreateExperiment(w http.ResponseWriter, r *http.Request) {
 		experiment.CreateWithDeployMode(deployMode),
 		experiment.CreateWithDefaultBridge(req.DefaultBridge),
 		experiment.CreateWithGREMesh(req.UseGreMesh),
+		experiment.CreateWithOwner(ctx.Value("user").(string)),
 	}
 
 	if req.WorkflowBranch != "" {
@@ -237,6 +239,308 @@ func CreateExperiment(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusNoContent)
 }
 
+// POST /experiments/{name}/clone
+func CloneExperiment(w http.ResponseWriter, r *http.Request) error {
+	plog.Debug("HTTP handler called", "handler", "CloneExperiment")
+
+	var (
+		ctx  = r.Context()
+		role = ctx.Value("role").(rbac.Role)
+		vars = mux.Vars(r)
+		name = vars["name"]
+	)
+
+	if !role.Allowed("experiments", "get", name) || !role.Allowed("experiments", "create") {
+		err := weberror.NewWebError(nil, "cloning experiment %s not allowed for %s", name, ctx.Value("user").(string))
+		return err.SetStatus(http.StatusForbidden)
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		err := weberror.NewWebError(err, "unable to parse clone request for experiment %s", name)
+		return err.SetStatus(http.StatusInternalServerError)
+	}
+
+	var req CloneExperimentRequest
+
+	if err := json.Unmarshal(body, &req); err != nil {
+		err := weberror.NewWebError(err, "unable to parse clone request for experiment %s", name)
+		return err.SetStatus(http.StatusBadRequest)
+	}
+
+	if err := cache.LockExperimentForCreation(req.Name); err != nil {
+		err := weberror.NewWebError(err, "unable to lock experiment %s for creation", req.Name)
+		return err.SetStatus(http.StatusConflict)
+	}
+
+	defer cache.UnlockExperiment(req.Name)
+
+	// The base directory isn't configurable via the API since it's removed when
+	// the experiment is deleted. It defaults to a directory named after the
+	// cloned experiment.
+	opts := []experiment.CloneOption{
+		experiment.CloneWithDefaultBridge(req.DefaultBridge),
+		experiment.CloneWithFiles(experiment.CloneFilesMode(req.Files)),
+		experiment.CloneWithOwner(ctx.Value("user").(string)),
+	}
+
+	if len(req.TopologyPatch) > 0 {
+		opts = append(opts, experiment.CloneWithTopologyPatch(req.TopologyPatch))
+	}
+
+	if len(req.ScenarioPatch) > 0 {
+		opts = append(opts, experiment.CloneWithScenarioPatch(req.ScenarioPatch))
+	}
+
+	if err := experiment.Clone(name, req.Name, opts...); err != nil {
+		return weberror.NewWebError(err, "unable to clone experiment %s", name)
+	}
+
+	exp, err := experiment.Get(req.Name)
+	if err != nil {
+		err := weberror.NewWebError(err, "unable to get experiment %s from store", req.Name)
+		return err.SetStatus(http.StatusInternalServerError)
+	}
+
+	body, err = marshaler.Marshal(util.ExperimentToProtobuf(*exp, "", nil))
+	if err != nil {
+		err := weberror.NewWebError(err, "marshaling experiment %s - %v", req.Name, err)
+		return err.SetStatus(http.StatusInternalServerError)
+	}
+
+	broker.Broadcast(
+		bt.NewRequestPolicy("experiments", "get", req.Name),
+		bt.NewResource("experiment", req.Name, "create"),
+		body,
+	)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	w.Write(body)
+
+	return nil
+}
+
+// GET /experiments/{name}/checkpoints
+func GetExperimentCheckpoints(w http.ResponseWriter, r *http.Request) error {
+	plog.Debug("HTTP handler called", "handler", "GetExperimentCheckpoints")
+
+	var (
+		ctx  = r.Context()
+		role = ctx.Value("role").(rbac.Role)
+		vars = mux.Vars(r)
+		name = vars["name"]
+	)
+
+	if !role.Allowed("experiments/checkpoints", "list", name) {
+		err := weberror.NewWebError(nil, "listing checkpoints for experiment %s not allowed for %s", name, ctx.Value("user").(string))
+		return err.SetStatus(http.StatusForbidden)
+	}
+
+	checkpoints, err := experiment.Checkpoints(name)
+	if err != nil {
+		return weberror.NewWebError(err, "unable to list checkpoints for experiment %s", name)
+	}
+
+	if checkpoints == nil {
+		checkpoints = []experiment.Checkpoint{}
+	}
+
+	body, err := json.Marshal(map[string]any{"checkpoints": checkpoints})
+	if err != nil {
+		err := weberror.NewWebError(err, "marshaling checkpoints for experiment %s - %v", name, err)
+		return err.SetStatus(http.StatusInternalServerError)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(body)
+
+	return nil
+}
+
+// POST /experiments/{name}/checkpoints
+func CreateExperimentCheckpoint(w http.ResponseWriter, r *http.Request) error {
+	plog.Debug("HTTP handler called", "handler", "CreateExperimentCheckpoint")
+
+	var (
+		ctx  = r.Context()
+		role = ctx.Value("role").(rbac.Role)
+		vars = mux.Vars(r)
+		name = vars["name"]
+	)
+
+	if !role.Allowed("experiments/checkpoints", "create", name) {
+		err := weberror.NewWebError(nil, "checkpointing experiment %s not allowed for %s", name, ctx.Value("user").(string))
+		return err.SetStatus(http.StatusForbidden)
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		err := weberror.NewWebError(err, "unable to parse checkpoint request for experiment %s", name)
+		return err.SetStatus(http.StatusInternalServerError)
+	}
+
+	var req CreateCheckpointRequest
+
+	if len(body) > 0 {
+		if err := json.Unmarshal(body, &req); err != nil {
+			err := weberror.NewWebError(err, "unable to parse checkpoint request for experiment %s", name)
+			return err.SetStatus(http.StatusBadRequest)
+		}
+	}
+
+	if err := cache.LockExperimentForSnapshotting(name); err != nil {
+		err := weberror.NewWebError(err, "unable to lock experiment %s for checkpointing", name)
+		return err.SetStatus(http.StatusConflict)
+	}
+
+	defer cache.UnlockExperiment(name)
+
+	broker.Broadcast(
+		bt.NewRequestPolicy("experiments/checkpoints", "create", name),
+		bt.NewResource("experiment/checkpoint", name, "creating"),
+		nil,
+	)
+
+	checkpoint, err := experiment.CreateCheckpoint(name, req.Name, checkpointProgress(name, "create"))
+	if err != nil {
+		broker.Broadcast(
+			bt.NewRequestPolicy("experiments/checkpoints", "create", name),
+			bt.NewResource("experiment/checkpoint", name, "errorCreating"),
+			nil,
+		)
+
+		if errors.Is(err, experiment.ErrCheckpointExists) {
+			return weberror.NewWebError(err, "unable to checkpoint experiment %s", name).SetStatus(http.StatusConflict)
+		}
+
+		return weberror.NewWebError(err, "unable to checkpoint experiment %s", name)
+	}
+
+	body, err = json.Marshal(checkpoint)
+	if err != nil {
+		err := weberror.NewWebError(err, "marshaling checkpoint %s for experiment %s - %v", checkpoint.Name, name, err)
+		return err.SetStatus(http.StatusInternalServerError)
+	}
+
+	broker.Broadcast(
+		bt.NewRequestPolicy("experiments/checkpoints", "create", name),
+		bt.NewResource("experiment/checkpoint", name+"/"+checkpoint.Name, "create"),
+		body,
+	)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	w.Write(body)
+
+	return nil
+}
+
+// POST /experiments/{name}/checkpoints/{checkpoint}
+func RestoreExperimentCheckpoint(w http.ResponseWriter, r *http.Request) error {
+	plog.Debug("HTTP handler called", "handler", "RestoreExperimentCheckpoint")
+
+	var (
+		ctx        = r.Context()
+		role       = ctx.Value("role").(rbac.Role)
+		vars       = mux.Vars(r)
+		name       = vars["name"]
+		checkpoint = vars["checkpoint"]
+	)
+
+	if !role.Allowed("experiments/checkpoints", "update", name) {
+		err := weberror.NewWebError(nil, "restoring checkpoint for experiment %s not allowed for %s", name, ctx.Value("user").(string))
+		return err.SetStatus(http.StatusForbidden)
+	}
+
+	if err := cache.LockExperimentForRestoring(name); err != nil {
+		err := weberror.NewWebError(err, "unable to lock experiment %s for restoring", name)
+		return err.SetStatus(http.StatusConflict)
+	}
+
+	defer cache.UnlockExperiment(name)
+
+	broker.Broadcast(
+		bt.NewRequestPolicy("experiments/checkpoints", "update", name),
+		bt.NewResource("experiment/checkpoint", name+"/"+checkpoint, "restoring"),
+		nil,
+	)
+
+	if err := experiment.RestoreCheckpoint(name, checkpoint, checkpointProgress(name, "update")); err != nil {
+		broker.Broadcast(
+			bt.NewRequestPolicy("experiments/checkpoints", "update", name),
+			bt.NewResource("experiment/checkpoint", name+"/"+checkpoint, "errorRestoring"),
+			nil,
+		)
+
+		if errors.Is(err, experiment.ErrCheckpointNotFound) {
+			return weberror.NewWebError(err, "unable to restore checkpoint %s for experiment %s", checkpoint, name).SetStatus(http.StatusNotFound)
+		}
+
+		return weberror.NewWebError(err, "unable to restore checkpoint %s for experiment %s", checkpoint, name)
+	}
+
+	broker.Broadcast(
+		bt.NewRequestPolicy("experiments/checkpoints", "update", name),
+		bt.NewResource("experiment/checkpoint", name+"/"+checkpoint, "restore"),
+		nil,
+	)
+
+	w.WriteHeader(http.StatusNoContent)
+
+	return nil
+}
+
+// DELETE /experiments/{name}/checkpoints/{checkpoint}
+func DeleteExperimentCheckpoint(w http.ResponseWriter, r *http.Request) error {
+	plog.Debug("HTTP handler called", "handler", "DeleteExperimentCheckpoint")
+
+	var (
+		ctx        = r.Context()
+		role       = ctx.Value("role").(rbac.Role)
+		vars       = mux.Vars(r)
+		name       = vars["name"]
+		checkpoint = vars["checkpoint"]
+	)
+
+	if !role.Allowed("experiments/checkpoints", "delete", name) {
+		err := weberror.NewWebError(nil, "deleting checkpoint for experiment %s not allowed for %s", name, ctx.Value("user").(string))
+		return err.SetStatus(http.StatusForbidden)
+	}
+
+	if err := experiment.DeleteCheckpoint(name, checkpoint); err != nil {
+		if errors.Is(err, experiment.ErrCheckpointNotFound) {
+			return weberror.NewWebError(err, "unable to delete checkpoint %s for experiment %s", checkpoint, name).SetStatus(http.StatusNotFound)
+		}
+
+		return weberror.NewWebError(err, "unable to delete checkpoint %s for experiment %s", checkpoint, name)
+	}
+
+	broker.Broadcast(
+		bt.NewRequestPolicy("experiments/checkpoints", "delete", name),
+		bt.NewResource("experiment/checkpoint", name+"/"+checkpoint, "delete"),
+		nil,
+	)
+
+	w.WriteHeader(http.StatusNoContent)
+
+	return nil
+}
+
+// checkpointProgress returns a checkpoint callback that broadcasts the progress
+// of checkpointing or restoring the given experiment.
+func checkpointProgress(exp, verb string) func(experiment.CheckpointProgress) {
+	return func(p experiment.CheckpointProgress) {
+		marshalled, _ := json.Marshal(p)
+
+		broker.Broadcast(
+			bt.NewRequestPolicy("experiments/checkpoints", verb, exp),
+			bt.NewResource("experiment/checkpoint", exp+"/"+p.Checkpoint, "progress"),
+			marshalled,
+		)
+	}
+}
+
 // PUT /experiments/{name}
 func UpdateExperiment(w http.ResponseWriter, r *http.Request) error {
 	plog.Debug("HTTP handler called", "handler", "UpdateExperiment")
@@ -277,18 +581,21 @@ func UpdateExperiment(w http.ResponseWriter, r *http.Request) error {
 		return err.SetStatus(http.StatusInternalServerError)
 	}
 
-	aliases := exp.Spec.VLANs().Aliases()
+	var (
+		existing = exp.Spec.VLANs().Aliases()
+		aliases  = make(map[string]int)
+	)
 
-	if len(vlans) > 0 {
-		for alias, id := range vlans {
-			if _, ok := aliases[alias]; ok {
-				aliases[alias] = id
-			}
+	for alias, id := range vlans {
+		if _, ok := existing[alias]; ok {
+			aliases[alias] = id
 		}
+	}
 
-		exp.Spec.VLANs().SetAliases(aliases)
+	if len(aliases) > 0 {
+		patch, _ := json.Marshal(map[string]any{"spec": map[string]any{"vlans": map[string]any{"aliases": aliases}}})
 
-		if err := exp.WriteToStore(false); err != nil {
+		if _, err := config.Patch("experiment/"+name, patch, store.PatchTypeMerge); err != nil {
 			err := weberror.NewWebError(err, "unable to write updated experiment %s", name)
 			return err.SetStatus(http.StatusInternalServerError)
 		}
@@ -455,7 +762,7 @@ func StartExperiment(w http.ResponseWriter, r *http.Request) error {
 		return err.SetStatus(http.StatusForbidden)
 	}
 
-	body, err := startExperiment(name)
+	body, err := startExperiment(name, ctx.Value("user").(string))
 	if err != nil {
 		return err
 	}
@@ -1039,6 +1346,7 @@ func UpdateVM(w http.ResponseWriter, r *http.Request) {
 		vm.UpdateWithMem(int(req.Ram)),
 		vm.UpdateWithDisk(req.Disk),
 		vm.UpdateWithPartition(int(req.InjectPartition)),
+		vm.UpdateWithUser(ctx.Value("user").(string)),
 	}
 
 	if req.Interface != nil {
@@ -1062,6 +1370,12 @@ func UpdateVM(w http.ResponseWriter, r *http.Request) {
 
 	if err := vm.Update(opts...); err != nil {
 		plog.Error("updating VM", "err", err)
+
+		if errors.Is(err, quota.ErrQuotaExceeded) {
+			http.Error(w, err.Error(), http.StatusForbidden)
+			return
+		}
+
 		http.Error(w, "unable to update VM", http.StatusInternalServerError)
 		return
 	}
@@ -1141,6 +1455,7 @@ func UpdateVMs(w http.ResponseWriter, r *http.Request) {
 			vm.UpdateWithCPU(int(vmRequest.Cpus)),
 			vm.UpdateWithMem(int(vmRequest.Ram)),
 			vm.UpdateWithDisk(vmRequest.Disk),
+			vm.UpdateWithUser(ctx.Value("user").(string)),
 		}
 
 		if vmRequest.Interface != nil {
@@ -1164,6 +1479,12 @@ func UpdateVMs(w http.ResponseWriter, r *http.Request) {
 
 		if err := vm.Update(opts...); err != nil {
 			plog.Error("updating VM", "err", err)
+
+			if errors.Is(err, quota.ErrQuotaExceeded) {
+				http.Error(w, err.Error(), http.StatusForbidden)
+				return
+			}
+
 			http.Error(w, "unable to update VM", http.StatusInternalServerError)
 			return
 		}
@@ -1610,6 +1931,7 @@ func RedeployVM(w http.ResponseWriter, r *http.Request) {
 	var (
 		ctx      = r.Context()
 		role     = ctx.Value("role").(rbac.Role)
+		user     = ctx.Value("user").(string)
 		vars     = mux.Vars(r)
 		expName  = vars["exp"]
 		name     = vars["name"]
@@ -1693,6 +2015,8 @@ func RedeployVM(w http.ResponseWriter, r *http.Request) {
 			}
 		}
 
+		opts = append(opts, vm.User(user))
+
 		if err := vm.Redeploy(expName, name, opts...); err != nil {
 			redeployed <- err
 		}
@@ -1715,6 +2039,11 @@ func RedeployVM(w http.ResponseWriter, r *http.Request) {
 			nil,
 		)
 
+		if errors.Is(err, quota.ErrQuotaExceeded) {
+			http.Error(w, err.Error(), http.StatusForbidden)
+			return
+		}
+
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
@@ -1846,8 +2175,14 @@ func StartVMCapture(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := vm.StartCapture(exp, name, int(req.Interface), req.Filename); err != nil {
+	if err := vm.StartCapture(exp, name, int(req.Interface), req.Filename, vm.CaptureWithUser(ctx.Value("user").(string))); err != nil {
 		plog.Error("starting capture for VM", "exp", exp, "vm", name, "err", err)
+
+		if errors.Is(err, quota.ErrQuotaExceeded) {
+			http.Error(w, err.Error(), http.StatusForbidden)
+			return
+		}
+
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
@@ -2,8 +2,11 @@ package web
 
 import (
 	"encoding/json"
+	"fmt"
 	"io"
 	"net/http"
+	"strconv"
+	"time"
 
 	"phenix/store"
 	"phenix/util/plog"
@@ -12,7 +15,7 @@ import (
 	"phenix/web/weberror"
 )
 
-// POST /history
+// POST /history?since={RFC3339}&until={RFC3339}&limit={n}
 func GetHistory(w http.ResponseWriter, r *http.Request) error {
 	plog.Debug("HTTP handler called", "handler", "GetHistory")
 
@@ -38,7 +41,34 @@ func GetHistory(w http.ResponseWriter, r *http.Request) error {
 		return weberror.NewWebError(err, "invalid history event filter provided")
 	}
 
-	events, err := store.GetEventsBy(event)
+	// Audit events record every user's actions, so they're only available to
+	// roles allowed to list the audit log.
+	audit := role.Allowed("audit", "list")
+
+	if event.Type == store.EventTypeAudit && !audit {
+		err := weberror.NewWebError(nil, "listing audit events not allowed for %s", ctx.Value("user").(string))
+		return err.SetStatus(http.StatusForbidden)
+	}
+
+	var events store.Events
+
+	if event.ID != "" {
+		events, err = store.GetEventsBy(event)
+
+		if err == nil && !audit {
+			events = store.EventQuery{ExcludeTypes: []store.EventType{store.EventTypeAudit}}.Filter(events)
+		}
+	} else {
+		var query store.EventQuery
+
+		query, err = historyQuery(r, event, audit)
+		if err != nil {
+			return weberror.NewWebError(err, "invalid history query provided")
+		}
+
+		events, err = store.QueryEvents(query)
+	}
+
 	if err != nil {
 		err := weberror.NewWebError(err, "unable to get matching history events")
 		return err.SetStatus(http.StatusInternalServerError)
@@ -58,3 +88,43 @@ func GetHistory(w http.ResponseWriter, r *http.Request) error {
 
 	return nil
 }
+
+// historyQuery converts the given history event filter, along with the
+// optional time range and limit query parameters, to an event query. Audit
+// events are excluded unless audit is true.
+func historyQuery(r *http.Request, event store.Event, audit bool) (store.EventQuery, error) {
+	query := store.EventQuery{Source: event.Source, Metadata: event.Metadata}
+
+	if event.Type != store.EventTypeNotSet {
+		query.Types = []store.EventType{event.Type}
+	}
+
+	if !audit {
+		query.ExcludeTypes = []store.EventType{store.EventTypeAudit}
+	}
+
+	var (
+		params = r.URL.Query()
+		err    error
+	)
+
+	if since := params.Get("since"); since != "" {
+		if query.Since, err = time.Parse(time.RFC3339, since); err != nil {
+			return query, fmt.Errorf("parsing since parameter: %w", err)
+		}
+	}
+
+	if until := params.Get("until"); until != "" {
+		if query.Until, err = time.Parse(time.RFC3339, until); err != nil {
+			return query, fmt.Errorf("parsing until parameter: %w", err)
+		}
+	}
+
+	if limit := params.Get("limit"); limit != "" {
+		if query.Limit, err = strconv.Atoi(limit); err != nil {
+			return query, fmt.Errorf("parsing limit parameter: %w", err)
+		}
+	}
+
+	return query, nil
+}
@@ -0,0 +1,91 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"phenix/api/audit"
+	"phenix/store"
+	v1 "phenix/types/version/v1"
+	"phenix/web/rbac"
+)
+
+func historyRequest(t *testing.T, role rbac.Role, filter string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/history", strings.NewReader(filter))
+
+	ctx := context.WithValue(req.Context(), "role", role)
+	ctx = context.WithValue(ctx, "user", "foo@bar.com")
+
+	w := httptest.NewRecorder()
+
+	if err := GetHistory(w, req.WithContext(ctx)); err != nil {
+		w.Code = http.StatusForbidden
+	}
+
+	return w
+}
+
+func TestGetHistoryAudit(t *testing.T) {
+	store.DefaultStore = store.NewBoltDB()
+
+	if err := store.DefaultStore.Init(store.Endpoint("bolt://" + filepath.Join(t.TempDir(), "phenix.bdb"))); err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+
+	if err := store.AddEvent(*store.NewInfoEvent("foo")); err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+
+	if err := audit.Record(audit.Entry{User: "admin@bar.com", Verb: "delete", Resource: "experiments", Outcome: audit.OutcomeSuccess}); err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+
+	history := &v1.PolicySpec{Resources: []string{"history"}, ResourceNames: []string{"*"}, Verbs: []string{"get"}}
+	auditor := &v1.PolicySpec{Resources: []string{"audit"}, ResourceNames: []string{"*"}, Verbs: []string{"list"}}
+
+	types := func(w *httptest.ResponseRecorder) []store.EventType {
+		var body struct {
+			History store.Events `json:"history"`
+		}
+
+		if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+			t.Log(err)
+			t.FailNow()
+		}
+
+		var types []store.EventType
+
+		for _, e := range body.History {
+			types = append(types, e.Type)
+		}
+
+		return types
+	}
+
+	user := rbac.Role{Spec: &v1.RoleSpec{Name: "user", Policies: []*v1.PolicySpec{history}}}
+
+	if got := types(historyRequest(t, user, `{}`)); len(got) != 1 || got[0] != store.EventTypeInfo {
+		t.Logf("expected only the info event without audit permission, got %v", got)
+		t.FailNow()
+	}
+
+	if w := historyRequest(t, user, `{"type": "audit"}`); w.Code != http.StatusForbidden {
+		t.Logf("expected audit event filter to be forbidden without audit permission, got %d", w.Code)
+		t.FailNow()
+	}
+
+	admin := rbac.Role{Spec: &v1.RoleSpec{Name: "admin", Policies: []*v1.PolicySpec{history, auditor}}}
+
+	if got := types(historyRequest(t, admin, `{}`)); len(got) != 2 {
+		t.Logf("expected info and audit events with audit permission, got %v", got)
+		t.FailNow()
+	}
+}
@@ -0,0 +1,227 @@
+package middleware
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"hash"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"phenix/api/audit"
+	"phenix/util/plog"
+	"phenix/web/rbac"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/gorilla/mux"
+	"google.golang.org/grpc"
+)
+
+// Requests that use mutating methods but don't change anything (e.g., queries
+// with request bodies) are not audited.
+var auditSkipSuffixes = []string{"/history", "/configs/download", "/login", "/signup"}
+
+var auditVerbs = map[string]string{
+	http.MethodPost:   "create",
+	http.MethodPut:    "update",
+	http.MethodPatch:  "patch",
+	http.MethodDelete: "delete",
+}
+
+// auditMethods are the gRPC methods that change things, mapped to the verb and
+// resource they're audited as. The names match the RBAC checks the methods make.
+var auditMethods = map[string]struct{ verb, resource string }{
+	"/Phenix/StartExperiment": {"update", "experiments/start"},
+	"/Phenix/StopExperiment":  {"update", "experiments/stop"},
+}
+
+// Audit records an audit entry for every mutating (POST, PUT, PATCH, DELETE)
+// request made by an authenticated user. It must be used after the Auth
+// middleware so the user and role are available in the request context.
+func Audit(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		verb, ok := auditVerbs[r.Method]
+		if !ok {
+			h.ServeHTTP(w, r)
+			return
+		}
+
+		for _, suffix := range auditSkipSuffixes {
+			if strings.HasSuffix(r.URL.Path, suffix) {
+				h.ServeHTTP(w, r)
+				return
+			}
+		}
+
+		ctx := r.Context()
+
+		user, _ := ctx.Value("user").(string)
+		if user == "" {
+			h.ServeHTTP(w, r)
+			return
+		}
+
+		entry := audit.Entry{Origin: audit.OriginAPI, User: user, Verb: verb}
+
+		if role, ok := ctx.Value("role").(rbac.Role); ok {
+			entry.Role = role.Spec.Name
+		}
+
+		entry.Resource, entry.ResourceName, entry.Experiment = auditResource(r)
+
+		// Digest the request body as the handler reads it so large uploads aren't
+		// buffered in memory.
+		body := &digestReader{ReadCloser: r.Body, hash: sha256.New()}
+		r.Body = body
+
+		var (
+			rec   = &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+			start = time.Now()
+		)
+
+		h.ServeHTTP(rec, r)
+
+		entry.Duration = time.Since(start)
+		entry.Status = rec.status
+		entry.Outcome = audit.OutcomeSuccess
+
+		if rec.status >= 400 {
+			entry.Outcome = audit.OutcomeFailure
+		}
+
+		if body.read > 0 {
+			entry.Digest = hex.EncodeToString(body.hash.Sum(nil))
+		}
+
+		if err := audit.Record(entry); err != nil {
+			plog.Error("recording audit entry", "user", user, "path", r.URL.Path, "err", err)
+		}
+	})
+}
+
+// GRPCAudit returns a gRPC interceptor that records an audit entry for every
+// call to a gRPC method that changes things, the same way the Audit middleware
+// does for HTTP requests. It must run after the GRPCAuth unary interceptor so
+// the user and role are available in the request context.
+func GRPCAudit() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		method, ok := auditMethods[info.FullMethod]
+		if !ok {
+			return handler(ctx, req)
+		}
+
+		user, _ := ctx.Value("user").(string)
+
+		entry := audit.Entry{Origin: audit.OriginGRPC, User: user, Verb: method.verb, Resource: method.resource}
+
+		if role, ok := ctx.Value("role").(rbac.Role); ok {
+			entry.Role = role.Spec.Name
+		}
+
+		if named, ok := req.(interface{ GetName() string }); ok {
+			entry.ResourceName = named.GetName()
+
+			if strings.HasPrefix(method.resource, "experiments") {
+				entry.Experiment = entry.ResourceName
+			}
+		}
+
+		if msg, ok := req.(proto.Message); ok {
+			if body, err := proto.Marshal(msg); err == nil && len(body) > 0 {
+				entry.Digest = audit.Digest(body)
+			}
+		}
+
+		start := time.Now()
+
+		resp, err := handler(ctx, req)
+
+		entry.Duration = time.Since(start)
+		entry.Outcome = audit.OutcomeSuccess
+
+		if err != nil {
+			entry.Outcome = audit.OutcomeFailure
+		}
+
+		if err := audit.Record(entry); err != nil {
+			plog.Error("recording audit entry", "user", user, "method", info.FullMethod, "err", err)
+		}
+
+		return resp, err
+	}
+}
+
+// auditResource determines the resource, resource name, and experiment for
+// the given request from the template of the route it matched. For example, a
+// request matching `/api/v1/experiments/{exp}/vms/{name}/start` is for the
+// `experiments/vms/start` resource with the resource name `<exp>/<name>`.
+func auditResource(r *http.Request) (string, string, string) {
+	route := mux.CurrentRoute(r)
+	if route == nil {
+		return strings.TrimPrefix(r.URL.Path, "/api/v1/"), "", ""
+	}
+
+	tmpl, err := route.GetPathTemplate()
+	if err != nil {
+		return strings.TrimPrefix(r.URL.Path, "/api/v1/"), "", ""
+	}
+
+	var (
+		vars      = mux.Vars(r)
+		resources []string
+		names     []string
+		exp       string
+	)
+
+	for _, part := range strings.Split(strings.TrimPrefix(tmpl, "/api/v1/"), "/") {
+		if strings.HasPrefix(part, "{") {
+			name := vars[strings.Trim(strings.SplitN(part, ":", 2)[0], "{}")]
+
+			if len(resources) == 1 && resources[0] == "experiments" && len(names) == 0 {
+				exp = name
+			}
+
+			names = append(names, name)
+			continue
+		}
+
+		resources = append(resources, part)
+	}
+
+	return strings.Join(resources, "/"), strings.Join(names, "/"), exp
+}
+
+type digestReader struct {
+	io.ReadCloser
+
+	hash hash.Hash
+	read int
+}
+
+func (this *digestReader) Read(p []byte) (int, error) {
+	n, err := this.ReadCloser.Read(p)
+
+	this.hash.Write(p[:n])
+	this.read += n
+
+	return n, err
+}
+
+type statusRecorder struct {
+	http.ResponseWriter
+
+	status int
+}
+
+func (this *statusRecorder) WriteHeader(status int) {
+	this.status = status
+	this.ResponseWriter.WriteHeader(status)
+}
+
+func (this *statusRecorder) Flush() {
+	if f, ok := this.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
@@ -0,0 +1,127 @@
+package middleware
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"phenix/api/audit"
+	"phenix/store"
+	v1 "phenix/types/version/v1"
+	"phenix/web/rbac"
+
+	"github.com/gorilla/mux"
+)
+
+func TestAudit(t *testing.T) {
+	store.DefaultStore = store.NewBoltDB()
+
+	if err := store.DefaultStore.Init(store.Endpoint("bolt://" + filepath.Join(t.TempDir(), "phenix.bdb"))); err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+
+	var (
+		router = mux.NewRouter()
+		api    = router.PathPrefix("/api/v1").Subrouter()
+	)
+
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		io.ReadAll(r.Body)
+
+		if r.URL.Query().Get("fail") != "" {
+			w.WriteHeader(http.StatusBadRequest)
+		}
+	}
+
+	api.HandleFunc("/experiments/{exp}/vms/{name}/start", handler)
+	api.HandleFunc("/history", handler)
+
+	// Stand in for the Auth middleware.
+	api.Use(func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Header.Get("X-Anonymous") != "" {
+				h.ServeHTTP(w, r)
+				return
+			}
+
+			role := rbac.Role{Spec: &v1.RoleSpec{Name: "Experiment User"}}
+
+			ctx := context.WithValue(r.Context(), "user", "foo@bar.com")
+			ctx = context.WithValue(ctx, "role", role)
+
+			h.ServeHTTP(w, r.WithContext(ctx))
+		})
+	})
+
+	api.Use(Audit)
+
+	request := func(method, path, body string, anonymous bool) {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+
+		if anonymous {
+			req.Header.Set("X-Anonymous", "true")
+		}
+
+		router.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	// None of these should be audited.
+	request(http.MethodGet, "/api/v1/experiments/foo/vms/bar/start", "", false)
+	request(http.MethodPost, "/api/v1/history", `{"limit": 10}`, false)
+	request(http.MethodPost, "/api/v1/experiments/foo/vms/bar/start", "", true)
+
+	entries, err := audit.List()
+	if err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+
+	if len(entries) != 0 {
+		t.Logf("expected no audit entries, got %+v", entries)
+		t.FailNow()
+	}
+
+	request(http.MethodPost, "/api/v1/experiments/foo/vms/bar/start", `{"foo": "bar"}`, false)
+	request(http.MethodPost, "/api/v1/experiments/foo/vms/bar/start?fail=true", "", false)
+
+	entries, err = audit.List()
+	if err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+
+	if len(entries) != 2 {
+		t.Logf("expected 2 audit entries, got %d", len(entries))
+		t.FailNow()
+	}
+
+	expected := []audit.Entry{
+		{
+			Origin: audit.OriginAPI, User: "foo@bar.com", Role: "Experiment User", Verb: "create",
+			Resource: "experiments/vms/start", ResourceName: "foo/bar", Experiment: "foo",
+			Outcome: audit.OutcomeFailure, Status: http.StatusBadRequest,
+		},
+		{
+			Origin: audit.OriginAPI, User: "foo@bar.com", Role: "Experiment User", Verb: "create",
+			Resource: "experiments/vms/start", ResourceName: "foo/bar", Experiment: "foo",
+			Digest: audit.Digest([]byte(`{"foo": "bar"}`)), Outcome: audit.OutcomeSuccess, Status: http.StatusOK,
+		},
+	}
+
+	// Entries are listed most recent first.
+	for i, entry := range entries {
+		entry.ID = ""
+		entry.Timestamp = expected[i].Timestamp
+		entry.Duration = 0
+
+		if entry != expected[i] {
+			t.Logf("expected audit entry %+v, got %+v", expected[i], entry)
+			t.FailNow()
+		}
+	}
+}
@@ -3,7 +3,9 @@ package middleware
 import (
 	"context"
 	"fmt"
+	"net"
 	"net/http"
+	"phenix/util"
 	"phenix/util/plog"
 	"phenix/web/rbac"
 	jwtutil "phenix/web/util/jwt"
@@ -41,7 +43,11 @@ func NoAuth(h http.Handler) http.Handler {
 	})
 }
 
-func Auth(jwtKey, proxyAuthHeader string) mux.MiddlewareFunc {
+// Auth returns middleware that requires requests to include a valid JWT for a
+// phenix user. Requests for the given public routes (matched exactly against
+// the path template of the route, e.g. `/api/v1/login/oidc`) are allowed
+// without a JWT, in addition to the signup and login routes.
+func Auth(jwtKey, proxyAuthHeader string, public ...string) mux.MiddlewareFunc {
 	tokenMiddleware := jwtmiddleware.New(
 		jwtmiddleware.Options{
 			// Setting this to true since some resource paths don't require
@@ -108,6 +114,13 @@ func Auth(jwtKey, proxyAuthHeader string) mux.MiddlewareFunc {
 				return
 			}
 
+			if route := mux.CurrentRoute(r); route != nil {
+				if tmpl, err := route.GetPathTemplate(); err == nil && util.StringSliceContains(public, tmpl) {
+					h.ServeHTTP(w, r)
+					return
+				}
+			}
+
 			ctx := r.Context()
 
 			userToken := ctx.Value("user")
@@ -143,16 +156,10 @@ func Auth(jwtKey, proxyAuthHeader string) mux.MiddlewareFunc {
 				return
 			}
 
-			// Check to see that the token is still associated w/ the user (ie. the
-			// user didn't delete it because it became compromised).
-			if err := user.ValidateToken(token.Raw); err != nil {
-				http.Error(w, "user token error", http.StatusUnauthorized)
-				return
-			}
-
-			role, err := user.Role()
+			role, err := user.TokenRole(token.Raw, claims, remoteHost(r))
 			if err != nil {
-				http.Error(w, "user role error", http.StatusUnauthorized)
+				plog.Error("rejecting user token", "user", jwtUser, "err", err)
+				http.Error(w, "user token error", http.StatusUnauthorized)
 				return
 			}
 
@@ -196,3 +203,13 @@ func Auth(jwtKey, proxyAuthHeader string) mux.MiddlewareFunc {
 	// First validate the token itself, then ensure the user in the token is valid.
 	return func(h http.Handler) http.Handler { return tokenMiddleware.Handler(userMiddleware(h)) }
 }
+
+// remoteHost returns the host the given request came from, without the port.
+func remoteHost(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+
+	return host
+}
@@ -0,0 +1,52 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+)
+
+func TestAuthPublicRoutes(t *testing.T) {
+	var (
+		router = mux.NewRouter()
+		api    = router.PathPrefix("/api/v1").Subrouter()
+		served string
+	)
+
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		served = r.URL.Path
+	}
+
+	api.HandleFunc("/login/oidc", handler)
+	api.HandleFunc("/configs/{kind}/{name}", handler)
+	api.Use(Auth("secret", "", "/api/v1/login/oidc"))
+
+	tests := map[string]int{
+		"/api/v1/login/oidc":         http.StatusOK,
+		"/api/v1/configs/login/oidc": http.StatusForbidden,
+	}
+
+	for path, status := range tests {
+		served = ""
+
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest("GET", path, nil))
+
+		if rec.Code != status {
+			t.Logf("expected status %d for %s, got %d", status, path, rec.Code)
+			t.FailNow()
+		}
+
+		if status == http.StatusOK && served != path {
+			t.Logf("expected %s to be served", path)
+			t.FailNow()
+		}
+
+		if status != http.StatusOK && served != "" {
+			t.Logf("expected %s not to be served", path)
+			t.FailNow()
+		}
+	}
+}
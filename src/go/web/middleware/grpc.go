@@ -0,0 +1,178 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+
+	"phenix/util/plog"
+	"phenix/web/rbac"
+	jwtutil "phenix/web/util/jwt"
+
+	"github.com/dgrijalva/jwt-go"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// GRPCAuth returns gRPC interceptors that authenticate requests the same way
+// the Auth middleware authenticates HTTP requests. The JWT is expected to be in
+// the `x-phenix-auth-token` metadata key using the `Bearer {token}` format. The
+// resulting user and role are added to the request context using the same keys
+// as the Auth middleware.
+func GRPCAuth(jwtKey, proxyAuthHeader string) (grpc.UnaryServerInterceptor, grpc.StreamServerInterceptor) {
+	unary := func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		ctx, err := grpcAuthenticate(ctx, jwtKey, proxyAuthHeader)
+		if err != nil {
+			plog.Error("rejecting unauthorized gRPC request", "method", info.FullMethod, "err", err)
+			return nil, err
+		}
+
+		return handler(ctx, req)
+	}
+
+	stream := func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx, err := grpcAuthenticate(ss.Context(), jwtKey, proxyAuthHeader)
+		if err != nil {
+			plog.Error("rejecting unauthorized gRPC request", "method", info.FullMethod, "err", err)
+			return err
+		}
+
+		return handler(srv, authenticatedStream{ServerStream: ss, ctx: ctx})
+	}
+
+	return unary, stream
+}
+
+// ChainUnary combines the given unary interceptors into one that runs them in
+// the given order, since gRPC servers only accept a single unary interceptor.
+func ChainUnary(interceptors ...grpc.UnaryServerInterceptor) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		next := handler
+
+		for i := len(interceptors) - 1; i >= 0; i-- {
+			var (
+				interceptor = interceptors[i]
+				inner       = next
+			)
+
+			next = func(ctx context.Context, req interface{}) (interface{}, error) {
+				return interceptor(ctx, req, info, inner)
+			}
+		}
+
+		return next(ctx, req)
+	}
+}
+
+type authenticatedStream struct {
+	grpc.ServerStream
+
+	ctx context.Context
+}
+
+func (this authenticatedStream) Context() context.Context {
+	return this.ctx
+}
+
+func grpcAuthenticate(ctx context.Context, jwtKey, proxyAuthHeader string) (context.Context, error) {
+	if jwtKey == "" {
+		role, _ := rbac.RoleFromConfig("global-admin")
+
+		ctx = context.WithValue(ctx, "user", "global-admin")
+		ctx = context.WithValue(ctx, "role", *role)
+
+		return ctx, nil
+	}
+
+	if strings.HasPrefix(jwtKey, "dev|") {
+		creds := strings.Split(jwtKey, "|")
+
+		role, _ := rbac.RoleFromConfig(creds[2])
+
+		ctx = context.WithValue(ctx, "user", creds[1])
+		ctx = context.WithValue(ctx, "role", *role)
+
+		return ctx, nil
+	}
+
+	md, _ := metadata.FromIncomingContext(ctx)
+
+	raw, err := fromMetadata(md, "x-phenix-auth-token")
+	if err != nil {
+		return nil, status.Error(codes.Unauthenticated, err.Error())
+	}
+
+	if raw == "" {
+		return nil, status.Error(codes.Unauthenticated, "missing phenix auth token")
+	}
+
+	var token *jwt.Token
+
+	if jwtKey == "proxy-jwt" {
+		token, _, err = new(jwt.Parser).ParseUnverified(raw, jwt.MapClaims{})
+	} else {
+		token, err = jwt.Parse(raw, func(t *jwt.Token) (interface{}, error) {
+			if t.Method != jwt.SigningMethodHS256 {
+				return nil, fmt.Errorf("unexpected signing method %v", t.Header["alg"])
+			}
+
+			return []byte(jwtKey), nil
+		})
+	}
+
+	if err != nil {
+		return nil, status.Errorf(codes.Unauthenticated, "parsing auth token: %v", err)
+	}
+
+	jwtUser, err := jwtutil.UsernameFromClaims(token.Claims.(jwt.MapClaims))
+	if err != nil {
+		return nil, status.Error(codes.Unauthenticated, err.Error())
+	}
+
+	if proxyAuthHeader != "" {
+		if users := md.Get(strings.ToLower(proxyAuthHeader)); len(users) == 0 || users[0] != jwtUser {
+			return nil, status.Error(codes.Unauthenticated, "proxy user mismatch")
+		}
+	}
+
+	user, err := rbac.GetUser(jwtUser)
+	if err != nil {
+		return nil, status.Error(codes.Unauthenticated, "user error")
+	}
+
+	var addr string
+
+	if p, ok := peer.FromContext(ctx); ok {
+		addr, _, _ = net.SplitHostPort(p.Addr.String())
+	}
+
+	role, err := user.TokenRole(token.Raw, token.Claims.(jwt.MapClaims), addr)
+	if err != nil {
+		return nil, status.Error(codes.Unauthenticated, "user token error")
+	}
+
+	ctx = context.WithValue(ctx, "user", user.Username())
+	ctx = context.WithValue(ctx, "role", role)
+	ctx = context.WithValue(ctx, "jwt", token.Raw)
+
+	return ctx, nil
+}
+
+func fromMetadata(md metadata.MD, key string) (string, error) {
+	values := md.Get(key)
+
+	if len(values) == 0 {
+		return "", nil // No error, just no token
+	}
+
+	parts := strings.Split(values[0], " ")
+	if len(parts) != 2 || strings.ToLower(parts[0]) != "bearer" {
+		return "", fmt.Errorf("%s metadata format must be 'Bearer {token}'", key)
+	}
+
+	return parts[1], nil
+}
@@ -0,0 +1,86 @@
+package middleware
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/dgrijalva/jwt-go"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+func TestGRPCAuthRejected(t *testing.T) {
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{"sub": "alice"}).SignedString([]byte("secret"))
+	if err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+
+	forged, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{"sub": "alice"}).SignedString([]byte("forged"))
+	if err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+
+	tests := []struct {
+		name     string
+		md       metadata.MD
+		expected string
+	}{
+		{"missing token", metadata.Pairs(), "missing phenix auth token"},
+		{"bad bearer format", metadata.Pairs("x-phenix-auth-token", "Token "+token), "must be 'Bearer {token}'"},
+		{"bad signature", metadata.Pairs("x-phenix-auth-token", "Bearer "+forged, "x-forwarded-user", "alice"), "parsing auth token"},
+		{"missing proxy user", metadata.Pairs("x-phenix-auth-token", "Bearer "+token), "proxy user mismatch"},
+		{"proxy user mismatch", metadata.Pairs("x-phenix-auth-token", "Bearer "+token, "x-forwarded-user", "bob"), "proxy user mismatch"},
+	}
+
+	unary, stream := GRPCAuth("secret", "X-Forwarded-User")
+
+	for _, test := range tests {
+		var called bool
+
+		handler := func(context.Context, interface{}) (interface{}, error) {
+			called = true
+			return nil, nil
+		}
+
+		ctx := metadata.NewIncomingContext(context.Background(), test.md)
+
+		_, err := unary(ctx, nil, &grpc.UnaryServerInfo{FullMethod: "/Phenix/ListExperiments"}, handler)
+
+		if status.Code(err) != codes.Unauthenticated || !strings.Contains(err.Error(), test.expected) {
+			t.Logf("%s: expected unauthenticated error containing %q, got %v", test.name, test.expected, err)
+			t.FailNow()
+		}
+
+		if called {
+			t.Logf("%s: expected handler not to be called", test.name)
+			t.FailNow()
+		}
+
+		streamHandler := func(interface{}, grpc.ServerStream) error {
+			called = true
+			return nil
+		}
+
+		err = stream(nil, testStream{ctx: ctx}, &grpc.StreamServerInfo{FullMethod: "/Phenix/StreamLogs"}, streamHandler)
+
+		if status.Code(err) != codes.Unauthenticated || called {
+			t.Logf("%s: expected stream to be rejected as unauthenticated, got %v", test.name, err)
+			t.FailNow()
+		}
+	}
+}
+
+type testStream struct {
+	grpc.ServerStream
+
+	ctx context.Context
+}
+
+func (this testStream) Context() context.Context {
+	return this.ctx
+}
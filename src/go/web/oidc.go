@@ -0,0 +1,300 @@
+package web
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+
+	"phenix/util/plog"
+	"phenix/web/oidc"
+	"phenix/web/rbac"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+// How long users have to complete logging in with the OIDC provider, and how
+// long the UI has to pick up the resulting session once redirected back.
+const (
+	oidcLoginTimeout   = 10 * time.Minute
+	oidcSessionTimeout = 1 * time.Minute
+)
+
+// oidcStateCookie ties the state of an OIDC login to the browser that started
+// it, so a login started by someone else can't be completed in the browser
+// (login CSRF).
+const oidcStateCookie = "phenix-oidc-state"
+
+var (
+	oidcProvider *oidc.Provider
+	oidcPending  = newOIDCPending()
+)
+
+type oidcLogin struct {
+	nonce    string
+	verifier string
+	expires  time.Time
+}
+
+type oidcSession struct {
+	resp    LoginResponse
+	expires time.Time
+}
+
+// oidcPendingStore tracks OIDC logins that have been started but not yet
+// completed (keyed by state), and completed logins that have not yet been
+// picked up by the UI (keyed by one-time session code).
+type oidcPendingStore struct {
+	sync.Mutex
+
+	logins   map[string]oidcLogin
+	sessions map[string]oidcSession
+}
+
+func newOIDCPending() *oidcPendingStore {
+	return &oidcPendingStore{
+		logins:   make(map[string]oidcLogin),
+		sessions: make(map[string]oidcSession),
+	}
+}
+
+func (this *oidcPendingStore) addLogin(state string, login oidcLogin) {
+	this.Lock()
+	defer this.Unlock()
+
+	this.expire()
+	this.logins[state] = login
+}
+
+func (this *oidcPendingStore) login(state string) (oidcLogin, bool) {
+	this.Lock()
+	defer this.Unlock()
+
+	this.expire()
+
+	login, ok := this.logins[state]
+	delete(this.logins, state)
+
+	return login, ok
+}
+
+func (this *oidcPendingStore) addSession(code string, session oidcSession) {
+	this.Lock()
+	defer this.Unlock()
+
+	this.expire()
+	this.sessions[code] = session
+}
+
+func (this *oidcPendingStore) session(code string) (oidcSession, bool) {
+	this.Lock()
+	defer this.Unlock()
+
+	this.expire()
+
+	session, ok := this.sessions[code]
+	delete(this.sessions, code)
+
+	return session, ok
+}
+
+// expire must be called with the lock held.
+func (this *oidcPendingStore) expire() {
+	now := time.Now()
+
+	for state, login := range this.logins {
+		if now.After(login.expires) {
+			delete(this.logins, state)
+		}
+	}
+
+	for code, session := range this.sessions {
+		if now.After(session.expires) {
+			delete(this.sessions, code)
+		}
+	}
+}
+
+// GET /login/oidc
+func OIDCLogin(w http.ResponseWriter, r *http.Request) {
+	plog.Debug("HTTP handler called", "handler", "OIDCLogin")
+
+	var (
+		state = oidc.RandomString()
+		login = oidcLogin{
+			nonce:    oidc.RandomString(),
+			verifier: oidc.RandomString(),
+			expires:  time.Now().Add(oidcLoginTimeout),
+		}
+	)
+
+	oidcPending.addLogin(state, login)
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     oidcStateCookie,
+		Value:    state,
+		Path:     o.basePath + "api/v1/login/oidc",
+		MaxAge:   int(oidcLoginTimeout.Seconds()),
+		HttpOnly: true,
+		Secure:   r.TLS != nil,
+		// Lax (rather than strict) so the cookie is included when the OIDC
+		// provider redirects back to the callback.
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	http.Redirect(w, r, oidcProvider.AuthCodeURL(state, login.nonce, login.verifier), http.StatusFound)
+}
+
+// GET /login/oidc/callback
+func OIDCCallback(w http.ResponseWriter, r *http.Request) {
+	plog.Debug("HTTP handler called", "handler", "OIDCCallback")
+
+	query := r.URL.Query()
+
+	if e := query.Get("error"); e != "" {
+		plog.Error("OIDC provider returned error", "err", e, "description", query.Get("error_description"))
+		http.Error(w, "OIDC login failed: "+e, http.StatusUnauthorized)
+		return
+	}
+
+	state := query.Get("state")
+
+	cookie, err := r.Cookie(oidcStateCookie)
+	if err != nil || state == "" || subtle.ConstantTimeCompare([]byte(cookie.Value), []byte(state)) != 1 {
+		plog.Warn("OIDC login state does not match state cookie")
+		http.Error(w, "OIDC login state mismatch", http.StatusBadRequest)
+		return
+	}
+
+	// The state is single use, so clear the cookie now that it's been checked.
+	http.SetCookie(w, &http.Cookie{
+		Name:     oidcStateCookie,
+		Path:     o.basePath + "api/v1/login/oidc",
+		MaxAge:   -1,
+		HttpOnly: true,
+		Secure:   r.TLS != nil,
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	login, ok := oidcPending.login(state)
+	if !ok {
+		http.Error(w, "unknown or expired OIDC login state", http.StatusBadRequest)
+		return
+	}
+
+	claims, err := oidcProvider.Exchange(r.Context(), query.Get("code"), login.verifier, login.nonce)
+	if err != nil {
+		plog.Error("exchanging OIDC authorization code", "err", err)
+		http.Error(w, "OIDC login failed", http.StatusUnauthorized)
+		return
+	}
+
+	var usernameClaims []string
+
+	if o.oidc.UsernameClaim != "" {
+		usernameClaims = []string{o.oidc.UsernameClaim}
+	}
+
+	username, err := claims.Username(usernameClaims...)
+	if err != nil {
+		plog.Error("getting username from OIDC claims", "err", err)
+		http.Error(w, "OIDC login failed", http.StatusUnauthorized)
+		return
+	}
+
+	// Users are tied to the issuer and subject of the OIDC identity they were
+	// provisioned for, since those are the only claims guaranteed to uniquely
+	// and stably identify a user.
+	var (
+		issuer  = claims.String("iss")
+		subject = claims.String("sub")
+	)
+
+	if issuer == "" || subject == "" {
+		plog.Error("OIDC ID token missing issuer or subject", "user", username)
+		http.Error(w, "OIDC login failed", http.StatusUnauthorized)
+		return
+	}
+
+	mapping, ok := claims.Role(o.oidc)
+	if !ok {
+		plog.Warn("OIDC user does not map to a role", "user", username)
+		http.Error(w, "no phenix role mapped for "+username, http.StatusForbidden)
+		return
+	}
+
+	user, err := rbac.GetUser(username)
+	if err != nil {
+		plog.Info("provisioning OIDC user", "user", username, "role", mapping.Role)
+
+		// OIDC users never log in with a password, so give them one nobody knows.
+		if user = rbac.NewOIDCUser(username, issuer, subject, oidc.RandomString()); user == nil {
+			http.Error(w, "unable to create user", http.StatusInternalServerError)
+			return
+		}
+
+		user.Spec.FirstName = claims.String("given_name")
+		user.Spec.LastName = claims.String("family_name")
+	} else if iss, sub, ok := user.OIDCIdentity(); !ok || iss != issuer || sub != subject {
+		// Never log in as (or change the role of) a local account, or an account
+		// provisioned for a different OIDC identity.
+		plog.Warn("refusing OIDC login for user not provisioned for OIDC identity", "user", username, "issuer", issuer, "subject", subject)
+		http.Error(w, "user "+username+" is not linked to this OIDC identity", http.StatusForbidden)
+		return
+	}
+
+	// Roles are mapped on every login so changes made in the OIDC provider (like
+	// group membership) take effect the next time the user logs in.
+	if err := setUserRole(user, mapping.Role, mapping.ResourceNames...); err != nil {
+		plog.Error("setting role for OIDC user", "user", username, "role", mapping.Role, "err", err)
+		http.Error(w, "unable to set user role", http.StatusInternalServerError)
+		return
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"sub": user.Username(),
+		"exp": time.Now().Add(o.jwtLifetime).Unix(),
+	})
+
+	signed, err := token.SignedString([]byte(o.jwtKey))
+	if err != nil {
+		http.Error(w, "failed to sign JWT", http.StatusInternalServerError)
+		return
+	}
+
+	if err := user.AddToken(signed, "oidc "+time.Now().Format(time.RFC3339)); err != nil {
+		http.Error(w, "", http.StatusInternalServerError)
+		return
+	}
+
+	// The token is handed to the UI via a one-time code rather than directly in
+	// the redirect URL so it doesn't end up in browser history or proxy logs.
+	code := oidc.RandomString()
+
+	oidcPending.addSession(code, oidcSession{
+		resp:    LoginResponse{User: userFromRBAC(*user), Token: signed},
+		expires: time.Now().Add(oidcSessionTimeout),
+	})
+
+	http.Redirect(w, r, o.basePath+"signin?oidc="+code, http.StatusFound)
+}
+
+// GET /login/oidc/session
+func OIDCSession(w http.ResponseWriter, r *http.Request) {
+	plog.Debug("HTTP handler called", "handler", "OIDCSession")
+
+	session, ok := oidcPending.session(r.URL.Query().Get("code"))
+	if !ok {
+		http.Error(w, "unknown or expired OIDC session", http.StatusUnauthorized)
+		return
+	}
+
+	body, err := json.Marshal(session.resp)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Write(body)
+}
@@ -0,0 +1,114 @@
+package oidc
+
+import (
+	"fmt"
+	"strings"
+)
+
+// DefaultUsernameClaims are the ID token claims checked (in order) for a
+// username if no username claim is configured. Only `sub` is guaranteed by the
+// OIDC spec to be unique and stable for a user of an issuer, so claims like
+// `preferred_username` and `email` must be configured explicitly.
+var DefaultUsernameClaims = []string{"sub"}
+
+// Claims are the claims included in a verified ID token.
+type Claims map[string]any
+
+// String returns the value of the given claim if it's a string.
+func (this Claims) String(claim string) string {
+	v, _ := this[claim].(string)
+	return v
+}
+
+// Contains returns true if the given claim is equal to the given value or, for
+// list claims (like groups), includes the given value.
+func (this Claims) Contains(claim, value string) bool {
+	switch v := this[claim].(type) {
+	case string:
+		return v == value
+	case []any:
+		for _, e := range v {
+			if s, ok := e.(string); ok && s == value {
+				return true
+			}
+		}
+	case []string:
+		for _, s := range v {
+			if s == value {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// Username returns the value of the first of the given claims that's a
+// non-empty string. DefaultUsernameClaims are used if no claims are given.
+func (this Claims) Username(claims ...string) (string, error) {
+	if len(claims) == 0 {
+		claims = DefaultUsernameClaims
+	}
+
+	for _, claim := range claims {
+		if user := this.String(claim); user != "" {
+			return user, nil
+		}
+	}
+
+	return "", fmt.Errorf("username not found in ID token claims %v", claims)
+}
+
+// RoleMapping maps users with an ID token claim matching a value to a phenix
+// role, optionally limited to the given resource names.
+type RoleMapping struct {
+	Claim         string
+	Value         string
+	Role          string
+	ResourceNames []string
+}
+
+// ParseRoleMapping parses a role mapping of the form
+// `<claim>=<value>:<role>[:<resource name>...]`, for example
+// `groups=phenix-admins:Global Admin` or `groups=red-team:Experiment User:red-*`.
+func ParseRoleMapping(m string) (RoleMapping, error) {
+	parts := strings.Split(m, ":")
+
+	if len(parts) < 2 {
+		return RoleMapping{}, fmt.Errorf("role mapping %s must be of the form <claim>=<value>:<role>[:<resource name>...]", m)
+	}
+
+	claim, value, ok := strings.Cut(parts[0], "=")
+	if !ok || claim == "" || parts[1] == "" {
+		return RoleMapping{}, fmt.Errorf("role mapping %s must be of the form <claim>=<value>:<role>[:<resource name>...]", m)
+	}
+
+	return RoleMapping{Claim: claim, Value: value, Role: parts[1], ResourceNames: parts[2:]}, nil
+}
+
+// MapRole returns the first of the given role mappings that matches the
+// claims.
+func (this Claims) MapRole(mappings []RoleMapping) (RoleMapping, bool) {
+	for _, m := range mappings {
+		if this.Contains(m.Claim, m.Value) {
+			return m, true
+		}
+	}
+
+	return RoleMapping{}, false
+}
+
+// Role returns the role mapping to use for the claims given the role mappings
+// and default role in the config. It returns false if no mapping matches and
+// there's no default role, in which case the user should not be allowed in.
+func (this Claims) Role(config Config) (RoleMapping, bool) {
+	if m, ok := this.MapRole(config.RoleMappings); ok {
+		return m, true
+	}
+
+	if config.DefaultRole != "" {
+		return RoleMapping{Role: config.DefaultRole}, true
+	}
+
+	return RoleMapping{}, false
+}
@@ -0,0 +1,325 @@
+// Package oidc implements the parts of OpenID Connect needed to log users into
+// the phenix UI using the authorization code flow with PKCE (RFC 7636).
+// Provider metadata is discovered from the issuer, and ID tokens are verified
+// using the provider's published RSA signing keys.
+package oidc
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+// Config is the configuration for an OpenID Connect provider.
+type Config struct {
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+
+	// Scopes requested in addition to `openid`. Defaults to `profile` and
+	// `email` if empty.
+	Scopes []string
+
+	// UsernameClaim is the ID token claim used as the phenix username.
+	// DefaultUsernameClaims are used if empty.
+	UsernameClaim string
+
+	// RoleMappings map ID token claims to phenix roles. The first matching
+	// mapping is used, falling back to DefaultRole (if set) when none match.
+	RoleMappings []RoleMapping
+	DefaultRole  string
+}
+
+// Enabled returns true if an issuer has been configured.
+func (this Config) Enabled() bool {
+	return this.Issuer != ""
+}
+
+// Provider is an OpenID Connect provider discovered from its issuer.
+type Provider struct {
+	sync.Mutex
+
+	config Config
+	client *http.Client
+
+	issuer   string
+	authURL  string
+	tokenURL string
+	jwksURL  string
+
+	keys map[string]*rsa.PublicKey
+}
+
+type discovery struct {
+	Issuer   string `json:"issuer"`
+	AuthURL  string `json:"authorization_endpoint"`
+	TokenURL string `json:"token_endpoint"`
+	JWKSURL  string `json:"jwks_uri"`
+}
+
+// NewProvider discovers the endpoints of the OpenID Connect provider for the
+// given config using the provider's `.well-known/openid-configuration`
+// document.
+func NewProvider(ctx context.Context, config Config) (*Provider, error) {
+	if config.Issuer == "" || config.ClientID == "" || config.RedirectURL == "" {
+		return nil, fmt.Errorf("issuer, client ID, and redirect URL are required")
+	}
+
+	if len(config.Scopes) == 0 {
+		config.Scopes = []string{"profile", "email"}
+	}
+
+	p := &Provider{
+		config: config,
+		client: &http.Client{Timeout: 30 * time.Second},
+		keys:   make(map[string]*rsa.PublicKey),
+	}
+
+	var d discovery
+
+	wellKnown := strings.TrimSuffix(config.Issuer, "/") + "/.well-known/openid-configuration"
+
+	if err := p.getJSON(ctx, wellKnown, &d); err != nil {
+		return nil, fmt.Errorf("discovering OIDC provider: %w", err)
+	}
+
+	if d.Issuer != config.Issuer {
+		return nil, fmt.Errorf("discovered issuer %s does not match configured issuer %s", d.Issuer, config.Issuer)
+	}
+
+	p.issuer = d.Issuer
+	p.authURL = d.AuthURL
+	p.tokenURL = d.TokenURL
+	p.jwksURL = d.JWKSURL
+
+	return p, nil
+}
+
+// AuthCodeURL returns the provider URL to redirect users to in order to log
+// in. The given state and nonce are returned by the provider in the redirect
+// back and in the ID token, respectively, and the given PKCE verifier must be
+// passed to Exchange along with the resulting authorization code.
+func (this *Provider) AuthCodeURL(state, nonce, verifier string) string {
+	params := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {this.config.ClientID},
+		"redirect_uri":          {this.config.RedirectURL},
+		"scope":                 {strings.Join(append([]string{"openid"}, this.config.Scopes...), " ")},
+		"state":                 {state},
+		"nonce":                 {nonce},
+		"code_challenge":        {Challenge(verifier)},
+		"code_challenge_method": {"S256"},
+	}
+
+	sep := "?"
+
+	if strings.Contains(this.authURL, "?") {
+		sep = "&"
+	}
+
+	return this.authURL + sep + params.Encode()
+}
+
+// Exchange exchanges the given authorization code (and PKCE verifier) for
+// tokens, then verifies the ID token returned by the provider. It returns the
+// claims of the verified ID token.
+func (this *Provider) Exchange(ctx context.Context, code, verifier, nonce string) (Claims, error) {
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {this.config.RedirectURL},
+		"client_id":     {this.config.ClientID},
+		"code_verifier": {verifier},
+	}
+
+	if this.config.ClientSecret != "" {
+		form.Set("client_secret", this.config.ClientSecret)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, this.tokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, fmt.Errorf("creating token request: %w", err)
+	}
+
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := this.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("requesting tokens: %w", err)
+	}
+
+	defer resp.Body.Close()
+
+	var tokens struct {
+		IDToken     string `json:"id_token"`
+		Error       string `json:"error"`
+		Description string `json:"error_description"`
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(&tokens); err != nil {
+		return nil, fmt.Errorf("decoding token response: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("token request failed (%d): %s %s", resp.StatusCode, tokens.Error, tokens.Description)
+	}
+
+	if tokens.IDToken == "" {
+		return nil, fmt.Errorf("no ID token in token response")
+	}
+
+	return this.Verify(ctx, tokens.IDToken, nonce)
+}
+
+// Verify verifies the signature, issuer, audience, expiration, and nonce of
+// the given raw ID token, returning its claims.
+func (this *Provider) Verify(ctx context.Context, raw, nonce string) (Claims, error) {
+	claims := jwt.MapClaims{}
+
+	_, err := jwt.ParseWithClaims(raw, claims, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodRSA); !ok {
+			return nil, fmt.Errorf("unexpected ID token signing method %v", token.Header["alg"])
+		}
+
+		kid, _ := token.Header["kid"].(string)
+
+		return this.key(ctx, kid)
+	})
+
+	if err != nil {
+		return nil, fmt.Errorf("verifying ID token: %w", err)
+	}
+
+	if !claims.VerifyIssuer(this.issuer, true) {
+		return nil, fmt.Errorf("ID token issued by unexpected issuer %v", claims["iss"])
+	}
+
+	if !Claims(claims).Contains("aud", this.config.ClientID) {
+		return nil, fmt.Errorf("ID token issued for unexpected audience %v", claims["aud"])
+	}
+
+	if _, ok := claims["exp"]; !ok {
+		return nil, fmt.Errorf("ID token missing expiration")
+	}
+
+	if n, _ := claims["nonce"].(string); n != nonce {
+		return nil, fmt.Errorf("ID token nonce mismatch")
+	}
+
+	return Claims(claims), nil
+}
+
+// key returns the provider's public key with the given ID, refreshing the
+// provider's keys if the key isn't already known (since providers rotate
+// their signing keys).
+func (this *Provider) key(ctx context.Context, kid string) (*rsa.PublicKey, error) {
+	this.Lock()
+	defer this.Unlock()
+
+	if key := this.findKey(kid); key != nil {
+		return key, nil
+	}
+
+	var set struct {
+		Keys []struct {
+			Kty string `json:"kty"`
+			Kid string `json:"kid"`
+			Use string `json:"use"`
+			N   string `json:"n"`
+			E   string `json:"e"`
+		} `json:"keys"`
+	}
+
+	if err := this.getJSON(ctx, this.jwksURL, &set); err != nil {
+		return nil, fmt.Errorf("getting OIDC provider keys: %w", err)
+	}
+
+	keys := make(map[string]*rsa.PublicKey)
+
+	for _, k := range set.Keys {
+		if k.Kty != "RSA" || (k.Use != "" && k.Use != "sig") {
+			continue
+		}
+
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			continue
+		}
+
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil {
+			continue
+		}
+
+		keys[k.Kid] = &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}
+	}
+
+	this.keys = keys
+
+	if key := this.findKey(kid); key != nil {
+		return key, nil
+	}
+
+	return nil, fmt.Errorf("unknown ID token signing key %s", kid)
+}
+
+// findKey returns the known key with the given ID. If no ID is given, the only
+// known key is returned (if there is only one).
+func (this *Provider) findKey(kid string) *rsa.PublicKey {
+	if kid == "" && len(this.keys) == 1 {
+		for _, key := range this.keys {
+			return key
+		}
+	}
+
+	return this.keys[kid]
+}
+
+func (this *Provider) getJSON(ctx context.Context, u string, v any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := this.client.Do(req)
+	if err != nil {
+		return err
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status %d from %s", resp.StatusCode, u)
+	}
+
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+// RandomString returns a random, URL-safe string suitable for use as a state,
+// nonce, or PKCE verifier.
+func RandomString() string {
+	b := make([]byte, 32)
+	rand.Read(b)
+
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// Challenge returns the S256 PKCE code challenge for the given verifier.
+func Challenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
@@ -0,0 +1,241 @@
+package oidc
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+// mockIdP is a minimal OpenID Connect provider that issues ID tokens for a
+// single user with the given claims.
+type mockIdP struct {
+	*httptest.Server
+
+	key    *rsa.PrivateKey
+	claims jwt.MapClaims
+
+	// authorization code -> PKCE challenge and nonce
+	codes map[string][2]string
+}
+
+func newMockIdP(t *testing.T, claims jwt.MapClaims) *mockIdP {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+
+	idp := &mockIdP{key: key, claims: claims, codes: make(map[string][2]string)}
+
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 idp.URL,
+			"authorization_endpoint": idp.URL + "/authorize",
+			"token_endpoint":         idp.URL + "/token",
+			"jwks_uri":               idp.URL + "/keys",
+		})
+	})
+
+	mux.HandleFunc("/keys", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]any{
+			"keys": []map[string]string{{
+				"kty": "RSA",
+				"kid": "test",
+				"use": "sig",
+				"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+				"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+			}},
+		})
+	})
+
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+
+		code, ok := idp.codes[r.Form.Get("code")]
+		if !ok {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+			return
+		}
+
+		if Challenge(r.Form.Get("code_verifier")) != code[0] {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant", "error_description": "PKCE verification failed"})
+			return
+		}
+
+		json.NewEncoder(w).Encode(map[string]string{"id_token": idp.token(t, code[1], "phenix")})
+	})
+
+	idp.Server = httptest.NewServer(mux)
+	t.Cleanup(idp.Close)
+
+	return idp
+}
+
+// authorize simulates the user logging in at the given auth code URL,
+// returning the authorization code and state the provider would redirect back
+// with.
+func (this *mockIdP) authorize(t *testing.T, authURL string) (string, string) {
+	u, err := url.Parse(authURL)
+	if err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+
+	query := u.Query()
+
+	if query.Get("code_challenge_method") != "S256" {
+		t.Logf("expected S256 code challenge method, got %s", query.Get("code_challenge_method"))
+		t.FailNow()
+	}
+
+	code := RandomString()
+	this.codes[code] = [2]string{query.Get("code_challenge"), query.Get("nonce")}
+
+	return code, query.Get("state")
+}
+
+func (this *mockIdP) token(t *testing.T, nonce, aud string) string {
+	claims := jwt.MapClaims{
+		"iss":   this.URL,
+		"aud":   aud,
+		"exp":   time.Now().Add(time.Minute).Unix(),
+		"nonce": nonce,
+	}
+
+	for k, v := range this.claims {
+		claims[k] = v
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = "test"
+
+	signed, err := token.SignedString(this.key)
+	if err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+
+	return signed
+}
+
+func TestExchange(t *testing.T) {
+	idp := newMockIdP(t, jwt.MapClaims{
+		"sub":                "1234",
+		"preferred_username": "jdoe",
+		"groups":             []string{"phenix-users", "red-team"},
+	})
+
+	ctx := context.Background()
+
+	provider, err := NewProvider(ctx, Config{Issuer: idp.URL, ClientID: "phenix", RedirectURL: "http://localhost/callback"})
+	if err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+
+	var (
+		nonce    = RandomString()
+		verifier = RandomString()
+	)
+
+	code, state := idp.authorize(t, provider.AuthCodeURL("foobar", nonce, verifier))
+
+	if state != "foobar" {
+		t.Logf("expected state foobar, got %s", state)
+		t.FailNow()
+	}
+
+	if _, err := provider.Exchange(ctx, code, RandomString(), nonce); err == nil {
+		t.Log("expected exchange with wrong PKCE verifier to fail")
+		t.FailNow()
+	}
+
+	claims, err := provider.Exchange(ctx, code, verifier, nonce)
+	if err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+
+	if user, _ := claims.Username(); user != "1234" {
+		t.Logf("expected username 1234, got %s", user)
+		t.FailNow()
+	}
+
+	if user, _ := claims.Username("preferred_username"); user != "jdoe" {
+		t.Logf("expected username jdoe, got %s", user)
+		t.FailNow()
+	}
+
+	if !claims.Contains("groups", "red-team") {
+		t.Logf("expected groups to include red-team, got %v", claims["groups"])
+		t.FailNow()
+	}
+
+	if _, err := provider.Verify(ctx, idp.token(t, nonce, "phenix"), RandomString()); err == nil {
+		t.Log("expected ID token with wrong nonce to fail verification")
+		t.FailNow()
+	}
+
+	if _, err := provider.Verify(ctx, idp.token(t, nonce, "someone-else"), nonce); err == nil {
+		t.Log("expected ID token for wrong audience to fail verification")
+		t.FailNow()
+	}
+}
+
+func TestRoleMapping(t *testing.T) {
+	var mappings []RoleMapping
+
+	for _, m := range []string{"groups=phenix-admins:Global Admin", "groups=red-team:Experiment User:red-*:red-*/*"} {
+		mapping, err := ParseRoleMapping(m)
+		if err != nil {
+			t.Log(err)
+			t.FailNow()
+		}
+
+		mappings = append(mappings, mapping)
+	}
+
+	if _, err := ParseRoleMapping("phenix-admins:Global Admin"); err == nil {
+		t.Log("expected role mapping without claim to fail parsing")
+		t.FailNow()
+	}
+
+	claims := Claims{"groups": []any{"phenix-users", "red-team"}}
+
+	mapping, ok := claims.Role(Config{RoleMappings: mappings})
+	if !ok {
+		t.Log("expected role mapping to match")
+		t.FailNow()
+	}
+
+	if mapping.Role != "Experiment User" || len(mapping.ResourceNames) != 2 || mapping.ResourceNames[1] != "red-*/*" {
+		t.Logf("unexpected role mapping %+v", mapping)
+		t.FailNow()
+	}
+
+	claims = Claims{"groups": []any{"blue-team"}}
+
+	if _, ok := claims.Role(Config{RoleMappings: mappings}); ok {
+		t.Log("expected no role mapping to match")
+		t.FailNow()
+	}
+
+	if mapping, _ := claims.Role(Config{RoleMappings: mappings, DefaultRole: "Global Viewer"}); mapping.Role != "Global Viewer" {
+		t.Logf("expected default role, got %+v", mapping)
+		t.FailNow()
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"os"
 	"phenix/util/common"
 	"phenix/util/plog"
+	"phenix/web/oidc"
 	"phenix/web/rbac"
 	"phenix/web/weberror"
 	"strings"
@@ -15,9 +16,10 @@ import (
 type ServerOption func(*serverOptions)
 
 type serverOptions struct {
-	endpoint  string
-	users     []string
-	allowCORS bool
+	endpoint     string
+	grpcEndpoint string
+	users        []string
+	allowCORS    bool
 
 	tlsKeyPath string
 	tlsCrtPath string
@@ -34,6 +36,8 @@ type serverOptions struct {
 
 	proxyAuthHeader string
 
+	oidc oidc.Config
+
 	features map[string]bool
 
 	unixSocketGid int
@@ -90,6 +94,14 @@ func ServeOnEndpoint(e string) ServerOption {
 	}
 }
 
+// ServeGRPCOnEndpoint enables the gRPC API on the given endpoint. The gRPC API
+// is disabled if the endpoint is empty (the default).
+func ServeGRPCOnEndpoint(e string) ServerOption {
+	return func(o *serverOptions) {
+		o.grpcEndpoint = e
+	}
+}
+
 func ServeWithJWTKey(k string) ServerOption {
 	return func(o *serverOptions) {
 		o.jwtKey = k
@@ -159,6 +171,14 @@ func ServeWithProxyAuthHeader(h string) ServerOption {
 	}
 }
 
+// ServeWithOIDC enables logging into the UI via the given OpenID Connect
+// provider, in addition to any local users.
+func ServeWithOIDC(c oidc.Config) ServerOption {
+	return func(o *serverOptions) {
+		o.oidc = c
+	}
+}
+
 func ServeWithFeatures(f []string) ServerOption {
 	return func(o *serverOptions) {
 		if f == nil {
@@ -11,10 +11,12 @@ type Permission struct {
 
 var Permissions = []Permission{
 	{"applications", "list"},
+	{"audit", "list"},
 	{"configs", "create"},
 	{"configs", "delete"},
 	{"configs", "get"},
 	{"configs", "list"},
+	{"configs", "patch"},
 	{"configs", "update"},
 	{"disks", "list"},
 	{"exp/captureSubnet", "create"},
@@ -26,6 +28,10 @@ var Permissions = []Permission{
 	{"experiments", "update"},
 	{"experiments/apps", "get"},
 	{"experiments/captures", "list"},
+	{"experiments/checkpoints", "create"},
+	{"experiments/checkpoints", "delete"},
+	{"experiments/checkpoints", "list"},
+	{"experiments/checkpoints", "update"},
 	{"experiments/files", "get"},
 	{"experiments/files", "list"},
 	{"experiments/netflow", "create"},
@@ -33,6 +39,9 @@ var Permissions = []Permission{
 	{"experiments/netflow", "get"},
 	{"experiments/schedule", "create"},
 	{"experiments/schedule", "get"},
+	{"experiments/shares", "create"},
+	{"experiments/shares", "delete"},
+	{"experiments/shares", "list"},
 	{"experiments/start", "update"},
 	{"experiments/stop", "update"},
 	{"experiments/topology", "get"},
@@ -52,6 +61,7 @@ var Permissions = []Permission{
 	{"users", "get"},
 	{"users", "list"},
 	{"users", "patch"},
+	{"users/quotas", "patch"},
 	{"users/roles", "patch"},
 	{"vms", "delete"},
 	{"vms", "get"},
@@ -0,0 +1,94 @@
+package rbac
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"phenix/api/config"
+	"phenix/store"
+)
+
+// Ownership values for policies that are relative to experiment ownership.
+const (
+	OwnershipOwned  = "owned"
+	OwnershipShared = "shared"
+)
+
+// Experiment annotations used to record who owns an experiment and who it has
+// been shared with.
+const (
+	AnnotationOwner  = config.AnnotationOwner
+	AnnotationShares = config.AnnotationShares
+)
+
+type ownership struct {
+	owner  string
+	shares []config.Share
+
+	expires time.Time
+}
+
+// ownershipCacheTTL is how long experiment ownership is cached for. Roles can
+// live as long as a connection (e.g., for websocket clients and gRPC streams),
+// so ownership is only cached briefly to make sure changes to an experiment's
+// owner or shares (including newly created experiments) take effect.
+var ownershipCacheTTL = 5 * time.Second
+
+// ownershipCache caches experiment ownership for a role, since ownership
+// policies are often checked for every VM in an experiment.
+type ownershipCache struct {
+	sync.Mutex
+
+	experiments map[string]ownership
+}
+
+func (this Role) experimentOwnership(name string) ownership {
+	if this.owners != nil {
+		this.owners.Lock()
+		defer this.owners.Unlock()
+
+		if o, ok := this.owners.experiments[name]; ok && time.Now().Before(o.expires) {
+			return o
+		}
+	}
+
+	o := ownership{expires: time.Now().Add(ownershipCacheTTL)}
+
+	c, _ := store.NewConfig("experiment/" + name)
+
+	if err := store.Get(c); err == nil {
+		o.owner = c.Metadata.Annotations[AnnotationOwner]
+		o.shares = config.ParseShares(c.Metadata.Annotations[AnnotationShares])
+	}
+
+	if this.owners != nil {
+		this.owners.experiments[name] = o
+	}
+
+	return o
+}
+
+// ownershipAllowed determines if the given ownership policy applies to the
+// given resource name, which is either an experiment name or prefixed with one
+// (e.g., `<exp>/<vm>`).
+func (this Role) ownershipAllowed(policy Policy, name string) bool {
+	o := this.experimentOwnership(strings.SplitN(name, "/", 2)[0])
+
+	switch policy.Spec.Ownership {
+	case OwnershipOwned:
+		return this.user != "" && o.owner == this.user
+	case OwnershipShared:
+		for _, s := range o.shares {
+			if s.Kind == config.ShareKindUser && this.user != "" && s.Name == this.user {
+				return true
+			}
+
+			if s.Kind == config.ShareKindRole && s.Name == this.Spec.Name {
+				return true
+			}
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,146 @@
+package rbac
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"phenix/store"
+	v1 "phenix/types/version/v1"
+)
+
+func TestOwnershipPolicies(t *testing.T) {
+	defer func(ttl time.Duration) { ownershipCacheTTL = ttl }(ownershipCacheTTL)
+	ownershipCacheTTL = 50 * time.Millisecond
+
+	store.DefaultStore = store.NewBoltDB()
+
+	if err := store.DefaultStore.Init(store.Endpoint("bolt://" + filepath.Join(t.TempDir(), "phenix.bdb"))); err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+
+	experiments := map[string]map[string]string{
+		"mine":   {AnnotationOwner: "alice"},
+		"bobs":   {AnnotationOwner: "bob", AnnotationShares: "user:alice,role:Other Role"},
+		"roles":  {AnnotationOwner: "bob", AnnotationShares: "role:Owner Role"},
+		"others": {AnnotationOwner: "bob", AnnotationShares: "user:carol"},
+	}
+
+	for name, annotations := range experiments {
+		c := &store.Config{
+			Version:  "phenix.sandia.gov/v1",
+			Kind:     "Experiment",
+			Metadata: store.ConfigMetadata{Name: name, Annotations: annotations},
+			Spec:     map[string]any{"experimentName": name},
+		}
+
+		if err := store.Create(c); err != nil {
+			t.Log(err)
+			t.FailNow()
+		}
+	}
+
+	role := &Role{Spec: &v1.RoleSpec{
+		Name: "Owner Role",
+		Policies: []*v1.PolicySpec{
+			{Resources: []string{"experiments", "experiments/*", "vms", "vms/*"}, Verbs: []string{"*"}, Ownership: OwnershipOwned},
+			{Resources: []string{"experiments", "vms"}, Verbs: []string{"list", "get"}, Ownership: OwnershipShared},
+			{Resources: []string{"vms/vnc"}, ResourceNames: []string{"*/console-*"}, Verbs: []string{"get"}, Ownership: OwnershipShared},
+			{Resources: []string{"hosts"}, Verbs: []string{"list"}},
+		},
+	}}
+
+	// Ownership policies don't get resource names assigned to them.
+	if err := role.SetResourceNames("foo_*"); err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+
+	if role.Spec.Policies[0].ResourceNames != nil || role.Spec.Policies[3].ResourceNames[0] != "foo_*" {
+		t.Logf("unexpected resource names after setting them: %+v", role.Spec.Policies)
+		t.FailNow()
+	}
+
+	user := NewUser("alice", "foobar")
+	if user == nil {
+		t.Log("unable to create user")
+		t.FailNow()
+	}
+
+	if err := user.SetRole(role); err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+
+	// Make sure ownership policies survive a round trip through the store.
+	user, err := GetUser("alice")
+	if err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+
+	alice, err := user.Role()
+	if err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+
+	expect(alice.Allowed("experiments", "delete", "mine"), true, t)
+	expect(alice.Allowed("experiments/start", "update", "mine"), true, t)
+	expect(alice.Allowed("vms/redeploy", "update", "mine/vm1"), true, t)
+
+	// Shared with alice directly and with alice's role.
+	expect(alice.Allowed("experiments", "get", "bobs"), true, t)
+	expect(alice.Allowed("vms", "list", "roles/vm1"), true, t)
+	expect(alice.Allowed("experiments", "delete", "bobs"), false, t)
+	expect(alice.Allowed("experiments/start", "update", "roles"), false, t)
+
+	// Resource names further restrict ownership policies.
+	expect(alice.Allowed("vms/vnc", "get", "bobs/console-1"), true, t)
+	expect(alice.Allowed("vms/vnc", "get", "bobs/vm1"), false, t)
+
+	expect(alice.Allowed("experiments", "get", "others"), false, t)
+	expect(alice.Allowed("experiments", "get", "missing"), false, t)
+
+	// Changes to ownership take effect once cached ownership expires.
+	expect(alice.Allowed("experiments", "get", "new"), false, t)
+
+	c, _ := store.NewConfig("experiment/bobs")
+
+	if err := store.Get(c); err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+
+	c.Metadata.Annotations[AnnotationShares] = "role:Other Role"
+
+	if err := store.Update(c); err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+
+	c = &store.Config{
+		Version:  "phenix.sandia.gov/v1",
+		Kind:     "Experiment",
+		Metadata: store.ConfigMetadata{Name: "new", Annotations: map[string]string{AnnotationOwner: "alice"}},
+		Spec:     map[string]any{"experimentName": "new"},
+	}
+
+	if err := store.Create(c); err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+
+	time.Sleep(100 * time.Millisecond)
+
+	expect(alice.Allowed("experiments", "get", "bobs"), false, t)
+	expect(alice.Allowed("experiments", "get", "new"), true, t)
+
+	// Without a user, only shares with the role apply.
+	anonymous := Role{Spec: role.Spec}
+
+	expect(anonymous.Allowed("experiments", "delete", "mine"), false, t)
+	expect(anonymous.Allowed("experiments", "get", "roles"), true, t)
+	expect(anonymous.Allowed("experiments", "get", "bobs"), false, t)
+}
@@ -0,0 +1,30 @@
+package rbac
+
+import (
+	"fmt"
+
+	"phenix/api/quota"
+	v1 "phenix/types/version/v1"
+
+	"github.com/activeshadow/structs"
+)
+
+// Quota returns the user's effective resource quota, which is the quota of the
+// user's role with any limits set on the user itself taking precedence. A nil
+// quota means the user is not limited.
+func (this User) Quota() *v1.QuotaSpec {
+	return quota.Effective(this.Spec)
+}
+
+// SetQuota sets the user's own quota, overriding the limits of the user's role
+// quota. Passing nil removes the user's own quota.
+func (this *User) SetQuota(quota *v1.QuotaSpec) error {
+	this.Spec.Quota = quota
+	this.config.Spec = structs.MapDefaultCase(this.Spec, structs.CASESNAKE)
+
+	if err := this.Save(); err != nil {
+		return fmt.Errorf("setting user quota: %w", err)
+	}
+
+	return nil
+}
@@ -25,6 +25,14 @@ type Role struct {
 
 	config         *store.Config
 	mappedPolicies map[string][]Policy
+
+	// scope, if set, further restricts what the role allows (e.g., to the
+	// policies of a scoped API token).
+	scope *Role
+
+	// user the role belongs to, used to evaluate ownership policies.
+	user   string
+	owners *ownershipCache
 }
 
 func GetRoles() ([]*Role, error) {
@@ -92,6 +100,12 @@ func (this *Role) SetResourceNames(names ...string) error {
 	}
 
 	for _, policy := range this.Spec.Policies {
+		// Ownership policies apply to experiments based on who owns them, not
+		// their names.
+		if policy.Ownership != "" {
+			continue
+		}
+
 		if policy.ResourceNames != nil {
 			return fmt.Errorf("%w: resource names already exist for policy", ErrResourceNameExists)
 		}
@@ -112,6 +126,10 @@ func (this *Role) SetResourceNames(names ...string) error {
 
 func (this *Role) AddResourceName(name string) error {
 	for _, policy := range this.Spec.Policies {
+		if policy.Ownership != "" {
+			continue
+		}
+
 		for _, existing := range policy.ResourceNames {
 			if name == existing {
 				return fmt.Errorf("%w: %s", ErrResourceNameExists, name)
@@ -140,7 +158,28 @@ func (this *Role) AddPolicy(r, rn, v []string) {
 	this.Spec.Policies = append(this.Spec.Policies, policy)
 }
 
+// Scoped returns a copy of the role that only allows what is allowed by both
+// the role and the given policies. The role is returned as-is if no policies
+// are given.
+func (this Role) Scoped(policies []*v1.PolicySpec) Role {
+	if len(policies) == 0 {
+		return this
+	}
+
+	this.scope = &Role{
+		Spec:   &v1.RoleSpec{Name: this.Spec.Name, Policies: policies},
+		user:   this.user,
+		owners: this.owners,
+	}
+
+	return this
+}
+
 func (this Role) Allowed(resource, verb string, names ...string) bool {
+	if this.scope != nil && !this.scope.Allowed(resource, verb, names...) {
+		return false
+	}
+
 	for _, policy := range this.policiesForResource(resource) {
 		if policy.verbAllowed(verb) {
 			if len(names) == 0 {
@@ -148,7 +187,20 @@ func (this Role) Allowed(resource, verb string, names ...string) bool {
 			}
 
 			for _, n := range names {
-				if policy.resourceNameAllowed(n) {
+				if policy.Spec.Ownership == "" {
+					if policy.resourceNameAllowed(n) {
+						return true
+					}
+
+					continue
+				}
+
+				// Resource names further restrict ownership policies if provided.
+				if len(policy.Spec.ResourceNames) > 0 && !policy.resourceNameAllowed(n) {
+					continue
+				}
+
+				if this.ownershipAllowed(policy, n) {
 					return true
 				}
 			}
@@ -0,0 +1,259 @@
+package rbac
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	v1 "phenix/types/version/v1"
+	"phenix/util/plog"
+
+	"github.com/activeshadow/structs"
+	"github.com/dgrijalva/jwt-go"
+)
+
+var (
+	ErrTokenNotFound = errors.New("API token not found")
+	ErrTokenExpired  = errors.New("API token expired")
+	ErrTokenInvalid  = errors.New("API token invalid")
+	ErrTokenScope    = errors.New("API token policy not allowed by user role")
+)
+
+// APITokenUsageInterval limits how often the last used time and address of an
+// API token are persisted, since tokens can be used many times a second.
+var APITokenUsageInterval = time.Minute
+
+// CreateAPIToken creates a named API token for the user that expires after the
+// given lifetime, signing it with the given key. If any policies are given,
+// the token is restricted to them (they must also be allowed by the user's
+// current role). The role of the caller creating the token, if given, must
+// also allow the policies, so a caller using a scoped token can't create a
+// token with more permissions than its own. It returns the signed token, which
+// is not stored and cannot be retrieved again, and the stored token details.
+func (this *User) CreateAPIToken(key, name string, lifetime time.Duration, caller *Role, policies ...*v1.PolicySpec) (string, *v1.APITokenSpec, error) {
+	if lifetime <= 0 {
+		return "", nil, fmt.Errorf("API tokens must have a positive lifetime")
+	}
+
+	role, err := this.Role()
+	if err != nil {
+		return "", nil, fmt.Errorf("getting user role: %w", err)
+	}
+
+	// An unscoped token has all the permissions of the user's role, which a
+	// caller restricted by a scoped token doesn't have.
+	if caller != nil && caller.scope != nil && len(policies) == 0 {
+		return "", nil, fmt.Errorf("%w: scoped tokens can only create scoped tokens", ErrTokenScope)
+	}
+
+	for _, policy := range policies {
+		if len(policy.ResourceNames) == 0 {
+			policy.ResourceNames = []string{"*"}
+		}
+
+		if err := policyAllowed(role, policy); err != nil {
+			return "", nil, err
+		}
+
+		if caller != nil {
+			if err := policyAllowed(*caller, policy); err != nil {
+				return "", nil, err
+			}
+		}
+	}
+
+	id := make([]byte, 8)
+	rand.Read(id)
+
+	var (
+		now = time.Now()
+		exp = now.Add(lifetime)
+	)
+
+	spec := &v1.APITokenSpec{
+		ID:       hex.EncodeToString(id),
+		Name:     name,
+		Policies: policies,
+		Created:  now.Format(time.RFC3339),
+		Expires:  exp.Format(time.RFC3339),
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"sub": this.Username(),
+		"exp": exp.Unix(),
+		"jti": spec.ID,
+	})
+
+	signed, err := token.SignedString([]byte(key))
+	if err != nil {
+		return "", nil, fmt.Errorf("signing API token: %w", err)
+	}
+
+	spec.Hash = hashToken(signed)
+
+	this.Spec.APITokens = append(this.Spec.APITokens, spec)
+	this.config.Spec = structs.MapDefaultCase(this.Spec, structs.CASESNAKE)
+
+	if err := this.Save(); err != nil {
+		return "", nil, fmt.Errorf("persisting new API token: %w", err)
+	}
+
+	return signed, spec, nil
+}
+
+// APIToken returns the user's API token with the given ID.
+func (this User) APIToken(id string) (*v1.APITokenSpec, error) {
+	for _, token := range this.Spec.APITokens {
+		if token.ID == id {
+			return token, nil
+		}
+	}
+
+	return nil, fmt.Errorf("%w: %s", ErrTokenNotFound, id)
+}
+
+// RevokeAPIToken deletes the user's API token with the given ID, immediately
+// invalidating it.
+func (this *User) RevokeAPIToken(id string) error {
+	for i, token := range this.Spec.APITokens {
+		if token.ID != id {
+			continue
+		}
+
+		this.Spec.APITokens = append(this.Spec.APITokens[:i], this.Spec.APITokens[i+1:]...)
+		this.config.Spec = structs.MapDefaultCase(this.Spec, structs.CASESNAKE)
+
+		if _, ok := this.status.APITokens[id]; ok {
+			delete(this.status.APITokens, id)
+			this.config.Status = structs.MapDefaultCase(this.status, structs.CASESNAKE)
+		}
+
+		if err := this.Save(); err != nil {
+			return fmt.Errorf("revoking API token: %w", err)
+		}
+
+		return nil
+	}
+
+	return fmt.Errorf("%w: %s", ErrTokenNotFound, id)
+}
+
+// ValidateAPIToken confirms the given signed token is the user's API token
+// with the given ID and that it hasn't expired.
+func (this User) ValidateAPIToken(id, signed string) (*v1.APITokenSpec, error) {
+	token, err := this.APIToken(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if token.Hash != hashToken(signed) {
+		return nil, fmt.Errorf("%w: %s", ErrTokenInvalid, id)
+	}
+
+	exp, err := time.Parse(time.RFC3339, token.Expires)
+	if err != nil || time.Now().After(exp) {
+		return nil, fmt.Errorf("%w: %s", ErrTokenExpired, id)
+	}
+
+	return token, nil
+}
+
+// TouchAPIToken records the given address as the last one the user's API token
+// with the given ID was used from. Usage is recorded in the user config's
+// status so it doesn't create config revisions. To limit writes to the store,
+// usage is only persisted if the address changed or APITokenUsageInterval has
+// passed since the token was last used.
+func (this *User) TouchAPIToken(id, addr string) error {
+	token, err := this.APIToken(id)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+
+	if last, err := time.Parse(time.RFC3339, token.LastUsed); err == nil {
+		if token.LastUsedFrom == addr && now.Sub(last) < APITokenUsageInterval {
+			return nil
+		}
+	}
+
+	token.LastUsed = now.Format(time.RFC3339)
+	token.LastUsedFrom = addr
+
+	if this.status.APITokens == nil {
+		this.status.APITokens = make(map[string]*v1.APITokenUsage)
+	}
+
+	this.status.APITokens[id] = &v1.APITokenUsage{LastUsed: token.LastUsed, LastUsedFrom: addr}
+	this.config.Status = structs.MapDefaultCase(this.status, structs.CASESNAKE)
+
+	if err := this.Save(); err != nil {
+		return fmt.Errorf("recording API token usage: %w", err)
+	}
+
+	return nil
+}
+
+// TokenRole validates the given signed token, with the given (already
+// verified) claims, for the user and returns the role requests made with it
+// are subject to. For API tokens, this is the user's role scoped to the
+// token's policies, and usage of the token from the given address is recorded.
+// Other tokens must still be associated with the user (i.e., the user didn't
+// delete it because it became compromised).
+func (this *User) TokenRole(signed string, claims jwt.MapClaims, addr string) (Role, error) {
+	role, err := this.Role()
+	if err != nil {
+		return Role{}, fmt.Errorf("getting user role: %w", err)
+	}
+
+	id, _ := claims["jti"].(string)
+
+	if _, err := this.APIToken(id); id == "" || err != nil {
+		if err := this.ValidateToken(signed); err != nil {
+			return Role{}, err
+		}
+
+		return role, nil
+	}
+
+	token, err := this.ValidateAPIToken(id, signed)
+	if err != nil {
+		return Role{}, err
+	}
+
+	if err := this.TouchAPIToken(id, addr); err != nil {
+		plog.Error("recording API token usage", "user", this.Username(), "token", id, "err", err)
+	}
+
+	return role.Scoped(token.Policies), nil
+}
+
+// policyAllowed returns an error if the given policy grants anything not
+// already allowed by the given role.
+func policyAllowed(role Role, policy *v1.PolicySpec) error {
+	for _, resource := range policy.Resources {
+		for _, verb := range policy.Verbs {
+			for _, name := range policy.ResourceNames {
+				// Negated names only restrict the policy further.
+				if strings.HasPrefix(name, "!") {
+					continue
+				}
+
+				if !role.Allowed(resource, verb, name) {
+					return fmt.Errorf("%w: %s %s %s", ErrTokenScope, verb, resource, name)
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+func hashToken(signed string) string {
+	sum := sha256.Sum256([]byte(signed))
+	return hex.EncodeToString(sum[:])
+}
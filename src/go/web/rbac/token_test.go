@@ -0,0 +1,142 @@
+package rbac
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"phenix/store"
+	v1 "phenix/types/version/v1"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+func TestAPIToken(t *testing.T) {
+	store.DefaultStore = store.NewBoltDB()
+
+	if err := store.DefaultStore.Init(store.Endpoint("bolt://" + filepath.Join(t.TempDir(), "phenix.bdb"))); err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+
+	user := NewUser("foo@bar.com", "foobar")
+	if user == nil {
+		t.Log("unable to create user")
+		t.FailNow()
+	}
+
+	if err := user.SetRole(&role); err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+
+	// Tokens can't be scoped to more than the user's role allows.
+	escalated := &v1.PolicySpec{Resources: []string{"experiments"}, Verbs: []string{"delete"}}
+
+	if _, _, err := user.CreateAPIToken("secret", "bad", time.Hour, nil, escalated); !errors.Is(err, ErrTokenScope) {
+		t.Logf("expected ErrTokenScope creating escalated token, got %v", err)
+		t.FailNow()
+	}
+
+	scoped := &v1.PolicySpec{Resources: []string{"experiments"}, ResourceNames: []string{"exp1"}, Verbs: []string{"get", "delete"}}
+
+	signed, token, err := user.CreateAPIToken("secret", "ci", time.Hour, nil, scoped)
+	if err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+
+	// Make sure tokens survive a round trip through the store.
+	user, err = GetUser("foo@bar.com")
+	if err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+
+	parsed, err := jwt.Parse(signed, func(*jwt.Token) (interface{}, error) { return []byte("secret"), nil })
+	if err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+
+	claims := parsed.Claims.(jwt.MapClaims)
+
+	if claims["jti"] != token.ID {
+		t.Logf("expected token ID %s in claims, got %v", token.ID, claims["jti"])
+		t.FailNow()
+	}
+
+	revs, err := store.Revisions(user.config)
+	if err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+
+	scopedRole, err := user.TokenRole(signed, claims, "10.0.0.1")
+	if err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+
+	// Token usage is recorded in the user's status, so it isn't a config
+	// revision.
+	if after, _ := store.Revisions(user.config); len(after) != len(revs) {
+		t.Logf("expected recording token usage to not create a revision, got %d revisions (was %d)", len(after), len(revs))
+		t.FailNow()
+	}
+
+	if _, ok := user.config.Spec["api_tokens"].([]any)[0].(map[string]any)["last_used"]; ok {
+		t.Log("expected token usage to not be recorded in the user spec")
+		t.FailNow()
+	}
+
+	expect(scopedRole.Allowed("experiments", "get", "exp1"), true, t)
+	expect(scopedRole.Allowed("experiments", "delete", "exp1"), true, t)
+	expect(scopedRole.Allowed("experiments", "get", "expA"), false, t)
+	expect(scopedRole.Allowed("vms", "patch", "vm1"), false, t)
+
+	// Callers using a scoped token can't create tokens with more permissions.
+	if _, _, err := user.CreateAPIToken("secret", "unscoped", time.Hour, &scopedRole); !errors.Is(err, ErrTokenScope) {
+		t.Logf("expected ErrTokenScope creating unscoped token with scoped token, got %v", err)
+		t.FailNow()
+	}
+
+	wider := &v1.PolicySpec{Resources: []string{"experiments"}, ResourceNames: []string{"expA"}, Verbs: []string{"get"}}
+
+	if _, _, err := user.CreateAPIToken("secret", "wider", time.Hour, &scopedRole, wider); !errors.Is(err, ErrTokenScope) {
+		t.Logf("expected ErrTokenScope creating wider token with scoped token, got %v", err)
+		t.FailNow()
+	}
+
+	narrower := &v1.PolicySpec{Resources: []string{"experiments"}, ResourceNames: []string{"exp1"}, Verbs: []string{"get"}}
+
+	if _, _, err := user.CreateAPIToken("secret", "narrower", time.Hour, &scopedRole, narrower); err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+
+	user, _ = GetUser("foo@bar.com")
+
+	if user, _ := GetUser("foo@bar.com"); user.Spec.APITokens[0].LastUsedFrom != "10.0.0.1" {
+		t.Logf("expected token usage to be recorded, got %+v", user.Spec.APITokens[0])
+		t.FailNow()
+	}
+
+	if _, err := user.TokenRole(signed+"x", claims, "10.0.0.1"); !errors.Is(err, ErrTokenInvalid) {
+		t.Logf("expected ErrTokenInvalid for tampered token, got %v", err)
+		t.FailNow()
+	}
+
+	if err := user.RevokeAPIToken(token.ID); err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+
+	user, _ = GetUser("foo@bar.com")
+
+	if _, err := user.TokenRole(signed, claims, "10.0.0.1"); err == nil {
+		t.Log("expected revoked token to be rejected")
+		t.FailNow()
+	}
+}
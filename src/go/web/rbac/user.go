@@ -41,6 +41,20 @@ spec:
 			- bar_inverter
 			verbs:
 			- get
+		- resources:
+			- experiments
+			- "experiments/*"
+			verbs:
+			- "*"
+			ownership: owned
+		quota:
+			maxRunningExperiments: 2
+			maxVCPUs: 32
+			maxMemory: 65536
+			maxVMs: 16
+			maxCaptures: 4
+	quota:
+		maxVCPUs: 64
 */
 
 var ErrPasswordInvalid = fmt.Errorf("password invalid")
@@ -49,9 +63,33 @@ type User struct {
 	Spec *v1.UserSpec
 
 	config *store.Config
+	status *v1.UserStatus
 }
 
+// Annotations used to record the OpenID Connect identity (issuer and subject)
+// of users provisioned via OIDC login.
+const (
+	oidcIssuerAnnotation  = "oidc-issuer"
+	oidcSubjectAnnotation = "oidc-subject"
+)
+
 func NewUser(u, p string) *User {
+	return newUser(u, p, nil)
+}
+
+// NewOIDCUser creates a user provisioned via OIDC login for the given OIDC
+// issuer and subject. OIDC users never log in with a password, so they're
+// given one nobody knows.
+func NewOIDCUser(u, issuer, subject, password string) *User {
+	annotations := store.Annotations{
+		oidcIssuerAnnotation:  issuer,
+		oidcSubjectAnnotation: subject,
+	}
+
+	return newUser(u, password, annotations)
+}
+
+func newUser(u, p string, annotations store.Annotations) *User {
 	hashed, err := bcrypt.GenerateFromPassword([]byte(p), bcrypt.DefaultCost)
 	if err != nil {
 		return nil
@@ -65,7 +103,7 @@ func NewUser(u, p string) *User {
 	c := &store.Config{
 		Version:  "phenix.sandia.gov/v1",
 		Kind:     "User",
-		Metadata: store.ConfigMetadata{Name: u},
+		Metadata: store.ConfigMetadata{Name: u, Annotations: annotations},
 		Spec:     structs.MapDefaultCase(spec, structs.CASESNAKE),
 	}
 
@@ -73,7 +111,7 @@ func NewUser(u, p string) *User {
 		return nil
 	}
 
-	return &User{Spec: spec, config: c}
+	return &User{Spec: spec, config: c, status: new(v1.UserStatus)}
 }
 
 func GetUsers() ([]*User, error) {
@@ -85,12 +123,14 @@ func GetUsers() ([]*User, error) {
 	users := make([]*User, len(configs))
 
 	for i, c := range configs {
-		var u v1.UserSpec
-		if err := mapstructure.Decode(c.Spec, &u); err != nil {
-			return nil, fmt.Errorf("decoding user config: %w", err)
+		c := c
+
+		user, err := userFromConfig(&c)
+		if err != nil {
+			return nil, err
 		}
 
-		users[i] = &User{Spec: &u, config: &c}
+		users[i] = user
 	}
 
 	return users, nil
@@ -102,18 +142,53 @@ func GetUser(uname string) (*User, error) {
 		return nil, fmt.Errorf("getting user config: %w", err)
 	}
 
+	return userFromConfig(c)
+}
+
+func userFromConfig(c *store.Config) (*User, error) {
 	var u v1.UserSpec
 	if err := mapstructure.Decode(c.Spec, &u); err != nil {
 		return nil, fmt.Errorf("decoding user config: %w", err)
 	}
 
-	return &User{Spec: &u, config: c}, nil
+	var s v1.UserStatus
+	if err := mapstructure.Decode(c.Status, &s); err != nil {
+		return nil, fmt.Errorf("decoding user status: %w", err)
+	}
+
+	for _, token := range u.APITokens {
+		if usage, ok := s.APITokens[token.ID]; ok {
+			token.LastUsed = usage.LastUsed
+			token.LastUsedFrom = usage.LastUsedFrom
+		}
+	}
+
+	return &User{Spec: &u, config: c, status: &s}, nil
 }
 
 func (this User) Username() string {
 	return this.Spec.Username
 }
 
+// OIDCIdentity returns the OIDC issuer and subject the user was provisioned
+// for. It returns false if the user wasn't provisioned via OIDC login.
+func (this User) OIDCIdentity() (string, string, bool) {
+	if this.config == nil || this.config.Metadata.Annotations == nil {
+		return "", "", false
+	}
+
+	var (
+		issuer  = this.config.Metadata.Annotations[oidcIssuerAnnotation]
+		subject = this.config.Metadata.Annotations[oidcSubjectAnnotation]
+	)
+
+	if issuer == "" || subject == "" {
+		return "", "", false
+	}
+
+	return issuer, subject, true
+}
+
 func (this User) FirstName() string {
 	return this.Spec.FirstName
 }
@@ -269,7 +344,13 @@ func (this User) Role() (Role, error) {
 		return *disabled, nil
 	}
 
-	return Role{Spec: this.Spec.Role}, nil
+	role := Role{
+		Spec:   this.Spec.Role,
+		user:   this.Spec.Username,
+		owners: &ownershipCache{experiments: make(map[string]ownership)},
+	}
+
+	return role, nil
 }
 
 func (this *User) SetRole(role *Role) error {
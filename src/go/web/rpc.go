@@ -0,0 +1,542 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+
+	"phenix/api/experiment"
+	"phenix/api/vm"
+	"phenix/util/mm"
+	"phenix/util/plog"
+	"phenix/web/broker"
+	"phenix/web/cache"
+	"phenix/web/middleware"
+	"phenix/web/proto"
+	"phenix/web/rbac"
+	"phenix/web/util"
+	"phenix/web/weberror"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/status"
+)
+
+func startGRPC(o serverOptions) error {
+	opts := grpcInterceptors(o)
+
+	if o.tlsEnabled() {
+		creds, err := credentials.NewServerTLSFromFile(o.tlsCrtPath, o.tlsKeyPath)
+		if err != nil {
+			return fmt.Errorf("loading TLS credentials for gRPC server: %w", err)
+		}
+
+		opts = append(opts, grpc.Creds(creds))
+	}
+
+	listener, err := net.Listen("tcp", o.grpcEndpoint)
+	if err != nil {
+		return fmt.Errorf("listening on gRPC endpoint %s: %w", o.grpcEndpoint, err)
+	}
+
+	server := grpc.NewServer(opts...)
+	proto.RegisterPhenixServer(server, new(rpcServer))
+
+	plog.Info("starting gRPC server", "endpoint", o.grpcEndpoint)
+
+	go func() {
+		if err := server.Serve(listener); err != nil {
+			plog.Error("serving gRPC", "err", err)
+		}
+	}()
+
+	return nil
+}
+
+// grpcInterceptors returns the server options that authenticate gRPC requests
+// and audit the ones that change things.
+func grpcInterceptors(o serverOptions) []grpc.ServerOption {
+	unary, stream := middleware.GRPCAuth(o.jwtKey, o.proxyAuthHeader)
+
+	return []grpc.ServerOption{
+		// Requests have to be authenticated before they can be audited.
+		grpc.UnaryInterceptor(middleware.ChainUnary(unary, middleware.GRPCAudit())),
+		grpc.StreamInterceptor(stream),
+	}
+}
+
+// rpcServer implements the gRPC API, applying the same role-based access
+// control checks as the equivalent REST API handlers.
+type rpcServer struct {
+	proto.UnimplementedPhenixServer
+}
+
+func (rpcServer) ListExperiments(ctx context.Context, req *proto.ListExperimentsRequest) (*proto.ExperimentList, error) {
+	role := ctx.Value("role").(rbac.Role)
+
+	if !role.Allowed("experiments", "list") {
+		return nil, status.Error(codes.PermissionDenied, "listing experiments not allowed")
+	}
+
+	experiments, err := experiment.List()
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "getting experiments: %v", err)
+	}
+
+	resp := &proto.ExperimentList{Experiments: []*proto.Experiment{}}
+
+	for _, exp := range experiments {
+		if !role.Allowed("experiments", "list", exp.Metadata.Name) {
+			continue
+		}
+
+		vms, err := vm.List(exp.Spec.ExperimentName())
+		if err != nil {
+			plog.Error("listing VMs in experiment", "exp", exp.Metadata.Name, "err", err)
+		}
+
+		resp.Experiments = append(resp.Experiments, util.ExperimentToProtobuf(exp, rpcExperimentStatus(exp.Metadata.Name, exp.Running()), vms))
+	}
+
+	return resp, nil
+}
+
+func (rpcServer) GetExperiment(ctx context.Context, req *proto.ExperimentRequest) (*proto.Experiment, error) {
+	role := ctx.Value("role").(rbac.Role)
+
+	if !role.Allowed("experiments", "get", req.Name) {
+		return nil, status.Errorf(codes.PermissionDenied, "getting experiment %s not allowed", req.Name)
+	}
+
+	exp, err := experiment.Get(req.Name)
+	if err != nil {
+		return nil, status.Errorf(codes.NotFound, "getting experiment %s: %v", req.Name, err)
+	}
+
+	vms, err := vm.List(req.Name)
+	if err != nil {
+		plog.Error("listing VMs in experiment", "exp", req.Name, "err", err)
+	}
+
+	allowed := mm.VMs{}
+
+	for _, vm := range vms {
+		if role.Allowed("vms", "list", fmt.Sprintf("%s/%s", req.Name, vm.Name)) {
+			allowed = append(allowed, vm)
+		}
+	}
+
+	return util.ExperimentToProtobuf(*exp, rpcExperimentStatus(req.Name, exp.Running()), allowed), nil
+}
+
+func (rpcServer) StartExperiment(ctx context.Context, req *proto.ExperimentRequest) (*proto.Experiment, error) {
+	role := ctx.Value("role").(rbac.Role)
+
+	if !role.Allowed("experiments/start", "update", req.Name) {
+		return nil, status.Errorf(codes.PermissionDenied, "starting experiment %s not allowed", req.Name)
+	}
+
+	body, err := startExperiment(req.Name, ctx.Value("user").(string))
+	if err != nil {
+		return nil, rpcError(err)
+	}
+
+	var exp proto.Experiment
+
+	if err := unmarshaler.Unmarshal(body, &exp); err != nil {
+		return nil, status.Errorf(codes.Internal, "processing experiment %s: %v", req.Name, err)
+	}
+
+	return &exp, nil
+}
+
+func (rpcServer) StopExperiment(ctx context.Context, req *proto.ExperimentRequest) (*proto.Experiment, error) {
+	role := ctx.Value("role").(rbac.Role)
+
+	if !role.Allowed("experiments/stop", "update", req.Name) {
+		return nil, status.Errorf(codes.PermissionDenied, "stopping experiment %s not allowed", req.Name)
+	}
+
+	body, err := stopExperiment(req.Name)
+	if err != nil {
+		return nil, rpcError(err)
+	}
+
+	var exp proto.Experiment
+
+	if err := unmarshaler.Unmarshal(body, &exp); err != nil {
+		return nil, status.Errorf(codes.Internal, "processing experiment %s: %v", req.Name, err)
+	}
+
+	return &exp, nil
+}
+
+func (rpcServer) ListVMs(ctx context.Context, req *proto.ListVMsRequest) (*proto.VMList, error) {
+	role := ctx.Value("role").(rbac.Role)
+
+	if !role.Allowed("vms", "list") {
+		return nil, status.Error(codes.PermissionDenied, "listing VMs not allowed")
+	}
+
+	exp, err := experiment.Get(req.Experiment)
+	if err != nil {
+		return nil, status.Errorf(codes.NotFound, "getting experiment %s: %v", req.Experiment, err)
+	}
+
+	vms, err := vm.List(req.Experiment)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "listing VMs in experiment %s: %v", req.Experiment, err)
+	}
+
+	resp := &proto.VMList{Vms: []*proto.VM{}}
+
+	for _, vm := range vms {
+		if role.Allowed("vms", "list", fmt.Sprintf("%s/%s", req.Experiment, vm.Name)) {
+			resp.Vms = append(resp.Vms, util.VMToProtobuf(req.Experiment, vm, exp.Spec.Topology()))
+		}
+	}
+
+	resp.Total = uint32(len(resp.Vms))
+
+	return resp, nil
+}
+
+func (rpcServer) GetVM(ctx context.Context, req *proto.VMRequest) (*proto.VM, error) {
+	role := ctx.Value("role").(rbac.Role)
+
+	if !role.Allowed("vms", "get", fmt.Sprintf("%s/%s", req.Experiment, req.Name)) {
+		return nil, status.Errorf(codes.PermissionDenied, "getting VM %s/%s not allowed", req.Experiment, req.Name)
+	}
+
+	exp, err := experiment.Get(req.Experiment)
+	if err != nil {
+		return nil, status.Errorf(codes.NotFound, "getting experiment %s: %v", req.Experiment, err)
+	}
+
+	vm, err := vm.Get(req.Experiment, req.Name)
+	if err != nil {
+		return nil, status.Errorf(codes.NotFound, "getting VM %s/%s: %v", req.Experiment, req.Name, err)
+	}
+
+	return util.VMToProtobuf(req.Experiment, *vm, exp.Spec.Topology()), nil
+}
+
+func (rpcServer) ListUsers(ctx context.Context, req *proto.ListUsersRequest) (*proto.UserList, error) {
+	var (
+		uname = ctx.Value("user").(string)
+		role  = ctx.Value("role").(rbac.Role)
+	)
+
+	resp := &proto.UserList{Users: []*proto.User{}}
+
+	if role.Allowed("users", "list") {
+		users, err := rbac.GetUsers()
+		if err != nil {
+			return nil, status.Errorf(codes.Internal, "getting users: %v", err)
+		}
+
+		for _, user := range users {
+			if role.Allowed("users", "list", user.Username()) {
+				resp.Users = append(resp.Users, rpcUser(*user))
+			}
+		}
+	} else if role.Allowed("users", "get", uname) {
+		user, err := rbac.GetUser(uname)
+		if err != nil {
+			return nil, status.Errorf(codes.Internal, "getting user %s: %v", uname, err)
+		}
+
+		resp.Users = append(resp.Users, rpcUser(*user))
+	} else {
+		return nil, status.Error(codes.PermissionDenied, "listing users not allowed")
+	}
+
+	return resp, nil
+}
+
+func (rpcServer) GetUser(ctx context.Context, req *proto.UserRequest) (*proto.User, error) {
+	role := ctx.Value("role").(rbac.Role)
+
+	if !role.Allowed("users", "get", req.Username) {
+		return nil, status.Errorf(codes.PermissionDenied, "getting user %s not allowed", req.Username)
+	}
+
+	user, err := rbac.GetUser(req.Username)
+	if err != nil {
+		return nil, status.Errorf(codes.NotFound, "getting user %s: %v", req.Username, err)
+	}
+
+	return rpcUser(*user), nil
+}
+
+func (rpcServer) ListRoles(ctx context.Context, req *proto.ListRolesRequest) (*proto.RoleList, error) {
+	role := ctx.Value("role").(rbac.Role)
+
+	if !role.Allowed("roles", "list") {
+		return nil, status.Error(codes.PermissionDenied, "listing roles not allowed")
+	}
+
+	roles, err := rbac.GetRoles()
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "getting roles: %v", err)
+	}
+
+	resp := &proto.RoleList{Roles: []*proto.Role{}}
+
+	for _, r := range roles {
+		resp.Roles = append(resp.Roles, rpcRole(*r))
+	}
+
+	return resp, nil
+}
+
+func (rpcServer) StreamLogs(req *proto.StreamLogsRequest, stream proto.Phenix_StreamLogsServer) error {
+	var (
+		ctx     = stream.Context()
+		role    = ctx.Value("role").(rbac.Role)
+		sources = make(map[string]struct{})
+	)
+
+	for _, source := range req.Sources {
+		sources[source] = struct{}{}
+	}
+
+	pubs, unsubscribe := broker.Subscribe(role)
+	defer unsubscribe()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case pub, ok := <-pubs:
+			if !ok {
+				return status.Error(codes.ResourceExhausted, "log stream fell too far behind")
+			}
+
+			if pub.Resource == nil || pub.Resource.Type != "log" {
+				continue
+			}
+
+			if _, ok := sources[pub.Resource.Name]; len(sources) > 0 && !ok {
+				continue
+			}
+
+			var log struct {
+				Timestamp string `json:"timestamp"`
+				Source    string `json:"source"`
+				Level     string `json:"level"`
+				Log       string `json:"log"`
+				Epoch     int64  `json:"epoch"`
+			}
+
+			if err := json.Unmarshal(pub.Result, &log); err != nil {
+				continue
+			}
+
+			msg := &proto.Log{
+				Timestamp: log.Timestamp,
+				Source:    log.Source,
+				Level:     log.Level,
+				Log:       log.Log,
+				Epoch:     log.Epoch,
+			}
+
+			if err := stream.Send(msg); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func (rpcServer) StreamPublications(req *proto.StreamPublicationsRequest, stream proto.Phenix_StreamPublicationsServer) error {
+	var (
+		ctx   = stream.Context()
+		role  = ctx.Value("role").(rbac.Role)
+		types = make(map[string]struct{})
+	)
+
+	for _, typ := range req.Types {
+		types[typ] = struct{}{}
+	}
+
+	pubs, unsubscribe := broker.Subscribe(role)
+	defer unsubscribe()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case pub, ok := <-pubs:
+			if !ok {
+				return status.Error(codes.ResourceExhausted, "publication stream fell too far behind")
+			}
+
+			if pub.Resource == nil {
+				continue
+			}
+
+			if _, ok := types[pub.Resource.Type]; len(types) > 0 && !ok {
+				continue
+			}
+
+			msg := &proto.Publication{
+				Type:   pub.Resource.Type,
+				Name:   pub.Resource.Name,
+				Action: pub.Resource.Action,
+				Result: string(pub.Result),
+			}
+
+			if err := stream.Send(msg); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func (rpcServer) StreamVMStates(req *proto.StreamVMStatesRequest, stream proto.Phenix_StreamVMStatesServer) error {
+	var (
+		ctx      = stream.Context()
+		role     = ctx.Value("role").(rbac.Role)
+		interval = 5 * time.Second
+	)
+
+	if !role.Allowed("vms", "list") {
+		return status.Error(codes.PermissionDenied, "listing VMs not allowed")
+	}
+
+	if req.IntervalSeconds > 0 {
+		interval = time.Duration(req.IntervalSeconds) * time.Second
+	}
+
+	exp, err := experiment.Get(req.Experiment)
+	if err != nil {
+		return status.Errorf(codes.NotFound, "getting experiment %s: %v", req.Experiment, err)
+	}
+
+	type state struct {
+		host    string
+		state   string
+		running bool
+		busy    bool
+	}
+
+	var (
+		states = make(map[string]state)
+		ticker = time.NewTicker(interval)
+	)
+
+	defer ticker.Stop()
+
+	for {
+		vms, err := vm.List(req.Experiment)
+		if err != nil {
+			return status.Errorf(codes.Internal, "listing VMs in experiment %s: %v", req.Experiment, err)
+		}
+
+		for _, vm := range vms {
+			if !role.Allowed("vms", "list", fmt.Sprintf("%s/%s", req.Experiment, vm.Name)) {
+				continue
+			}
+
+			current := state{host: vm.Host, state: vm.State, running: vm.Running, busy: vm.Busy}
+
+			if prev, ok := states[vm.Name]; ok && prev == current {
+				continue
+			}
+
+			states[vm.Name] = current
+
+			if err := stream.Send(util.VMToProtobuf(req.Experiment, vm, exp.Spec.Topology())); err != nil {
+				return err
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+func rpcExperimentStatus(name string, running bool) cache.Status {
+	// This will happen if another handler is currently acting on the
+	// experiment.
+	status := cache.IsExperimentLocked(name)
+
+	if status == "" {
+		if running {
+			status = cache.StatusStarted
+		} else {
+			status = cache.StatusStopped
+		}
+	}
+
+	return status
+}
+
+func rpcUser(u rbac.User) *proto.User {
+	var (
+		user = userFromRBAC(u)
+		role = new(proto.Role)
+	)
+
+	if r, err := u.Role(); err == nil {
+		role = rpcRole(r)
+	}
+
+	return &proto.User{
+		Username:      user.Username,
+		FirstName:     user.FirstName,
+		LastName:      user.LastName,
+		ResourceNames: user.ResourceNames,
+		Role:          role,
+	}
+}
+
+func rpcRole(r rbac.Role) *proto.Role {
+	role := roleFromRBAC(r)
+
+	policies := make([]*proto.Policy, len(role.Policies))
+	for i, p := range role.Policies {
+		policies[i] = &proto.Policy{
+			Resources:     p.Resources,
+			ResourceNames: p.ResourceNames,
+			Verbs:         p.Verbs,
+		}
+	}
+
+	return &proto.Role{Name: role.Name, Policies: policies}
+}
+
+// rpcError converts errors returned by the functions shared with the REST API
+// handlers to gRPC status errors.
+func rpcError(err error) error {
+	var webErr *weberror.WebError
+
+	if !errors.As(err, &webErr) {
+		return status.Error(codes.Unknown, err.Error())
+	}
+
+	code := codes.Unknown
+
+	switch webErr.Status {
+	case http.StatusBadRequest:
+		code = codes.InvalidArgument
+	case http.StatusForbidden:
+		code = codes.PermissionDenied
+	case http.StatusNotFound:
+		code = codes.NotFound
+	case http.StatusConflict:
+		code = codes.FailedPrecondition
+	case http.StatusInternalServerError:
+		code = codes.Internal
+	}
+
+	return status.Error(code, webErr.Error())
+}
@@ -0,0 +1,233 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"phenix/api/audit"
+	"phenix/store"
+	v1 "phenix/types/version/v1"
+	"phenix/web/broker"
+	bt "phenix/web/broker/brokertypes"
+	"phenix/web/proto"
+	"phenix/web/rbac"
+
+	"github.com/dgrijalva/jwt-go"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+var startBroker sync.Once
+
+// rpcClient serves the phenix gRPC API over an in-memory connection, using the
+// same interceptors as the web server, and returns a client for
+// it along with a valid auth token for a user named alice.
+func rpcClient(t *testing.T) (proto.PhenixClient, string) {
+	store.DefaultStore = store.NewBoltDB()
+
+	if err := store.DefaultStore.Init(store.Endpoint("bolt://" + filepath.Join(t.TempDir(), "phenix.bdb"))); err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+
+	user := rbac.NewUser("alice", "password")
+
+	role := &rbac.Role{
+		Spec: &v1.RoleSpec{
+			Name: "Test",
+			Policies: []*v1.PolicySpec{
+				{Resources: []string{"users"}, ResourceNames: []string{"alice"}, Verbs: []string{"get"}},
+				{Resources: []string{"experiments"}, ResourceNames: []string{"foo"}, Verbs: []string{"get"}},
+			},
+		},
+	}
+
+	if err := user.SetRole(role); err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+
+	claims := jwt.MapClaims{"sub": "alice", "exp": time.Now().Add(time.Hour).Unix()}
+
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte("secret"))
+	if err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+
+	if err := user.AddToken(token, "test"); err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+
+	var (
+		lis    = bufconn.Listen(1024 * 1024)
+		opts   = grpcInterceptors(serverOptions{jwtKey: "secret", proxyAuthHeader: "X-Forwarded-User"})
+		server = grpc.NewServer(opts...)
+	)
+
+	proto.RegisterPhenixServer(server, new(rpcServer))
+
+	go server.Serve(lis)
+	t.Cleanup(server.Stop)
+
+	dialer := func(context.Context, string) (net.Conn, error) {
+		return lis.Dial()
+	}
+
+	conn, err := grpc.DialContext(context.Background(), "bufnet", grpc.WithContextDialer(dialer), grpc.WithInsecure())
+	if err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+
+	t.Cleanup(func() { conn.Close() })
+
+	return proto.NewPhenixClient(conn), token
+}
+
+func authContext(token, user string) context.Context {
+	return metadata.AppendToOutgoingContext(context.Background(), "x-phenix-auth-token", "Bearer "+token, "x-forwarded-user", user)
+}
+
+func TestRPCAuth(t *testing.T) {
+	client, token := rpcClient(t)
+
+	unauthenticated := map[string]context.Context{
+		"missing token":       context.Background(),
+		"bad bearer format":   metadata.AppendToOutgoingContext(context.Background(), "x-phenix-auth-token", token, "x-forwarded-user", "alice"),
+		"proxy user mismatch": authContext(token, "bob"),
+	}
+
+	for name, ctx := range unauthenticated {
+		if _, err := client.GetUser(ctx, &proto.UserRequest{Username: "alice"}); status.Code(err) != codes.Unauthenticated {
+			t.Logf("%s: expected unauthenticated error, got %v", name, err)
+			t.FailNow()
+		}
+	}
+
+	ctx := authContext(token, "alice")
+
+	user, err := client.GetUser(ctx, &proto.UserRequest{Username: "alice"})
+	if err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+
+	if user.Username != "alice" || user.GetRole().GetName() != "Test" {
+		t.Logf("unexpected user %+v", user)
+		t.FailNow()
+	}
+
+	if _, err := client.GetUser(ctx, &proto.UserRequest{Username: "bob"}); status.Code(err) != codes.PermissionDenied {
+		t.Logf("expected permission denied getting another user, got %v", err)
+		t.FailNow()
+	}
+
+	if _, err := client.GetExperiment(ctx, &proto.ExperimentRequest{Name: "bar"}); status.Code(err) != codes.PermissionDenied {
+		t.Logf("expected permission denied getting experiment, got %v", err)
+		t.FailNow()
+	}
+
+	if _, err := client.ListRoles(ctx, &proto.ListRolesRequest{}); status.Code(err) != codes.PermissionDenied {
+		t.Logf("expected permission denied listing roles, got %v", err)
+		t.FailNow()
+	}
+}
+
+func TestRPCStreamLogs(t *testing.T) {
+	client, token := rpcClient(t)
+
+	startBroker.Do(func() { go broker.Start() })
+
+	ctx, cancel := context.WithTimeout(authContext(token, "alice"), 5*time.Second)
+	defer cancel()
+
+	stream, err := client.StreamLogs(ctx, &proto.StreamLogsRequest{Sources: []string{"phenix"}})
+	if err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+
+	// The stream subscribes to the broker asynchronously, so keep publishing
+	// logs from both sources until some have been received.
+	go func() {
+		mm, _ := json.Marshal(map[string]any{"source": "minimega", "level": "INFO", "log": "from minimega"})
+
+		for {
+			broker.Broadcast(nil, bt.NewResource("log", "minimega", "update"), mm)
+			PublishPhenixLog(time.Now(), "info", "from phenix")
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(10 * time.Millisecond):
+			}
+		}
+	}()
+
+	for i := 0; i < 5; i++ {
+		log, err := stream.Recv()
+		if err != nil {
+			t.Log(err)
+			t.FailNow()
+		}
+
+		if log.Source != "phenix" || log.Log != "from phenix" || log.Level != "INFO" {
+			t.Logf("expected only phenix logs, got %+v", log)
+			t.FailNow()
+		}
+	}
+}
+
+func TestRPCAudit(t *testing.T) {
+	client, token := rpcClient(t)
+
+	ctx := authContext(token, "alice")
+
+	if _, err := client.GetUser(ctx, &proto.UserRequest{Username: "alice"}); err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+
+	if _, err := client.StartExperiment(ctx, &proto.ExperimentRequest{Name: "foo"}); status.Code(err) != codes.PermissionDenied {
+		t.Logf("expected permission denied starting experiment, got %v", err)
+		t.FailNow()
+	}
+
+	entries, err := audit.List()
+	if err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+
+	if len(entries) != 1 {
+		t.Logf("expected only the call to start the experiment to be audited, got %+v", entries)
+		t.FailNow()
+	}
+
+	entry := entries[0]
+
+	if entry.Origin != audit.OriginGRPC || entry.User != "alice" || entry.Role != "Test" || entry.Verb != "update" {
+		t.Logf("unexpected audit entry %+v", entry)
+		t.FailNow()
+	}
+
+	if entry.Resource != "experiments/start" || entry.ResourceName != "foo" || entry.Experiment != "foo" {
+		t.Logf("unexpected audit entry resource %+v", entry)
+		t.FailNow()
+	}
+
+	if entry.Outcome != audit.OutcomeFailure || entry.Digest == "" {
+		t.Logf("expected failed audit entry with digest, got %+v", entry)
+		t.FailNow()
+	}
+}
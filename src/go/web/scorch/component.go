@@ -11,6 +11,7 @@ type ComponentUpdate struct {
 	Run     int    // experiment run
 	Loop    int    // current loop
 	Count   int    // current loop count
+	Params  string // current loop matrix parameters
 	Stage   string // component stage
 	Status  string // component status
 	Output  []byte // component output
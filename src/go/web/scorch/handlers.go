@@ -15,6 +15,7 @@ import (
 	"phenix/api/experiment"
 	"phenix/api/scorch/scorchexe"
 	"phenix/api/scorch/scorchmd"
+	"phenix/api/scorch/scorchresult"
 	"phenix/app"
 	"phenix/util/plog"
 	"phenix/util/pubsub"
@@ -678,3 +679,150 @@ func CancelPipeline(w http.ResponseWriter, r *http.Request) error {
 	w.WriteHeader(http.StatusNoContent)
 	return nil
 }
+
+// GET /experiments/{name}/scorch/results
+func GetResults(w http.ResponseWriter, r *http.Request) error {
+	plog.Debug("HTTP handler called", "handler", "GetScorchResults")
+
+	var (
+		ctx  = r.Context()
+		role = ctx.Value("role").(rbac.Role)
+		vars = mux.Vars(r)
+		name = vars["name"]
+		run  = -1
+	)
+
+	if !role.Allowed("experiments", "get", name) {
+		err := weberror.NewWebError(nil, "getting experiment %s not allowed for %s", name, ctx.Value("user").(string))
+		return err.SetStatus(http.StatusForbidden)
+	}
+
+	if id := r.URL.Query().Get("run"); id != "" {
+		var err error
+
+		if run, err = strconv.Atoi(id); err != nil {
+			return weberror.NewWebError(err, "invalid run ID '%s' provided", id).SetStatus(http.StatusBadRequest)
+		}
+	}
+
+	exp, err := experiment.Get(name)
+	if err != nil {
+		return weberror.NewWebError(err, "unable to get experiment %s from store", name)
+	}
+
+	results, err := scorchresult.List(exp.FilesDir(), run)
+	if err != nil {
+		err := weberror.NewWebError(err, "unable to get Scorch results for experiment %s", name)
+		return err.SetStatus(http.StatusInternalServerError)
+	}
+
+	if results == nil {
+		results = []scorchresult.Run{}
+	}
+
+	body, _ := json.Marshal(util.WithRoot("results", results))
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(body)
+
+	return nil
+}
+
+// GET /experiments/{name}/scorch/results/{id}
+func GetResult(w http.ResponseWriter, r *http.Request) error {
+	plog.Debug("HTTP handler called", "handler", "GetScorchResult")
+
+	var (
+		ctx   = r.Context()
+		role  = ctx.Value("role").(rbac.Role)
+		vars  = mux.Vars(r)
+		name  = vars["name"]
+		id    = vars["id"]
+		query = r.URL.Query()
+	)
+
+	if !role.Allowed("experiments", "get", name) {
+		err := weberror.NewWebError(nil, "getting experiment %s not allowed for %s", name, ctx.Value("user").(string))
+		return err.SetStatus(http.StatusForbidden)
+	}
+
+	exp, err := experiment.Get(name)
+	if err != nil {
+		return weberror.NewWebError(err, "unable to get experiment %s from store", name)
+	}
+
+	getResult := func(id string) (*scorchresult.Run, error) {
+		result, err := scorchresult.Get(exp.FilesDir(), id)
+		if err != nil {
+			status := http.StatusBadRequest
+
+			if errors.Is(err, scorchresult.ErrResultsNotFound) {
+				status = http.StatusNotFound
+			}
+
+			return nil, weberror.NewWebError(err, "unable to get Scorch results %s for experiment %s", id, name).SetStatus(status)
+		}
+
+		return result, nil
+	}
+
+	result, err := getResult(id)
+	if err != nil {
+		return err
+	}
+
+	// If other results to compare against are provided, respond with the
+	// differences between the two instead of the results themselves.
+	if other := query.Get("diff"); other != "" {
+		to, err := getResult(other)
+		if err != nil {
+			return err
+		}
+
+		diffs := scorchresult.Diff(result, to)
+
+		if diffs == nil {
+			diffs = []scorchresult.Difference{}
+		}
+
+		body, _ := json.Marshal(map[string]interface{}{"a": result.ID, "b": to.ID, "differences": diffs})
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(body)
+
+		return nil
+	}
+
+	var buf bytes.Buffer
+
+	switch format := query.Get("format"); format {
+	case "", "json":
+		body, _ := json.Marshal(result)
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(body)
+
+		return nil
+	case "junit":
+		err = result.JUnit(&buf)
+
+		w.Header().Set("Content-Type", "application/xml")
+		w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s_%s.xml"`, name, id))
+	case "csv":
+		err = result.CSV(&buf)
+
+		w.Header().Set("Content-Type", "text/csv")
+		w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s_%s.csv"`, name, id))
+	default:
+		return weberror.NewWebError(nil, "unknown Scorch results format '%s' provided", format).SetStatus(http.StatusBadRequest)
+	}
+
+	if err != nil {
+		err := weberror.NewWebError(err, "unable to export Scorch results %s for experiment %s", id, name)
+		return err.SetStatus(http.StatusInternalServerError)
+	}
+
+	w.Write(buf.Bytes())
+
+	return nil
+}
@@ -21,6 +21,7 @@ Valid status codes client-side:
 	failure
 	paused
 	unstable
+	skipped
 	end
 */
 
@@ -37,6 +38,27 @@ type node struct {
 
 	idx   int
 	edges map[int]*edge
+
+	// Failure of the node doesn't fail its stage.
+	continueOnError bool
+
+	// The node is a failure handler that only runs if another node fails.
+	optional bool
+}
+
+// complete returns true if the node is done running (or won't be run), and
+// whether it failed in a way that fails its stage.
+func (this *node) complete() (bool, bool) {
+	switch this.Status {
+	case "success", "background", "skipped":
+		return true, false
+	case "failure":
+		return true, !this.continueOnError
+	case "unknown":
+		return this.optional, false
+	default:
+		return false, false
+	}
 }
 
 func (this *node) addEdge(n *node, weight int) {
@@ -67,6 +89,7 @@ type pipeline struct {
 	Pipeline []*node   `json:"pipeline"`
 	Loop     *pipeline `json:"loop,omitempty"`
 	Name     string    `json:"name,omitempty"`
+	Params   string    `json:"params,omitempty"` // current loop matrix parameters
 
 	exp    string
 	runID  int
@@ -79,12 +102,14 @@ type pipeline struct {
 	cleanup *node
 	done    *node
 	loop    *node
+	finally *node
 
 	// component nodes
 	configs  map[string]*node
 	starts   map[string]*node
 	stops    map[string]*node
 	cleanups map[string]*node
+	finals   map[string]*node
 }
 
 func (this *pipeline) addNode(stage string, node *node) {
@@ -104,6 +129,8 @@ func (this *pipeline) addNode(stage string, node *node) {
 		this.stops[node.Name] = node
 	case "cleanup":
 		this.cleanups[node.Name] = node
+	case "finally":
+		this.finals[node.Name] = node
 	}
 }
 
@@ -119,7 +146,82 @@ func (this *pipeline) addComponentToStage(stage string, node *node) {
 		this.stop.addEdge(node, 0)
 	case "cleanup":
 		this.cleanup.addEdge(node, 0)
+	case "finally":
+		this.finally.addEdge(node, 0)
+	}
+}
+
+// addStage adds nodes for the given stage components to the pipeline, along
+// with nodes for their failure handlers, connecting them to the given next
+// node.
+func (this *pipeline) addStage(stage string, names []string, specs scorchmd.ComponentSpecMap, next *node) {
+	if len(names) == 0 {
+		this.addComponentToStage(stage, next)
+		return
+	}
+
+	nodes := this.stageNodes(stage)
+
+	for _, cmp := range names {
+		n := &node{Name: cmp, Status: "unknown", continueOnError: specs[cmp].ContinueOnError}
+		n.addEdge(next, 0)
+
+		this.addNode(stage, n)
+		this.addComponentToStage(stage, n)
+	}
+
+	// Failure handlers can have failure handlers of their own, so keep going
+	// until there are no new handlers to add.
+	for i := 0; i < len(names); i++ {
+		cmp := names[i]
+
+		for _, handler := range specs[cmp].OnFailure {
+			h, ok := nodes[handler]
+			if !ok {
+				h = &node{
+					Name:            handler,
+					Hint:            "runs if " + cmp + " fails",
+					Status:          "unknown",
+					Stage:           stage,
+					continueOnError: specs[handler].ContinueOnError,
+					optional:        true,
+				}
+
+				h.addEdge(next, 0)
+
+				this.addNode(stage, h)
+				names = append(names, handler)
+			}
+
+			nodes[cmp].addEdge(h, 0)
+		}
+	}
+}
+
+func (this *pipeline) stageNodes(stage string) map[string]*node {
+	switch stage {
+	case "configure":
+		return this.configs
+	case "start":
+		return this.starts
+	case "stop":
+		return this.stops
+	case "cleanup":
+		return this.cleanups
+	case "finally":
+		return this.finals
+	}
+
+	return nil
+}
+
+// afterCleanup returns the node that follows the cleanup stage.
+func (this *pipeline) afterCleanup() *node {
+	if this.finally != nil {
+		return this.finally
 	}
+
+	return this.done
 }
 
 func (this *pipeline) setStageStatus(stage string, status string) bool {
@@ -155,7 +257,18 @@ func (this *pipeline) setStageStatus(stage string, status string) bool {
 
 		switch status {
 		case "success", "failure":
-			this.cleanup.updateEdge(this.done, 2)
+			this.cleanup.updateEdge(this.afterCleanup(), 2)
+		}
+	case "finally":
+		if this.finally == nil {
+			return false
+		}
+
+		this.finally.Status = status
+
+		switch status {
+		case "success", "failure":
+			this.finally.updateEdge(this.done, 2)
 		}
 	case "done":
 		this.done.Status = status
@@ -178,166 +291,95 @@ func (this *pipeline) setStageStatus(stage string, status string) bool {
 }
 
 func (this *pipeline) updateNodeStatus(stage string, name string, status string) bool {
+	var (
+		stageNode *node
+		next      *node // node following the stage
+		abort     *node // node the stage skips to on failure, if any
+	)
+
 	switch stage {
 	case "configure":
-		node, ok := this.configs[name]
-		if !ok {
-			return false
-		}
-
-		node.Status = status
-
-		switch status {
-		case "running", "unstable":
-			this.config.Status = "running"
-			this.config.updateEdge(node, 2)
-		case "background":
-			this.config.Status = "running"
-			this.config.updateEdge(node, 2)
-
-			fallthrough
-		case "success":
-			complete := true
-
-			for _, v := range this.configs {
-				if v.Status != "background" && v.Status != "success" {
-					complete = false
-					break
-				}
-			}
-
-			if complete {
-				this.config.Status = "success"
-
-				for _, v := range this.configs {
-					v.updateEdge(this.start, 2)
-				}
-			}
-		case "failure":
-			this.config.Status = "failure"
-			this.config.addEdge(this.cleanup, 2)
-		}
+		stageNode, next, abort = this.config, this.start, this.cleanup
 	case "start":
-		node, ok := this.starts[name]
-		if !ok {
-			return false
-		}
-
-		node.Status = status
+		stageNode, next, abort = this.start, this.stop, this.stop
 
-		switch status {
-		case "running", "unstable":
-			this.start.Status = "running"
-			this.start.updateEdge(node, 2)
-		case "background":
-			this.start.Status = "running"
-			this.start.updateEdge(node, 2)
-
-			fallthrough
-		case "success":
-			complete := true
-
-			for _, v := range this.starts {
-				if v.Status != "background" && v.Status != "success" {
-					complete = false
-					break
-				}
-			}
-
-			if complete {
-				this.start.Status = "success"
-
-				for _, v := range this.starts {
-					next := this.stop
-					if this.loop != nil {
-						next = this.loop
-					}
-
-					v.updateEdge(next, 2)
-				}
-			}
-		case "failure":
-			this.start.Status = "failure"
-			this.start.addEdge(this.stop, 2)
+		if this.loop != nil {
+			next = this.loop
 		}
 	case "stop":
-		node, ok := this.stops[name]
-		if !ok {
-			return false
-		}
-
-		node.Status = status
+		stageNode, next = this.stop, this.cleanup
+	case "cleanup":
+		stageNode, next = this.cleanup, this.afterCleanup()
+	case "finally":
+		stageNode, next = this.finally, this.done
+	default:
+		return false
+	}
 
-		switch status {
-		case "running", "unstable":
-			this.stop.Status = "running"
-			this.stop.updateEdge(node, 2)
-		}
+	nodes := this.stageNodes(stage)
 
-		complete := true
-		finalStatus := "success"
+	node, ok := nodes[name]
+	if !ok || stageNode == nil {
+		return false
+	}
 
-		for _, v := range this.stops {
-			switch v.Status {
-			case "success":
-			case "failure":
-				finalStatus = "failure"
-			default:
-				complete = false
-			}
+	node.Status = status
 
-			if !complete {
-				break
-			}
+	switch status {
+	case "running", "unstable", "background":
+		// Failure handlers run after their stage may have already failed.
+		if !node.optional {
+			stageNode.Status = "running"
 		}
 
-		if complete {
-			this.stop.Status = finalStatus
+		// highlight the edge from the stage (or the failed component, for failure
+		// handlers) to the node
+		stageNode.updateEdge(node, 2)
 
-			for _, v := range this.stops {
-				v.updateEdge(this.cleanup, 2)
+		for _, v := range nodes {
+			if v.Status == "failure" {
+				v.updateEdge(node, 2)
 			}
 		}
-	case "cleanup":
-		node, ok := this.cleanups[name]
-		if !ok {
-			return false
+	}
+
+	if abort != nil {
+		if stageNode.Status == "failure" {
+			return true
 		}
 
-		node.Status = status
+		if _, failed := node.complete(); failed {
+			stageNode.Status = "failure"
+			stageNode.addEdge(abort, 2)
 
-		switch status {
-		case "running", "unstable":
-			this.cleanup.Status = "running"
-			this.cleanup.updateEdge(node, 2)
+			return true
 		}
+	}
 
-		complete := true
-		finalStatus := "success"
+	var (
+		complete    = true
+		finalStatus = "success"
+	)
 
-		for _, v := range this.cleanups {
-			switch v.Status {
-			case "success":
-			case "failure":
-				finalStatus = "failure"
-			default:
-				complete = false
-			}
+	for _, v := range nodes {
+		done, failed := v.complete()
 
-			if !complete {
-				break
-			}
+		if !done {
+			complete = false
+			break
+		}
+
+		if failed {
+			finalStatus = "failure"
 		}
+	}
 
-		if complete {
-			this.cleanup.Status = finalStatus
+	if complete {
+		stageNode.Status = finalStatus
 
-			for _, v := range this.cleanups {
-				v.updateEdge(this.done, 2)
-			}
+		for _, v := range nodes {
+			v.updateEdge(next, 2)
 		}
-	default:
-		return false
 	}
 
 	return true
@@ -370,6 +412,7 @@ func newPipeline(exp, name string, run, loop int) *pipeline {
 		starts:   make(map[string]*node),
 		stops:    make(map[string]*node),
 		cleanups: make(map[string]*node),
+		finals:   make(map[string]*node),
 	}
 }
 
@@ -405,20 +448,20 @@ func getPipeline(name string, run, loop int) (*pipeline, error) {
 		exe = exe.Loop
 	}
 
-	pl := newPipeline(name, runName, run, loop)
+	var (
+		pl    = newPipeline(name, runName, run, loop)
+		specs = md.ComponentSpecs()
+	)
 
-	if len(exe.Configure) == 0 {
-		pl.addComponentToStage("configure", pl.start)
-	} else {
-		for _, cmp := range exe.Configure {
-			n := &node{Name: cmp, Status: "unknown"}
-			n.addEdge(pl.start, 0)
+	if len(exe.Finally) > 0 {
+		pl.finally = &node{Name: "finally", Status: "unknown"}
+		pl.finally.addEdge(pl.done, 0)
 
-			pl.addNode("configure", n)
-			pl.addComponentToStage("configure", n)
-		}
+		pl.addNode("", pl.finally)
 	}
 
+	pl.addStage("configure", exe.Configure, specs, pl.start)
+
 	var next *node
 
 	if exe.Loop == nil {
@@ -431,41 +474,10 @@ func getPipeline(name string, run, loop int) (*pipeline, error) {
 		pl.loop = next
 	}
 
-	if len(exe.Start) == 0 {
-		pl.addComponentToStage("start", next)
-	} else {
-		for _, cmp := range exe.Start {
-			n := &node{Name: cmp, Status: "unknown"}
-			n.addEdge(next, 0)
-
-			pl.addNode("start", n)
-			pl.addComponentToStage("start", n)
-		}
-	}
-
-	if len(exe.Stop) == 0 {
-		pl.addComponentToStage("stop", pl.cleanup)
-	} else {
-		for _, cmp := range exe.Stop {
-			n := &node{Name: cmp, Status: "unknown"}
-			n.addEdge(pl.cleanup, 0)
-
-			pl.addNode("stop", n)
-			pl.addComponentToStage("stop", n)
-		}
-	}
-
-	if len(exe.Cleanup) == 0 {
-		pl.addComponentToStage("cleanup", pl.done)
-	} else {
-		for _, cmp := range exe.Cleanup {
-			n := &node{Name: cmp, Status: "unknown"}
-			n.addEdge(pl.done, 0)
-
-			pl.addNode("cleanup", n)
-			pl.addComponentToStage("cleanup", n)
-		}
-	}
+	pl.addStage("start", exe.Start, specs, next)
+	pl.addStage("stop", exe.Stop, specs, pl.cleanup)
+	pl.addStage("cleanup", exe.Cleanup, specs, pl.afterCleanup())
+	pl.addStage("finally", exe.Finally, specs, pl.done)
 
 	if _, ok := pipelines[name]; !ok {
 		pipelines[name] = make(map[int]map[int]*pipeline)
@@ -485,6 +497,10 @@ func updatePipeline(update PipelineUpdate) error {
 		return fmt.Errorf("getting pipeline %d for experiment %s: %w", update.Run, update.Exp, err)
 	}
 
+	if update.Params != "" {
+		pl.Params = update.Params
+	}
+
 	if update.CmpName == "" {
 		if pl.setStageStatus(update.Stage, update.Status) {
 			broadcastPipeline(update.Exp, update.Run, update.Loop, pl)
@@ -0,0 +1,71 @@
+package scorch
+
+import (
+	"testing"
+
+	"phenix/api/scorch/scorchmd"
+)
+
+func TestPipelineFailureHandling(t *testing.T) {
+	specs := scorchmd.ComponentSpecMap{
+		"flaky":  {Name: "flaky", ContinueOnError: true},
+		"deploy": {Name: "deploy", OnFailure: []string{"notify"}},
+		"notify": {Name: "notify"},
+	}
+
+	pl := newPipeline("foo", "run", 0, 0)
+
+	pl.finally = &node{Name: "finally", Status: "unknown"}
+	pl.finally.addEdge(pl.done, 0)
+	pl.addNode("", pl.finally)
+
+	pl.addStage("configure", []string{"flaky"}, specs, pl.start)
+	pl.addStage("start", []string{"deploy"}, specs, pl.stop)
+	pl.addStage("stop", nil, specs, pl.cleanup)
+	pl.addStage("cleanup", nil, specs, pl.afterCleanup())
+	pl.addStage("finally", []string{"collect"}, specs, pl.done)
+
+	if _, ok := pl.starts["notify"]; !ok {
+		t.Log("expected failure handler node to be added to start stage")
+		t.FailNow()
+	}
+
+	// A failed component configured to continue on error shouldn't fail the
+	// stage.
+	pl.updateNodeStatus("configure", "flaky", "failure")
+
+	if pl.config.Status != "success" {
+		t.Logf("expected configure stage success, got %s", pl.config.Status)
+		t.FailNow()
+	}
+
+	pl.updateNodeStatus("start", "deploy", "failure")
+
+	if pl.start.Status != "failure" {
+		t.Logf("expected start stage failure, got %s", pl.start.Status)
+		t.FailNow()
+	}
+
+	// The failure handler running after the stage failed shouldn't change the
+	// status of the stage.
+	pl.updateNodeStatus("start", "notify", "running")
+
+	if pl.start.Status != "failure" {
+		t.Logf("expected start stage to remain failed, got %s", pl.start.Status)
+		t.FailNow()
+	}
+
+	pl.setStageStatus("cleanup", "success")
+
+	if e, ok := pl.cleanup.edges[pl.finally.idx]; !ok || e.Weight != 2 {
+		t.Log("expected cleanup stage to lead to finally stage")
+		t.FailNow()
+	}
+
+	pl.updateNodeStatus("finally", "collect", "skipped")
+
+	if pl.finally.Status != "success" {
+		t.Logf("expected finally stage success, got %s", pl.finally.Status)
+		t.FailNow()
+	}
+}
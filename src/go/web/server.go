@@ -2,6 +2,7 @@ package web
 
 import (
 	"context"
+	"fmt"
 	"net"
 	"net/http"
 	"os"
@@ -12,6 +13,7 @@ import (
 	"phenix/web/broker"
 	"phenix/web/forward"
 	"phenix/web/middleware"
+	"phenix/web/oidc"
 	"phenix/web/rbac"
 	"phenix/web/scorch"
 	"phenix/web/util"
@@ -29,24 +31,28 @@ type route struct {
 
 var o serverOptions
 
-func ConfigureUsers(users []string) error {
-	setUserRole := func(user *rbac.User, rname string, resources ...string) {
-		if role, err := rbac.RoleFromConfig(rname); err == nil {
-			role.SetResourceNames(resources...)
-
-			// allow user to get their own user details
-			role.AddPolicy(
-				[]string{"users"},
-				[]string{user.Username()},
-				[]string{"get"},
-			)
-
-			user.SetRole(role)
-		} else {
-			plog.Error("getting role for user", "user", user.Username(), "role", rname, "err", err)
-		}
+// setUserRole sets the given user's role to a copy of the named role, limited
+// to the given resource names, that also allows the user to get their own user
+// details.
+func setUserRole(user *rbac.User, rname string, resources ...string) error {
+	role, err := rbac.RoleFromConfig(rname)
+	if err != nil {
+		return fmt.Errorf("getting role %s: %w", rname, err)
 	}
 
+	role.SetResourceNames(resources...)
+
+	// allow user to get their own user details
+	role.AddPolicy(
+		[]string{"users"},
+		[]string{user.Username()},
+		[]string{"get"},
+	)
+
+	return user.SetRole(role)
+}
+
+func ConfigureUsers(users []string) error {
 	for _, u := range users {
 		creds := strings.Split(u, ":")
 		uname := creds[0]
@@ -59,7 +65,9 @@ func ConfigureUsers(users []string) error {
 			if user.RoleName() != rname {
 				plog.Debug("updating role for existing user", "user", user.Username(), "old", user.RoleName(), "new", rname)
 
-				setUserRole(user, rname, creds[3:]...)
+				if err := setUserRole(user, rname, creds[3:]...); err != nil {
+					plog.Error("setting role for user", "user", user.Username(), "role", rname, "err", err)
+				}
 			}
 
 			continue
@@ -69,7 +77,9 @@ func ConfigureUsers(users []string) error {
 
 		user := rbac.NewUser(uname, pword)
 
-		setUserRole(user, rname, creds[3:]...)
+		if err := setUserRole(user, rname, creds[3:]...); err != nil {
+			plog.Error("setting role for user", "user", user.Username(), "role", rname, "err", err)
+		}
 	}
 
 	return nil
@@ -146,7 +156,11 @@ func Start(opts ...ServerOption) error {
 	api.Handle("/configs", weberror.ErrorHandler(CreateConfig)).Methods("POST", "OPTIONS")
 	api.Handle("/configs/{kind}/{name}", weberror.ErrorHandler(GetConfig)).Methods("GET", "OPTIONS")
 	api.Handle("/configs/{kind}/{name}", weberror.ErrorHandler(UpdateConfig)).Methods("PUT", "OPTIONS")
+	api.Handle("/configs/{kind}/{name}", weberror.ErrorHandler(PatchConfig)).Methods("PATCH", "OPTIONS")
 	api.Handle("/configs/{kind}/{name}", weberror.ErrorHandler(DeleteConfig)).Methods("DELETE", "OPTIONS")
+	api.Handle("/configs/{kind}/{name}/revisions", weberror.ErrorHandler(GetConfigRevisions)).Methods("GET", "OPTIONS")
+	api.Handle("/configs/{kind}/{name}/revisions/{revision}", weberror.ErrorHandler(GetConfigRevision)).Methods("GET", "OPTIONS")
+	api.Handle("/configs/{kind}/{name}/revisions/{revision}", weberror.ErrorHandler(RollbackConfig)).Methods("POST", "OPTIONS")
 	api.Handle("/configs/download", weberror.ErrorHandler(DownloadConfigs)).Methods("POST", "OPTIONS")
 	api.Handle("/schemas/{version}", weberror.ErrorHandler(GetSchemaSpec)).Methods("GET", "OPTIONS")
 	api.Handle("/schemas/{kind}/{version}", weberror.ErrorHandler(GetSchema)).Methods("GET", "OPTIONS")
@@ -157,7 +171,15 @@ func Start(opts ...ServerOption) error {
 	api.Handle("/experiments/{name}", weberror.ErrorHandler(GetExperiment)).Methods("GET", "OPTIONS")
 	api.Handle("/experiments/{name}", weberror.ErrorHandler(UpdateExperiment)).Methods("PATCH", "OPTIONS")
 	api.HandleFunc("/experiments/{name}", DeleteExperiment).Methods("DELETE", "OPTIONS")
+	api.Handle("/experiments/{name}/clone", weberror.ErrorHandler(CloneExperiment)).Methods("POST", "OPTIONS")
+	api.Handle("/experiments/{name}/shares", weberror.ErrorHandler(GetExperimentShares)).Methods("GET", "OPTIONS")
+	api.Handle("/experiments/{name}/shares", weberror.ErrorHandler(CreateExperimentShare)).Methods("POST", "OPTIONS")
+	api.Handle("/experiments/{name}/shares/{kind}/{principal}", weberror.ErrorHandler(DeleteExperimentShare)).Methods("DELETE", "OPTIONS")
 	api.Handle("/experiments/{name}/apps", weberror.ErrorHandler(GetExperimentApps)).Methods("GET", "OPTIONS")
+	api.Handle("/experiments/{name}/checkpoints", weberror.ErrorHandler(GetExperimentCheckpoints)).Methods("GET", "OPTIONS")
+	api.Handle("/experiments/{name}/checkpoints", weberror.ErrorHandler(CreateExperimentCheckpoint)).Methods("POST", "OPTIONS")
+	api.Handle("/experiments/{name}/checkpoints/{checkpoint}", weberror.ErrorHandler(RestoreExperimentCheckpoint)).Methods("POST", "OPTIONS")
+	api.Handle("/experiments/{name}/checkpoints/{checkpoint}", weberror.ErrorHandler(DeleteExperimentCheckpoint)).Methods("DELETE", "OPTIONS")
 	api.Handle("/experiments/{name}/start", weberror.ErrorHandler(StartExperiment)).Methods("POST", "OPTIONS")
 	api.Handle("/experiments/{name}/stop", weberror.ErrorHandler(StopExperiment)).Methods("POST", "OPTIONS")
 	api.HandleFunc("/experiments/{exp}/netflow", GetNetflow).Methods("GET", "OPTIONS")
@@ -181,6 +203,8 @@ func Start(opts ...ServerOption) error {
 	api.Handle("/experiments/{name}/scorch/pipelines/{run}/{loop}", weberror.ErrorHandler(scorch.GetPipeline)).Methods("GET", "OPTIONS")
 	api.Handle("/experiments/{name}/scorch/pipelines/{run}", weberror.ErrorHandler(scorch.StartPipeline)).Methods("POST", "OPTIONS")
 	api.Handle("/experiments/{name}/scorch/pipelines/{run}", weberror.ErrorHandler(scorch.CancelPipeline)).Methods("DELETE", "OPTIONS")
+	api.Handle("/experiments/{name}/scorch/results", weberror.ErrorHandler(scorch.GetResults)).Methods("GET", "OPTIONS")
+	api.Handle("/experiments/{name}/scorch/results/{id}", weberror.ErrorHandler(scorch.GetResult)).Methods("GET", "OPTIONS")
 	api.HandleFunc("/experiments/{name}/scorch/terminals", scorch.GetTerminals).Methods("GET", "OPTIONS")
 	api.HandleFunc("/experiments/{name}/scorch/terminals/{pid}", scorch.ConnectTerminal).Methods("GET", "OPTIONS")
 	api.HandleFunc("/experiments/{name}/scorch/terminals/{pid}/exit/{id}", scorch.ExitTerminal).Methods("POST", "OPTIONS")
@@ -235,12 +259,40 @@ func Start(opts ...ServerOption) error {
 	api.HandleFunc("/users/{username}", GetUser).Methods("GET", "OPTIONS")
 	api.HandleFunc("/users/{username}", UpdateUser).Methods("PATCH", "OPTIONS")
 	api.HandleFunc("/users/{username}", DeleteUser).Methods("DELETE", "OPTIONS")
+	api.HandleFunc("/users/{username}/usage", GetUserUsage).Methods("GET", "OPTIONS")
+	api.HandleFunc("/users/{username}/tokens", GetUserTokens).Methods("GET", "OPTIONS")
 	api.HandleFunc("/users/{username}/tokens", CreateUserToken).Methods("POST", "OPTIONS")
+	api.HandleFunc("/users/{username}/tokens/{id}", GetUserToken).Methods("GET", "OPTIONS")
+	api.HandleFunc("/users/{username}/tokens/{id}", DeleteUserToken).Methods("DELETE", "OPTIONS")
 	api.HandleFunc("/roles", GetRoles).Methods("GET", "OPTIONS")
 	api.HandleFunc("/signup", Signup).Methods("POST", "OPTIONS")
 	api.HandleFunc("/login", Login).Methods("GET", "POST", "OPTIONS")
+
+	// Routes that don't require authentication, in addition to signup and login.
+	var public []string
+
+	if o.oidc.Enabled() {
+		provider, err := oidc.NewProvider(context.Background(), o.oidc)
+		if err != nil {
+			return fmt.Errorf("configuring OIDC provider: %w", err)
+		}
+
+		oidcProvider = provider
+		o.features["oidc"] = true
+
+		plog.Info("OIDC login is enabled", "issuer", o.oidc.Issuer)
+
+		api.HandleFunc("/login/oidc", OIDCLogin).Methods("GET", "OPTIONS")
+		api.HandleFunc("/login/oidc/callback", OIDCCallback).Methods("GET", "OPTIONS")
+		api.HandleFunc("/login/oidc/session", OIDCSession).Methods("GET", "OPTIONS")
+
+		// The OIDC login flow happens before the user has a phenix JWT.
+		public = append(public, "/api/v1/login/oidc", "/api/v1/login/oidc/callback", "/api/v1/login/oidc/session")
+	}
+
 	api.HandleFunc("/logout", Logout).Methods("GET", "OPTIONS")
 	api.Handle("/history", weberror.ErrorHandler(GetHistory)).Methods("POST", "OPTIONS")
+	api.Handle("/audit", weberror.ErrorHandler(GetAudit)).Methods("GET", "OPTIONS")
 	api.HandleFunc("/ws", broker.ServeWS).Methods("GET")
 	api.HandleFunc("/console", CreateConsole).Methods("POST", "OPTIONS")
 	api.HandleFunc("/console/{pid}/ws", WsConsole).Methods("GET", "OPTIONS")
@@ -277,7 +329,8 @@ func Start(opts ...ServerOption) error {
 		api.Use(middleware.LogRequests)
 	}
 
-	api.Use(middleware.Auth(o.jwtKey, o.proxyAuthHeader))
+	api.Use(middleware.Auth(o.jwtKey, o.proxyAuthHeader, public...))
+	api.Use(middleware.Audit)
 
 	plog.Info("starting websockets broker")
 
@@ -291,6 +344,12 @@ func Start(opts ...ServerOption) error {
 
 	go PublishMinimegaLogs(context.Background(), o.minimegaLogs)
 
+	if o.grpcEndpoint != "" {
+		if err := startGRPC(o); err != nil {
+			return err
+		}
+	}
+
 	plog.Info("using base path", "path", o.basePath)
 	plog.Info("using JWT lifetime", "lifetime", o.jwtLifetime)
 
@@ -305,6 +364,7 @@ func Start(opts ...ServerOption) error {
 		addRoutesToRouter(api, optionRoutes...)
 
 		api.Use(middleware.NoAuth)
+		api.Use(middleware.Audit)
 
 		os.Remove(common.UnixSocket)
 
@@ -0,0 +1,139 @@
+package web
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+
+	"phenix/api/config"
+	"phenix/api/experiment"
+	"phenix/store"
+	"phenix/util/plog"
+	"phenix/web/rbac"
+	"phenix/web/weberror"
+
+	"github.com/gorilla/mux"
+)
+
+// GET /experiments/{name}/shares
+func GetExperimentShares(w http.ResponseWriter, r *http.Request) error {
+	plog.Debug("HTTP handler called", "handler", "GetExperimentShares")
+
+	var (
+		ctx  = r.Context()
+		role = ctx.Value("role").(rbac.Role)
+		vars = mux.Vars(r)
+		name = vars["name"]
+	)
+
+	if !role.Allowed("experiments/shares", "list", name) {
+		err := weberror.NewWebError(nil, "listing shares for experiment %s not allowed for %s", name, ctx.Value("user").(string))
+		return err.SetStatus(http.StatusForbidden)
+	}
+
+	owner, shares, err := experiment.Ownership(name)
+	if err != nil {
+		return shareError(err, "unable to get shares for experiment %s", name)
+	}
+
+	if shares == nil {
+		shares = []config.Share{}
+	}
+
+	body, err := json.Marshal(ExperimentShares{Owner: owner, Shares: shares})
+	if err != nil {
+		err := weberror.NewWebError(err, "unable to process shares for experiment %s", name)
+		return err.SetStatus(http.StatusInternalServerError)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(body)
+
+	return nil
+}
+
+// POST /experiments/{name}/shares
+func CreateExperimentShare(w http.ResponseWriter, r *http.Request) error {
+	plog.Debug("HTTP handler called", "handler", "CreateExperimentShare")
+
+	var (
+		ctx  = r.Context()
+		role = ctx.Value("role").(rbac.Role)
+		vars = mux.Vars(r)
+		name = vars["name"]
+	)
+
+	if !role.Allowed("experiments/shares", "create", name) {
+		err := weberror.NewWebError(nil, "sharing experiment %s not allowed for %s", name, ctx.Value("user").(string))
+		return err.SetStatus(http.StatusForbidden)
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		err := weberror.NewWebError(err, "unable to read request body")
+		return err.SetStatus(http.StatusInternalServerError)
+	}
+
+	var req config.Share
+
+	if err := json.Unmarshal(body, &req); err != nil {
+		return weberror.NewWebError(err, "unable to parse share request for experiment %s", name)
+	}
+
+	share, err := experiment.Share(name, req)
+	if err != nil {
+		return shareError(err, "unable to share experiment %s with %s", name, req)
+	}
+
+	plog.Info("experiment shared", "exp", name, "share", share.String(), "user", ctx.Value("user").(string))
+
+	body, _ = json.Marshal(share)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	w.Write(body)
+
+	return nil
+}
+
+// DELETE /experiments/{name}/shares/{kind}/{principal}
+func DeleteExperimentShare(w http.ResponseWriter, r *http.Request) error {
+	plog.Debug("HTTP handler called", "handler", "DeleteExperimentShare")
+
+	var (
+		ctx   = r.Context()
+		role  = ctx.Value("role").(rbac.Role)
+		vars  = mux.Vars(r)
+		name  = vars["name"]
+		share = config.Share{Kind: vars["kind"], Name: vars["principal"]}
+	)
+
+	if !role.Allowed("experiments/shares", "delete", name) {
+		err := weberror.NewWebError(nil, "unsharing experiment %s not allowed for %s", name, ctx.Value("user").(string))
+		return err.SetStatus(http.StatusForbidden)
+	}
+
+	if err := experiment.Unshare(name, share); err != nil {
+		return shareError(err, "unable to unshare experiment %s with %s", name, share)
+	}
+
+	plog.Info("experiment unshared", "exp", name, "share", share.String(), "user", ctx.Value("user").(string))
+
+	w.WriteHeader(http.StatusNoContent)
+
+	return nil
+}
+
+func shareError(err error, format string, args ...any) error {
+	webErr := weberror.NewWebError(err, format, args...)
+
+	switch {
+	case errors.Is(err, store.ErrNotExist):
+		return webErr.SetStatus(http.StatusNotFound)
+	case errors.Is(err, experiment.ErrShareExists):
+		return webErr.SetStatus(http.StatusConflict)
+	}
+
+	return webErr.SetStatus(http.StatusBadRequest)
+}
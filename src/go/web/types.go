@@ -1,8 +1,12 @@
 package web
 
 import (
+	"encoding/json"
 	"sort"
 
+	"phenix/api/config"
+	"phenix/api/quota"
+	v1 "phenix/types/version/v1"
 	"phenix/web/rbac"
 )
 
@@ -26,19 +30,59 @@ type UpdateUserRequest struct {
 	CreateUserRequest
 
 	NewPassword string `json:"new_password"`
+
+	// Quota replaces the user's own quota if set. An empty quota removes it.
+	Quota *v1.QuotaSpec `json:"quota"`
+}
+
+type UserUsage struct {
+	// Quota is the user's effective quota (nil if unlimited).
+	Quota *v1.QuotaSpec `json:"quota"`
+	Usage quota.Usage   `json:"usage"`
 }
 
 type CreateTokenRequest struct {
-	Lifetime    string `json:"lifetime"`
-	Description string `json:"desc"`
+	Name        string   `json:"name"`
+	Lifetime    string   `json:"lifetime"`
+	Description string   `json:"desc"`
+	Policies    []Policy `json:"policies"`
 }
 
 type CreateTokenResponse struct {
+	APIToken
+
 	Token       string `json:"token"`
 	Description string `json:"desc"`
 	Expiration  string `json:"exp"`
 }
 
+type APIToken struct {
+	ID           string   `json:"id"`
+	Name         string   `json:"name"`
+	Policies     []Policy `json:"policies"`
+	Created      string   `json:"created"`
+	Expires      string   `json:"expires"`
+	LastUsed     string   `json:"last_used,omitempty"`
+	LastUsedFrom string   `json:"last_used_from,omitempty"`
+}
+
+type ExperimentShares struct {
+	Owner  string         `json:"owner"`
+	Shares []config.Share `json:"shares"`
+}
+
+type CloneExperimentRequest struct {
+	Name          string          `json:"name"`
+	DefaultBridge string          `json:"default_bridge"`
+	Files         string          `json:"files"`
+	TopologyPatch json.RawMessage `json:"topology_patch"`
+	ScenarioPatch json.RawMessage `json:"scenario_patch"`
+}
+
+type CreateCheckpointRequest struct {
+	Name string `json:"name"`
+}
+
 type LoginRequest struct {
 	Username string `json:"user"`
 	Password string `json:"pass"`
@@ -62,6 +106,7 @@ type Policy struct {
 	Resources     []string `json:"resources"`
 	ResourceNames []string `json:"resourceNames"`
 	Verbs         []string `json:"verbs"`
+	Ownership     string   `json:"ownership,omitempty"`
 }
 
 type Role struct {
@@ -90,6 +135,7 @@ func roleFromRBAC(r rbac.Role) Role {
 			Resources:     p.Resources,
 			ResourceNames: p.ResourceNames,
 			Verbs:         p.Verbs,
+			Ownership:     p.Ownership,
 		}
 	}
 
@@ -131,3 +177,25 @@ func resourceNamesFromRBAC(r rbac.Role) []string {
 	sort.Strings(rnames)
 	return rnames
 }
+
+func apiTokenFromRBAC(t *v1.APITokenSpec) APIToken {
+	policies := make([]Policy, len(t.Policies))
+	for i, p := range t.Policies {
+		policies[i] = Policy{
+			Resources:     p.Resources,
+			ResourceNames: p.ResourceNames,
+			Verbs:         p.Verbs,
+			Ownership:     p.Ownership,
+		}
+	}
+
+	return APIToken{
+		ID:           t.ID,
+		Name:         t.Name,
+		Policies:     policies,
+		Created:      t.Created,
+		Expires:      t.Expires,
+		LastUsed:     t.LastUsed,
+		LastUsedFrom: t.LastUsedFrom,
+	}
+}
@@ -6,6 +6,8 @@ import (
 	"net/http"
 
 	"phenix/api/config"
+	"phenix/api/quota"
+	v1 "phenix/types/version/v1"
 	"phenix/util/plog"
 	"phenix/web/broker"
 	"phenix/web/rbac"
@@ -251,6 +253,20 @@ func UpdateUser(w http.ResponseWriter, r *http.Request) {
 		u.SetRole(uRole)
 	}
 
+	if req.Quota != nil && role.Allowed("users/quotas", "patch", uname) {
+		q := req.Quota
+
+		if *q == (v1.QuotaSpec{}) {
+			q = nil
+		}
+
+		if err := u.SetQuota(q); err != nil {
+			plog.Error("updating quota for user", "user", uname, "err", err)
+			http.Error(w, "unable to update user", http.StatusInternalServerError)
+			return
+		}
+	}
+
 	if req.NewPassword != "" {
 		if req.Password == "" {
 			plog.Error("new password provided without old password", "user", uname)
@@ -283,6 +299,47 @@ func UpdateUser(w http.ResponseWriter, r *http.Request) {
 	w.Write(body)
 }
 
+// GET /users/{username}/usage
+func GetUserUsage(w http.ResponseWriter, r *http.Request) {
+	plog.Debug("HTTP handler called", "handler", "GetUserUsage")
+
+	var (
+		ctx      = r.Context()
+		uname    = ctx.Value("user").(string)
+		role     = ctx.Value("role").(rbac.Role)
+		vars     = mux.Vars(r)
+		username = vars["username"]
+	)
+
+	// Users can always see their own usage.
+	if username != uname && !role.Allowed("users", "get", username) {
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return
+	}
+
+	u, err := rbac.GetUser(username)
+	if err != nil {
+		http.Error(w, "unable to get user", http.StatusNotFound)
+		return
+	}
+
+	usage, err := quota.Get(username)
+	if err != nil {
+		plog.Error("getting usage for user", "user", username, "err", err)
+		http.Error(w, "unable to get user usage", http.StatusInternalServerError)
+		return
+	}
+
+	body, err := json.Marshal(UserUsage{Quota: u.Quota(), Usage: usage})
+	if err != nil {
+		plog.Error("marshaling user usage", "user", username, "err", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Write(body)
+}
+
 // DELETE /users/{username}
 func DeleteUser(w http.ResponseWriter, r *http.Request) {
 	plog.Debug("HTTP handler called", "handler", "DeleteUser")
@@ -39,6 +39,7 @@ func ExperimentToProtobuf(exp types.Experiment, status cache.Status, vms []mm.VM
 	}
 
 	pb.Apps = apps
+	pb.AppNextRun = exp.Status.AppNextRun()
 
 	var aliases map[string]int
 
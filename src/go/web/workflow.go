@@ -1,7 +1,6 @@
 package web
 
 import (
-	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
@@ -15,13 +14,10 @@ import (
 	"phenix/types/version"
 	"phenix/util/common"
 	"phenix/util/plog"
-	"phenix/web/broker"
 	"phenix/web/cache"
 	"phenix/web/rbac"
 	"phenix/web/weberror"
 
-	bt "phenix/web/broker/brokertypes"
-
 	"github.com/gorilla/mux"
 	"github.com/mitchellh/mapstructure"
 )
@@ -141,6 +137,7 @@ func ApplyWorkflow(w http.ResponseWriter, r *http.Request) error {
 			experiment.CreateWithDeployMode(wf.ExperimentDeployMode()),
 			experiment.CreateWithDefaultBridge(wf.DefaultBridgeName()),
 			experiment.CreateWithGREMesh(wf.UseGREMesh),
+			experiment.CreateWithOwner(ctx.Value("user").(string)),
 		}
 
 		if err := experiment.Create(ctx, opts...); err != nil {
@@ -151,7 +148,7 @@ func ApplyWorkflow(w http.ResponseWriter, r *http.Request) error {
 		if wf.AutoRestart() {
 			cache.UnlockExperiment(expName)
 
-			if _, err := startExperiment(expName); err != nil {
+			if _, err := startExperiment(expName, ctx.Value("user").(string)); err != nil {
 				return err
 			}
 		}
@@ -332,7 +329,7 @@ func ApplyWorkflow(w http.ResponseWriter, r *http.Request) error {
 		if wf.AutoRestart() {
 			cache.UnlockExperiment(expName)
 
-			if _, err := startExperiment(expName); err != nil {
+			if _, err := startExperiment(expName, ctx.Value("user").(string)); err != nil {
 				return err
 			}
 		}
@@ -467,20 +464,7 @@ func WorkflowUpsertConfig(w http.ResponseWriter, r *http.Request) error {
 	w.Header().Set("Location", strings.ToLower(fmt.Sprintf("/api/v1/configs/%s", name)))
 	w.WriteHeader(http.StatusCreated)
 
-	cfg.Spec = nil
-	cfg.Status = nil
-
-	body, err := json.Marshal(cfg)
-	if err != nil {
-		plog.Error("marshaling config", "config", cfg.FullName(), "err", err)
-		return nil
-	}
-
-	broker.Broadcast(
-		bt.NewRequestPolicy("configs", "list", cfg.FullName()),
-		bt.NewResource("config", cfg.FullName(), "create"),
-		body,
-	)
+	// The config creation is published to clients by the broker's store watcher.
 
 	return nil
 }
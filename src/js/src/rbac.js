@@ -22,7 +22,11 @@ let roleAllowed = (role, resource, verb, ...names) => {
                 return true
               }
               for (const name of names) {
-                if (name && resourceNameAllowed(p, name)) {
+                if (name && p.ownership && ownershipNameAllowed(p, name)) {
+                  cache.set(k, true)
+                  return true
+                }
+                if (name && !p.ownership && resourceNameAllowed(p, name)) {
                   cache.set(k, true)
                   return true
                 }
@@ -57,4 +61,14 @@ let resourceNameAllowed = (policy, name) => {
     return allowed
 }
 
+// Experiment ownership isn't known client-side, so ownership policies (see
+// ownership.go#ownershipAllowed) are assumed to apply and are enforced by the
+// server. Resource names further restrict them if provided.
+let ownershipNameAllowed = (policy, name) => {
+    if (!policy.resourceNames || policy.resourceNames.length == 0) {
+      return true
+    }
+    return resourceNameAllowed(policy, name)
+}
+
 export {roleAllowed};
\ No newline at end of file
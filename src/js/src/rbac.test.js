@@ -87,4 +87,28 @@ test('resourceName mid-wildcard', () => {
     expect(roleAllowed(testRole, "items", "delete", "item")).toBe(true)
     expect(roleAllowed(testRole, "items", "delete", "item1")).toBe(true)
     expect(roleAllowed(testRole, "items", "delete", "thing")).toBe(false)
-})
\ No newline at end of file
+})
+test('ownership policies are left to the server', () => {
+    const ownerRole = {
+        "name": "Owner Role",
+        "policies": [
+            {
+                "resources": ["experiments", "experiments/*"],
+                "resourceNames": null,
+                "verbs": ["*"],
+                "ownership": "owned"
+            },
+            {
+                "resources": ["vms/vnc"],
+                "resourceNames": ["exp1/*"],
+                "verbs": ["get"],
+                "ownership": "shared"
+            }
+        ]
+    }
+
+    expect(roleAllowed(ownerRole, "experiments/start", "update", "expA")).toBe(true)
+    expect(roleAllowed(ownerRole, "vms/vnc", "get", "exp1/vm1")).toBe(true)
+    expect(roleAllowed(ownerRole, "vms/vnc", "get", "exp2/vm1")).toBe(false)
+    expect(roleAllowed(ownerRole, "vms", "get", "exp1/vm1")).toBe(false)
+})